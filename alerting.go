@@ -0,0 +1,380 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultAlertKey is the default key of the group which holds the details of an alert in the records synthesized
+	// by an [AlertingHandler].
+	//
+	// This value is used when the alert key in [AlertingHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AlertingHandlerOptions
+	DefaultAlertKey = "alert"
+
+	// DefaultAlertMessage is the default message of the records synthesized by an [AlertingHandler] when a rule's
+	// threshold is crossed.
+	//
+	// This value is used when the message in [AlertRule] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AlertRule
+	DefaultAlertMessage = "alert threshold exceeded"
+)
+
+// AlertRule holds a single rule evaluated by an [AlertingHandler].
+//
+// An alert is raised whenever the number of records matching the rule within the window reaches the threshold.
+type AlertRule struct {
+	// Cooldown is the minimum time between two alerts raised by the rule.
+	//
+	// Records matching the rule during the cooldown are still counted and the number of alerts suppressed by the
+	// cooldown is reported in the next alert.
+	//
+	// The default behavior is to use the window of the rule.
+	Cooldown time.Duration
+
+	// Level is the minimum level of records matching the rule.
+	//
+	// The default behavior is to match records at [slog.LevelError] or above.
+	Level slog.Leveler
+
+	// Match is called for each record at or above the level of the rule to decide whether or not it matches the rule
+	// (eg: by checking its message or attributes).
+	//
+	// The function must not retain or modify the record.
+	//
+	// The default behavior is to match every record at or above the level of the rule.
+	Match func(r slog.Record) bool
+
+	// Message is the message of the alert records raised by the rule (eg: "too many payment failures").
+	//
+	// The default behavior is defined by the default alert message setting defined in the package.
+	Message string
+
+	// Name is the name of the rule, which is included in each alert.
+	Name string
+
+	// Threshold is the number of records matching the rule within the window which raises an alert.
+	Threshold int
+
+	// Window is the length of the window over which matching records are counted.
+	Window time.Duration
+}
+
+// AlertingHandlerOptions holds the options for an [AlertingHandler].
+type AlertingHandlerOptions struct {
+	// AlertHandler is the handler to which alert records are sent (eg: a handler which posts to Slack or PagerDuty).
+	AlertHandler slog.Handler
+
+	// AlertKey is the key of the group which holds the details of each alert.
+	//
+	// The default behavior is defined by the default alert key setting defined in the package.
+	AlertKey string
+
+	// AlertLevel is the level of alert records.
+	//
+	// The default behavior is to raise alerts at [slog.LevelError].
+	AlertLevel slog.Leveler
+
+	// Clock is the clock used to decide which records fall within the window of each rule.
+	//
+	// The default behavior is to use [DefaultClock].
+	Clock Clock
+
+	// Rules holds the rules which are evaluated for each record.
+	Rules []AlertRule
+}
+
+// AlertingHandler is a handler which passes records to an underlying handler and evaluates a set of simple threshold
+// rules (eg: 10 errors within 5 minutes) against them, sending a synthesized alert record to a designated alert
+// handler whenever a rule's threshold is crossed.
+//
+// This turns the logging pipeline into a lightweight alert source for small deployments which do not run a separate
+// alerting system. Each alert record holds a group with the name of the rule, the threshold, the length of the window,
+// the times of the first and last matching records, the message of the last matching record and the number of alerts
+// suppressed by the cooldown since the previous alert.
+//
+// Records are evaluated against the rules even if the underlying handler is not enabled for them. Handlers derived
+// using WithAttrs or WithGroup share the same rules and counts, and their attributes and groups are not added to
+// alert records.
+//
+// All methods are safe to call concurrently.
+type AlertingHandler struct {
+	// unexported variables
+	handler slog.Handler   // underlying handler
+	state   *alertingState // state shared by all derived handlers
+}
+
+// ensure [AlertingHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &AlertingHandler{}
+
+// alertingState holds the rules and counts shared by all handlers derived from the same alerting handler.
+type alertingState struct {
+	minLevel slog.Level             // lowest level of all of the rules
+	mu       sync.Mutex             // protects the rule states
+	options  AlertingHandlerOptions // immutable handler options
+	rules    []alertRuleState       // state of each rule, in the same order as the rules in the options
+}
+
+// alertRuleState holds the times of the most recent records matching a single rule.
+type alertRuleState struct {
+	last       time.Time   // time of the last alert raised by the rule
+	message    string      // message of the most recent matching record
+	next       int         // index at which the time of the next matching record is stored
+	suppressed int         // number of alerts suppressed by the cooldown since the last alert
+	times      []time.Time // times of up to threshold most recent matching records, wrapping around once full
+}
+
+// NewAlertingHandler creates a new [AlertingHandler] which passes records to the given handler and sends alerts to
+// the alert handler in the options.
+//
+// If the handler is nil, records are evaluated against the rules but not written anywhere.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the alert handler is nil, there are no rules or a rule has no name, a threshold or
+//     window less than 1 or a negative cooldown
+func NewAlertingHandler(h slog.Handler, options AlertingHandlerOptions) (*AlertingHandler, xerrors.Error) {
+	var fields []string
+	var problems []error
+	if options.AlertHandler == nil {
+		fields = append(fields, "alert_handler")
+		problems = append(problems, errors.New("alert_handler: must not be nil"))
+	}
+	if len(options.Rules) == 0 {
+		fields = append(fields, "rules")
+		problems = append(problems, errors.New("rules: at least one rule is required"))
+	}
+	for i, rule := range options.Rules {
+		if rule.Name == "" {
+			fields = append(fields, fmt.Sprintf("rules.%d.name", i))
+			problems = append(problems, fmt.Errorf("rules.%d.name: must not be empty", i))
+		}
+		if rule.Threshold < 1 {
+			fields = append(fields, fmt.Sprintf("rules.%d.threshold", i))
+			problems = append(problems, fmt.Errorf("rules.%d.threshold: %d: must be greater than 0", i,
+				rule.Threshold))
+		}
+		if rule.Window <= 0 {
+			fields = append(fields, fmt.Sprintf("rules.%d.window", i))
+			problems = append(problems, fmt.Errorf("rules.%d.window: %s: must be greater than 0", i, rule.Window))
+		}
+		if rule.Cooldown < 0 {
+			fields = append(fields, fmt.Sprintf("rules.%d.cooldown", i))
+			problems = append(problems, fmt.Errorf("rules.%d.cooldown: %s: must not be negative", i, rule.Cooldown))
+		}
+	}
+	if len(problems) > 0 {
+		err := errors.Join(problems...)
+		return nil, xerrors.Wrapf(OptionsValidationError, err, "invalid alerting handler options: %s", err.Error()).
+			WithAttr("fields", fields)
+	}
+
+	if h == nil {
+		h = slog.DiscardHandler
+	}
+	if options.AlertKey == "" {
+		options.AlertKey = DefaultAlertKey
+	}
+	if options.AlertLevel == nil {
+		options.AlertLevel = slog.LevelError
+	}
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	rules := make([]AlertRule, len(options.Rules))
+	states := make([]alertRuleState, len(options.Rules))
+	minLevel := slog.Level(math.MaxInt)
+	for i, rule := range options.Rules {
+		if rule.Cooldown == 0 {
+			rule.Cooldown = rule.Window
+		}
+		if rule.Level == nil {
+			rule.Level = slog.LevelError
+		}
+		if rule.Message == "" {
+			rule.Message = DefaultAlertMessage
+		}
+		rules[i] = rule
+		states[i].times = make([]time.Time, rule.Threshold)
+		minLevel = min(minLevel, rule.Level.Level())
+	}
+	options.Rules = rules
+
+	return &AlertingHandler{
+		handler: h,
+		state: &alertingState{
+			minLevel: minLevel,
+			options:  options,
+			rules:    states,
+		},
+	}, nil
+}
+
+// ChildHandlers returns the underlying handler and the alert handler.
+func (a *AlertingHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{a.handler, a.state.options.AlertHandler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level or records at the given level
+// may match any of the rules.
+//
+// Note that rule levels are only read when the handler is created, so a rule whose level changes afterwards may not
+// see records below its original level.
+func (a *AlertingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= a.state.minLevel || a.handler.Enabled(ctx, level)
+}
+
+// Handle evaluates the record against each rule, sends an alert record to the alert handler for each rule whose
+// threshold is crossed and passes the record to the underlying handler if the handler is enabled for its level.
+//
+// Any errors returned by the underlying handler and the alert handler are joined together.
+func (a *AlertingHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, alert := range a.state.evaluate(r) {
+		if err := a.state.raise(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if a.handler.Enabled(ctx, r.Level) {
+		if err := a.handler.Handle(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options returns a copy of the handler's options.
+func (a *AlertingHandler) Options() any {
+	options := a.state.options
+	options.Rules = append([]AlertRule(nil), options.Rules...)
+	return options
+}
+
+// Type returns the type of the handler.
+func (a *AlertingHandler) Type() string {
+	return "alerting"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (a *AlertingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return a
+	}
+	return &AlertingHandler{
+		handler: a.handler.WithAttrs(attrs),
+		state:   a.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (a *AlertingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return a
+	}
+	return &AlertingHandler{
+		handler: a.handler.WithGroup(name),
+		state:   a.state,
+	}
+}
+
+// alert holds the details of a single alert raised by a rule.
+type alert struct {
+	first      time.Time // time of the oldest matching record within the window
+	last       time.Time // time of the matching record which crossed the threshold
+	message    string    // message of the matching record which crossed the threshold
+	rule       AlertRule // rule which raised the alert
+	suppressed int       // number of alerts suppressed by the cooldown since the previous alert
+}
+
+// evaluate counts the record against each rule it matches and returns the alerts raised as a result.
+func (st *alertingState) evaluate(r slog.Record) []alert {
+	if r.Level < st.minLevel {
+		return nil
+	}
+	matched := make([]bool, len(st.options.Rules))
+	matchedAny := false
+	for i, rule := range st.options.Rules {
+		if r.Level >= rule.Level.Level() && (rule.Match == nil || rule.Match(r)) {
+			matched[i] = true
+			matchedAny = true
+		}
+	}
+	if !matchedAny {
+		return nil
+	}
+
+	var alerts []alert
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := st.options.Clock.Now()
+	for i, rule := range st.options.Rules {
+		if !matched[i] {
+			continue
+		}
+		rs := &st.rules[i]
+		rs.times[rs.next] = now
+		rs.next = (rs.next + 1) % len(rs.times)
+		rs.message = r.Message
+
+		// the oldest stored time is the one which will be overwritten next
+		oldest := rs.times[rs.next]
+		if oldest.IsZero() || now.Sub(oldest) > rule.Window {
+			continue
+		}
+		if !rs.last.IsZero() && now.Sub(rs.last) < rule.Cooldown {
+			rs.suppressed++
+			continue
+		}
+		alerts = append(alerts, alert{
+			first:      oldest,
+			last:       now,
+			message:    rs.message,
+			rule:       rule,
+			suppressed: rs.suppressed,
+		})
+		rs.last = now
+		rs.suppressed = 0
+	}
+	return alerts
+}
+
+// raise sends a record describing the given alert to the alert handler.
+func (st *alertingState) raise(ctx context.Context, a alert) error {
+	level := st.options.AlertLevel.Level()
+	h := st.options.AlertHandler
+	if !h.Enabled(ctx, level) {
+		return nil
+	}
+	rule := a.rule
+	r := slog.NewRecord(a.last, level, rule.Message, 0)
+	r.AddAttrs(slog.Group(st.options.AlertKey,
+		slog.String("rule", rule.Name),
+		slog.Int("threshold", rule.Threshold),
+		slog.String("window", rule.Window.String()),
+		slog.Time("first", a.first),
+		slog.Time("last", a.last),
+		slog.String("sample", a.message),
+		slog.Int("suppressed", a.suppressed),
+	))
+	if err := h.Handle(ctx, r); err != nil {
+		return xerrors.Wrapf(HandleRecordError, err, "failed to send '%s' alert: %s", rule.Name, err.Error()).
+			WithAttr("rule", rule.Name)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package xlog
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+)
+
+var (
+	// DefaultAttrOverflowKey is the default key of the attribute which summarizes the attributes or map keys dropped
+	// because a limit was exceeded (eg: "+42 more").
+	//
+	// This value is used when the overflow key in [AttrLimitOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AttrLimitOptions
+	DefaultAttrOverflowKey = "_overflow"
+)
+
+// AttrLimitOptions holds the options for the handler returned by [NewAttrLimitHandler].
+//
+// Limits which are less than or equal to 0 are not enforced.
+type AttrLimitOptions struct {
+	// MaxAttrs is the maximum number of attributes at the top level of a record and inside of each group.
+	//
+	// Attributes beyond the limit are dropped and summarized in an overflow attribute.
+	MaxAttrs int
+
+	// MaxDepth is the maximum nesting depth of groups, where attributes at the top level of a record have a depth
+	// of 1.
+	//
+	// Groups which would exceed the limit are replaced with a summary of the number of attributes they hold.
+	MaxDepth int
+
+	// MaxMapKeys is the maximum number of keys of each map held by an attribute value.
+	//
+	// Maps with more keys are replaced with a map holding the first keys in sorted order and an overflow key
+	// summarizing the rest.
+	MaxMapKeys int
+
+	// OverflowKey is the key of the attribute or map key which summarizes the dropped attributes or map keys.
+	//
+	// The default behavior is defined by the default attribute overflow key setting defined in the package.
+	OverflowKey string
+}
+
+// attrLimitHandler is the [slog.Handler] returned by [NewAttrLimitHandler].
+type attrLimitHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	options AttrLimitOptions // immutable handler options
+}
+
+// ensure [attrLimitHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &attrLimitHandler{}
+
+// NewAttrLimitHandler returns a new [slog.Handler] which limits the number of attributes, the nesting depth of groups
+// and the number of keys in maps held by attribute values, which protects sinks with limits on the number of fields
+// (eg: mapping explosions in Elasticsearch or facet limits in Datadog).
+//
+// Whatever is dropped is summarized (eg: "+42 more") so that it is clear the record was limited. The limits apply to
+// the complete set of attributes, including those added using WithAttrs.
+func NewAttrLimitHandler(h slog.Handler, options AttrLimitOptions) slog.Handler {
+	if options.OverflowKey == "" {
+		options.OverflowKey = DefaultAttrOverflowKey
+	}
+	return &attrLimitHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		options:         options,
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *attrLimitHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *attrLimitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle combines the handler's attributes with the record's attributes, applies the limits and passes the resulting
+// record to the underlying handler.
+func (h *attrLimitHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(h.limitAttrs(h.mergeAttrs(r), 1)...)
+	return h.handler.Handle(ctx, record)
+}
+
+// Options returns the handler's options.
+func (h *attrLimitHandler) Options() any {
+	return h.options
+}
+
+// Type returns the type of the handler.
+func (h *attrLimitHandler) Type() string {
+	return "attr_limit"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *attrLimitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *attrLimitHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// limitAttrs returns the given attributes, found at the given depth, with the limits applied.
+func (h *attrLimitHandler) limitAttrs(attrs []slog.Attr, depth int) []slog.Attr {
+	limited := make([]slog.Attr, 0, len(attrs))
+	dropped := 0
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if h.options.MaxAttrs > 0 && len(limited) >= h.options.MaxAttrs {
+			dropped++
+			continue
+		}
+
+		switch attr.Value.Kind() {
+		case slog.KindGroup:
+			group := attr.Value.Group()
+			if attr.Key == "" {
+				// groups with empty keys are inlined into their parent
+				for _, child := range h.limitAttrs(group, depth) {
+					if h.options.MaxAttrs > 0 && len(limited) >= h.options.MaxAttrs {
+						dropped++
+						continue
+					}
+					limited = append(limited, child)
+				}
+				continue
+			}
+			if h.options.MaxDepth > 0 && depth >= h.options.MaxDepth {
+				attr.Value = slog.StringValue(fmt.Sprintf("+%d more", len(group)))
+			} else {
+				attr.Value = slog.GroupValue(h.limitAttrs(group, depth+1)...)
+			}
+		case slog.KindAny:
+			if h.options.MaxMapKeys > 0 {
+				attr.Value = h.limitMap(attr.Value)
+			}
+		}
+		limited = append(limited, attr)
+	}
+	if dropped > 0 {
+		limited = append(limited, slog.String(h.options.OverflowKey, fmt.Sprintf("+%d more", dropped)))
+	}
+	return limited
+}
+
+// limitMap returns the given value unchanged unless it holds a map with more keys than allowed, in which case a map
+// holding the first keys in sorted order and an overflow key summarizing the rest is returned.
+func (h *attrLimitHandler) limitMap(v slog.Value) slog.Value {
+	rv := reflect.ValueOf(v.Any())
+	if rv.Kind() != reflect.Map || rv.Len() <= h.options.MaxMapKeys {
+		return v
+	}
+
+	keys := rv.MapKeys()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = fmt.Sprint(key.Interface())
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return cmp.Compare(names[a], names[b]) })
+
+	limited := make(map[string]any, h.options.MaxMapKeys+1)
+	for _, i := range order[:h.options.MaxMapKeys] {
+		limited[names[i]] = rv.MapIndex(keys[i]).Interface()
+	}
+	limited[h.options.OverflowKey] = fmt.Sprintf("+%d more", len(keys)-h.options.MaxMapKeys)
+	return slog.AnyValue(limited)
+}
@@ -0,0 +1,127 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// LevelAudit is the level for audit records describing regulated actions (eg: authentication changes or data
+	// exports) which are logged using an [AuditLogger].
+	//
+	// The level is above [slog.LevelError] so that audit records pass through handlers which only log errors.
+	LevelAudit = slog.Level(10)
+)
+
+// AuditLogger logs audit records synchronously through a designated handler chain and reports every failure to the
+// caller so that regulated actions can be refused when they cannot be recorded.
+//
+// Unlike a [slog.Logger], records are never silently discarded: an error is returned if the handler is not enabled
+// for [LevelAudit] records, if it fails to handle the record or if it fails to flush the record to its destination.
+// All methods are safe to call concurrently.
+type AuditLogger struct {
+	// unexported variables
+	handler slog.Handler // handler through which audit records are delivered
+}
+
+// NewAuditLogger creates a new [AuditLogger] which delivers audit records through the given handler.
+//
+// The handler chain should not sample or drop records. Handlers which may deliver records after they have been
+// handled and flushed (ie: handlers implementing [AsyncHandler] which report that they are asynchronous, such as
+// handlers returned by [Pipeline.Handler]) are rejected since the delivery of records cannot be acknowledged. After
+// each record is handled, every handler in the chain with a Flush function returning an error is flushed so that
+// buffered records reach their destination before the logging function returns.
+//
+// This function may return an error with any of the following codes:
+//   - [InvalidParameter]: the handler is nil or handles records asynchronously
+func NewAuditLogger(h slog.Handler) (*AuditLogger, xerrors.Error) {
+	if h == nil {
+		return nil, xerrors.New(InvalidParameter, "audit handler cannot be nil")
+	}
+	if IsAsyncHandler(h) {
+		return nil, xerrors.New(InvalidParameter, "audit handler cannot handle records asynchronously")
+	}
+	return &AuditLogger{
+		handler: h,
+	}, nil
+}
+
+// Handler returns the handler through which audit records are delivered.
+func (a *AuditLogger) Handler() slog.Handler {
+	return a.handler
+}
+
+// Log logs an audit record with the given message and attributes, which are given as alternating keys and values in
+// the same way as [slog.Logger.Log].
+//
+// This function may return an error with any of the following codes:
+//   - [AuditDeliveryError]: the record could not be delivered
+func (a *AuditLogger) Log(ctx context.Context, msg string, args ...any) xerrors.Error {
+	r := a.newRecord(msg)
+	r.Add(args...)
+	return a.deliver(ctx, r)
+}
+
+// LogAttrs logs an audit record with the given message and attributes.
+//
+// This function may return an error with any of the following codes:
+//   - [AuditDeliveryError]: the record could not be delivered
+func (a *AuditLogger) LogAttrs(ctx context.Context, msg string, attrs ...slog.Attr) xerrors.Error {
+	r := a.newRecord(msg)
+	r.AddAttrs(attrs...)
+	return a.deliver(ctx, r)
+}
+
+// With returns a new [AuditLogger] which includes the given attributes, which are given as alternating keys and
+// values in the same way as [slog.Logger.With], in each record.
+func (a *AuditLogger) With(args ...any) *AuditLogger {
+	if len(args) == 0 {
+		return a
+	}
+	return &AuditLogger{
+		handler: slog.New(a.handler).With(args...).Handler(),
+	}
+}
+
+// WithGroup returns a new [AuditLogger] which nests the attributes of each record in the given group.
+func (a *AuditLogger) WithGroup(name string) *AuditLogger {
+	if name == "" {
+		return a
+	}
+	return &AuditLogger{
+		handler: a.handler.WithGroup(name),
+	}
+}
+
+// deliver handles the record and flushes the handler chain, returning an error if any step fails.
+//
+// This function may return an error with any of the following codes:
+//   - [AuditDeliveryError]: the record could not be delivered
+func (a *AuditLogger) deliver(ctx context.Context, r slog.Record) xerrors.Error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !a.handler.Enabled(ctx, LevelAudit) {
+		return xerrors.New(AuditDeliveryError, "audit handler is not enabled for audit records").
+			WithAttr("message", r.Message)
+	}
+	if err := a.handler.Handle(ctx, r); err != nil {
+		return xerrors.Wrapf(AuditDeliveryError, err, "failed to handle audit record: %s", err.Error()).
+			WithAttr("message", r.Message)
+	}
+	if err := FlushHandler(a.handler); err != nil {
+		return xerrors.Wrapf(AuditDeliveryError, err, "failed to flush audit record: %s", err.Error()).
+			WithAttr("message", r.Message)
+	}
+	return nil
+}
+
+// newRecord creates a new audit record with the given message and the caller of the public logging function.
+func (a *AuditLogger) newRecord(msg string) slog.Record {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip [runtime.Callers, newRecord, Log/LogAttrs]
+	return slog.NewRecord(DefaultClock.Now(), LevelAudit, msg, pcs[0])
+}
@@ -0,0 +1,107 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// baggageCtxKey is just a key for storing baggage in a context.
+type baggageCtxKey struct{}
+
+// baggageHandler is the [slog.Handler] returned by [NewBaggageHandler].
+type baggageHandler struct {
+	stampingWrapper
+}
+
+// ensure [baggageHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &baggageHandler{}
+
+// AddBaggageToContext adds the given attributes to the baggage stored in the existing context and returns a new
+// context.
+//
+// Baggage is a set of attributes (eg: a tenant or session ID) which should follow a request across services so that
+// the records logged by each service can be correlated. The httplog package propagates it between services in a
+// request header. An attribute with the same key as an attribute already in the baggage replaces it and attributes
+// with an empty key are ignored.
+func AddBaggageToContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	baggage := slices.Clone(BaggageFromContext(ctx))
+	for _, attr := range attrs {
+		if attr.Key == "" {
+			continue
+		}
+		if i := slices.IndexFunc(baggage, func(a slog.Attr) bool { return a.Key == attr.Key }); i >= 0 {
+			baggage[i] = attr
+			continue
+		}
+		baggage = append(baggage, attr)
+	}
+	return context.WithValue(ctx, baggageCtxKey{}, baggage)
+}
+
+// BaggageFromContext returns the baggage stored in the context using [AddBaggageToContext], in the order the
+// attributes were first added, or nil if there isn't any.
+//
+// The returned slice should not be modified.
+func BaggageFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	if baggage, ok := ctx.Value(baggageCtxKey{}).([]slog.Attr); ok {
+		return baggage
+	}
+	return nil
+}
+
+// NewBaggageHandler returns a new [slog.Handler] which adds the baggage stored in the context passed to Handle using
+// [AddBaggageToContext] to every record.
+//
+// The attributes are added at the top level of the record, outside of any groups, and attributes which the record
+// already holds at the top level are not replaced.
+func NewBaggageHandler(h slog.Handler) slog.Handler {
+	return &baggageHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *baggageHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *baggageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the baggage stored in the context, combines the handler's attributes with the record's attributes and
+// passes the resulting record to the underlying handler.
+func (h *baggageHandler) Handle(ctx context.Context, r slog.Record) error {
+	baggage := BaggageFromContext(ctx)
+	if len(baggage) == 0 && len(h.attrs) == 0 && len(h.groups) == 0 {
+		return h.handler.Handle(ctx, r)
+	}
+
+	return h.handler.Handle(ctx, h.stamp(r, baggage...))
+}
+
+// Options always returns nil since the handler has no options.
+func (h *baggageHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *baggageHandler) Type() string {
+	return "baggage"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *baggageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *baggageHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
@@ -0,0 +1,97 @@
+package xlog_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"go.innotegrity.dev/xlog"
+	"go.innotegrity.dev/xlog/xlogbench"
+)
+
+// benchmarkStack describes a middleware stack measured by [BenchmarkMiddleware].
+type benchmarkStack struct {
+	flush func(h slog.Handler) error        // flushes the stack, if it needs more than the default flush
+	name  string                            // name of the sub-benchmark
+	wrap  func(h slog.Handler) slog.Handler // wraps the underlying handler in the stack
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	var pipeline *xlog.Pipeline
+	stacks := []benchmarkStack{
+		{
+			name: "none",
+			wrap: func(h slog.Handler) slog.Handler { return h },
+		},
+		{
+			name: "attr_limit",
+			wrap: func(h slog.Handler) slog.Handler {
+				return xlog.NewAttrLimitHandler(h, xlog.AttrLimitOptions{MaxAttrs: 32, MaxDepth: 4, MaxMapKeys: 32})
+			},
+		},
+		{
+			name: "concurrency",
+			wrap: func(h slog.Handler) slog.Handler {
+				return xlog.NewConcurrencyHandler(h, xlog.ConcurrencyHandlerOptions{Instrument: true, MaxConcurrent: 4})
+			},
+		},
+		{
+			name: "dedup",
+			wrap: xlog.NewDedupHandler,
+		},
+		{
+			name: "flatten",
+			wrap: func(h slog.Handler) slog.Handler {
+				return xlog.NewFlattenHandler(h, xlog.FlattenHandlerOptions{})
+			},
+		},
+		{
+			name: "pipeline",
+			flush: func(h slog.Handler) error {
+				if err := pipeline.Close(context.Background()); err != nil {
+					return err
+				}
+				return nil
+			},
+			wrap: func(h slog.Handler) slog.Handler {
+				pipeline = xlog.NewPipeline(xlog.PipelineOptions{})
+				return pipeline.Handler("benchmark", h)
+			},
+		},
+		{
+			name: "rate_limit",
+			wrap: func(h slog.Handler) slog.Handler {
+				return xlog.NewRateLimitHandler(h, xlog.RateLimitHandlerOptions{Burst: 1000, KeyAttr: "path", Rate: 1e9})
+			},
+		},
+		{
+			name: "trace",
+			wrap: func(h slog.Handler) slog.Handler { return xlog.NewTraceHandler(h, "benchmark") },
+		},
+		{
+			name: "typical",
+			wrap: func(h slog.Handler) slog.Handler {
+				h = xlog.NewAttrLimitHandler(h, xlog.AttrLimitOptions{MaxAttrs: 32, MaxDepth: 4, MaxMapKeys: 32})
+				h = xlog.NewDedupHandler(h)
+				h = xlog.NewRateLimitHandler(h, xlog.RateLimitHandlerOptions{Burst: 1000, KeyAttr: "path", Rate: 1e9})
+				return xlog.NewTraceHandler(h, "benchmark")
+			},
+		},
+	}
+
+	for _, stack := range stacks {
+		b.Run(stack.name, func(b *testing.B) {
+			for _, goroutines := range []int{1, 8} {
+				b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+					h := stack.wrap(slog.NewJSONHandler(io.Discard, nil))
+					xlogbench.Benchmark(b, h, xlogbench.Options{
+						Flush:      stack.flush,
+						Goroutines: goroutines,
+					})
+				})
+			}
+		})
+	}
+}
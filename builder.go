@@ -33,3 +33,35 @@ type HandlerBuilder interface {
 	// Type should return the type of the handler.
 	Type() string
 }
+
+// HandlerBuilderValidator defines the interface for handler builders which are able to check their options without
+// actually building the handler.
+type HandlerBuilderValidator interface {
+	// Validate should pass a copy of the stored options to the callback function and check the result for problems
+	// without creating files, opening connections, sending data or modifying the stored options.
+	Validate(cb BuildHandlerCallbackFn) xerrors.Error
+}
+
+// ValidateConfig performs a "dry run" of building a handler with the given builder.
+//
+// The options are passed through the callback function and validated exactly as they would be when the handler is
+// built, but no handler is created, so configuration can be checked on machines which lack the target paths or
+// network access (eg: CI pipelines). The builder can still be used to build the handler afterwards.
+//
+// Builders which do not implement [HandlerBuilderValidator] are only checked for the problems which were found while
+// parsing their options, so this function returns nil for them.
+//
+// This function may return an error with any of the following codes:
+//   - [InvalidParameter]: the builder is nil
+//   - [OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func ValidateConfig(b HandlerBuilder, cb BuildHandlerCallbackFn) xerrors.Error {
+	if b == nil {
+		return xerrors.New(InvalidParameter, "handler builder cannot be nil")
+	}
+	if v, ok := b.(HandlerBuilderValidator); ok {
+		return v.Validate(cb)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	// DefaultBuildInfoKey is the default key of the group which holds the build information of the running binary.
+	//
+	// This value is used when the key in [BuildInfoHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BuildInfoHandlerOptions
+	DefaultBuildInfoKey = "build"
+
+	// _buildInfo holds the build information attributes, which are only read once.
+	_buildInfo = sync.OnceValue(readBuildInfo)
+)
+
+// BuildInfoHandlerOptions holds the options for the handler returned by [NewBuildInfoHandler].
+type BuildInfoHandlerOptions struct {
+	// Key is the key of the group which holds the build information.
+	//
+	// The default behavior is defined by the default build info key setting defined in the package.
+	Key string
+}
+
+// buildInfoHandler is the [slog.Handler] returned by [NewBuildInfoHandler].
+type buildInfoHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	build slog.Attr // group holding the build information
+}
+
+// ensure [buildInfoHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &buildInfoHandler{}
+
+// BuildInfoAttr returns a group with the given key holding the build information of the running binary, as read by
+// [debug.ReadBuildInfo], so that a record can be traced to the exact binary which logged it.
+//
+// The group holds the following attributes, where those which are not available are omitted:
+//   - "module": the path of the main module
+//   - "version": the version of the main module (eg: "v1.2.3" or "(devel)")
+//   - "revision": the VCS revision from which the binary was built
+//   - "dirty": whether or not the VCS working tree had uncommitted changes when the binary was built
+//   - "go_version": the version of Go which built the binary
+//
+// If key is empty, the default build info key defined in the package is used.
+func BuildInfoAttr(key string) slog.Attr {
+	if key == "" {
+		key = DefaultBuildInfoKey
+	}
+	return slog.Attr{Key: key, Value: slog.GroupValue(_buildInfo()...)}
+}
+
+// NewBuildInfoHandler returns a new [slog.Handler] which adds a group holding the build information of the running
+// binary, as returned by [BuildInfoAttr], to every record.
+//
+// The group is added at the top level of the record, outside of any groups, and records which already hold an
+// attribute with the same key at the top level are not changed.
+func NewBuildInfoHandler(h slog.Handler, options BuildInfoHandlerOptions) slog.Handler {
+	return &buildInfoHandler{
+		build:           BuildInfoAttr(options.Key),
+		stampingWrapper: stampingWrapper{handler: h},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *buildInfoHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *buildInfoHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the build information group, combines the handler's attributes with the record's attributes and passes
+// the resulting record to the underlying handler.
+func (h *buildInfoHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, h.stamp(r, h.build))
+}
+
+// Options returns the handler's options.
+func (h *buildInfoHandler) Options() any {
+	return BuildInfoHandlerOptions{
+		Key: h.build.Key,
+	}
+}
+
+// Type returns the type of the handler.
+func (h *buildInfoHandler) Type() string {
+	return "build_info"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *buildInfoHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *buildInfoHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// readBuildInfo returns the attributes describing the build information of the running binary.
+//
+// If the binary was built without module support, only the Go version is returned.
+func readBuildInfo() []slog.Attr {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return []slog.Attr{slog.String("go_version", runtime.Version())}
+	}
+
+	var attrs []slog.Attr
+	if info.Main.Path != "" {
+		attrs = append(attrs, slog.String("module", info.Main.Path))
+	}
+	if info.Main.Version != "" {
+		attrs = append(attrs, slog.String("version", info.Main.Version))
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			attrs = append(attrs, slog.String("revision", setting.Value))
+		case "vcs.modified":
+			attrs = append(attrs, slog.Bool("dirty", setting.Value == "true"))
+		}
+	}
+	return append(attrs, slog.String("go_version", info.GoVersion))
+}
@@ -0,0 +1,352 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultBurstKey is the default key of the group which holds the details of a burst in summary records.
+	//
+	// This value is used when the summary key in [BurstHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BurstHandlerOptions
+	DefaultBurstKey = "burst"
+
+	// DefaultBurstMaxBursts is the default maximum number of bursts a [BurstHandler] tracks at the same time.
+	//
+	// This value is used when the max bursts in [BurstHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BurstHandlerOptions
+	DefaultBurstMaxBursts = 1000
+
+	// DefaultBurstMaxSampleValues is the default maximum number of distinct values kept for each attribute of the
+	// records within a burst.
+	//
+	// This value is used when the max sample values in [BurstHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BurstHandlerOptions
+	DefaultBurstMaxSampleValues = 5
+
+	// DefaultBurstWindow is the default length of the window over which a [BurstHandler] collapses records with the
+	// same key.
+	//
+	// This value is used when the window in [BurstHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BurstHandlerOptions
+	DefaultBurstWindow = time.Minute
+)
+
+// BurstHandlerOptions holds the options for a [BurstHandler].
+type BurstHandlerOptions struct {
+	// Key is called for each record at or above the level to get the key by which records are grouped into bursts.
+	//
+	// The default behavior is to use [RecordFingerprint], so records logged from the same call site with the same
+	// message are grouped together.
+	Key func(r slog.Record) string
+
+	// Level is the minimum level of records which are collapsed into bursts.
+	//
+	// Records below this level are passed to the underlying handler unchanged.
+	//
+	// The default behavior is to collapse records at [slog.LevelError] or above.
+	Level slog.Leveler
+
+	// MaxBursts is the maximum number of bursts tracked at the same time.
+	//
+	// Records which would start a new burst once the limit is reached are passed to the underlying handler unchanged.
+	//
+	// The default behavior is defined by the default burst max bursts setting defined in the package.
+	MaxBursts int
+
+	// MaxSampleValues is the maximum number of distinct values kept for each attribute of the records within a burst.
+	//
+	// Set this value to a negative number to not keep any values.
+	//
+	// The default behavior is defined by the default burst max sample values setting defined in the package.
+	MaxSampleValues int
+
+	// SummaryKey is the key of the group which holds the details of the burst in summary records.
+	//
+	// The default behavior is defined by the default burst key setting defined in the package.
+	SummaryKey string
+
+	// Window is the length of time, starting with the first record of a burst, over which records with the same key
+	// are collapsed.
+	//
+	// The default behavior is defined by the default burst window setting defined in the package.
+	Window time.Duration
+}
+
+// BurstHandler is a handler which collapses bursts of repeated records (eg: the same error logged thousands of times
+// during an incident) into a single summary record, drastically reducing the volume sent to sinks which charge by
+// ingestion.
+//
+// The first record with a given key (see [BurstHandlerOptions]) is passed to the underlying handler immediately and
+// starts a burst. Any further records with the same key within the window are counted rather than written. When the
+// window ends, a summary record is written with the message, level and source of the first record and a group holding
+// the number of records in the burst ("count"), the number of records which were not written ("suppressed"), the
+// times of the first and last records ("first" and "last") and a sample of the distinct values of each top-level
+// attribute ("values"). No summary is written for a burst holding a single record.
+//
+// Unlike [NewDedupHandler], which removes duplicate attribute keys within a single record, this handler removes whole
+// records. Summaries are written through the same handler (including any attributes and groups added using WithAttrs
+// or WithGroup) as the first record of the burst. Handlers derived using WithAttrs or WithGroup share the same bursts.
+//
+// Call [BurstHandler.Flush] before the application exits to write the summaries of any bursts in progress.
+//
+// All methods are safe to call concurrently.
+type BurstHandler struct {
+	// unexported variables
+	handler slog.Handler // underlying handler
+	state   *burstState  // state shared by all derived handlers
+}
+
+// ensure [BurstHandler] implements the following interfaces.
+var (
+	_ ExtendedHandler = &BurstHandler{}
+	_ Flusher         = &BurstHandler{}
+)
+
+// burstState holds the bursts shared by all handlers derived from the same burst handler.
+type burstState struct {
+	bursts  map[string]*burst   // bursts in progress by key
+	mu      sync.Mutex          // protects the bursts
+	options BurstHandlerOptions // immutable handler options
+}
+
+// burst holds the details of the records within a single burst.
+type burst struct {
+	count   int                 // number of records within the burst
+	ctx     context.Context     // context of the first record, without its cancellation
+	first   time.Time           // time of the first record
+	handler slog.Handler        // handler to which the first record was passed
+	keys    []string            // keys of the sampled attributes, in the order in which they were first seen
+	last    time.Time           // time of the last record
+	level   slog.Level          // highest level of the records
+	message string              // message of the first record
+	pc      uintptr             // source of the first record
+	timer   *time.Timer         // timer which ends the burst
+	values  map[string][]string // distinct values of each sampled attribute
+}
+
+// NewBurstHandler creates a new [BurstHandler] which passes records to the given handler, collapsing bursts of
+// repeated records into summaries.
+func NewBurstHandler(h slog.Handler, options BurstHandlerOptions) *BurstHandler {
+	if options.Key == nil {
+		options.Key = RecordFingerprint
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelError
+	}
+	if options.MaxBursts <= 0 {
+		options.MaxBursts = DefaultBurstMaxBursts
+	}
+	if options.MaxSampleValues == 0 {
+		options.MaxSampleValues = DefaultBurstMaxSampleValues
+	}
+	if options.SummaryKey == "" {
+		options.SummaryKey = DefaultBurstKey
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultBurstWindow
+	}
+	return &BurstHandler{
+		handler: h,
+		state: &burstState{
+			bursts:  map[string]*burst{},
+			options: options,
+		},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (b *BurstHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{b.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (b *BurstHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return b.handler.Enabled(ctx, level)
+}
+
+// Flush ends every burst in progress, writing their summaries, and returns any errors joined together.
+func (b *BurstHandler) Flush() error {
+	st := b.state
+	st.mu.Lock()
+	bursts := make([]*burst, 0, len(st.bursts))
+	for key, bu := range st.bursts {
+		bu.timer.Stop()
+		bursts = append(bursts, bu)
+		delete(st.bursts, key)
+	}
+	st.mu.Unlock()
+
+	var errs []error
+	for _, bu := range bursts {
+		if err := st.summarize(bu); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handle passes the record to the underlying handler if it starts a new burst or is below the level, or counts it
+// in the burst in progress otherwise.
+func (b *BurstHandler) Handle(ctx context.Context, r slog.Record) error {
+	st := b.state
+	if r.Level < st.options.Level.Level() {
+		return b.handler.Handle(ctx, r)
+	}
+	key := st.options.Key(r)
+
+	st.mu.Lock()
+	if bu, ok := st.bursts[key]; ok {
+		bu.count++
+		bu.last = r.Time
+		bu.level = max(bu.level, r.Level)
+		st.sample(bu, r)
+		st.mu.Unlock()
+		return nil
+	}
+	if len(st.bursts) >= st.options.MaxBursts {
+		st.mu.Unlock()
+		return b.handler.Handle(ctx, r)
+	}
+	bu := &burst{
+		count:   1,
+		ctx:     context.WithoutCancel(ctx),
+		first:   r.Time,
+		handler: b.handler,
+		last:    r.Time,
+		level:   r.Level,
+		message: r.Message,
+		pc:      r.PC,
+		values:  map[string][]string{},
+	}
+	st.sample(bu, r)
+	st.bursts[key] = bu
+	bu.timer = time.AfterFunc(st.options.Window, func() {
+		st.end(key, bu)
+	})
+	st.mu.Unlock()
+
+	return b.handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the handler's options.
+func (b *BurstHandler) Options() any {
+	return b.state.options
+}
+
+// Type returns the type of the handler.
+func (b *BurstHandler) Type() string {
+	return "burst"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (b *BurstHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return b
+	}
+	return &BurstHandler{
+		handler: b.handler.WithAttrs(attrs),
+		state:   b.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (b *BurstHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return b
+	}
+	return &BurstHandler{
+		handler: b.handler.WithGroup(name),
+		state:   b.state,
+	}
+}
+
+// end ends the given burst once its window has passed and writes its summary, unless the burst was already ended by
+// a flush.
+func (st *burstState) end(key string, bu *burst) {
+	st.mu.Lock()
+	if st.bursts[key] != bu {
+		st.mu.Unlock()
+		return
+	}
+	delete(st.bursts, key)
+	st.mu.Unlock()
+
+	if err := st.summarize(bu); err != nil {
+		LogInternal(bu.ctx, slog.LevelError, InternalEventHandleError, "failed to handle record",
+			slog.String("handler_type", "burst"), slog.String("error", err.Error()))
+	}
+}
+
+// sample keeps the distinct values of the record's top-level attributes, up to the maximum number of values for each
+// attribute.
+//
+// The caller must hold the lock.
+func (st *burstState) sample(bu *burst, r slog.Record) {
+	if st.options.MaxSampleValues < 0 {
+		return
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "" {
+			return true
+		}
+		values, ok := bu.values[attr.Key]
+		if !ok {
+			bu.keys = append(bu.keys, attr.Key)
+		}
+		if len(values) < st.options.MaxSampleValues {
+			if value := attr.Value.Resolve().String(); !slices.Contains(values, value) {
+				bu.values[attr.Key] = append(values, value)
+			}
+		}
+		return true
+	})
+}
+
+// summarize writes the summary record for the given burst, which must no longer be in progress.
+//
+// Nothing is written if the burst holds a single record.
+func (st *burstState) summarize(bu *burst) error {
+	if bu.count < 2 || !bu.handler.Enabled(bu.ctx, bu.level) {
+		return nil
+	}
+
+	summary := []any{
+		slog.Int("count", bu.count),
+		slog.Int("suppressed", bu.count-1),
+		slog.Time("first", bu.first),
+		slog.Time("last", bu.last),
+	}
+	if len(bu.keys) > 0 {
+		values := make([]any, 0, len(bu.keys))
+		for _, key := range bu.keys {
+			values = append(values, slog.Any(key, bu.values[key]))
+		}
+		summary = append(summary, slog.Group("values", values...))
+	}
+	r := slog.NewRecord(bu.last, bu.level, bu.message, bu.pc)
+	r.AddAttrs(slog.Group(st.options.SummaryKey, summary...))
+	return bu.handler.Handle(bu.ctx, r)
+}
@@ -0,0 +1,120 @@
+package xlog
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+)
+
+const (
+	// CapabilityBatch indicates that a handler implements [BatchHandler].
+	CapabilityBatch Capability = "batch"
+
+	// CapabilityClose indicates that a handler implements [io.Closer].
+	CapabilityClose Capability = "close"
+
+	// CapabilityFlush indicates that a handler implements [Flusher].
+	CapabilityFlush Capability = "flush"
+
+	// CapabilityHealth indicates that a handler implements [HealthChecker].
+	CapabilityHealth Capability = "health"
+
+	// CapabilityLevelVar indicates that a handler implements [LevelVarHandler].
+	CapabilityLevelVar Capability = "level_var"
+
+	// CapabilityStats indicates that a handler reports statistics, either by implementing [HandlerStatsReporter] or
+	// by having a Stats function which takes no arguments and returns a single value (eg: [Pipeline.Stats]).
+	CapabilityStats Capability = "stats"
+)
+
+// Capability identifies an optional interface which a handler may implement.
+type Capability string
+
+// HandlerCapabilities describes the optional interfaces implemented by a handler and its children.
+type HandlerCapabilities struct {
+	// Capabilities holds the capabilities of the handler itself, sorted by name.
+	Capabilities []Capability `json:"capabilities"`
+
+	// Children holds the capabilities of each of the handler's children, as returned by
+	// [ExtendedHandler.ChildHandlers].
+	Children []HandlerCapabilities `json:"children,omitempty"`
+
+	// Type holds the type of the handler, as returned by [ExtendedHandler.Type], or its Go type if it does not
+	// implement [ExtendedHandler].
+	Type string `json:"type"`
+}
+
+// Capabilities reports which optional interfaces the given handler and all of its descendants implement, using
+// [ExtendedHandler.ChildHandlers] to walk the tree, so that applications and frameworks embedding the package can
+// adapt their behavior (eg: only offer a flush endpoint when a handler can be flushed).
+func Capabilities(h slog.Handler) HandlerCapabilities {
+	var caps HandlerCapabilities
+	if h == nil {
+		return caps
+	}
+	caps.Capabilities = []Capability{}
+	caps.Type = fmt.Sprintf("%T", h)
+
+	if _, ok := h.(BatchHandler); ok {
+		caps.Capabilities = append(caps.Capabilities, CapabilityBatch)
+	}
+	if _, ok := h.(io.Closer); ok {
+		caps.Capabilities = append(caps.Capabilities, CapabilityClose)
+	}
+	if _, ok := h.(Flusher); ok {
+		caps.Capabilities = append(caps.Capabilities, CapabilityFlush)
+	}
+	if _, ok := h.(HealthChecker); ok {
+		caps.Capabilities = append(caps.Capabilities, CapabilityHealth)
+	}
+	if _, ok := h.(LevelVarHandler); ok {
+		caps.Capabilities = append(caps.Capabilities, CapabilityLevelVar)
+	}
+	if reportsStats(h) {
+		caps.Capabilities = append(caps.Capabilities, CapabilityStats)
+	}
+
+	if eh, ok := h.(ExtendedHandler); ok {
+		caps.Type = eh.Type()
+		for _, child := range eh.ChildHandlers() {
+			caps.Children = append(caps.Children, Capabilities(child))
+		}
+	}
+	return caps
+}
+
+// Any returns whether or not the handler or any of its descendants has the given capability.
+func (c HandlerCapabilities) Any(capability Capability) bool {
+	if c.Has(capability) {
+		return true
+	}
+	return slices.ContainsFunc(c.Children, func(child HandlerCapabilities) bool {
+		return child.Any(capability)
+	})
+}
+
+// Has returns whether or not the handler itself has the given capability.
+func (c HandlerCapabilities) Has(capability Capability) bool {
+	return slices.Contains(c.Capabilities, capability)
+}
+
+// reportsStats returns whether or not the given handler reports statistics as described by [CapabilityStats].
+func reportsStats(h slog.Handler) bool {
+	if _, ok := h.(HandlerStatsReporter); ok {
+		return true
+	}
+	_, ok := statsMethod(h)
+	return ok
+}
+
+// statsMethod returns the Stats function of the given object if it has one which takes no arguments and returns a
+// single value.
+func statsMethod(v any) (reflect.Value, bool) {
+	m := reflect.ValueOf(v).MethodByName("Stats")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	return m, true
+}
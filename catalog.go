@@ -0,0 +1,61 @@
+package xlog
+
+import (
+	"strings"
+)
+
+var (
+	// DefaultMessageCatalog is the default catalog used to translate messages.
+	//
+	// This value is used when the catalog in [TemplateHandlerOptions] is nil and a locale is set.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TemplateHandlerOptions
+	DefaultMessageCatalog MessageCatalog
+
+	// DefaultMessageIDKey is the default key of the attribute which holds the untranslated message of a record, which
+	// serves as a stable identifier for the message in every locale.
+	//
+	// This value is used when the message ID key in [TemplateHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TemplateHandlerOptions
+	DefaultMessageIDKey = "message_id"
+)
+
+// MessageCatalog defines the interface for a source of translated messages.
+type MessageCatalog interface {
+	// Message should return the translation of the message with the given ID (ie: the message or template as it was
+	// logged) for the given locale (eg: "de-DE") and whether or not a translation exists.
+	//
+	// The translation may hold the same named placeholders as the original message (eg: "{user_id}").
+	Message(locale, id string) (string, bool)
+}
+
+// MapMessageCatalog is a simple [MessageCatalog] holding translations keyed by locale and then by message ID.
+type MapMessageCatalog map[string]map[string]string
+
+// ensure [MapMessageCatalog] implements [MessageCatalog] interface.
+var _ MessageCatalog = MapMessageCatalog{}
+
+// Message returns the translation of the message with the given ID for the given locale and whether or not one exists.
+func (c MapMessageCatalog) Message(locale, id string) (string, bool) {
+	msg, ok := c[locale][id]
+	return msg, ok
+}
+
+// translateMessage returns the translation of the message with the given ID from the catalog for the given locale,
+// falling back on the locale's base language (eg: "pt" for "pt-BR"), and whether or not a translation was found.
+func translateMessage(c MessageCatalog, locale, id string) (string, bool) {
+	if msg, ok := c.Message(locale, id); ok {
+		return msg, true
+	}
+	if base, _, ok := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-"); ok {
+		return c.Message(base, id)
+	}
+	return "", false
+}
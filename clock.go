@@ -0,0 +1,114 @@
+package xlog
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	// TimestampPolicyClock replaces the zero time of a record with the current time from the handler's clock.
+	TimestampPolicyClock TimestampPolicy = "clock"
+
+	// TimestampPolicyDrop drops records with a zero time.
+	TimestampPolicyDrop TimestampPolicy = "drop"
+
+	// TimestampPolicyKeep leaves the time of every record unchanged, so records with a zero time are logged without
+	// one.
+	TimestampPolicyKeep TimestampPolicy = "keep"
+
+	// TimestampPolicyOverride replaces the time of every record with the current time from the handler's clock.
+	//
+	// This is mainly useful for deterministic tests and simulations, since [slog.Logger] always sets the time of a
+	// record to the current system time.
+	TimestampPolicyOverride TimestampPolicy = "override"
+)
+
+var (
+	// DefaultClock is the clock used by handlers to get the current time.
+	//
+	// This value is used when the clock in a handler's options is nil and by functions in this package which need the
+	// current time.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Clock
+	DefaultClock Clock = ClockFunc(time.Now)
+
+	// DefaultTimestampPolicy is the policy used by handlers for the time of each record.
+	//
+	// This value is used when the timestamp policy in a handler's options is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimestampPolicy
+	DefaultTimestampPolicy = TimestampPolicyKeep
+)
+
+// Clock defines the interface for a source of the current time, which allows the time used by handlers to be
+// controlled in tests and simulations.
+type Clock interface {
+	// Now should return the current time.
+	Now() time.Time
+}
+
+// ClockFunc is an adapter which allows an ordinary function to be used as a [Clock].
+type ClockFunc func() time.Time
+
+// Now returns the result of calling the function.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// TimestampPolicy defines how a handler treats the time of each record.
+type TimestampPolicy string
+
+// IsValid returns whether or not the policy is one of the pre-defined policies or empty.
+func (p TimestampPolicy) IsValid() bool {
+	switch p {
+	case TimestampPolicyClock, TimestampPolicyDrop, TimestampPolicyKeep, TimestampPolicyOverride, "":
+		return true
+	}
+	return false
+}
+
+// ApplyTimestampPolicy applies the given policy to the time of the record, returning the updated record and whether
+// or not it should be handled.
+//
+// If the policy is empty, [DefaultTimestampPolicy] is used. If the clock is nil, [DefaultClock] is used. Handlers
+// which support a timestamp policy should call this function at the start of their Handle function.
+func ApplyTimestampPolicy(r slog.Record, policy TimestampPolicy, clock Clock) (slog.Record, bool) {
+	if policy == "" {
+		policy = DefaultTimestampPolicy
+	}
+	if clock == nil {
+		clock = DefaultClock
+	}
+	switch policy {
+	case TimestampPolicyClock:
+		if r.Time.IsZero() {
+			r.Time = clock.Now()
+		}
+	case TimestampPolicyDrop:
+		return r, !r.Time.IsZero()
+	case TimestampPolicyOverride:
+		r.Time = clock.Now()
+	}
+	return r, true
+}
+
+// RecordExpired returns whether or not a record with the given time is older than the given maximum age.
+//
+// Records without a time never expire and nothing expires if the maximum age is 0 or less. If the clock is nil,
+// [DefaultClock] is used. Handlers which queue records or batches for later delivery should call this function before
+// delivering them and drop them with [DropReasonExpired] if they have expired.
+func RecordExpired(t time.Time, maxAge time.Duration, clock Clock) bool {
+	if maxAge <= 0 || t.IsZero() {
+		return false
+	}
+	if clock == nil {
+		clock = DefaultClock
+	}
+	return clock.Now().Sub(t) > maxAge
+}
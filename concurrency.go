@@ -0,0 +1,302 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// DefaultConcurrencyBuckets is the default upper bounds of the buckets of the histograms recorded by a
+	// [ConcurrencyHandler].
+	//
+	// This value is used when the buckets in [ConcurrencyHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#ConcurrencyHandlerOptions
+	DefaultConcurrencyBuckets = []time.Duration{
+		10 * time.Microsecond,
+		50 * time.Microsecond,
+		100 * time.Microsecond,
+		500 * time.Microsecond,
+		time.Millisecond,
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+	}
+)
+
+// ConcurrencyHandlerOptions holds the options for a [ConcurrencyHandler].
+type ConcurrencyHandlerOptions struct {
+	// Buckets holds the upper bounds of the buckets of the histograms recorded when Instrument is set, in ascending
+	// order.
+	//
+	// The default behavior is defined by the default concurrency buckets setting defined in the package.
+	Buckets []time.Duration
+
+	// Instrument indicates whether or not to record histograms of the time spent waiting to call the underlying
+	// handler and the time spent in its Handle function.
+	//
+	// The default behavior is to only count the calls in progress, which adds no measurable overhead.
+	Instrument bool
+
+	// MaxConcurrent is the maximum number of calls to the Handle function of the underlying handler in progress at the
+	// same time.
+	//
+	// Further calls block until a call returns or the context passed to Handle is canceled.
+	//
+	// The default behavior is to not limit the number of calls.
+	MaxConcurrent int
+}
+
+// ConcurrencyStats holds the counters and histograms for a [ConcurrencyHandler].
+type ConcurrencyStats struct {
+	// Active is the number of calls to the underlying handler currently in progress.
+	Active int64 `json:"active"`
+
+	// HandleDuration is the histogram of the time spent in the Handle function of the underlying handler, if the
+	// handler is instrumented.
+	HandleDuration DurationHistogram `json:"handle_duration"`
+
+	// MaxActive is the highest number of calls to the underlying handler in progress at the same time.
+	MaxActive int64 `json:"max_active"`
+
+	// Wait is the histogram of the time spent waiting for one of the calls in progress to return before calling the
+	// underlying handler, if the handler is instrumented.
+	Wait DurationHistogram `json:"wait"`
+
+	// Waiting is the number of calls currently waiting for one of the calls in progress to return.
+	Waiting int64 `json:"waiting"`
+}
+
+// DurationHistogram holds a histogram of durations.
+type DurationHistogram struct {
+	// Buckets holds the cumulative number of durations less than or equal to each upper bound, in ascending order.
+	//
+	// Durations greater than the highest upper bound are only included in Count.
+	Buckets []HistogramBucket `json:"buckets,omitempty"`
+
+	// Count is the total number of durations.
+	Count uint64 `json:"count"`
+
+	// Sum is the total of all durations.
+	Sum time.Duration `json:"sum"`
+}
+
+// HistogramBucket holds the cumulative number of durations in a single bucket of a [DurationHistogram].
+type HistogramBucket struct {
+	// Count is the number of durations less than or equal to the upper bound.
+	Count uint64 `json:"count"`
+
+	// UpperBound is the upper bound of the bucket.
+	UpperBound time.Duration `json:"le"`
+}
+
+// ConcurrencyHandler is a handler which limits the number of calls to the Handle function of the underlying handler in
+// progress at the same time and, optionally, records how long calls wait and how long they take.
+//
+// Limiting concurrency protects handlers which serialize records on a lock (eg: a shared buffer) or call a sink
+// which cannot cope with many concurrent requests from being overwhelmed by heavy parallel logging. When the handler
+// is instrumented, a handle duration which grows with the number of calls in progress while the wait remains low
+// indicates contention on a lock within the underlying handler. The statistics are available from
+// [ConcurrencyHandler.Stats], in diagnostic dumps (see [DumpDiagnostics]) and as metrics through the prom package.
+//
+// Handlers derived using WithAttrs or WithGroup share the same limit and statistics.
+type ConcurrencyHandler struct {
+	// unexported variables
+	handler slog.Handler      // underlying handler
+	state   *concurrencyState // state shared by all derived handlers
+}
+
+// ensure [ConcurrencyHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &ConcurrencyHandler{}
+
+// concurrencyState holds the limit and statistics shared by all handlers derived from the same concurrency handler.
+type concurrencyState struct {
+	active    atomic.Int64              // number of calls in progress
+	handle    *durationHistogram        // time spent in the Handle function, if instrumented
+	maxActive atomic.Int64              // highest number of calls in progress
+	options   ConcurrencyHandlerOptions // immutable handler options
+	sem       chan struct{}             // semaphore limiting the calls in progress, if limited
+	wait      *durationHistogram        // time spent waiting for the semaphore, if instrumented
+	waiting   atomic.Int64              // number of calls waiting for the semaphore
+}
+
+// durationHistogram records durations into buckets using atomic counters.
+type durationHistogram struct {
+	bounds []time.Duration // upper bounds of the buckets, in ascending order
+	counts []atomic.Uint64 // non-cumulative counts for each bucket, plus one for durations above the highest bound
+	sum    atomic.Int64    // total of all durations in nanoseconds
+	total  atomic.Uint64   // total number of durations
+}
+
+// NewConcurrencyHandler creates a new [ConcurrencyHandler] which passes records to the given handler.
+func NewConcurrencyHandler(h slog.Handler, options ConcurrencyHandlerOptions) *ConcurrencyHandler {
+	if len(options.Buckets) == 0 {
+		options.Buckets = DefaultConcurrencyBuckets
+	}
+	options.Buckets = slices.Sorted(slices.Values(options.Buckets))
+	st := &concurrencyState{
+		options: options,
+	}
+	if options.MaxConcurrent > 0 {
+		st.sem = make(chan struct{}, options.MaxConcurrent)
+	}
+	if options.Instrument {
+		st.handle = newDurationHistogram(options.Buckets)
+		st.wait = newDurationHistogram(options.Buckets)
+	}
+	return &ConcurrencyHandler{
+		handler: h,
+		state:   st,
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *ConcurrencyHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *ConcurrencyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle waits until fewer than the maximum number of calls are in progress and passes the record to the underlying
+// handler.
+//
+// If the context is canceled while waiting, the record is not handled and the context's error is returned.
+func (h *ConcurrencyHandler) Handle(ctx context.Context, r slog.Record) error {
+	st := h.state
+	var start time.Time
+	if st.options.Instrument {
+		start = time.Now()
+	}
+	if st.sem != nil {
+		select {
+		case st.sem <- struct{}{}:
+		default:
+			st.waiting.Add(1)
+			select {
+			case st.sem <- struct{}{}:
+				st.waiting.Add(-1)
+			case <-ctx.Done():
+				st.waiting.Add(-1)
+				return ctx.Err()
+			}
+		}
+		defer func() { <-st.sem }()
+	}
+
+	active := st.active.Add(1)
+	defer st.active.Add(-1)
+	for {
+		maxActive := st.maxActive.Load()
+		if active <= maxActive || st.maxActive.CompareAndSwap(maxActive, active) {
+			break
+		}
+	}
+
+	if !st.options.Instrument {
+		return h.handler.Handle(ctx, r)
+	}
+	handleStart := time.Now()
+	st.wait.observe(handleStart.Sub(start))
+	err := h.handler.Handle(ctx, r)
+	st.handle.observe(time.Since(handleStart))
+	return err
+}
+
+// Options returns a copy of the handler's options.
+func (h *ConcurrencyHandler) Options() any {
+	options := h.state.options
+	options.Buckets = slices.Clone(options.Buckets)
+	return options
+}
+
+// Stats returns the handler's counters and, if it is instrumented, histograms.
+func (h *ConcurrencyHandler) Stats() ConcurrencyStats {
+	st := h.state
+	stats := ConcurrencyStats{
+		Active:    st.active.Load(),
+		MaxActive: st.maxActive.Load(),
+		Waiting:   st.waiting.Load(),
+	}
+	if st.options.Instrument {
+		stats.HandleDuration = st.handle.snapshot()
+		stats.Wait = st.wait.snapshot()
+	}
+	return stats
+}
+
+// Type returns the type of the handler.
+func (h *ConcurrencyHandler) Type() string {
+	return "concurrency"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *ConcurrencyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &ConcurrencyHandler{
+		handler: h.handler.WithAttrs(attrs),
+		state:   h.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *ConcurrencyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ConcurrencyHandler{
+		handler: h.handler.WithGroup(name),
+		state:   h.state,
+	}
+}
+
+// newDurationHistogram returns a new histogram with buckets for the given upper bounds, which must be sorted.
+func newDurationHistogram(bounds []time.Duration) *durationHistogram {
+	return &durationHistogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+// observe records the given duration.
+func (h *durationHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(h.bounds, d)
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+	h.total.Add(1)
+}
+
+// snapshot returns the current contents of the histogram with cumulative bucket counts.
+//
+// Since the counters are updated independently, the snapshot may be slightly inconsistent while durations are being
+// recorded.
+func (h *durationHistogram) snapshot() DurationHistogram {
+	snapshot := DurationHistogram{
+		Buckets: make([]HistogramBucket, len(h.bounds)),
+		Count:   h.total.Load(),
+		Sum:     time.Duration(h.sum.Load()),
+	}
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i].Load()
+		snapshot.Buckets[i] = HistogramBucket{
+			Count:      cumulative,
+			UpperBound: bound,
+		}
+	}
+	return snapshot
+}
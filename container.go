@@ -0,0 +1,193 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultContainerLevelEnvVar is the default name of the environment variable from which a container logger reads
+	// its minimum logging level.
+	//
+	// This value is used when the level environment variable in [ContainerLoggerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#ContainerLoggerOptions
+	DefaultContainerLevelEnvVar = "LOG_LEVEL"
+)
+
+// ContainerLoggerOptions holds the options for [NewContainerLogger].
+type ContainerLoggerOptions struct {
+	// IncludeCaller indicates whether or not to include the caller in records.
+	//
+	// The default behavior is to not include caller information.
+	IncludeCaller bool
+
+	// Level is the minimum level at which to log records.
+	//
+	// The default behavior is to read the level from the environment variable named by LevelEnvVar (eg: "debug",
+	// "warn" or "error+2") and to log records at [slog.LevelInfo] or above if the variable is not set.
+	Level slog.Leveler
+
+	// LevelEnvVar is the name of the environment variable from which to read the minimum logging level.
+	//
+	// The default behavior is defined by the default container level environment variable setting defined in the
+	// package.
+	LevelEnvVar string
+
+	// Stderr is the writer to which warnings and errors are mirrored.
+	//
+	// The default behavior is to write to [os.Stderr].
+	Stderr io.Writer
+
+	// StderrLevel is the minimum level of records which are mirrored to Stderr.
+	//
+	// Records are never mirrored if their level is below the minimum logging level. The default behavior is to mirror
+	// records at [slog.LevelWarn] or above.
+	StderrLevel slog.Leveler
+
+	// Stdout is the writer to which all records are written.
+	//
+	// The default behavior is to write to [os.Stdout].
+	Stdout io.Writer
+}
+
+// containerStderrLevel is the [slog.Leveler] for the stderr handler of a container logger.
+type containerStderrLevel struct {
+	// unexported variables
+	level  slog.Leveler // minimum logging level
+	stderr slog.Leveler // minimum level for mirroring records
+}
+
+// teeHandler is an [slog.Handler] which passes each record to every one of its handlers which is enabled for it.
+type teeHandler struct {
+	// unexported variables
+	handlers []slog.Handler // immutable list of handlers
+}
+
+// ensure [teeHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &teeHandler{}
+
+// NewContainerLogger returns a new [slog.Logger] suited to containers (eg: Kubernetes pods), which writes every
+// record as JSON to stdout and mirrors warnings and errors as JSON to stderr, so that platforms which treat the two
+// streams differently can surface problems without any further configuration.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the level read from the environment is invalid
+func NewContainerLogger(options ContainerLoggerOptions) (*slog.Logger, xerrors.Error) {
+	if options.LevelEnvVar == "" {
+		options.LevelEnvVar = DefaultContainerLevelEnvVar
+	}
+	if options.Level == nil {
+		var level slog.LevelVar
+		if value, ok := os.LookupEnv(options.LevelEnvVar); ok && value != "" {
+			if err := level.UnmarshalText([]byte(value)); err != nil {
+				return nil, xerrors.Wrapf(OptionsValidationError, err, "invalid level in environment variable '%s': %s",
+					options.LevelEnvVar, err.Error()).WithAttr("env_var", options.LevelEnvVar)
+			}
+		}
+		options.Level = &level
+	}
+	if options.Stderr == nil {
+		options.Stderr = os.Stderr
+	}
+	if options.StderrLevel == nil {
+		options.StderrLevel = slog.LevelWarn
+	}
+	if options.Stdout == nil {
+		options.Stdout = os.Stdout
+	}
+
+	return New(&teeHandler{
+		handlers: []slog.Handler{
+			slog.NewJSONHandler(options.Stdout, &slog.HandlerOptions{
+				AddSource: options.IncludeCaller,
+				Level:     options.Level,
+			}),
+			slog.NewJSONHandler(options.Stderr, &slog.HandlerOptions{
+				AddSource: options.IncludeCaller,
+				Level: containerStderrLevel{
+					level:  options.Level,
+					stderr: options.StderrLevel,
+				},
+			}),
+		},
+	}), nil
+}
+
+// Level returns the higher of the minimum logging level and the minimum level for mirroring records.
+func (l containerStderrLevel) Level() slog.Level {
+	return max(l.level.Level(), l.stderr.Level())
+}
+
+// ChildHandlers returns the handlers which each record is passed to.
+func (h *teeHandler) ChildHandlers() []slog.Handler {
+	return slices.Clone(h.handlers)
+}
+
+// Enabled returns whether or not any of the handlers is enabled for the given level.
+func (h *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slices.ContainsFunc(h.handlers, func(handler slog.Handler) bool {
+		return handler.Enabled(ctx, level)
+	})
+}
+
+// Handle passes a copy of the record to each handler which is enabled for its level.
+func (h *teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, r.Level) {
+			if err := handler.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options always returns nil since the handler has no options.
+func (h *teeHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *teeHandler) Type() string {
+	return "tee"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return &teeHandler{
+		handlers: handlers,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return &teeHandler{
+		handlers: handlers,
+	}
+}
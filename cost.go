@@ -0,0 +1,381 @@
+package xlog
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// bytesPerGB is the number of bytes in a gigabyte, as used by sinks which charge for ingestion.
+	bytesPerGB = 1e9
+)
+
+var (
+	// DefaultCostTrackerInterval is the default interval at which a [CostTracker] logs a summary of its costs.
+	//
+	// This value is used when the interval in [CostTrackerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#CostTrackerOptions
+	DefaultCostTrackerInterval = time.Hour
+
+	// DefaultCostTrackerMessage is the default message of the records holding a summary of costs.
+	//
+	// This value is used when the message in [CostTrackerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#CostTrackerOptions
+	DefaultCostTrackerMessage = "log ingestion costs"
+)
+
+// CostReporter defines the interface for an object which keeps track of the volume of records sent to each sink and
+// what it costs, such as a [CostTracker], so that the costs can be exported to a monitoring system.
+type CostReporter interface {
+	// Costs should return the volume and cost of the records sent to each sink since the reporter was created.
+	Costs() []SinkCost
+}
+
+// CostTrackerOptions holds the options for a [CostTracker].
+type CostTrackerOptions struct {
+	// DefaultRate is the cost per gigabyte (10^9 bytes) of records sent to sinks without a rate in Rates.
+	//
+	// The default behavior is to treat such sinks as free.
+	DefaultRate float64
+
+	// GroupKey is the key of a top-level attribute (eg: "service") whose value is used to break down the costs of each
+	// sink, in addition to the level of the records.
+	//
+	// The attribute may be added to the record itself or to the handler using WithAttrs.
+	//
+	// The default behavior is to only break down costs by level.
+	GroupKey string
+
+	// Interval is the interval at which a summary of the costs is logged by [CostTracker.Run].
+	//
+	// The default behavior is defined by the default cost tracker interval setting defined in the package.
+	Interval time.Duration
+
+	// Level is the level at which summaries are logged.
+	//
+	// The default behavior is to log summaries at [slog.LevelInfo].
+	Level slog.Leveler
+
+	// Logger is the logger through which summaries are logged.
+	//
+	// The default behavior is to use [slog.Default] at the time each summary is logged.
+	Logger *slog.Logger
+
+	// Message is the message of the records holding summaries.
+	//
+	// The default behavior is defined by the default cost tracker message setting defined in the package.
+	Message string
+
+	// Rates holds the cost per gigabyte (10^9 bytes) of records sent to each sink, keyed by the name given to
+	// [CostTracker.Handler].
+	//
+	// Costs are reported in whichever currency the rates are given in.
+	Rates map[string]float64
+
+	// Size is called for each record successfully handled by a sink to get the number of bytes it is billed for.
+	//
+	// The default behavior is to use the size of the record encoded as a single line of JSON by [slog.JSONHandler],
+	// not including any attributes added to the handler using WithAttrs, which is close to what most sinks bill for.
+	Size func(r slog.Record) int64
+}
+
+// SinkCost holds the volume and cost of the records sent to a single sink, for a single level and group.
+type SinkCost struct {
+	// Bytes is the number of bytes sent to the sink.
+	Bytes uint64 `json:"bytes"`
+
+	// Cost is the cost of the bytes sent to the sink.
+	Cost float64 `json:"cost"`
+
+	// Group is the value of the group key attribute of the records, if any.
+	Group string `json:"group,omitempty"`
+
+	// Level is the name of the level of the records (eg: "INFO").
+	Level string `json:"level"`
+
+	// Records is the number of records sent to the sink.
+	Records uint64 `json:"records"`
+
+	// Sink is the name of the sink.
+	Sink string `json:"sink"`
+}
+
+// CostTracker attributes the cost of sending records to sinks which charge by ingestion volume (eg: a SIEM) to the
+// sinks, levels and, optionally, services which produced them.
+//
+// Wrap each sink's handler using [CostTracker.Handler]. The size of each record the sink handles successfully is
+// counted and multiplied by the sink's rate per gigabyte. The costs are available from [CostTracker.Costs], as
+// periodic summary records logged by [CostTracker.Run] or as metrics through the prom package.
+//
+// All methods are safe to call concurrently.
+type CostTracker struct {
+	// unexported variables
+	counts  map[costKey]*costCount // counters by sink, level and group
+	mu      sync.Mutex             // protects the counters
+	options CostTrackerOptions     // immutable tracker options
+}
+
+// ensure [CostTracker] implements [CostReporter] interface.
+var _ CostReporter = &CostTracker{}
+
+// costCount holds the number of records and bytes sent to a sink for a single level and group.
+type costCount struct {
+	bytes   uint64 // number of bytes
+	records uint64 // number of records
+}
+
+// costHandler is the [slog.Handler] returned by [CostTracker.Handler].
+type costHandler struct {
+	// unexported variables
+	group   string       // value of the group key attribute added using WithAttrs, if any
+	grouped bool         // whether or not a group has been opened using WithGroup
+	handler slog.Handler // underlying handler
+	name    string       // name of the sink
+	tracker *CostTracker // tracker which counts the records
+}
+
+// costCountingWriter is an [io.Writer] which discards everything written to it but counts the bytes.
+type costCountingWriter struct {
+	n int64 // number of bytes written
+}
+
+// costKey identifies the counters for a single sink, level and group.
+type costKey struct {
+	group string     // value of the group key attribute
+	level slog.Level // level of the records
+	sink  string     // name of the sink
+}
+
+// NewCostTracker creates a new [CostTracker] with the given options.
+//
+// Call [CostTracker.Run] to start logging summaries.
+func NewCostTracker(options CostTrackerOptions) *CostTracker {
+	if options.Interval <= 0 {
+		options.Interval = DefaultCostTrackerInterval
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	if options.Message == "" {
+		options.Message = DefaultCostTrackerMessage
+	}
+	if options.Size == nil {
+		options.Size = jsonRecordSize
+	}
+	options.Rates = maps.Clone(options.Rates)
+	return &CostTracker{
+		counts:  map[costKey]*costCount{},
+		options: options,
+	}
+}
+
+// Costs returns the volume and cost of the records sent to each sink since the tracker was created, sorted by sink,
+// group and level.
+func (t *CostTracker) Costs() []SinkCost {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := slices.SortedFunc(maps.Keys(t.counts), func(a, b costKey) int {
+		return cmp.Or(cmp.Compare(a.sink, b.sink), cmp.Compare(a.group, b.group), cmp.Compare(a.level, b.level))
+	})
+	costs := make([]SinkCost, 0, len(keys))
+	for _, key := range keys {
+		count := t.counts[key]
+		costs = append(costs, SinkCost{
+			Bytes:   count.bytes,
+			Cost:    float64(count.bytes) / bytesPerGB * t.rate(key.sink),
+			Group:   key.group,
+			Level:   key.level.String(),
+			Records: count.records,
+			Sink:    key.sink,
+		})
+	}
+	return costs
+}
+
+// Emit logs a single record holding a summary of the current costs.
+//
+// The record holds the total cost of all sinks ("total_cost") and the volume and cost of each sink, level and group
+// ("costs").
+func (t *CostTracker) Emit(ctx context.Context) {
+	logger := t.options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := t.options.Level.Level()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	costs := t.Costs()
+	var total float64
+	for _, c := range costs {
+		total += c.Cost
+	}
+	logger.LogAttrs(ctx, level, t.options.Message, slog.Float64("total_cost", total), slog.Any("costs", costs))
+}
+
+// Handler returns a new [slog.Handler] which passes records to the given handler and counts the size of each record
+// it handles successfully against the sink with the given name.
+//
+// Handlers wrapped with the same name share the same counters, as do handlers derived from the returned handler using
+// WithAttrs or WithGroup.
+func (t *CostTracker) Handler(name string, h slog.Handler) slog.Handler {
+	return &costHandler{
+		handler: h,
+		name:    name,
+		tracker: t,
+	}
+}
+
+// Options returns a copy of the tracker's options.
+func (t *CostTracker) Options() any {
+	options := t.options
+	options.Rates = maps.Clone(t.options.Rates)
+	return options
+}
+
+// Run logs a summary of the costs at the configured interval until the context is canceled.
+//
+// This function blocks, so it is typically called in its own goroutine.
+func (t *CostTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		t.Emit(ctx)
+	}
+}
+
+// add counts a record of the given size sent to a sink.
+func (t *CostTracker) add(key costKey, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count, ok := t.counts[key]
+	if !ok {
+		count = &costCount{}
+		t.counts[key] = count
+	}
+	count.bytes += uint64(max(size, 0))
+	count.records++
+}
+
+// rate returns the cost per gigabyte of the given sink.
+func (t *CostTracker) rate(sink string) float64 {
+	if rate, ok := t.options.Rates[sink]; ok {
+		return rate
+	}
+	return t.options.DefaultRate
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *costHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *costHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle passes the record to the underlying handler and counts its size if it was handled successfully.
+func (h *costHandler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	key := costKey{
+		group: h.group,
+		level: r.Level,
+		sink:  h.name,
+	}
+	if groupKey := h.tracker.options.GroupKey; groupKey != "" && !h.grouped {
+		r.Attrs(func(attr slog.Attr) bool {
+			if attr.Key == groupKey {
+				key.group = attr.Value.Resolve().String()
+				return false
+			}
+			return true
+		})
+	}
+	h.tracker.add(key, h.tracker.options.Size(r))
+	return nil
+}
+
+// Options returns a copy of the tracker's options.
+func (h *costHandler) Options() any {
+	return h.tracker.Options()
+}
+
+// Type returns the type of the handler.
+func (h *costHandler) Type() string {
+	return "cost"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *costHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	group := h.group
+	if groupKey := h.tracker.options.GroupKey; groupKey != "" && !h.grouped {
+		for _, attr := range attrs {
+			if attr.Key == groupKey {
+				group = attr.Value.Resolve().String()
+			}
+		}
+	}
+	return &costHandler{
+		group:   group,
+		grouped: h.grouped,
+		handler: h.handler.WithAttrs(attrs),
+		name:    h.name,
+		tracker: h.tracker,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *costHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &costHandler{
+		group:   h.group,
+		grouped: true,
+		handler: h.handler.WithGroup(name),
+		name:    h.name,
+		tracker: h.tracker,
+	}
+}
+
+// Write counts the bytes in the given data and discards them.
+func (w *costCountingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// jsonRecordSize returns the number of bytes in the record when it is encoded as a single line of JSON.
+func jsonRecordSize(r slog.Record) int64 {
+	var w costCountingWriter
+	_ = slog.NewJSONHandler(&w, nil).Handle(context.Background(), r)
+	return w.n
+}
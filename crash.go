@@ -0,0 +1,182 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// DefaultCrashExitCode is the status code with which the application exits after a crash has been handled by a
+	// [CrashHandler] when no exit code is provided.
+	//
+	// This value matches the status code used by the Go runtime when a program exits due to an unrecovered panic.
+	//
+	// Setting this value changes the default globally for the package.
+	DefaultCrashExitCode = 2
+
+	// installedCrashHandler holds the crash handler installed using [InstallCrashHandler].
+	installedCrashHandler atomic.Pointer[CrashHandler]
+)
+
+// CrashHandlerOptions holds the options for a [CrashHandler].
+type CrashHandlerOptions struct {
+	// CrashReportPath is the path of the file to which a crash report is written after a panic is recovered.
+	//
+	// The report holds the panic value, the stack traces of all goroutines and the records held by History, if it
+	// is set. See [CrashReportHook] for details.
+	//
+	// The default behavior is to not write a crash report.
+	CrashReportPath string
+
+	// ExitCode is the status code with which the application exits after a panic has been handled.
+	//
+	// The default behavior is defined by the default crash exit code defined in the package.
+	ExitCode int
+
+	// Handler is the handler which is flushed and closed after the panic is logged so that any buffered records are
+	// written before the application exits.
+	//
+	// The default behavior is to flush and close the logger's handler.
+	Handler slog.Handler
+
+	// History is a ring buffer holding the most recent records, which are included in the crash report.
+	//
+	// The default behavior is to not include any records in the crash report.
+	History *RingBuffer
+}
+
+// CrashHandler recovers panics, logs them along with the stack trace of the goroutine which panicked and exits the
+// application once the handler tree has been flushed and closed.
+//
+// Use [CrashHandler.Recover] at the top of the main function and [CrashHandler.Go] to start goroutines which should
+// be protected. Panics in goroutines which are not protected cannot be recovered and crash the application without
+// being logged.
+type CrashHandler struct {
+	// unexported variables
+	logger  *slog.Logger        // logger used to log panics
+	mu      sync.Mutex          // serializes the handling of concurrent panics
+	options CrashHandlerOptions // immutable handler options
+}
+
+// InstallCrashHandler creates a new [CrashHandler] which logs panics using the given logger and installs it for the
+// package so that it is used by the [Go] and [RecoverCrash] functions.
+//
+// Any previously installed crash handler is replaced. If logger is nil, the default logger is used.
+func InstallCrashHandler(logger *slog.Logger, options CrashHandlerOptions) *CrashHandler {
+	c := NewCrashHandler(logger, options)
+	installedCrashHandler.Store(c)
+	return c
+}
+
+// NewCrashHandler creates a new [CrashHandler] which logs panics using the given logger without installing it for
+// the package.
+//
+// If logger is nil, the default logger is used.
+func NewCrashHandler(logger *slog.Logger, options CrashHandlerOptions) *CrashHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if options.ExitCode == 0 {
+		options.ExitCode = DefaultCrashExitCode
+	}
+	if options.Handler == nil {
+		options.Handler = logger.Handler()
+	}
+	return &CrashHandler{
+		logger:  logger,
+		options: options,
+	}
+}
+
+// Go runs the given function in a new goroutine, recovering any panic using the crash handler installed for the
+// package.
+//
+// If no crash handler has been installed, the function is simply run in a new goroutine.
+func Go(fn func()) {
+	go func() {
+		defer RecoverCrash()
+		fn()
+	}()
+}
+
+// RecoverCrash recovers a panic using the crash handler installed for the package, if any.
+//
+// It must be called directly by a deferred function call (eg: defer xlog.RecoverCrash()) at the top of main or a
+// goroutine. If no crash handler has been installed, the panic continues as if it had not been recovered.
+func RecoverCrash() {
+	if v := recover(); v != nil {
+		c := installedCrashHandler.Load()
+		if c == nil {
+			panic(v)
+		}
+		c.handle(v, debug.Stack())
+	}
+}
+
+// Go runs the given function in a new goroutine, recovering and handling any panic.
+func (c *CrashHandler) Go(fn func()) {
+	go func() {
+		defer c.Recover()
+		fn()
+	}()
+}
+
+// Recover recovers and handles a panic, if one occurred.
+//
+// It must be called directly by a deferred function call (eg: defer c.Recover()) at the top of main or a goroutine.
+func (c *CrashHandler) Recover() {
+	if v := recover(); v != nil {
+		c.handle(v, debug.Stack())
+	}
+}
+
+// Wrap returns a function which runs the given function, recovering and handling any panic.
+//
+// This is useful for protecting functions which are started as goroutines by other packages.
+func (c *CrashHandler) Wrap(fn func()) func() {
+	return func() {
+		defer c.Recover()
+		fn()
+	}
+}
+
+// handle logs the panic, flushes and closes the handler tree, writes the crash report, if desired, and exits the
+// application.
+//
+// Any errors which occur while doing so are written to [DefaultErrorHandlerWriter] since the handler tree may no
+// longer be usable.
+func (c *CrashHandler) handle(v any, stack []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx := context.Background()
+	c.logger.LogAttrs(ctx, slog.LevelError, fmt.Sprintf("panic: %v", v),
+		slog.String("panic", fmt.Sprint(v)),
+		slog.String("stack", string(stack)),
+	)
+
+	var errs []error
+	if err := FlushHandler(c.options.Handler); err != nil {
+		errs = append(errs, err)
+	}
+	if closer, ok := c.options.Handler.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.options.CrashReportPath != "" {
+		if err := writeCrashReport(c.options.CrashReportPath, nil, v, c.options.History); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		fmt.Fprintf(DefaultErrorHandlerWriter, "failed to handle crash: %s\n", err.Error())
+	}
+	exitFn(c.options.ExitCode)
+}
@@ -0,0 +1,112 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// dedupHandler is the [slog.Handler] returned by [NewDedupHandler].
+type dedupHandler struct {
+	stampingWrapper
+}
+
+// ensure [dedupHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &dedupHandler{}
+
+// DeduplicateAttrs returns a copy of the given attributes with duplicate keys removed.
+//
+// When the same key appears more than once, the last value is kept in the position where the key first appeared.
+// Groups with the same key are merged recursively and groups with empty keys are inlined into their parent, the same
+// way [slog] handlers treat them.
+func DeduplicateAttrs(attrs []slog.Attr) []slog.Attr {
+	deduped := make([]slog.Attr, 0, len(attrs))
+	index := make(map[string]int, len(attrs))
+	var add func(attrs []slog.Attr)
+	add = func(attrs []slog.Attr) {
+		for _, attr := range attrs {
+			attr.Value = attr.Value.Resolve()
+			if attr.Value.Kind() == slog.KindGroup && attr.Key == "" {
+				add(attr.Value.Group())
+				continue
+			}
+			if attr.Equal(slog.Attr{}) {
+				continue
+			}
+
+			i, ok := index[attr.Key]
+			if !ok {
+				index[attr.Key] = len(deduped)
+				if attr.Value.Kind() == slog.KindGroup {
+					attr.Value = slog.GroupValue(DeduplicateAttrs(attr.Value.Group())...)
+				}
+				deduped = append(deduped, attr)
+				continue
+			}
+
+			// merge groups with the same key or replace the previous value
+			prev := deduped[i]
+			if prev.Value.Kind() == slog.KindGroup && attr.Value.Kind() == slog.KindGroup {
+				merged := slices.Concat(prev.Value.Group(), attr.Value.Group())
+				attr.Value = slog.GroupValue(DeduplicateAttrs(merged)...)
+			} else if attr.Value.Kind() == slog.KindGroup {
+				attr.Value = slog.GroupValue(DeduplicateAttrs(attr.Value.Group())...)
+			}
+			deduped[i] = attr
+		}
+	}
+	add(attrs)
+	return deduped
+}
+
+// NewDedupHandler returns a new [slog.Handler] which removes duplicate attribute keys before passing records to the
+// given handler.
+//
+// Attributes added using WithAttrs and attributes in the record are combined and, whenever the same key appears
+// more than once within the same group, only the last value is kept in the position where the key first appeared.
+// Groups with the same key are merged rather than replaced. This avoids duplicate fields in JSON output, which some
+// strict parsers reject.
+func NewDedupHandler(h slog.Handler) slog.Handler {
+	return &dedupHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *dedupHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *dedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle combines the handler's attributes with the record's attributes, removes any duplicate keys and passes the
+// resulting record to the underlying handler.
+func (h *dedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(DeduplicateAttrs(h.mergeAttrs(r))...)
+	return h.handler.Handle(ctx, record)
+}
+
+// Options always returns nil since the handler has no options.
+func (h *dedupHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *dedupHandler) Type() string {
+	return "dedup"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *dedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *dedupHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
@@ -0,0 +1,63 @@
+package xlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// DeliveryCallbackFn is a function that's called by a network handler once a batch of records has been delivered to
+// its sink or has failed to be delivered.
+//
+// The function is called from the goroutine which sent the batch, so it should return quickly. It should not log
+// through the handler which called it, since doing so may cause the handler to deadlock.
+//
+// Only the SentinelOne HTTP event collector handler in the handlers package currently reports deliveries, using its
+// OnDelivered and OnFailed options. Other handlers, including those which write to files, never call the function.
+type DeliveryCallbackFn func(ctx context.Context, report DeliveryReport)
+
+// DeliveryReport describes the outcome of sending a single batch of records to a sink, which allows applications
+// needing end-to-end delivery guarantees (eg: for billing or audit events) to reconcile what actually reached the
+// sink.
+type DeliveryReport struct {
+	// BatchID uniquely identifies the batch.
+	//
+	// The ID is assigned when the batch is formed (ie: when the handler's buffer fills up or is flushed), so a batch
+	// keeps its ID until it is sent. Batches recovered from a write-ahead log after a restart are given a new ID.
+	BatchID string
+
+	// Checksum is the hex-encoded checksum of the batch's payload sent to the sink, or an empty string if the handler
+	// was not configured to compute one.
+	Checksum string
+
+	// Err is the error which caused the batch to fail, or nil if the batch was delivered.
+	Err error
+
+	// FirstSequence is the lowest sequence number stamped on the records in the batch by a [SequenceHandler] using
+	// the default sequence key, or 0 if none of the records were stamped.
+	//
+	// Together with LastSequence and the instance ID of the process, it identifies the records in the batch. Batches
+	// recovered from a write-ahead log after a restart have no sequence range.
+	FirstSequence uint64
+
+	// HandlerType is the type of the handler which sent the batch.
+	HandlerType string
+
+	// LastSequence is the highest sequence number stamped on the records in the batch by a [SequenceHandler] using
+	// the default sequence key, or 0 if none of the records were stamped.
+	LastSequence uint64
+
+	// Latency is the time taken to send the batch, including any compression and the network round trip.
+	Latency time.Duration
+
+	// Records is the number of records in the batch.
+	Records int
+}
+
+// NewBatchID returns a new random identifier for a batch of records.
+func NewBatchID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,281 @@
+package xlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	// _diagnostics holds the objects registered using [RegisterDiagnostics].
+	_diagnostics = &diagnosticsRegistry{
+		sources: map[string]*diagnosticsSource{},
+	}
+)
+
+// diagnosticsRegistry holds the objects included in diagnostic dumps in addition to the loggers.
+type diagnosticsRegistry struct {
+	mu      sync.Mutex                    // protects the sources
+	sources map[string]*diagnosticsSource // registered objects by name
+}
+
+// diagnosticsSource holds a single object registered using [RegisterDiagnostics].
+type diagnosticsSource struct {
+	v any // registered object
+}
+
+// diagnosticsWriter writes a diagnostic dump, remembering the first error.
+type diagnosticsWriter struct {
+	err error     // first error which occurred
+	w   io.Writer // underlying writer
+}
+
+// DumpDiagnostics writes a human-readable snapshot of the state of the logging pipeline to the given writer so that
+// support engineers can capture it from a process which appears to be stuck or is not delivering records.
+//
+// The dump holds the Go runtime version and number of goroutines, the handler tree of [slog.Default], the handlers
+// and level overrides attached to named loggers (see [GetLogger]), the handler designated using
+// [SetInternalHandler], every object registered using [RegisterDiagnostics] and the recent internal warnings and
+// errors returned by [RecentInternalErrors].
+//
+// For each handler in a tree, the dump holds its type, its capabilities (see [Capabilities]), its current levels if
+// it implements [LevelVarHandler] and its statistics, encoded as JSON, if it reports any (eg: the queue depths of a
+// [Pipeline] or the backlog of a spool handler).
+func DumpDiagnostics(w io.Writer) error {
+	dw := &diagnosticsWriter{w: w}
+	dw.printf("xlog diagnostics\n")
+	dw.printf("go version: %s\n", runtime.Version())
+	dw.printf("goroutines: %d\n", runtime.NumGoroutine())
+
+	dw.printf("\n== default handler ==\n")
+	dw.tree(slog.Default().Handler(), 0)
+
+	// named loggers
+	_loggers.mu.RLock()
+	names := slices.Sorted(maps.Keys(_loggers.handlers))
+	for name := range _loggers.levels {
+		if _, ok := _loggers.handlers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	handlers := maps.Clone(_loggers.handlers)
+	levels := maps.Clone(_loggers.levels)
+	_loggers.mu.RUnlock()
+	dw.printf("\n== named loggers ==\n")
+	if len(names) == 0 {
+		dw.printf("none\n")
+	}
+	for _, name := range names {
+		dw.printf("logger %q", name)
+		if level, ok := levels[name]; ok && level != nil {
+			dw.printf(" level=%s", level.Level())
+		}
+		dw.printf("\n")
+		if h, ok := handlers[name]; ok {
+			dw.tree(h, 1)
+		}
+	}
+
+	dw.printf("\n== internal handler ==\n")
+	if h := InternalHandler(); h != nil {
+		dw.tree(h, 0)
+	} else {
+		dw.printf("none\n")
+	}
+
+	// registered objects
+	_diagnostics.mu.Lock()
+	sources := maps.Clone(_diagnostics.sources)
+	_diagnostics.mu.Unlock()
+	for _, name := range slices.Sorted(maps.Keys(sources)) {
+		dw.printf("\n== %s ==\n", name)
+		dw.source(sources[name].v)
+	}
+
+	records := RecentInternalErrors()
+	dw.printf("\n== recent internal errors (%d) ==\n", len(records))
+	if len(records) > 0 && dw.err == nil {
+		h := slog.NewTextHandler(dw, nil)
+		for _, r := range records {
+			if err := h.Handle(context.Background(), r); err != nil {
+				break
+			}
+		}
+	}
+	return dw.err
+}
+
+// NotifyDiagnostics writes a diagnostic dump (see [DumpDiagnostics]) to the given writer whenever the process receives
+// one of the given signals and returns a function which stops listening for the signals.
+//
+// If no signals are given, SIGQUIT is used. Note that listening for SIGQUIT replaces the default behavior of the Go
+// runtime, which dumps the stacks of all goroutines and exits, so the process keeps running after the dump is written.
+// If w is nil, the dump is written to [os.Stderr].
+func NotifyDiagnostics(w io.Writer, signals ...os.Signal) func() {
+	if w == nil {
+		w = os.Stderr
+	}
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGQUIT}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, signals...)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				_ = DumpDiagnostics(w)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// RegisterDiagnostics registers an object to include in diagnostic dumps under the given name and returns a function
+// which unregisters it.
+//
+// Register handler trees which are not reachable from [slog.Default] or a named logger and objects which report
+// statistics about the pipeline. The object may be a [slog.Handler], whose tree is dumped, a [HandlerStatsReporter]
+// (eg: a [Pipeline]), a [CostReporter] or any object with a Stats function which takes no arguments and returns a
+// single value. Any object previously registered with the same name is replaced.
+func RegisterDiagnostics(name string, v any) func() {
+	source := &diagnosticsSource{
+		v: v,
+	}
+	_diagnostics.mu.Lock()
+	defer _diagnostics.mu.Unlock()
+	_diagnostics.sources[name] = source
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_diagnostics.mu.Lock()
+			defer _diagnostics.mu.Unlock()
+			if _diagnostics.sources[name] == source {
+				delete(_diagnostics.sources, name)
+			}
+		})
+	}
+}
+
+// Write writes the data to the underlying writer unless an error has already occurred.
+func (w *diagnosticsWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.err = err
+	return n, err
+}
+
+// json writes the given value as a single line of JSON, prefixed by the given label and indented by the given depth.
+func (w *diagnosticsWriter) json(depth int, label string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%q", fmt.Sprintf("%+v", v)))
+	}
+	w.printf("%s%s: %s\n", strings.Repeat("  ", depth), label, data)
+}
+
+// printf writes the formatted text unless an error has already occurred.
+func (w *diagnosticsWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// source writes the details of an object registered using [RegisterDiagnostics].
+func (w *diagnosticsWriter) source(v any) {
+	switch v := v.(type) {
+	case nil:
+		w.printf("none\n")
+	case slog.Handler:
+		w.tree(v, 0)
+	case HandlerStatsReporter:
+		stats := v.HandlerStats()
+		for _, name := range slices.Sorted(maps.Keys(stats)) {
+			w.json(0, name, stats[name])
+		}
+	case CostReporter:
+		for _, cost := range v.Costs() {
+			w.json(0, cost.Sink, cost)
+		}
+	default:
+		if stats, ok := callStats(v); ok {
+			w.json(0, "stats", stats)
+		} else {
+			w.printf("%T\n", v)
+		}
+	}
+}
+
+// tree writes the details of the given handler and all of its descendants, indented by the given depth.
+func (w *diagnosticsWriter) tree(h slog.Handler, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if h == nil {
+		w.printf("%s- none\n", indent)
+		return
+	}
+
+	caps := Capabilities(h)
+	w.printf("%s- %s", indent, caps.Type)
+	if len(caps.Capabilities) > 0 {
+		names := make([]string, len(caps.Capabilities))
+		for i, c := range caps.Capabilities {
+			names[i] = string(c)
+		}
+		w.printf(" [%s]", strings.Join(names, ","))
+	}
+	if lh, ok := h.(LevelVarHandler); ok {
+		if level := lh.GetLevelVar(); level != nil {
+			w.printf(" level=%s", level.Level())
+		}
+		if maxLevel := lh.GetMaxLevelVar(); maxLevel != nil {
+			w.printf(" max_level=%s", maxLevel.Level())
+		}
+	}
+	w.printf("\n")
+
+	if reporter, ok := h.(HandlerStatsReporter); ok {
+		w.json(depth+1, "handler_stats", reporter.HandlerStats())
+	} else if stats, ok := callStats(h); ok {
+		w.json(depth+1, "stats", stats)
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			w.tree(child, depth+1)
+		}
+	}
+}
+
+// callStats calls the Stats function of the given object, if it has one which takes no arguments and returns a single
+// value, and returns the value.
+func callStats(v any) (any, bool) {
+	m, ok := statsMethod(v)
+	if !ok {
+		return nil, false
+	}
+	return m.Call(nil)[0].Interface(), true
+}
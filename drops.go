@@ -0,0 +1,199 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultDropNotificationInterval is the interval over which dropped records are counted before subscribers are
+	// notified.
+	//
+	// All records dropped by the same handler for the same reason within the interval are reported in a single
+	// [DropNotification] to avoid flooding subscribers when a handler is under sustained pressure.
+	//
+	// Setting this value changes the interval globally for the package.
+	DefaultDropNotificationInterval = time.Second
+)
+
+const (
+	// DropReasonAdaptiveSampling indicates that records were dropped by an [AdaptiveSampler] because the sink was
+	// under pressure.
+	DropReasonAdaptiveSampling = "adaptive_sampling"
+
+	// DropReasonClosed indicates that records were dropped because they were logged after the [Pipeline] they would
+	// have been queued in was closed.
+	DropReasonClosed = "closed"
+
+	// DropReasonExpired indicates that queued records were dropped because they were older than the maximum record age
+	// by the time they were due to be delivered.
+	DropReasonExpired = "expired"
+
+	// DropReasonFairShare indicates that records were dropped because the handler was using more than its share of
+	// a [Pipeline] which was more than half full.
+	DropReasonFairShare = "fair_share"
+
+	// DropReasonLoadShedding indicates that records were dropped by a [LoadShedder] because the heap usage or queue
+	// depth crossed one of its thresholds.
+	DropReasonLoadShedding = "load_shedding"
+
+	// DropReasonMaxBytes indicates that records were dropped because the memory budget was exhausted.
+	DropReasonMaxBytes = "max_bytes"
+
+	// DropReasonRateLimit indicates that records were dropped by a [RateLimitHandler] because their key exceeded its
+	// rate limit.
+	DropReasonRateLimit = "rate_limit"
+
+	// DropReasonMaxRecords indicates that records were dropped because the maximum number of queued records was
+	// reached.
+	DropReasonMaxRecords = "max_records"
+)
+
+var (
+	// dropNotifierState holds the package-wide subscribers and pending drop counts.
+	dropNotifierState = &dropNotifier{
+		pending:     map[dropKey]*DropNotification{},
+		subscribers: map[int]DropNotificationFn{},
+	}
+)
+
+// DropNotification describes a number of records that were dropped by a handler.
+type DropNotification struct {
+	// Count is the number of records that were dropped.
+	Count uint64
+
+	// End is the time at which the notification was sent.
+	End time.Time
+
+	// Handler is the name of the handler which dropped the records, if it has one.
+	Handler string
+
+	// HandlerType is the type of the handler which dropped the records, if it is known.
+	HandlerType string
+
+	// Reason is the reason the records were dropped (eg: [DropReasonMaxRecords]).
+	Reason string
+
+	// Start is the time at which the first of the records was dropped.
+	Start time.Time
+}
+
+// DropNotificationFn is a function that's called whenever records are dropped by a handler.
+//
+// The function is called from its own goroutine, so it may log the notification through the same handlers without
+// deadlocking. It should not modify the notification.
+type DropNotificationFn func(n DropNotification)
+
+// dropKey identifies the handler and reason for which drops are counted together.
+type dropKey struct {
+	handler     string // handler name
+	handlerType string // handler type
+	reason      string // reason for the drop
+}
+
+// dropNotifier counts dropped records and notifies subscribers once per interval.
+type dropNotifier struct {
+	mu          sync.Mutex                    // protects the fields below
+	nextID      int                           // ID for the next subscriber
+	pending     map[dropKey]*DropNotification // drops counted but not yet sent
+	subscribers map[int]DropNotificationFn    // registered subscribers
+}
+
+// DropNotifications returns a channel on which drop notifications are delivered, along with a function which should
+// be called to stop delivery.
+//
+// Notifications are discarded if the channel's buffer of the given size is full when they are sent, so the channel
+// should be drained promptly.
+func DropNotifications(size int) (<-chan DropNotification, func()) {
+	ch := make(chan DropNotification, size)
+	unsubscribe := SubscribeDropNotifications(func(n DropNotification) {
+		select {
+		case ch <- n:
+		default:
+		}
+	})
+	return ch, unsubscribe
+}
+
+// NotifyDropped records that the given number of records were dropped by a handler for the given reason.
+//
+// Subscribers are notified once per [DefaultDropNotificationInterval] with the total number of records dropped by the
+// same handler for the same reason during that interval. Custom handlers with their own backpressure policies should
+// call this function whenever they discard records. Nothing is counted if there are no subscribers, but the drop is
+// still traced (see [Trace]).
+func NotifyDropped(handler, handlerType, reason string, count uint64) {
+	if count > 0 && TraceEnabled() {
+		Trace(context.Background(), TraceEventRecordDropped, "records dropped", slog.String("handler", handler),
+			slog.String("handler_type", handlerType), slog.String("reason", reason), slog.Uint64("count", count))
+	}
+
+	n := dropNotifierState
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if count == 0 || len(n.subscribers) == 0 {
+		return
+	}
+
+	key := dropKey{
+		handler:     handler,
+		handlerType: handlerType,
+		reason:      reason,
+	}
+	if p, ok := n.pending[key]; ok {
+		p.Count += count
+		return
+	}
+	n.pending[key] = &DropNotification{
+		Count:       count,
+		Handler:     handler,
+		HandlerType: handlerType,
+		Reason:      reason,
+		Start:       DefaultClock.Now(),
+	}
+	time.AfterFunc(DefaultDropNotificationInterval, func() {
+		n.flush(key)
+	})
+}
+
+// SubscribeDropNotifications registers a function which is called whenever records are dropped by a handler and
+// returns a function which unregisters it.
+func SubscribeDropNotifications(fn DropNotificationFn) func() {
+	n := dropNotifierState
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	id := n.nextID
+	n.nextID++
+	n.subscribers[id] = fn
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			delete(n.subscribers, id)
+		})
+	}
+}
+
+// flush sends the pending notification for the given key to all subscribers.
+func (n *dropNotifier) flush(key dropKey) {
+	n.mu.Lock()
+	p, ok := n.pending[key]
+	delete(n.pending, key)
+	subscribers := make([]DropNotificationFn, 0, len(n.subscribers))
+	for _, fn := range n.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	n.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	notification := *p
+	notification.End = DefaultClock.Now()
+	for _, fn := range subscribers {
+		fn(notification)
+	}
+}
@@ -0,0 +1,26 @@
+package xlog
+
+import (
+	"bytes"
+	"log/slog"
+)
+
+// Encoder defines the interface for an object which formats a record for output.
+//
+// Handlers which support encoders take care of level filtering, handler-level attributes and groups, buffering and
+// writing the output, so a custom output format only has to implement this interface once to be usable by all of
+// them.
+type Encoder interface {
+	// EncodeRecord should encode the record along with any attributes and groups that were added to the handler
+	// using [slog.Handler.WithAttrs] and [slog.Handler.WithGroup] and write the result to buf.
+	//
+	// attrs holds the handler-level attributes with any groups which were open when they were added already applied,
+	// so they may contain nested group attributes. groups holds the names of the groups that are currently open and
+	// which the record's own attributes belong to.
+	//
+	// Line-based formats should terminate the output with a newline.
+	//
+	// References:
+	//   https://pkg.go.dev/log/slog#Handler
+	EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error
+}
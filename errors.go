@@ -61,4 +61,34 @@ const (
 
 	// HTTPResponseError indicates that there was an error specifically with an HTTP response.
 	HTTPResponseError = 16
+
+	// DataEncryptionError indicates that there was an error encrypting data.
+	DataEncryptionError = 17
+
+	// DataDecryptionError indicates that there was an error decrypting data.
+	DataDecryptionError = 18
+
+	// DataReadError indicates that there was an error reading data from a file, stream or network connection.
+	DataReadError = 19
+
+	// NetworkListenError indicates that there was an error listening for or accepting network connections.
+	NetworkListenError = 20
+
+	// PluginError indicates that there was an error starting, communicating with or stopping a plugin process.
+	PluginError = 21
+
+	// SignatureVerificationError indicates that a signature was missing or did not match the signed data.
+	SignatureVerificationError = 22
+
+	// DataWriteError indicates that there was an error writing data to a file, stream or network connection.
+	DataWriteError = 23
+
+	// AuditDeliveryError indicates that an audit record could not be delivered to its destination.
+	AuditDeliveryError = 24
+
+	// EventValidationError indicates that an event is missing one or more required fields.
+	EventValidationError = 25
+
+	// UnsupportedFormatError indicates that data is not in any of the formats supported by a function.
+	UnsupportedFormatError = 26
 )
@@ -0,0 +1,259 @@
+// Package events logs typed event structs as structured records so that wide events (eg: canonical log lines) are
+// validated and serialized consistently wherever they are logged.
+//
+// Applications define a struct for each event and describe its attributes using "log" struct tags:
+//
+//	type UserSignedUp struct {
+//		UserID string        `log:"user_id,required"`
+//		Plan   string        `log:"plan,omitempty"`
+//		Took   time.Duration `log:"duration"`
+//		Client struct {
+//			IP string `log:"ip"`
+//		} `log:"client"`
+//	}
+//
+//	func (UserSignedUp) EventName() string { return "user signed up" }
+//
+//	err := events.Log(ctx, logger, slog.LevelInfo, UserSignedUp{UserID: "42"})
+//
+// The tag holds the attribute key followed by any of the following options, separated by commas:
+//   - required: the event is invalid if the field holds its zero value
+//   - omitempty: the attribute is omitted if the field holds its zero value
+//
+// Fields without a tag use the field's name as the key and fields tagged with "-" are skipped. Nested structs (other
+// than [time.Time], types implementing [slog.LogValuer] and types of the structs containing them) become groups.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+var (
+	// _plans caches the field plans for each event type.
+	_plans sync.Map // map[reflect.Type][]fieldPlan
+
+	// logValuerType is the reflected type of the [slog.LogValuer] interface.
+	logValuerType = reflect.TypeFor[slog.LogValuer]()
+
+	// timeType is the reflected type of [time.Time].
+	timeType = reflect.TypeFor[time.Time]()
+)
+
+// Event may be implemented by event structs to set the message of the records they are logged as.
+//
+// Events which do not implement this interface are logged with the name of their type as the message.
+type Event interface {
+	// EventName should return the message for the event's record.
+	EventName() string
+}
+
+// fieldPlan describes how a single struct field is converted into an attribute.
+type fieldPlan struct {
+	group     []fieldPlan // plans for the fields of a nested struct, if the field is a group
+	index     int         // index of the field in the struct
+	key       string      // attribute key
+	name      string      // name of the field, used in error messages
+	omitEmpty bool        // whether or not to omit the attribute if the field is zero
+	required  bool        // whether or not the field must be non-zero
+}
+
+// Attrs validates the given event and converts it into attributes.
+//
+// The event must be a struct or a pointer to a struct.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.EventValidationError]: one or more required fields are missing
+//   - [xlog.InvalidParameter]: the event is not a struct
+func Attrs(event any) ([]slog.Attr, xerrors.Error) {
+	v, plans, err := inspect(event)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	attrs := buildAttrs(v, plans, "", &missing)
+	if len(missing) > 0 {
+		return nil, xerrors.Newf(xlog.EventValidationError, "%s: missing required fields: %s", Name(event),
+			strings.Join(missing, ", ")).WithAttrs(map[string]any{
+			"event":  Name(event),
+			"fields": missing,
+		})
+	}
+	return attrs, nil
+}
+
+// Log validates the given event and logs it at the given level using the given logger.
+//
+// If logger is nil, the default logger is used. Nothing is validated or logged if the logger is not enabled for the
+// level.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.EventValidationError]: one or more required fields are missing
+//   - [xlog.InvalidParameter]: the event is not a struct
+//
+// In addition, the function may return any error returned by the logger's handler.
+func Log(ctx context.Context, logger *slog.Logger, level slog.Level, event any) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !logger.Enabled(ctx, level) {
+		return nil
+	}
+	attrs, err := Attrs(event)
+	if err != nil {
+		return err
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:]) // skip [runtime.Callers, Log]
+	r := slog.NewRecord(xlog.DefaultClock.Now(), level, Name(event), pcs[0])
+	r.AddAttrs(attrs...)
+	return logger.Handler().Handle(ctx, r)
+}
+
+// Name returns the message for the given event, which is the value returned by [Event.EventName] if the event
+// implements [Event] or the name of its type otherwise.
+func Name(event any) string {
+	if e, ok := event.(Event); ok {
+		return e.EventName()
+	}
+	t := reflect.TypeOf(event)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
+// Validate checks that all of the required fields of the given event are set.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.EventValidationError]: one or more required fields are missing
+//   - [xlog.InvalidParameter]: the event is not a struct
+func Validate(event any) xerrors.Error {
+	_, err := Attrs(event)
+	return err
+}
+
+// buildAttrs converts the fields of the given struct value into attributes according to the given plans, adding the
+// dotted names of any missing required fields to missing.
+func buildAttrs(v reflect.Value, plans []fieldPlan, prefix string, missing *[]string) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(plans))
+	for _, plan := range plans {
+		field := v.Field(plan.index)
+		if plan.group != nil {
+			for field.Kind() == reflect.Pointer && !field.IsNil() {
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Pointer {
+				if plan.required {
+					*missing = append(*missing, prefix+plan.name)
+				}
+				continue
+			}
+			group := buildAttrs(field, plan.group, prefix+plan.name+".", missing)
+			if len(group) > 0 || !plan.omitEmpty {
+				attrs = append(attrs, slog.Attr{Key: plan.key, Value: slog.GroupValue(group...)})
+			}
+			continue
+		}
+
+		if field.IsZero() {
+			if plan.required {
+				*missing = append(*missing, prefix+plan.name)
+				continue
+			}
+			if plan.omitEmpty {
+				continue
+			}
+		}
+		attrs = append(attrs, slog.Any(plan.key, field.Interface()))
+	}
+	return attrs
+}
+
+// inspect returns the struct value held by the given event along with the plans for its fields.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: the event is not a struct
+func inspect(event any) (reflect.Value, []fieldPlan, xerrors.Error) {
+	v := reflect.ValueOf(event)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, xerrors.Newf(xlog.InvalidParameter, "event must be a struct, but got %T", event)
+	}
+	return v, planFor(v.Type()), nil
+}
+
+// planFor returns the cached field plans for the given struct type, creating them if necessary.
+func planFor(t reflect.Type) []fieldPlan {
+	if plans, ok := _plans.Load(t); ok {
+		return plans.([]fieldPlan)
+	}
+	plans := newPlans(t, map[reflect.Type]bool{t: true})
+	_plans.Store(t, plans)
+	return plans
+}
+
+// newPlans creates the field plans for the given struct type.
+//
+// Nested structs whose types are in ancestors are not turned into groups so that types which refer back to
+// themselves, directly or through other types, do not recurse forever.
+func newPlans(t reflect.Type, ancestors map[reflect.Type]bool) []fieldPlan {
+	plans := make([]fieldPlan, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("log")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		plan := fieldPlan{
+			index: i,
+			key:   name,
+			name:  field.Name,
+		}
+		if plan.key == "" {
+			plan.key = field.Name
+		}
+		for option := range strings.SplitSeq(options, ",") {
+			switch strings.TrimSpace(option) {
+			case "omitempty":
+				plan.omitEmpty = true
+			case "required":
+				plan.required = true
+			}
+		}
+
+		// nested structs become groups unless they know how to log themselves or refer back to a type which
+		// contains them
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !ancestors[ft] && ft != timeType && !ft.Implements(logValuerType) &&
+			!reflect.PointerTo(ft).Implements(logValuerType) {
+			ancestors[ft] = true
+			plan.group = newPlans(ft, ancestors)
+			delete(ancestors, ft)
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
@@ -0,0 +1,149 @@
+package xlog
+
+import (
+	"context"
+	"encoding/hex"
+	"hash/fnv"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+var (
+	// DefaultFingerprintKey is the default key of the attribute which holds the fingerprint of a record.
+	//
+	// This value is used when the fingerprint key in [FingerprintHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#FingerprintHandlerOptions
+	DefaultFingerprintKey = "fingerprint"
+
+	// DefaultLoggerNameKey is the default key of the attribute which holds the name of the named logger from which a
+	// record originated.
+	//
+	// This value is used when the logger key in [FingerprintHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#FingerprintHandlerOptions
+	DefaultLoggerNameKey = "logger"
+)
+
+// FingerprintHandlerOptions holds the options for the handler returned by [NewFingerprintHandler].
+type FingerprintHandlerOptions struct {
+	// FingerprintKey is the key of the attribute which holds the fingerprint.
+	//
+	// The default behavior is defined by the default fingerprint key setting defined in the package.
+	FingerprintKey string
+
+	// LoggerKey is the key of the attribute which holds the name of the originating logger.
+	//
+	// The default behavior is defined by the default logger name key setting defined in the package.
+	LoggerKey string
+}
+
+// fingerprintHandler is the [slog.Handler] returned by [NewFingerprintHandler].
+type fingerprintHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	options FingerprintHandlerOptions // immutable handler options
+}
+
+// ensure [fingerprintHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &fingerprintHandler{}
+
+// NewFingerprintHandler returns a new [slog.Handler] which stamps each record with a fingerprint attribute and, for
+// records logged through a logger returned by [GetLogger], an attribute holding the name of the logger.
+//
+// The fingerprint is computed by [RecordFingerprint] from the record's message and source location, so every record
+// logged from the same call site with the same message has the same fingerprint no matter which values it holds. This
+// allows sinks to deduplicate, group or rate limit records per call site.
+//
+// Both attributes are added at the top level of the record, outside of any groups, and records which already hold an
+// attribute with the same key at the top level are not changed.
+func NewFingerprintHandler(h slog.Handler, options FingerprintHandlerOptions) slog.Handler {
+	if options.FingerprintKey == "" {
+		options.FingerprintKey = DefaultFingerprintKey
+	}
+	if options.LoggerKey == "" {
+		options.LoggerKey = DefaultLoggerNameKey
+	}
+	return &fingerprintHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		options:         options,
+	}
+}
+
+// RecordFingerprint returns a stable fingerprint for the record, which is a hex-encoded 64-bit FNV-1a hash of the
+// record's message and the function, file and line of its source location, if it has one.
+//
+// If the record holds a top-level string attribute whose key is the default message template key defined in the
+// package (see [NewTemplateHandler]), the template is hashed in place of the message.
+func RecordFingerprint(r slog.Record) string {
+	msg := r.Message
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == DefaultMessageTemplateKey && attr.Value.Kind() == slog.KindString {
+			msg = attr.Value.String()
+			return false
+		}
+		return true
+	})
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(msg))
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		_, _ = hash.Write([]byte{0})
+		_, _ = hash.Write([]byte(frame.Function))
+		_, _ = hash.Write([]byte{0})
+		_, _ = hash.Write([]byte(frame.File))
+		_, _ = hash.Write([]byte{0})
+		_, _ = hash.Write([]byte(strconv.Itoa(frame.Line)))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *fingerprintHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *fingerprintHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the fingerprint and logger attributes, combines the handler's attributes with the record's attributes
+// and passes the resulting record to the underlying handler.
+func (h *fingerprintHandler) Handle(ctx context.Context, r slog.Record) error {
+	stamps := []slog.Attr{slog.String(h.options.FingerprintKey, RecordFingerprint(r))}
+	if name, ok := LoggerNameFromContext(ctx); ok {
+		stamps = append(stamps, slog.String(h.options.LoggerKey, name))
+	}
+	return h.handler.Handle(ctx, h.stamp(r, stamps...))
+}
+
+// Options returns the handler's options.
+func (h *fingerprintHandler) Options() any {
+	return h.options
+}
+
+// Type returns the type of the handler.
+func (h *fingerprintHandler) Type() string {
+	return "fingerprint"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *fingerprintHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *fingerprintHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
@@ -0,0 +1,131 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+var (
+	// DefaultFlattenSeparator is the default string used to join the keys of nested groups when they are flattened.
+	//
+	// This value is used when the separator in [FlattenHandlerOptions] is empty or when an empty separator is passed
+	// to [FlattenAttrs].
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFlattenHandler
+	DefaultFlattenSeparator = "."
+)
+
+// FlattenHandlerOptions holds the options for [NewFlattenHandler].
+type FlattenHandlerOptions struct {
+	// Separator is the string used to join the keys of nested groups (eg: "." for "event.dataSource.name").
+	//
+	// The default behavior is defined by the default flatten separator defined in the package.
+	Separator string
+}
+
+// flattenHandler is the [slog.Handler] returned by [NewFlattenHandler].
+type flattenHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	separator string // string used to join group keys
+}
+
+// ensure [flattenHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &flattenHandler{}
+
+// FlattenAttrs returns a copy of the given attributes with every group replaced by its attributes, whose keys are
+// prefixed with the key of the group and the given separator (eg: a group "event" holding a group "dataSource" holding
+// an attribute "name" becomes a single attribute with the key "event.dataSource.name").
+//
+// Values are resolved, groups with empty keys are inlined into their parent and empty groups are removed, the same
+// way [slog] handlers treat them. If the separator is empty, [DefaultFlattenSeparator] is used.
+func FlattenAttrs(attrs []slog.Attr, separator string) []slog.Attr {
+	if separator == "" {
+		separator = DefaultFlattenSeparator
+	}
+	return appendFlattenedAttrs(make([]slog.Attr, 0, len(attrs)), attrs, "", separator)
+}
+
+// NewFlattenHandler returns a new [slog.Handler] which flattens nested groups into attributes with dotted keys before
+// passing records to the given handler, for sinks that do not handle nested JSON objects well (eg: CSV files or
+// SIEM field extractors).
+//
+// Attributes added using WithAttrs and attributes in the record are combined and flattened using [FlattenAttrs], so
+// the underlying handler receives every record without any groups. Note that the underlying handler's ReplaceAttr
+// function, if any, therefore sees the flattened keys rather than the original groups.
+func NewFlattenHandler(h slog.Handler, options FlattenHandlerOptions) slog.Handler {
+	if options.Separator == "" {
+		options.Separator = DefaultFlattenSeparator
+	}
+	return &flattenHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		separator:       options.Separator,
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *flattenHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *flattenHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle combines the handler's attributes with the record's attributes, flattens any groups and passes the resulting
+// record to the underlying handler.
+func (h *flattenHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(FlattenAttrs(h.mergeAttrs(r), h.separator)...)
+	return h.handler.Handle(ctx, record)
+}
+
+// Options returns the handler's options.
+func (h *flattenHandler) Options() any {
+	return FlattenHandlerOptions{
+		Separator: h.separator,
+	}
+}
+
+// Type returns the type of the handler.
+func (h *flattenHandler) Type() string {
+	return "flatten"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *flattenHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *flattenHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// appendFlattenedAttrs appends the given attributes to dst with any groups flattened and their keys prefixed with the
+// given prefix and returns the extended slice.
+func appendFlattenedAttrs(dst, attrs []slog.Attr, prefix, separator string) []slog.Attr {
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Value.Kind() == slog.KindGroup {
+			groupPrefix := prefix
+			if attr.Key != "" {
+				groupPrefix = prefix + attr.Key + separator
+			}
+			dst = appendFlattenedAttrs(dst, attr.Value.Group(), groupPrefix, separator)
+			continue
+		}
+		if attr.Equal(slog.Attr{}) {
+			continue
+		}
+		attr.Key = prefix + attr.Key
+		dst = append(dst, attr)
+	}
+	return dst
+}
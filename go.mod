@@ -3,6 +3,7 @@ module go.innotegrity.dev/xlog
 go 1.25.0
 
 require (
+	filippo.io/age v1.2.1
 	github.com/lmittmann/tint v1.1.2
 	github.com/mattn/go-colorable v0.1.14
 	github.com/mattn/go-isatty v0.0.20
@@ -28,5 +29,6 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sts v1.39.0 // indirect
 	github.com/aws/smithy-go v1.23.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 )
@@ -0,0 +1,208 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// goroutineAttrLabelPrefix is the prefix of the keys of the profiler labels holding goroutine attributes, which
+	// distinguishes them from labels set for other purposes.
+	goroutineAttrLabelPrefix = "xlog."
+)
+
+var (
+	// _goroutineLabelsSupported holds whether or not the profiler labels of the current goroutine can be read, which is
+	// only checked once.
+	_goroutineLabelsSupported = sync.OnceValue(checkGoroutineLabels)
+)
+
+// goroutineAttrsHandler is the [slog.Handler] returned by [NewGoroutineAttrsHandler].
+type goroutineAttrsHandler struct {
+	stampingWrapper
+}
+
+// ensure [goroutineAttrsHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &goroutineAttrsHandler{}
+
+// goroutineLabel mirrors a single profiler label as stored by the runtime/pprof package.
+type goroutineLabel struct {
+	key   string // key of the label
+	value string // value of the label
+}
+
+// DoWithGoroutineAttrs calls fn with a copy of the given context holding the given attributes, in addition to any
+// attributes it already holds, as profiler labels (see [pprof.Do]).
+//
+// The labels are also attached to the current goroutine, along with any labels already attached to it, while fn runs,
+// so goroutines started by fn inherit them, and the labels of the goroutine are restored once fn returns. Attribute
+// values are stored as strings. Unlike [SetGoroutineAttrs], this function works even if the package cannot read the
+// profiler labels of the current goroutine, in which case the labels already attached to it are replaced while fn runs
+// (see [pprof.Do]) and the attributes only reach records logged with the context passed to fn or a context derived
+// from it.
+func DoWithGoroutineAttrs(ctx context.Context, attrs []slog.Attr, fn func(ctx context.Context)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	previous, ok := goroutineLabels()
+	if !ok {
+		pprof.Do(ctx, pprof.Labels(goroutineAttrPairs(nil, attrs)...), fn)
+		return
+	}
+	defer pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(goroutineLabelPairs(previous)...)))
+	ctx = pprof.WithLabels(ctx, pprof.Labels(goroutineAttrPairs(goroutineLabelPairs(previous), attrs)...))
+	pprof.SetGoroutineLabels(ctx)
+	fn(ctx)
+}
+
+// GoroutineAttrs returns the attributes attached to the current goroutine using [SetGoroutineAttrs] or
+// [DoWithGoroutineAttrs], sorted by key, along with any attributes stored in the given context by
+// [DoWithGoroutineAttrs] whose keys are not attached to the goroutine.
+//
+// The attributes attached to the goroutine are read from its profiler labels, so they are found even if the caller
+// logs using [context.Background]. If the package cannot read the labels of the goroutine, either because it was built
+// with the "xlog_nogoroutinelabels" tag or because the release of Go in use stores them differently, only the
+// attributes stored in the context are returned. Nil is returned if there are no attributes.
+func GoroutineAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	add := func(key, value string) bool {
+		key, ok := strings.CutPrefix(key, goroutineAttrLabelPrefix)
+		if ok && !slices.ContainsFunc(attrs, func(attr slog.Attr) bool { return attr.Key == key }) {
+			attrs = append(attrs, slog.String(key, value))
+		}
+		return true
+	}
+	labels, _ := goroutineLabels()
+	for _, label := range labels {
+		add(label.key, label.value)
+	}
+	if ctx != nil {
+		pprof.ForLabels(ctx, add)
+	}
+	slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	return attrs
+}
+
+// NewGoroutineAttrsHandler returns a new [slog.Handler] which adds the attributes attached to the goroutine logging
+// each record (see [GoroutineAttrs]) to the record.
+//
+// The attributes are added at the top level of the record, outside of any groups, and attributes which the record
+// already holds at the top level are not replaced.
+func NewGoroutineAttrsHandler(h slog.Handler) slog.Handler {
+	return &goroutineAttrsHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+	}
+}
+
+// SetGoroutineAttrs attaches the given attributes to the current goroutine, in addition to any attributes already
+// attached to it, and returns a function which restores the attributes which were attached before.
+//
+// This allows attributes (eg: a request ID) to reach records logged by legacy code paths which do not pass a context
+// along, when the records are handled by a handler returned by [NewGoroutineAttrsHandler]. The attributes are stored
+// as profiler labels (see [pprof.SetGoroutineLabels]), so their values are stored as strings, goroutines started by
+// the current goroutine inherit them and they also appear in CPU and goroutine profiles. The returned function should
+// be called from the same goroutine, typically using defer:
+//
+//	defer xlog.SetGoroutineAttrs(slog.String("request_id", id))()
+//
+// Setting the labels of a goroutine replaces all of them, so if the package cannot read the existing labels (see
+// [GoroutineAttrs]) nothing is attached rather than discarding labels set for other purposes, and the returned function
+// does nothing. Use [DoWithGoroutineAttrs] where a context can be passed along instead.
+func SetGoroutineAttrs(attrs ...slog.Attr) func() {
+	previous, ok := goroutineLabels()
+	if !ok {
+		return func() {}
+	}
+	pairs := goroutineAttrPairs(goroutineLabelPairs(previous), attrs)
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(pairs...)))
+
+	return func() {
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(goroutineLabelPairs(previous)...)))
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *goroutineAttrsHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *goroutineAttrsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the attributes attached to the current goroutine, combines the handler's attributes with the record's
+// attributes and passes the resulting record to the underlying handler.
+func (h *goroutineAttrsHandler) Handle(ctx context.Context, r slog.Record) error {
+	goroutineAttrs := GoroutineAttrs(ctx)
+	if len(goroutineAttrs) == 0 && len(h.attrs) == 0 && len(h.groups) == 0 {
+		return h.handler.Handle(ctx, r)
+	}
+
+	return h.handler.Handle(ctx, h.stamp(r, goroutineAttrs...))
+}
+
+// Options always returns nil since the handler has no options.
+func (h *goroutineAttrsHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *goroutineAttrsHandler) Type() string {
+	return "goroutine_attrs"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *goroutineAttrsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *goroutineAttrsHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// checkGoroutineLabels returns whether or not the profiler labels of a goroutine are stored the way this package
+// expects by setting known labels on a new goroutine and reading them back.
+func checkGoroutineLabels() bool {
+	result := make(chan bool)
+	go func() {
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("a", "1", "b", "2")))
+		labels, ok := readGoroutineLabels()
+		result <- ok && slices.Equal(labels, []goroutineLabel{{key: "a", value: "1"}, {key: "b", value: "2"}})
+	}()
+	return <-result
+}
+
+// goroutineAttrPairs appends the keys and values of the labels holding the given attributes to the given pairs.
+func goroutineAttrPairs(pairs []string, attrs []slog.Attr) []string {
+	for _, attr := range attrs {
+		if attr.Key != "" {
+			pairs = append(pairs, goroutineAttrLabelPrefix+attr.Key, attr.Value.Resolve().String())
+		}
+	}
+	return pairs
+}
+
+// goroutineLabelPairs returns the keys and values of the given labels.
+func goroutineLabelPairs(labels []goroutineLabel) []string {
+	pairs := make([]string, 0, 2*len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, label.key, label.value)
+	}
+	return pairs
+}
+
+// goroutineLabels returns a copy of the profiler labels of the current goroutine and whether or not they could be
+// read.
+func goroutineLabels() ([]goroutineLabel, bool) {
+	if !_goroutineLabelsSupported() {
+		return nil, false
+	}
+	return readGoroutineLabels()
+}
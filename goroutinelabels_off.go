@@ -0,0 +1,10 @@
+//go:build xlog_nogoroutinelabels
+
+package xlog
+
+// readGoroutineLabels always reports that the profiler labels of the current goroutine cannot be read.
+//
+// The package was built with the "xlog_nogoroutinelabels" tag, so goroutine attributes are only read from contexts.
+func readGoroutineLabels() ([]goroutineLabel, bool) {
+	return nil, false
+}
@@ -0,0 +1,32 @@
+//go:build !xlog_nogoroutinelabels
+
+package xlog
+
+import (
+	"slices"
+	"unsafe"
+)
+
+// goroutineLabelSet mirrors the profiler labels of a goroutine as stored by the runtime/pprof package.
+type goroutineLabelSet struct {
+	list []goroutineLabel // labels sorted by key
+}
+
+// runtimeGetProfLabel returns the profiler labels of the current goroutine, which is the function used internally by
+// the runtime/pprof package.
+//
+// Build with the "xlog_nogoroutinelabels" tag to avoid linking to it, in which case goroutine attributes are only read
+// from contexts.
+//
+//go:linkname runtimeGetProfLabel runtime/pprof.runtime_getProfLabel
+func runtimeGetProfLabel() unsafe.Pointer
+
+// readGoroutineLabels returns a copy of the profiler labels of the current goroutine without checking whether they
+// are stored the way this package expects.
+func readGoroutineLabels() ([]goroutineLabel, bool) {
+	set := (*goroutineLabelSet)(runtimeGetProfLabel())
+	if set == nil {
+		return nil, true
+	}
+	return slices.Clone(set.list), true
+}
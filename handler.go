@@ -3,11 +3,13 @@ package xlog
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"reflect"
+	"strings"
 
 	"go.innotegrity.dev/xerrors"
 )
@@ -37,6 +39,29 @@ var (
 // [slog.Record.Clone] function to clone it first.
 type ErrorHandlerFn func(ctx context.Context, err error, r *slog.Record) error
 
+// AsyncHandler defines the interface for a handler which may deliver records to its sink after Handle returns (eg: by
+// buffering records or sending them from another goroutine).
+type AsyncHandler interface {
+	// Async should return whether or not records may still be delivered after Handle returns, even once the handler
+	// has been flushed using its Flush function, if it has one.
+	Async() bool
+}
+
+// BatchHandler defines the interface for a handler which is able to process multiple records at once, such as a
+// handler which sends records to a sink with a native bulk API.
+//
+// Use the [HandleBatch] function to pass records to any handler, whether or not it implements this interface.
+type BatchHandler interface {
+	slog.Handler
+
+	// HandleBatch should process all of the given records as a single batch.
+	//
+	// Unlike [slog.Handler.Handle], the caller does not check whether the handler is enabled for each record first,
+	// so the handler should skip any records it is not enabled for. The handler should not retain or modify the
+	// records after the function returns.
+	HandleBatch(ctx context.Context, records []slog.Record) error
+}
+
 // ExtendedHandler defines the interface for a handler with extended functionality that is useful when creating
 // handlers from configuration files.
 type ExtendedHandler interface {
@@ -48,12 +73,31 @@ type ExtendedHandler interface {
 	ChildHandlers() []slog.Handler
 
 	// Options should return the configured handler-specific options.
+	//
+	// Handlers should treat their options as immutable once they are created and return a copy which the caller can
+	// modify without affecting the handler. Level variables may be shared since they are designed to be changed
+	// while the handler is in use.
 	Options() any
 
 	// Type should return the type of the handler.
 	Type() string
 }
 
+// Flusher defines the interface for a handler which buffers records and is able to write them to its sink on demand.
+type Flusher interface {
+	// Flush should write any buffered records to the handler's sink and return any error that occurs.
+	Flush() error
+}
+
+// HealthChecker defines the interface for a handler which can report whether or not it is currently able to deliver
+// records (eg: whether a network handler is connected to its sink).
+//
+// Use the [CheckHealth] function to check every handler in a tree.
+type HealthChecker interface {
+	// HealthCheck should return nil if the handler is able to deliver records or an error describing why it is not.
+	HealthCheck(ctx context.Context) error
+}
+
 // LevelHandler defines the interface for a handler that allows you to retrieve underlying [slog.LevelVar] objects
 // in the handler which is when building handlers from configuration files.
 type LevelVarHandler interface {
@@ -72,6 +116,38 @@ type LevelVarHandler interface {
 	GetMaxLevelVar() *slog.LevelVar
 }
 
+// OptionsValidator defines the interface for handler options which are able to check themselves for problems before
+// a handler is created from them.
+type OptionsValidator interface {
+	// Validate should check the options and return a single [OptionsValidationError] error describing every problem
+	// found (not just the first one) or nil if there are none.
+	//
+	// The names of the fields with problems should be attached to the error as attributes so that configuration
+	// errors can be traced back to their source.
+	Validate() xerrors.Error
+}
+
+// CheckHealth checks the health of the given handler and all of its descendants which implement [HealthChecker],
+// using [ExtendedHandler.ChildHandlers] to walk the tree.
+//
+// It returns nil if every handler is healthy or all of the errors returned by unhealthy handlers joined together.
+func CheckHealth(ctx context.Context, h slog.Handler) error {
+	var errs []error
+	if hc, ok := h.(HealthChecker); ok {
+		if err := hc.HealthCheck(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			if err := CheckHealth(ctx, child); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // DefaultErrorHandler can be used as a default error handler for any of the handlers supported by this package.
 //
 // It will simply wrap the error in an [xerrors.Error] object and add the record's details as attributes to the error
@@ -117,9 +193,34 @@ func DefaultErrorHandler(ctx context.Context, err error, r *slog.Record) error {
 	return xerr.WithAttrs(output)
 }
 
+// FlushHandler flushes the given handler and all of its descendants which implement [Flusher], using
+// [ExtendedHandler.ChildHandlers] to walk the tree.
+//
+// It returns nil if every handler was flushed or all of the errors returned by the handlers joined together.
+func FlushHandler(h slog.Handler) error {
+	var errs []error
+	if flusher, ok := h.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			if err := FlushHandler(child); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // GetHandlerOptionValue inspects the given options (which should be a struct or a pointer to a struct) to find an
 // exported field with the given name. If the field exists and is exported, it returns the field's value.
 //
+// The name may be a dotted path (eg: "File.FSPath" or "file.path") to retrieve the value of a field nested inside
+// of structs, pointers to structs or maps with string keys. Each part of the path may be either the name of a field
+// or the name given to it in its JSON struct tag. A name without any dots works exactly as it always has.
+//
 // This function may return an error with any of the following codes:
 //   - [HandlerOptionDoesNotExist]: given field (name) does not exist in the options
 //   - [HandlerOptionIsNotGettable]: given field (name) cannot be retrieve because it is not exported
@@ -135,17 +236,70 @@ func GetHandlerOptionValue(options any, name string) (any, xerrors.Error) {
 			"options must be a struct or a pointer to a struct, but got %T", options)
 	}
 
-	// get the field from the struct by its name
-	field := objVal.FieldByName(name)
-	if !field.IsValid() {
-		return nil, xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
-	}
-	if !field.CanInterface() {
-		return nil, xerrors.Newf(HandlerOptionIsNotGettable, "%s: field exists but is inaccessible", name)
+	// follow the path to the field
+	field := objVal
+	for _, part := range strings.Split(name, ".") {
+		for (field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface) && !field.IsNil() {
+			field = field.Elem()
+		}
+		switch field.Kind() {
+		case reflect.Struct:
+			field = findOptionField(field, part)
+		case reflect.Map:
+			if field.Type().Key().Kind() != reflect.String {
+				return nil, xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+			}
+			field = field.MapIndex(reflect.ValueOf(part).Convert(field.Type().Key()))
+		default:
+			field = reflect.Value{}
+		}
+		if !field.IsValid() {
+			return nil, xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+		}
+		if !field.CanInterface() {
+			return nil, xerrors.Newf(HandlerOptionIsNotGettable, "%s: field exists but is inaccessible", name)
+		}
 	}
 	return field.Interface(), nil
 }
 
+// HandleBatch passes all of the given records to the handler.
+//
+// If the handler implements [BatchHandler], the records are passed to it as a single batch. Otherwise, each record
+// for which the handler is enabled is passed to the handler's [slog.Handler.Handle] function and all errors are
+// joined together.
+func HandleBatch(ctx context.Context, h slog.Handler, records []slog.Record) error {
+	if bh, ok := h.(BatchHandler); ok {
+		return bh.HandleBatch(ctx, records)
+	}
+
+	var errs []error
+	for _, r := range records {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsAsyncHandler returns true if the given handler or any of its descendants implements [AsyncHandler] and may
+// deliver records after they have been handled and flushed, using [ExtendedHandler.ChildHandlers] to walk the tree.
+func IsAsyncHandler(h slog.Handler) bool {
+	if ah, ok := h.(AsyncHandler); ok && ah.Async() {
+		return true
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			if IsAsyncHandler(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // New is just a wrapper to create a new [slog.Logger] object.
 func New(h slog.Handler) *slog.Logger {
 	return slog.New(h)
@@ -155,6 +309,12 @@ func New(h slog.Handler) *slog.Logger {
 // the given name. If the field exists, is settable, and the type of value is assignable to the field's type, it sets
 // the field's value.
 //
+// The name may be a dotted path (eg: "File.FSPath" or "file.path") to set the value of a field nested inside of
+// structs, pointers to structs or maps with string keys. Each part of the path may be either the name of a field or
+// the name given to it in its JSON struct tag. Nil pointers and maps along the path are created as needed and the
+// last part of the path is added to a map if the key does not exist yet. A name without any dots works exactly as it
+// always has.
+//
 // This function may return an error with any of the following codes:
 //   - [HandlerOptionDoesNotExist]: given field (name) does not exist in the options
 //   - [HandlerOptionDoesNotSupportNil]: given field (name) does not support nil values but one was passed
@@ -173,16 +333,99 @@ func OverrideHandlerOptionValue(options any, name string, value any) xerrors.Err
 		return xerrors.Newf(InvalidParameter,
 			"options must be a pointer to a struct, but got pointer to %v", structVal.Kind())
 	}
+	return setOptionPath(structVal, strings.Split(name, "."), name, value)
+}
 
-	// check if the field is valid (exists) and can be set (is exported).
-	field := structVal.FieldByName(name)
-	if !field.IsValid() {
-		return xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+// findOptionField returns the field in the given struct whose name or JSON struct tag name matches the given name.
+//
+// Fields promoted from embedded structs (eg: [CommonHandlerOptions]) are found as well. It returns the zero value if
+// no such field exists.
+func findOptionField(structVal reflect.Value, name string) reflect.Value {
+	if field := structVal.FieldByName(name); field.IsValid() {
+		return field
 	}
-	if !field.CanSet() {
-		return xerrors.Newf(HandlerOptionIsNotSettable, "%s: field exists but is not settable", name)
+	for _, f := range reflect.VisibleFields(structVal.Type()) {
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "-" && tag == name {
+			if field, err := structVal.FieldByIndexErr(f.Index); err == nil {
+				return field
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+// setOptionPath sets the value of the field at the given path inside of the given settable value.
+//
+// See [OverrideHandlerOptionValue] for details on how the path is followed. name is the full path, which is used in
+// error messages.
+func setOptionPath(field reflect.Value, path []string, name string, value any) xerrors.Error {
+	if len(path) == 0 {
+		return setOptionValue(field, name, value)
 	}
 
+	// create any nil pointers along the way, only storing them once the value has been set so that the options are
+	// left unchanged if it cannot be
+	if field.Kind() == reflect.Pointer {
+		if !field.IsNil() {
+			return setOptionPath(field.Elem(), path, name, value)
+		}
+		elem := reflect.New(field.Type().Elem())
+		if err := setOptionPath(elem.Elem(), path, name, value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		child := findOptionField(field, path[0])
+		if !child.IsValid() {
+			return xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+		}
+		if !child.CanSet() {
+			return xerrors.Newf(HandlerOptionIsNotSettable, "%s: field exists but is not settable", name)
+		}
+		return setOptionPath(child, path[1:], name, value)
+
+	case reflect.Map:
+		mapType := field.Type()
+		if mapType.Key().Kind() != reflect.String {
+			return xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+		}
+
+		// map values are not addressable, so update a copy of the value and store it back in the map
+		key := reflect.ValueOf(path[0]).Convert(mapType.Key())
+		elem := reflect.New(mapType.Elem()).Elem()
+		if existing := field.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		} else if len(path) > 1 {
+			return xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+		}
+		if len(path) > 1 && elem.Kind() == reflect.Interface && !elem.IsNil() {
+			// values stored in interfaces are not addressable either, so copy the underlying value too
+			inner := reflect.New(elem.Elem().Type()).Elem()
+			inner.Set(elem.Elem())
+			if err := setOptionPath(inner, path[1:], name, value); err != nil {
+				return err
+			}
+			elem.Set(inner)
+		} else if err := setOptionPath(elem, path[1:], name, value); err != nil {
+			return err
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(mapType))
+		}
+		field.SetMapIndex(key, elem)
+		return nil
+	}
+	return xerrors.Newf(HandlerOptionDoesNotExist, "%s: no such field exists in the options", name)
+}
+
+// setOptionValue sets the given settable field to the given value.
+//
+// name is the full path to the field, which is used in error messages.
+func setOptionValue(field reflect.Value, name string, value any) xerrors.Error {
 	// handle nil values
 	fieldType := field.Type()
 	valToSetVal := reflect.ValueOf(value)
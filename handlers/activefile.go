@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// _activeLogFiles holds the number of open file handler outputs writing to each absolute log file path.
+	_activeLogFiles   = map[string]int{}
+	_activeLogFilesMu sync.Mutex
+)
+
+// IsActiveLogFile returns whether or not the given log file is currently being written by a [FileHandler] in this
+// process.
+//
+// Rotated backups of a log file are never active, since the handler only writes to the current file.
+func IsActiveLogFile(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	_activeLogFilesMu.Lock()
+	defer _activeLogFilesMu.Unlock()
+	return _activeLogFiles[path] > 0
+}
+
+// acquireActiveLogFile marks the given absolute log file path as being written by a file handler.
+func acquireActiveLogFile(path string) {
+	_activeLogFilesMu.Lock()
+	defer _activeLogFilesMu.Unlock()
+	_activeLogFiles[path]++
+}
+
+// releaseActiveLogFile marks the given absolute log file path as no longer being written by a file handler.
+func releaseActiveLogFile(path string) {
+	_activeLogFilesMu.Lock()
+	defer _activeLogFilesMu.Unlock()
+	if _activeLogFiles[path] <= 1 {
+		delete(_activeLogFiles, path)
+		return
+	}
+	_activeLogFiles[path]--
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+
+	"filippo.io/age"
+	"go.innotegrity.dev/xerrors"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// archiveBackupTimeFormat is the format of the timestamp lumberjack embeds in the name of rotated log files.
+	archiveBackupTimeFormat = "2006-01-02T15-04-05.000"
+
+	// archiveCompressSuffix is the suffix added to rotated log files that have been compressed.
+	archiveCompressSuffix = ".gz"
+
+	// archiveEncryptSuffix is the suffix added to rotated log files that have been encrypted.
+	archiveEncryptSuffix = ".age"
+
+	// archiveDefaultMaxSize is the size (in megabytes) at which lumberjack rotates files when no size is configured.
+	archiveDefaultMaxSize = 100
+)
+
+// archiveWriter is a goroutine-safe wrapper for a [lumberjack.Logger] which encrypts rotated log files.
+//
+// lumberjack does not expose a hook for when a file is rotated, so the writer tracks the size of the active file
+// the same way lumberjack does in order to detect a rotation. Once a rotation occurs, a background goroutine
+// compresses (if enabled) and encrypts any rotated files and then applies the retention settings to the encrypted
+// files, since lumberjack is unable to recognize them.
+type archiveWriter struct {
+	// unexported variables
+	closeOnce    sync.Once           // ensures the writer is only closed once
+	compress     bool                // whether or not to compress rotated files before encrypting them
+	done         chan struct{}       // closed to stop the background goroutine
+	errorHandler xlog.ErrorHandlerFn // called when rotated files cannot be processed
+	kick         chan struct{}       // signals the background goroutine to process rotated files
+	logger       *lumberjack.Logger  // underlying lumberjack logger
+	maxAge       int                 // maximum number of days to retain encrypted files
+	maxCount     int                 // maximum number of encrypted files to retain
+	mu           sync.Mutex          // mutex for synchronization
+	recipient    age.Recipient       // recipient used to encrypt rotated files
+	size         int64               // current size of the active log file
+	wg           sync.WaitGroup      // tracks the background goroutine
+}
+
+// newArchiveWriter creates a new [archiveWriter] object and starts the goroutine which processes rotated files.
+//
+// Compression and retention are handled by the writer, so they are disabled on the given logger.
+func newArchiveWriter(logger *lumberjack.Logger, recipient age.Recipient, errorHandler xlog.ErrorHandlerFn,
+) *archiveWriter {
+	w := &archiveWriter{
+		compress:     logger.Compress,
+		done:         make(chan struct{}),
+		errorHandler: errorHandler,
+		kick:         make(chan struct{}, 1),
+		logger:       logger,
+		maxAge:       logger.MaxAge,
+		maxCount:     logger.MaxBackups,
+		recipient:    recipient,
+	}
+	logger.Compress = false
+	logger.MaxAge = 0
+	logger.MaxBackups = 0
+	if info, err := os.Stat(logger.Filename); err == nil {
+		w.size = info.Size()
+	}
+
+	// process any files left unencrypted by a previous run
+	w.wg.Add(1)
+	go w.run()
+	w.trigger()
+	return w
+}
+
+// Close closes the underlying log file and synchronously processes any remaining rotated files.
+func (w *archiveWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		err = w.logger.Close()
+		w.mu.Unlock()
+
+		close(w.done)
+		w.wg.Wait()
+		if archiveErr := w.archive(); archiveErr != nil {
+			err = errors.Join(err, archiveErr)
+		}
+	})
+	return err
+}
+
+// Write implements the io.Writer interface.
+func (w *archiveWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// lumberjack rotates the file before writing whenever the write would exceed the maximum file size
+	maxSize := int64(w.logger.MaxSize) * 1024 * 1024
+	if maxSize == 0 {
+		maxSize = archiveDefaultMaxSize * 1024 * 1024
+	}
+	rotated := w.size+int64(len(p)) > maxSize
+
+	n, err := w.logger.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if rotated {
+		w.size = int64(n)
+		w.trigger()
+	} else {
+		w.size += int64(n)
+	}
+	return n, nil
+}
+
+// archive encrypts any rotated log files which have not yet been encrypted and then removes old encrypted files
+// according to the retention settings.
+func (w *archiveWriter) archive() error {
+	dir := filepath.Dir(w.logger.Filename)
+	base := filepath.Base(w.logger.Filename)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return xerrors.Wrapf(xlog.DataEncryptionError, err, "failed to read log directory '%s': %s", dir,
+			err.Error()).WithAttr("log_dir", dir)
+	}
+
+	// encrypt any plaintext rotated files and collect the encrypted ones
+	type archiveFile struct {
+		path      string
+		timestamp time.Time
+	}
+	var archives []archiveFile
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		encrypted := strings.HasSuffix(name, archiveEncryptSuffix)
+		suffix := ext
+		trimmed := strings.TrimSuffix(name, archiveEncryptSuffix)
+		if strings.HasSuffix(trimmed, ext+archiveCompressSuffix) {
+			suffix = ext + archiveCompressSuffix
+		}
+		if !strings.HasSuffix(trimmed, suffix) {
+			continue
+		}
+		timestamp, err := time.Parse(archiveBackupTimeFormat,
+			strings.TrimSuffix(strings.TrimPrefix(trimmed, prefix), suffix))
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if !encrypted {
+			if path, err = w.encryptFile(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		archives = append(archives, archiveFile{path: path, timestamp: timestamp})
+	}
+
+	// apply the retention settings to the encrypted files, newest first
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].timestamp.After(archives[j].timestamp)
+	})
+	cutoff := time.Now().Add(-time.Duration(w.maxAge) * 24 * time.Hour)
+	for i, a := range archives {
+		if (w.maxCount > 0 && i >= w.maxCount) || (w.maxAge > 0 && a.timestamp.Before(cutoff)) {
+			if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("failed to remove old log archive '%s': %w", a.path, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// encryptFile compresses (if enabled) and encrypts the given rotated file, removing the original on success.
+//
+// The path to the encrypted file is returned.
+func (w *archiveWriter) encryptFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", xerrors.Wrapf(xlog.DataEncryptionError, err, "failed to open rotated log file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", xerrors.Wrapf(xlog.DataEncryptionError, err, "failed to stat rotated log file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+
+	// write to a temporary file first so a partially encrypted file is never mistaken for a complete one
+	dstPath := path
+	if w.compress && !strings.HasSuffix(path, archiveCompressSuffix) {
+		dstPath += archiveCompressSuffix
+	}
+	dstPath += archiveEncryptSuffix
+	tmpPath := dstPath + ".tmp"
+	err = func() error {
+		dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+
+		encWriter, err := age.Encrypt(dst, w.recipient)
+		if err != nil {
+			return err
+		}
+		if w.compress && !strings.HasSuffix(path, archiveCompressSuffix) {
+			gw := gzip.NewWriter(encWriter)
+			if _, err := io.Copy(gw, src); err != nil {
+				return err
+			}
+			if err := gw.Close(); err != nil {
+				return err
+			}
+		} else if _, err := io.Copy(encWriter, src); err != nil {
+			return err
+		}
+		if err := encWriter.Close(); err != nil {
+			return err
+		}
+		return dst.Close()
+	}()
+	if err == nil {
+		err = os.Rename(tmpPath, dstPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", xerrors.Wrapf(xlog.DataEncryptionError, err, "failed to encrypt rotated log file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", xerrors.Wrapf(xlog.DataEncryptionError, err,
+			"failed to remove rotated log file '%s' after encrypting it: %s", path, err.Error()).
+			WithAttr("log_file", path)
+	}
+	return dstPath, nil
+}
+
+// run processes rotated files whenever the writer is signaled until the writer is closed.
+func (w *archiveWriter) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.kick:
+			if err := w.archive(); err != nil && w.errorHandler != nil {
+				w.errorHandler(context.Background(), err, nil)
+			}
+		}
+	}
+}
+
+// trigger signals the background goroutine to process rotated files without blocking.
+func (w *archiveWriter) trigger() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+// DecryptFileHandlerArchive decrypts a rotated log file that was encrypted by a [FileHandler] using the given age
+// X25519 identity (the private key matching the handler's encryption key) and writes the plaintext to w.
+//
+// If the file was compressed before it was encrypted, the data is decompressed as well.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: the identity could not be parsed
+//   - [xlog.DataDecryptionError]: the file could not be read or decrypted
+//   - [xlog.DataCompressionError]: the decrypted data could not be decompressed
+func DecryptFileHandlerArchive(path, identity string, w io.Writer) xerrors.Error {
+	id, err := age.ParseX25519Identity(strings.TrimSpace(identity))
+	if err != nil {
+		return xerrors.Wrapf(xlog.InvalidParameter, err, "failed to parse identity: %s", err.Error())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return xerrors.Wrapf(xlog.DataDecryptionError, err, "failed to open log archive '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	defer file.Close()
+
+	decReader, err := age.Decrypt(file, id)
+	if err != nil {
+		return xerrors.Wrapf(xlog.DataDecryptionError, err, "failed to decrypt log archive '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+
+	// detect compressed data using the gzip magic number rather than relying on the file name
+	r := bufio.NewReader(decReader)
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return xerrors.Wrapf(xlog.DataCompressionError, err, "failed to decompress log archive '%s': %s", path,
+				err.Error()).WithAttr("log_file", path)
+		}
+		defer gr.Close()
+		if _, err := io.Copy(w, gr); err != nil {
+			return xerrors.Wrapf(xlog.DataCompressionError, err, "failed to decompress log archive '%s': %s", path,
+				err.Error()).WithAttr("log_file", path)
+		}
+		return nil
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return xerrors.Wrapf(xlog.DataDecryptionError, err, "failed to decrypt log archive '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	return nil
+}
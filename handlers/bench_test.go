@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xlog/xlogbench"
+)
+
+// benchmarkGoroutines holds the number of goroutines logging records concurrently in each handler benchmark.
+var benchmarkGoroutines = []int{1, 8}
+
+// benchmarkHandler runs the given benchmark once for each number of goroutines, creating a new handler for each run.
+func benchmarkHandler(b *testing.B, newHandler func(b *testing.B) slog.Handler, flush func(h slog.Handler) error) {
+	b.Helper()
+	for _, goroutines := range benchmarkGoroutines {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			xlogbench.Benchmark(b, newHandler(b), xlogbench.Options{
+				Flush:      flush,
+				Goroutines: goroutines,
+			})
+		})
+	}
+}
+
+// closeHandler closes the given handler, which flushes any buffered records, if the handler can be closed.
+func closeHandler(h slog.Handler) error {
+	if c, ok := h.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// newBenchmarkFileHandler creates a buffered file handler writing records in the given format to a temporary file.
+func newBenchmarkFileHandler(b *testing.B, format FileHandlerFormat) slog.Handler {
+	b.Helper()
+	h, err := NewFileHandler(FileHandlerOptions{
+		BufferSize: 64 * 1024,
+		File: types.Path{
+			FSPath: filepath.Join(b.TempDir(), "benchmark.log"),
+			Group:  -1,
+			Owner:  -1,
+		},
+		Format: format,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+func BenchmarkHandlers(b *testing.B) {
+	b.Run("console", func(b *testing.B) {
+		for _, format := range []ConsoleHandlerFormat{ConsoleHandlerJSONFormat, ConsoleHandlerLogfmtFormat} {
+			b.Run(string(format), func(b *testing.B) {
+				benchmarkHandler(b, func(b *testing.B) slog.Handler {
+					h, err := NewConsoleHandler(ConsoleHandlerOptions{
+						Format: format,
+						Writer: io.Discard,
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+					return h
+				}, nil)
+			})
+		}
+	})
+
+	b.Run("discard", func(b *testing.B) {
+		benchmarkHandler(b, func(b *testing.B) slog.Handler {
+			h, err := NewDiscardHandler(DiscardHandlerOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			return h
+		}, nil)
+	})
+
+	b.Run("fanout", func(b *testing.B) {
+		benchmarkHandler(b, func(b *testing.B) slog.Handler {
+			discard, err := NewDiscardHandler(DiscardHandlerOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			h, err := NewFanoutHandler(FanoutHandlerOptions{
+				Handlers: []slog.Handler{newBenchmarkFileHandler(b, FileHandlerJSONFormat), discard},
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			return h
+		}, func(h slog.Handler) error {
+			return closeHandler(h.(*FanoutHandler).ChildHandlers()[0])
+		})
+	})
+
+	b.Run("file", func(b *testing.B) {
+		formats := []FileHandlerFormat{
+			FileHandlerCBORFormat,
+			FileHandlerCEFFormat,
+			FileHandlerCSVFormat,
+			FileHandlerECSFormat,
+			FileHandlerJSONFormat,
+			FileHandlerLEEFFormat,
+			FileHandlerLogfmtFormat,
+			FileHandlerMsgpackFormat,
+			FileHandlerTSVFormat,
+			FileHandlerW3CFormat,
+		}
+		for _, format := range formats {
+			b.Run(string(format), func(b *testing.B) {
+				benchmarkHandler(b, func(b *testing.B) slog.Handler {
+					return newBenchmarkFileHandler(b, format)
+				}, closeHandler)
+			})
+		}
+	})
+
+	b.Run("parquet", func(b *testing.B) {
+		benchmarkHandler(b, func(b *testing.B) slog.Handler {
+			h, err := NewParquetHandler(ParquetHandlerOptions{
+				Directory: b.TempDir(),
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(func() { _ = h.Close() })
+			return h
+		}, nil)
+	})
+
+	b.Run("sentinelone", func(b *testing.B) {
+		benchmarkHandler(b, newBenchmarkSentinelOneHECHandler, closeHandler)
+	})
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+// binaryWriter defines the interface for an object which writes primitive values to a buffer using a particular
+// binary serialization format.
+type binaryWriter interface {
+	writeArrayHeader(buf *bytes.Buffer, n int)
+	writeBool(buf *bytes.Buffer, b bool)
+	writeBytes(buf *bytes.Buffer, b []byte)
+	writeFloat(buf *bytes.Buffer, f float64)
+	writeInt(buf *bytes.Buffer, i int64)
+	writeMapHeader(buf *bytes.Buffer, n int)
+	writeNil(buf *bytes.Buffer)
+	writeString(buf *bytes.Buffer, s string)
+	writeTime(buf *bytes.Buffer, t time.Time)
+	writeUint(buf *bytes.Buffer, u uint64)
+}
+
+// binaryEncoder encodes records as a single map using a binary serialization format.
+//
+// The record is structured the same way as with [slog.JSONHandler]: groups are written as nested maps, times use the
+// format's native timestamp type, durations are written as a number of nanoseconds, errors are written using their
+// error string and the caller is written as a map containing the function, file and line. Other values are
+// converted using their JSON representation.
+//
+// Each encoded record is self-delimiting, so records are simply written one after the other with no separator.
+type binaryEncoder struct {
+	// unexported variables
+	options slog.HandlerOptions // encoder options
+	writer  binaryWriter        // writer for the serialization format
+}
+
+// NewMsgpackEncoder creates a new [xlog.Encoder] which encodes each record as a MessagePack map.
+//
+// Records are self-delimiting and are written one after the other with no separator. Only the AddSource and
+// ReplaceAttr options are used by the encoder.
+//
+// The package has no network handlers (eg: TCP, Unix socket or Kafka), so the encoder is only supported for file
+// output using [FileHandlerMsgpackFormat] or the Encoder option of [FileHandlerOptions]. The SentinelOne HEC handler
+// sends JSON events and should not be given a binary encoder.
+//
+// References:
+//
+//	https://github.com/msgpack/msgpack/blob/master/spec.md
+func NewMsgpackEncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &binaryEncoder{
+		writer: msgpackWriter{},
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// NewCBOREncoder creates a new [xlog.Encoder] which encodes each record as a CBOR map.
+//
+// Records are self-delimiting and are written one after the other with no separator. Only the AddSource and
+// ReplaceAttr options are used by the encoder.
+//
+// Like [NewMsgpackEncoder], the encoder is only supported for file output, using [FileHandlerCBORFormat].
+//
+// References:
+//
+//	https://www.rfc-editor.org/rfc/rfc8949
+func NewCBOREncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &binaryEncoder{
+		writer: cborWriter{},
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single binary map.
+func (e *binaryEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	all := builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr)
+	all = append(all, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...)
+	e.writeAttrs(buf, all)
+	return nil
+}
+
+// writeAttrs writes the given resolved attributes as a map.
+func (e *binaryEncoder) writeAttrs(buf *bytes.Buffer, attrs []slog.Attr) {
+	e.writer.writeMapHeader(buf, len(attrs))
+	for _, attr := range attrs {
+		e.writer.writeString(buf, attr.Key)
+		if attr.Value.Kind() == slog.KindGroup {
+			e.writeAttrs(buf, attr.Value.Group())
+			continue
+		}
+		e.writeValue(buf, attr.Value)
+	}
+}
+
+// writeValue writes the given resolved, non-group value.
+func (e *binaryEncoder) writeValue(buf *bytes.Buffer, v slog.Value) {
+	switch v.Kind() {
+	case slog.KindString:
+		e.writer.writeString(buf, v.String())
+	case slog.KindInt64:
+		e.writer.writeInt(buf, v.Int64())
+	case slog.KindUint64:
+		e.writer.writeUint(buf, v.Uint64())
+	case slog.KindFloat64:
+		e.writer.writeFloat(buf, v.Float64())
+	case slog.KindBool:
+		e.writer.writeBool(buf, v.Bool())
+	case slog.KindTime:
+		e.writer.writeTime(buf, v.Time())
+	case slog.KindDuration:
+		e.writer.writeInt(buf, int64(v.Duration()))
+	default:
+		switch a := v.Any().(type) {
+		case nil:
+			e.writer.writeNil(buf)
+		case *slog.Source:
+			e.writeAttrs(buf, []slog.Attr{
+				slog.String("function", a.Function),
+				slog.String("file", a.File),
+				slog.Int("line", a.Line),
+			})
+		case slog.Level:
+			e.writer.writeString(buf, a.String())
+		case []byte:
+			e.writer.writeBytes(buf, a)
+		case json.Marshaler:
+			e.writeJSON(buf, v)
+		case error:
+			e.writer.writeString(buf, a.Error())
+		default:
+			e.writeJSON(buf, v)
+		}
+	}
+}
+
+// writeJSON writes the given value by converting it to JSON and writing the decoded JSON value.
+func (e *binaryEncoder) writeJSON(buf *bytes.Buffer, v slog.Value) {
+	var decoded any
+	d := json.NewDecoder(bytes.NewReader(marshalJSONValue(v)))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		e.writer.writeString(buf, "!ERROR:"+err.Error())
+		return
+	}
+	e.writeGeneric(buf, decoded)
+}
+
+// writeGeneric writes a value decoded from JSON, writing object keys in sorted order.
+func (e *binaryEncoder) writeGeneric(buf *bytes.Buffer, v any) {
+	switch val := v.(type) {
+	case nil:
+		e.writer.writeNil(buf)
+	case bool:
+		e.writer.writeBool(buf, val)
+	case string:
+		e.writer.writeString(buf, val)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			e.writer.writeInt(buf, i)
+		} else if f, err := val.Float64(); err == nil {
+			e.writer.writeFloat(buf, f)
+		} else {
+			e.writer.writeString(buf, val.String())
+		}
+	case []any:
+		e.writer.writeArrayHeader(buf, len(val))
+		for _, item := range val {
+			e.writeGeneric(buf, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		e.writer.writeMapHeader(buf, len(keys))
+		for _, k := range keys {
+			e.writer.writeString(buf, k)
+			e.writeGeneric(buf, val[k])
+		}
+	}
+}
+
+// msgpackWriter writes values using the MessagePack format.
+type msgpackWriter struct{}
+
+// writeArrayHeader writes the header for an array with n elements.
+func (msgpackWriter) writeArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xdc)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xdd)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// writeBool writes a boolean value.
+func (msgpackWriter) writeBool(buf *bytes.Buffer, b bool) {
+	if b {
+		buf.WriteByte(0xc3)
+	} else {
+		buf.WriteByte(0xc2)
+	}
+}
+
+// writeBytes writes a binary value.
+func (msgpackWriter) writeBytes(buf *bytes.Buffer, b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xc6)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	buf.Write(b)
+}
+
+// writeFloat writes a 64-bit floating point value.
+func (msgpackWriter) writeFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xcb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// writeInt writes a signed integer value using the smallest possible encoding.
+func (w msgpackWriter) writeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		w.writeUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(int16(i))))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+// writeMapHeader writes the header for a map with n key/value pairs.
+func (msgpackWriter) writeMapHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xde)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xdf)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// writeNil writes a nil value.
+func (msgpackWriter) writeNil(buf *bytes.Buffer) {
+	buf.WriteByte(0xc0)
+}
+
+// writeString writes a string value.
+func (msgpackWriter) writeString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xdb)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	buf.WriteString(s)
+}
+
+// writeTime writes a time value using the timestamp extension type in its 96-bit form.
+func (msgpackWriter) writeTime(buf *bytes.Buffer, t time.Time) {
+	buf.Write([]byte{0xc7, 12, 0xff})
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Nanosecond())))
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(t.Unix())))
+}
+
+// writeUint writes an unsigned integer value using the smallest possible encoding.
+func (msgpackWriter) writeUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u < 128:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
+
+// cborWriter writes values using the CBOR format.
+type cborWriter struct{}
+
+// writeHead writes the initial byte(s) of a data item with the given major type and argument.
+func (cborWriter) writeHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+// writeArrayHeader writes the header for an array with n elements.
+func (w cborWriter) writeArrayHeader(buf *bytes.Buffer, n int) {
+	w.writeHead(buf, 4, uint64(n))
+}
+
+// writeBool writes a boolean value.
+func (cborWriter) writeBool(buf *bytes.Buffer, b bool) {
+	if b {
+		buf.WriteByte(0xf5)
+	} else {
+		buf.WriteByte(0xf4)
+	}
+}
+
+// writeBytes writes a byte string value.
+func (w cborWriter) writeBytes(buf *bytes.Buffer, b []byte) {
+	w.writeHead(buf, 2, uint64(len(b)))
+	buf.Write(b)
+}
+
+// writeFloat writes a 64-bit floating point value.
+func (cborWriter) writeFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xfb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// writeInt writes a signed integer value using the smallest possible encoding.
+func (w cborWriter) writeInt(buf *bytes.Buffer, i int64) {
+	if i >= 0 {
+		w.writeHead(buf, 0, uint64(i))
+	} else {
+		w.writeHead(buf, 1, uint64(-(i + 1)))
+	}
+}
+
+// writeMapHeader writes the header for a map with n key/value pairs.
+func (w cborWriter) writeMapHeader(buf *bytes.Buffer, n int) {
+	w.writeHead(buf, 5, uint64(n))
+}
+
+// writeNil writes a null value.
+func (cborWriter) writeNil(buf *bytes.Buffer) {
+	buf.WriteByte(0xf6)
+}
+
+// writeString writes a text string value.
+func (w cborWriter) writeString(buf *bytes.Buffer, s string) {
+	w.writeHead(buf, 3, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// writeTime writes a time value as an RFC 3339 date/time string (tag 0).
+func (w cborWriter) writeTime(buf *bytes.Buffer, t time.Time) {
+	w.writeHead(buf, 6, 0)
+	w.writeString(buf, t.Format(time.RFC3339Nano))
+}
+
+// writeUint writes an unsigned integer value using the smallest possible encoding.
+func (w cborWriter) writeUint(buf *bytes.Buffer, u uint64) {
+	w.writeHead(buf, 0, u)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+// TestBinaryEncoders checks that the binary encoders write records as maps holding the built-in and record attributes.
+func TestBinaryEncoders(t *testing.T) {
+	options := &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}
+	tests := []struct {
+		name    string
+		encoder xlog.Encoder
+		want    []byte
+	}{
+		{
+			name:    "cbor",
+			encoder: NewCBOREncoder(options),
+			want: []byte("\xa4" + "\x65level\x64INFO" + "\x63msg\x61m" + "\x61n\x18\x2a" +
+				"\x61g\xa1\x61b\xf5"),
+		},
+		{
+			name:    "msgpack",
+			encoder: NewMsgpackEncoder(options),
+			want: []byte("\x84" + "\xa5level\xa4INFO" + "\xa3msg\xa1m" + "\xa1n\x2a" +
+				"\xa1g\x81\xa1b\xc3"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := slog.NewRecord(time.Now(), slog.LevelInfo, "m", 0)
+			r.AddAttrs(slog.Int("n", 42), slog.Group("g", slog.Bool("b", true)))
+
+			var buf bytes.Buffer
+			if err := tt.encoder.EncodeRecord(&buf, r, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("EncodeRecord() = %x, want %x", buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
@@ -22,6 +22,12 @@ import (
 func NewBuilderFromConfig(handlerType string, options map[string]any) (xlog.HandlerBuilder, xerrors.Error) {
 	handlerType = strings.TrimSpace(strings.ToLower(handlerType))
 
+	// resolve any alias to its handler type and default options
+	if alias, ok := _aliases[handlerType]; ok {
+		handlerType = alias.handlerType
+		options = mergeOptions(alias.defaults, options)
+	}
+
 	// marshal the options to JSON
 	jsonOptions, err := json.Marshal(options)
 	if err != nil {
@@ -43,15 +49,93 @@ func NewBuilderFromConfig(handlerType string, options map[string]any) (xlog.Hand
 		})
 }
 
+// NewManagedLoggerFromConfig parses and validates the given handler type and its options, builds the handler tree
+// and returns a new [xlog.ManagedLogger] which owns it.
+//
+// To replace the tree later (eg: when the configuration file changes), create a new builder using
+// [NewBuilderFromConfig] and pass it to [xlog.ManagedLogger.Reconfigure].
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: error while unmarshaling options to JSON
+//   - [xlog.UnsupportedHandlerType]: unknown or unsupported handler type was encountered
+//
+// In addition, the function may return any error returned while creating the builder or building the handler.
+func NewManagedLoggerFromConfig(handlerType string, options map[string]any, cb xlog.BuildHandlerCallbackFn) (
+	*xlog.ManagedLogger, xerrors.Error) {
+	b, xerr := NewBuilderFromConfig(handlerType, options)
+	if xerr != nil {
+		return nil, xerr
+	}
+	return xlog.NewManagedLogger(b, cb)
+}
+
+// RegisterAlias attempts to register an alias for the given handler type so that configuration files can refer to
+// the handler using a shorter or organization-specific name (eg: "stdout" for a console handler).
+//
+// Any default options given are used as the starting point for the options of every handler created using the alias.
+// Options from the configuration are merged on top of the defaults, with nested objects merged recursively, so the
+// configuration always wins. The handler type itself is resolved when the handler is created, so it does not need to
+// be registered before the alias, but it cannot be another alias.
+//
+// To overwrite an existing alias or a builder registered with the same name, set overwrite to true.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: an invalid parameter was passed to the function (eg: alias or handler type was empty,
+//     the handler type is an alias or another alias refers to the alias as its handler type)
+//   - [xlog.HandlerTypeExists]: an alias or builder with the given name already exists
+func RegisterAlias(alias, handlerType string, defaults map[string]any, overwrite bool) xerrors.Error {
+	alias = strings.TrimSpace(strings.ToLower(alias))
+	handlerType = strings.TrimSpace(strings.ToLower(handlerType))
+	if alias == "" {
+		return xerrors.New(xlog.InvalidParameter, "alias cannot be empty")
+	}
+	if handlerType == "" {
+		return xerrors.New(xlog.InvalidParameter, "handler type cannot be empty")
+	}
+	if _, ok := _aliases[handlerType]; ok || alias == handlerType {
+		return xerrors.Newf(xlog.InvalidParameter, "%s: handler type cannot be an alias", handlerType).
+			WithAttrs(map[string]any{
+				"alias": alias,
+				"type":  handlerType,
+			})
+	}
+	for name, a := range _aliases {
+		if a.handlerType == alias {
+			return xerrors.Newf(xlog.InvalidParameter, "%s: alias is the handler type for alias '%s'", alias, name).
+				WithAttrs(map[string]any{
+					"alias": alias,
+					"type":  handlerType,
+				})
+		}
+	}
+	if !overwrite {
+		if _, ok := _aliases[alias]; ok {
+			return xerrors.Newf(xlog.HandlerTypeExists, "%s: alias is already registered", alias).
+				WithAttr("alias", alias)
+		}
+		if _, ok := _builders[alias]; ok {
+			return xerrors.Newf(xlog.HandlerTypeExists, "%s: handler type is already registered", alias).
+				WithAttr("type", alias)
+		}
+	}
+	delete(_builders, alias)
+	_aliases[alias] = handlerAlias{
+		defaults:    mergeOptions(defaults, nil),
+		handlerType: handlerType,
+	}
+	return nil
+}
+
 // RegisterBuilder attempts to register an [xlog.NewBuilderFromConfigFn] for creating a handler builder with the given
 // handler type.
 //
-// To overwrite the function attached to a particular handler type, set overwrite to true.
+// To overwrite the function attached to a particular handler type or an alias registered with the same name, set
+// overwrite to true.
 //
 // This function may return an error with any of the following codes:
 //   - [xlog.InvalidParameter]: an invalid parameter was passed to the function (eg: handler was empty or factory
 //     function was nil)
-//   - [xlog.HandlerTypeExists]: a builder for the given handler type already exists
+//   - [xlog.HandlerTypeExists]: a builder or alias for the given handler type already exists
 func RegisterBuilder(handlerType string, factoryFn xlog.NewBuilderFromConfigFn, overwrite bool) xerrors.Error {
 	handlerType = strings.TrimSpace(strings.ToLower(handlerType))
 	if handlerType == "" {
@@ -60,14 +144,47 @@ func RegisterBuilder(handlerType string, factoryFn xlog.NewBuilderFromConfigFn,
 	if factoryFn == nil {
 		return xerrors.New(xlog.InvalidParameter, "factory function cannot be nil")
 	}
-	if _, ok := _builders[handlerType]; ok && !overwrite {
-		return xerrors.Newf(xlog.HandlerTypeExists, "%s: handler type is already registered").
-			WithAttr("type", handlerType)
+	if !overwrite {
+		if _, ok := _builders[handlerType]; ok {
+			return xerrors.Newf(xlog.HandlerTypeExists, "%s: handler type is already registered", handlerType).
+				WithAttr("type", handlerType)
+		}
+		if _, ok := _aliases[handlerType]; ok {
+			return xerrors.Newf(xlog.HandlerTypeExists, "%s: alias is already registered", handlerType).
+				WithAttr("alias", handlerType)
+		}
 	}
+	delete(_aliases, handlerType)
 	_builders[handlerType] = factoryFn
 	return nil
 }
 
+// ValidateConfig parses and validates the given handler type and its options without actually building the handler.
+//
+// This is a "dry run" of [NewBuilderFromConfig] followed by Build: the options go through the same parsing, callback
+// and validation steps, but no files are created, no connections are opened and no data is sent. Child handlers of
+// handlers such as the fanout handler are validated as well. See [xlog.ValidateConfig] for more details.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: error while unmarshaling options to JSON
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//   - [xlog.UnsupportedHandlerType]: unknown or unsupported handler type was encountered
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func ValidateConfig(handlerType string, options map[string]any, cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	builder, err := NewBuilderFromConfig(handlerType, options)
+	if err != nil {
+		return err
+	}
+	return xlog.ValidateConfig(builder, cb)
+}
+
+// handlerAlias holds the details of an alias registered using [RegisterAlias].
+type handlerAlias struct {
+	defaults    map[string]any // default options for the handler
+	handlerType string         // actual type of the handler
+}
+
 // handlerBuilder is used to build a handler that contains child handlers.
 type handlerBuilder struct {
 	// HandlerType holds the type of the handler to build.
@@ -76,6 +193,9 @@ type handlerBuilder struct {
 	// HandlerOptions holds the options for the handler to build.
 	HandlerOptions map[string]any `json:"options"`
 
+	// Wrap holds the wrappers to apply to the handler once it is built, from outermost to innermost.
+	Wrap []WrapperConfig `json:"wrap,omitempty"`
+
 	// unexported variables
 	builder xlog.HandlerBuilder // the underlying builder to use to build the new handler
 }
@@ -85,7 +205,13 @@ type handlerBuilder struct {
 type jsonHandlerBuilder handlerBuilder
 
 // UnmarshalJSON decodes the JSON-encoded data into the current object.
+//
+// Any configurations named by the [ConfigExtendsKey] key are merged beneath the configuration first.
 func (h *handlerBuilder) UnmarshalJSON(data []byte) error {
+	data, xerr := resolveConfigExtends(data)
+	if xerr != nil {
+		return xerr
+	}
 	var b jsonHandlerBuilder
 	if err := json.Unmarshal(data, &b); err != nil {
 		return err
@@ -95,9 +221,39 @@ func (h *handlerBuilder) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+	builder, err = NewWrappedBuilder(builder, b.Wrap)
+	if err != nil {
+		return err
+	}
 	h.HandlerType = b.HandlerType
 	h.HandlerOptions = b.HandlerOptions
+	h.Wrap = b.Wrap
 	h.builder = builder
 
 	return nil
 }
+
+// mergeOptions returns a deep copy of the default options with the given options merged on top of them.
+//
+// Nested objects are merged recursively while all other values from options replace the default values. Neither map
+// is modified.
+func mergeOptions(defaults, options map[string]any) map[string]any {
+	if defaults == nil && options == nil {
+		return nil
+	}
+	merged := make(map[string]any, len(defaults)+len(options))
+	for k, v := range defaults {
+		if m, ok := v.(map[string]any); ok {
+			v = mergeOptions(m, nil)
+		}
+		merged[k] = v
+	}
+	for k, v := range options {
+		if m, ok := v.(map[string]any); ok {
+			base, _ := merged[k].(map[string]any)
+			v = mergeOptions(base, m)
+		}
+		merged[k] = v
+	}
+	return merged
+}
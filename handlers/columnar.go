@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log/slog"
+	"slices"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// columnarTimeFormat is the layout used to format times in CSV and TSV output.
+	columnarTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+var (
+	// DefaultColumnarColumns is the default list of columns written to each row of CSV and TSV formatted messages.
+	//
+	// This value is used when the columns in [ColumnarFormatOptions] are unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ColumnarFormatOptions
+	DefaultColumnarColumns = []string{slog.TimeKey, slog.LevelKey, slog.MessageKey}
+)
+
+// ColumnarFormatOptions holds the options used when writing messages in the CSV or TSV formats.
+type ColumnarFormatOptions struct {
+	// Columns holds the keys of the attributes written to each row, in order.
+	//
+	// The built-in attributes use the [slog.TimeKey], [slog.LevelKey], [slog.MessageKey] and [slog.SourceKey] keys
+	// (the caller is only available when it is included). Keys for attributes inside of groups are the group names
+	// and attribute key joined by a dot (eg: "http.method"). Columns whose attribute is missing from a record are left
+	// empty and attributes without a column are not written.
+	//
+	// The default behavior is defined by the default columns setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	Columns []string `json:"columns,omitempty"`
+
+	// Header indicates whether or not to write a header row holding the column names when the log file is empty
+	// when it is opened.
+	//
+	// Files created when the log file is rotated do not get a header row.
+	//
+	// The default behavior is to not write a header row.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Header bool `json:"header"`
+}
+
+// clone returns a copy of the options which shares no slices with the original.
+func (o ColumnarFormatOptions) clone() ColumnarFormatOptions {
+	o.Columns = slices.Clone(o.Columns)
+	return o
+}
+
+// withDefaults returns a copy of the options with any unset values replaced by the package defaults.
+func (o ColumnarFormatOptions) withDefaults() ColumnarFormatOptions {
+	if len(o.Columns) == 0 {
+		o.Columns = slices.Clone(DefaultColumnarColumns)
+	}
+	return o
+}
+
+// columnarEncoder encodes records as rows of delimiter-separated values with a fixed list of columns.
+//
+// Fields are quoted whenever they contain the delimiter, quotes, line breaks or leading spaces and quotes inside of
+// quoted fields are doubled, following RFC 4180.
+//
+// References:
+//
+//	https://www.rfc-editor.org/rfc/rfc4180
+type columnarEncoder struct {
+	// unexported variables
+	columnOpts ColumnarFormatOptions // column-specific options
+	comma      rune                  // field delimiter
+	options    slog.HandlerOptions   // encoder options
+}
+
+// NewCSVEncoder creates a new [xlog.Encoder] which encodes records as rows of comma-separated values.
+//
+// Only the AddSource and ReplaceAttr options are used by the encoder. The header row, if desired, is written by the
+// file handler rather than the encoder.
+//
+// References:
+//
+//	https://www.rfc-editor.org/rfc/rfc4180
+func NewCSVEncoder(options *slog.HandlerOptions, columnOptions ColumnarFormatOptions) xlog.Encoder {
+	return newColumnarEncoder(options, columnOptions, ',')
+}
+
+// NewTSVEncoder creates a new [xlog.Encoder] which encodes records as rows of tab-separated values.
+//
+// Fields are escaped the same way as [NewCSVEncoder], using tabs rather than commas as the delimiter. Only the
+// AddSource and ReplaceAttr options are used by the encoder.
+func NewTSVEncoder(options *slog.HandlerOptions, columnOptions ColumnarFormatOptions) xlog.Encoder {
+	return newColumnarEncoder(options, columnOptions, '\t')
+}
+
+// newColumnarEncoder creates a new [columnarEncoder] object.
+func newColumnarEncoder(options *slog.HandlerOptions, columnOptions ColumnarFormatOptions,
+	comma rune) *columnarEncoder {
+
+	e := &columnarEncoder{
+		columnOpts: columnOptions.clone().withDefaults(),
+		comma:      comma,
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single row.
+func (e *columnarEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	all := builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr)
+	all = append(all, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...)
+	values := make(map[string]string, len(all))
+	for _, attr := range xlog.FlattenAttrs(all, ".") {
+		values[attr.Key] = formatTextValue(attr.Value, columnarTimeFormat)
+	}
+
+	row := make([]string, len(e.columnOpts.Columns))
+	for i, column := range e.columnOpts.Columns {
+		row[i] = values[column]
+	}
+	return e.writeRow(buf, row)
+}
+
+// writeHeader writes the header row holding the column names to the buffer, if a header row is desired.
+func (e *columnarEncoder) writeHeader(buf *bytes.Buffer) error {
+	if !e.columnOpts.Header {
+		return nil
+	}
+	return e.writeRow(buf, e.columnOpts.Columns)
+}
+
+// writeRow writes the given fields to the buffer as a single row followed by a newline.
+func (e *columnarEncoder) writeRow(buf *bytes.Buffer, row []string) error {
+	w := csv.NewWriter(buf)
+	w.Comma = e.comma
+	if err := w.Write(row); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
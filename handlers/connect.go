@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// ConnectEager connects a network handler to its sink when the handler is created, failing fast if the sink
+	// cannot be reached.
+	ConnectEager ConnectMode = "eager"
+
+	// ConnectLazy connects a network handler to its sink when the first record is sent, so that the handler can be
+	// created before the sink is available.
+	ConnectLazy ConnectMode = "lazy"
+)
+
+var (
+	// DefaultConnectMode is the default mode used by network handlers to connect to their sink.
+	//
+	// This value is used when the connect mode in a network handler's options is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
+	DefaultConnectMode = ConnectLazy
+
+	// DefaultReconnectInterval is the default interval at which a network handler which lost its connection to its
+	// sink tries to reconnect in the background.
+	//
+	// This value is used when the reconnect interval in a network handler's options is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
+	DefaultReconnectInterval = types.Duration(5 * time.Second)
+)
+
+// ConnectMode defines when a network handler connects to its sink.
+type ConnectMode string
+
+// IsValid returns whether or not the mode is one of the modes defined in the package or empty.
+func (m ConnectMode) IsValid() bool {
+	switch m {
+	case ConnectEager, ConnectLazy, "":
+		return true
+	}
+	return false
+}
+
+// connectionMonitor tracks whether a network handler is able to reach its sink and, once the sink cannot be
+// reached, tries to reconnect in the background until it can be reached again.
+type connectionMonitor struct {
+	// unexported variables
+	closed   bool                        // whether or not the monitor has been closed
+	connect  func(context.Context) error // function which checks that the sink can be reached
+	done     chan struct{}               // closed when the monitor is closed
+	err      error                       // most recent connection error or nil if the sink can be reached
+	interval time.Duration               // interval between reconnect attempts
+	loop     sync.WaitGroup              // running reconnect loop
+	mu       sync.Mutex                  // protects closed, err and retrying
+	retrying bool                        // whether or not the reconnect loop is running
+}
+
+// newConnectionMonitor returns a new monitor which uses the given function to check that the sink can be reached,
+// retrying at the given interval once the sink cannot be reached.
+func newConnectionMonitor(connect func(context.Context) error, interval time.Duration) *connectionMonitor {
+	if interval <= 0 {
+		interval = time.Duration(DefaultReconnectInterval)
+	}
+	return &connectionMonitor{
+		connect:  connect,
+		done:     make(chan struct{}),
+		interval: interval,
+	}
+}
+
+// check tries to reach the sink, records the result and returns any error.
+func (m *connectionMonitor) check(ctx context.Context) error {
+	err := m.connect(ctx)
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+	return err
+}
+
+// close stops the reconnect loop, if it is running, and waits for it to exit.
+func (m *connectionMonitor) close() {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	m.mu.Unlock()
+	m.loop.Wait()
+}
+
+// failed records that the sink could not be reached because of the given error and starts the reconnect loop if it
+// is not already running.
+func (m *connectionMonitor) failed(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+	if m.closed || m.retrying {
+		return
+	}
+	m.retrying = true
+	xlog.Trace(context.Background(), xlog.TraceEventRetryScheduled, "reconnect scheduled",
+		slog.Duration("interval", m.interval), slog.String("error", err.Error()))
+	m.loop.Go(m.reconnect)
+}
+
+// reconnect tries to reach the sink at the monitor's interval until it can be reached or the monitor is closed.
+func (m *connectionMonitor) reconnect() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			m.mu.Lock()
+			m.retrying = false
+			m.mu.Unlock()
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+			err := m.connect(ctx)
+			cancel()
+
+			m.mu.Lock()
+			if m.err == nil || err == nil {
+				// a record was delivered in the meantime or the sink can be reached again
+				m.err = nil
+				m.retrying = false
+				m.mu.Unlock()
+				return
+			}
+			m.err = err
+			m.mu.Unlock()
+			xlog.Trace(context.Background(), xlog.TraceEventRetryScheduled, "reconnect scheduled",
+				slog.Duration("interval", m.interval), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// status returns the most recent connection error or nil if the sink could be reached the last time it was tried or
+// has not been tried yet.
+func (m *connectionMonitor) status() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// succeeded records that the sink was reached.
+func (m *connectionMonitor) succeeded() {
+	m.mu.Lock()
+	m.err = nil
+	m.mu.Unlock()
+}
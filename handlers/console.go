@@ -4,9 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"go.innotegrity.dev/xlog"
 
@@ -17,12 +23,49 @@ import (
 )
 
 const (
+	// ConsoleHandlerAlwaysColor always colorizes output, even when the output is not a terminal.
+	ConsoleHandlerAlwaysColor ConsoleHandlerColor = "always"
+
+	// ConsoleHandlerAutoColor colorizes output based on the NO_COLOR and FORCE_COLOR environment variables and
+	// whether or not the output is a terminal.
+	//
+	// If NO_COLOR is set to a non-empty value, output is not colorized. Otherwise, if FORCE_COLOR is set to a
+	// non-empty value other than "0" or "false", output is colorized. If neither variable is set, output is only
+	// colorized when it is written to a terminal.
+	//
+	// References:
+	//   https://no-color.org
+	//   https://force-color.org
+	ConsoleHandlerAutoColor ConsoleHandlerColor = "auto"
+
+	// ConsoleHandlerNeverColor never colorizes output.
+	ConsoleHandlerNeverColor ConsoleHandlerColor = "never"
+)
+
+const (
+	// ConsoleHandlerECSFormat outputs messages in JSON format using Elastic Common Schema (ECS) field names.
+	//
+	// The time, level, message and caller are written to the "@timestamp", "log.level", "message" and "log.origin"
+	// fields and all other attributes are written to "labels".
+	//
+	// References:
+	//   https://www.elastic.co/guide/en/ecs/current/index.html
+	ConsoleHandlerECSFormat ConsoleHandlerFormat = "ecs"
+
 	// ConsoleHandlerJSONFormat outputs messages in JSON format using [slog.JSONHandler].
 	//
 	// References:
 	//   https://pkg.go.dev/log/slog#JSONHandler
 	ConsoleHandlerJSONFormat ConsoleHandlerFormat = "json"
 
+	// ConsoleHandlerLogfmtFormat outputs messages as key=value pairs in logfmt format.
+	//
+	// Nested groups are written using dotted keys (eg: "group.key=value").
+	//
+	// References:
+	//   https://brandur.org/logfmt
+	ConsoleHandlerLogfmtFormat ConsoleHandlerFormat = "logfmt"
+
 	// ConsoleHandlerPlaintextFormat outputs messages in plaintext format using [slog.TextHandler].
 	//
 	// References:
@@ -34,6 +77,10 @@ const (
 	// References:
 	//   https://pkg.go.dev/github.com/lmittmann/tint#NewHandler
 	ConsoleHandlerPrettyFormat ConsoleHandlerFormat = "pretty"
+
+	// ConsoleHandlerPrettyJSONFormat outputs messages in JSON format with each message indented across multiple
+	// lines, which is easier to read during local development.
+	ConsoleHandlerPrettyJSONFormat ConsoleHandlerFormat = "json-pretty"
 )
 
 const (
@@ -45,6 +92,17 @@ const (
 )
 
 var (
+	// DefaultConsoleHandlerColor is the default color mode to use for the handler when the output format is
+	// [ConsoleHandlerPrettyFormat].
+	//
+	// This value is used when the color in [ConsoleHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ConsoleHandlerOptions
+	DefaultConsoleHandlerColor = ConsoleHandlerAutoColor
+
 	// DefaultConsoleHandlerLogLevel is the default log level to use when one is not provided.
 	//
 	// This value is used when the level in [ConsoleHandlerOptions] is unset.
@@ -64,27 +122,144 @@ var (
 	// References:
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ConsoleHandlerOptions
 	DefaultConsoleHandlerFormat = ConsoleHandlerPrettyFormat
+
+	// DefaultConsoleHandlerTimeFormat is the default time format to use for the handler when the output format is
+	// [ConsoleHandlerPrettyFormat].
+	//
+	// This value is used when the time format in [ConsoleHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ConsoleHandlerOptions
+	DefaultConsoleHandlerTimeFormat = time.DateTime
 )
 
+// consoleHandlerTimeFormats maps the names of well-known time formats to their layouts.
+var consoleHandlerTimeFormats = map[string]string{
+	"datetime":    time.DateTime,
+	"dateonly":    time.DateOnly,
+	"kitchen":     time.Kitchen,
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"stamp":       time.Stamp,
+	"stampmicro":  time.StampMicro,
+	"stampmilli":  time.StampMilli,
+	"stampnano":   time.StampNano,
+	"timeonly":    time.TimeOnly,
+}
+
+// ConsoleHandlerColor controls whether or not output written to the console is colorized.
+type ConsoleHandlerColor string
+
 // ConsoleHandlerFormat is a pre-defined output format for the console.
 type ConsoleHandlerFormat string
 
+// ConsoleWriteHook coordinates the messages written by a [ConsoleHandler] with other output written to the same
+// terminal, such as a progress bar or spinner drawn by a terminal UI library.
+//
+// The handler calls BeforeWrite immediately before each message is written and AfterWrite immediately after, passing
+// the writer to which the message is written in both cases. Writes to stdout and stderr are serialized by the handler,
+// so calls are never nested or interleaved. Since the handler does not know when the terminal UI redraws itself, the
+// hook should acquire whatever lock guards the UI's own output in BeforeWrite and release it in AfterWrite.
+type ConsoleWriteHook interface {
+	// AfterWrite is called after a message has been written (eg: to redraw the progress bar below the message).
+	AfterWrite(w io.Writer)
+
+	// BeforeWrite is called before a message is written (eg: to clear the line holding the progress bar).
+	BeforeWrite(w io.Writer)
+}
+
 // ConsoleHandlerOptions holds the options for a [ConsoleHandler].
 type ConsoleHandlerOptions struct {
-	// ErrorHandler is a function that's called to process any internal errors that may occur when a message is
-	// processed by the underlying handler.
+	// CommonHandlerOptions holds the level, caller, error handler and attribute replacement options shared with
+	// other handlers.
+	xlog.CommonHandlerOptions
+
+	// Clock is the source of the current time used to set the time of records according to the TimestampPolicy.
 	//
-	// The default behavior is to ignore these errors.
+	// The default behavior is defined by the default clock defined in the xlog package.
 	//
 	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
 	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
 	// set this value from your application, if desired.
 	//
 	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Clock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultClock
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ErrorHandler xlog.ErrorHandlerFn `json:"-"`
+	Clock xlog.Clock `json:"-"`
+
+	// Color controls whether or not output is colorized when the output format is [ConsoleHandlerPrettyFormat].
+	//
+	// The default behavior is defined by the default color setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	Color ConsoleHandlerColor `json:"color"`
+
+	// DeduplicateKeys indicates whether or not to remove duplicate attribute keys from log messages.
+	//
+	// When enabled, attributes added to the handler and attributes in each record are combined and only the last
+	// value for each key within the same group is logged. See [xlog.NewDedupHandler] for details.
+	//
+	// The default behavior is to log every attribute, even if its key is repeated.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewDedupHandler
+	DeduplicateKeys bool `json:"deduplicate_keys"`
+
+	// Encoder is a custom encoder used to format messages.
+	//
+	// When set, messages are formatted using this encoder and all of the formatting options (eg: Format, Color,
+	// ShortCaller and TimeFormat) are ignored. The encoder's own options control whether or not the caller is
+	// included and how attributes are replaced.
+	//
+	// The default behavior is to format messages using the configured format.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Encoder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	Encoder xlog.Encoder `json:"-"`
+
+	// ExpandErrors indicates whether or not to expand attributes holding an [xerrors.Error] into a group holding its
+	// code, message, attributes, stack trace and wrapped errors using [ExpandErrorAttr].
+	//
+	// This value only applies to the ECS, JSON and pretty JSON formats. The attribute is expanded after ReplaceAttr is
+	// called.
+	//
+	// The default behavior is to log the string returned by the error's Error function.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	ExpandErrors bool `json:"expand_errors"`
+
+	// FlattenGroups indicates whether or not to flatten nested groups into attributes with dotted keys (eg:
+	// "request.headers.host") for sinks which do not handle nested JSON objects well.
+	//
+	// Groups are flattened before the record is formatted, so ReplaceAttr sees the flattened keys. The non-JSON
+	// formats already write groups as dotted keys, so this value mostly affects the ECS, JSON and pretty JSON formats.
+	//
+	// The default behavior is to write groups as nested objects.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultFlattenSeparator
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFlattenHandler
+	FlattenGroups bool `json:"flatten_groups"`
 
 	// Format stores the output format for the handler.
 	//
@@ -94,71 +269,182 @@ type ConsoleHandlerOptions struct {
 	// to an empty string.
 	Format ConsoleHandlerFormat `json:"format"`
 
-	// IncludeCaller indicates whether or not to include the caller in log messages.
+	// Humanize indicates whether or not to render durations, sizes and byte counts in human-readable units
+	// (eg: "350ms" or "1.2 MiB") using [HumanizeAttr].
 	//
-	// The default behavior is to not include caller information.
+	// This value only applies to the logfmt, plaintext and pretty formats, so the JSON-based formats always hold the
+	// raw numeric values. The attribute is humanized after ReplaceAttr is called.
+	//
+	// The default behavior is to display raw values.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Humanize bool `json:"humanize"`
+
+	// MaxAttrLength is the maximum number of characters to display for the value of each attribute.
+	//
+	// Longer values are converted to strings, if necessary, and truncated with an ellipsis. Numeric, boolean, time
+	// and duration values are never truncated, nor is the caller information, if it is included.
+	//
+	// The default behavior is to not limit the length of attribute values.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	MaxAttrLength int `json:"max_attr_length,omitempty"`
+
+	// MaxMessageLength is the maximum number of characters to display for each message.
+	//
+	// Longer messages are truncated with an ellipsis.
+	//
+	// The default behavior is to not limit the length of messages.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	MaxMessageLength int `json:"max_message_length,omitempty"`
+
+	// Sanitize indicates whether or not to fix attribute values which would otherwise produce invalid output (eg:
+	// invalid UTF-8, NaN floats or cyclic structures) using [SanitizeAttr].
+	//
+	// Each fix is reported to the ErrorHandler, if one is set, as an [xlog.MarshalError] with a nil record. Values are
+	// sanitized after ReplaceAttr is called.
+	//
+	// The default behavior is to write values as they are.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
 	// to false.
-	IncludeCaller bool `json:"include_caller"`
+	Sanitize bool `json:"sanitize"`
 
-	// Level is the minimum level at which to log messages.
+	// SensitiveKeys holds additional patterns, as accepted by [path.Match], of the attribute keys whose values are
+	// masked by the handler, in addition to the default sensitive keys defined in the package.
+	//
+	// Patterns are matched against the lowercase key of each attribute, without the keys of its groups. Values are
+	// masked after ReplaceAttr is called.
 	//
-	// The default behavior is defined by the default level setting defined in the package.
+	// The default behavior is to only mask the default sensitive keys.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
 	// to nil.
-	Level *slog.LevelVar `json:"level"`
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultSensitiveKeys
+	SensitiveKeys []string `json:"sensitive_keys,omitempty"`
 
-	// MaxLevel is the maximum level at which to log messages.
+	// ShortCaller indicates whether or not to shorten the caller's file path to just the name of its parent directory
+	// and the file itself (eg: "handlers/console.go:42") when caller information is included.
 	//
-	// The default behavior is to disable any maximum log message level.
+	// The default behavior is to display the full path to the file.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	ShortCaller bool `json:"short_caller"`
+
+	// Stderr is a flag to send messages for this handler to stderr instead of stdout.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Stderr bool `json:"stderr"`
+
+	// StderrLevel is the minimum level at which messages are sent to stderr instead of stdout.
+	//
+	// When set, messages at or above this level are written to stderr and all other messages are written to stdout,
+	// regardless of the value of Stderr.
+	//
+	// The default behavior is to write all messages to the same output.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
 	// to nil.
-	MaxLevel *slog.LevelVar `json:"max_level,omitempty"`
+	StderrLevel *slog.LevelVar `json:"stderr_level,omitempty"`
 
-	// ReplaceAttr is called to rewrite each non-group attribute before it is logged.
+	// TimeFormat is the layout used to format the time of each message when the output format is
+	// [ConsoleHandlerPrettyFormat].
 	//
-	// The attribute's value has been resolved (see [slog.Value.Resolve]). If ReplaceAttr returns a zero Attr, the
-	// attribute is discarded.
+	// The value may either be a layout as accepted by [time.Time.Format] (eg: "2006-01-02 15:04:05.000") or the
+	// case-insensitive name of one of the following well-known layouts: datetime, dateonly, kitchen, rfc3339,
+	// rfc3339nano, stamp, stampmicro, stampmilli, stampnano or timeonly.
 	//
-	// The built-in attributes with keys [slog.TimeKey], [slog.LevelKey], [slog.SourceKey], and [slog.MessageKey]
-	// are passed to this function, except that time is omitted if zero, and source is omitted if IncludeCaller is
-	// false.
+	// The default behavior is defined by the default time format setting defined in the package.
 	//
-	// The default behavior is to not replace any attributes.
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/time#pkg-constants
+	TimeFormat string `json:"time_format"`
+
+	// TimestampPolicy defines how the time of each record is treated (eg: whether records with a zero time are given
+	// the current time from the Clock, dropped or logged without a time).
+	//
+	// The default behavior is defined by the default timestamp policy defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultTimestampPolicy
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimestampPolicy
+	TimestampPolicy xlog.TimestampPolicy `json:"timestamp_policy,omitempty"`
+
+	// UTC indicates whether or not to convert the time of each message to UTC before it is written.
+	//
+	// The default behavior is to write the time in the local time zone.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	UTC bool `json:"utc"`
+
+	// Writer is the writer to which messages are written instead of stdout or stderr.
+	//
+	// When set, all messages are written to this writer and the Stderr and StderrLevel options are ignored. Writes
+	// to the writer are serialized by the handler. When the color mode is [ConsoleHandlerAutoColor], output is only
+	// colorized if the writer is an [os.File] connected to a terminal or if color is forced by the environment.
+	//
+	// The default behavior is to write messages to stdout or stderr.
 	//
 	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
 	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
 	// set this value from your application, if desired.
 	//
 	// References:
-	//   https://pkg.go.dev/log/slog#TimeKey
-	//   https://pkg.go.dev/log/slog#LevelKey
-	//   https://pkg.go.dev/log/slog#SourceKey
-	//   https://pkg.go.dev/log/slog#MessageKey
-	//   https://pkg.go.dev/log/slog#HandlerOptions
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr `json:"-"`
+	Writer io.Writer `json:"-"`
 
-	// Stderr is a flag to send messages for this handler to stderr instead of stdout.
+	// WriteHook is called around each message that is written so that the output can be coordinated with a terminal
+	// UI, such as a progress bar, which shares the terminal.
 	//
-	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to false.
-	Stderr bool `json:"stderr"`
+	// The default behavior is to write messages without calling any hook.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	WriteHook ConsoleWriteHook `json:"-"`
 }
 
 // jsonConsoleHandlerOptions is an alternate form of [ConsoleHandlerOptions] that is used during unmarshalling to
 // prevent infinite recursion.
 type jsonConsoleHandlerOptions struct {
-	Format        string `json:"format"`
-	IncludeCaller bool   `json:"include_caller"`
-	Level         string `json:"level"`
-	MaxLevel      string `json:"max_level"`
-	Stderr        bool   `json:"stderr"`
+	Color            string   `json:"color"`
+	DeduplicateKeys  bool     `json:"deduplicate_keys"`
+	ExpandErrors     bool     `json:"expand_errors"`
+	FlattenGroups    bool     `json:"flatten_groups"`
+	Format           string   `json:"format"`
+	Humanize         bool     `json:"humanize"`
+	MaxAttrLength    int      `json:"max_attr_length"`
+	MaxMessageLength int      `json:"max_message_length"`
+	Sanitize         bool     `json:"sanitize"`
+	SensitiveKeys    []string `json:"sensitive_keys"`
+	ShortCaller      bool     `json:"short_caller"`
+	Stderr           bool     `json:"stderr"`
+	StderrLevel      string   `json:"stderr_level"`
+	TimeFormat       string   `json:"time_format"`
+	TimestampPolicy  string   `json:"timestamp_policy"`
+	UTC              bool     `json:"utc"`
 }
 
 // UnmarshalJSON decodes the JSON-encoded data into the current object.
@@ -167,6 +453,9 @@ func (o *ConsoleHandlerOptions) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &opts); err != nil {
 		return err
 	}
+	if err := o.CommonHandlerOptions.UnmarshalJSON(data); err != nil {
+		return err
+	}
 
 	// validate the format
 	//
@@ -174,49 +463,118 @@ func (o *ConsoleHandlerOptions) UnmarshalJSON(data []byte) error {
 	// is created or overridden by the calling application
 	format := ConsoleHandlerFormat(strings.TrimSpace(strings.ToLower(opts.Format)))
 	switch format {
-	case ConsoleHandlerJSONFormat, ConsoleHandlerPlaintextFormat, ConsoleHandlerPrettyFormat, "":
+	case ConsoleHandlerECSFormat, ConsoleHandlerJSONFormat, ConsoleHandlerLogfmtFormat, ConsoleHandlerPlaintextFormat,
+		ConsoleHandlerPrettyFormat, ConsoleHandlerPrettyJSONFormat, "":
 		o.Format = format
 	default:
 		return fmt.Errorf("%s: invalid format for console handler", opts.Format)
 	}
 
-	// validate the log level(s)
+	// validate the color mode
+	//
+	// note that we purposely leave the color empty here if it's not set so that it can be set when the handler
+	// is created or overridden by the calling application
+	color := ConsoleHandlerColor(strings.TrimSpace(strings.ToLower(opts.Color)))
+	switch color {
+	case ConsoleHandlerAlwaysColor, ConsoleHandlerAutoColor, ConsoleHandlerNeverColor, "":
+		o.Color = color
+	default:
+		return fmt.Errorf("%s: invalid color for console handler", opts.Color)
+	}
+
+	// validate the stderr level
 	//
 	// note that we purposely leave the level nil here if it's not set so that it can be set when the handler
 	// is created or overridden by the calling application
-	if opts.Level != "" {
+	if opts.StderrLevel != "" {
 		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
-			return fmt.Errorf("failed to parse level '%s' for console handler: %s", opts.Level, err.Error())
+		if err := level.UnmarshalText([]byte(opts.StderrLevel)); err != nil {
+			return fmt.Errorf("failed to parse stderr level '%s' for console handler: %s", opts.StderrLevel,
+				err.Error())
 		}
-		o.Level = &level
+		o.StderrLevel = &level
 	}
-	if opts.MaxLevel != "" {
-		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.MaxLevel)); err != nil {
-			return fmt.Errorf("failed to parse max level '%s' for console handler: %s", opts.MaxLevel, err.Error())
-		}
-		o.MaxLevel = &level
+
+	// validate the timestamp policy
+	policy := xlog.TimestampPolicy(strings.TrimSpace(strings.ToLower(opts.TimestampPolicy)))
+	if !policy.IsValid() {
+		return fmt.Errorf("%s: invalid timestamp policy for console handler", opts.TimestampPolicy)
 	}
+	o.TimestampPolicy = policy
 
 	// copy remaining options
-	o.IncludeCaller = opts.IncludeCaller
+	o.DeduplicateKeys = opts.DeduplicateKeys
+	o.ExpandErrors = opts.ExpandErrors
+	o.FlattenGroups = opts.FlattenGroups
+	o.Humanize = opts.Humanize
+	o.MaxAttrLength = opts.MaxAttrLength
+	o.MaxMessageLength = opts.MaxMessageLength
+	o.Sanitize = opts.Sanitize
+	o.SensitiveKeys = opts.SensitiveKeys
+	o.ShortCaller = opts.ShortCaller
 	o.Stderr = opts.Stderr
+	o.TimeFormat = opts.TimeFormat
+	o.UTC = opts.UTC
 
 	return nil
 }
 
+// Validate checks the options for problems, returning a single error describing all of them.
+//
+// Values which are not set are not treated as problems since they are replaced by defaults when the handler is
+// created.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o ConsoleHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	switch o.Color {
+	case ConsoleHandlerAlwaysColor, ConsoleHandlerAutoColor, ConsoleHandlerNeverColor, "":
+	default:
+		v.addf("color", "invalid color '%s'", o.Color)
+	}
+	switch o.Format {
+	case ConsoleHandlerECSFormat, ConsoleHandlerJSONFormat, ConsoleHandlerLogfmtFormat, ConsoleHandlerPlaintextFormat,
+		ConsoleHandlerPrettyFormat, ConsoleHandlerPrettyJSONFormat, "":
+	default:
+		if o.Encoder == nil {
+			v.addf("format", "invalid format '%s'", o.Format)
+		}
+	}
+	v.checkLevels(o.Level, o.MaxLevel)
+	v.checkNonNegative("max_attr_length", int64(o.MaxAttrLength))
+	v.checkNonNegative("max_message_length", int64(o.MaxMessageLength))
+	v.checkPatterns("sensitive_keys", o.SensitiveKeys)
+	if !o.TimestampPolicy.IsValid() {
+		v.addf("timestamp_policy", "invalid timestamp policy '%s'", o.TimestampPolicy)
+	}
+	return v.err(ConsoleHandlerType)
+}
+
+// ensure [ConsoleHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = ConsoleHandlerOptions{}
+
 // ensure [ConsoleHandler] implements [xlog.ExtendedHandler] interface.
 var _ xlog.ExtendedHandler = &ConsoleHandler{}
 
 // ensure [ConsoleHandler] implements [xlog.LevelVarHandler] interface.
 var _ xlog.LevelVarHandler = &ConsoleHandler{}
 
+// consoleJSONSource holds the caller information of a record written by the JSON format, which marshals it the same
+// way [slog.JSONHandler] does.
+type consoleJSONSource slog.Source
+
 // ConsoleHandler is a handler that simply writes messages to stdout or stderr.
+//
+// The options are copied when the handler is created and are never modified afterward. Handlers derived using
+// WithAttrs or WithGroup share the options, level variables and writers with the handler they were derived from, so
+// changing a level variable affects all of them.
 type ConsoleHandler struct {
 	// unexported variables
-	handler slog.Handler          // underlying handler used for output
-	options ConsoleHandlerOptions // handler options
+	handler       slog.Handler          // underlying handler used for output
+	options       ConsoleHandlerOptions // immutable handler options
+	stderrHandler slog.Handler          // underlying handler used for stderr output when splitting output by level
+	writeMu       *sync.Mutex           // mutex shared by all clones to serialize hooked writes
 }
 
 // NewConsoleHandler creates a new [ConsoleHandler] object with the given options.
@@ -228,12 +586,6 @@ func NewConsoleHandler(options ConsoleHandlerOptions) (*ConsoleHandler, xerrors.
 		options: options,
 	}
 
-	// setup the output writer to stdout or stderr
-	writer := os.Stdout
-	if h.options.Stderr {
-		writer = os.Stderr
-	}
-
 	// ensure a minimum level is set
 	if h.options.Level == nil {
 		var level slog.LevelVar
@@ -241,41 +593,66 @@ func NewConsoleHandler(options ConsoleHandlerOptions) (*ConsoleHandler, xerrors.
 		h.options.Level = &level
 	}
 
-	// create the handler based on the format
+	// set remaining defaults
+	if h.options.Color == "" {
+		h.options.Color = DefaultConsoleHandlerColor
+	}
 	if h.options.Format == "" {
 		h.options.Format = DefaultConsoleHandlerFormat
 	}
-	switch h.options.Format {
-	case ConsoleHandlerJSONFormat:
-		h.handler = slog.NewJSONHandler(writer, &slog.HandlerOptions{
-			AddSource:   h.options.IncludeCaller,
-			Level:       h.options.Level,
-			ReplaceAttr: h.options.ReplaceAttr,
-		})
-	case ConsoleHandlerPlaintextFormat:
-		h.handler = slog.NewTextHandler(writer, &slog.HandlerOptions{
-			AddSource:   h.options.IncludeCaller,
-			Level:       h.options.Level,
-			ReplaceAttr: h.options.ReplaceAttr,
-		})
-	case ConsoleHandlerPrettyFormat:
-		h.handler = tint.NewHandler(colorable.NewColorable(writer), &tint.Options{
-			AddSource:   h.options.IncludeCaller,
-			Level:       h.options.Level,
-			NoColor:     !isatty.IsTerminal(writer.Fd()),
-			ReplaceAttr: h.options.ReplaceAttr,
-			TimeFormat:  "2006-01-02 15:04:05",
-		})
-	default:
-		return nil, xerrors.Newf(xlog.OptionsValidationError, "%s: invalid console handler format",
-			h.options.Format).WithAttr("format", h.options.Format)
+	if h.options.TimeFormat == "" {
+		h.options.TimeFormat = DefaultConsoleHandlerTimeFormat
+	}
+	if h.options.WriteHook != nil {
+		h.writeMu = &sync.Mutex{}
 	}
 
+	// create the handler(s) for stdout and/or stderr
+	var err xerrors.Error
+	if h.options.Writer != nil {
+		if h.handler, err = h.newFormatHandler(h.options.Writer); err != nil {
+			return nil, err
+		}
+	} else if h.options.StderrLevel != nil {
+		if h.handler, err = h.newFormatHandler(os.Stdout); err != nil {
+			return nil, err
+		}
+		if h.stderrHandler, err = h.newFormatHandler(os.Stderr); err != nil {
+			return nil, err
+		}
+	} else {
+		writer := os.Stdout
+		if h.options.Stderr {
+			writer = os.Stderr
+		}
+		if h.handler, err = h.newFormatHandler(writer); err != nil {
+			return nil, err
+		}
+	}
+
+	// flatten nested groups, if desired
+	if h.options.FlattenGroups {
+		h.handler = xlog.NewFlattenHandler(h.handler, xlog.FlattenHandlerOptions{})
+		if h.stderrHandler != nil {
+			h.stderrHandler = xlog.NewFlattenHandler(h.stderrHandler, xlog.FlattenHandlerOptions{})
+		}
+	}
+
+	// remove duplicate attribute keys, if desired
+	if h.options.DeduplicateKeys {
+		h.handler = xlog.NewDedupHandler(h.handler)
+		if h.stderrHandler != nil {
+			h.stderrHandler = xlog.NewDedupHandler(h.stderrHandler)
+		}
+	}
 	return h, nil
 }
 
-// ChildHandlers returns the underlying [slog.Handler] which actually performs the logging.
+// ChildHandlers returns the underlying [slog.Handler] objects which actually perform the logging.
 func (h *ConsoleHandler) ChildHandlers() []slog.Handler {
+	if h.stderrHandler != nil {
+		return []slog.Handler{h.handler, h.stderrHandler}
+	}
 	return []slog.Handler{h.handler}
 }
 
@@ -286,11 +663,7 @@ func (h *ConsoleHandler) Close() error {
 
 // Enabled returns true if the handler should handle the message or false if it should not.
 func (h *ConsoleHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	handlerLevel := h.options.Level.Level()
-	if h.options.MaxLevel == nil {
-		return level >= handlerLevel
-	}
-	return level >= handlerLevel && level <= handlerLevel
+	return h.options.Enabled(level)
 }
 
 // GetLevelVar returns the handler's [slog.LevelVar] for manipulating the minimum logging level.
@@ -304,15 +677,32 @@ func (h *ConsoleHandler) GetMaxLevelVar() *slog.LevelVar {
 }
 
 // Handle processes the record and handles logging it.
+//
+// If a stderr level is configured, records at or above that level are written to stderr and all other records are
+// written to stdout.
 func (h *ConsoleHandler) Handle(ctx context.Context, r slog.Record) error {
-	err := h.handler.Handle(ctx, r)
-	if err != nil && h.options.ErrorHandler != nil {
-		err = h.options.ErrorHandler(ctx, err, &r)
+	r, ok := xlog.ApplyTimestampPolicy(r, h.options.TimestampPolicy, h.options.Clock)
+	if !ok {
+		return nil
+	}
+
+	handler := h.handler
+	if h.stderrHandler != nil && r.Level >= h.options.StderrLevel.Level() {
+		handler = h.stderrHandler
+	}
+	err := handler.Handle(ctx, r)
+	if err != nil {
+		logHandleError(ctx, ConsoleHandlerType, err, &r)
+		if h.options.ErrorHandler != nil {
+			err = h.options.ErrorHandler(ctx, err, &r)
+		}
 	}
 	return err
 }
 
-// Options returns the handler's options.
+// Options returns a copy of the handler's options.
+//
+// Modifying the returned options has no effect on the handler, except through the shared level variables.
 func (h *ConsoleHandler) Options() any {
 	return h.options
 }
@@ -327,6 +717,9 @@ func (h *ConsoleHandler) Type() string {
 func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	clone := h.clone()
 	clone.handler = h.handler.WithAttrs(attrs)
+	if h.stderrHandler != nil {
+		clone.stderrHandler = h.stderrHandler.WithAttrs(attrs)
+	}
 	return clone
 }
 
@@ -338,15 +731,216 @@ func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
 
 	clone := h.clone()
 	clone.handler = h.handler.WithGroup(name)
+	if h.stderrHandler != nil {
+		clone.stderrHandler = h.stderrHandler.WithGroup(name)
+	}
 	return clone
 }
 
 // clone creates a copy of current handler.
 func (h *ConsoleHandler) clone() *ConsoleHandler {
 	return &ConsoleHandler{
-		handler: h.handler,
-		options: h.options,
+		handler:       h.handler,
+		options:       h.options,
+		stderrHandler: h.stderrHandler,
+		writeMu:       h.writeMu,
+	}
+}
+
+// hookWriter wraps the given writer so that the write hook is called around each write, if one is configured.
+func (h *ConsoleHandler) hookWriter(w io.Writer) io.Writer {
+	if h.options.WriteHook == nil {
+		return w
+	}
+	return &hookedWriter{
+		hook: h.options.WriteHook,
+		mu:   h.writeMu,
+		w:    w,
+	}
+}
+
+// newFormatHandler creates the underlying handler which writes messages to the given writer in the configured
+// format.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (h *ConsoleHandler) newFormatHandler(writer io.Writer) (slog.Handler, xerrors.Error) {
+	if h.options.Encoder != nil {
+		return newEncoderHandler(h.hookWriter(writer), h.options.Level, h.options.Encoder), nil
+	}
+
+	// translate well-known time format names into layouts
+	timeFormat := h.options.TimeFormat
+	if layout, ok := consoleHandlerTimeFormats[strings.ToLower(strings.TrimSpace(timeFormat))]; ok {
+		timeFormat = layout
+	}
+
+	// determine whether or not to colorize the output
+	var noColor bool
+	switch h.options.Color {
+	case ConsoleHandlerAlwaysColor:
+		noColor = false
+	case ConsoleHandlerAutoColor:
+		noColor = !useConsoleColor(writer)
+	case ConsoleHandlerNeverColor:
+		noColor = true
+	default:
+		return nil, xerrors.Newf(xlog.OptionsValidationError, "%s: invalid console handler color",
+			h.options.Color).WithAttr("color", h.options.Color)
+	}
+
+	// convert message times to UTC and shorten output, if desired
+	replaceAttr := h.options.ReplaceAttr
+	if h.options.UTC {
+		replaceAttr = replaceAttrWithUTC(replaceAttr)
+	}
+	if h.options.ShortCaller || h.options.MaxMessageLength > 0 || h.options.MaxAttrLength > 0 {
+		replaceAttr = h.replaceAttrWithLimits(replaceAttr)
+	}
+
+	// render values in human-readable units for text formats and expand errors for JSON-based formats, if desired
+	switch h.options.Format {
+	case ConsoleHandlerECSFormat, ConsoleHandlerJSONFormat, ConsoleHandlerPrettyJSONFormat:
+		if h.options.ExpandErrors {
+			replaceAttr = replaceAttrWithErrors(replaceAttr)
+		}
+	case ConsoleHandlerLogfmtFormat, ConsoleHandlerPlaintextFormat, ConsoleHandlerPrettyFormat:
+		if h.options.Humanize {
+			replaceAttr = replaceAttrWithHumanize(replaceAttr)
+		}
 	}
+
+	// fix invalid values, if desired, and mask sensitive values last so that they cannot be revealed by any other
+	// replacement
+	if h.options.Sanitize {
+		replaceAttr = replaceAttrWithSanitize(replaceAttr, h.options.ErrorHandler)
+	}
+	replaceAttr = replaceAttrWithMask(replaceAttr, h.options.SensitiveKeys)
+
+	// create the handler based on the format
+	output := h.hookWriter(writer)
+	switch h.options.Format {
+	case ConsoleHandlerECSFormat:
+		return newEncoderHandler(output, h.options.Level, NewECSEncoder(&slog.HandlerOptions{
+			AddSource:   h.options.IncludeCaller,
+			ReplaceAttr: replaceAttr,
+		})), nil
+	case ConsoleHandlerJSONFormat:
+		return slog.NewJSONHandler(output, &slog.HandlerOptions{
+			AddSource:   h.options.IncludeCaller,
+			Level:       h.options.Level,
+			ReplaceAttr: replaceAttr,
+		}), nil
+	case ConsoleHandlerLogfmtFormat:
+		return newEncoderHandler(output, h.options.Level, NewLogfmtEncoder(&slog.HandlerOptions{
+			AddSource:   h.options.IncludeCaller,
+			ReplaceAttr: replaceAttr,
+		})), nil
+	case ConsoleHandlerPlaintextFormat:
+		return slog.NewTextHandler(output, &slog.HandlerOptions{
+			AddSource:   h.options.IncludeCaller,
+			Level:       h.options.Level,
+			ReplaceAttr: replaceAttr,
+		}), nil
+	case ConsoleHandlerPrettyJSONFormat:
+		return newEncoderHandler(output, h.options.Level, NewJSONEncoder(&slog.HandlerOptions{
+			AddSource:   h.options.IncludeCaller,
+			ReplaceAttr: replaceAttr,
+		}, "  ")), nil
+	case ConsoleHandlerPrettyFormat:
+		if f, ok := writer.(*os.File); ok {
+			output = h.hookWriter(colorable.NewColorable(f))
+		}
+		return tint.NewHandler(output, &tint.Options{
+			AddSource:   h.options.IncludeCaller,
+			Level:       h.options.Level,
+			NoColor:     noColor,
+			ReplaceAttr: replaceAttr,
+			TimeFormat:  timeFormat,
+		}), nil
+	}
+	return nil, xerrors.Newf(xlog.OptionsValidationError, "%s: invalid console handler format",
+		h.options.Format).WithAttr("format", h.options.Format)
+}
+
+// replaceAttrWithLimits wraps the given ReplaceAttr function so that the attribute it returns has its caller path
+// shortened and its message or value truncated according to the handler's options.
+func (h *ConsoleHandler) replaceAttrWithLimits(
+	next func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if next != nil {
+			attr = next(groups, attr)
+			if attr.Key == "" {
+				return attr
+			}
+		}
+
+		// handle built-in attributes
+		if len(groups) == 0 {
+			switch attr.Key {
+			case slog.SourceKey:
+				if src, ok := attr.Value.Any().(*slog.Source); ok && h.options.ShortCaller {
+					shortSrc := *src
+					shortSrc.File = path.Join(path.Base(path.Dir(filepath.ToSlash(src.File))), path.Base(src.File))
+					attr.Value = slog.AnyValue(&shortSrc)
+				}
+
+				// the JSON handler would otherwise expand the caller information and pass its fields back through
+				// this function as top-level attributes, where they cannot be told apart from other attributes
+				if src, ok := attr.Value.Any().(*slog.Source); ok && h.options.Format == ConsoleHandlerJSONFormat {
+					attr.Value = slog.AnyValue((*consoleJSONSource)(src))
+				}
+				return attr
+			case slog.MessageKey:
+				if h.options.MaxMessageLength > 0 {
+					attr.Value = slog.StringValue(truncateString(attr.Value.String(), h.options.MaxMessageLength))
+				}
+				return attr
+			case slog.TimeKey, slog.LevelKey:
+				return attr
+			}
+		}
+
+		// truncate attribute values which are not numeric
+		if h.options.MaxAttrLength > 0 {
+			switch attr.Value.Kind() {
+			case slog.KindString:
+				attr.Value = slog.StringValue(truncateString(attr.Value.String(), h.options.MaxAttrLength))
+			case slog.KindAny:
+				str := formatTextValue(attr.Value, time.RFC3339)
+				if utf8.RuneCountInString(str) > h.options.MaxAttrLength {
+					attr.Value = slog.StringValue(truncateString(str, h.options.MaxAttrLength))
+				}
+			}
+		}
+		return attr
+	}
+}
+
+// MarshalJSON marshals the caller information into an object holding its function, file and line, omitting those
+// which are not set.
+func (s *consoleJSONSource) MarshalJSON() ([]byte, error) {
+	return marshalJSON(struct {
+		Function string `json:"function,omitempty"`
+		File     string `json:"file,omitempty"`
+		Line     int    `json:"line,omitempty"`
+	}{s.Function, s.File, s.Line})
+}
+
+// useConsoleColor determines whether or not output to the given writer should be colorized based on the NO_COLOR and
+// FORCE_COLOR environment variables and whether or not the writer is a terminal.
+func useConsoleColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := strings.ToLower(os.Getenv("FORCE_COLOR")); force != "" && force != "0" && force != "false" {
+		return true
+	}
+	if f, ok := w.(*os.File); ok {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return false
 }
 
 // consoleHandlerBuilder is used to build the handler from configuration options.
@@ -355,6 +949,9 @@ type consoleHandlerBuilder struct {
 	options ConsoleHandlerOptions // handler options
 }
 
+// ensure [consoleHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &consoleHandlerBuilder{}
+
 // NewConsoleHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options, setting
 // and default values as necessary.
 //
@@ -379,17 +976,9 @@ func NewConsoleHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBu
 //
 // This function may return other errors if the callback function fails and defines its own error values.
 func (b *consoleHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
-	if cb != nil {
-		if err := cb(b.Type(), &b.options); err != nil {
-			return nil, err
-		}
-	}
-	h, err := NewConsoleHandler(b.options)
-	if err != nil {
-		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
-			err.Error())
-	}
-	return h, nil
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
 }
 
 // MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
@@ -420,3 +1009,41 @@ func (b *consoleHandlerBuilder) Options() map[string]any {
 func (b *consoleHandlerBuilder) Type() string {
 	return ConsoleHandlerType
 }
+
+// Validate checks the options for problems without actually building the handler.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *consoleHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *consoleHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewConsoleHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"go.innotegrity.dev/xlog"
+)
+
+// sequenceRange holds the lowest and highest sequence numbers stamped by an [xlog.SequenceHandler] on the records of
+// a batch.
+//
+// Both numbers are 0 if none of the records were stamped.
+type sequenceRange struct {
+	first uint64 // lowest sequence number
+	last  uint64 // highest sequence number
+}
+
+// add extends the range to include the given sequence number.
+func (s *sequenceRange) add(seq uint64) {
+	if s.first == 0 || seq < s.first {
+		s.first = seq
+	}
+	if seq > s.last {
+		s.last = seq
+	}
+}
+
+// merge extends the range to include the given range.
+func (s *sequenceRange) merge(other sequenceRange) {
+	if other.first != 0 {
+		s.add(other.first)
+		s.add(other.last)
+	}
+}
+
+// recordSequence returns the sequence number stamped on the record by an [xlog.SequenceHandler] using the default
+// sequence key, if it has one.
+func recordSequence(r slog.Record) (uint64, bool) {
+	var seq uint64
+	var found bool
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key != xlog.DefaultSequenceKey {
+			return true
+		}
+		if v := attr.Value.Resolve(); v.Kind() == slog.KindUint64 && v.Uint64() > 0 {
+			seq, found = v.Uint64(), true
+		}
+		return false
+	})
+	return seq, found
+}
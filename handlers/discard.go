@@ -21,6 +21,16 @@ const (
 // DiscardHandlerOptions holds the options for a [DiscardHandler].
 type DiscardHandlerOptions struct{}
 
+// Validate checks the options for problems.
+//
+// Since there are no options for the handler, this function always returns nil.
+func (o DiscardHandlerOptions) Validate() xerrors.Error {
+	return nil
+}
+
+// ensure [DiscardHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = DiscardHandlerOptions{}
+
 // ensure [DiscardHandler] implements [ExtendedHandler] interface.
 var _ xlog.ExtendedHandler = &DiscardHandler{}
 
@@ -89,6 +99,9 @@ type discardHandlerBuilder struct {
 	options DiscardHandlerOptions // handler options
 }
 
+// ensure [discardHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &discardHandlerBuilder{}
+
 // NewDiscardHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options, setting
 // and default values as necessary.
 //
@@ -110,17 +123,9 @@ func NewDiscardHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBu
 //
 // This function may return an error if the callback function fails and defines its own error values.
 func (b *discardHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
-	if cb != nil {
-		if err := cb(b.Type(), &b.options); err != nil {
-			return nil, err
-		}
-	}
-	h, err := NewDiscardHandler(b.options)
-	if err != nil {
-		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
-			err.Error())
-	}
-	return h, nil
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
 }
 
 // MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
@@ -151,3 +156,41 @@ func (b *discardHandlerBuilder) Options() map[string]any {
 func (b *discardHandlerBuilder) Type() string {
 	return DiscardHandlerType
 }
+
+// Validate checks the options for problems without actually building the handler.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *discardHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *discardHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewDiscardHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
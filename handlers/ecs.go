@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// ecsVersion is the version of the Elastic Common Schema that the ECS encoder conforms to.
+	ecsVersion = "8.11.0"
+)
+
+// ecsEncoder encodes records as JSON objects using Elastic Common Schema (ECS) field names.
+//
+// The built-in record fields are mapped as follows:
+//   - time is mapped to "@timestamp"
+//   - level is mapped to "log.level" (in lowercase)
+//   - message is mapped to "message"
+//   - caller information is mapped to "log.origin.file.name", "log.origin.file.line" and "log.origin.function"
+//
+// All other attributes are mapped into "labels" with the names of any groups joined to the attribute key using an
+// underscore, since ECS labels may not contain nested objects. Attribute values which are objects or arrays are
+// written as JSON-encoded strings for the same reason.
+//
+// References:
+//
+//	https://www.elastic.co/guide/en/ecs/current/index.html
+type ecsEncoder struct {
+	// unexported variables
+	options slog.HandlerOptions // encoder options
+}
+
+// NewECSEncoder creates a new [xlog.Encoder] which encodes records as JSON objects using Elastic Common Schema (ECS)
+// field names.
+//
+// Only the AddSource and ReplaceAttr options are used by the encoder.
+//
+// References:
+//
+//	https://www.elastic.co/guide/en/ecs/current/index.html
+func NewECSEncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &ecsEncoder{}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single-line JSON object followed by a newline.
+func (e *ecsEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	var labels []slog.Attr
+	var logAttrs []slog.Attr
+	fields := make([]slog.Attr, 0, 5)
+
+	// map the built-in attributes to their ECS fields
+	for _, attr := range builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr) {
+		switch attr.Key {
+		case slog.TimeKey:
+			if attr.Value.Kind() == slog.KindTime {
+				attr.Value = slog.StringValue(attr.Value.Time().Format(time.RFC3339Nano))
+			}
+			fields = append(fields, slog.Attr{Key: "@timestamp", Value: attr.Value})
+		case slog.LevelKey:
+			logAttrs = append(logAttrs, slog.String("level", strings.ToLower(formatTextValue(attr.Value, ""))))
+		case slog.SourceKey:
+			if src, ok := attr.Value.Any().(*slog.Source); ok {
+				logAttrs = append(logAttrs, slog.Group("origin",
+					slog.Group("file",
+						slog.String("name", src.File),
+						slog.Int("line", src.Line),
+					),
+					slog.String("function", src.Function),
+				))
+			} else {
+				labels = append(labels, attr)
+			}
+		case slog.MessageKey:
+			fields = append(fields, slog.Attr{Key: "message", Value: attr.Value})
+		default:
+			labels = append(labels, attr)
+		}
+	}
+	if len(logAttrs) > 0 {
+		fields = append(fields, slog.Attr{Key: "log", Value: slog.GroupValue(logAttrs...)})
+	}
+	fields = append(fields, slog.Group("ecs", slog.String("version", ecsVersion)))
+
+	// flatten all other attributes into labels
+	for _, attr := range recordAttrs(r, attrs, groups, e.options.ReplaceAttr) {
+		labels = appendECSLabels(labels, "", attr)
+	}
+	if len(labels) > 0 {
+		fields = append(fields, slog.Attr{Key: "labels", Value: slog.GroupValue(labels...)})
+	}
+
+	appendJSONObject(buf, fields, "", 0)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// appendECSLabels flattens the given attribute into one or more labels, prefixing keys with the given prefix.
+func appendECSLabels(labels []slog.Attr, prefix string, attr slog.Attr) []slog.Attr {
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, child := range attr.Value.Group() {
+			labels = appendECSLabels(labels, prefix+attr.Key+"_", child)
+		}
+		return labels
+	}
+
+	// objects and arrays are not allowed in labels, so they are stored as JSON strings instead
+	value := attr.Value
+	if value.Kind() == slog.KindAny {
+		if b := marshalJSONValue(value); len(b) > 0 && (b[0] == '{' || b[0] == '[') {
+			value = slog.StringValue(string(b))
+		}
+	}
+	return append(labels, slog.Attr{Key: prefix + attr.Key, Value: value})
+}
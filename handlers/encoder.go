@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// encoderMaxPooledBufferSize is the maximum capacity of a record buffer that will be returned to the buffer pool.
+	// Larger buffers are discarded so that a single large record does not pin memory indefinitely.
+	encoderMaxPooledBufferSize = 64 * 1024
+)
+
+var (
+	// encoderBufferPool holds the buffers used to encode individual records.
+	encoderBufferPool = sync.Pool{
+		New: func() any {
+			return &bytes.Buffer{}
+		},
+	}
+)
+
+// encoderHandler is a generic [slog.Handler] which formats records using an [xlog.Encoder] and writes them to an
+// [io.Writer].
+type encoderHandler struct {
+	// unexported variables
+	attrs          []slog.Attr        // handler-level attributes
+	encoder        xlog.Encoder       // encoder used to format records
+	groups         []string           // currently open groups
+	level          slog.Leveler       // minimum level at which to log messages
+	maxRecordBytes int                // maximum size of an encoded record or 0 for no limit
+	mu             *sync.Mutex        // mutex shared by all clones to serialize writes or nil if the writer is safe
+	sizeStrategy   RecordSizeStrategy // strategy used to make oversized records fit
+	writer         io.Writer          // output writer
+}
+
+// newEncoderHandler creates a new [encoderHandler] object.
+//
+// If level is nil, [slog.LevelInfo] is used as the minimum level.
+func newEncoderHandler(writer io.Writer, level slog.Leveler, encoder xlog.Encoder) *encoderHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	h := &encoderHandler{
+		encoder: encoder,
+		level:   level,
+		writer:  writer,
+	}
+
+	// the async writer serializes writes itself without a lock, so only lock around other writers
+	if _, ok := writer.(*asyncWriter); !ok {
+		h.mu = &sync.Mutex{}
+	}
+	return h
+}
+
+// Enabled returns true if the level is at or above the handler's minimum level.
+func (h *encoderHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle encodes the record, making it fit within the maximum record size if one is set, and writes it to the
+// output writer.
+func (h *encoderHandler) Handle(ctx context.Context, r slog.Record) error {
+	buf := encoderBufferPool.Get().(*bytes.Buffer)
+	defer putEncoderBuffer(buf)
+	err := encodeWithinSize(buf, r, h.maxRecordBytes, h.sizeStrategy, func(b *bytes.Buffer, r slog.Record) error {
+		return h.encoder.EncodeRecord(b, r, h.attrs, h.groups)
+	})
+	if err != nil {
+		return err
+	}
+
+	if h.mu != nil {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+	_, err = h.writer.Write(buf.Bytes())
+	return err
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the
+// given attributes.
+func (h *encoderHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := h.clone()
+	clone.attrs = appendGroupedAttrs(h.attrs, h.groups, attrs)
+	return clone
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *encoderHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+	clone := h.clone()
+	clone.groups = append(slices.Clip(h.groups), name)
+	return clone
+}
+
+// clone creates a copy of current handler.
+func (h *encoderHandler) clone() *encoderHandler {
+	return &encoderHandler{
+		attrs:          h.attrs,
+		encoder:        h.encoder,
+		groups:         h.groups,
+		level:          h.level,
+		maxRecordBytes: h.maxRecordBytes,
+		mu:             h.mu,
+		sizeStrategy:   h.sizeStrategy,
+		writer:         h.writer,
+	}
+}
+
+// appendGroupedAttrs returns a new slice containing the given attributes followed by newAttrs nested inside of the
+// given groups.
+//
+// If the last attribute already holds the outermost group, newAttrs are merged into it so that the same group does
+// not appear in the output more than once. The original slice is never modified.
+func appendGroupedAttrs(attrs []slog.Attr, groups []string, newAttrs []slog.Attr) []slog.Attr {
+	if len(newAttrs) == 0 {
+		return attrs
+	}
+	if len(groups) == 0 {
+		return append(slices.Clip(attrs), newAttrs...)
+	}
+
+	// merge into the last attribute if it is the same group
+	if n := len(attrs); n > 0 && attrs[n-1].Key == groups[0] && attrs[n-1].Value.Kind() == slog.KindGroup {
+		merged := slices.Clone(attrs)
+		merged[n-1] = slog.Attr{
+			Key:   groups[0],
+			Value: slog.GroupValue(appendGroupedAttrs(attrs[n-1].Value.Group(), groups[1:], newAttrs)...),
+		}
+		return merged
+	}
+	return append(slices.Clip(attrs), slog.Attr{
+		Key:   groups[0],
+		Value: slog.GroupValue(appendGroupedAttrs(nil, groups[1:], newAttrs)...),
+	})
+}
+
+// builtinAttrs returns the built-in attributes for the record in the order time, level, source (if addSource is true)
+// and message after passing each of them to the replace function, if one is given.
+//
+// Attributes removed by the replace function are not returned. The time attribute is omitted if the record's time is
+// zero and the source attribute is omitted if the record has no caller information.
+func builtinAttrs(r slog.Record, addSource bool, replace func([]string, slog.Attr) slog.Attr) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 4)
+	if !r.Time.IsZero() {
+		attrs = append(attrs, slog.Time(slog.TimeKey, r.Time.Round(0)))
+	}
+	attrs = append(attrs, slog.Any(slog.LevelKey, r.Level))
+	if addSource {
+		if src := r.Source(); src != nil {
+			attrs = append(attrs, slog.Any(slog.SourceKey, src))
+		}
+	}
+	attrs = append(attrs, slog.String(slog.MessageKey, r.Message))
+
+	if replace == nil {
+		return attrs
+	}
+	replaced := attrs[:0]
+	for _, attr := range attrs {
+		attr = replace(nil, attr)
+		attr.Value = attr.Value.Resolve()
+		if attr.Key != "" {
+			replaced = append(replaced, attr)
+		}
+	}
+	return replaced
+}
+
+// putEncoderBuffer resets the given buffer and returns it to the buffer pool unless it has grown too large.
+func putEncoderBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > encoderMaxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	encoderBufferPool.Put(buf)
+}
+
+// flattenGroupedAttrs nests the given resolved attributes inside of the given groups and flattens the result using
+// [xlog.FlattenAttrs], so that each key is prefixed with the names of the groups.
+func flattenGroupedAttrs(groups []string, attrs []slog.Attr) []slog.Attr {
+	for i := len(groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{slog.GroupAttrs(groups[i], attrs...)}
+	}
+	return xlog.FlattenAttrs(attrs, "")
+}
+
+// recordAttrs returns the complete, resolved set of non-built-in attributes for the record, including any
+// handler-level attributes and groups.
+//
+// Values are resolved, the replace function (if given) is called for every non-group attribute, empty attributes and
+// groups are removed and groups with empty keys are inlined into their parent, matching the behavior of the built-in
+// [slog] handlers. The values of attributes and groups whose key matches any of the default sensitive keys defined in
+// the package are masked.
+func recordAttrs(r slog.Record, attrs []slog.Attr, groups []string,
+	replace func([]string, slog.Attr) slog.Attr) []slog.Attr {
+
+	recAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		recAttrs = append(recAttrs, attr)
+		return true
+	})
+	return resolveAttrs(appendGroupedAttrs(attrs, groups, recAttrs), nil, replace)
+}
+
+// resolveAttrs recursively resolves and replaces the given attributes, returning only those which should be output.
+func resolveAttrs(attrs []slog.Attr, groups []string, replace func([]string, slog.Attr) slog.Attr) []slog.Attr {
+	resolved := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Value.Kind() == slog.KindGroup && IsSensitiveKey(attr.Key, DefaultSensitiveKeys) {
+			resolved = append(resolved, slog.String(attr.Key, DefaultSensitiveValueMask))
+			continue
+		}
+		if attr.Value.Kind() == slog.KindGroup {
+			var children []slog.Attr
+			if attr.Key == "" {
+				children = resolveAttrs(attr.Value.Group(), groups, replace)
+				resolved = append(resolved, children...)
+				continue
+			}
+			children = resolveAttrs(attr.Value.Group(), append(slices.Clip(groups), attr.Key), replace)
+			if len(children) > 0 {
+				resolved = append(resolved, slog.Attr{Key: attr.Key, Value: slog.GroupValue(children...)})
+			}
+			continue
+		}
+		if replace != nil {
+			attr = replace(groups, attr)
+			attr.Value = attr.Value.Resolve()
+		}
+		attr = maskSensitiveAttr(groups, attr, DefaultSensitiveKeys)
+		if attr.Key != "" {
+			resolved = append(resolved, attr)
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// ConfigExtendsKey is the key in a handler configuration which names the configuration it extends.
+	//
+	// The value is either the name of a preset registered using [RegisterConfigPreset] or the path to a file holding
+	// a JSON handler configuration, or an array of such names and paths which are applied in order. The extended
+	// configurations are merged using [MergeConfigs], followed by the rest of the configuration holding the key, so
+	// local options always override the extended ones.
+	ConfigExtendsKey = "extends"
+)
+
+var (
+	// _configPresets holds the handler configurations registered using [RegisterConfigPreset].
+	_configPresets = map[string][]byte{}
+)
+
+// RegisterConfigPreset attempts to register a named handler configuration which other handler configurations can
+// extend using the [ConfigExtendsKey] key, so that organizations can share logging profiles (eg: "org-audit")
+// between applications.
+//
+// The configuration must be a JSON object in the same form as a child handler of a fanout handler. It may extend
+// other presets or files itself.
+//
+// To overwrite an existing preset with the same name, set overwrite to true.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: an invalid parameter was passed to the function (eg: name was empty)
+//   - [xlog.HandlerTypeExists]: a preset with the given name already exists
+//   - [xlog.MarshalError]: the configuration is not a valid JSON object
+func RegisterConfigPreset(name string, config []byte, overwrite bool) xerrors.Error {
+	name = strings.TrimSpace(strings.ToLower(name))
+	if name == "" {
+		return xerrors.New(xlog.InvalidParameter, "preset name cannot be empty")
+	}
+	if _, ok := _configPresets[name]; ok && !overwrite {
+		return xerrors.Newf(xlog.HandlerTypeExists, "%s: preset is already registered", name).
+			WithAttr("preset", name)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(config, &object); err != nil {
+		return xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal preset '%s': %s", name, err.Error()).
+			WithAttr("preset", name)
+	}
+	_configPresets[name] = slices.Clone(config)
+	return nil
+}
+
+// decodeConfigObject decodes the given JSON configuration, which must be an object, preserving numbers as they are.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: the configuration is not a valid JSON object
+func decodeConfigObject(config []byte) (map[string]any, xerrors.Error) {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal configuration: %s", err.Error())
+	}
+	return object, nil
+}
+
+// extendConfig merges the configurations named by the [ConfigExtendsKey] key of the given configuration beneath it,
+// resolving their own keys recursively, and returns the result without the key.
+//
+// The chain holds the names of the configurations being extended, which is used to detect cycles.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read an extended file
+//   - [xlog.InvalidParameter]: a configuration extends itself or the key holds an invalid value
+//   - [xlog.MarshalError]: a configuration is not a valid JSON object
+func extendConfig(config map[string]any, chain []string) (map[string]any, xerrors.Error) {
+	var names []string
+	switch v := config[ConfigExtendsKey].(type) {
+	case nil:
+		return config, nil
+	case string:
+		names = []string{v}
+	case []any:
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return nil, xerrors.Newf(xlog.InvalidParameter, "'%s' must hold a string or an array of strings",
+					ConfigExtendsKey).WithAttr("value", v)
+			}
+			names = append(names, s)
+		}
+	default:
+		return nil, xerrors.Newf(xlog.InvalidParameter, "'%s' must hold a string or an array of strings",
+			ConfigExtendsKey).WithAttr("value", v)
+	}
+
+	merged := map[string]any{}
+	for _, name := range names {
+		if slices.Contains(chain, name) {
+			return nil, xerrors.Newf(xlog.InvalidParameter, "%s: configuration extends itself", name).
+				WithAttr("chain", append(slices.Clone(chain), name))
+		}
+
+		// load the preset or file
+		data, ok := _configPresets[strings.TrimSpace(strings.ToLower(name))]
+		if !ok {
+			var err error
+			if data, err = os.ReadFile(name); err != nil {
+				return nil, xerrors.Wrapf(xlog.DataReadError, err,
+					"'%s' is not a registered preset and the file could not be read: %s", name, err.Error()).
+					WithAttr("path", name)
+			}
+		}
+		base, xerr := decodeConfigObject(data)
+		if xerr != nil {
+			return nil, xerr.WithAttr("extends", name)
+		}
+		if base, xerr = extendConfig(base, append(slices.Clone(chain), name)); xerr != nil {
+			return nil, xerr
+		}
+		merged = mergeConfigObjects(merged, base)
+	}
+
+	delete(config, ConfigExtendsKey)
+	return mergeConfigObjects(merged, config), nil
+}
+
+// resolveConfigExtends returns the given JSON handler configuration with the configurations named by its
+// [ConfigExtendsKey] key, if any, merged beneath it.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read an extended file
+//   - [xlog.InvalidParameter]: the configuration extends itself or the key holds an invalid value
+//   - [xlog.MarshalError]: a configuration is not a valid JSON object
+func resolveConfigExtends(config []byte) ([]byte, xerrors.Error) {
+	object, xerr := decodeConfigObject(config)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if _, ok := object[ConfigExtendsKey]; !ok {
+		return config, nil
+	}
+	if object, xerr = extendConfig(object, nil); xerr != nil {
+		return nil, xerr
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to marshal extended configuration: %s",
+			err.Error())
+	}
+	return data, nil
+}
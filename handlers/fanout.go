@@ -8,6 +8,8 @@ import (
 	"io"
 	"log/slog"
 	"reflect"
+	"slices"
+	"strings"
 
 	"go.innotegrity.dev/xlog"
 
@@ -28,13 +30,43 @@ type FanoutHandlerOptions struct {
 	Handlers []slog.Handler `json:"-"`
 }
 
+// Validate checks the options for problems, returning a single error describing all of them.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o FanoutHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	for i, handler := range o.Handlers {
+		if handler == nil {
+			v.addf(fmt.Sprintf("handlers[%d]", i), "handler cannot be nil")
+		}
+	}
+	return v.err(FanoutHandlerType)
+}
+
+// clone returns a copy of the options which shares no slices with the original.
+func (o FanoutHandlerOptions) clone() FanoutHandlerOptions {
+	o.Handlers = slices.Clone(o.Handlers)
+	return o
+}
+
+// ensure [FanoutHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = FanoutHandlerOptions{}
+
 // ensure [FanoutHandler] implements [xlog.ExtendedHandler] interface.
 var _ xlog.ExtendedHandler = &FanoutHandler{}
 
+// ensure [FanoutHandler] implements [xlog.BatchHandler] interface.
+var _ xlog.BatchHandler = &FanoutHandler{}
+
 // FanoutHandler is a handler that simply writes messages to multiple child handlers.
+//
+// The list of child handlers is copied when the handler is created, so changing the slice passed to
+// [NewFanoutHandler] afterward has no effect. Handlers derived using WithAttrs or WithGroup hold their own list of
+// derived child handlers.
 type FanoutHandler struct {
 	// unexported variables
-	options FanoutHandlerOptions // handler options
+	options FanoutHandlerOptions // immutable handler options
 }
 
 // NewFanoutHandler creates a new [FanoutHandler] object.
@@ -43,7 +75,7 @@ type FanoutHandler struct {
 // handler "constructors".
 func NewFanoutHandler(options FanoutHandlerOptions) (*FanoutHandler, xerrors.Error) {
 	return &FanoutHandler{
-		options: options,
+		options: options.clone(),
 	}, nil
 }
 
@@ -101,6 +133,27 @@ func (h *FanoutHandler) Handle(ctx context.Context, r slog.Record) error {
 	return errors.Join(errs...)
 }
 
+// HandleBatch distributes a batch of records to all child handlers.
+//
+// Each child handler receives its own cloned copy of the records using [xlog.HandleBatch], so child handlers which
+// implement [xlog.BatchHandler] receive the whole batch at once. Any errors that occur are combined and returned.
+func (h *FanoutHandler) HandleBatch(ctx context.Context, records []slog.Record) error {
+	var errs []error
+	for _, handler := range h.options.Handlers {
+		clones := make([]slog.Record, len(records))
+		for i, r := range records {
+			clones[i] = r.Clone()
+		}
+		err := try(func() error {
+			return xlog.HandleBatch(ctx, handler, clones)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Options returns all of the child handler options in an array inside a string map under the "handlers" key.
 func (h *FanoutHandler) Options() any {
 	handlerOptions := []any{}
@@ -179,6 +232,9 @@ type fanoutHandlerBuilder struct {
 	options fanoutHandlerBuilderOptions // builder options
 }
 
+// ensure [fanoutHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &fanoutHandlerBuilder{}
+
 // NewFanoutHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options, setting
 // and default values as necessary.
 //
@@ -205,23 +261,9 @@ func NewFanoutHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBui
 //
 // This function may return other errors if the callback function fails and defines its own error values.
 func (b *fanoutHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
-	var errs []error
-	handlers := make([]slog.Handler, len(b.options.HandlerBuilders))
-	for i, hb := range b.options.HandlerBuilders {
-		handler, err := hb.builder.Build(cb)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to build '%s' handler: %s", hb.builder.Type(), err.Error()))
-		} else {
-			handlers[i] = handler
-		}
-	}
-	if len(errs) > 0 {
-		return nil, xerrors.Wrap(xlog.BuildHandlerError, errors.Join(errs...),
-			"failed to build one or more handlers")
-	}
-	return NewFanoutHandler(FanoutHandlerOptions{
-		Handlers: handlers,
-	})
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
 }
 
 // MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
@@ -252,3 +294,49 @@ func (b *fanoutHandlerBuilder) Options() map[string]any {
 func (b *fanoutHandlerBuilder) Type() string {
 	return FanoutHandlerType
 }
+
+// Validate checks the options for each of the child handlers for problems without actually building any of them.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more child handlers have invalid options
+func (b *fanoutHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	var errs []error
+	var msgs []string
+	for _, hb := range b.options.HandlerBuilders {
+		if err := xlog.ValidateConfig(hb.builder, cb); err != nil {
+			errs = append(errs, fmt.Errorf("invalid '%s' handler: %w", hb.builder.Type(), err))
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return xerrors.Wrapf(xlog.OptionsValidationError, errors.Join(errs...),
+			"one or more handlers have invalid options: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *fanoutHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	var errs []error
+	handlers := make([]slog.Handler, len(b.options.HandlerBuilders))
+	for i, hb := range b.options.HandlerBuilders {
+		handler, err := hb.builder.Build(cb)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to build '%s' handler: %s", hb.builder.Type(), err.Error()))
+		} else {
+			handlers[i] = handler
+		}
+	}
+	if len(errs) > 0 {
+		return nil, xerrors.Wrap(xlog.BuildHandlerError, errors.Join(errs...),
+			"failed to build one or more handlers")
+	}
+	options := FanoutHandlerOptions{
+		Handlers: handlers,
+	}
+	if err := options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return NewFanoutHandler(options)
+}
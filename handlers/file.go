@@ -1,21 +1,108 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode"
 
+	"filippo.io/age"
 	"go.innotegrity.dev/types"
 	"go.innotegrity.dev/xerrors"
 	"go.innotegrity.dev/xlog"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+const (
+	// FileHandlerCBORFormat outputs messages as CBOR maps using [NewCBOREncoder].
+	//
+	// Records are structured the same way as with [FileHandlerJSONFormat] and are written one after the other with no
+	// separator.
+	//
+	// References:
+	//   https://www.rfc-editor.org/rfc/rfc8949
+	FileHandlerCBORFormat FileHandlerFormat = "cbor"
+
+	// FileHandlerCEFFormat outputs messages in the ArcSight Common Event Format (CEF).
+	//
+	// The header and extension keys are configured using the SIEM options in [FileHandlerOptions].
+	//
+	// References:
+	//   https://www.microfocus.com/documentation/arcsight/arcsight-smartconnectors-8.3/cef-implementation-standard/
+	FileHandlerCEFFormat FileHandlerFormat = "cef"
+
+	// FileHandlerCSVFormat outputs messages as rows of comma-separated values.
+	//
+	// The columns and header row are configured using the columnar options in [FileHandlerOptions].
+	//
+	// References:
+	//   https://www.rfc-editor.org/rfc/rfc4180
+	FileHandlerCSVFormat FileHandlerFormat = "csv"
+
+	// FileHandlerECSFormat outputs messages in JSON format using Elastic Common Schema (ECS) field names.
+	//
+	// The time, level, message and caller are written to the "@timestamp", "log.level", "message" and "log.origin"
+	// fields and all other attributes are written to "labels".
+	//
+	// References:
+	//   https://www.elastic.co/guide/en/ecs/current/index.html
+	FileHandlerECSFormat FileHandlerFormat = "ecs"
+
+	// FileHandlerJSONFormat outputs messages in the same JSON format as [slog.JSONHandler] using [NewJSONEncoder].
+	//
+	// References:
+	//   https://pkg.go.dev/log/slog#JSONHandler
+	FileHandlerJSONFormat FileHandlerFormat = "json"
+
+	// FileHandlerLEEFFormat outputs messages in the IBM QRadar Log Event Extended Format (LEEF) version 2.0.
+	//
+	// The header and attribute keys are configured using the SIEM options in [FileHandlerOptions].
+	//
+	// References:
+	//   https://www.ibm.com/docs/en/dsm?topic=leef-overview
+	FileHandlerLEEFFormat FileHandlerFormat = "leef"
+
+	// FileHandlerLogfmtFormat outputs messages as key=value pairs in logfmt format.
+	//
+	// Nested groups are written using dotted keys (eg: "group.key=value").
+	//
+	// References:
+	//   https://brandur.org/logfmt
+	FileHandlerLogfmtFormat FileHandlerFormat = "logfmt"
+
+	// FileHandlerMsgpackFormat outputs messages as MessagePack maps using [NewMsgpackEncoder].
+	//
+	// Records are structured the same way as with [FileHandlerJSONFormat] and are written one after the other with no
+	// separator.
+	//
+	// References:
+	//   https://github.com/msgpack/msgpack/blob/master/spec.md
+	FileHandlerMsgpackFormat FileHandlerFormat = "msgpack"
+
+	// FileHandlerTSVFormat outputs messages as rows of tab-separated values.
+	//
+	// The columns and header row are configured using the columnar options in [FileHandlerOptions].
+	FileHandlerTSVFormat FileHandlerFormat = "tsv"
+
+	// FileHandlerW3CFormat outputs messages in the W3C Extended Log File Format.
+	//
+	// The fields and #Software directive are configured using the W3C options in [FileHandlerOptions]. The #Version,
+	// #Date and #Fields directives are written to the beginning of the log file when it is empty.
+	//
+	// References:
+	//   https://www.w3.org/TR/WD-logfile.html
+	FileHandlerW3CFormat FileHandlerFormat = "w3c"
+)
+
 const (
 	// FileHandlerType is the type for a [FileHandler].
 	//
@@ -61,6 +148,17 @@ var (
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#FileHandlerOptions
 	DefaultFileHandlerAutoCreateLogFileParent = true
 
+	// DefaultFileHandlerBufferSlots is the default number of records which can be waiting to be copied into the
+	// buffer when buffering is enabled before logging blocks.
+	//
+	// This value is used when the buffer slots in [FileHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#FileHandlerOptions
+	DefaultFileHandlerBufferSlots = 1024
+
 	// DefaultFileHandlerDirMode is the mode that will be used to create any parent directories of the log file if
 	// parent directory creation is enabled or if the auto chmod feature is enabled.
 	//
@@ -99,6 +197,16 @@ var (
 	//   https://pkg.go.dev/go.innotegrity.dev/types#Path.FSpath
 	DefaultFileHandlerFileName = "app.log"
 
+	// DefaultFileHandlerFormat is the default output format to use for the handler.
+	//
+	// This value is used when the format in [FileHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#FileHandlerOptions
+	DefaultFileHandlerFormat = FileHandlerJSONFormat
+
 	// DefaultFileHandlerLogFolders is a list of possible folders where the log file can be written to. The first
 	// folder in the list which allows for the successful creation of the log file will be used.
 	//
@@ -121,8 +229,15 @@ var (
 	DefaultFileHandlerLogLevel = slog.LevelInfo
 )
 
+// FileHandlerFormat is a pre-defined output format for the log file.
+type FileHandlerFormat string
+
 // FileHandlerOptions holds the options for a [FileHandler].
 type FileHandlerOptions struct {
+	// CommonHandlerOptions holds the level, caller, error handler and attribute replacement options shared with
+	// other handlers.
+	xlog.CommonHandlerOptions
+
 	// BufferSize indicates the size (in bytes) of the buffer to use before flushing records to the file.
 	//
 	// The default behavior is to disable buffering.
@@ -131,6 +246,43 @@ type FileHandlerOptions struct {
 	// to 0.
 	BufferSize types.Size `json:"buffer_size"`
 
+	// BufferSlots is the maximum number of records which can be waiting to be copied into the buffer before logging
+	// blocks. This option is ignored if buffering is disabled.
+	//
+	// The number is rounded up to a power of 2. Each slot keeps the memory of the last record it held if the record
+	// was at most 4KB, so the memory used by the waiting records is bounded by the number of slots multiplied by 4KB
+	// (4MB with the default setting) plus the size of any larger records currently waiting.
+	//
+	// The default behavior is defined by the default buffer slots setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	BufferSlots int `json:"buffer_slots,omitempty"`
+
+	// Clock is the source of the current time used to set the time of records according to the TimestampPolicy.
+	//
+	// The default behavior is defined by the default clock defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Clock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultClock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	Clock xlog.Clock `json:"-"`
+
+	// Columnar holds the options used when the output format is [FileHandlerCSVFormat] or [FileHandlerTSVFormat].
+	//
+	// The default behavior is defined by the default columnar settings defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, all of its members
+	// will be set to their zero values.
+	Columnar ColumnarFormatOptions `json:"columnar"`
+
 	// Compress indicates whether or not to compress rotated log files using gzip.
 	//
 	// The default behavior is to disable compression.
@@ -139,20 +291,67 @@ type FileHandlerOptions struct {
 	// to false.
 	Compress bool `json:"compress"`
 
-	// ErrorHandler is a function that's called to process any internal errors that may occur when a message is
-	// processed by the underlying handler.
+	// DeduplicateKeys indicates whether or not to remove duplicate attribute keys from log messages.
+	//
+	// When enabled, attributes added to the handler and attributes in each record are combined and only the last
+	// value for each key within the same group is logged. See [xlog.NewDedupHandler] for details.
+	//
+	// The default behavior is to log every attribute, even if its key is repeated.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewDedupHandler
+	DeduplicateKeys bool `json:"deduplicate_keys"`
+
+	// Encoder is a custom encoder used to format messages.
+	//
+	// When set, messages are formatted using this encoder and the Format, Columnar, SIEM and W3C options are
+	// ignored. The encoder's own options control whether or not the caller is included and how attributes are replaced.
 	//
-	// The default behavior is to ignore these errors.
+	// The default behavior is to format messages using the configured format.
 	//
 	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
 	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
 	// set this value from your application, if desired.
 	//
 	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Encoder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ErrorHandler xlog.ErrorHandlerFn `json:"-"`
+	Encoder xlog.Encoder `json:"-"`
+
+	// EncryptionKey is the public key (an age X25519 recipient beginning with "age1") used to encrypt rotated log
+	// files.
+	//
+	// When set, rotated log files are compressed (if enabled) and then encrypted into files with an ".age" extension
+	// and the unencrypted files are removed. MaxAge and MaxCount are applied to the encrypted files. Use the
+	// [DecryptFileHandlerArchive] function to decrypt the files with the matching private key.
+	//
+	// Any errors that occur while processing rotated files are passed to the ErrorHandler with a nil record.
+	//
+	// The default behavior is to not encrypt rotated log files.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://age-encryption.org
+	EncryptionKey string `json:"encryption_key"`
+
+	// ExpandErrors indicates whether or not to expand attributes holding an [xerrors.Error] into a group holding its
+	// code, message, attributes, stack trace and wrapped errors using [ExpandErrorAttr].
+	//
+	// This value only applies to the CBOR, ECS, JSON and MessagePack formats. The attribute is expanded after
+	// ReplaceAttr is called.
+	//
+	// The default behavior is to log the string returned by the error's Error function.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	ExpandErrors bool `json:"expand_errors"`
 
 	// File is the output path for the file.
 	//
@@ -171,21 +370,41 @@ type FileHandlerOptions struct {
 	//	 - Owner will be -1.
 	File types.Path `json:"file"`
 
-	// IncludeCaller indicates whether or not to include the caller in log messages.
+	// FlattenGroups indicates whether or not to flatten nested groups into attributes with dotted keys (eg:
+	// "request.headers.host") for sinks which do not handle nested JSON objects well.
+	//
+	// Groups are flattened before the record is formatted, so ReplaceAttr sees the flattened keys. The logfmt format
+	// already writes groups as dotted keys, so this value mostly affects the ECS and JSON formats.
 	//
-	// The default behavior is to not include caller information.
+	// The default behavior is to write groups as nested objects.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
 	// to false.
-	IncludeCaller bool `json:"include_caller"`
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultFlattenSeparator
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFlattenHandler
+	FlattenGroups bool `json:"flatten_groups"`
 
-	// Level is the minimum level at which to log messages.
+	// Format stores the output format for the handler.
 	//
-	// The default behavior is defined by the default level setting defined in the package.
+	// The default behavior is defined by the default format setting defined in the package.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to nil.
-	Level *slog.LevelVar `json:"level"`
+	// to an empty string.
+	Format FileHandlerFormat `json:"format"`
+
+	// Humanize indicates whether or not to render durations, sizes and byte counts in human-readable units (eg:
+	// "350ms" or "1.2 MiB") using [HumanizeAttr].
+	//
+	// This value only applies to the logfmt format, so the other formats always hold the raw numeric values. The
+	// attribute is humanized after ReplaceAttr is called.
+	//
+	// The default behavior is to write raw values.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Humanize bool `json:"humanize"`
 
 	// MaxAge is the maximum number of days to retain old log files based on the
 	// timestamp encoded in their filename.
@@ -207,13 +426,15 @@ type FileHandlerOptions struct {
 	// to 0.
 	MaxCount int `json:"max_count,omitempty"`
 
-	// MaxLevel is the maximum level at which to log messages.
+	// MaxRecordBytes is the maximum size (in bytes) of each encoded record.
 	//
-	// The default behavior is to disable any maximum log message level.
+	// Records which are larger are made to fit using the RecordSizeStrategy.
+	//
+	// The default behavior is to not limit the size of records.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to nil.
-	MaxLevel *slog.LevelVar `json:"max_level,omitempty"`
+	// to 0.
+	MaxRecordBytes types.Size `json:"max_record_bytes,omitempty"`
 
 	// MaxSize is the maximum size in megabytes of the log file before it gets rotated.
 	//
@@ -223,39 +444,98 @@ type FileHandlerOptions struct {
 	// to 0.
 	MaxSize int `json:"max_size,omitempty"`
 
-	// ReplaceAttr is called to rewrite each non-group attribute before it is logged.
+	// RecordSizeStrategy is the strategy used to make records which are larger than MaxRecordBytes fit.
 	//
-	// The attribute's value has been resolved (see [slog.Value.Resolve]). If ReplaceAttr returns a zero Attr, the
-	// attribute is discarded.
+	// The default behavior is defined by the default record size strategy defined in the package.
 	//
-	// The built-in attributes with keys [slog.TimeKey], [slog.LevelKey], [slog.SourceKey], and [slog.MessageKey]
-	// are passed to this function, except that time is omitted if zero, and source is omitted if IncludeCaller is
-	// false.
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	RecordSizeStrategy RecordSizeStrategy `json:"record_size_strategy,omitempty"`
+
+	// Retention holds the options for the separate files to which records are written for each retention class,
+	// keyed by the class (eg: [xlog.RetentionShort]).
 	//
-	// The default behavior is to not replace any attributes.
+	// The retention class of each record is determined by [xlog.RecordRetention]. Records whose class has no entry
+	// are written to the main log file. Each class's file is rotated and removed according to its own MaxAge and
+	// MaxCount, but otherwise shares the settings of the main log file.
 	//
-	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
-	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
-	// set this value from your application, if desired.
+	// The default behavior is to write all records to the main log file.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
 	//
 	// References:
-	//   https://pkg.go.dev/log/slog#TimeKey
-	//   https://pkg.go.dev/log/slog#LevelKey
-	//   https://pkg.go.dev/log/slog#SourceKey
-	//   https://pkg.go.dev/log/slog#MessageKey
-	//   https://pkg.go.dev/log/slog#HandlerOptions
-	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
-	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
-	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr `json:"-"`
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RecordRetention
+	Retention map[string]FileRetentionOptions `json:"retention,omitempty"`
+
+	// SIEM holds the options used when the output format is [FileHandlerCEFFormat] or [FileHandlerLEEFFormat].
+	//
+	// The default behavior is defined by the default SIEM settings defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, all of its members
+	// will be set to their zero values.
+	SIEM SIEMFormatOptions `json:"siem"`
+
+	// Sanitize indicates whether or not to fix attribute values which would otherwise produce invalid output (eg:
+	// invalid UTF-8, NaN floats or cyclic structures) using [SanitizeAttr].
+	//
+	// Each fix is reported to the ErrorHandler, if one is set, as an [xlog.MarshalError] with a nil record. Values are
+	// sanitized after ReplaceAttr is called.
+	//
+	// The default behavior is to write values as they are.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Sanitize bool `json:"sanitize"`
+
+	// SensitiveKeys holds additional patterns, as accepted by [path.Match], of the attribute keys whose values are
+	// masked by the handler, in addition to the default sensitive keys defined in the package.
+	//
+	// Patterns are matched against the lowercase key of each attribute, without the keys of its groups. Values are
+	// masked after ReplaceAttr is called.
+	//
+	// The default behavior is to only mask the default sensitive keys.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultSensitiveKeys
+	SensitiveKeys []string `json:"sensitive_keys,omitempty"`
+
+	// TimestampPolicy defines how the time of each record is treated (eg: whether records with a zero time are given
+	// the current time from the Clock, dropped or logged without a time).
+	//
+	// The default behavior is defined by the default timestamp policy defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultTimestampPolicy
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimestampPolicy
+	TimestampPolicy xlog.TimestampPolicy `json:"timestamp_policy,omitempty"`
+
+	// W3C holds the options used when the output format is [FileHandlerW3CFormat].
+	//
+	// The default behavior is defined by the default W3C settings defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, all of its members
+	// will be set to their zero values.
+	W3C W3CFormatOptions `json:"w3c"`
 }
 
 // jsonFileHandlerOptions is an alternate form of [FileHandlerOptions] that is used during unmarshalling to prevent
 // infinite recursion.
 type jsonFileHandlerOptions struct {
-	BufferSize types.Size `json:"buffer_size"`
-	Compress   bool       `json:"compress"`
-	File       struct {
+	BufferSize      types.Size            `json:"buffer_size"`
+	BufferSlots     int                   `json:"buffer_slots"`
+	Columnar        ColumnarFormatOptions `json:"columnar"`
+	Compress        bool                  `json:"compress"`
+	DeduplicateKeys bool                  `json:"deduplicate_keys"`
+	EncryptionKey   string                `json:"encryption_key"`
+	ExpandErrors    bool                  `json:"expand_errors"`
+	File            struct {
 		AutoChmod        *bool           `json:"auto_chmod"`
 		AutoChown        *bool           `json:"auto_chown"`
 		AutoCreateParent *bool           `json:"auto_create_parent"`
@@ -265,12 +545,20 @@ type jsonFileHandlerOptions struct {
 		Group            *types.GroupID  `json:"group"`
 		Owner            *types.UserID   `json:"owner"`
 	} `json:"file"`
-	IncludeCaller bool   `json:"include_caller"`
-	Level         string `json:"level"`
-	MaxAge        int    `json:"max_age"`
-	MaxCount      int    `json:"max_count"`
-	MaxLevel      string `json:"max_level"`
-	MaxSize       int    `json:"max_size"`
+	FlattenGroups      bool                            `json:"flatten_groups"`
+	Format             string                          `json:"format"`
+	Humanize           bool                            `json:"humanize"`
+	MaxAge             int                             `json:"max_age"`
+	MaxCount           int                             `json:"max_count"`
+	MaxRecordBytes     types.Size                      `json:"max_record_bytes"`
+	MaxSize            int                             `json:"max_size"`
+	RecordSizeStrategy string                          `json:"record_size_strategy"`
+	Retention          map[string]FileRetentionOptions `json:"retention"`
+	SIEM               SIEMFormatOptions               `json:"siem"`
+	Sanitize           bool                            `json:"sanitize"`
+	SensitiveKeys      []string                        `json:"sensitive_keys"`
+	TimestampPolicy    string                          `json:"timestamp_policy"`
+	W3C                W3CFormatOptions                `json:"w3c"`
 }
 
 // UnmarshalJSON decodes the JSON-encoded data into the current object.
@@ -279,24 +567,22 @@ func (o *FileHandlerOptions) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &opts); err != nil {
 		return err
 	}
+	if err := o.CommonHandlerOptions.UnmarshalJSON(data); err != nil {
+		return err
+	}
 
-	// validate the log level(s)
+	// validate the format
 	//
-	// note that we purposely leave the level nil here if it's not set so that it can be set when the handler
+	// note that we purposely leave the format empty here if it's not set so that it can be set when the handler
 	// is created or overridden by the calling application
-	if opts.Level != "" {
-		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
-			return fmt.Errorf("failed to parse level '%s' for console handler: %s", opts.Level, err.Error())
-		}
-		o.Level = &level
-	}
-	if opts.MaxLevel != "" {
-		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.MaxLevel)); err != nil {
-			return fmt.Errorf("failed to parse max level '%s' for console handler: %s", opts.MaxLevel, err.Error())
-		}
-		o.MaxLevel = &level
+	format := FileHandlerFormat(strings.TrimSpace(strings.ToLower(opts.Format)))
+	switch format {
+	case FileHandlerCBORFormat, FileHandlerCEFFormat, FileHandlerCSVFormat, FileHandlerECSFormat,
+		FileHandlerJSONFormat, FileHandlerLEEFFormat, FileHandlerLogfmtFormat, FileHandlerMsgpackFormat,
+		FileHandlerTSVFormat, FileHandlerW3CFormat, "":
+		o.Format = format
+	default:
+		return fmt.Errorf("%s: invalid format for file handler", opts.Format)
 	}
 
 	// configure file defaults
@@ -344,17 +630,139 @@ func (o *FileHandlerOptions) UnmarshalJSON(data []byte) error {
 		o.File.Owner = -1
 	}
 
+	// validate the record size strategy
+	strategy := RecordSizeStrategy(strings.TrimSpace(strings.ToLower(opts.RecordSizeStrategy)))
+	if !strategy.IsValid() {
+		return fmt.Errorf("%s: invalid record size strategy for file handler", opts.RecordSizeStrategy)
+	}
+	o.RecordSizeStrategy = strategy
+
+	// validate the timestamp policy
+	policy := xlog.TimestampPolicy(strings.TrimSpace(strings.ToLower(opts.TimestampPolicy)))
+	if !policy.IsValid() {
+		return fmt.Errorf("%s: invalid timestamp policy for file handler", opts.TimestampPolicy)
+	}
+	o.TimestampPolicy = policy
+
 	// copy remaining options
 	o.BufferSize = opts.BufferSize
+	o.BufferSlots = opts.BufferSlots
+	o.Columnar = opts.Columnar
 	o.Compress = opts.Compress
-	o.IncludeCaller = opts.IncludeCaller
+	o.DeduplicateKeys = opts.DeduplicateKeys
+	o.EncryptionKey = opts.EncryptionKey
+	o.ExpandErrors = opts.ExpandErrors
+	o.FlattenGroups = opts.FlattenGroups
+	o.Humanize = opts.Humanize
 	o.MaxAge = opts.MaxAge
 	o.MaxCount = opts.MaxCount
+	o.MaxRecordBytes = opts.MaxRecordBytes
 	o.MaxSize = opts.MaxSize
+	o.Retention = opts.Retention
+	o.SIEM = opts.SIEM
+	o.Sanitize = opts.Sanitize
+	o.SensitiveKeys = opts.SensitiveKeys
+	o.W3C = opts.W3C
 
 	return nil
 }
 
+// Validate checks the options for problems, returning a single error describing all of them.
+//
+// Values which are not set are not treated as problems since they are replaced by defaults when the handler is
+// created.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o FileHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	v.checkNonNegative("buffer_size", int64(o.BufferSize))
+	v.checkNonNegative("buffer_slots", int64(o.BufferSlots))
+	for i, column := range o.Columnar.Columns {
+		if strings.TrimSpace(column) == "" {
+			v.addf(fmt.Sprintf("columnar.columns[%d]", i), "column name cannot be empty")
+		}
+	}
+	if o.EncryptionKey != "" {
+		if _, err := age.ParseX25519Recipient(strings.TrimSpace(o.EncryptionKey)); err != nil {
+			v.addf("encryption_key", "failed to parse encryption key: %s", err.Error())
+		}
+	}
+	switch o.Format {
+	case FileHandlerCBORFormat, FileHandlerCEFFormat, FileHandlerCSVFormat, FileHandlerECSFormat,
+		FileHandlerJSONFormat, FileHandlerLEEFFormat, FileHandlerLogfmtFormat, FileHandlerMsgpackFormat,
+		FileHandlerTSVFormat, FileHandlerW3CFormat, "":
+	default:
+		if o.Encoder == nil {
+			v.addf("format", "invalid format '%s'", o.Format)
+		}
+	}
+	v.checkLevels(o.Level, o.MaxLevel)
+	v.checkNonNegative("max_age", int64(o.MaxAge))
+	v.checkNonNegative("max_count", int64(o.MaxCount))
+	v.checkNonNegative("max_record_bytes", int64(o.MaxRecordBytes))
+	v.checkNonNegative("max_size", int64(o.MaxSize))
+	if !o.RecordSizeStrategy.IsValid() {
+		v.addf("record_size_strategy", "invalid record size strategy '%s'", o.RecordSizeStrategy)
+	}
+	for _, class := range slices.Sorted(maps.Keys(o.Retention)) {
+		if class == "" {
+			v.addf("retention", "retention class cannot be empty")
+			continue
+		}
+		v.checkNonNegative(fmt.Sprintf("retention.%s.max_age", class), int64(o.Retention[class].MaxAge))
+		v.checkNonNegative(fmt.Sprintf("retention.%s.max_count", class), int64(o.Retention[class].MaxCount))
+	}
+	v.checkPatterns("sensitive_keys", o.SensitiveKeys)
+	if !o.TimestampPolicy.IsValid() {
+		v.addf("timestamp_policy", "invalid timestamp policy '%s'", o.TimestampPolicy)
+	}
+	for i, field := range o.W3C.Fields {
+		if field == "" || strings.ContainsFunc(field, unicode.IsSpace) {
+			v.addf(fmt.Sprintf("w3c.fields[%d]", i), "invalid field identifier '%s'", field)
+		}
+	}
+	return v.err(FileHandlerType)
+}
+
+// clone returns a copy of the options which shares no maps or slices with the original.
+//
+// Level variables are intentionally shared so that the level of a handler can be changed after it is created.
+func (o FileHandlerOptions) clone() FileHandlerOptions {
+	o.Columnar = o.Columnar.clone()
+	o.Retention = maps.Clone(o.Retention)
+	o.SensitiveKeys = slices.Clone(o.SensitiveKeys)
+	o.SIEM = o.SIEM.clone()
+	o.W3C = o.W3C.clone()
+	return o
+}
+
+// FileRetentionOptions holds the options for the file to which a [FileHandler] writes records of a single retention
+// class.
+type FileRetentionOptions struct {
+	// MaxAge is the maximum number of days to retain old log files for the class based on the timestamp encoded in
+	// their filename.
+	//
+	// The default behavior is not to remove old log files based on age.
+	MaxAge int `json:"max_age,omitempty"`
+
+	// MaxCount is the maximum number of old log files to retain for the class.
+	//
+	// The default behavior is to retain all old log files (though MaxAge may still cause them to get deleted).
+	MaxCount int `json:"max_count,omitempty"`
+
+	// Path is the path of the log file for the class.
+	//
+	// The default behavior is to insert the class before the extension of the main log file (eg: "app.short.log").
+	Path string `json:"path,omitempty"`
+}
+
+// ensure [FileHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = FileHandlerOptions{}
+
+// ensure [FileHandler] implements [xlog.AsyncHandler] interface.
+var _ xlog.AsyncHandler = &FileHandler{}
+
 // ensure [FileHandler] implements [xlog.ExtendedHandler] interface.
 var _ xlog.ExtendedHandler = &FileHandler{}
 
@@ -362,12 +770,35 @@ var _ xlog.ExtendedHandler = &FileHandler{}
 var _ xlog.LevelVarHandler = &FileHandler{}
 
 // FileHandler is a handler that writes messages to a file with optional buffering and file rotation.
+//
+// The options are copied when the handler is created and are never modified afterward. Handlers derived using
+// WithAttrs or WithGroup share the options, level variables and open file with the handler they were derived from,
+// so changing a level variable or closing any one of them affects all of them.
 type FileHandler struct {
 	// unexported variables
-	bufferedWriter *atomicWriter      // buffer writer
-	fileWriter     *lumberjack.Logger // lumberjack logger
-	handler        slog.Handler       // underlying handler used for output
-	options        FileHandlerOptions // handler options
+	classHandlers map[string]slog.Handler // underlying handlers used for output for each retention class
+	grouped       bool                    // whether or not a group has been opened using WithGroup
+	handler       slog.Handler            // underlying handler used for output
+	options       FileHandlerOptions      // immutable handler options
+	retention     string                  // retention class added using WithAttrs, if any
+	state         *fileHandlerState       // shared writers
+}
+
+// fileHeaderEncoder is implemented by encoders whose format begins each log file with a header (eg: CSV column names
+// or W3C directives).
+type fileHeaderEncoder interface {
+	// writeHeader should write the header to buf or write nothing if no header is desired.
+	writeHeader(buf *bytes.Buffer) error
+}
+
+// fileHandlerState holds the shared, mutable state for a handler and its descendants. This includes the chain of
+// writers used to write to the file.
+type fileHandlerState struct {
+	archiveWriter  *archiveWriter               // rotated file encryption writer
+	bufferedWriter *asyncWriter                 // buffer writer
+	classes        map[string]*fileHandlerState // writers for the file of each retention class
+	filename       string                       // absolute path of the active log file, while it is open
+	fileWriter     *lumberjack.Logger           // lumberjack logger
 }
 
 // NewFileHandler creates a new [FileHandler] object with the given options.
@@ -375,9 +806,8 @@ type FileHandler struct {
 // This function may return an error with any of the following codes:
 //   - [xlog.OptionsValidationError]: one or more options are invalid
 func NewFileHandler(options FileHandlerOptions) (*FileHandler, xerrors.Error) {
-	var writer io.Writer
 	h := &FileHandler{
-		options: options,
+		options: options.clone(),
 	}
 
 	// ensure a minimum level is set
@@ -387,7 +817,23 @@ func NewFileHandler(options FileHandlerOptions) (*FileHandler, xerrors.Error) {
 		h.options.Level = &level
 	}
 
-	// set file defaults
+	// validate the format before any files are created
+	if h.options.Format == "" {
+		h.options.Format = DefaultFileHandlerFormat
+	}
+	switch h.options.Format {
+	case FileHandlerCBORFormat, FileHandlerCEFFormat, FileHandlerCSVFormat, FileHandlerECSFormat,
+		FileHandlerJSONFormat, FileHandlerLEEFFormat, FileHandlerLogfmtFormat, FileHandlerMsgpackFormat,
+		FileHandlerTSVFormat, FileHandlerW3CFormat:
+	default:
+		return nil, xerrors.Newf(xlog.OptionsValidationError, "%s: invalid file handler format",
+			h.options.Format).WithAttr("format", h.options.Format)
+	}
+
+	// set buffer and file defaults
+	if h.options.BufferSlots == 0 {
+		h.options.BufferSlots = DefaultFileHandlerBufferSlots
+	}
 	if h.options.File.DirMode == 0 {
 		h.options.File.DirMode = DefaultFileHandlerDirMode
 	}
@@ -401,69 +847,88 @@ func NewFileHandler(options FileHandlerOptions) (*FileHandler, xerrors.Error) {
 		h.options.File.Group = types.GroupID(os.Getgid())
 	}
 
-	// construct the lumberjack logger for file rotation
-	filename, xerr := createLogFile(h.options.File)
+	// parse the encryption key before any files are created
+	var recipient *age.X25519Recipient
+	if h.options.EncryptionKey != "" {
+		var err error
+		recipient, err = age.ParseX25519Recipient(strings.TrimSpace(h.options.EncryptionKey))
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.OptionsValidationError, err, "failed to parse encryption key: %s",
+				err.Error())
+		}
+	}
+
+	// open the main log file
+	handler, state, xerr := h.openOutput(h.options.File, h.options.MaxAge, h.options.MaxCount, recipient)
 	if xerr != nil {
 		return nil, xerr
 	}
-	filename, err := filepath.Abs(filename)
-	if err != nil {
-		return nil, xerrors.Wrapf(xlog.OptionsValidationError, err,
-			"failed to convert log file path '%s' to an absolute path: %s", filename, err.Error()).
-			WithAttr("log_file", filename)
-	}
-	h.options.File.FSPath = filename
-	h.fileWriter = &lumberjack.Logger{
-		Compress:   h.options.Compress,
-		Filename:   filename,
-		MaxAge:     h.options.MaxAge,
-		MaxBackups: h.options.MaxCount,
-		MaxSize:    h.options.MaxSize,
-	}
-	writer = h.fileWriter
+	h.handler = handler
+	h.state = state
+	h.options.File.FSPath = state.fileWriter.Filename
 
-	// construct the buffered writer, if enabled
-	if h.options.BufferSize > 0 {
-		h.bufferedWriter = newAtomicWriter(h.fileWriter, int(h.options.BufferSize))
-		writer = h.bufferedWriter
+	// open the log file for each retention class
+	if len(h.options.Retention) > 0 {
+		h.classHandlers = make(map[string]slog.Handler, len(h.options.Retention))
+		h.state.classes = make(map[string]*fileHandlerState, len(h.options.Retention))
+	}
+	for _, class := range slices.Sorted(maps.Keys(h.options.Retention)) {
+		retention := h.options.Retention[class]
+		path := h.options.File
+		path.FSPath = retention.Path
+		if path.FSPath == "" {
+			ext := filepath.Ext(h.options.File.FSPath)
+			path.FSPath = strings.TrimSuffix(h.options.File.FSPath, ext) + "." + class + ext
+		}
+		handler, state, xerr := h.openOutput(path, retention.MaxAge, retention.MaxCount, recipient)
+		if xerr != nil {
+			_ = h.Close()
+			return nil, xerr.WithAttr("retention", class)
+		}
+		retention.Path = state.fileWriter.Filename
+		h.options.Retention[class] = retention
+		h.classHandlers[class] = handler
+		h.state.classes[class] = state
 	}
-
-	// create the JSON handler for the output
-	h.handler = slog.NewJSONHandler(writer, &slog.HandlerOptions{
-		AddSource:   h.options.IncludeCaller,
-		Level:       h.options.Level,
-		ReplaceAttr: h.options.ReplaceAttr,
-	})
 	return h, nil
 }
 
-// ChildHandlers returns the underlying [slog.Handler] which actually performs the logging.
+// Async returns whether or not records are buffered before being written to the file, in which case they are written
+// by a background goroutine.
+func (h *FileHandler) Async() bool {
+	return h.options.BufferSize > 0
+}
+
+// ChildHandlers returns the underlying [slog.Handler] which actually performs the logging followed by the handlers
+// for each retention class, sorted by class.
 func (h *FileHandler) ChildHandlers() []slog.Handler {
-	return []slog.Handler{h.handler}
+	children := []slog.Handler{h.handler}
+	for _, class := range slices.Sorted(maps.Keys(h.classHandlers)) {
+		children = append(children, h.classHandlers[class])
+	}
+	return children
 }
 
 // Close flushes any data in the buffer to the file and then closes the file handle.
+//
+// If encryption is enabled, any rotated files which have not yet been encrypted are encrypted before returning. The
+// files for each retention class are closed as well.
 func (h *FileHandler) Close() error {
-	if h.bufferedWriter != nil {
-		if err := h.bufferedWriter.Flush(); err != nil {
-			return err
-		}
+	var errs []error
+	if err := h.state.close(); err != nil {
+		errs = append(errs, err)
 	}
-	if h.fileWriter != nil {
-		if err := h.fileWriter.Close(); err != nil {
-			return err
+	for _, class := range slices.Sorted(maps.Keys(h.state.classes)) {
+		if err := h.state.classes[class].close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // Enabled returns true if the handler should handle the message or false if it should not.
 func (h *FileHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	handlerLevel := h.options.Level.Level()
-	if h.options.MaxLevel == nil {
-		return level >= handlerLevel
-	}
-	return level >= handlerLevel && level <= handlerLevel
+	return h.options.Enabled(level)
 }
 
 // GetLevelVar returns the handler's [slog.LevelVar] for manipulating the minimum logging level.
@@ -477,17 +942,35 @@ func (h *FileHandler) GetMaxLevelVar() *slog.LevelVar {
 }
 
 // Handle processes the record and handles logging it.
+//
+// Records are written to the file for their retention class, if one is configured, or to the main log file.
 func (h *FileHandler) Handle(ctx context.Context, r slog.Record) error {
-	err := h.handler.Handle(ctx, r)
-	if err != nil && h.options.ErrorHandler != nil {
-		err = h.options.ErrorHandler(ctx, err, &r)
+	r, ok := xlog.ApplyTimestampPolicy(r, h.options.TimestampPolicy, h.options.Clock)
+	if !ok {
+		return nil
+	}
+
+	handler := h.handler
+	if len(h.classHandlers) > 0 {
+		if classHandler, ok := h.classHandlers[xlog.RecordRetention(ctx, r, h.retention)]; ok {
+			handler = classHandler
+		}
+	}
+	err := handler.Handle(ctx, r)
+	if err != nil {
+		logHandleError(ctx, FileHandlerType, err, &r)
+		if h.options.ErrorHandler != nil {
+			err = h.options.ErrorHandler(ctx, err, &r)
+		}
 	}
 	return err
 }
 
-// Options returns the handler's options.
+// Options returns a copy of the handler's options.
+//
+// Modifying the returned options has no effect on the handler, except through the shared level variables.
 func (h *FileHandler) Options() any {
-	return h.options
+	return h.options.clone()
 }
 
 // Type returns the type of the handler.
@@ -498,8 +981,12 @@ func (h *FileHandler) Type() string {
 // WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the
 // given attributes.
 func (h *FileHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	clone := h.clone()
-	clone.handler = h.handler.WithAttrs(attrs)
+	clone := h.clone(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+	if class := xlog.RetentionFromAttrs(attrs); class != "" && !h.grouped {
+		clone.retention = class
+	}
 	return clone
 }
 
@@ -509,19 +996,171 @@ func (h *FileHandler) WithGroup(name string) slog.Handler {
 		return h
 	}
 
-	clone := h.clone()
-	clone.handler = h.handler.WithGroup(name)
+	clone := h.clone(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+	clone.grouped = true
 	return clone
 }
 
-// clone creates a copy of current handler.
-func (h *FileHandler) clone() *FileHandler {
-	return &FileHandler{
-		bufferedWriter: h.bufferedWriter,
-		fileWriter:     h.fileWriter,
-		handler:        h.handler,
-		options:        h.options,
+// clone creates a copy of current handler whose underlying handlers have been derived using the given function.
+func (h *FileHandler) clone(derive func(slog.Handler) slog.Handler) *FileHandler {
+	clone := &FileHandler{
+		grouped:   h.grouped,
+		handler:   derive(h.handler),
+		options:   h.options,
+		retention: h.retention,
+		state:     h.state,
+	}
+	if h.classHandlers != nil {
+		clone.classHandlers = make(map[string]slog.Handler, len(h.classHandlers))
+		for class, handler := range h.classHandlers {
+			clone.classHandlers[class] = derive(handler)
+		}
+	}
+	return clone
+}
+
+// openOutput opens the given log file and returns the handler which writes records to it along with the chain of
+// writers used to write to the file.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: the header could not be written to the log file
+//   - [xlog.OptionsValidationError]: the log file could not be opened for writing
+func (h *FileHandler) openOutput(path types.Path, maxAge, maxCount int, recipient *age.X25519Recipient) (
+	slog.Handler, *fileHandlerState, xerrors.Error) {
+	var writer io.Writer
+	state := &fileHandlerState{}
+
+	// construct the lumberjack logger for file rotation
+	filename, xerr := createLogFile(path)
+	if xerr != nil {
+		return nil, nil, xerr
+	}
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, nil, xerrors.Wrapf(xlog.OptionsValidationError, err,
+			"failed to convert log file path '%s' to an absolute path: %s", filename, err.Error()).
+			WithAttr("log_file", filename)
+	}
+	state.fileWriter = &lumberjack.Logger{
+		Compress:   h.options.Compress,
+		Filename:   filename,
+		MaxAge:     maxAge,
+		MaxBackups: maxCount,
+		MaxSize:    h.options.MaxSize,
+	}
+	writer = state.fileWriter
+
+	// construct the archive writer, if encryption is enabled
+	if recipient != nil {
+		state.archiveWriter = newArchiveWriter(state.fileWriter, recipient, h.options.ErrorHandler)
+		writer = state.archiveWriter
+	}
+
+	// construct the buffered writer, if enabled
+	if h.options.BufferSize > 0 {
+		state.bufferedWriter = newAsyncWriter(writer, int(h.options.BufferSize), h.options.BufferSlots)
+		writer = state.bufferedWriter
+	}
+
+	// create the handler for the output based on the format
+	var handler slog.Handler
+	handlerOptions := &slog.HandlerOptions{
+		AddSource:   h.options.IncludeCaller,
+		Level:       h.options.Level,
+		ReplaceAttr: h.options.ReplaceAttr,
+	}
+	switch h.options.Format {
+	case FileHandlerCBORFormat, FileHandlerECSFormat, FileHandlerJSONFormat, FileHandlerMsgpackFormat:
+		if h.options.ExpandErrors {
+			handlerOptions.ReplaceAttr = replaceAttrWithErrors(handlerOptions.ReplaceAttr)
+		}
+	case FileHandlerLogfmtFormat:
+		if h.options.Humanize {
+			handlerOptions.ReplaceAttr = replaceAttrWithHumanize(handlerOptions.ReplaceAttr)
+		}
+	}
+	if h.options.Sanitize {
+		handlerOptions.ReplaceAttr = replaceAttrWithSanitize(handlerOptions.ReplaceAttr, h.options.ErrorHandler)
+	}
+	handlerOptions.ReplaceAttr = replaceAttrWithMask(handlerOptions.ReplaceAttr, h.options.SensitiveKeys)
+	switch {
+	case h.options.Encoder != nil:
+		handler = newEncoderHandler(writer, h.options.Level, h.options.Encoder)
+	case h.options.Format == FileHandlerCBORFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewCBOREncoder(handlerOptions))
+	case h.options.Format == FileHandlerCEFFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewCEFEncoder(handlerOptions, h.options.SIEM))
+	case h.options.Format == FileHandlerCSVFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewCSVEncoder(handlerOptions, h.options.Columnar))
+	case h.options.Format == FileHandlerECSFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewECSEncoder(handlerOptions))
+	case h.options.Format == FileHandlerJSONFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewJSONEncoder(handlerOptions, ""))
+	case h.options.Format == FileHandlerLEEFFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewLEEFEncoder(handlerOptions, h.options.SIEM))
+	case h.options.Format == FileHandlerLogfmtFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewLogfmtEncoder(handlerOptions))
+	case h.options.Format == FileHandlerMsgpackFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewMsgpackEncoder(handlerOptions))
+	case h.options.Format == FileHandlerTSVFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewTSVEncoder(handlerOptions, h.options.Columnar))
+	case h.options.Format == FileHandlerW3CFormat:
+		handler = newEncoderHandler(writer, h.options.Level, NewW3CEncoder(handlerOptions, h.options.W3C))
+	}
+
+	// write the header to empty files for formats which have one
+	if encoder, ok := handler.(*encoderHandler); ok {
+		if headerEncoder, ok := encoder.encoder.(fileHeaderEncoder); ok {
+			if xerr := writeFileHeader(writer, filename, headerEncoder); xerr != nil {
+				return nil, nil, xerr
+			}
+		}
+	}
+
+	// limit the size of records, if desired
+	if encoder, ok := handler.(*encoderHandler); ok {
+		encoder.maxRecordBytes = int(h.options.MaxRecordBytes)
+		encoder.sizeStrategy = h.options.RecordSizeStrategy
+	}
+
+	// flatten nested groups, if desired
+	if h.options.FlattenGroups {
+		handler = xlog.NewFlattenHandler(handler, xlog.FlattenHandlerOptions{})
+	}
+
+	// remove duplicate attribute keys, if desired
+	if h.options.DeduplicateKeys {
+		handler = xlog.NewDedupHandler(handler)
+	}
+
+	// mark the file as active so that it isn't redacted while it is being written
+	state.filename = filename
+	acquireActiveLogFile(filename)
+	return handler, state, nil
+}
+
+// close flushes any data in the buffer to the file and then closes the file handle.
+func (s *fileHandlerState) close() error {
+	if s.filename != "" {
+		releaseActiveLogFile(s.filename)
+		s.filename = ""
+	}
+	if s.bufferedWriter != nil {
+		if err := s.bufferedWriter.Close(); err != nil {
+			return err
+		}
 	}
+	if s.archiveWriter != nil {
+		return s.archiveWriter.Close()
+	}
+	if s.fileWriter != nil {
+		if err := s.fileWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // createDefaultLogFile attempts to open the default log file for writing.
@@ -578,12 +1217,38 @@ func createLogFile(path types.Path) (string, xerrors.Error) {
 	return path.FSPath, nil
 }
 
+// writeFileHeader writes the header of the given encoder to the writer if the log file is empty.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: the header could not be written
+func writeFileHeader(w io.Writer, filename string, encoder fileHeaderEncoder) xerrors.Error {
+	if info, err := os.Stat(filename); err == nil && info.Size() > 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := encoder.writeHeader(&buf); err != nil {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to encode header: %s", err.Error()).
+			WithAttr("log_file", filename)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to write header to log file '%s': %s",
+			filename, err.Error()).WithAttr("log_file", filename)
+	}
+	return nil
+}
+
 // fileHandlerBuilder is used to build the handler from configuration options.
 type fileHandlerBuilder struct {
 	// unexported variables
 	options FileHandlerOptions // handler options
 }
 
+// ensure [fileHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &fileHandlerBuilder{}
+
 // NewFileHandlerBuilderFromConfig creates a new [HandlerBuilder] and validates the given options, setting
 // and default values as necessary.
 //
@@ -608,17 +1273,9 @@ func NewFileHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBuild
 //
 // This function may return other errors if the callback function fails and defines its own error values.
 func (b *fileHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
-	if cb != nil {
-		if err := cb(b.Type(), &b.options); err != nil {
-			return nil, err
-		}
-	}
-	h, err := NewFileHandler(b.options)
-	if err != nil {
-		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
-			err.Error())
-	}
-	return h, nil
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
 }
 
 // MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
@@ -649,3 +1306,41 @@ func (b *fileHandlerBuilder) Options() map[string]any {
 func (b *fileHandlerBuilder) Type() string {
 	return FileHandlerType
 }
+
+// Validate checks the options for problems without actually building the handler.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *fileHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options.clone()
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *fileHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewFileHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/types"
+)
+
+var (
+	// DefaultHumanizeByteKeys holds the keys of integer attributes which hold byte counts and are rendered in
+	// human-readable units by [HumanizeAttr].
+	//
+	// This value is used by [HumanizeAttr] and therefore by any handler whose Humanize option is enabled.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#HumanizeAttr
+	DefaultHumanizeByteKeys = []string{"bytes", "bytes_in", "bytes_out", "bytes_read", "bytes_written",
+		"content_length", "size"}
+)
+
+// FormatBytes returns the given number of bytes in human-readable IEC units (eg: "512 B", "1.2 MiB" or "3 GiB").
+//
+// Values of 1 KiB or more are rounded to at most one decimal place.
+func FormatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n > -1024 && n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	value := float64(n)
+	i := -1
+	for (value >= 1024 || value <= -1024) && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + " " + units[i:i+1] + "iB"
+}
+
+// HumanizeAttr is a ReplaceAttr-compatible function (see [slog.HandlerOptions]) which renders attribute values in
+// human-readable units for text formats.
+//
+// [time.Duration] and [types.Duration] values are rendered as durations (eg: "350ms" or "1m30s"), [types.Size] values
+// are rendered using [FormatBytes] and so are integer values whose key is one of the default humanize byte keys
+// defined in the package. Built-in attributes and all other values are returned unchanged.
+//
+// Since the values are replaced with strings, this function should only be used with text formats, leaving the raw
+// numeric values in JSON output.
+func HumanizeAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch attr.Key {
+		case slog.TimeKey, slog.LevelKey, slog.SourceKey, slog.MessageKey:
+			return attr
+		}
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindDuration:
+		attr.Value = slog.StringValue(attr.Value.Duration().String())
+	case slog.KindInt64:
+		if slices.Contains(DefaultHumanizeByteKeys, attr.Key) {
+			attr.Value = slog.StringValue(FormatBytes(attr.Value.Int64()))
+		}
+	case slog.KindUint64:
+		if slices.Contains(DefaultHumanizeByteKeys, attr.Key) && attr.Value.Uint64() <= 1<<63-1 {
+			attr.Value = slog.StringValue(FormatBytes(int64(attr.Value.Uint64())))
+		}
+	case slog.KindAny:
+		switch v := attr.Value.Any().(type) {
+		case types.Duration:
+			attr.Value = slog.StringValue(time.Duration(v).String())
+		case types.Size:
+			attr.Value = slog.StringValue(FormatBytes(int64(v)))
+		}
+	}
+	return attr
+}
+
+// replaceAttrWithHumanize wraps the given ReplaceAttr function so that the attribute it returns is passed through
+// [HumanizeAttr].
+//
+// If next is nil, the humanized attribute is simply returned.
+func replaceAttrWithHumanize(next func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if next != nil {
+			attr = next(groups, attr)
+			if attr.Key == "" {
+				return attr
+			}
+		}
+		return HumanizeAttr(groups, attr)
+	}
+}
@@ -3,7 +3,9 @@ package handlers
 import "go.innotegrity.dev/xlog"
 
 var (
+	_aliases  = map[string]handlerAlias{}
 	_builders map[string]xlog.NewBuilderFromConfigFn
+	_wrappers map[string]WrapperFn
 )
 
 func init() {
@@ -13,6 +15,113 @@ func init() {
 		DiscardHandlerType:        NewDiscardHandlerBuilderFromConfig,
 		FanoutHandlerType:         NewFanoutHandlerBuilderFromConfig,
 		FileHandlerType:           NewFileHandlerBuilderFromConfig,
+		ParquetHandlerType:        NewParquetHandlerBuilderFromConfig,
+		PluginHandlerType:         NewPluginHandlerBuilderFromConfig,
 		SentinelOneHECHandlerType: NewSentinelOneHECHandlerBuilderFromConfig,
 	}
+
+	// register built-in wrappers
+	_wrappers = map[string]WrapperFn{
+		AttrLimitWrapperType:      wrapAttrLimit,
+		BaggageWrapperType:        wrapBaggage,
+		BuildInfoWrapperType:      wrapBuildInfo,
+		BurstWrapperType:          wrapBurst,
+		ConcurrencyWrapperType:    wrapConcurrency,
+		DedupWrapperType:          wrapDedup,
+		FingerprintWrapperType:    wrapFingerprint,
+		FlattenWrapperType:        wrapFlatten,
+		GoroutineAttrsWrapperType: wrapGoroutineAttrs,
+		RateLimitWrapperType:      wrapRateLimit,
+		RequestIDWrapperType:      wrapRequestID,
+		SequenceWrapperType:       wrapSequence,
+		SourceFilterWrapperType:   wrapSourceFilter,
+		SubjectWrapperType:        wrapSubject,
+		TemplateWrapperType:       wrapTemplate,
+		TimeNormalizeWrapperType:  wrapTimeNormalize,
+		TraceWrapperType:          wrapTrace,
+	}
+
+	// register built-in handler option schemas
+	intOrStringSchema := map[string]any{"type": []string{"integer", "string"}}
+	timestampPolicySchema := map[string]any{"enum": []xlog.TimestampPolicy{xlog.TimestampPolicyClock,
+		xlog.TimestampPolicyDrop, xlog.TimestampPolicyKeep, xlog.TimestampPolicyOverride}}
+	_ = RegisterSchema(ConsoleHandlerType, jsonConsoleHandlerOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"color": {"enum": []ConsoleHandlerColor{ConsoleHandlerAlwaysColor, ConsoleHandlerAutoColor,
+				ConsoleHandlerNeverColor}},
+			"format": {"enum": []ConsoleHandlerFormat{ConsoleHandlerECSFormat, ConsoleHandlerJSONFormat,
+				ConsoleHandlerLogfmtFormat, ConsoleHandlerPlaintextFormat, ConsoleHandlerPrettyFormat,
+				ConsoleHandlerPrettyJSONFormat}},
+			"include_caller":     {"type": "boolean"},
+			"level":              levelSchema,
+			"max_attr_length":    {"minimum": 0},
+			"max_level":          levelSchema,
+			"max_message_length": {"minimum": 0},
+			"stderr_level":       levelSchema,
+			"timestamp_policy":   timestampPolicySchema,
+		},
+	})
+	_ = RegisterSchema(DiscardHandlerType, DiscardHandlerOptions{}, SchemaMetadata{})
+	_ = RegisterSchema(FanoutHandlerType, fanoutHandlerBuilderOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"handlers": {"items": map[string]any{"$ref": "#/$defs/handler"}},
+		},
+	})
+	_ = RegisterSchema(FileHandlerType, jsonFileHandlerOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"buffer_size":    intOrStringSchema,
+			"buffer_slots":   {"minimum": 0},
+			"file.dir_mode":  intOrStringSchema,
+			"file.file_mode": intOrStringSchema,
+			"file.group":     intOrStringSchema,
+			"file.owner":     intOrStringSchema,
+			"file.path":      {"type": "string"},
+			"format": {"enum": []FileHandlerFormat{FileHandlerCBORFormat, FileHandlerCEFFormat, FileHandlerCSVFormat,
+				FileHandlerECSFormat, FileHandlerJSONFormat, FileHandlerLEEFFormat, FileHandlerLogfmtFormat,
+				FileHandlerMsgpackFormat, FileHandlerTSVFormat, FileHandlerW3CFormat}},
+			"include_caller":   {"type": "boolean"},
+			"level":            levelSchema,
+			"max_age":          {"minimum": 0},
+			"max_count":        {"minimum": 0},
+			"max_level":        levelSchema,
+			"max_record_bytes": intOrStringSchema,
+			"max_size":         {"minimum": 0},
+			"timestamp_policy": timestampPolicySchema,
+		},
+	})
+	_ = RegisterSchema(ParquetHandlerType, jsonParquetHandlerOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"batch_size":     {"minimum": 0},
+			"flush_interval": intOrStringSchema,
+			"include_caller": {"type": "boolean"},
+			"level":          levelSchema,
+			"max_level":      levelSchema,
+		},
+	})
+	_ = RegisterSchema(PluginHandlerType, jsonPluginHandlerOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"command":          {"minLength": 1},
+			"include_caller":   {"type": "boolean"},
+			"level":            levelSchema,
+			"max_level":        levelSchema,
+			"timeout":          intOrStringSchema,
+			"timestamp_policy": timestampPolicySchema,
+		},
+		Required: []string{"command"},
+	})
+	_ = RegisterSchema(SentinelOneHECHandlerType, jsonSentinelOneHECHandlerOptions{}, SchemaMetadata{
+		Properties: map[string]map[string]any{
+			"buffer_size":        intOrStringSchema,
+			"connect_mode":       {"enum": []ConnectMode{ConnectEager, ConnectLazy}},
+			"include_caller":     {"type": "boolean"},
+			"level":              levelSchema,
+			"max_level":          levelSchema,
+			"max_record_age":     intOrStringSchema,
+			"max_record_bytes":   intOrStringSchema,
+			"reconnect_interval": intOrStringSchema,
+			"send_timeout":       intOrStringSchema,
+			"timestamp_policy":   timestampPolicySchema,
+		},
+		Required: []string{"api_token", "ingest_hostname", "scope"},
+	})
 }
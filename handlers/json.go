@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/xlog"
+)
+
+var (
+	// jsonEncodeStatePool holds the states used to encode records in a single pass.
+	jsonEncodeStatePool = sync.Pool{
+		New: func() any {
+			return &jsonEncodeState{
+				groups: make([]string, 0, 8),
+			}
+		},
+	}
+)
+
+// jsonEncoder encodes records as JSON objects, optionally indented across multiple lines.
+type jsonEncoder struct {
+	// unexported variables
+	indent  string              // string used to indent each level of nesting
+	options slog.HandlerOptions // encoder options
+}
+
+// jsonEncodeState holds the state used to encode a record as a single-line JSON object in a single pass, writing each
+// attribute as soon as it is resolved rather than first collecting the resolved attributes.
+type jsonEncodeState struct {
+	buf     *bytes.Buffer                       // output buffer
+	groups  []string                            // keys of the groups enclosing the current attribute
+	replace func([]string, slog.Attr) slog.Attr // function used to replace attributes, if any
+	sep     bool                                // whether or not a separator is needed before the next member
+}
+
+// NewJSONEncoder creates a new [xlog.Encoder] which encodes records as JSON objects in the same structure as
+// [slog.JSONHandler].
+//
+// If indent is empty, each record is written on a single line. Otherwise, each record is written across multiple
+// lines with each level of nesting indented by indent. Only the AddSource and ReplaceAttr options are used by the
+// encoder.
+//
+// References:
+//
+//	https://pkg.go.dev/log/slog#JSONHandler
+func NewJSONEncoder(options *slog.HandlerOptions, indent string) xlog.Encoder {
+	e := &jsonEncoder{
+		indent: indent,
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a JSON object followed by a newline.
+//
+// Records written on a single line without any open groups take a fast path which writes each attribute directly to
+// the buffer, so that encoding common attribute kinds does not allocate.
+func (e *jsonEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	if e.indent != "" || len(groups) > 0 {
+		all := builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr)
+		all = append(all, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...)
+		appendJSONObject(buf, all, e.indent, 0)
+		buf.WriteByte('\n')
+		return nil
+	}
+
+	s := jsonEncodeStatePool.Get().(*jsonEncodeState)
+	defer putJSONEncodeState(s)
+	s.buf = buf
+	s.replace = e.options.ReplaceAttr
+	buf.WriteByte('{')
+	s.appendBuiltins(r, e.options.AddSource)
+	for _, attr := range attrs {
+		s.appendAttr(attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		s.appendAttr(attr)
+		return true
+	})
+	buf.WriteString("}\n")
+	return nil
+}
+
+// appendAttr resolves the given attribute and writes it to the buffer as a member of the current object.
+//
+// The attribute is resolved, replaced and masked the same way as by [resolveAttrs]. Groups with an empty key are
+// inlined and groups whose attributes are all removed are not written at all.
+func (s *jsonEncodeState) appendAttr(attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() == slog.KindGroup {
+		if IsSensitiveKey(attr.Key, DefaultSensitiveKeys) {
+			s.appendMember(slog.String(attr.Key, DefaultSensitiveValueMask))
+			return
+		}
+		if attr.Key == "" {
+			for _, child := range attr.Value.Group() {
+				s.appendAttr(child)
+			}
+			return
+		}
+
+		// write the group and discard it again if none of its attributes were written
+		mark, sep := s.buf.Len(), s.sep
+		s.appendKey(attr.Key)
+		s.buf.WriteByte('{')
+		s.sep = false
+		s.groups = append(s.groups, attr.Key)
+		for _, child := range attr.Value.Group() {
+			s.appendAttr(child)
+		}
+		s.groups = s.groups[:len(s.groups)-1]
+		if !s.sep {
+			s.buf.Truncate(mark)
+			s.sep = sep
+			return
+		}
+		s.buf.WriteByte('}')
+		return
+	}
+
+	if s.replace != nil {
+		attr = s.replace(s.groups, attr)
+		attr.Value = attr.Value.Resolve()
+	}
+	attr = maskSensitiveAttr(s.groups, attr, DefaultSensitiveKeys)
+	if attr.Key != "" {
+		s.appendMember(attr)
+	}
+}
+
+// appendBuiltin replaces the given built-in attribute and writes it to the buffer unless it is removed.
+func (s *jsonEncodeState) appendBuiltin(attr slog.Attr) {
+	if s.replace != nil {
+		attr = s.replace(nil, attr)
+		attr.Value = attr.Value.Resolve()
+		if attr.Key == "" {
+			return
+		}
+	}
+	s.appendMember(attr)
+}
+
+// appendBuiltins writes the built-in attributes for the record to the buffer the same way as [builtinAttrs] returns
+// them.
+func (s *jsonEncodeState) appendBuiltins(r slog.Record, addSource bool) {
+	if !r.Time.IsZero() {
+		s.appendBuiltin(slog.Time(slog.TimeKey, r.Time.Round(0)))
+	}
+	if s.replace == nil {
+		s.appendKey(slog.LevelKey)
+		appendJSONString(s.buf, r.Level.String())
+	} else {
+		s.appendBuiltin(slog.Any(slog.LevelKey, r.Level))
+	}
+	if addSource {
+		if src := r.Source(); src != nil {
+			s.appendBuiltin(slog.Any(slog.SourceKey, src))
+		}
+	}
+	s.appendBuiltin(slog.String(slog.MessageKey, r.Message))
+}
+
+// appendKey writes the given key to the buffer, preceded by a separator if needed.
+func (s *jsonEncodeState) appendKey(key string) {
+	if s.sep {
+		s.buf.WriteByte(',')
+	}
+	s.sep = true
+	appendJSONString(s.buf, key)
+	s.buf.WriteByte(':')
+}
+
+// appendMember writes the given resolved attribute to the buffer, preceded by a separator if needed.
+func (s *jsonEncodeState) appendMember(attr slog.Attr) {
+	if s.sep {
+		s.buf.WriteByte(',')
+	}
+	s.sep = true
+	appendJSONMember(s.buf, attr, "", 0)
+}
+
+// appendJSONFloat appends the given finite float to b the same way [json.Marshal] does.
+func appendJSONFloat(b []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// shorten exponents such as e-07 to e-7
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// appendJSONMember writes the given resolved attribute to the buffer as a single "key":value member of a JSON object.
+//
+// Group attributes are written as nested objects. indent and depth are used the same way as in [appendJSONObject].
+func appendJSONMember(buf *bytes.Buffer, attr slog.Attr, indent string, depth int) {
+	appendJSONString(buf, attr.Key)
+	buf.WriteByte(':')
+	if indent != "" {
+		buf.WriteByte(' ')
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		appendJSONObject(buf, attr.Value.Group(), indent, depth+1)
+		return
+	}
+	if indent == "" || attr.Value.Kind() != slog.KindAny {
+		appendJSONValue(buf, attr.Value)
+		return
+	}
+	value := marshalJSONValue(attr.Value)
+	if len(value) > 0 && (value[0] == '{' || value[0] == '[') {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, value, strings.Repeat(indent, depth+1), indent); err == nil {
+			value = indented.Bytes()
+		}
+	}
+	buf.Write(value)
+}
+
+// appendJSONObject writes the given resolved attributes to the buffer as a JSON object, preserving their order.
+//
+// Group attributes are written as nested objects. If indent is not empty, the object is written across multiple
+// lines with each level of nesting indented by indent and depth indicating the current level of nesting.
+func appendJSONObject(buf *bytes.Buffer, attrs []slog.Attr, indent string, depth int) {
+	if len(attrs) == 0 {
+		buf.WriteString("{}")
+		return
+	}
+
+	buf.WriteByte('{')
+	for i, attr := range attrs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if indent != "" {
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(indent, depth+1))
+		}
+		appendJSONMember(buf, attr, indent, depth)
+	}
+	if indent != "" {
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(indent, depth))
+	}
+	buf.WriteByte('}')
+}
+
+// appendJSONString writes the given string to the buffer as a quoted JSON string without escaping HTML characters.
+//
+// The output is identical to that of [json.Encoder] with HTML escaping disabled, but the string is written directly
+// to the buffer to avoid allocating.
+func appendJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\b':
+				buf.WriteString(`\b`)
+			case '\f':
+				buf.WriteString(`\f`)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		// invalid UTF-8 is replaced with U+FFFD and U+2028 and U+2029 are escaped for compatibility with JavaScript
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteRune(utf8.RuneError)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
+
+// appendJSONValue writes the given resolved, non-group value to the buffer the same way [slog.JSONHandler] does.
+//
+// Scalar values, levels, sources and errors are written directly to the buffer without using reflection. Everything
+// else is marshalled using [marshalJSONValue].
+func appendJSONValue(buf *bytes.Buffer, v slog.Value) {
+	var scratch [64]byte
+	switch v.Kind() {
+	case slog.KindString:
+		appendJSONString(buf, v.String())
+	case slog.KindInt64:
+		buf.Write(strconv.AppendInt(scratch[:0], v.Int64(), 10))
+	case slog.KindUint64:
+		buf.Write(strconv.AppendUint(scratch[:0], v.Uint64(), 10))
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			buf.Write(appendJSONFloat(scratch[:0], f))
+		} else {
+			buf.Write(marshalJSONValue(v))
+		}
+	case slog.KindBool:
+		buf.Write(strconv.AppendBool(scratch[:0], v.Bool()))
+	case slog.KindTime:
+		buf.WriteByte('"')
+		buf.Write(v.Time().AppendFormat(scratch[:0], time.RFC3339Nano))
+		buf.WriteByte('"')
+	case slog.KindDuration:
+		buf.Write(strconv.AppendInt(scratch[:0], int64(v.Duration()), 10))
+	default:
+		switch a := v.Any().(type) {
+		case *slog.Source:
+			buf.WriteString(`{"function":`)
+			appendJSONString(buf, a.Function)
+			buf.WriteString(`,"file":`)
+			appendJSONString(buf, a.File)
+			buf.WriteString(`,"line":`)
+			buf.Write(strconv.AppendInt(scratch[:0], int64(a.Line), 10))
+			buf.WriteByte('}')
+		case slog.Level:
+			appendJSONString(buf, a.String())
+		case json.Marshaler:
+			buf.Write(marshalJSONValue(v))
+		case error:
+			appendJSONString(buf, a.Error())
+		default:
+			buf.Write(marshalJSONValue(v))
+		}
+	}
+}
+
+// marshalJSON marshals the given value to JSON without escaping HTML characters or adding a trailing newline.
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// marshalJSONValue converts the given resolved, non-group value into JSON the same way [slog.JSONHandler] does.
+//
+// Times are formatted using [time.RFC3339Nano], durations are written as a number of nanoseconds and errors are
+// written using their error string. Values which cannot be marshalled are written as a string describing the error.
+func marshalJSONValue(v slog.Value) []byte {
+	var val any
+	switch v.Kind() {
+	case slog.KindString:
+		val = v.String()
+	case slog.KindInt64:
+		return strconv.AppendInt(nil, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(nil, v.Uint64(), 10)
+	case slog.KindFloat64:
+		f := v.Float64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			val = strconv.FormatFloat(f, 'g', -1, 64)
+		} else {
+			return appendJSONFloat(nil, f)
+		}
+	case slog.KindBool:
+		return strconv.AppendBool(nil, v.Bool())
+	case slog.KindTime:
+		val = v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return strconv.AppendInt(nil, int64(v.Duration()), 10)
+	default:
+		switch a := v.Any().(type) {
+		case *slog.Source:
+			val = struct {
+				Function string `json:"function"`
+				File     string `json:"file"`
+				Line     int    `json:"line"`
+			}{a.Function, a.File, a.Line}
+		case slog.Level:
+			val = a.String()
+		case json.Marshaler:
+			val = a
+		case error:
+			val = a.Error()
+		default:
+			val = a
+		}
+	}
+
+	b, err := marshalJSON(val)
+	if err != nil {
+		b, _ = marshalJSON(fmt.Sprintf("!ERROR:%s", err.Error()))
+	}
+	return b
+}
+
+// putJSONEncodeState resets the given state and returns it to the pool.
+func putJSONEncodeState(s *jsonEncodeState) {
+	s.buf = nil
+	s.groups = s.groups[:0]
+	s.replace = nil
+	s.sep = false
+	jsonEncodeStatePool.Put(s)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// logfmtTimeFormat is the layout used to format times in logfmt output.
+	logfmtTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// logfmtEncoder encodes records as key=value pairs on a single line using the logfmt conventions.
+//
+// Nested groups are written using dotted keys (eg: "group.key=value") and values are quoted whenever they are empty or
+// contain spaces, equal signs, quotes or non-printable characters. Keys containing any of those characters are either
+// sanitized or quoted, depending on whether the encoder is in text mode.
+type logfmtEncoder struct {
+	// unexported variables
+	options slog.HandlerOptions // encoder options
+	text    bool                // true to quote keys like [slog.TextHandler] rather than sanitizing them
+}
+
+// NewLogfmtEncoder creates a new [xlog.Encoder] which encodes records as key=value pairs in logfmt format.
+//
+// Nested groups are written using dotted keys (eg: "group.key=value"). Only the AddSource and ReplaceAttr options are
+// used by the encoder.
+//
+// References:
+//
+//	https://brandur.org/logfmt
+func NewLogfmtEncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &logfmtEncoder{}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// NewTextEncoder creates a new [xlog.Encoder] which encodes records as key=value pairs in the same format as
+// [slog.TextHandler].
+//
+// Unlike the logfmt encoder, keys which contain spaces, equal signs, quotes or non-printable characters are quoted
+// rather than sanitized. Only the AddSource and ReplaceAttr options are used by the encoder.
+//
+// References:
+//
+//	https://pkg.go.dev/log/slog#TextHandler
+func NewTextEncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &logfmtEncoder{
+		text: true,
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single logfmt line.
+func (e *logfmtEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	for _, attr := range builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr) {
+		e.appendAttr(buf, "", attr)
+	}
+	for _, attr := range recordAttrs(r, attrs, groups, e.options.ReplaceAttr) {
+		e.appendAttr(buf, "", attr)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// appendAttr writes the attribute to the buffer, prefixing its key with the given group prefix.
+func (e *logfmtEncoder) appendAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, child := range attr.Value.Group() {
+			e.appendAttr(buf, prefix+attr.Key+".", child)
+		}
+		return
+	}
+
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	if e.text {
+		buf.WriteString(logfmtValue(prefix + attr.Key))
+	} else {
+		buf.WriteString(logfmtKey(prefix + attr.Key))
+	}
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(formatTextValue(attr.Value, logfmtTimeFormat)))
+}
+
+// formatTextValue converts the given resolved value into a string suitable for a text-based format.
+//
+// Times are formatted using the given layout, sources are formatted as "file:line" and errors and values which
+// implement [encoding.TextMarshaler] are converted using their respective methods.
+func formatTextValue(v slog.Value, timeFormat string) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindTime:
+		return v.Time().Format(timeFormat)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch val := v.Any().(type) {
+		case *slog.Source:
+			return fmt.Sprintf("%s:%d", val.File, val.Line)
+		case slog.Level:
+			return val.String()
+		case error:
+			return val.Error()
+		case encoding.TextMarshaler:
+			if text, err := val.MarshalText(); err == nil {
+				return string(text)
+			}
+		case []byte:
+			return string(val)
+		case time.Time:
+			return val.Format(timeFormat)
+		}
+		return fmt.Sprintf("%+v", v.Any())
+	}
+	return v.String()
+}
+
+// logfmtKey sanitizes the given key so that it contains no spaces, equal signs, quotes or non-printable characters.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes the given value if necessary.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if !utf8.ValidString(value) {
+		return strconv.Quote(value)
+	}
+	for _, r := range value {
+		if r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// MergeConfigs merges the given JSON configuration documents, in order, into a single document so that a base
+// configuration can be shared across environments and each environment only needs an overlay holding the options it
+// overrides.
+//
+// Each document is applied on top of the result of merging the documents before it, so later documents take
+// precedence, using the following rules:
+//   - objects are merged recursively, key by key
+//   - a null value removes the key from the merged object
+//   - arrays holding only objects (eg: the "handlers" of a fanout handler) are merged element by element, by index,
+//     with any additional elements in the overlay appended
+//   - all other values, including any other arrays, replace the value in the merged document
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: a document is not a valid JSON object or the merged document could not be encoded
+func MergeConfigs(docs ...[]byte) ([]byte, xerrors.Error) {
+	merged := map[string]any{}
+	for i, doc := range docs {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var overlay map[string]any
+		if err := decoder.Decode(&overlay); err != nil {
+			return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal configuration document %d: %s", i,
+				err.Error()).WithAttr("document", i)
+		}
+		merged = mergeConfigObjects(merged, overlay)
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to marshal merged configuration: %s", err.Error())
+	}
+	return data, nil
+}
+
+// NewBuilderFromConfigFiles reads the JSON configuration documents in the given files, merges them using
+// [MergeConfigs] and returns a new [xlog.HandlerBuilder] for the merged configuration using
+// [NewBuilderFromConfigs].
+//
+// Files are merged in the order given, so later files take precedence (eg: "base.json" followed by "prod.json").
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read a file
+//
+// In addition, the function may return any error returned by [NewBuilderFromConfigs].
+func NewBuilderFromConfigFiles(paths ...string) (xlog.HandlerBuilder, xerrors.Error) {
+	docs := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		doc, err := os.ReadFile(path)
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.DataReadError, err, "failed to read configuration file '%s': %s", path,
+				err.Error()).WithAttr("path", path)
+		}
+		docs = append(docs, doc)
+	}
+	return NewBuilderFromConfigs(docs...)
+}
+
+// NewBuilderFromConfigs merges the given JSON configuration documents using [MergeConfigs] and returns a new
+// [xlog.HandlerBuilder] for the merged configuration.
+//
+// The merged document must be an object holding the "type" of the handler, its "options" and, optionally, the
+// wrappers to apply to it in "wrap", in the same form as a child handler of a fanout handler. Any configurations
+// named by its [ConfigExtendsKey] key are merged beneath it once the documents have been merged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read a file named by the [ConfigExtendsKey] key
+//   - [xlog.InvalidParameter]: a configuration extends itself or the [ConfigExtendsKey] key holds an invalid value
+//   - [xlog.MarshalError]: a document is not a valid JSON object or the merged document could not be decoded
+//
+// In addition, the function may return any error returned by [NewBuilderFromConfig] or [NewWrappedBuilder].
+func NewBuilderFromConfigs(docs ...[]byte) (xlog.HandlerBuilder, xerrors.Error) {
+	data, xerr := MergeConfigs(docs...)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if data, xerr = resolveConfigExtends(data); xerr != nil {
+		return nil, xerr
+	}
+	var config jsonHandlerBuilder
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal merged configuration: %s",
+			err.Error())
+	}
+	builder, xerr := NewBuilderFromConfig(config.HandlerType, config.HandlerOptions)
+	if xerr != nil {
+		return nil, xerr
+	}
+	return NewWrappedBuilder(builder, config.Wrap)
+}
+
+// mergeConfigObjects merges the overlay object into the base object as described by [MergeConfigs] and returns the
+// result.
+//
+// The base object may be modified.
+func mergeConfigObjects(base, overlay map[string]any) map[string]any {
+	if base == nil {
+		base = make(map[string]any, len(overlay))
+	}
+	for k, v := range overlay {
+		if v == nil {
+			delete(base, k)
+			continue
+		}
+		base[k] = mergeConfigValues(base[k], v)
+	}
+	return base
+}
+
+// mergeConfigValues merges the overlay value into the base value as described by [MergeConfigs] and returns the
+// result.
+func mergeConfigValues(base, overlay any) any {
+	switch o := overlay.(type) {
+	case map[string]any:
+		b, _ := base.(map[string]any)
+		return mergeConfigObjects(b, o)
+	case []any:
+		b, ok := base.([]any)
+		if !ok || !objectsOnly(b) || !objectsOnly(o) {
+			return o
+		}
+		merged := make([]any, max(len(b), len(o)))
+		copy(merged, b)
+		for i, v := range o {
+			if i < len(b) {
+				merged[i] = mergeConfigObjects(b[i].(map[string]any), v.(map[string]any))
+			} else {
+				merged[i] = mergeConfigObjects(nil, v.(map[string]any))
+			}
+		}
+		return merged
+	}
+	return overlay
+}
+
+// objectsOnly returns whether or not every element of the given array is an object.
+func objectsOnly(values []any) bool {
+	for _, v := range values {
+		if _, ok := v.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,750 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// ParquetHandlerType is the type for a [ParquetHandler].
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandler
+	ParquetHandlerType = "parquet"
+)
+
+var (
+	// DefaultParquetBatchSize is the default maximum number of records written to each Parquet file.
+	//
+	// This value is used when the batch size in [ParquetHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandlerOptions
+	DefaultParquetBatchSize = 10000
+
+	// DefaultParquetFilePrefix is the default prefix for the names of Parquet files.
+	//
+	// This value is used when the file prefix in [ParquetHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandlerOptions
+	DefaultParquetFilePrefix = "xlog"
+
+	// DefaultParquetFlushInterval is the default interval at which buffered records are written to a Parquet file,
+	// even if the batch is not full.
+	//
+	// This value is used when the flush interval in [ParquetHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandlerOptions
+	DefaultParquetFlushInterval = 5 * time.Minute
+
+	// DefaultParquetHandlerLogLevel is the default log level to use when one is not provided.
+	//
+	// This value is used when the level in [ParquetHandlerOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandlerOptions
+	DefaultParquetHandlerLogLevel = slog.LevelInfo
+
+	// DefaultParquetUploadTimeout is the default duration to wait for a Parquet file to be uploaded.
+	//
+	// This value is used when the client in [ParquetHandlerOptions] is nil.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#ParquetHandlerOptions
+	DefaultParquetUploadTimeout = time.Minute
+)
+
+// ParquetHandlerOptions holds the options for a [ParquetHandler].
+type ParquetHandlerOptions struct {
+	// CommonHandlerOptions holds the options which are shared by most handlers.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#CommonHandlerOptions
+	xlog.CommonHandlerOptions
+
+	// BatchSize is the maximum number of records written to each file.
+	//
+	// Once the batch is full, the file is written by the goroutine which logged the last record.
+	//
+	// The default behavior is defined by the default batch size setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	BatchSize int `json:"batch_size"`
+
+	// Client is the HTTP client used to upload files to the URL.
+	//
+	// The default behavior is to use a client which times out after the default upload timeout defined in the
+	// package.
+	//
+	// When reading configuration settings from a file or raw JSON, create a [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function a [xlog.BuildHandlerCallbackFn] callback to modify the options and set
+	// this value from your application, if desired.
+	Client *http.Client `json:"-"`
+
+	// Compress indicates whether or not to compress the pages of each file using gzip.
+	//
+	// The default behavior is to disable compression.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Compress bool `json:"compress"`
+
+	// Directory is the local directory to which files are written.
+	//
+	// Each file is written to a temporary file which is renamed once it is complete, so files in the directory can
+	// be read at any time. Either this value or the URL must be set.
+	//
+	// The default behavior is to not write files locally.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	Directory string `json:"directory"`
+
+	// FilePrefix is the prefix for the name of each file.
+	//
+	// Files are named using the prefix, the time at which the file was written, the process ID and a sequence number
+	// (eg: "xlog-20250102T150405Z-1234-000001.parquet"), so processes writing to the same location should use
+	// different prefixes if they may share a process ID (eg: containers).
+	//
+	// The default behavior is defined by the default file prefix setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	FilePrefix string `json:"file_prefix"`
+
+	// FlushInterval is the interval at which buffered records are written to a file, even if the batch is not full.
+	//
+	// The default behavior is defined by the default flush interval setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	FlushInterval types.Duration `json:"flush_interval,omitempty"`
+
+	// Headers holds additional headers to send with each upload (eg: an authorization header).
+	//
+	// The default behavior is to only send the Content-Type header.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// RequestSigner is called to modify each upload request before it is sent.
+	//
+	// This can be used to sign requests to object storage (eg: using AWS Signature Version 4 for S3 buckets).
+	//
+	// The default behavior is to send requests without modification.
+	//
+	// When reading configuration settings from a file or raw JSON, create a [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function a [xlog.BuildHandlerCallbackFn] callback to modify the options and set
+	// this value from your application, if desired.
+	RequestSigner func(r *http.Request) error `json:"-"`
+
+	// URL is the location to which files are uploaded using HTTP PUT requests.
+	//
+	// The name of each file is appended to the path of the URL. The URL may use the "http", "https" or "s3" scheme.
+	// S3 URLs take the form "s3://bucket/prefix" and are uploaded to the virtual-hosted endpoint for the bucket. Add a
+	// "region" query parameter to use a regional endpoint (eg: "s3://bucket/logs?region=us-east-2"). Either this
+	// value or the directory must be set.
+	//
+	// The default behavior is to not upload files.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	URL string `json:"url"`
+}
+
+// jsonParquetHandlerOptions is an alternate form of [ParquetHandlerOptions] that is used during unmarshalling to
+// prevent infinite recursion.
+type jsonParquetHandlerOptions struct {
+	BatchSize     int               `json:"batch_size"`
+	Compress      bool              `json:"compress"`
+	Directory     string            `json:"directory"`
+	FilePrefix    string            `json:"file_prefix"`
+	FlushInterval types.Duration    `json:"flush_interval"`
+	Headers       map[string]string `json:"headers"`
+	URL           string            `json:"url"`
+}
+
+// UnmarshalJSON decodes the JSON-encoded data into the current object.
+func (o *ParquetHandlerOptions) UnmarshalJSON(data []byte) error {
+	var opts jsonParquetHandlerOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return err
+	}
+	if err := o.CommonHandlerOptions.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	// copy remaining options
+	o.BatchSize = opts.BatchSize
+	o.Compress = opts.Compress
+	o.Directory = opts.Directory
+	o.FilePrefix = opts.FilePrefix
+	o.FlushInterval = opts.FlushInterval
+	o.Headers = opts.Headers
+	o.URL = opts.URL
+
+	return nil
+}
+
+// Validate checks the options for problems, returning a single error describing all of them.
+//
+// Either the directory or the URL is required. Other values which are not set are not treated as problems since they
+// are replaced by defaults when the handler is created.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o ParquetHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	v.checkNonNegative("batch_size", int64(o.BatchSize))
+	if o.Directory == "" && o.URL == "" {
+		v.addf("directory", "either directory or url is required")
+	}
+	if strings.ContainsAny(o.FilePrefix, `/\`) {
+		v.addf("file_prefix", "file prefix cannot contain path separators")
+	}
+	v.checkNonNegative("flush_interval", int64(o.FlushInterval))
+	v.checkLevels(o.Level, o.MaxLevel)
+	if o.URL != "" {
+		if _, err := parquetObjectURL(o.URL, "file.parquet"); err != nil {
+			v.addf("url", "invalid URL '%s': %s", o.URL, err.Error())
+		}
+	}
+	return v.err(ParquetHandlerType)
+}
+
+// clone returns a copy of the options which shares no maps or slices with the original.
+//
+// Level variables are intentionally shared so that the level of a handler can be changed after it is created.
+func (o ParquetHandlerOptions) clone() ParquetHandlerOptions {
+	o.Headers = maps.Clone(o.Headers)
+	return o
+}
+
+// ensure [ParquetHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = ParquetHandlerOptions{}
+
+// ensure [ParquetHandler] implements [xlog.ExtendedHandler] interface.
+var _ xlog.ExtendedHandler = &ParquetHandler{}
+
+// ensure [ParquetHandler] implements [xlog.Flusher] interface.
+var _ xlog.Flusher = &ParquetHandler{}
+
+// ensure [ParquetHandler] implements [xlog.LevelVarHandler] interface.
+var _ xlog.LevelVarHandler = &ParquetHandler{}
+
+// ParquetHandler is a handler that accumulates records and writes them in batches to Apache Parquet files, either
+// locally or to object storage, for cheap long-term storage which can be queried using tools like Amazon Athena or
+// DuckDB.
+//
+// Every file uses the same schema: a "time" column holding the time of the record as a UTC timestamp with microsecond
+// precision, "level" and "message" string columns and an "attrs" column holding a map of strings to strings. Groups
+// are flattened into dotted keys (eg: "http.method") and values are written as text, so the schema does not change
+// when the attributes which are logged do.
+//
+// The options are copied when the handler is created and are never modified afterward. Handlers derived using
+// WithAttrs or WithGroup share the options, level variables and batch with the handler they were derived from, so
+// records logged through any of them are written to the same files and closing any one of them writes the batch for
+// all of them.
+type ParquetHandler struct {
+	// unexported variables
+	attrs   []slog.Attr           // immutable attributes for the handler, nested within their groups
+	groups  []string              // immutable groups for the handler
+	options ParquetHandlerOptions // immutable handler options
+	state   *parquetHandlerState  // shared batch and background flusher
+}
+
+// parquetHandlerState holds the shared, mutable state for a handler and its descendants. This includes the batch of
+// records waiting to be written and the goroutine which writes them periodically.
+type parquetHandlerState struct {
+	mu   sync.Mutex
+	rows []parquetRow
+
+	closeOnce sync.Once      // ensures the flusher is only stopped once
+	done      chan struct{}  // closed to stop the flusher
+	flusher   sync.WaitGroup // running background flusher
+	seq       atomic.Uint64  // number of files which have been named
+	writeMu   sync.Mutex     // serializes writing files
+}
+
+// NewParquetHandler creates a new [ParquetHandler] object with the given options.
+//
+// The directory, if set, is created if it does not already exist.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to create the directory
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func NewParquetHandler(options ParquetHandlerOptions) (*ParquetHandler, xerrors.Error) {
+	h := &ParquetHandler{
+		options: options.clone(),
+		state: &parquetHandlerState{
+			done: make(chan struct{}),
+		},
+	}
+	if err := h.options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// set defaults
+	if h.options.Level == nil {
+		var level slog.LevelVar
+		level.Set(DefaultParquetHandlerLogLevel)
+		h.options.Level = &level
+	}
+	if h.options.BatchSize == 0 {
+		h.options.BatchSize = DefaultParquetBatchSize
+	}
+	if h.options.Client == nil {
+		h.options.Client = &http.Client{Timeout: DefaultParquetUploadTimeout}
+	}
+	if h.options.FilePrefix == "" {
+		h.options.FilePrefix = DefaultParquetFilePrefix
+	}
+	if h.options.FlushInterval == 0 {
+		h.options.FlushInterval = types.Duration(DefaultParquetFlushInterval)
+	}
+
+	// create the directory
+	if h.options.Directory != "" {
+		if err := os.MkdirAll(h.options.Directory, 0o755); err != nil {
+			return nil, xerrors.Wrapf(xlog.DataWriteError, err, "failed to create directory '%s': %s",
+				h.options.Directory, err.Error()).WithAttr("directory", h.options.Directory)
+		}
+	}
+
+	// write the batch periodically
+	h.state.flusher.Add(1)
+	go h.flushWorker(time.Duration(h.options.FlushInterval))
+	return h, nil
+}
+
+// ChildHandlers will always return nil as this handler has no child handlers.
+func (h *ParquetHandler) ChildHandlers() []slog.Handler {
+	return nil
+}
+
+// Close stops writing the batch periodically and writes any records in it to a file.
+//
+// Records handled after the handler is closed are only written once the batch is full or Flush is called.
+func (h *ParquetHandler) Close() error {
+	h.state.closeOnce.Do(func() {
+		close(h.state.done)
+	})
+	h.state.flusher.Wait()
+	return h.Flush()
+}
+
+// Enabled returns true if the handler should handle the message or false if it should not.
+func (h *ParquetHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.options.Enabled(level)
+}
+
+// Flush writes any records in the batch to a file.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: the file could not be encoded or written to the directory
+//   - [xlog.HTTPClientError]: the file could not be uploaded
+func (h *ParquetHandler) Flush() error {
+	h.state.mu.Lock()
+	rows := h.state.rows
+	h.state.rows = nil
+	h.state.mu.Unlock()
+	if len(rows) == 0 {
+		return nil
+	}
+	return h.writeBatch(rows)
+}
+
+// GetLevelVar returns the handler's [slog.LevelVar] for manipulating the minimum logging level.
+func (h *ParquetHandler) GetLevelVar() *slog.LevelVar {
+	return h.options.Level
+}
+
+// GetMaxLevelVar returns the handler's [slog.LevelVar] for manipulating the maximum logging level.
+func (h *ParquetHandler) GetMaxLevelVar() *slog.LevelVar {
+	return h.options.MaxLevel
+}
+
+// Handle adds the record to the batch, writing the batch to a file if it is full.
+func (h *ParquetHandler) Handle(ctx context.Context, r slog.Record) error {
+	row := h.newRow(r)
+	h.state.mu.Lock()
+	h.state.rows = append(h.state.rows, row)
+	var rows []parquetRow
+	if len(h.state.rows) >= h.options.BatchSize {
+		rows = h.state.rows
+		h.state.rows = nil
+	}
+	h.state.mu.Unlock()
+
+	if rows != nil {
+		if err := h.writeBatch(rows); err != nil {
+			return h.handleError(ctx, err, &r)
+		}
+	}
+	return nil
+}
+
+// Options returns the handler's options.
+func (h *ParquetHandler) Options() any {
+	return h.options
+}
+
+// Type returns the type of the handler.
+func (h *ParquetHandler) Type() string {
+	return ParquetHandlerType
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the
+// given attributes.
+func (h *ParquetHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &ParquetHandler{
+		attrs:   appendGroupedAttrs(h.attrs, h.groups, attrs),
+		groups:  h.groups,
+		options: h.options,
+		state:   h.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *ParquetHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups)+1)
+	copy(groups, h.groups)
+	groups[len(h.groups)] = name
+	return &ParquetHandler{
+		attrs:   h.attrs,
+		groups:  groups,
+		options: h.options,
+		state:   h.state,
+	}
+}
+
+// flushWorker writes the batch at the given interval until the handler is closed.
+func (h *ParquetHandler) flushWorker(interval time.Duration) {
+	defer h.state.flusher.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.state.done:
+			return
+		case <-ticker.C:
+			if err := h.Flush(); err != nil {
+				h.handleError(context.Background(), err, nil)
+			}
+		}
+	}
+}
+
+// handleError is a simple wrapper function to log the error as an internal event and call the error handler function
+// if it is defined.
+func (h *ParquetHandler) handleError(ctx context.Context, err error, r *slog.Record) error {
+	logHandleError(ctx, ParquetHandlerType, err, r)
+	if h.options.ErrorHandler != nil {
+		err = h.options.ErrorHandler(ctx, err, r)
+	}
+	return err
+}
+
+// newRow converts the record into a row, combining it with the handler's attributes and groups.
+//
+// The time, level and message are written to their own columns unless ReplaceAttr changes their keys, in which case
+// they are written to the attributes along with the caller, if it is included.
+func (h *ParquetHandler) newRow(r slog.Record) parquetRow {
+	t := r.Time
+	if t.IsZero() {
+		t = xlog.DefaultClock.Now()
+	}
+	row := parquetRow{
+		time: t.UnixMicro(),
+	}
+
+	var attrs []slog.Attr
+	for _, attr := range builtinAttrs(r, h.options.IncludeCaller, h.options.ReplaceAttr) {
+		switch {
+		case attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime:
+			row.time = attr.Value.Time().UnixMicro()
+		case attr.Key == slog.LevelKey:
+			row.level = attr.Value.String()
+		case attr.Key == slog.MessageKey:
+			row.message = attr.Value.String()
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+	attrs = append(attrs, recordAttrs(r, h.attrs, h.groups, h.options.ReplaceAttr)...)
+	for _, attr := range xlog.FlattenAttrs(attrs, "") {
+		row.attrs = append(row.attrs, parquetAttr{
+			key:   attr.Key,
+			value: formatTextValue(attr.Value, time.RFC3339Nano),
+		})
+	}
+	return row
+}
+
+// upload sends the file with the given name to the URL.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.HTTPClientError]: the file could not be uploaded
+func (h *ParquetHandler) upload(name string, data []byte) xerrors.Error {
+	target, err := parquetObjectURL(h.options.URL, name)
+	if err != nil {
+		return xerrors.Wrapf(xlog.HTTPClientError, err, "invalid URL '%s': %s", h.options.URL, err.Error()).
+			WithAttr("url", h.options.URL)
+	}
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return xerrors.Wrapf(xlog.HTTPClientError, err, "failed to create request: %s", err.Error()).
+			WithAttr("url", target)
+	}
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	for key, value := range h.options.Headers {
+		req.Header.Set(key, value)
+	}
+	if h.options.RequestSigner != nil {
+		if err := h.options.RequestSigner(req); err != nil {
+			return xerrors.Wrapf(xlog.HTTPClientError, err, "failed to sign request: %s", err.Error()).
+				WithAttr("url", target)
+		}
+	}
+
+	resp, err := h.options.Client.Do(req)
+	if err != nil {
+		return xerrors.Wrapf(xlog.HTTPClientError, err, "failed to upload file '%s': %s", name, err.Error()).
+			WithAttr("url", target)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return xerrors.Newf(xlog.HTTPClientError, "failed to upload file '%s': unexpected status %s", name,
+			resp.Status).WithAttrs(map[string]any{
+			"status_code": resp.StatusCode,
+			"url":         target,
+		})
+	}
+	return nil
+}
+
+// writeBatch encodes the given rows as a single file and writes it to the directory and URL.
+//
+// If the file cannot be written to the directory, it is still uploaded and vice versa, and both errors are returned.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: the file could not be encoded or written to the directory
+//   - [xlog.HTTPClientError]: the file could not be uploaded
+func (h *ParquetHandler) writeBatch(rows []parquetRow) error {
+	h.state.writeMu.Lock()
+	defer h.state.writeMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := writeParquetFile(&buf, rows, h.options.Compress); err != nil {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to encode Parquet file: %s", err.Error())
+	}
+	name := fmt.Sprintf("%s-%s-%d-%06d.parquet", h.options.FilePrefix,
+		xlog.DefaultClock.Now().UTC().Format("20060102T150405Z"), os.Getpid(), h.state.seq.Add(1))
+
+	var errs []error
+	if h.options.Directory != "" {
+		if err := writeFileAtomic(filepath.Join(h.options.Directory, name), buf.Bytes()); err != nil {
+			errs = append(errs, xerrors.Wrapf(xlog.DataWriteError, err, "failed to write file '%s': %s", name,
+				err.Error()).WithAttr("directory", h.options.Directory))
+		}
+	}
+	if h.options.URL != "" {
+		if err := h.upload(name, buf.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parquetObjectURL returns the HTTP(S) URL to which the file with the given name is uploaded.
+func parquetObjectURL(rawURL, name string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", errors.New("URL must include a host")
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+		u.RawPath = ""
+		return u.String(), nil
+	case "s3":
+		if u.Host == "" {
+			return "", errors.New("S3 URLs must include a bucket")
+		}
+		path := strings.TrimSuffix(u.Path, "/") + "/" + name
+		return (&url.URL{Scheme: "https", Host: s3Host(u), Path: path}).String(), nil
+	}
+	return "", fmt.Errorf("unsupported URL scheme '%s'", u.Scheme)
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory as the given path and renames it to the
+// path once it is complete.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// parquetHandlerBuilder is used to build the handler from configuration options.
+type parquetHandlerBuilder struct {
+	// unexported variables
+	options ParquetHandlerOptions // handler options
+}
+
+// ensure [parquetHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &parquetHandlerBuilder{}
+
+// NewParquetHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options, setting
+// and default values as necessary.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: error while unmarshaling options to JSON
+func NewParquetHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBuilder, xerrors.Error) {
+	var opts ParquetHandlerOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal handler options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	return &parquetHandlerBuilder{
+		options: opts,
+	}, nil
+}
+
+// Build actually creates and returns the handler.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.BuildHandlerError]: failed to construct the new handler
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *parquetHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
+}
+
+// MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
+// add additional fields.
+func (b *parquetHandlerBuilder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.options)
+}
+
+// Options returns the options as a string map.
+func (b *parquetHandlerBuilder) Options() map[string]any {
+	jsonOptions, err := json.Marshal(b)
+	if err != nil {
+		return map[string]any{
+			"error": err.Error(),
+		}
+	}
+
+	var options map[string]any
+	if err := json.Unmarshal(jsonOptions, &options); err != nil {
+		return map[string]any{
+			"error": err.Error(),
+		}
+	}
+	return options
+}
+
+// Type returns the type of the handler being built.
+func (b *parquetHandlerBuilder) Type() string {
+	return ParquetHandlerType
+}
+
+// Validate checks the options for problems without actually building the handler.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *parquetHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options.clone()
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *parquetHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewParquetHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// Parquet physical types
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	// Parquet repetition types
+	parquetRequired = 0
+	parquetRepeated = 2
+
+	// Parquet converted types, which are written alongside logical types for older readers
+	parquetConvertedUTF8            = 0
+	parquetConvertedMap             = 1
+	parquetConvertedTimestampMicros = 10
+
+	// Parquet encodings
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	// Parquet compression codecs
+	parquetCodecUncompressed = 0
+	parquetCodecGzip         = 2
+
+	// Parquet page types
+	parquetDataPage = 0
+
+	// parquetMagic is written to the beginning and end of every Parquet file.
+	parquetMagic = "PAR1"
+)
+
+const (
+	// Thrift compact protocol types
+	thriftBoolTrue = 1
+	thriftI32      = 5
+	thriftI64      = 6
+	thriftBinary   = 8
+	thriftList     = 9
+	thriftStruct   = 12
+)
+
+// parquetAttr is a single entry in the attrs column of a Parquet file.
+type parquetAttr struct {
+	key   string
+	value string
+}
+
+// parquetRow is a single record to be written to a Parquet file.
+type parquetRow struct {
+	attrs   []parquetAttr // flattened attributes
+	level   string        // name of the level
+	message string        // message
+	time    int64         // number of microseconds since the Unix epoch
+}
+
+// parquetColumn holds the encoded levels and values of a single column while a Parquet file is written.
+type parquetColumn struct {
+	defLevels []byte       // definition levels, if the column is nested
+	path      []string     // path of the column in the schema
+	physType  int32        // physical type of the column
+	repLevels []byte       // repetition levels, if the column is nested
+	values    bytes.Buffer // PLAIN-encoded values
+}
+
+// parquetColumnChunk describes a column which has been written to a Parquet file.
+type parquetColumnChunk struct {
+	column           *parquetColumn // column which was written
+	compressedSize   int64          // size of the page header and compressed page
+	numValues        int64          // number of values, including empty maps
+	offset           int64          // offset of the page header within the file
+	uncompressedSize int64          // size of the page header and uncompressed page
+}
+
+// writeParquetFile writes the given rows to w as a complete Parquet file containing a single row group.
+//
+// The file uses the following schema, with each column held in a single PLAIN-encoded data page, which is compressed
+// using gzip if desired:
+//
+//	message schema {
+//	  required int64 time (TIMESTAMP(MICROS,true));
+//	  required binary level (STRING);
+//	  required binary message (STRING);
+//	  required group attrs (MAP) {
+//	    repeated group key_value {
+//	      required binary key (STRING);
+//	      required binary value (STRING);
+//	    }
+//	  }
+//	}
+//
+// References:
+//
+//	https://parquet.apache.org/docs/file-format/
+func writeParquetFile(w io.Writer, rows []parquetRow, compress bool) error {
+	timeCol := &parquetColumn{path: []string{"time"}, physType: parquetTypeInt64}
+	levelCol := &parquetColumn{path: []string{"level"}, physType: parquetTypeByteArray}
+	messageCol := &parquetColumn{path: []string{"message"}, physType: parquetTypeByteArray}
+	keyCol := &parquetColumn{path: []string{"attrs", "key_value", "key"}, physType: parquetTypeByteArray}
+	valueCol := &parquetColumn{path: []string{"attrs", "key_value", "value"}, physType: parquetTypeByteArray}
+	for _, row := range rows {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(row.time))
+		timeCol.values.Write(b[:])
+		appendParquetString(&levelCol.values, row.level)
+		appendParquetString(&messageCol.values, row.message)
+
+		// an empty map is a single entry with no value
+		if len(row.attrs) == 0 {
+			for _, col := range []*parquetColumn{keyCol, valueCol} {
+				col.repLevels = append(col.repLevels, 0)
+				col.defLevels = append(col.defLevels, 0)
+			}
+			continue
+		}
+		for i, attr := range row.attrs {
+			rep := byte(1)
+			if i == 0 {
+				rep = 0
+			}
+			for _, col := range []*parquetColumn{keyCol, valueCol} {
+				col.repLevels = append(col.repLevels, rep)
+				col.defLevels = append(col.defLevels, 1)
+			}
+			appendParquetString(&keyCol.values, attr.key)
+			appendParquetString(&valueCol.values, attr.value)
+		}
+	}
+
+	// write the column chunks
+	if _, err := io.WriteString(w, parquetMagic); err != nil {
+		return err
+	}
+	offset := int64(len(parquetMagic))
+	var chunks []parquetColumnChunk
+	for _, col := range []*parquetColumn{timeCol, levelCol, messageCol, keyCol, valueCol} {
+		chunk, err := writeParquetColumn(w, col, int64(len(rows)), offset, compress)
+		if err != nil {
+			return err
+		}
+		offset += chunk.compressedSize
+		chunks = append(chunks, chunk)
+	}
+
+	// write the footer
+	metadata := parquetFileMetaData(chunks, int64(len(rows)), compress)
+	if _, err := w.Write(metadata); err != nil {
+		return err
+	}
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(len(metadata)))
+	if _, err := w.Write(footer[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, parquetMagic)
+	return err
+}
+
+// appendParquetLevels appends the given levels, which must be 0 or 1, to b using the RLE/bit-packing hybrid encoding
+// with only RLE runs and a bit width of 1, prefixed with the length of the encoded levels, and returns the extended
+// slice.
+func appendParquetLevels(b []byte, levels []byte) []byte {
+	var runs []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs = binary.AppendUvarint(runs, uint64(j-i)<<1)
+		runs = append(runs, levels[i])
+		i = j
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(runs)))
+	return append(b, runs...)
+}
+
+// appendParquetString writes the given string to buf using the PLAIN encoding for byte arrays.
+func appendParquetString(buf *bytes.Buffer, s string) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(s)))
+	buf.Write(length[:])
+	buf.WriteString(s)
+}
+
+// parquetFileMetaData returns the Thrift-encoded FileMetaData structure for a file holding the given column chunks.
+func parquetFileMetaData(chunks []parquetColumnChunk, numRows int64, compress bool) []byte {
+	codec := int32(parquetCodecUncompressed)
+	if compress {
+		codec = parquetCodecGzip
+	}
+
+	var t thriftWriter
+	t.beginStruct()
+	t.writeI32(1, 1) // version
+
+	// schema
+	t.beginList(2, thriftStruct, 8)
+	t.beginStruct()
+	t.writeBinary(4, "schema")
+	t.writeI32(5, 4) // num_children
+	t.endStruct()
+	t.beginStruct()
+	t.writeI32(1, parquetTypeInt64)
+	t.writeI32(3, parquetRequired)
+	t.writeBinary(4, "time")
+	t.writeI32(6, parquetConvertedTimestampMicros)
+	t.beginField(10, thriftStruct) // logicalType
+	t.beginField(8, thriftStruct)  // TIMESTAMP
+	t.writeBool(1, true)           // isAdjustedToUTC
+	t.beginField(2, thriftStruct)  // unit
+	t.beginField(2, thriftStruct)  // MICROS
+	t.endStruct()
+	t.endStruct()
+	t.endStruct()
+	t.endStruct()
+	t.endStruct()
+	for _, name := range []string{"level", "message"} {
+		t.writeStringSchemaElement(name)
+	}
+	t.beginStruct()
+	t.writeI32(3, parquetRequired)
+	t.writeBinary(4, "attrs")
+	t.writeI32(5, 1) // num_children
+	t.writeI32(6, parquetConvertedMap)
+	t.beginField(10, thriftStruct) // logicalType
+	t.beginField(2, thriftStruct)  // MAP
+	t.endStruct()
+	t.endStruct()
+	t.endStruct()
+	t.beginStruct()
+	t.writeI32(3, parquetRepeated)
+	t.writeBinary(4, "key_value")
+	t.writeI32(5, 2) // num_children
+	t.endStruct()
+	for _, name := range []string{"key", "value"} {
+		t.writeStringSchemaElement(name)
+	}
+
+	t.writeI64(3, numRows)
+
+	// row groups
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.uncompressedSize
+	}
+	t.beginList(4, thriftStruct, 1)
+	t.beginStruct()
+	t.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		t.beginStruct()
+		t.writeI64(2, chunk.offset) // file_offset
+		t.beginField(3, thriftStruct)
+		t.writeI32(1, chunk.column.physType)
+		t.beginList(2, thriftI32, 2)
+		t.writeListI32(parquetEncodingPlain)
+		t.writeListI32(parquetEncodingRLE)
+		t.beginList(3, thriftBinary, len(chunk.column.path))
+		for _, name := range chunk.column.path {
+			t.writeListBinary(name)
+		}
+		t.writeI32(4, codec)
+		t.writeI64(5, chunk.numValues)
+		t.writeI64(6, chunk.uncompressedSize)
+		t.writeI64(7, chunk.compressedSize)
+		t.writeI64(9, chunk.offset) // data_page_offset
+		t.endStruct()
+		t.endStruct()
+	}
+	t.writeI64(2, totalSize)
+	t.writeI64(3, numRows)
+	t.endStruct()
+
+	t.writeBinary(6, "go.innotegrity.dev/xlog")
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// writeParquetColumn writes the given column to w as a single data page starting at the given offset within the
+// file.
+func writeParquetColumn(w io.Writer, col *parquetColumn, numRows, offset int64, compress bool) (
+	parquetColumnChunk, error) {
+
+	chunk := parquetColumnChunk{
+		column:    col,
+		numValues: numRows,
+		offset:    offset,
+	}
+	var page []byte
+	if col.repLevels != nil {
+		chunk.numValues = int64(len(col.repLevels))
+		page = appendParquetLevels(page, col.repLevels)
+		page = appendParquetLevels(page, col.defLevels)
+	}
+	page = append(page, col.values.Bytes()...)
+	uncompressedSize := len(page)
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(page); err != nil {
+			return chunk, err
+		}
+		if err := zw.Close(); err != nil {
+			return chunk, err
+		}
+		page = buf.Bytes()
+	}
+
+	var t thriftWriter
+	t.beginStruct()
+	t.writeI32(1, parquetDataPage)
+	t.writeI32(2, int32(uncompressedSize))
+	t.writeI32(3, int32(len(page)))
+	t.beginField(5, thriftStruct) // data_page_header
+	t.writeI32(1, int32(chunk.numValues))
+	t.writeI32(2, parquetEncodingPlain)
+	t.writeI32(3, parquetEncodingRLE)
+	t.writeI32(4, parquetEncodingRLE)
+	t.endStruct()
+	t.endStruct()
+
+	if _, err := w.Write(t.buf.Bytes()); err != nil {
+		return chunk, err
+	}
+	if _, err := w.Write(page); err != nil {
+		return chunk, err
+	}
+	chunk.compressedSize = int64(t.buf.Len() + len(page))
+	chunk.uncompressedSize = int64(t.buf.Len() + uncompressedSize)
+	return chunk, nil
+}
+
+// thriftWriter writes structures using the Thrift compact protocol, which Parquet uses for its metadata.
+//
+// References:
+//
+//	https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+type thriftWriter struct {
+	// unexported variables
+	buf    bytes.Buffer // encoded data
+	fields []int16      // ID of the last field written to each open structure
+}
+
+// beginField writes the header of a field of the given type in the current structure, opening a nested structure if
+// the type is a structure.
+func (t *thriftWriter) beginField(id int16, fieldType byte) {
+	last := t.fields[len(t.fields)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.buf.Write(binary.AppendUvarint(nil, zigzag(int64(id))))
+	}
+	t.fields[len(t.fields)-1] = id
+	if fieldType == thriftStruct {
+		t.fields = append(t.fields, 0)
+	}
+}
+
+// beginList writes the header of a list field holding the given number of elements of the given type.
+func (t *thriftWriter) beginList(id int16, elemType byte, n int) {
+	t.beginField(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+// beginStruct opens a top-level structure or a structure which is an element of a list.
+func (t *thriftWriter) beginStruct() {
+	t.fields = append(t.fields, 0)
+}
+
+// endStruct closes the current structure.
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.fields = t.fields[:len(t.fields)-1]
+}
+
+// writeBinary writes a binary or string field.
+func (t *thriftWriter) writeBinary(id int16, s string) {
+	t.beginField(id, thriftBinary)
+	t.writeListBinary(s)
+}
+
+// writeBool writes a boolean field.
+func (t *thriftWriter) writeBool(id int16, b bool) {
+	fieldType := byte(thriftBoolTrue)
+	if !b {
+		fieldType++
+	}
+	t.beginField(id, fieldType)
+}
+
+// writeI32 writes a 32-bit integer field.
+func (t *thriftWriter) writeI32(id int16, i int32) {
+	t.beginField(id, thriftI32)
+	t.writeListI32(i)
+}
+
+// writeI64 writes a 64-bit integer field.
+func (t *thriftWriter) writeI64(id int16, i int64) {
+	t.beginField(id, thriftI64)
+	t.buf.Write(binary.AppendUvarint(nil, zigzag(i)))
+}
+
+// writeListBinary writes a binary or string list element.
+func (t *thriftWriter) writeListBinary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+// writeListI32 writes a 32-bit integer list element.
+func (t *thriftWriter) writeListI32(i int32) {
+	t.buf.Write(binary.AppendUvarint(nil, zigzag(int64(i))))
+}
+
+// writeStringSchemaElement writes a Parquet SchemaElement list element for a required string column with the given
+// name.
+func (t *thriftWriter) writeStringSchemaElement(name string) {
+	t.beginStruct()
+	t.writeI32(1, parquetTypeByteArray)
+	t.writeI32(3, parquetRequired)
+	t.writeBinary(4, name)
+	t.writeI32(6, parquetConvertedUTF8)
+	t.beginField(10, thriftStruct) // logicalType
+	t.beginField(1, thriftStruct)  // STRING
+	t.endStruct()
+	t.endStruct()
+	t.endStruct()
+}
@@ -0,0 +1,825 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// PluginHandlerType is the type for a [PluginHandler].
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#PluginHandler
+	PluginHandlerType = "plugin"
+
+	// PluginProtocolVersion is the version of the plugin protocol spoken by a [PluginHandler].
+	PluginProtocolVersion = 1
+
+	// PluginSocketEnvVar is the name of the environment variable holding the path of the Unix socket to which a
+	// plugin should connect when the socket in [PluginHandlerOptions] is set.
+	PluginSocketEnvVar = "XLOG_PLUGIN_SOCKET"
+)
+
+const (
+	// pluginMessageAck is the type of the reply sent by a plugin after it successfully processes a command.
+	pluginMessageAck = "ack"
+
+	// pluginMessageClose is the type of the command sent to a plugin before it is stopped.
+	pluginMessageClose = "close"
+
+	// pluginMessageError is the type of the reply sent by a plugin when it fails to process a command.
+	pluginMessageError = "error"
+
+	// pluginMessageFlush is the type of the command sent to a plugin to flush any records it has buffered.
+	pluginMessageFlush = "flush"
+
+	// pluginMessageHandshake is the type of the command sent to a plugin when it is started and of its reply.
+	pluginMessageHandshake = "handshake"
+
+	// pluginMessageRecord is the type of the command which holds a single record.
+	pluginMessageRecord = "record"
+)
+
+var (
+	// DefaultPluginHandlerLogLevel is the log level to use for the handler.
+	//
+	// This value is used when the level in [PluginHandlerOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#PluginHandlerOptions
+	DefaultPluginHandlerLogLevel = slog.LevelInfo
+
+	// DefaultPluginHandlerTimeout is the default duration to wait for a plugin to connect, to reply to a command or
+	// to exit after it has been closed.
+	//
+	// This value is used when the timeout in [PluginHandlerOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#PluginHandlerOptions
+	DefaultPluginHandlerTimeout = types.Duration(10 * time.Second)
+)
+
+// PluginHandlerOptions holds the options for a [PluginHandler].
+type PluginHandlerOptions struct {
+	// CommonHandlerOptions holds the level, caller, error handler and attribute replacement options shared with
+	// other handlers.
+	xlog.CommonHandlerOptions
+
+	// Args holds the command line arguments to pass to the plugin.
+	//
+	// The default behavior is to pass no arguments.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	Args []string `json:"args,omitempty"`
+
+	// Clock is the source of the current time used to set the time of records according to the TimestampPolicy.
+	//
+	// The default behavior is defined by the default clock defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Clock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultClock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	Clock xlog.Clock `json:"-"`
+
+	// Command is the path of the plugin executable.
+	//
+	// If the path contains no path separators, the executable is searched for in the directories named by the PATH
+	// environment variable.
+	//
+	// This value is required.
+	Command string `json:"command"`
+
+	// Dir is the working directory for the plugin.
+	//
+	// The default behavior is to run the plugin in the current working directory.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	Dir string `json:"dir,omitempty"`
+
+	// Env holds additional environment variables (in "KEY=value" form) to pass to the plugin.
+	//
+	// The plugin always inherits the environment of the current process.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	Env []string `json:"env,omitempty"`
+
+	// Socket is the path of a Unix socket on which the handler listens for the plugin to connect.
+	//
+	// When set, the path is passed to the plugin in the environment variable named by [PluginSocketEnvVar] and all
+	// messages are exchanged over the socket instead of the plugin's stdin and stdout. The socket must not already
+	// exist.
+	//
+	// The default behavior is to exchange messages over the plugin's stdin and stdout.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	Socket string `json:"socket,omitempty"`
+
+	// Timeout is the duration to wait for the plugin to connect, to reply to a command or to exit after the handler
+	// has been closed. If the plugin does not exit in time, it is killed. A value of 0 waits indefinitely.
+	//
+	// The default behavior is defined by the default timeout setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, is null or is -1, it
+	// will be left unset.
+	Timeout xlog.Optional[types.Duration] `json:"timeout,omitzero"`
+
+	// TimestampPolicy defines how the time of each record is treated (eg: whether records with a zero time are given
+	// the current time from the Clock, dropped or logged without a time).
+	//
+	// The default behavior is defined by the default timestamp policy defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultTimestampPolicy
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimestampPolicy
+	TimestampPolicy xlog.TimestampPolicy `json:"timestamp_policy,omitempty"`
+}
+
+// jsonPluginHandlerOptions is an alternate form of [PluginHandlerOptions] that is used during unmarshalling to
+// prevent infinite recursion.
+type jsonPluginHandlerOptions struct {
+	Args            []string                      `json:"args"`
+	Command         string                        `json:"command"`
+	Dir             string                        `json:"dir"`
+	Env             []string                      `json:"env"`
+	Socket          string                        `json:"socket"`
+	Timeout         xlog.Optional[types.Duration] `json:"timeout"`
+	TimestampPolicy string                        `json:"timestamp_policy"`
+}
+
+// UnmarshalJSON decodes the JSON-encoded data into the current object.
+func (o *PluginHandlerOptions) UnmarshalJSON(data []byte) error {
+	var opts jsonPluginHandlerOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return err
+	}
+	if err := o.CommonHandlerOptions.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	// validate the timestamp policy
+	policy := xlog.TimestampPolicy(strings.TrimSpace(strings.ToLower(opts.TimestampPolicy)))
+	if !policy.IsValid() {
+		return fmt.Errorf("%s: invalid timestamp policy for plugin handler", opts.TimestampPolicy)
+	}
+	o.TimestampPolicy = policy
+
+	// copy remaining options
+	o.Args = opts.Args
+	o.Command = opts.Command
+	o.Dir = opts.Dir
+	o.Env = opts.Env
+	o.Socket = opts.Socket
+	o.Timeout = legacyDuration(opts.Timeout)
+
+	return nil
+}
+
+// Validate checks the options for problems.
+//
+// The command is required. Other values which are not set are not treated as problems since they are replaced by
+// defaults when the handler is created, including an unset timeout.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o PluginHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	if o.Command == "" {
+		v.addf("command", "value is required")
+	}
+	v.checkLevels(o.Level, o.MaxLevel)
+	v.checkNonNegative("timeout", int64(o.Timeout.Value()))
+	if !o.TimestampPolicy.IsValid() {
+		v.addf("timestamp_policy", "invalid timestamp policy '%s'", o.TimestampPolicy)
+	}
+	return v.err(PluginHandlerType)
+}
+
+// clone returns a copy of the options which shares no maps or slices with the original.
+//
+// Level variables are intentionally shared so that the level of a handler can be changed after it is created.
+func (o PluginHandlerOptions) clone() PluginHandlerOptions {
+	o.Args = slices.Clone(o.Args)
+	o.Env = slices.Clone(o.Env)
+	return o
+}
+
+// ensure [PluginHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = PluginHandlerOptions{}
+
+// ensure [PluginHandler] implements [xlog.ExtendedHandler] interface.
+var _ xlog.ExtendedHandler = &PluginHandler{}
+
+// ensure [PluginHandler] implements [xlog.LevelVarHandler] interface.
+var _ xlog.LevelVarHandler = &PluginHandler{}
+
+// PluginHandler is a handler that sends records to an external plugin process so that proprietary sinks can be added
+// without recompiling the application.
+//
+// The plugin is launched when the handler is created and stopped when the handler is closed. The handler and the
+// plugin exchange newline-delimited JSON messages, each of which is an object with a "type" member, over the plugin's
+// stdin and stdout or, when the socket option is set, over a Unix socket. The protocol is as follows:
+//
+//   - Handshake: the handler sends {"type":"handshake","version":1} and the plugin must reply with the same message
+//     and the highest protocol version it supports, which must match [PluginProtocolVersion].
+//   - Record: the handler sends {"type":"record","record":{...}} for each record, where the record is encoded exactly
+//     as it would be by [slog.JSONHandler]. The plugin must not reply.
+//   - Flush: the handler sends {"type":"flush"} when [PluginHandler.Flush] is called and the plugin must reply with
+//     {"type":"ack"} once all of the records it has received have been delivered.
+//   - Close: the handler sends {"type":"close"} when [PluginHandler.Close] is called and the plugin must deliver any
+//     remaining records, reply with {"type":"ack"} and exit.
+//
+// A plugin which fails to process a command may reply with {"type":"error","error":"..."} instead. Plugins must only
+// write protocol messages to their stdout and should write any diagnostics to stderr, which is passed through to the
+// stderr of the current process.
+//
+// The options are copied when the handler is created and are never modified afterward. Handlers derived using
+// WithAttrs or WithGroup share the options, level variables and plugin process with the handler they were derived
+// from, so changing a level variable or closing any one of them affects all of them.
+type PluginHandler struct {
+	// unexported variables
+	handler slog.Handler         // underlying handler used to encode records
+	options PluginHandlerOptions // immutable handler options
+	state   *pluginHandlerState  // shared plugin process and connection
+}
+
+// pluginHandlerState holds the shared, mutable state for a handler and its descendants. This includes the plugin
+// process and the connection used to communicate with it.
+type pluginHandlerState struct {
+	mu       sync.Mutex         // serializes messages sent to the plugin
+	buf      bytes.Buffer       // buffer used to build messages
+	closed   bool               // whether or not the handler has been closed
+	cmd      *exec.Cmd          // plugin process
+	conn     io.WriteCloser     // stdin of the plugin or the socket connection
+	exited   chan struct{}      // closed once the plugin process has exited
+	listener net.Listener       // socket listener, if a socket is used
+	replies  chan pluginMessage // replies read from the plugin, closed when the connection is closed
+	timeout  time.Duration      // duration to wait for the plugin
+	waitErr  error              // error returned when waiting for the plugin to exit
+}
+
+// pluginMessage is a single message exchanged with a plugin.
+type pluginMessage struct {
+	Error   string          `json:"error,omitempty"`
+	Record  json.RawMessage `json:"record,omitempty"`
+	Type    string          `json:"type"`
+	Version int             `json:"version,omitempty"`
+}
+
+// NewPluginHandler creates a new [PluginHandler] object with the given options, launching the plugin and performing
+// the protocol handshake.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.NetworkListenError]: failed to listen on the socket or the plugin failed to connect to it
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//   - [xlog.PluginError]: the plugin failed to start or the handshake failed
+func NewPluginHandler(options PluginHandlerOptions) (*PluginHandler, xerrors.Error) {
+	h := &PluginHandler{
+		options: options.clone(),
+	}
+
+	// command is a required field
+	if h.options.Command == "" {
+		return nil, xerrors.New(xlog.OptionsValidationError, "command is a required setting")
+	}
+
+	// ensure a minimum level is set
+	if h.options.Level == nil {
+		var level slog.LevelVar
+		level.Set(DefaultPluginHandlerLogLevel)
+		h.options.Level = &level
+	}
+
+	if !h.options.Timeout.IsSet() {
+		h.options.Timeout = xlog.Some(DefaultPluginHandlerTimeout)
+	}
+
+	// launch the plugin
+	state, err := startPlugin(h.options)
+	if err != nil {
+		return nil, err
+	}
+	h.state = state
+	h.handler = slog.NewJSONHandler(&pluginRecordWriter{state: state}, &slog.HandlerOptions{
+		AddSource:   h.options.IncludeCaller,
+		Level:       h.options.Level,
+		ReplaceAttr: h.options.ReplaceAttr,
+	})
+	return h, nil
+}
+
+// ChildHandlers returns the underlying [slog.Handler] which actually encodes the records.
+func (h *PluginHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Close asks the plugin to deliver any remaining records and exit, killing it if it does not exit in time.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.PluginError]: the plugin failed to acknowledge the command or to exit cleanly
+func (h *PluginHandler) Close() error {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var errs []error
+	if err := s.request(pluginMessage{Type: pluginMessageClose}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.stop(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		return xerrors.Wrapf(xlog.PluginError, err, "failed to close plugin '%s': %s", h.options.Command,
+			err.Error()).WithAttr("command", h.options.Command)
+	}
+	return nil
+}
+
+// Enabled returns true if the handler should handle the message or false if it should not.
+func (h *PluginHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.options.Enabled(level)
+}
+
+// Flush asks the plugin to deliver any records it has buffered and waits for it to acknowledge the command.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.PluginError]: the handler is closed or the plugin failed to acknowledge the command
+func (h *PluginHandler) Flush() error {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return xerrors.New(xlog.PluginError, "plugin handler is closed").WithAttr("command", h.options.Command)
+	}
+	if err := s.request(pluginMessage{Type: pluginMessageFlush}); err != nil {
+		return xerrors.Wrapf(xlog.PluginError, err, "failed to flush plugin '%s': %s", h.options.Command,
+			err.Error()).WithAttr("command", h.options.Command)
+	}
+	return nil
+}
+
+// GetLevelVar returns the handler's [slog.LevelVar] for manipulating the minimum logging level.
+func (h *PluginHandler) GetLevelVar() *slog.LevelVar {
+	return h.options.Level
+}
+
+// GetMaxLevelVar returns the handler's [slog.LevelVar] for manipulating the maximum logging level.
+func (h *PluginHandler) GetMaxLevelVar() *slog.LevelVar {
+	return h.options.MaxLevel
+}
+
+// Handle processes the record and sends it to the plugin.
+func (h *PluginHandler) Handle(ctx context.Context, r slog.Record) error {
+	r, ok := xlog.ApplyTimestampPolicy(r, h.options.TimestampPolicy, h.options.Clock)
+	if !ok {
+		return nil
+	}
+
+	err := h.handler.Handle(ctx, r)
+	if err != nil {
+		logHandleError(ctx, PluginHandlerType, err, &r)
+		if h.options.ErrorHandler != nil {
+			err = h.options.ErrorHandler(ctx, err, &r)
+		}
+	}
+	return err
+}
+
+// Options returns a copy of the handler's options.
+//
+// Modifying the returned options has no effect on the handler, except through the shared level variables.
+func (h *PluginHandler) Options() any {
+	return h.options.clone()
+}
+
+// Type returns the type of the handler.
+func (h *PluginHandler) Type() string {
+	return PluginHandlerType
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the
+// given attributes.
+func (h *PluginHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := h.clone()
+	clone.handler = h.handler.WithAttrs(attrs)
+	return clone
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *PluginHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+
+	clone := h.clone()
+	clone.handler = h.handler.WithGroup(name)
+	return clone
+}
+
+// clone creates a copy of current handler.
+func (h *PluginHandler) clone() *PluginHandler {
+	return &PluginHandler{
+		handler: h.handler,
+		options: h.options,
+		state:   h.state,
+	}
+}
+
+// startPlugin launches the plugin, connects to it and performs the protocol handshake.
+//
+// The plugin is stopped if any step fails.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.NetworkListenError]: failed to listen on the socket or the plugin failed to connect to it
+//   - [xlog.PluginError]: the plugin failed to start or the handshake failed
+func startPlugin(options PluginHandlerOptions) (*pluginHandlerState, xerrors.Error) {
+	cmd := exec.Command(options.Command, options.Args...)
+	cmd.Dir = options.Dir
+	cmd.Env = append(os.Environ(), options.Env...)
+	cmd.Stderr = os.Stderr
+	s := &pluginHandlerState{
+		cmd:     cmd,
+		exited:  make(chan struct{}),
+		replies: make(chan pluginMessage, 1),
+		timeout: time.Duration(options.Timeout.Value()),
+	}
+	attrs := map[string]any{
+		"command": options.Command,
+		"socket":  options.Socket,
+	}
+
+	// launch the plugin and connect to it
+	if options.Socket == "" {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.PluginError, err, "failed to create stdin pipe for plugin: %s",
+				err.Error()).WithAttrs(attrs)
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.PluginError, err, "failed to create stdout pipe for plugin: %s",
+				err.Error()).WithAttrs(attrs)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, xerrors.Wrapf(xlog.PluginError, err, "failed to start plugin '%s': %s", options.Command,
+				err.Error()).WithAttrs(attrs)
+		}
+		s.conn = stdin
+
+		// the process must not be waited on until all of its output has been read
+		go func() {
+			s.read(stdout)
+			s.wait()
+		}()
+	} else {
+		listener, err := net.Listen("unix", options.Socket)
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.NetworkListenError, err, "failed to listen on plugin socket '%s': %s",
+				options.Socket, err.Error()).WithAttrs(attrs)
+		}
+		s.listener = listener
+		cmd.Env = append(cmd.Env, PluginSocketEnvVar+"="+options.Socket)
+		if err := cmd.Start(); err != nil {
+			listener.Close()
+			return nil, xerrors.Wrapf(xlog.PluginError, err, "failed to start plugin '%s': %s", options.Command,
+				err.Error()).WithAttrs(attrs)
+		}
+		go s.wait()
+
+		conn, err := s.accept()
+		if err != nil {
+			cmd.Process.Kill()
+			s.stop()
+			return nil, xerrors.Wrapf(xlog.NetworkListenError, err, "plugin failed to connect to socket '%s': %s",
+				options.Socket, err.Error()).WithAttrs(attrs)
+		}
+		s.conn = conn
+		go s.read(conn)
+	}
+
+	// perform the handshake
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply, err := s.send(pluginMessage{Type: pluginMessageHandshake, Version: PluginProtocolVersion}, true)
+	if err == nil && (reply.Type != pluginMessageHandshake || reply.Version != PluginProtocolVersion) {
+		err = fmt.Errorf("unsupported handshake reply of type '%s' with version %d", reply.Type, reply.Version)
+	}
+	if err != nil {
+		s.closed = true
+		s.stop()
+		return nil, xerrors.Wrapf(xlog.PluginError, err, "plugin '%s' handshake failed: %s", options.Command,
+			err.Error()).WithAttrs(attrs)
+	}
+	return s, nil
+}
+
+// accept waits for the plugin to connect to the socket, giving up if the plugin exits or the timeout expires.
+func (s *pluginHandlerState) accept() (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := s.listener.Accept()
+		accepted <- result{conn: conn, err: err}
+	}()
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-accepted:
+		return r.conn, r.err
+	case <-s.exited:
+		s.listener.Close()
+		return nil, errors.New("plugin exited before connecting")
+	case <-timeout:
+		s.listener.Close()
+		return nil, errors.New("timed out waiting for plugin to connect")
+	}
+}
+
+// read reads replies from the plugin until the connection is closed.
+//
+// Replies which arrive while an earlier reply has not been consumed are discarded, since the plugin only replies to
+// commands and every command waits for its reply.
+func (s *pluginHandlerState) read(r io.Reader) {
+	defer close(s.replies)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var msg pluginMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			msg = pluginMessage{
+				Error: fmt.Sprintf("invalid reply from plugin: %s", err.Error()),
+				Type:  pluginMessageError,
+			}
+		}
+		select {
+		case s.replies <- msg:
+		default:
+		}
+	}
+}
+
+// request sends the given command to the plugin and waits for it to be acknowledged.
+//
+// The caller must hold the mutex.
+func (s *pluginHandlerState) request(msg pluginMessage) error {
+	reply, err := s.send(msg, true)
+	if err != nil {
+		return err
+	}
+	if reply.Type != pluginMessageAck {
+		return fmt.Errorf("unexpected reply of type '%s' to '%s' command", reply.Type, msg.Type)
+	}
+	return nil
+}
+
+// send sends the given message to the plugin and, if desired, waits for its reply.
+//
+// Any replies which were not requested are discarded before the message is sent. The caller must hold the mutex.
+func (s *pluginHandlerState) send(msg pluginMessage, waitForReply bool) (pluginMessage, error) {
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-s.replies:
+			drained = !ok
+		default:
+			drained = true
+		}
+	}
+
+	s.buf.Reset()
+	if msg.Type == pluginMessageRecord {
+		// the record has already been encoded, so avoid validating and compacting it again
+		s.buf.WriteString(`{"type":"record","record":`)
+		s.buf.Write(msg.Record)
+		s.buf.WriteString("}\n")
+	} else if err := json.NewEncoder(&s.buf).Encode(msg); err != nil {
+		return pluginMessage{}, err
+	}
+	if _, err := s.conn.Write(s.buf.Bytes()); err != nil {
+		return pluginMessage{}, err
+	}
+	if !waitForReply {
+		return pluginMessage{}, nil
+	}
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case reply, ok := <-s.replies:
+		if !ok {
+			return pluginMessage{}, errors.New("plugin closed the connection")
+		}
+		if reply.Type == pluginMessageError {
+			return reply, errors.New(reply.Error)
+		}
+		return reply, nil
+	case <-timeout:
+		return pluginMessage{}, fmt.Errorf("timed out waiting for reply to '%s' command", msg.Type)
+	}
+}
+
+// stop closes the connection to the plugin and waits for it to exit, killing it if it does not exit in time.
+func (s *pluginHandlerState) stop() error {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-s.exited:
+		return s.waitErr
+	case <-timeout:
+		s.cmd.Process.Kill()
+		<-s.exited
+		return errors.New("timed out waiting for plugin to exit")
+	}
+}
+
+// wait waits for the plugin process to exit.
+func (s *pluginHandlerState) wait() {
+	s.waitErr = s.cmd.Wait()
+	close(s.exited)
+}
+
+// pluginRecordWriter wraps each record encoded by the underlying handler in a message and sends it to the plugin.
+type pluginRecordWriter struct {
+	state *pluginHandlerState // shared plugin state
+}
+
+// Write sends the single record in p to the plugin.
+func (w *pluginRecordWriter) Write(p []byte) (int, error) {
+	s := w.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errors.New("plugin handler is closed")
+	}
+	record := bytes.TrimSuffix(p, []byte("\n"))
+	if _, err := s.send(pluginMessage{Type: pluginMessageRecord, Record: record}, false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// pluginHandlerBuilder is used to build the handler from configuration options.
+type pluginHandlerBuilder struct {
+	// unexported variables
+	options PluginHandlerOptions // handler options
+}
+
+// ensure [pluginHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &pluginHandlerBuilder{}
+
+// NewPluginHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options, setting
+// and default values as necessary.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: error while unmarshaling options to JSON
+func NewPluginHandlerBuilderFromConfig(options json.RawMessage) (xlog.HandlerBuilder, xerrors.Error) {
+	var opts PluginHandlerOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal handler options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	return &pluginHandlerBuilder{
+		options: opts,
+	}, nil
+}
+
+// Build actually creates and returns the handler, launching the plugin.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.BuildHandlerError]: failed to construct the new handler
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *pluginHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
+}
+
+// MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
+// add additional fields.
+func (b *pluginHandlerBuilder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.options)
+}
+
+// Options returns the options as a string map.
+func (b *pluginHandlerBuilder) Options() map[string]any {
+	jsonOptions, err := json.Marshal(b)
+	if err != nil {
+		return map[string]any{
+			"error": err.Error(),
+		}
+	}
+
+	var options map[string]any
+	if err := json.Unmarshal(jsonOptions, &options); err != nil {
+		return map[string]any{
+			"error": err.Error(),
+		}
+	}
+	return options
+}
+
+// Type returns the type of the handler being built.
+func (b *pluginHandlerBuilder) Type() string {
+	return PluginHandlerType
+}
+
+// Validate checks the options for problems without actually building the handler or launching the plugin.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *pluginHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options.clone()
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *pluginHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewPluginHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// ProductionLoggerOptions holds the options for [NewProductionLogger].
+type ProductionLoggerOptions struct {
+	// ConsoleLevel is the minimum level at which records are also written to stderr.
+	//
+	// The default behavior is to write records at [slog.LevelWarn] or above to stderr.
+	ConsoleLevel *slog.LevelVar
+
+	// Level is the minimum level at which records are written to the log file.
+	//
+	// The default behavior is defined by the default level setting for the file handler defined in the package.
+	Level *slog.LevelVar
+
+	// MaxAge is the maximum number of days to retain rotated log files.
+	//
+	// The default behavior is to not remove rotated log files based on age.
+	MaxAge int
+
+	// MaxCount is the maximum number of rotated log files to retain.
+	//
+	// The default behavior is to retain all rotated log files (though MaxAge may still cause them to get deleted).
+	MaxCount int
+
+	// Path is the path of the log file.
+	//
+	// The default behavior is defined by the default file settings for the file handler defined in the package.
+	Path string
+}
+
+// NewDevelopmentLogger returns a new [slog.Logger] suited to local development, which writes records at
+// [slog.LevelDebug] or above to stdout in a colorized format with the short caller of each record.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func NewDevelopmentLogger() (*slog.Logger, xerrors.Error) {
+	var level slog.LevelVar
+	level.Set(slog.LevelDebug)
+	h, xerr := NewConsoleHandler(ConsoleHandlerOptions{
+		CommonHandlerOptions: xlog.CommonHandlerOptions{
+			IncludeCaller: true,
+			Level:         &level,
+		},
+		Color:       ConsoleHandlerAutoColor,
+		Format:      ConsoleHandlerPrettyFormat,
+		ShortCaller: true,
+	})
+	if xerr != nil {
+		return nil, xerr
+	}
+	return slog.New(h), nil
+}
+
+// NewProductionLogger returns a new [slog.Logger] suited to production services, which writes records in JSON format
+// to a rotated log file and also writes warnings and errors in JSON format to stderr.
+//
+// The handler of the returned logger is a [FanoutHandler], which should be closed by the application before it exits
+// to flush the log file (eg: logger.Handler().(io.Closer).Close()).
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the log file cannot be created.
+func NewProductionLogger(options ProductionLoggerOptions) (*slog.Logger, xerrors.Error) {
+	if options.ConsoleLevel == nil {
+		options.ConsoleLevel = &slog.LevelVar{}
+		options.ConsoleLevel.Set(slog.LevelWarn)
+	}
+
+	file, xerr := NewFileHandler(FileHandlerOptions{
+		CommonHandlerOptions: xlog.CommonHandlerOptions{Level: options.Level},
+		File: types.Path{
+			AutoChmod:        DefaultFileHandlerAutoChmodLogFile,
+			AutoChown:        DefaultFileHandlerAutoChownLogFile,
+			AutoCreateParent: DefaultFileHandlerAutoCreateLogFileParent,
+			FSPath:           options.Path,
+			Group:            -1,
+			Owner:            -1,
+		},
+		Format:   FileHandlerJSONFormat,
+		MaxAge:   options.MaxAge,
+		MaxCount: options.MaxCount,
+	})
+	if xerr != nil {
+		return nil, xerr
+	}
+	console, xerr := NewConsoleHandler(ConsoleHandlerOptions{
+		CommonHandlerOptions: xlog.CommonHandlerOptions{Level: options.ConsoleLevel},
+		Color:                ConsoleHandlerNeverColor,
+		Format:               ConsoleHandlerJSONFormat,
+		Stderr:               true,
+	})
+	if xerr != nil {
+		_ = file.Close()
+		return nil, xerr
+	}
+	h, xerr := NewFanoutHandler(FanoutHandlerOptions{
+		Handlers: []slog.Handler{file, console},
+	})
+	if xerr != nil {
+		_ = file.Close()
+		return nil, xerr
+	}
+	return slog.New(h), nil
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// protobuf wire types
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+const (
+	// field numbers of the Record message
+	protoRecordTime       = 1
+	protoRecordLevel      = 2
+	protoRecordLevelValue = 3
+	protoRecordMessage    = 4
+	protoRecordSource     = 5
+	protoRecordAttrs      = 6
+
+	// field numbers of the Value message
+	protoValueString   = 1
+	protoValueInt      = 2
+	protoValueUint     = 3
+	protoValueFloat    = 4
+	protoValueBool     = 5
+	protoValueTime     = 6
+	protoValueDuration = 7
+	protoValueGroup    = 8
+	protoValueBytes    = 9
+	protoValueJSON     = 10
+)
+
+var (
+	// ProtobufRecordSchema holds the protocol buffer schema for the records encoded by [NewProtobufEncoder].
+	//
+	// The schema can be written to a file and passed to protoc to generate code for reading the records in other
+	// languages.
+	//
+	//go:embed record.proto
+	ProtobufRecordSchema string
+)
+
+// protobufEncoder encodes records as length-delimited Record messages defined by [ProtobufRecordSchema].
+type protobufEncoder struct {
+	// unexported variables
+	options slog.HandlerOptions // encoder options
+}
+
+// NewProtobufEncoder creates a new [xlog.Encoder] which encodes each record as a protocol buffer Record message, as
+// defined by [ProtobufRecordSchema], so that pipelines written in other languages can decode records using generated
+// code rather than guessing at the shape of the JSON output.
+//
+// The time, level, message and caller are written to their own fields and all other attributes, including built-in
+// attributes whose keys were changed by ReplaceAttr, are written in order to the attrs field with groups written as
+// nested attributes. Values of types without a matching field are written using their JSON representation. Each
+// message is prefixed with its length as a varint, so records are written one after the other with no separator.
+// Only the AddSource and ReplaceAttr options are used by the encoder.
+//
+// There is no protobuf file format, so the encoder is used by passing it to a [FileHandler] using the Encoder option of
+// [FileHandlerOptions]. It is not supported by any network handler.
+//
+// References:
+//
+//	https://protobuf.dev/programming-guides/encoding/
+func NewProtobufEncoder(options *slog.HandlerOptions) xlog.Encoder {
+	e := &protobufEncoder{}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single length-delimited message.
+func (e *protobufEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	var msg []byte
+	var extra []slog.Attr
+	for _, attr := range builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr) {
+		switch attr.Key {
+		case slog.TimeKey:
+			if attr.Value.Kind() == slog.KindTime {
+				msg = appendProtoBytes(msg, protoRecordTime, appendProtoTimestamp(nil, attr.Value.Time()))
+				continue
+			}
+		case slog.LevelKey:
+			if level, ok := attr.Value.Any().(slog.Level); ok {
+				msg = appendProtoBytes(msg, protoRecordLevel, []byte(level.String()))
+				msg = appendProtoVarint(msg, protoRecordLevelValue, zigzag(int64(level)))
+				continue
+			}
+		case slog.MessageKey:
+			msg = appendProtoBytes(msg, protoRecordMessage, []byte(attr.Value.String()))
+			continue
+		case slog.SourceKey:
+			if src, ok := attr.Value.Any().(*slog.Source); ok {
+				msg = appendProtoBytes(msg, protoRecordSource, appendProtoSource(nil, src))
+				continue
+			}
+		}
+		extra = append(extra, attr)
+	}
+	for _, attr := range append(extra, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...) {
+		msg = appendProtoBytes(msg, protoRecordAttrs, e.appendAttr(nil, attr))
+	}
+
+	var length [binary.MaxVarintLen64]byte
+	buf.Write(binary.AppendUvarint(length[:0], uint64(len(msg))))
+	buf.Write(msg)
+	return nil
+}
+
+// appendAttr appends the given resolved attribute to b as an Attr message and returns the extended slice.
+func (e *protobufEncoder) appendAttr(b []byte, attr slog.Attr) []byte {
+	b = appendProtoBytes(b, 1, []byte(attr.Key))
+	return appendProtoBytes(b, 2, e.appendValue(nil, attr.Value))
+}
+
+// appendValue appends the given resolved value to b as a Value message and returns the extended slice.
+func (e *protobufEncoder) appendValue(b []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendProtoBytes(b, protoValueString, []byte(v.String()))
+	case slog.KindInt64:
+		return appendProtoVarint(b, protoValueInt, zigzag(v.Int64()))
+	case slog.KindUint64:
+		return appendProtoVarint(b, protoValueUint, v.Uint64())
+	case slog.KindFloat64:
+		b = appendProtoTag(b, protoValueFloat, protoFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float64()))
+	case slog.KindBool:
+		var u uint64
+		if v.Bool() {
+			u = 1
+		}
+		return appendProtoVarint(b, protoValueBool, u)
+	case slog.KindTime:
+		return appendProtoBytes(b, protoValueTime, appendProtoTimestamp(nil, v.Time()))
+	case slog.KindDuration:
+		return appendProtoBytes(b, protoValueDuration, appendProtoDuration(nil, v.Duration()))
+	case slog.KindGroup:
+		var group []byte
+		for _, attr := range v.Group() {
+			group = appendProtoBytes(group, 1, e.appendAttr(nil, attr))
+		}
+		return appendProtoBytes(b, protoValueGroup, group)
+	}
+
+	switch a := v.Any().(type) {
+	case nil:
+		return b
+	case *slog.Source:
+		return e.appendValue(b, slog.GroupValue(
+			slog.String("function", a.Function),
+			slog.String("file", a.File),
+			slog.Int("line", a.Line),
+		))
+	case slog.Level:
+		return appendProtoBytes(b, protoValueString, []byte(a.String()))
+	case []byte:
+		return appendProtoBytes(b, protoValueBytes, a)
+	case json.Marshaler:
+		return appendProtoBytes(b, protoValueJSON, marshalJSONValue(v))
+	case error:
+		return appendProtoBytes(b, protoValueString, []byte(a.Error()))
+	default:
+		return appendProtoBytes(b, protoValueJSON, marshalJSONValue(v))
+	}
+}
+
+// appendProtoBytes appends a length-delimited field (eg: a string, bytes or an embedded message) to b and returns
+// the extended slice.
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendProtoDuration appends the fields of a google.protobuf.Duration message to b and returns the extended slice.
+func appendProtoDuration(b []byte, d time.Duration) []byte {
+	b = appendProtoVarint(b, 1, uint64(int64(d/time.Second)))
+	return appendProtoVarint(b, 2, uint64(int64(d%time.Second)))
+}
+
+// appendProtoSource appends the fields of a Source message to b and returns the extended slice.
+func appendProtoSource(b []byte, src *slog.Source) []byte {
+	b = appendProtoBytes(b, 1, []byte(src.Function))
+	b = appendProtoBytes(b, 2, []byte(src.File))
+	return appendProtoVarint(b, 3, uint64(int64(src.Line)))
+}
+
+// appendProtoTag appends the key of a field with the given number and wire type to b and returns the extended slice.
+func appendProtoTag(b []byte, field int, wireType uint64) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|wireType)
+}
+
+// appendProtoTimestamp appends the fields of a google.protobuf.Timestamp message to b and returns the extended
+// slice.
+func appendProtoTimestamp(b []byte, t time.Time) []byte {
+	b = appendProtoVarint(b, 1, uint64(t.Unix()))
+	return appendProtoVarint(b, 2, uint64(t.Nanosecond()))
+}
+
+// appendProtoVarint appends a varint field to b and returns the extended slice.
+//
+// Negative int32 and int64 values must be converted using a plain conversion to uint64, as protobuf does, and sint32
+// and sint64 values must be converted using [zigzag].
+func appendProtoVarint(b []byte, field int, u uint64) []byte {
+	b = appendProtoTag(b, field, protoVarint)
+	return binary.AppendUvarint(b, u)
+}
+
+// zigzag returns the ZigZag encoding of the given signed integer used by the sint32 and sint64 protobuf types.
+func zigzag(i int64) uint64 {
+	return uint64(i<<1) ^ uint64(i>>63)
+}
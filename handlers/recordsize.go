@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+)
+
+const (
+	// RecordSizeDropAttrs drops the largest attributes of an oversized record, one at a time, until the record fits
+	// and adds an attribute holding the keys of the dropped attributes.
+	//
+	// If the record still does not fit once all of its attributes have been dropped, it is summarized as described
+	// for [RecordSizeSummarize].
+	RecordSizeDropAttrs RecordSizeStrategy = "drop_attrs"
+
+	// RecordSizeSummarize replaces an oversized record with a summary record at the same level holding the beginning
+	// of the original message and the original size of the record.
+	RecordSizeSummarize RecordSizeStrategy = "summarize"
+
+	// RecordSizeTruncateMessage truncates the message of an oversized record until the record fits.
+	//
+	// If the record still does not fit with an empty message, it is summarized as described for
+	// [RecordSizeSummarize].
+	RecordSizeTruncateMessage RecordSizeStrategy = "truncate_message"
+)
+
+const (
+	// maxSummaryMessageLength is the maximum number of characters of the original message held by a summary record,
+	// which is further limited to a quarter of the maximum record size.
+	maxSummaryMessageLength = 256
+)
+
+var (
+	// DefaultDroppedAttrsKey is the default key of the attribute which holds the keys of the attributes dropped from
+	// an oversized record using [RecordSizeDropAttrs].
+	//
+	// Setting this value changes the default globally for the package.
+	DefaultDroppedAttrsKey = "dropped_attrs"
+
+	// DefaultRecordSizeStrategy is the default strategy used to make oversized records fit within the maximum record
+	// size of a handler.
+	//
+	// This value is used when a handler's maximum record size is set but its record size strategy is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#FileHandlerOptions
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
+	DefaultRecordSizeStrategy = RecordSizeTruncateMessage
+)
+
+// RecordSizeStrategy is the strategy used to make a record which exceeds the maximum record size of a handler fit.
+type RecordSizeStrategy string
+
+// IsValid returns whether or not the strategy is one of the strategies defined in the package or empty.
+func (s RecordSizeStrategy) IsValid() bool {
+	switch s {
+	case RecordSizeDropAttrs, RecordSizeSummarize, RecordSizeTruncateMessage, "":
+		return true
+	}
+	return false
+}
+
+// encodeWithinSize encodes the record into the buffer using the given function and, if the encoded record is larger
+// than maxBytes, applies the given strategy and encodes the record again until it fits.
+//
+// The encode function must append the encoded record to the buffer. If maxBytes is less than or equal to 0, the
+// record is encoded as it is. If the record cannot be made to fit, the smallest version of it is written anyway.
+func encodeWithinSize(buf *bytes.Buffer, r slog.Record, maxBytes int, strategy RecordSizeStrategy,
+	encode func(*bytes.Buffer, slog.Record) error) error {
+
+	start := buf.Len()
+	if err := encode(buf, r); err != nil {
+		return err
+	}
+	size := buf.Len() - start
+	if maxBytes <= 0 || size <= maxBytes {
+		return nil
+	}
+	originalSize := size
+
+	// try to make the record fit using the strategy
+	if strategy == "" {
+		strategy = DefaultRecordSizeStrategy
+	}
+	switch strategy {
+	case RecordSizeDropAttrs:
+		attrs := make([]slog.Attr, 0, r.NumAttrs())
+		sizes := make([]int, 0, r.NumAttrs())
+		r.Attrs(func(attr slog.Attr) bool {
+			attrs = append(attrs, attr)
+			sizes = append(sizes, encodedAttrSize(attr))
+			return true
+		})
+		var dropped []string
+		for len(attrs) > 0 {
+			largest := slices.Index(sizes, slices.Max(sizes))
+			dropped = append(dropped, attrs[largest].Key)
+			attrs = slices.Delete(attrs, largest, largest+1)
+			sizes = slices.Delete(sizes, largest, largest+1)
+
+			record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+			record.AddAttrs(attrs...)
+			record.AddAttrs(slog.Any(DefaultDroppedAttrsKey, dropped))
+			buf.Truncate(start)
+			if err := encode(buf, record); err != nil {
+				return err
+			}
+			if buf.Len()-start <= maxBytes {
+				return nil
+			}
+		}
+	case RecordSizeTruncateMessage:
+		msg := r.Message
+		for len(msg) > 0 {
+			keep := max(len(msg)-(size-maxBytes)-len("…"), 0)
+			for keep > 0 && !utf8.RuneStart(msg[keep]) {
+				keep--
+			}
+			msg = msg[:keep]
+
+			record := r.Clone()
+			record.Message = msg + "…"
+			buf.Truncate(start)
+			if err := encode(buf, record); err != nil {
+				return err
+			}
+			size = buf.Len() - start
+			if size <= maxBytes {
+				return nil
+			}
+		}
+	}
+
+	// replace the record with a summary
+	summary := slog.NewRecord(r.Time, r.Level, "record exceeded the maximum size and was summarized", r.PC)
+	summary.AddAttrs(
+		slog.String("original_message", truncateString(r.Message, min(maxSummaryMessageLength, maxBytes/4))),
+		slog.Int("original_size", originalSize),
+		slog.Int("max_size", maxBytes),
+	)
+	buf.Truncate(start)
+	return encode(buf, summary)
+}
+
+// encodedAttrSize returns the approximate size of the attribute once it is encoded.
+func encodedAttrSize(attr slog.Attr) int {
+	var buf bytes.Buffer
+	attr.Value = attr.Value.Resolve()
+	appendJSONMember(&buf, attr, "", 0)
+	return buf.Len()
+}
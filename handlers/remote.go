@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// remoteConfigMaxSize is the maximum size (in bytes) of a remote configuration document.
+	remoteConfigMaxSize = 16 * 1024 * 1024
+)
+
+var (
+	// DefaultRemoteConfigRefreshInterval is the default interval at which a remote configuration is checked for
+	// changes.
+	//
+	// This value is used when the refresh interval in [RemoteConfigOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#RemoteConfigOptions
+	DefaultRemoteConfigRefreshInterval = time.Minute
+
+	// DefaultRemoteConfigSignatureHeader is the default name of the HTTP response header holding the signature of a
+	// remote configuration.
+	//
+	// This value is used when the signature header in [RemoteConfigOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#RemoteConfigOptions
+	DefaultRemoteConfigSignatureHeader = "X-Xlog-Signature"
+
+	// DefaultRemoteConfigTimeout is the default duration to wait for a remote configuration to be fetched.
+	//
+	// This value is used when the client in [RemoteConfigOptions] is nil.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#RemoteConfigOptions
+	DefaultRemoteConfigTimeout = 30 * time.Second
+)
+
+// RemoteConfigOptions holds the options for a [RemoteConfigLoader].
+type RemoteConfigOptions struct {
+	// BuildCallback is passed to [xlog.HandlerBuilder.Build] whenever a handler is built from the configuration.
+	//
+	// The default behavior is to build the handler without modifying its options.
+	BuildCallback xlog.BuildHandlerCallbackFn
+
+	// Client is the HTTP client used to fetch the configuration.
+	//
+	// The default behavior is to use a client which times out after the default timeout defined in the package.
+	Client *http.Client
+
+	// ErrorHandler is a function that's called to process any errors that occur while refreshing the configuration
+	// in [RemoteConfigLoader.Watch]. The record passed to the function is always nil.
+	//
+	// The current handler is kept whenever the configuration cannot be fetched, verified or built.
+	//
+	// The default behavior is to ignore these errors.
+	ErrorHandler xlog.ErrorHandlerFn
+
+	// Headers holds additional headers to send with each request (eg: an authorization header).
+	Headers map[string]string
+
+	// PublicKey is the Ed25519 public key used to verify the signature of the configuration.
+	//
+	// When set, the server must return the base64-encoded Ed25519 signature of the response body in the signature
+	// header and configurations with missing or invalid signatures are rejected.
+	//
+	// The default behavior is to not verify the configuration.
+	PublicKey ed25519.PublicKey
+
+	// RefreshInterval is the interval at which the configuration is checked for changes.
+	//
+	// Each check sends the ETag and Last-Modified values from the previous response, so servers which support
+	// conditional requests only return the configuration when it has changed.
+	//
+	// The default behavior is defined by the default refresh interval setting defined in the package.
+	RefreshInterval time.Duration
+
+	// RequestSigner is called to modify each request before it is sent.
+	//
+	// This can be used to sign requests for private objects (eg: using AWS Signature Version 4 for S3 objects).
+	//
+	// The default behavior is to send requests without modification.
+	RequestSigner func(r *http.Request) error
+
+	// SignatureHeader is the name of the HTTP response header holding the signature of the configuration.
+	//
+	// The default behavior is defined by the default signature header setting defined in the package.
+	SignatureHeader string
+
+	// URL is the location of the configuration.
+	//
+	// The URL may use the "http", "https" or "s3" scheme. S3 URLs take the form "s3://bucket/key" and are fetched from
+	// the virtual-hosted endpoint for the bucket. Add a "region" query parameter to use a regional endpoint (eg:
+	// "s3://bucket/key?region=us-east-2").
+	//
+	// This value is required.
+	URL string
+}
+
+// RemoteConfigLoader fetches a handler configuration document from a remote location and builds handlers from it.
+//
+// The document holds a handler type and its options, exactly as described by [ConfigSchema]:
+//
+//	{"type": "file", "options": {"format": "json"}}
+//
+// All methods are safe to call concurrently.
+type RemoteConfigLoader struct {
+	// unexported variables
+	mu      sync.Mutex           // protects the version of the last configuration loaded
+	options RemoteConfigOptions  // loader options
+	url     string               // HTTP(S) URL from which the configuration is fetched
+	version *remoteConfigVersion // version of the last configuration loaded, if any
+}
+
+// remoteConfig is the configuration document fetched by a [RemoteConfigLoader].
+type remoteConfig struct {
+	HandlerType    string            `json:"type"`
+	HandlerOptions map[string]any    `json:"options"`
+	Loggers        map[string]string `json:"loggers,omitempty"`
+	Wrap           []WrapperConfig   `json:"wrap,omitempty"`
+}
+
+// remoteConfigUpdate holds a changed configuration fetched by a [RemoteConfigLoader] which has not been applied yet.
+type remoteConfigUpdate struct {
+	builder xlog.HandlerBuilder // builder for the handler described by the configuration
+	loggers map[string]string   // level overrides for named loggers, if any
+	version remoteConfigVersion // version of the configuration
+}
+
+// remoteConfigVersion identifies a version of the configuration fetched by a [RemoteConfigLoader].
+type remoteConfigVersion struct {
+	digest       [sha256.Size]byte // digest of the configuration
+	etag         string            // ETag of the configuration
+	lastModified string            // Last-Modified time of the configuration
+}
+
+// NewRemoteConfigLoader creates a new [RemoteConfigLoader] object with the given options.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func NewRemoteConfigLoader(options RemoteConfigOptions) (*RemoteConfigLoader, xerrors.Error) {
+	configURL, err := remoteConfigURL(options.URL)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.OptionsValidationError, err, "invalid remote configuration URL '%s': %s",
+			options.URL, err.Error()).WithAttr("url", options.URL)
+	}
+	if options.PublicKey != nil && len(options.PublicKey) != ed25519.PublicKeySize {
+		return nil, xerrors.Newf(xlog.OptionsValidationError, "public key must be %d bytes, but got %d bytes",
+			ed25519.PublicKeySize, len(options.PublicKey))
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{
+			Timeout: DefaultRemoteConfigTimeout,
+		}
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = DefaultRemoteConfigRefreshInterval
+	}
+	if options.SignatureHeader == "" {
+		options.SignatureHeader = DefaultRemoteConfigSignatureHeader
+	}
+	return &RemoteConfigLoader{
+		options: options,
+		url:     configURL,
+	}, nil
+}
+
+// Load fetches the configuration and returns a builder for the handler it describes.
+//
+// If the configuration holds a "loggers" map of logger names to levels, the level overrides for named loggers are
+// replaced with them using [xlog.SetLoggerLevels].
+//
+// If the configuration has not changed since it was last loaded successfully, nil is returned for both the builder
+// and the error. Changes are detected using conditional requests when the server supports them and by comparing the
+// content of the configuration otherwise.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.HTTPClientError]: failed to send the HTTP request
+//   - [xlog.HTTPRequestError]: failed to construct or sign the HTTP request
+//   - [xlog.HTTPResponseError]: failed to process the HTTP response
+//   - [xlog.MarshalError]: the configuration is not valid JSON
+//   - [xlog.OptionsValidationError]: one or more logger levels are invalid
+//   - [xlog.SignatureVerificationError]: the configuration's signature is missing or invalid
+//   - [xlog.UnsupportedHandlerType]: unknown or unsupported handler type was encountered
+//
+// In addition, the function may return any error returned by [NewBuilderFromConfig].
+func (l *RemoteConfigLoader) Load(ctx context.Context) (xlog.HandlerBuilder, xerrors.Error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	update, err := l.fetch(ctx)
+	if err != nil || update == nil {
+		return nil, err
+	}
+	l.apply(update)
+	return update.builder, nil
+}
+
+// Watch loads the configuration, builds a handler from it and swaps it into the given handler. It then checks the
+// configuration for changes at the configured refresh interval, swapping in a new handler whenever it changes, until
+// the context is canceled.
+//
+// Whenever a handler created by the loader is replaced, it is closed if it has a Close method once the records still
+// being handled by it have finished or [xlog.DefaultSwapDrainTimeout] has passed. Any errors that occur after the
+// first handler is swapped in are passed to the ErrorHandler. If the configuration cannot be reloaded, the current
+// handler is kept, while an error closing a replaced handler does not affect the new handler.
+//
+// The level overrides for named loggers held by a configuration are only applied once a handler built from it has
+// been swapped in. A configuration whose handler cannot be built is fetched and built again at the next refresh.
+//
+// This function may return any error returned by [RemoteConfigLoader.Load] or [xlog.HandlerBuilder.Build] while
+// loading the first handler. It returns nil once the context is canceled.
+func (l *RemoteConfigLoader) Watch(ctx context.Context, target *xlog.SwappableHandler) xerrors.Error {
+	if target == nil {
+		return xerrors.New(xlog.InvalidParameter, "target handler cannot be nil")
+	}
+	// the original handler was not created by the loader, so it is not closed
+	if _, err := l.refresh(ctx, target); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(l.options.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		old, err := l.refresh(ctx, target)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.handleError(ctx, "failed to reload configuration", err)
+			}
+			continue
+		}
+		if closer, ok := old.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				l.handleError(ctx, "failed to close previous handler", err)
+			}
+		}
+	}
+}
+
+// apply replaces the level overrides for named loggers with those of the given configuration, if it holds any, and
+// remembers its version so that it is not loaded again until it changes. The caller must hold the lock.
+func (l *RemoteConfigLoader) apply(update *remoteConfigUpdate) {
+	if update.loggers != nil {
+		// the levels were validated when the configuration was fetched
+		_ = xlog.SetLoggerLevels(update.loggers)
+	}
+	l.version = &update.version
+}
+
+// fetch fetches the configuration and returns it without applying it, or nil if it has not changed since it was last
+// applied. The caller must hold the lock.
+//
+// This function may return any error described by [RemoteConfigLoader.Load].
+func (l *RemoteConfigLoader) fetch(ctx context.Context) (*remoteConfigUpdate, xerrors.Error) {
+	// construct the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.HTTPRequestError, err, "failed to create HTTP request: %s", err.Error()).
+			WithAttr("url", l.url)
+	}
+	for k, v := range l.options.Headers {
+		req.Header.Set(k, v)
+	}
+	if l.version != nil && l.version.etag != "" {
+		req.Header.Set("If-None-Match", l.version.etag)
+	}
+	if l.version != nil && l.version.lastModified != "" {
+		req.Header.Set("If-Modified-Since", l.version.lastModified)
+	}
+	if l.options.RequestSigner != nil {
+		if err := l.options.RequestSigner(req); err != nil {
+			return nil, xerrors.Wrapf(xlog.HTTPRequestError, err, "failed to sign HTTP request: %s", err.Error()).
+				WithAttr("url", l.url)
+		}
+	}
+
+	// fetch the configuration
+	resp, err := l.options.Client.Do(req)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.HTTPClientError, err, "failed to execute HTTP request: %s", err.Error()).
+			WithAttr("url", l.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, xerrors.Newf(xlog.HTTPResponseError, "unexpected HTTP status code %d while fetching configuration",
+			resp.StatusCode).WithAttrs(map[string]any{
+			"status_code": resp.StatusCode,
+			"url":         l.url,
+		})
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, remoteConfigMaxSize+1))
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.HTTPResponseError, err, "failed to read HTTP response: %s", err.Error()).
+			WithAttr("url", l.url)
+	}
+	if len(body) > remoteConfigMaxSize {
+		return nil, xerrors.Newf(xlog.HTTPResponseError, "configuration is larger than %d bytes",
+			remoteConfigMaxSize).WithAttr("url", l.url)
+	}
+
+	digest := sha256.Sum256(body)
+	if l.version != nil && digest == l.version.digest {
+		return nil, nil
+	}
+
+	// verify the signature
+	if l.options.PublicKey != nil {
+		signature, err := base64.StdEncoding.DecodeString(resp.Header.Get(l.options.SignatureHeader))
+		if err != nil || len(signature) == 0 {
+			if err == nil {
+				err = errors.New("signature is missing")
+			}
+			return nil, xerrors.Wrapf(xlog.SignatureVerificationError, err, "failed to decode '%s' header: %s",
+				l.options.SignatureHeader, err.Error()).WithAttr("url", l.url)
+		}
+		if !ed25519.Verify(l.options.PublicKey, body, signature) {
+			return nil, xerrors.New(xlog.SignatureVerificationError, "configuration signature is invalid").
+				WithAttr("url", l.url)
+		}
+	}
+
+	// create the builder
+	var config remoteConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal configuration: %s", err.Error()).
+			WithAttr("url", l.url)
+	}
+	builder, xerr := NewBuilderFromConfig(config.HandlerType, config.HandlerOptions)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if builder, xerr = NewWrappedBuilder(builder, config.Wrap); xerr != nil {
+		return nil, xerr
+	}
+	for name, text := range config.Loggers {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(text)); err != nil {
+			return nil, xerrors.Wrapf(xlog.OptionsValidationError, err, "invalid level '%s' for logger '%s': %s", text,
+				name, err.Error()).WithAttrs(map[string]any{
+				"logger": name,
+				"url":    l.url,
+			})
+		}
+	}
+	return &remoteConfigUpdate{
+		builder: builder,
+		loggers: config.Loggers,
+		version: remoteConfigVersion{
+			digest:       digest,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, nil
+}
+
+// handleError logs the given error as an internal event with the given message and passes it to the ErrorHandler,
+// if one is set.
+func (l *RemoteConfigLoader) handleError(ctx context.Context, msg string, err error) {
+	xlog.LogInternal(ctx, slog.LevelError, xlog.InternalEventConfigReload, msg, slog.String("error", err.Error()),
+		slog.String("url", l.url))
+	if l.options.ErrorHandler != nil {
+		l.options.ErrorHandler(ctx, err, nil)
+	}
+}
+
+// refresh loads the configuration and, if it has changed, builds a new handler and swaps it into the given handler,
+// returning the previous handler once the records still being handled by it have finished or nil if the
+// configuration has not changed.
+func (l *RemoteConfigLoader) refresh(ctx context.Context, target *xlog.SwappableHandler) (slog.Handler, xerrors.Error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	update, err := l.fetch(ctx)
+	if err != nil || update == nil {
+		return nil, err
+	}
+	handler, err := update.builder.Build(l.options.BuildCallback)
+	if err != nil {
+		return nil, err
+	}
+	xlog.LogInternal(ctx, slog.LevelInfo, xlog.InternalEventConfigReload, "reloaded configuration",
+		slog.String("handler_type", update.builder.Type()), slog.String("url", l.url))
+
+	// give the records still being handled by the previous handler a chance to finish, even while shutting down
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), xlog.DefaultSwapDrainTimeout)
+	defer cancel()
+	old, err := target.SwapAndDrain(drainCtx, handler)
+	if err != nil {
+		l.handleError(ctx, "replaced previous handler before all of its records were handled", err)
+	}
+
+	// only apply the levels and remember the version once the handler has been swapped in so that they stay in sync
+	// and failures are retried
+	l.apply(update)
+	return old, nil
+}
+
+// remoteConfigURL converts the given configuration URL into an HTTP(S) URL.
+func remoteConfigURL(rawURL string) (string, error) {
+	if rawURL == "" {
+		return "", errors.New("URL is required")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String(), nil
+	case "s3":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return "", errors.New("S3 URLs must include a bucket and key")
+		}
+		return (&url.URL{Scheme: "https", Host: s3Host(u), Path: u.Path}).String(), nil
+	}
+	return "", fmt.Errorf("unsupported URL scheme '%s'", u.Scheme)
+}
+
+// s3Host returns the virtual-hosted endpoint for the bucket in the given S3 URL, using the regional endpoint if the
+// URL has a "region" query parameter.
+func s3Host(u *url.URL) string {
+	if region := u.Query().Get("region"); region != "" {
+		return fmt.Sprintf("%s.s3.%s.amazonaws.com", u.Host, region)
+	}
+	return u.Host + ".s3.amazonaws.com"
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// TestRemoteConfigLoaderRetriesFailedBuild checks that a configuration whose handler fails to build is neither
+// remembered nor has its levels applied, so that the next refresh builds it again.
+func TestRemoteConfigLoaderRetriesFailedBuild(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, `{"type": "discard", "loggers": {"remotetest": "error"}}`)
+	}))
+	t.Cleanup(server.Close)
+	xlog.SetLoggerHandler("remotetest", slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() {
+		xlog.SetLoggerHandler("remotetest", nil)
+		xlog.SetLoggerLevels(nil)
+	})
+
+	builds := 0
+	l, err := NewRemoteConfigLoader(RemoteConfigOptions{
+		BuildCallback: func(string, any) xerrors.Error {
+			builds++
+			if builds == 1 {
+				return xerrors.New(xlog.OptionsValidationError, "build failed")
+			}
+			return nil
+		},
+		URL: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	target := xlog.NewSwappableHandler(slog.NewTextHandler(io.Discard, nil))
+	logger := xlog.GetLogger("remotetest")
+
+	if _, err := l.refresh(ctx, target); err == nil {
+		t.Fatal("refresh() succeeded, want the build error")
+	}
+	if !logger.Enabled(ctx, slog.LevelInfo) {
+		t.Error("levels were applied although the handler failed to build")
+	}
+	if _, err := l.refresh(ctx, target); err != nil {
+		t.Fatalf("refresh() = %v, want the build to be retried", err)
+	}
+	if builds != 2 {
+		t.Errorf("handler was built %d times, want 2", builds)
+	}
+	if _, ok := target.Handler().(*DiscardHandler); !ok {
+		t.Errorf("target handler is %T, want *DiscardHandler", target.Handler())
+	}
+	if logger.Enabled(ctx, slog.LevelInfo) {
+		t.Error("levels were not applied after the handler was swapped in")
+	}
+	if _, err := l.refresh(ctx, target); err != nil || builds != 2 {
+		t.Errorf("refresh() = %v after %d builds, want the unchanged configuration to be skipped", err, builds)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+var (
+	// DefaultSanitizeMaxDepth is the maximum nesting depth of the structures held by attribute values which are
+	// sanitized by [SanitizeAttr].
+	//
+	// Values nested more deeply, including cyclic structures, are replaced with a string noting that the maximum
+	// depth was exceeded.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SanitizeAttr
+	DefaultSanitizeMaxDepth = 32
+)
+
+// SanitizeAttr is a ReplaceAttr-compatible function (see [slog.HandlerOptions]) which fixes attribute values that
+// would otherwise produce invalid output or output which downstream parsers may reject.
+//
+// The following values are fixed:
+//   - strings holding invalid UTF-8 have each invalid sequence replaced with U+FFFD
+//   - NaN and infinite floats are replaced with the strings "NaN", "+Inf" and "-Inf"
+//   - structures nested more deeply than the default sanitize max depth defined in the package, including cyclic
+//     structures, are replaced with a string noting that the maximum depth was exceeded
+//   - values which cannot be marshalled to JSON are replaced with a string describing the error
+//
+// All other values are returned unchanged.
+func SanitizeAttr(groups []string, attr slog.Attr) slog.Attr {
+	attr, _ = sanitizeAttr(attr)
+	return attr
+}
+
+// replaceAttrWithSanitize wraps the given ReplaceAttr function so that the attribute it returns is sanitized using
+// [SanitizeAttr] and each fix is reported to the given error handler as an [xlog.MarshalError].
+//
+// If next is nil, the sanitized attribute is simply returned. If the error handler is nil, fixes are not reported.
+func replaceAttrWithSanitize(
+	next func([]string, slog.Attr) slog.Attr, errorHandler xlog.ErrorHandlerFn) func([]string, slog.Attr) slog.Attr {
+
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if next != nil {
+			attr = next(groups, attr)
+			if attr.Key == "" {
+				return attr
+			}
+		}
+		attr, problem := sanitizeAttr(attr)
+		if problem != "" && errorHandler != nil {
+			key := strings.Join(append(slices.Clip(groups), attr.Key), ".")
+			_ = errorHandler(context.Background(), xerrors.Newf(xlog.MarshalError, "sanitized attribute '%s': %s",
+				key, problem).WithAttr("key", key), nil)
+		}
+		return attr
+	}
+}
+
+// sanitizeAttr fixes the value of the given resolved attribute as described by [SanitizeAttr] and returns it along
+// with a description of the problem which was fixed, if any.
+func sanitizeAttr(attr slog.Attr) (slog.Attr, string) {
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		if s := attr.Value.String(); !utf8.ValidString(s) {
+			attr.Value = slog.StringValue(strings.ToValidUTF8(s, string(utf8.RuneError)))
+			return attr, "invalid UTF-8"
+		}
+	case slog.KindFloat64:
+		if f := attr.Value.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			attr.Value = slog.StringValue(strconv.FormatFloat(f, 'g', -1, 64))
+			return attr, "unsupported float value"
+		}
+	case slog.KindAny:
+		switch v := attr.Value.Any().(type) {
+		case nil, error, json.Marshaler, encoding.TextMarshaler, *slog.Source, slog.Level:
+			return attr, ""
+		default:
+			if exceedsDepth(reflect.ValueOf(v), 0) {
+				attr.Value = slog.StringValue(fmt.Sprintf("!TRUNCATED: maximum depth of %d exceeded",
+					DefaultSanitizeMaxDepth))
+				return attr, "maximum depth exceeded"
+			}
+			if _, err := marshalJSON(v); err != nil {
+				attr.Value = slog.StringValue(fmt.Sprintf("!ERROR:%s", err.Error()))
+				return attr, err.Error()
+			}
+		}
+	}
+	return attr, ""
+}
+
+// exceedsDepth returns whether or not the given value holds structures nested more deeply than the default sanitize
+// max depth defined in the package, starting at the given depth.
+func exceedsDepth(v reflect.Value, depth int) bool {
+	if depth > DefaultSanitizeMaxDepth {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return !v.IsNil() && exceedsDepth(v.Elem(), depth+1)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() && exceedsDepth(v.Field(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			if exceedsDepth(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := range v.Len() {
+			if exceedsDepth(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// schemaDialect is the JSON Schema dialect used by the generated schemas.
+	schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+	// schemaModulePath is the path of the module whose types are converted into schemas by inspecting their fields.
+	schemaModulePath = "go.innotegrity.dev/xlog"
+)
+
+var (
+	// levelSchema is the schema for a log level, which is a level name optionally followed by an offset.
+	levelSchema = map[string]any{
+		"type":    "string",
+		"pattern": "^(" + caseInsensitivePattern("debug|info|warn|error") + ")([+-][0-9]+)?$",
+	}
+
+	// _schemas holds the registered handler option schemas.
+	_schemas = map[string]handlerSchema{}
+)
+
+// SchemaMetadata holds additional details about handler options which cannot be derived from the struct tags of
+// the options type alone.
+type SchemaMetadata struct {
+	// Properties holds additional schema keywords for individual options, keyed by the option's JSON name.
+	//
+	// The keywords are merged into the schema generated for the option, replacing any generated keywords with the
+	// same name. Options nested inside of objects may be addressed using a dotted path (eg: "file.path").
+	Properties map[string]map[string]any
+
+	// Required holds the JSON names of any top-level options which must be present.
+	Required []string
+}
+
+// handlerSchema holds the registration details for a handler type's option schema.
+type handlerSchema struct {
+	metadata    SchemaMetadata // additional details about the options
+	optionsType reflect.Type   // type whose JSON struct tags describe the options
+}
+
+// ConfigSchema returns a JSON Schema describing a handler configuration document, which holds a handler type and its
+// options, for all of the registered handler types.
+//
+// The options for each handler type with a registered schema are validated against that schema. Aliases registered
+// using [RegisterAlias] share the schema of their handler type, except that options given a default value by the alias
+// are no longer required. The options for handler types registered using [RegisterBuilder] without a schema may hold
+// any values. Each handler may also list the wrappers, registered using [RegisterWrapper], to apply to it once it is
+// built, and the top-level document may hold a "loggers" map of named logger levels (see [xlog.SetLoggerLevels]). The
+// returned map can be encoded to JSON and used by editors and CI pipelines to check configuration files before they are
+// deployed.
+func ConfigSchema() map[string]any {
+	handlerTypes := slices.AppendSeq(slices.Collect(maps.Keys(_builders)), maps.Keys(_aliases))
+	slices.Sort(handlerTypes)
+
+	defs := map[string]any{}
+	var conditions []any
+	for _, handlerType := range handlerTypes {
+		schema, ok := optionsSchema(handlerType)
+		if !ok {
+			continue
+		}
+		defs[handlerType] = schema
+		conditions = append(conditions, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{
+					"type": map[string]any{"const": handlerType},
+				},
+			},
+			"then": map[string]any{
+				"properties": map[string]any{
+					"options": map[string]any{"$ref": "#/$defs/" + handlerType},
+				},
+			},
+		})
+	}
+	wrapperTypeSchema := map[string]any{
+		"type": "string",
+		"enum": slices.Sorted(maps.Keys(_wrappers)),
+	}
+
+	handler := map[string]any{
+		"type":     "object",
+		"required": []string{"type"},
+		"properties": map[string]any{
+			"type": map[string]any{
+				"type": "string",
+				"enum": handlerTypes,
+			},
+			"loggers": map[string]any{
+				"type":                 "object",
+				"additionalProperties": levelSchema,
+			},
+			"options": map[string]any{
+				"type": "object",
+			},
+			"wrap": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"oneOf": []any{
+						wrapperTypeSchema,
+						map[string]any{
+							"type":     "object",
+							"required": []string{"type"},
+							"properties": map[string]any{
+								"type":    wrapperTypeSchema,
+								"options": map[string]any{"type": "object"},
+							},
+							"additionalProperties": false,
+						},
+					},
+				},
+			},
+		},
+		"additionalProperties": false,
+	}
+	if len(conditions) > 0 {
+		handler["allOf"] = conditions
+	}
+	defs["handler"] = handler
+
+	return map[string]any{
+		"$schema": schemaDialect,
+		"$ref":    "#/$defs/handler",
+		"$defs":   defs,
+	}
+}
+
+// HandlerSchema returns a JSON Schema describing the options for the given handler type or alias.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.UnsupportedHandlerType]: no schema has been registered for the handler type
+func HandlerSchema(handlerType string) (map[string]any, xerrors.Error) {
+	handlerType = strings.TrimSpace(strings.ToLower(handlerType))
+	if _, ok := optionsSchema(handlerType); !ok {
+		return nil, xerrors.Newf(xlog.UnsupportedHandlerType, "%s: no schema registered for handler type",
+			handlerType).WithAttr("type", handlerType)
+	}
+
+	// options may refer to other handlers (eg: fanout), so include the definitions for every handler type
+	schema := ConfigSchema()
+	schema["$ref"] = "#/$defs/" + handlerType
+	return schema, nil
+}
+
+// RegisterSchema registers the schema for the options of the given handler type.
+//
+// The schema is generated from the JSON struct tags of the given options value, which should be the struct into which
+// the raw JSON options for the handler are unmarshalled, and combined with the given metadata. Any existing schema
+// for the handler type is replaced.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: the handler type is empty or the options are not a struct
+func RegisterSchema(handlerType string, options any, metadata SchemaMetadata) xerrors.Error {
+	handlerType = strings.TrimSpace(strings.ToLower(handlerType))
+	if handlerType == "" {
+		return xerrors.New(xlog.InvalidParameter, "handler type cannot be empty")
+	}
+	t := reflect.TypeOf(options)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return xerrors.Newf(xlog.InvalidParameter, "options must be a struct, but got %T", options)
+	}
+	_schemas[handlerType] = handlerSchema{
+		metadata:    metadata,
+		optionsType: t,
+	}
+	return nil
+}
+
+// schema generates the schema for the handler's options.
+func (s handlerSchema) schema() map[string]any {
+	schema := schemaForType(s.optionsType)
+	for _, path := range slices.Sorted(maps.Keys(s.metadata.Properties)) {
+		prop := schema
+		for part := range strings.SplitSeq(path, ".") {
+			props, _ := prop["properties"].(map[string]any)
+			if props == nil {
+				props = map[string]any{}
+				prop["properties"] = props
+			}
+			child, _ := props[part].(map[string]any)
+			if child == nil {
+				child = map[string]any{}
+				props[part] = child
+			}
+			prop = child
+		}
+		maps.Copy(prop, s.metadata.Properties[path])
+	}
+	if len(s.metadata.Required) > 0 {
+		schema["required"] = slices.Clone(s.metadata.Required)
+	}
+	return schema
+}
+
+// caseInsensitivePattern converts the given regular expression into one that matches letters regardless of case,
+// since JSON Schema patterns do not support flags.
+func caseInsensitivePattern(pattern string) string {
+	var sb strings.Builder
+	for _, r := range pattern {
+		upper, lower := strings.ToUpper(string(r)), strings.ToLower(string(r))
+		if upper == lower {
+			sb.WriteRune(r)
+			continue
+		}
+		sb.WriteString("[" + upper + lower + "]")
+	}
+	return sb.String()
+}
+
+// optionsSchema generates the schema for the options of the given handler type or alias, returning false if no
+// schema has been registered for the handler type.
+func optionsSchema(handlerType string) (map[string]any, bool) {
+	alias, isAlias := _aliases[handlerType]
+	if isAlias {
+		handlerType = alias.handlerType
+	}
+	s, ok := _schemas[handlerType]
+	if !ok {
+		return nil, false
+	}
+	if isAlias {
+		s.metadata.Required = slices.DeleteFunc(slices.Clone(s.metadata.Required), func(name string) bool {
+			_, ok := alias.defaults[name]
+			return ok
+		})
+	}
+	return s.schema(), true
+}
+
+// schemaForType generates a schema for values of the given type based on its kind and, for structs, the JSON struct
+// tags of its fields.
+//
+// Types from outside of this module may have custom JSON encodings, so their schema allows any value. Use
+// [SchemaMetadata] to describe them.
+func schemaForType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.PkgPath() != "" && !strings.HasPrefix(t.PkgPath(), schemaModulePath) {
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Array, reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			props[name] = schemaForType(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+)
+
+var (
+	// DefaultSensitiveKeys holds the patterns, as accepted by [path.Match], of the attribute keys whose values are
+	// masked by all of the built-in encoders and handlers.
+	//
+	// Patterns are matched against the lowercase key of each attribute, without the keys of its groups. Masking keys
+	// at encode time provides defense in depth when a sensitive value is logged without using a secret type. Handlers
+	// may add their own patterns using their SensitiveKeys option.
+	//
+	// Setting this value changes the default globally for the package. Set it to nil to disable masking.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#MaskSensitiveAttr
+	DefaultSensitiveKeys = []string{"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*",
+		"authorization", "cookie", "set-cookie"}
+
+	// DefaultSensitiveValueMask is the value which replaces the value of attributes with a sensitive key.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultSensitiveKeys
+	DefaultSensitiveValueMask = "[REDACTED]"
+)
+
+// IsSensitiveKey returns whether or not the given attribute key matches any of the given patterns, as accepted by
+// [path.Match], ignoring case.
+func IsSensitiveKey(key string, patterns []string) bool {
+	if key == "" || len(patterns) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSensitiveAttr is a ReplaceAttr-compatible function (see [slog.HandlerOptions]) which replaces the value of
+// attributes whose key matches any of the default sensitive keys defined in the package with the default sensitive
+// value mask.
+//
+// Built-in attributes are never masked.
+func MaskSensitiveAttr(groups []string, attr slog.Attr) slog.Attr {
+	return maskSensitiveAttr(groups, attr, DefaultSensitiveKeys)
+}
+
+// maskSensitiveAttr replaces the value of the attribute with the default sensitive value mask if its key matches any
+// of the given patterns, unless it is a built-in attribute.
+func maskSensitiveAttr(groups []string, attr slog.Attr, patterns []string) slog.Attr {
+	if len(groups) == 0 {
+		switch attr.Key {
+		case slog.TimeKey, slog.LevelKey, slog.SourceKey, slog.MessageKey:
+			return attr
+		}
+	}
+	if IsSensitiveKey(attr.Key, patterns) {
+		attr.Value = slog.StringValue(DefaultSensitiveValueMask)
+	}
+	return attr
+}
+
+// replaceAttrWithMask wraps the given ReplaceAttr function so that the value of the attribute it returns is masked if
+// its key matches any of the default sensitive keys defined in the package or the given additional patterns.
+//
+// If next is nil, the masked attribute is simply returned.
+func replaceAttrWithMask(
+	next func([]string, slog.Attr) slog.Attr, patterns []string) func([]string, slog.Attr) slog.Attr {
+
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if next != nil {
+			attr = next(groups, attr)
+			if attr.Key == "" {
+				return attr
+			}
+		}
+		attr = maskSensitiveAttr(groups, attr, DefaultSensitiveKeys)
+		return maskSensitiveAttr(groups, attr, patterns)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -35,6 +40,19 @@ const (
 const (
 	// sentinelOneHECIngestURL is the tokenized form of the ingestion URL for HEC.
 	sentinelOneHECIngestURL = "https://%s/services/collector/event"
+
+	// sentinelOneHECMaxPooledBufferSize is the maximum capacity of a record buffer that will be returned to the
+	// buffer pool. Larger buffers are discarded so that a single large record does not pin memory indefinitely.
+	sentinelOneHECMaxPooledBufferSize = 64 * 1024
+)
+
+var (
+	// sentinelOneHECBufferPool holds the buffers used to format individual records.
+	sentinelOneHECBufferPool = sync.Pool{
+		New: func() any {
+			return &bytes.Buffer{}
+		},
+	}
 )
 
 var (
@@ -49,6 +67,17 @@ var (
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
 	DefaultSentinelOneHECHandlerCallerKey = "caller"
 
+	// DefaultSentinelOneHECHandlerChecksumHeader is the name of the HTTP header which holds the checksum of each
+	// payload sent to the SentinelOne HTTP Event Collector.
+	//
+	// This value is used when the payload checksum in [SentinelOneHECHandlerOptions] is enabled.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
+	DefaultSentinelOneHECHandlerChecksumHeader = "X-Payload-SHA256"
+
 	// DefaultSentinelOneHECHandlerDSCategory is the value to use for dataSource.Category when sending the event
 	// to the SentinelOne HTTP Event Collector.
 	//
@@ -105,10 +134,21 @@ var (
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
 	DefaultSentinelOneHECHandlerLogLevel = slog.LevelInfo
 
+	// DefaultSentinelOneHECHandlerSendQueueSize is the default maximum number of batches waiting to be sent by the
+	// sender workers before logging blocks.
+	//
+	// This value is used when the send queue size in [SentinelOneHECHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SentinelOneHECHandlerOptions
+	DefaultSentinelOneHECHandlerSendQueueSize = 64
+
 	// DefaultSentinelOneHECHandlerSendTimeout is the default duration to wait for an HTTP request to be sent
 	// before the request times out.
 	//
-	// This value is used when the timeout in [SentinelOneHECHandlerOptions] is unset or is set to -1.
+	// This value is used when the timeout in [SentinelOneHECHandlerOptions] is unset.
 	//
 	// Setting this value changes the default globally for the package.
 	//
@@ -159,6 +199,10 @@ func DefaultSentinelOneHECLevelTranslator(l slog.Level) string {
 
 // SentinelOneHECHandlerOptions holds the options for a [SentinelOneHECHandler].
 type SentinelOneHECHandlerOptions struct {
+	// CommonHandlerOptions holds the level, caller, error handler and attribute replacement options shared with
+	// other handlers.
+	xlog.CommonHandlerOptions
+
 	// APIToken holds the URL to use to retrieve the API token for the SentinelOne HTTP Event Collector ingest API.
 	//
 	// This field is required.
@@ -193,6 +237,37 @@ type SentinelOneHECHandlerOptions struct {
 	// to an empty string.
 	CallerKey string `json:"caller_key"`
 
+	// Clock is the source of the current time used to set the time of records according to the TimestampPolicy.
+	//
+	// The default behavior is defined by the default clock defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Clock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultClock
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	Clock xlog.Clock `json:"-"`
+
+	// ConnectMode defines whether the handler connects to the HTTP event collector when it is created, failing if the
+	// collector cannot be reached, or when the first batch is sent.
+	//
+	// Whenever the collector cannot be reached, the handler tries to reconnect in the background at the
+	// ReconnectInterval and reports the problem through [SentinelOneHECHandler.HealthCheck] until it succeeds.
+	//
+	// The default behavior is defined by the default connect mode defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultConnectMode
+	ConnectMode ConnectMode `json:"connect_mode,omitempty"`
+
 	// DisableAsync disables sending events asynchronously and forces everything to be sent synchronously over HTTP.
 	//
 	// Note that when the handler is being closed, it will always synchronously send any data remaining in the buffer.
@@ -228,20 +303,37 @@ type SentinelOneHECHandlerOptions struct {
 	// to an empty string.
 	DSVendor string `json:"datasource_vendor"`
 
-	// ErrorHandler is a function that's called to process any internal errors that may occur when a message is
-	// processed by the underlying handler.
+	// Encoder is a custom encoder used to format events.
 	//
-	// The default behavior is to ignore these errors.
+	// When set, events are formatted using this encoder and the ExpandErrors, FlattenGroups, Sanitize and
+	// SensitiveKeys options are ignored. The encoder receives each record with its attributes nested within the
+	// "event" group, followed by the host, source, sourcetype and site attributes, and must write it as a single JSON
+	// object followed by a newline, which is the format accepted by the HTTP Event Collector. The encoder's own
+	// options control how attributes are replaced.
+	//
+	// The default behavior is to format events as JSON objects using the handler's own options.
 	//
 	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
 	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
 	// set this value from your application, if desired.
 	//
 	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#Encoder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ErrorHandler xlog.ErrorHandlerFn `json:"-"`
+	Encoder xlog.Encoder `json:"-"`
+
+	// ExpandErrors indicates whether or not to expand attributes holding an [xerrors.Error] into a group holding its
+	// code, message, attributes, stack trace and wrapped errors using [ExpandErrorAttr].
+	//
+	// The attribute is expanded after ReplaceAttr is called.
+	//
+	// The default behavior is to log the string returned by the error's Error function.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	ExpandErrors bool `json:"expand_errors"`
 
 	// Fields holds the value of any additional fields to send in the 'fields' field to the HTTP event collector.
 	//
@@ -253,6 +345,22 @@ type SentinelOneHECHandlerOptions struct {
 	// to nil.
 	Fields map[string]any `json:"fields"`
 
+	// FlattenGroups indicates whether or not to flatten nested groups into attributes with dotted keys (eg:
+	// "request.headers.host") for sinks which do not handle nested JSON objects well.
+	//
+	// Groups added by the handler itself (eg: "event" and "dataSource") are flattened as well (eg:
+	// "event.dataSource.name"). Groups are flattened after ReplaceAttr is called.
+	//
+	// The default behavior is to write groups as nested objects.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultFlattenSeparator
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFlattenHandler
+	FlattenGroups bool `json:"flatten_groups"`
+
 	// Host is the value to send for the 'host' field to the HTTP event collector.
 	//
 	// 'host' will not be populated if this value is an empty string.
@@ -264,14 +372,6 @@ type SentinelOneHECHandlerOptions struct {
 	// to an empty string.
 	Host string `json:"host"`
 
-	// IncludeCaller indicates whether or not to include the caller in log messages.
-	//
-	// The default behavior is to not include caller information.
-	//
-	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to false.
-	IncludeCaller bool `json:"include_caller"`
-
 	// IngestHostname is the hostname to use in the SentinelOne HTTP event collector ingestion URL.
 	//
 	// This field is required.
@@ -280,14 +380,6 @@ type SentinelOneHECHandlerOptions struct {
 	// to an empty string.
 	IngestHostname string `json:"ingest_hostname"`
 
-	// Level is the minimum level at which to log messages.
-	//
-	// The default behavior is defined by the default level setting defined in the package.
-	//
-	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to nil.
-	Level *slog.LevelVar `json:"level"`
-
 	// LevelTranslator is a function that's called to translate a standard [slog.Level] into an appropriate "severity"
 	// level for the SentinelOne HTTP Event Collector.
 	//
@@ -307,39 +399,120 @@ type SentinelOneHECHandlerOptions struct {
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
 	LevelTranslator func(slog.Level) string `json:"-"`
 
-	// MaxLevel is the maximum level at which to log messages.
+	// MaxRecordAge is the maximum age of a batch of records, measured from when the batch was formed, at which it is
+	// still sent to the HTTP event collector.
+	//
+	// Batches waiting to be sent by the sender workers or left in the write-ahead log (measured from when they were
+	// written to it) which are older are discarded, removed from the write-ahead log and reported using
+	// [xlog.NotifyDropped] rather than delivering stale records after a long outage. Batches which are sent
+	// synchronously or from their own goroutine never expire.
 	//
-	// The default behavior is to disable any maximum log message level.
+	// The default behavior is to send batches no matter how old they are.
 	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to nil.
-	MaxLevel *slog.LevelVar `json:"max_level,omitempty"`
+	// to 0.
+	MaxRecordAge types.Duration `json:"max_record_age,omitempty"`
+
+	// MaxRecordBytes is the maximum size (in bytes) of each record sent to the HTTP event collector.
+	//
+	// Records which are larger are made to fit using the RecordSizeStrategy, which prevents a single oversized record
+	// from exceeding the request size limit of the collector.
+	//
+	// The default behavior is to not limit the size of records.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	MaxRecordBytes types.Size `json:"max_record_bytes,omitempty"`
 
-	// ReplaceAttr is called to rewrite each non-group attribute before it is logged.
+	// OnDelivered is a function that's called once each batch of records has been accepted by the HTTP event
+	// collector.
+	//
+	// The default behavior is to not report delivered batches.
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
 	//
-	// The attribute's value has been resolved (see [slog.Value.Resolve]). If ReplaceAttr returns a zero Attr, the
-	// attribute is discarded.
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DeliveryCallbackFn
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	OnDelivered xlog.DeliveryCallbackFn `json:"-"`
+
+	// OnFailed is a function that's called once for each batch of records which could not be delivered to the HTTP
+	// event collector, with the error which caused the failure.
 	//
-	// The built-in attributes with keys [slog.TimeKey], [slog.LevelKey], [slog.SourceKey], and [slog.MessageKey]
-	// are passed to this function, except that time is omitted if zero, and source is omitted if IncludeCaller is
-	// false.
+	// The function is called before the error is passed to the ErrorHandler and is not affected by it.
 	//
-	// The default behavior is to not replace any attributes.
+	// The default behavior is to not report failed batches.
 	//
 	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
 	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
 	// set this value from your application, if desired.
 	//
 	// References:
-	//   https://pkg.go.dev/log/slog#TimeKey
-	//   https://pkg.go.dev/log/slog#LevelKey
-	//   https://pkg.go.dev/log/slog#SourceKey
-	//   https://pkg.go.dev/log/slog#MessageKey
-	//   https://pkg.go.dev/log/slog#HandlerOptions
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DeliveryCallbackFn
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
 	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
-	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr `json:"-"`
+	OnFailed xlog.DeliveryCallbackFn `json:"-"`
+
+	// OrderedDelivery indicates whether or not batches must be sent in the order they were created.
+	//
+	// When enabled, a single sender worker is used regardless of the SendWorkers setting, so each batch is only sent
+	// once the batch before it has been sent. This guarantees the order of records at the collector at the cost of
+	// throughput. This option is ignored if DisableAsync is set.
+	//
+	// The default behavior is to send batches in parallel, which may deliver them out of order.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	OrderedDelivery bool `json:"ordered_delivery"`
+
+	// PayloadChecksum indicates whether or not to send the SHA-256 checksum of each payload, before it is compressed,
+	// in the default checksum header defined in the package, so that the collector can verify the integrity of the
+	// payload.
+	//
+	// The checksum is also included in the reports passed to OnDelivered and OnFailed.
+	//
+	// The default behavior is to not compute checksums.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	PayloadChecksum bool `json:"payload_checksum"`
+
+	// ReconnectInterval is the interval at which the handler tries to reconnect to the HTTP event collector in the
+	// background once it cannot be reached.
+	//
+	// The default behavior is defined by the default reconnect interval defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultReconnectInterval
+	ReconnectInterval types.Duration `json:"reconnect_interval,omitempty"`
+
+	// RecordSizeStrategy is the strategy used to make records which are larger than MaxRecordBytes fit.
+	//
+	// The default behavior is defined by the default record size strategy defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	RecordSizeStrategy RecordSizeStrategy `json:"record_size_strategy,omitempty"`
+
+	// Sanitize indicates whether or not to fix attribute values which would otherwise produce invalid output (eg:
+	// invalid UTF-8, NaN floats or cyclic structures) using [SanitizeAttr].
+	//
+	// Each fix is reported to the ErrorHandler, if one is set, as an [xlog.MarshalError] with a nil record. Values are
+	// sanitized after ReplaceAttr is called.
+	//
+	// The default behavior is to write values as they are.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	Sanitize bool `json:"sanitize"`
 
 	// Scope is the SentinelOne scope that will be passed in the S1-Scope header.
 	//
@@ -354,15 +527,54 @@ type SentinelOneHECHandlerOptions struct {
 	// to an empty string.
 	Scope string `json:"scope"`
 
+	// SendQueueSize is the maximum number of batches waiting to be sent by the sender workers.
+	//
+	// When the queue is full, logging blocks until a worker takes the next batch, which applies backpressure to the
+	// application rather than using an unbounded amount of memory. This option is ignored if there are no sender
+	// workers.
+	//
+	// The default behavior is defined by the default send queue size setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to 0.
+	SendQueueSize int `json:"send_queue_size,omitempty"`
+
 	// SendTimeout is the duration to wait for an HTTP request to complete before timing out.
 	//
 	// Set this to 0 if you wish to disable timeouts.
 	//
 	// The default behavior is to wait the duration specified by the package default before timing out.
 	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, is null or is -1, it
+	// will be left unset.
+	SendTimeout xlog.Optional[types.Duration] `json:"send_timeout,omitzero"`
+
+	// SendWorkers is the number of sender workers which send batches to the HTTP event collector in parallel.
+	//
+	// Each batch (ie: the contents of the buffer when it fills up) is queued and sent by the next available worker,
+	// so a single slow HTTP round trip does not cap the throughput of the handler. This option is ignored if
+	// DisableAsync is set.
+	//
+	// The default behavior is to send each batch from its own goroutine without any limit.
+	//
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
-	// to -1.
-	SendTimeout types.Duration `json:"send_timeout"`
+	// to 0.
+	SendWorkers int `json:"send_workers,omitempty"`
+
+	// SensitiveKeys holds additional patterns, as accepted by [path.Match], of the attribute keys whose values are
+	// masked by the handler, in addition to the default sensitive keys defined in the package.
+	//
+	// Patterns are matched against the lowercase key of each attribute, without the keys of its groups. Values are
+	// masked after ReplaceAttr is called.
+	//
+	// The default behavior is to only mask the default sensitive keys.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#DefaultSensitiveKeys
+	SensitiveKeys []string `json:"sensitive_keys,omitempty"`
 
 	// Source is the value to send for the 'source' field to the HTTP event collector.
 	//
@@ -374,27 +586,67 @@ type SentinelOneHECHandlerOptions struct {
 	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
 	// to an empty string.
 	Source string `json:"source"`
+
+	// TimestampPolicy defines how the time of each record is treated (eg: whether records with a zero time are given
+	// the current time from the Clock, dropped or logged without a time).
+	//
+	// The default behavior is defined by the default timestamp policy defined in the xlog package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#DefaultTimestampPolicy
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimestampPolicy
+	TimestampPolicy xlog.TimestampPolicy `json:"timestamp_policy,omitempty"`
+
+	// WALDir is the path to a directory in which each batch of records is persisted before it is sent, enabling
+	// at-least-once delivery.
+	//
+	// A batch is only removed from the directory once the HTTP event collector has accepted it. Batches left in the
+	// directory (eg: because the process crashed or the collector was unavailable) are sent again when the next
+	// handler using the directory is created, so the collector may receive a batch more than once. Only one handler
+	// at a time should use a directory.
+	//
+	// The default behavior is to not persist batches, so batches which have not been delivered are lost when the
+	// process exits.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	WALDir string `json:"wal_dir,omitempty"`
 }
 
 // jsonSentinelOneHECHandlerOptions is an alternate form of [SentinelOneHECHandlerOptions] that is used during
 // unmarshalling to prevent infinite recursion.
 type jsonSentinelOneHECHandlerOptions struct {
-	APIToken       secrets.GenericSecret `json:"api_token"`
-	BufferSize     types.Size            `json:"buffer_size"`
-	CallerKey      string                `json:"caller_key"`
-	DisableAsync   bool                  `json:"disable_async"`
-	DSCategory     string                `json:"datasource_category"`
-	DSName         string                `json:"datasource_name"`
-	DSVendor       string                `json:"datasource_vendor"`
-	Fields         map[string]any        `json:"fields"`
-	Host           string                `json:"host"`
-	IncludeCaller  bool                  `json:"include_caller"`
-	IngestHostname string                `json:"ingest_hostname"`
-	Level          string                `json:"level"`
-	MaxLevel       string                `json:"max_level"`
-	Scope          string                `json:"scope"`
-	SendTimeout    *types.Duration       `json:"send_timeout"`
-	Source         string                `json:"source"`
+	APIToken           secrets.GenericSecret         `json:"api_token"`
+	BufferSize         types.Size                    `json:"buffer_size"`
+	CallerKey          string                        `json:"caller_key"`
+	ConnectMode        string                        `json:"connect_mode"`
+	DisableAsync       bool                          `json:"disable_async"`
+	DSCategory         string                        `json:"datasource_category"`
+	DSName             string                        `json:"datasource_name"`
+	DSVendor           string                        `json:"datasource_vendor"`
+	ExpandErrors       bool                          `json:"expand_errors"`
+	Fields             map[string]any                `json:"fields"`
+	FlattenGroups      bool                          `json:"flatten_groups"`
+	Host               string                        `json:"host"`
+	IngestHostname     string                        `json:"ingest_hostname"`
+	MaxRecordAge       types.Duration                `json:"max_record_age"`
+	MaxRecordBytes     types.Size                    `json:"max_record_bytes"`
+	OrderedDelivery    bool                          `json:"ordered_delivery"`
+	PayloadChecksum    bool                          `json:"payload_checksum"`
+	ReconnectInterval  types.Duration                `json:"reconnect_interval"`
+	RecordSizeStrategy string                        `json:"record_size_strategy"`
+	Sanitize           bool                          `json:"sanitize"`
+	Scope              string                        `json:"scope"`
+	SendQueueSize      int                           `json:"send_queue_size"`
+	SendTimeout        xlog.Optional[types.Duration] `json:"send_timeout"`
+	SendWorkers        int                           `json:"send_workers"`
+	SensitiveKeys      []string                      `json:"sensitive_keys"`
+	Source             string                        `json:"source"`
+	TimestampPolicy    string                        `json:"timestamp_policy"`
+	WALDir             string                        `json:"wal_dir"`
 }
 
 // UnmarshalJSON decodes the JSON-encoded data into the current object.
@@ -403,35 +655,30 @@ func (o *SentinelOneHECHandlerOptions) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &opts); err != nil {
 		return err
 	}
+	if err := o.CommonHandlerOptions.UnmarshalJSON(data); err != nil {
+		return err
+	}
 
-	// validate the log level(s)
-	//
-	// note that we purposely leave the level nil here if it's not set so that it can be set when the handler
-	// is created or overridden by the calling application
-	if opts.Level != "" {
-		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
-			return fmt.Errorf("failed to parse level '%s' for console handler: %s", opts.Level, err.Error())
-		}
-		o.Level = &level
+	// validate the connect mode
+	mode := ConnectMode(strings.TrimSpace(strings.ToLower(opts.ConnectMode)))
+	if !mode.IsValid() {
+		return fmt.Errorf("%s: invalid connect mode for SentinelOne HEC handler", opts.ConnectMode)
 	}
-	if opts.MaxLevel != "" {
-		var level slog.LevelVar
-		if err := level.UnmarshalText([]byte(opts.MaxLevel)); err != nil {
-			return fmt.Errorf("failed to parse max level '%s' for console handler: %s", opts.MaxLevel, err.Error())
-		}
-		o.MaxLevel = &level
+	o.ConnectMode = mode
+
+	// validate the record size strategy
+	strategy := RecordSizeStrategy(strings.TrimSpace(strings.ToLower(opts.RecordSizeStrategy)))
+	if !strategy.IsValid() {
+		return fmt.Errorf("%s: invalid record size strategy for SentinelOne HEC handler", opts.RecordSizeStrategy)
 	}
+	o.RecordSizeStrategy = strategy
 
-	// validate the send timeout setting
-	//
-	// note that we purposely set it to -1 here if it's not set so that it can be set when the handler is created or
-	// overridden by the calling application
-	if opts.SendTimeout == nil {
-		o.SendTimeout = -1
-	} else {
-		o.SendTimeout = *opts.SendTimeout
+	// validate the timestamp policy
+	policy := xlog.TimestampPolicy(strings.TrimSpace(strings.ToLower(opts.TimestampPolicy)))
+	if !policy.IsValid() {
+		return fmt.Errorf("%s: invalid timestamp policy for SentinelOne HEC handler", opts.TimestampPolicy)
 	}
+	o.TimestampPolicy = policy
 
 	// copy remaining options
 	o.APIToken = opts.APIToken
@@ -441,49 +688,155 @@ func (o *SentinelOneHECHandlerOptions) UnmarshalJSON(data []byte) error {
 	o.DSCategory = opts.DSCategory
 	o.DSName = opts.DSName
 	o.DSVendor = opts.DSVendor
+	o.ExpandErrors = opts.ExpandErrors
 	o.Fields = opts.Fields
+	o.FlattenGroups = opts.FlattenGroups
 	o.Host = opts.Host
-	o.IncludeCaller = opts.IncludeCaller
 	o.IngestHostname = opts.IngestHostname
+	o.MaxRecordAge = opts.MaxRecordAge
+	o.MaxRecordBytes = opts.MaxRecordBytes
+	o.OrderedDelivery = opts.OrderedDelivery
+	o.PayloadChecksum = opts.PayloadChecksum
+	o.ReconnectInterval = opts.ReconnectInterval
+	o.Sanitize = opts.Sanitize
 	o.Scope = opts.Scope
+	o.SendQueueSize = opts.SendQueueSize
+	o.SendTimeout = legacyDuration(opts.SendTimeout)
+	o.SendWorkers = opts.SendWorkers
+	o.SensitiveKeys = opts.SensitiveKeys
 	o.Source = opts.Source
+	o.WALDir = opts.WALDir
 
 	return nil
 }
 
+// Validate checks the options for problems, returning a single error describing all of them.
+//
+// The API token, ingest hostname and scope are required. Other values which are not set are not treated as problems
+// since they are replaced by defaults when the handler is created, including an unset send timeout.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func (o SentinelOneHECHandlerOptions) Validate() xerrors.Error {
+	var v optionsValidation
+	if len(o.APIToken.Data) == 0 {
+		v.addf("api_token", "value is required")
+	}
+	v.checkNonNegative("buffer_size", int64(o.BufferSize))
+	if !o.ConnectMode.IsValid() {
+		v.addf("connect_mode", "invalid connect mode '%s'", o.ConnectMode)
+	}
+	if o.IngestHostname == "" {
+		v.addf("ingest_hostname", "value is required")
+	}
+	v.checkLevels(o.Level, o.MaxLevel)
+	v.checkNonNegative("max_record_age", int64(o.MaxRecordAge))
+	v.checkNonNegative("max_record_bytes", int64(o.MaxRecordBytes))
+	v.checkNonNegative("reconnect_interval", int64(o.ReconnectInterval))
+	if !o.RecordSizeStrategy.IsValid() {
+		v.addf("record_size_strategy", "invalid record size strategy '%s'", o.RecordSizeStrategy)
+	}
+	if o.Scope == "" {
+		v.addf("scope", "value is required")
+	}
+	v.checkNonNegative("send_queue_size", int64(o.SendQueueSize))
+	v.checkNonNegative("send_timeout", int64(o.SendTimeout.Value()))
+	v.checkNonNegative("send_workers", int64(o.SendWorkers))
+	v.checkPatterns("sensitive_keys", o.SensitiveKeys)
+	if !o.TimestampPolicy.IsValid() {
+		v.addf("timestamp_policy", "invalid timestamp policy '%s'", o.TimestampPolicy)
+	}
+	return v.err(SentinelOneHECHandlerType)
+}
+
+// clone returns a copy of the options which shares no maps or slices with the original.
+//
+// Level variables are intentionally shared so that the level of a handler can be changed after it is created.
+func (o SentinelOneHECHandlerOptions) clone() SentinelOneHECHandlerOptions {
+	o.APIToken.Data = slices.Clone(o.APIToken.Data)
+	o.Fields = maps.Clone(o.Fields)
+	o.SensitiveKeys = slices.Clone(o.SensitiveKeys)
+	return o
+}
+
+// ensure [SentinelOneHECHandlerOptions] implements [xlog.OptionsValidator] interface.
+var _ xlog.OptionsValidator = SentinelOneHECHandlerOptions{}
+
+// ensure [SentinelOneHECHandler] implements [xlog.AsyncHandler] interface.
+var _ xlog.AsyncHandler = &SentinelOneHECHandler{}
+
 // ensure [SentinelOneHECHandler] implements [xlog.ExtendedHandler] interface.
 var _ xlog.ExtendedHandler = &SentinelOneHECHandler{}
 
+// ensure [SentinelOneHECHandler] implements [xlog.BatchHandler] interface.
+var _ xlog.BatchHandler = &SentinelOneHECHandler{}
+
 // ensure [SentinelOneHECHandler] implements [xlog.LevelVarHandler] interface.
 var _ xlog.LevelVarHandler = &SentinelOneHECHandler{}
 
 // SentinelOneHECHandler is a handler that sends events to SentinelOne AI SIEM using its HTTP event collector.
+//
+// The options are copied when the handler is created and are never modified afterward. Handlers derived using
+// WithAttrs or WithGroup share the options, level variables, HTTP client and buffer with the handler they were
+// derived from, so records logged through any of them are sent together and closing any one of them flushes the
+// buffer for all of them.
 type SentinelOneHECHandler struct {
 	// unexported variables
-	attrs        []slog.Attr                  // immuatable attributes for the handler
-	authToken    string                       // authorization token
-	client       *http.Client                 // HTTP client object
-	groups       []string                     // immutable groups for the handler
-	ingestionURL string                       // HEC ingestion URL
-	options      SentinelOneHECHandlerOptions // handler options
-	state        *sentinelOneHECHandlerState  // shared buffer and mutex
+	attrs          []slog.Attr                         // immutable attributes passed to the custom encoder, if any
+	authToken      string                              // authorization token
+	client         *http.Client                        // HTTP client object
+	collectorAttrs []slog.Attr                         // immutable host, source and sourcetype attributes
+	dataSource     slog.Attr                           // immutable dataSource group for the "event" group
+	groups         []string                            // immutable groups for the handler
+	ingestionURL   string                              // HEC ingestion URL
+	options        SentinelOneHECHandlerOptions        // immutable handler options
+	prefix         []byte                              // immutable pre-encoded attributes and open groups
+	prefixSep      bool                                // whether a separator is needed after the prefix
+	replaceAttr    func([]string, slog.Attr) slog.Attr // function used to rewrite attributes
+	state          *sentinelOneHECHandlerState         // shared buffer and mutex
+}
+
+// sentinelOneHECBatch is a batch of records waiting to be sent by a sender worker.
+type sentinelOneHECBatch struct {
+	created time.Time       // time at which the batch was formed or written to the write-ahead log
+	ctx     context.Context // context of the record which filled the buffer
+	id      string          // identifier assigned when the batch was formed
+	payload []byte          // formatted records
+	record  *slog.Record    // record which filled the buffer, if any
+	seqs    sequenceRange   // sequence numbers stamped on the records, if any
+	walPath string          // path to the batch in the write-ahead log, if any
 }
 
 // sentinelOneHECHandlerState holds the shared, mutable state for a handler and its descendants. This includes the
-// buffer and the mutex protecting it.
+// buffer and the mutex protecting it along with the queue of batches waiting to be sent by the sender workers.
 type sentinelOneHECHandlerState struct {
-	mu  sync.Mutex
-	buf *bytes.Buffer
+	mu   sync.Mutex
+	buf  *bytes.Buffer
+	seqs sequenceRange // sequence numbers stamped on the records in the buffer
+
+	conn *connectionMonitor // tracks whether or not the collector can be reached
+	wal  *payloadWAL        // write-ahead log holding batches until they are delivered, if enabled
+
+	closed  bool                     // whether or not the queue has been closed
+	queue   chan sentinelOneHECBatch // batches waiting to be sent, if there are sender workers
+	queueMu sync.RWMutex             // protects closed and sending to the queue
+	workers sync.WaitGroup           // running sender workers
 }
 
 // NewSentinelOneHECHandler creates a new [SentinelOneHECHandler] object with the given options.
 //
+// If a write-ahead log directory is set, any batches left in it are sent again before this function returns when
+// asynchronous sending is disabled, or queued to be sent otherwise.
+//
 // This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the write-ahead log directory
+//   - [xlog.DataWriteError]: failed to create the write-ahead log directory or remove a temporary file left in it
+//   - [xlog.HTTPClientError]: the connect mode is eager and the collector could not be reached
 //   - [xlog.OptionsValidationError]: one or more options are invalid
 func NewSentinelOneHECHandler(options SentinelOneHECHandlerOptions) (*SentinelOneHECHandler, xerrors.Error) {
 	h := &SentinelOneHECHandler{
 		client:  &http.Client{},
-		options: options,
+		options: options.clone(),
 		state: &sentinelOneHECHandlerState{
 			buf: &bytes.Buffer{},
 		},
@@ -547,11 +900,11 @@ func NewSentinelOneHECHandler(options SentinelOneHECHandlerOptions) (*SentinelOn
 		}
 	}
 
-	if h.options.SendTimeout == -1 {
-		h.options.SendTimeout = DefaultSentinelOneHECHandlerSendTimeout
+	if !h.options.SendTimeout.IsSet() {
+		h.options.SendTimeout = xlog.Some(DefaultSentinelOneHECHandlerSendTimeout)
 	}
-	if h.options.SendTimeout > 0 {
-		h.client.Timeout = time.Duration(h.options.SendTimeout)
+	if timeout := h.options.SendTimeout.Value(); timeout > 0 {
+		h.client.Timeout = time.Duration(timeout)
 	}
 
 	if h.options.Source == "" {
@@ -562,40 +915,130 @@ func NewSentinelOneHECHandler(options SentinelOneHECHandlerOptions) (*SentinelOn
 		}
 	}
 
+	// pre-build everything that doesn't change from record to record
+	h.collectorAttrs = []slog.Attr{
+		slog.String("host", h.options.Host),
+		slog.String("source", h.options.Source),
+		slog.String("sourcetype", "gron"),
+	}
+	h.dataSource = slog.Group("dataSource",
+		slog.String("category", h.options.DSCategory),
+		slog.String("name", h.options.DSName),
+		slog.String("vendor", h.options.DSVendor),
+	)
+	h.replaceAttr = sentinelOneHECReplaceAttr(h.options.ReplaceAttr)
+	if h.options.ExpandErrors {
+		h.replaceAttr = replaceAttrWithErrors(h.replaceAttr)
+	}
+	if h.options.Sanitize {
+		h.replaceAttr = replaceAttrWithSanitize(h.replaceAttr, h.options.ErrorHandler)
+	}
+	h.replaceAttr = replaceAttrWithMask(h.replaceAttr, h.options.SensitiveKeys)
+
+	// connect to the collector now, if desired, to fail fast when it cannot be reached
+	if h.options.ConnectMode == "" {
+		h.options.ConnectMode = DefaultConnectMode
+	}
+	h.state.conn = newConnectionMonitor(h.connect, time.Duration(h.options.ReconnectInterval))
+	if h.options.ConnectMode == ConnectEager {
+		ctx, cancel := context.WithCancel(context.Background())
+		if timeout := h.options.SendTimeout.Value(); timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout))
+		}
+		err := h.state.conn.check(ctx)
+		cancel()
+		if err != nil {
+			return nil, err.(xerrors.Error)
+		}
+	}
+
+	// open the write-ahead log, if enabled
+	var pending []string
+	if h.options.WALDir != "" {
+		wal, batches, xerr := openPayloadWAL(h.options.WALDir)
+		if xerr != nil {
+			return nil, xerr
+		}
+		h.state.wal, pending = wal, batches
+	}
+
+	// start the sender workers, if enabled
+	if h.options.SendQueueSize == 0 {
+		h.options.SendQueueSize = DefaultSentinelOneHECHandlerSendQueueSize
+	}
+	workers := h.options.SendWorkers
+	if h.options.OrderedDelivery {
+		workers = 1
+	}
+	if !h.options.DisableAsync && workers > 0 {
+		h.state.queue = make(chan sentinelOneHECBatch, h.options.SendQueueSize)
+		for range workers {
+			h.state.workers.Go(h.sendWorker)
+		}
+	}
+
+	// send any batches left in the write-ahead log by a previous handler
+	h.recoverWAL(pending)
 	return h, nil
 }
 
+// Async always returns true since records are buffered until the next record is handled or the handler is closed
+// and may be sent by a sender worker, even when asynchronous sending is disabled.
+func (h *SentinelOneHECHandler) Async() bool {
+	return true
+}
+
 // ChildHandlers will always return nil as this handler has no child handlers.
 func (h *SentinelOneHECHandler) ChildHandlers() []slog.Handler {
 	return nil
 }
 
 // Close synchronously flushes any data in the buffer to the HTTP event collector.
+//
+// If there are sender workers, the remaining data is queued behind any batches waiting to be sent and Close waits for
+// the workers to send all of them before returning. Records handled after the handler is closed are sent
+// synchronously.
 func (h *SentinelOneHECHandler) Close() error {
 	h.state.mu.Lock()
+	var batch *sentinelOneHECBatch
+	if h.state.buf.Len() > 0 {
+		payload := make([]byte, h.state.buf.Len())
+		copy(payload, h.state.buf.Bytes())
+		h.state.buf.Reset()
+		batch = h.newBatch(context.Background(), nil, payload, h.state.seqs)
+		h.state.seqs = sequenceRange{}
+	}
+	h.state.mu.Unlock()
+	if batch != nil {
+		batch.walPath = h.persist(batch.ctx, nil, batch.payload)
+	}
 
-	// nothing in the buffer to flush
-	if h.state.buf.Len() == 0 {
-		h.state.mu.Unlock()
-		return nil
+	// drain the queue of batches waiting to be sent
+	if h.state.queue != nil {
+		h.state.queueMu.Lock()
+		if !h.state.closed {
+			if batch != nil {
+				h.state.queue <- *batch
+				batch = nil
+			}
+			h.state.closed = true
+			close(h.state.queue)
+		}
+		h.state.queueMu.Unlock()
+		h.state.workers.Wait()
 	}
 
 	// send the remaining buffer content synchronously to ensure everything has been sent
-	payload := make([]byte, h.state.buf.Len())
-	copy(payload, h.state.buf.Bytes())
-	h.state.buf.Reset()
-	h.state.mu.Unlock()
-	h.send(context.Background(), nil, payload)
+	if batch != nil {
+		h.send(*batch)
+	}
+	h.state.conn.close()
 	return nil
 }
 
 // Enabled returns true if the handler should handle the message or false if it should not.
 func (h *SentinelOneHECHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	handlerLevel := h.options.Level.Level()
-	if h.options.MaxLevel == nil {
-		return level >= handlerLevel
-	}
-	return level >= handlerLevel && level <= handlerLevel
+	return h.options.Enabled(level)
 }
 
 // GetLevelVar returns the handler's [slog.LevelVar] for manipulating the minimum logging level.
@@ -610,108 +1053,19 @@ func (h *SentinelOneHECHandler) GetMaxLevelVar() *slog.LevelVar {
 
 // Handle processes the record and handles logging it.
 func (h *SentinelOneHECHandler) Handle(ctx context.Context, r slog.Record) error {
-	// create a *local* buffer to avoid holding the global lock during JSON formatting
-	recordBuf := &bytes.Buffer{}
-
-	// create a temporary JSONHandler that writes to our *local* buffer.
-	tempHandler := slog.Handler(slog.NewJSONHandler(recordBuf, &slog.HandlerOptions{
-		AddSource: false, // don't need the caller here
-		Level:     h.options.Level,
-		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
-			numGroups := len(groups)
-
-			// call the user-defined ReplaceAttr() function if it's set
-			if h.options.ReplaceAttr != nil {
-				attr = h.options.ReplaceAttr(groups, attr)
-			}
-
-			// make sure the "time" key is set to seconds since the epoch
-			if numGroups == 0 && attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime {
-				attr.Key = "time"
-				attr.Value = slog.Int64Value(attr.Value.Time().UnixMilli())
-			}
-
-			// remove the top-level "time", "level" and "msg" keys
-			if numGroups == 0 && (attr.Key == slog.LevelKey || attr.Key == slog.MessageKey) {
-				return slog.Attr{}
-			}
-			return attr
-		},
-	}))
-	if h.attrs != nil {
-		tempHandler = tempHandler.WithAttrs(h.attrs)
-	}
-	if h.groups != nil {
-		for _, group := range h.groups {
-			tempHandler = tempHandler.WithGroup(group)
-		}
-	}
-
-	// copy all of the record's attributes so they can be added to a new record under an "event" group
-	extraAttrs := 2
-	if h.options.IncludeCaller {
-		extraAttrs++
-	}
-	eventAttrs := make([]slog.Attr, 0, r.NumAttrs()+extraAttrs)
-	r.Attrs(func(attr slog.Attr) bool {
-		eventAttrs = append(eventAttrs, attr)
-		return true
-	})
-
-	// add the message to the "event" group
-	eventAttrs = append(eventAttrs, slog.String("message", r.Message))
-
-	// add the time to the "event" group
-	//eventAttrs = append(eventAttrs, slog.Time("time", r.Time))
-
-	// rename event.level to event.severity and modify value
-	var severity string
-	if h.options.LevelTranslator != nil {
-		severity = h.options.LevelTranslator(r.Level)
-	} else {
-		severity = DefaultSentinelOneHECLevelTranslator(r.Level)
-	}
-	eventAttrs = append(eventAttrs, slog.String("severity", severity))
-
-	// add the caller info if desired
-	if h.options.IncludeCaller && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		eventAttrs = append(eventAttrs, slog.Any(h.options.CallerKey, &slog.Source{
-			Function: f.Function,
-			File:     f.File,
-			Line:     f.Line,
-		}))
-	}
-
-	// add dataSource fields
-	eventAttrs = append(eventAttrs, slog.Group("dataSource",
-		slog.String("category", h.options.DSCategory),
-		slog.String("name", h.options.DSName),
-		slog.String("vendor", h.options.DSVendor),
-	))
-
-	// create the new record with the "event" group
-	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
-	if len(eventAttrs) > 0 {
-		record.AddAttrs(slog.GroupAttrs("event", eventAttrs...))
+	r, ok := xlog.ApplyTimestampPolicy(r, h.options.TimestampPolicy, h.options.Clock)
+	if !ok {
+		return nil
 	}
 
-	// add host, source, sourcetype, and site.id fields
-	record.AddAttrs(
-		slog.String("host", h.options.Host),
-		slog.String("source", h.options.Source),
-		slog.String("sourcetype", "gron"),
-	)
-
-	// let the temporary handler format the record into our *local* buffer
-	if err := tempHandler.Handle(ctx, record); err != nil {
-		return h.handleError(ctx, fmt.Errorf(
-			"failed to format log record to send to SentinelOne HTTP event collector: %w", err), &record)
+	// format the record into a *local* buffer to avoid holding the global lock during JSON formatting
+	recordBuf := sentinelOneHECBufferPool.Get().(*bytes.Buffer)
+	defer putSentinelOneHECBuffer(recordBuf)
+	record, err := h.formatRecordWithinSize(ctx, r, recordBuf)
+	if err != nil {
+		return err
 	}
-
-	// add a newline to separate log entries (NDJSON format)
-	recordBuf.WriteByte('\n')
+	seq, hasSeq := recordSequence(r)
 
 	// lock the shared buffer
 	h.state.mu.Lock()
@@ -721,14 +1075,16 @@ func (h *SentinelOneHECHandler) Handle(ctx context.Context, r slog.Record) error
 	//
 	// We check if the buffer *already has data* before checking size. This ensures a single log larger than the max
 	// size is still processed.
-	var payload []byte
+	var batch *sentinelOneHECBatch
 	if h.state.buf.Len() > 0 && (h.options.BufferSize == 0 ||
 		(types.Size(h.state.buf.Len()+recordBuf.Len()) > h.options.BufferSize)) {
 
 		// buffer is full (or disabled) -- prepare to send the *current* buffer contents
-		payload = make([]byte, h.state.buf.Len())
+		payload := make([]byte, h.state.buf.Len())
 		copy(payload, h.state.buf.Bytes())
 		h.state.buf.Reset()
+		batch = h.newBatch(ctx, &record, payload, h.state.seqs)
+		h.state.seqs = sequenceRange{}
 	}
 
 	// write the new record to the (possibly empty) buffer
@@ -736,20 +1092,78 @@ func (h *SentinelOneHECHandler) Handle(ctx context.Context, r slog.Record) error
 		return h.handleError(ctx, fmt.Errorf(
 			"failed to write to buffer for SentinelOne HTTP event collector: %w\n", err), &record)
 	}
+	if hasSeq {
+		h.state.seqs.add(seq)
+	}
 
-	// send the payload if one was created
-	if payload != nil {
-		if h.options.DisableAsync {
-			return h.send(ctx, &record, payload)
-		}
-		go h.send(ctx, &record, payload)
+	// send the batch if one was formed
+	if batch != nil {
+		return h.dispatch(*batch)
 	}
 	return nil
 }
 
-// Options returns the handler's options.
+// HandleBatch formats all of the records for which the handler is enabled and sends them, along with any data
+// already in the buffer, to the HTTP event collector in a single request.
+//
+// Errors formatting individual records are passed to the ErrorHandler and the remaining records are still sent. All
+// errors are joined together and returned.
+func (h *SentinelOneHECHandler) HandleBatch(ctx context.Context, records []slog.Record) error {
+	// format the records into a *local* buffer to avoid holding the global lock during JSON formatting
+	var errs []error
+	batchBuf := sentinelOneHECBufferPool.Get().(*bytes.Buffer)
+	defer putSentinelOneHECBuffer(batchBuf)
+	var seqs sequenceRange
+	for _, r := range records {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		r, ok := xlog.ApplyTimestampPolicy(r, h.options.TimestampPolicy, h.options.Clock)
+		if !ok {
+			continue
+		}
+		if _, err := h.formatRecordWithinSize(ctx, r, batchBuf); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if seq, ok := recordSequence(r); ok {
+			seqs.add(seq)
+		}
+	}
+	if batchBuf.Len() == 0 {
+		return errors.Join(errs...)
+	}
+
+	// send any buffered data along with the batch so that ordering is preserved
+	h.state.mu.Lock()
+	payload := make([]byte, 0, h.state.buf.Len()+batchBuf.Len())
+	payload = append(payload, h.state.buf.Bytes()...)
+	payload = append(payload, batchBuf.Bytes()...)
+	h.state.buf.Reset()
+	seqs.merge(h.state.seqs)
+	h.state.seqs = sequenceRange{}
+	h.state.mu.Unlock()
+
+	if err := h.dispatch(*h.newBatch(ctx, nil, payload, seqs)); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// HealthCheck returns nil if the HTTP event collector could be reached the last time the handler tried or an error
+// describing why it could not be reached otherwise.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.HTTPClientError]: the collector could not be reached
+func (h *SentinelOneHECHandler) HealthCheck(ctx context.Context) error {
+	return h.state.conn.status()
+}
+
+// Options returns a copy of the handler's options.
+//
+// Modifying the returned options has no effect on the handler, except through the shared level variables.
 func (h *SentinelOneHECHandler) Options() any {
-	return h.options
+	return h.options.clone()
 }
 
 // Type returns the type of the handler.
@@ -760,11 +1174,33 @@ func (h *SentinelOneHECHandler) Type() string {
 // WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the
 // given attributes.
 func (h *SentinelOneHECHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	if h.options.Encoder != nil {
+		clone := h.clone()
+		clone.attrs = append(slices.Clip(h.attrs), attrs...)
+		return clone
+	}
+	resolved := resolveAttrs(attrs, h.groups, h.replaceAttr)
+	if len(resolved) == 0 {
+		return h
+	}
+	if h.options.FlattenGroups {
+		resolved = flattenGroupedAttrs(h.groups, resolved)
+	}
+
+	// encode the attributes once so they don't need to be encoded for every record
 	clone := h.clone()
-	newAttrs := make([]slog.Attr, len(h.attrs)+len(attrs))
-	copy(newAttrs, h.attrs)
-	copy(newAttrs[len(h.attrs):], attrs)
-	clone.attrs = newAttrs
+	buf := bytes.NewBuffer(slices.Clone(h.prefix))
+	for _, attr := range resolved {
+		if clone.prefixSep {
+			buf.WriteByte(',')
+		}
+		appendJSONMember(buf, attr, "", 0)
+		clone.prefixSep = true
+	}
+	clone.prefix = buf.Bytes()
 	return clone
 }
 
@@ -779,92 +1215,465 @@ func (h *SentinelOneHECHandler) WithGroup(name string) slog.Handler {
 	copy(newGroups, h.groups)
 	newGroups[len(h.groups)] = name
 	clone.groups = newGroups
+	if h.options.FlattenGroups || h.options.Encoder != nil {
+		// the group is added to the keys of the attributes or passed to the encoder instead
+		return clone
+	}
+
+	// open the group in the prefix; it's closed after the record's attributes are written
+	buf := bytes.NewBuffer(slices.Clone(h.prefix))
+	if h.prefixSep {
+		buf.WriteByte(',')
+	}
+	appendJSONString(buf, name)
+	buf.WriteString(":{")
+	clone.prefix = buf.Bytes()
+	clone.prefixSep = false
 	return clone
 }
 
 // clone creates a copy of current handler.
 func (h *SentinelOneHECHandler) clone() *SentinelOneHECHandler {
 	return &SentinelOneHECHandler{
-		attrs:        slices.Clone(h.attrs),
-		authToken:    h.authToken,
-		client:       h.client,
-		groups:       slices.Clone(h.groups),
-		ingestionURL: h.ingestionURL,
-		options:      h.options,
-		state:        h.state,
+		attrs:          h.attrs,
+		authToken:      h.authToken,
+		client:         h.client,
+		collectorAttrs: h.collectorAttrs,
+		dataSource:     h.dataSource,
+		groups:         h.groups,
+		ingestionURL:   h.ingestionURL,
+		options:        h.options,
+		prefix:         h.prefix,
+		prefixSep:      h.prefixSep,
+		replaceAttr:    h.replaceAttr,
+		state:          h.state,
+	}
+}
+
+// connect checks that the HTTP event collector can be reached by opening and closing a TCP connection to it.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.HTTPClientError]: the collector could not be reached
+func (h *SentinelOneHECHandler) connect(ctx context.Context) error {
+	address := h.options.IngestHostname
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "443")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return xerrors.Wrapf(xlog.HTTPClientError, err, "failed to connect to SentinelOne HTTP event collector: %s",
+			err.Error()).WithAttr("address", address)
+	}
+	_ = conn.Close()
+	return nil
+}
+
+// dispatch persists the batch to the write-ahead log, if enabled, and then sends it using
+// [SentinelOneHECHandler.enqueue].
+//
+// Errors are only returned when the batch is sent synchronously.
+func (h *SentinelOneHECHandler) dispatch(batch sentinelOneHECBatch) error {
+	batch.walPath = h.persist(batch.ctx, batch.record, batch.payload)
+	return h.enqueue(batch)
+}
+
+// enqueue sends the batch synchronously, queues it for the sender workers or sends it from a new goroutine,
+// depending on the handler's options.
+//
+// The batch is removed from the write-ahead log once it is delivered, if it was persisted. Its created time is used to
+// discard it if it expires while waiting in the queue.
+//
+// Errors are only returned when the batch is sent synchronously.
+func (h *SentinelOneHECHandler) enqueue(batch sentinelOneHECBatch) error {
+	if h.options.DisableAsync {
+		return h.send(batch)
+	}
+	if h.state.queue == nil {
+		go h.send(batch)
+		return nil
+	}
+
+	h.state.queueMu.RLock()
+	defer h.state.queueMu.RUnlock()
+	if h.state.closed {
+		return h.send(batch)
+	}
+	h.state.queue <- batch
+	return nil
+}
+
+// expire discards a batch which is older than the maximum record age, removing it from the write-ahead log if it was
+// persisted and reporting its records as dropped using [xlog.NotifyDropped].
+func (h *SentinelOneHECHandler) expire(batch sentinelOneHECBatch) {
+	xlog.NotifyDropped("", SentinelOneHECHandlerType, xlog.DropReasonExpired,
+		uint64(bytes.Count(batch.payload, []byte{'\n'})))
+	if batch.walPath != "" {
+		if err := h.state.wal.remove(batch.walPath); err != nil {
+			h.handleError(batch.ctx, err, batch.record)
+		}
+	}
+}
+
+// formatRecord formats the record as a single NDJSON line in the format expected by the HTTP event collector and
+// writes it to the given buffer.
+//
+// It returns the record that was actually formatted, which holds the "event" group and other collector fields.
+func (h *SentinelOneHECHandler) formatRecord(ctx context.Context, r slog.Record, buf *bytes.Buffer) (slog.Record,
+	error) {
+
+	// copy all of the record's attributes so they can be added to a new record under an "event" group
+	extraAttrs := 3
+	if h.options.IncludeCaller {
+		extraAttrs++
+	}
+	eventAttrs := make([]slog.Attr, 0, r.NumAttrs()+extraAttrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		eventAttrs = append(eventAttrs, attr)
+		return true
+	})
+
+	// add the message to the "event" group
+	eventAttrs = append(eventAttrs, slog.String("message", r.Message))
+
+	// add the time to the "event" group
+	//eventAttrs = append(eventAttrs, slog.Time("time", r.Time))
+
+	// rename event.level to event.severity and modify value
+	var severity string
+	if h.options.LevelTranslator != nil {
+		severity = h.options.LevelTranslator(r.Level)
+	} else {
+		severity = DefaultSentinelOneHECLevelTranslator(r.Level)
+	}
+	eventAttrs = append(eventAttrs, slog.String("severity", severity))
+
+	// add the caller info if desired
+	if h.options.IncludeCaller && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		eventAttrs = append(eventAttrs, slog.Any(h.options.CallerKey, &slog.Source{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		}))
+	}
+
+	// add dataSource fields
+	eventAttrs = append(eventAttrs, h.dataSource)
+
+	// create the new record with the "event" group
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	if len(eventAttrs) > 0 {
+		record.AddAttrs(slog.GroupAttrs("event", eventAttrs...))
+	}
+
+	// add host, source, sourcetype, and site.id fields
+	record.AddAttrs(h.collectorAttrs...)
+	if h.options.Encoder != nil {
+		return record, h.options.Encoder.EncodeRecord(buf, record, h.attrs, h.groups)
+	}
+
+	// write the built-in attributes followed by the pre-encoded handler attributes and open groups
+	s := jsonEncodeStatePool.Get().(*jsonEncodeState)
+	defer putJSONEncodeState(s)
+	s.buf = buf
+	s.replace = h.replaceAttr
+	buf.WriteByte('{')
+	s.appendBuiltins(record, false)
+	if len(h.prefix) > 0 {
+		if s.sep {
+			buf.WriteByte(',')
+		}
+		buf.Write(h.prefix)
+		s.sep = h.prefixSep
 	}
+
+	// write the record's attributes inside of the open groups and close them (NDJSON format)
+	if h.options.FlattenGroups {
+		recordAttrs := make([]slog.Attr, 0, record.NumAttrs())
+		record.Attrs(func(attr slog.Attr) bool {
+			recordAttrs = append(recordAttrs, attr)
+			return true
+		})
+		for _, attr := range flattenGroupedAttrs(h.groups, resolveAttrs(recordAttrs, h.groups, h.replaceAttr)) {
+			s.appendMember(attr)
+		}
+	} else {
+		s.groups = append(s.groups, h.groups...)
+		record.Attrs(func(attr slog.Attr) bool {
+			s.appendAttr(attr)
+			return true
+		})
+		for range h.groups {
+			buf.WriteByte('}')
+		}
+	}
+	buf.WriteString("}\n")
+	return record, nil
+}
+
+// formatRecordWithinSize formats the record using [SentinelOneHECHandler.formatRecord], making it fit within the
+// maximum record size if one is set, and writes it to the given buffer.
+//
+// It returns the record that was actually formatted.
+func (h *SentinelOneHECHandler) formatRecordWithinSize(ctx context.Context, r slog.Record, buf *bytes.Buffer) (
+	slog.Record, error) {
+
+	var record slog.Record
+	err := encodeWithinSize(buf, r, int(h.options.MaxRecordBytes), h.options.RecordSizeStrategy,
+		func(b *bytes.Buffer, r slog.Record) error {
+			var err error
+			record, err = h.formatRecord(ctx, r, b)
+			return err
+		})
+	return record, err
 }
 
-// handleError is a simple wrapper function to call the error handler function if it is defined.
+// handleError is a simple wrapper function to log the error as an internal event and call the error handler function
+// if it is defined.
 func (h *SentinelOneHECHandler) handleError(ctx context.Context, err error, r *slog.Record) error {
+	logHandleError(ctx, SentinelOneHECHandlerType, err, r)
 	if h.options.ErrorHandler != nil {
 		err = h.options.ErrorHandler(ctx, err, r)
 	}
 	return err
 }
 
-// send actually sends the HTTP POST request to the SentinelOne Event Collector.
+// newBatch returns a new batch holding the given payload, assigning it a new batch ID.
+func (h *SentinelOneHECHandler) newBatch(ctx context.Context, r *slog.Record, payload []byte,
+	seqs sequenceRange) *sentinelOneHECBatch {
+	return &sentinelOneHECBatch{
+		created: xlog.DefaultClock.Now(),
+		ctx:     ctx,
+		id:      xlog.NewBatchID(),
+		payload: payload,
+		record:  r,
+		seqs:    seqs,
+	}
+}
+
+// persist writes the payload to the write-ahead log, if enabled, and returns the path to it.
+//
+// If the payload cannot be persisted, the error is passed to the error handler and an empty path is returned so that
+// the payload is still sent.
+func (h *SentinelOneHECHandler) persist(ctx context.Context, r *slog.Record, payload []byte) string {
+	if h.state.wal == nil {
+		return ""
+	}
+	path, err := h.state.wal.write(payload)
+	if err != nil {
+		h.handleError(ctx, err, r)
+		return ""
+	}
+	return path
+}
+
+// post actually sends the HTTP POST request to the SentinelOne Event Collector.
 //
 // This function may return an error with any of the following codes:
 //   - [xlog.DataCompressionError]: failed to gzip the payload
 //   - [xlog.HTTPClientError]: failed to send the HTTP request
 //   - [xlog.HTTPRequestError]: failed to construct the HTTP request
 //   - [xlog.HTTPResponseError]: failed to process the HTTP response
-//
-// It is possible that the function may return other errors if the handler's [ErrorHandler] modifies the
-// error passed to it in any way.
-func (h *SentinelOneHECHandler) send(ctx context.Context, r *slog.Record, payload []byte) error {
+func (h *SentinelOneHECHandler) post(payload []byte, checksum string) xerrors.Error {
 	// gzip the payload
 	var gzipBuf bytes.Buffer
 	gw := gzip.NewWriter(&gzipBuf)
 	if _, err := gw.Write(payload); err != nil {
-		return h.handleError(ctx, xerrors.Wrapf(xlog.DataCompressionError, err, "failed to compress payload: %s",
-			err.Error()), r)
+		return xerrors.Wrapf(xlog.DataCompressionError, err, "failed to compress payload: %s", err.Error())
 	}
 	if err := gw.Close(); err != nil {
-		return h.handleError(ctx, xerrors.Wrapf(xlog.DataCompressionError, err, "failed to close gzip writer: %s",
-			err.Error()), r)
+		return xerrors.Wrapf(xlog.DataCompressionError, err, "failed to close gzip writer: %s", err.Error())
 	}
 
 	// construct the request
 	req, err := http.NewRequest("POST", h.ingestionURL, &gzipBuf)
 	if err != nil {
-		return h.handleError(ctx, xerrors.Wrapf(xlog.HTTPRequestError, err, "failed to create HTTP request: %s",
-			err.Error()), r)
+		return xerrors.Wrapf(xlog.HTTPRequestError, err, "failed to create HTTP request: %s", err.Error())
 	}
 	req.Header.Set("Authorization", h.authToken)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("S1-Scope", h.options.Scope)
+	if checksum != "" {
+		req.Header.Set(DefaultSentinelOneHECHandlerChecksumHeader, checksum)
+	}
 
 	// execute the request
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return h.handleError(ctx, xerrors.Wrapf(xlog.HTTPClientError, err, "failed to execute HTTP request: %s",
-			err.Error()), r)
+		xerr := xerrors.Wrapf(xlog.HTTPClientError, err, "failed to execute HTTP request: %s", err.Error())
+		h.state.conn.failed(xerr)
+		return xerr
 	}
 	defer resp.Body.Close()
+	h.state.conn.succeeded()
 
 	// ensure an error did not occur
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
-		return h.handleError(ctx, xerrors.Newf(xlog.HTTPResponseError,
+		statusErr := &xlog.HTTPStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+		return xerrors.Wrapf(xlog.HTTPResponseError, statusErr,
 			"log endpoint returned non-OK status: %s, body: %s\n", resp.Status, string(body)).WithAttrs(
 			map[string]any{
 				"status_code": resp.StatusCode,
 				"status":      resp.Status,
 				"body":        string(body),
-			}), r)
+			})
 	}
 	return nil
 }
 
+// recoverWAL sends the batches at the given paths in the write-ahead log, which were left there by a previous
+// handler, using [SentinelOneHECHandler.enqueue].
+//
+// Batches which cannot be read are passed to the error handler and left in the write-ahead log. Batches which are
+// older than the maximum record age are discarded.
+func (h *SentinelOneHECHandler) recoverWAL(paths []string) {
+	for _, path := range paths {
+		payload, created, err := h.state.wal.read(path)
+		if err != nil {
+			h.handleError(context.Background(), err, nil)
+			continue
+		}
+		batch := sentinelOneHECBatch{
+			created: created,
+			ctx:     context.Background(),
+			id:      xlog.NewBatchID(),
+			payload: payload,
+			walPath: path,
+		}
+		if xlog.RecordExpired(created, time.Duration(h.options.MaxRecordAge), nil) {
+			h.expire(batch)
+			continue
+		}
+		h.enqueue(batch)
+	}
+}
+
+// send sends the batch to the SentinelOne Event Collector, reports the outcome to the delivery callbacks and
+// passes any error to the error handler.
+//
+// If the batch was delivered and was persisted, it is removed from the write-ahead log.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataCompressionError]: failed to gzip the payload
+//   - [xlog.HTTPClientError]: failed to send the HTTP request
+//   - [xlog.HTTPRequestError]: failed to construct the HTTP request
+//   - [xlog.HTTPResponseError]: failed to process the HTTP response
+//
+// It is possible that the function may return other errors if the handler's [ErrorHandler] modifies the
+// error passed to it in any way.
+func (h *SentinelOneHECHandler) send(batch sentinelOneHECBatch) error {
+	ctx, r, payload := batch.ctx, batch.record, batch.payload
+	start := time.Now()
+	var checksum string
+	if h.options.PayloadChecksum {
+		sum := sha256.Sum256(payload)
+		checksum = hex.EncodeToString(sum[:])
+	}
+	err := h.post(payload, checksum)
+	callback := h.options.OnDelivered
+	if err != nil {
+		callback = h.options.OnFailed
+	}
+	if callback != nil {
+		report := xlog.DeliveryReport{
+			BatchID:       batch.id,
+			Checksum:      checksum,
+			Err:           err,
+			FirstSequence: batch.seqs.first,
+			HandlerType:   SentinelOneHECHandlerType,
+			LastSequence:  batch.seqs.last,
+			Latency:       time.Since(start),
+			Records:       bytes.Count(payload, []byte{'\n'}),
+		}
+		callback(ctx, report)
+	}
+	if xlog.TraceEnabled() {
+		attrs := []slog.Attr{
+			slog.String("handler_type", SentinelOneHECHandlerType),
+			slog.String("batch_id", batch.id),
+			slog.Int("bytes", len(payload)),
+			slog.Int("records", bytes.Count(payload, []byte{'\n'})),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if err != nil {
+			xlog.Trace(ctx, xlog.TraceEventBatchFailed, "batch failed", append(attrs,
+				slog.String("error", err.Error()))...)
+		} else {
+			xlog.Trace(ctx, xlog.TraceEventBatchFlushed, "batch flushed", attrs...)
+		}
+	}
+	if err != nil {
+		return h.handleError(ctx, err, r)
+	}
+	if batch.walPath != "" {
+		if err := h.state.wal.remove(batch.walPath); err != nil {
+			return h.handleError(ctx, err, r)
+		}
+	}
+	return nil
+}
+
+// sendWorker sends the batches in the queue until the queue is closed.
+func (h *SentinelOneHECHandler) sendWorker() {
+	for batch := range h.state.queue {
+		if xlog.RecordExpired(batch.created, time.Duration(h.options.MaxRecordAge), nil) {
+			h.expire(batch)
+			continue
+		}
+		h.send(batch)
+	}
+}
+
+// putSentinelOneHECBuffer resets the given buffer and returns it to the buffer pool unless it has grown too large.
+func putSentinelOneHECBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > sentinelOneHECMaxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	sentinelOneHECBufferPool.Put(buf)
+}
+
+// sentinelOneHECReplaceAttr returns the function used to rewrite attributes before they are formatted for the HTTP
+// event collector.
+//
+// The function calls the user-defined replace function, if any, converts the top-level time into milliseconds since
+// the epoch and removes the top-level level and message, which are sent as part of the "event" group instead.
+func sentinelOneHECReplaceAttr(replace func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		numGroups := len(groups)
+
+		// call the user-defined ReplaceAttr() function if it's set
+		if replace != nil {
+			attr = replace(groups, attr)
+		}
+
+		// make sure the "time" key is set to milliseconds since the epoch
+		if numGroups == 0 && attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime {
+			attr.Key = "time"
+			attr.Value = slog.Int64Value(attr.Value.Time().UnixMilli())
+		}
+
+		// remove the top-level "level" and "msg" keys
+		if numGroups == 0 && (attr.Key == slog.LevelKey || attr.Key == slog.MessageKey) {
+			return slog.Attr{}
+		}
+		return attr
+	}
+}
+
 // sentinelOneHECHandlerBuilder is used to build the handler from configuration options.
 type sentinelOneHECHandlerBuilder struct {
 	// unexported variables
 	options SentinelOneHECHandlerOptions // handler options
 }
 
+// ensure [sentinelOneHECHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &sentinelOneHECHandlerBuilder{}
+
 // NewSentinelOneHECHandlerBuilderFromConfig creates a new [xlog.HandlerBuilder] and validates the given options,
 // setting and default values as necessary.
 //
@@ -889,17 +1698,9 @@ func NewSentinelOneHECHandlerBuilderFromConfig(options json.RawMessage) (xlog.Ha
 //
 // This function may return other errors if the callback function fails and defines its own error values.
 func (b *sentinelOneHECHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
-	if cb != nil {
-		if err := cb(b.Type(), &b.options); err != nil {
-			return nil, err
-		}
-	}
-	h, err := NewSentinelOneHECHandler(b.options)
-	if err != nil {
-		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
-			err.Error())
-	}
-	return h, nil
+	handler, err := b.build(cb)
+	logBuildResult(b.Type(), err)
+	return handler, err
 }
 
 // MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
@@ -930,3 +1731,41 @@ func (b *sentinelOneHECHandlerBuilder) Options() map[string]any {
 func (b *sentinelOneHECHandlerBuilder) Type() string {
 	return SentinelOneHECHandlerType
 }
+
+// Validate checks the options for problems without actually building the handler.
+//
+// A copy of the options is passed to the callback function and validated exactly as it would be by Build, so the
+// builder itself is left unchanged.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *sentinelOneHECHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	options := b.options.clone()
+	if cb != nil {
+		if err := cb(b.Type(), &options); err != nil {
+			return err
+		}
+	}
+	return options.Validate()
+}
+
+// build creates the handler without logging the result as an internal event.
+func (b *sentinelOneHECHandlerBuilder) build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	if cb != nil {
+		if err := cb(b.Type(), &b.options); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.options.Validate(); err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	h, err := NewSentinelOneHECHandler(b.options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to build '%s' handler: %s", b.Type(),
+			err.Error())
+	}
+	return h, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.innotegrity.dev/secretmgr/secrets"
+	"go.innotegrity.dev/xlog"
+)
+
+// discardRoundTripper is an HTTP transport which discards all requests without sending them.
+type discardRoundTripper struct{}
+
+// RoundTrip discards the request and returns an empty successful response.
+func (discardRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// newBenchmarkSentinelOneHECHandler creates a handler which buffers records and discards any requests it sends.
+func newBenchmarkSentinelOneHECHandler(b *testing.B) slog.Handler {
+	b.Helper()
+	h, err := NewSentinelOneHECHandler(SentinelOneHECHandlerOptions{
+		APIToken:       secrets.GenericSecret{Data: []byte("token")},
+		BufferSize:     1024 * 1024,
+		IngestHostname: "ingest.example.com",
+		Scope:          "site-id",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	h.client.Transport = discardRoundTripper{}
+	return h.WithAttrs([]slog.Attr{slog.String("service", "benchmark")}).WithGroup("request").
+		WithAttrs([]slog.Attr{slog.String("id", "c0ffee")})
+}
+
+// newBenchmarkRecord creates the record logged by the benchmarks.
+func newBenchmarkRecord() slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "benchmark message", 0)
+	r.AddAttrs(
+		slog.String("method", "GET"),
+		slog.String("path", "/api/v1/items"),
+		slog.Int("status", 200),
+		slog.Duration("elapsed", 1500*time.Microsecond),
+	)
+	return r
+}
+
+// TestSentinelOneHECHandlerDeliveryReport checks that the delivery report identifies the batch and the sequence
+// numbers of its records.
+func TestSentinelOneHECHandlerDeliveryReport(t *testing.T) {
+	var reports []xlog.DeliveryReport
+	h, err := NewSentinelOneHECHandler(SentinelOneHECHandlerOptions{
+		APIToken:       secrets.GenericSecret{Data: []byte("token")},
+		BufferSize:     1024 * 1024,
+		DisableAsync:   true,
+		IngestHostname: "ingest.example.com",
+		OnDelivered: func(_ context.Context, report xlog.DeliveryReport) {
+			reports = append(reports, report)
+		},
+		Scope: "site-id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.client.Transport = discardRoundTripper{}
+
+	logger := slog.New(xlog.NewSequenceHandler(h, xlog.SequenceHandlerOptions{}))
+	for range 3 {
+		logger.Info("message")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	report := reports[0]
+	if report.BatchID == "" || report.Records != 3 || report.FirstSequence != 1 || report.LastSequence != 3 {
+		t.Errorf("report = %+v, want a batch ID, 3 records and sequence numbers 1 to 3", report)
+	}
+}
+
+func BenchmarkSentinelOneHECHandlerFormat(b *testing.B) {
+	h := newBenchmarkSentinelOneHECHandler(b).(*SentinelOneHECHandler)
+	r := newBenchmarkRecord()
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		buf := sentinelOneHECBufferPool.Get().(*bytes.Buffer)
+		if _, err := h.formatRecord(ctx, r, buf); err != nil {
+			b.Fatal(err)
+		}
+		putSentinelOneHECBuffer(buf)
+	}
+}
+
+func BenchmarkSentinelOneHECHandlerHandle(b *testing.B) {
+	h := newBenchmarkSentinelOneHECHandler(b)
+	r := newBenchmarkRecord()
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := h.Handle(ctx, r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
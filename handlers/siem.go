@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// leefTimeFormat is the layout used to format the devTime attribute in LEEF output.
+	leefTimeFormat = "2006-01-02T15:04:05.000-0700"
+
+	// leefTimeFormatPattern is the Java date pattern which matches leefTimeFormat and is written to the devTimeFormat
+	// attribute in LEEF output.
+	leefTimeFormatPattern = "yyyy-MM-dd'T'HH:mm:ss.SSSZ"
+)
+
+var (
+	// DefaultSIEMDeviceProduct is the default product name written to the header of CEF and LEEF formatted messages.
+	//
+	// This value is used when the device product in [SIEMFormatOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SIEMFormatOptions
+	DefaultSIEMDeviceProduct = "xlog"
+
+	// DefaultSIEMDeviceVendor is the default vendor name written to the header of CEF and LEEF formatted messages.
+	//
+	// This value is used when the device vendor in [SIEMFormatOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SIEMFormatOptions
+	DefaultSIEMDeviceVendor = "Unknown"
+
+	// DefaultSIEMDeviceVersion is the default product version written to the header of CEF and LEEF formatted
+	// messages.
+	//
+	// This value is used when the device version in [SIEMFormatOptions] is unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#SIEMFormatOptions
+	DefaultSIEMDeviceVersion = "1.0"
+)
+
+// DefaultSIEMSeverityTranslator acts as a default translator which takes an [slog.Level] and translates it to a
+// severity between 0 and 10 when a message is written in CEF or LEEF format.
+//
+// This function translates the level as follows:
+//   - message level > [slog.LevelError] = 10
+//   - [slog.LevelError] >= message level > [slog.LevelWarn] = 8
+//   - [slog.LevelWarn] >= message level > [slog.LevelInfo] = 6
+//   - [slog.LevelInfo] >= message level > [slog.LevelDebug] = 3
+//   - [slog.LevelDebug] >= message level = 1
+func DefaultSIEMSeverityTranslator(l slog.Level) int {
+	if l > slog.LevelError {
+		return 10
+	} else if l > slog.LevelWarn {
+		return 8
+	} else if l > slog.LevelInfo {
+		return 6
+	} else if l > slog.LevelDebug {
+		return 3
+	}
+	return 1
+}
+
+// SIEMFormatOptions holds the options used when writing messages in the CEF or LEEF formats.
+type SIEMFormatOptions struct {
+	// DeviceProduct is the product name written to the header of each message.
+	//
+	// The default behavior is defined by the default device product setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	DeviceProduct string `json:"device_product"`
+
+	// DeviceVendor is the vendor name written to the header of each message.
+	//
+	// The default behavior is defined by the default device vendor setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	DeviceVendor string `json:"device_vendor"`
+
+	// DeviceVersion is the product version written to the header of each message.
+	//
+	// The default behavior is defined by the default device version setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	DeviceVersion string `json:"device_version"`
+
+	// ExtensionKeys maps attribute keys to the extension (CEF) or attribute (LEEF) keys under which their values are
+	// written.
+	//
+	// Keys for attributes inside of groups are the group names and attribute key joined by a dot (eg: "http.method").
+	// The caller, if included, uses the [slog.SourceKey] key. Attributes which are not mapped are written using
+	// their own key with any characters that are not allowed in a key replaced by an underscore.
+	//
+	// The default behavior is to not map any attribute keys.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	ExtensionKeys map[string]string `json:"extension_keys,omitempty"`
+
+	// SeverityTranslator is a function that's called to translate the level of a message into a severity
+	// between 0 and 10.
+	//
+	// The default behavior is to use [DefaultSIEMSeverityTranslator].
+	//
+	// When reading configuration settings from a file or raw JSON, create an [xlog.HandlerBuilder] and pass the
+	// [xlog.HandlerBuilder.Build] function an [xlog.HandlerBuildCallbackFn] callback to modify the options and
+	// set this value from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilderBuildCallbackFn
+	SeverityTranslator func(slog.Level) int `json:"-"`
+}
+
+// clone returns a copy of the options which shares no maps with the original.
+func (o SIEMFormatOptions) clone() SIEMFormatOptions {
+	o.ExtensionKeys = maps.Clone(o.ExtensionKeys)
+	return o
+}
+
+// withDefaults returns a copy of the options with any unset values replaced by the package defaults.
+func (o SIEMFormatOptions) withDefaults() SIEMFormatOptions {
+	if o.DeviceProduct == "" {
+		o.DeviceProduct = DefaultSIEMDeviceProduct
+	}
+	if o.DeviceVendor == "" {
+		o.DeviceVendor = DefaultSIEMDeviceVendor
+	}
+	if o.DeviceVersion == "" {
+		o.DeviceVersion = DefaultSIEMDeviceVersion
+	}
+	if o.SeverityTranslator == nil {
+		o.SeverityTranslator = DefaultSIEMSeverityTranslator
+	}
+	return o
+}
+
+// siemEncoder encodes records as single lines in either the ArcSight Common Event Format (CEF) or the IBM QRadar
+// Log Event Extended Format (LEEF).
+//
+// For CEF, the level name is used as the signature ID, the message as the name and the translated level as the
+// severity in the header, and the time is written to the "rt" extension as milliseconds since the epoch.
+//
+// For LEEF, the level name is used as the event ID in the header, and the time, severity and message are written to
+// the "devTime", "sev" and "msg" attributes. Attributes are separated by tabs.
+//
+// All other attributes, including the caller, are written as extensions using the mapped key from the options, if
+// one exists.
+//
+// References:
+//
+//	https://www.microfocus.com/documentation/arcsight/arcsight-smartconnectors-8.3/cef-implementation-standard/
+//	https://www.ibm.com/docs/en/dsm?topic=leef-overview
+type siemEncoder struct {
+	// unexported variables
+	leef     bool                // true to encode as LEEF rather than CEF
+	options  slog.HandlerOptions // encoder options
+	siemOpts SIEMFormatOptions   // SIEM-specific options
+}
+
+// NewCEFEncoder creates a new [xlog.Encoder] which encodes records in the ArcSight Common Event Format (CEF).
+//
+// Only the AddSource and ReplaceAttr options are used by the encoder.
+//
+// References:
+//
+//	https://www.microfocus.com/documentation/arcsight/arcsight-smartconnectors-8.3/cef-implementation-standard/
+func NewCEFEncoder(options *slog.HandlerOptions, siemOptions SIEMFormatOptions) xlog.Encoder {
+	return newSIEMEncoder(options, siemOptions, false)
+}
+
+// NewLEEFEncoder creates a new [xlog.Encoder] which encodes records in the IBM QRadar Log Event Extended Format
+// (LEEF) version 2.0.
+//
+// Only the AddSource and ReplaceAttr options are used by the encoder.
+//
+// References:
+//
+//	https://www.ibm.com/docs/en/dsm?topic=leef-overview
+func NewLEEFEncoder(options *slog.HandlerOptions, siemOptions SIEMFormatOptions) xlog.Encoder {
+	return newSIEMEncoder(options, siemOptions, true)
+}
+
+// newSIEMEncoder creates a new [siemEncoder] object.
+func newSIEMEncoder(options *slog.HandlerOptions, siemOptions SIEMFormatOptions, leef bool) *siemEncoder {
+	e := &siemEncoder{
+		leef:     leef,
+		siemOpts: siemOptions.clone().withDefaults(),
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single CEF or LEEF line.
+func (e *siemEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	var extensions []slog.Attr
+	eventID := r.Level.String()
+	message := r.Message
+	severity := e.siemOpts.SeverityTranslator(r.Level)
+	var timestamp time.Time
+
+	// pull out the built-in attributes used in the header
+	for _, attr := range builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr) {
+		switch {
+		case attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime:
+			timestamp = attr.Value.Time()
+		case attr.Key == slog.LevelKey:
+			if level, ok := attr.Value.Any().(slog.Level); ok {
+				severity = e.siemOpts.SeverityTranslator(level)
+			}
+			eventID = formatTextValue(attr.Value, "")
+		case attr.Key == slog.MessageKey:
+			message = formatTextValue(attr.Value, time.RFC3339Nano)
+		default:
+			extensions = append(extensions, attr)
+		}
+	}
+	extensions = append(extensions, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...)
+
+	// write the header
+	if e.leef {
+		buf.WriteString("LEEF:2.0|")
+	} else {
+		buf.WriteString("CEF:0|")
+	}
+	for _, field := range []string{e.siemOpts.DeviceVendor, e.siemOpts.DeviceProduct, e.siemOpts.DeviceVersion,
+		eventID} {
+		buf.WriteString(escapeSIEMHeader(field))
+		buf.WriteByte('|')
+	}
+	if e.leef {
+		buf.WriteString("x09|")
+	} else {
+		buf.WriteString(escapeSIEMHeader(message))
+		fmt.Fprintf(buf, "|%d|", severity)
+	}
+
+	// write the extensions
+	first := true
+	appendExtension := func(key, value string) {
+		if !first {
+			if e.leef {
+				buf.WriteByte('\t')
+			} else {
+				buf.WriteByte(' ')
+			}
+		}
+		first = false
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(e.escapeExtension(value))
+	}
+	if e.leef {
+		if !timestamp.IsZero() {
+			appendExtension("devTime", timestamp.Format(leefTimeFormat))
+			appendExtension("devTimeFormat", leefTimeFormatPattern)
+		}
+		appendExtension("sev", fmt.Sprintf("%d", severity))
+		appendExtension("msg", message)
+	} else if !timestamp.IsZero() {
+		appendExtension("rt", fmt.Sprintf("%d", timestamp.UnixMilli()))
+	}
+	for _, attr := range extensions {
+		e.appendExtensions(appendExtension, "", attr)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// appendExtensions flattens the given attribute into one or more extensions, prefixing keys with the given prefix.
+func (e *siemEncoder) appendExtensions(appendExtension func(string, string), prefix string, attr slog.Attr) {
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, child := range attr.Value.Group() {
+			e.appendExtensions(appendExtension, prefix+attr.Key+".", child)
+		}
+		return
+	}
+
+	key := prefix + attr.Key
+	if mapped, ok := e.siemOpts.ExtensionKeys[key]; ok && mapped != "" {
+		key = mapped
+	} else {
+		key = strings.Map(func(r rune) rune {
+			if r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, key)
+	}
+	appendExtension(key, formatTextValue(attr.Value, time.RFC3339Nano))
+}
+
+// escapeExtension escapes the given extension value so that it does not break the format.
+//
+// For CEF, backslashes, equal signs and line breaks are escaped. For LEEF, tabs and line breaks are escaped.
+func (e *siemEncoder) escapeExtension(value string) string {
+	if e.leef {
+		return strings.NewReplacer("\t", `\t`, "\r", `\r`, "\n", `\n`).Replace(value)
+	}
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`).Replace(value)
+}
+
+// escapeSIEMHeader escapes backslashes and pipes in the given header field and replaces line breaks with spaces.
+func escapeSIEMHeader(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r", " ", "\n", " ").Replace(value)
+}
@@ -1,6 +1,77 @@
 package handlers
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// legacyDuration returns the given duration option, leaving it unset if it was set to -1.
+//
+// Before duration options were made optional, -1 was used to request the default value, so configuration files
+// using it must still be accepted.
+func legacyDuration(d xlog.Optional[types.Duration]) xlog.Optional[types.Duration] {
+	if value, ok := d.Get(); ok && value == -1 {
+		return xlog.Optional[types.Duration]{}
+	}
+	return d
+}
+
+// logBuildResult logs the result of building a handler of the given type as an internal event.
+func logBuildResult(handlerType string, err xerrors.Error) {
+	if err != nil {
+		xlog.LogInternal(context.Background(), slog.LevelError, xlog.InternalEventBuild, "failed to build handler",
+			slog.String("handler_type", handlerType), slog.String("error", err.Error()))
+		return
+	}
+	xlog.LogInternal(context.Background(), slog.LevelInfo, xlog.InternalEventBuild, "built handler",
+		slog.String("handler_type", handlerType))
+}
+
+// logHandleError logs an error returned while handling the given record or, if the record is nil, while flushing
+// buffered records as an internal event.
+func logHandleError(ctx context.Context, handlerType string, err error, r *slog.Record) {
+	if r == nil {
+		xlog.LogInternal(ctx, slog.LevelError, xlog.InternalEventFlushError, "failed to flush records",
+			slog.String("handler_type", handlerType), slog.String("error", err.Error()))
+		return
+	}
+	xlog.LogInternal(ctx, slog.LevelError, xlog.InternalEventHandleError, "failed to handle record",
+		slog.String("handler_type", handlerType), slog.String("error", err.Error()))
+}
+
+// replaceAttrWithUTC wraps the given ReplaceAttr function so that the built-in time attribute of a record is
+// converted to UTC before it is passed to the function.
+//
+// If next is nil, the converted attribute is simply returned.
+func replaceAttrWithUTC(next func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if len(groups) == 0 && attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime {
+			attr.Value = slog.TimeValue(attr.Value.Time().UTC())
+		}
+		if next != nil {
+			return next(groups, attr)
+		}
+		return attr
+	}
+}
+
+// truncateString truncates the given string to at most max characters (runes), replacing the last character with an
+// ellipsis if the string was truncated.
+//
+// If max is less than or equal to 0, the string is returned unchanged.
+func truncateString(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
 
 // try implements try/catch-like functionality to try a function and recover from any errors or panics that may occur.
 func try(callback func() error) (err error) {
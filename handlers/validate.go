@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// optionsValidation collects the problems found while validating handler options so that they can all be reported
+// at once.
+type optionsValidation struct {
+	errs   []error  // individual problems
+	fields []string // names of the fields with problems
+}
+
+// addf records a problem with the given field.
+func (v *optionsValidation) addf(field, format string, args ...any) {
+	v.errs = append(v.errs, xerrors.Newf(xlog.OptionsValidationError, "%s: %s", field,
+		fmt.Sprintf(format, args...)).WithAttr("field", field))
+	v.fields = append(v.fields, field)
+}
+
+// checkLevels records a problem if both levels are set and the maximum level is lower than the minimum level.
+func (v *optionsValidation) checkLevels(level, maxLevel *slog.LevelVar) {
+	if level != nil && maxLevel != nil && maxLevel.Level() < level.Level() {
+		v.addf("max_level", "maximum level '%s' is lower than minimum level '%s'", maxLevel.Level(),
+			level.Level())
+	}
+}
+
+// checkNonNegative records a problem if the given value is negative.
+func (v *optionsValidation) checkNonNegative(field string, value int64) {
+	if value < 0 {
+		v.addf(field, "value cannot be negative")
+	}
+}
+
+// checkPatterns records a problem for each of the given patterns which is not accepted by [path.Match].
+func (v *optionsValidation) checkPatterns(field string, patterns []string) {
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.addf(fmt.Sprintf("%s[%d]", field, i), "invalid pattern '%s': %s", pattern, err.Error())
+		}
+	}
+}
+
+// err returns a single error describing all of the problems that were recorded or nil if there were none.
+//
+// The names of all of the fields with problems are attached to the error in the "fields" attribute and each of the
+// individual problems can be retrieved from the wrapped error.
+func (v *optionsValidation) err(handlerType string) xerrors.Error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(v.errs))
+	for _, err := range v.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return xerrors.Wrapf(xlog.OptionsValidationError, errors.Join(v.errs...), "invalid %s handler options: %s",
+		handlerType, strings.Join(msgs, "; ")).WithAttrs(map[string]any{
+		"fields":       v.fields,
+		"handler_type": handlerType,
+	})
+}
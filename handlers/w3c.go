@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// w3cDateFormat is the layout used to format dates in W3C extended log files.
+	w3cDateFormat = "2006-01-02"
+
+	// w3cTimeFormat is the layout used to format times in W3C extended log files.
+	w3cTimeFormat = "15:04:05"
+)
+
+var (
+	// DefaultW3CFields is the default list of fields written to each line of W3C extended log files.
+	//
+	// This value is used when the fields in [W3CFormatOptions] are unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/handlers#W3CFormatOptions
+	DefaultW3CFields = []string{"date", "time", "c-ip", "cs-method", "cs-uri-stem", "cs-uri-query", "sc-status",
+		"sc-bytes", "time-taken", "cs(User-Agent)", "cs(Referer)"}
+
+	// w3cFieldAttrs maps the standard W3C field identifiers to the keys of the attributes added by the httplog
+	// middleware.
+	w3cFieldAttrs = map[string]string{
+		"c-ip":           "remote_addr",
+		"cs-host":        "host",
+		"cs-method":      "method",
+		"cs-uri":         "uri",
+		"cs-uri-query":   "uri",
+		"cs-uri-stem":    "uri",
+		"cs-username":    "user",
+		"cs-version":     "proto",
+		"cs(Referer)":    "referer",
+		"cs(User-Agent)": "user_agent",
+		"sc-bytes":       "bytes",
+		"sc-status":      "status",
+		"time-taken":     "duration",
+	}
+)
+
+// W3CFormatOptions holds the options used when writing messages in the W3C Extended Log File Format.
+type W3CFormatOptions struct {
+	// Fields holds the identifiers of the fields written to each line, in order.
+	//
+	// The date, time and comment fields hold the date and time of the record in UTC and its message. The following
+	// standard fields are taken from the attributes added by the httplog middleware: c-ip, cs-host, cs-method, cs-uri,
+	// cs-uri-stem, cs-uri-query, cs-username, cs-version, cs(Referer), cs(User-Agent), sc-bytes, sc-status and
+	// time-taken. Any other field is taken from the attribute with the same key, after removing an "x-" prefix if
+	// there is one (eg: "x-request_id" holds the "request_id" attribute). Keys for attributes inside of groups are the
+	// group names and attribute key joined by a dot.
+	//
+	// The default behavior is defined by the default W3C fields setting defined in the package.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	//
+	// References:
+	//   https://www.w3.org/TR/WD-logfile.html
+	Fields []string `json:"fields,omitempty"`
+
+	// Software is the value of the #Software directive written to the header of each log file.
+	//
+	// The default behavior is to not write the #Software directive.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to an empty string.
+	Software string `json:"software"`
+}
+
+// clone returns a copy of the options which shares no slices with the original.
+func (o W3CFormatOptions) clone() W3CFormatOptions {
+	o.Fields = slices.Clone(o.Fields)
+	return o
+}
+
+// withDefaults returns a copy of the options with any unset values replaced by the package defaults.
+func (o W3CFormatOptions) withDefaults() W3CFormatOptions {
+	if len(o.Fields) == 0 {
+		o.Fields = slices.Clone(DefaultW3CFields)
+	}
+	return o
+}
+
+// w3cEncoder encodes records as lines in the W3C Extended Log File Format.
+type w3cEncoder struct {
+	// unexported variables
+	options slog.HandlerOptions // encoder options
+	w3cOpts W3CFormatOptions    // W3C-specific options
+}
+
+// NewW3CEncoder creates a new [xlog.Encoder] which encodes records as lines in the W3C Extended Log File Format.
+//
+// Fields are separated by spaces and missing values are written as "-". Values which contain spaces, quotes or
+// control characters are quoted, with quotes doubled and control characters replaced by spaces. Only the AddSource
+// and ReplaceAttr options are used by the encoder. The directives which make up the header of the file are written by
+// the file handler rather than the encoder.
+//
+// References:
+//
+//	https://www.w3.org/TR/WD-logfile.html
+func NewW3CEncoder(options *slog.HandlerOptions, w3cOptions W3CFormatOptions) xlog.Encoder {
+	e := &w3cEncoder{
+		w3cOpts: w3cOptions.clone().withDefaults(),
+	}
+	if options != nil {
+		e.options = *options
+	}
+	return e
+}
+
+// EncodeRecord encodes the record as a single line.
+func (e *w3cEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, groups []string) error {
+	all := builtinAttrs(r, e.options.AddSource, e.options.ReplaceAttr)
+	all = append(all, recordAttrs(r, attrs, groups, e.options.ReplaceAttr)...)
+	values := make(map[string]slog.Value, len(all))
+	for _, attr := range xlog.FlattenAttrs(all, ".") {
+		values[attr.Key] = attr.Value
+	}
+
+	t := r.Time.UTC()
+	if v, ok := values[slog.TimeKey]; ok && v.Kind() == slog.KindTime {
+		t = v.Time().UTC()
+	}
+	for i, field := range e.w3cOpts.Fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		var value string
+		switch field {
+		case "comment":
+			value = w3cValue(values[slog.MessageKey])
+		case "date":
+			value = t.Format(w3cDateFormat)
+		case "time":
+			value = t.Format(w3cTimeFormat)
+		default:
+			value = e.fieldValue(field, values)
+		}
+		writeW3CField(buf, value)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// fieldValue returns the value of the given field from the flattened attributes of a record.
+func (e *w3cEncoder) fieldValue(field string, values map[string]slog.Value) string {
+	key, ok := w3cFieldAttrs[field]
+	if !ok {
+		return w3cValue(values[strings.TrimPrefix(field, "x-")])
+	}
+
+	v := values[key]
+	switch field {
+	case "c-ip":
+		host := w3cValue(v)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return host
+	case "cs-uri-query":
+		_, query, _ := strings.Cut(w3cValue(v), "?")
+		return query
+	case "cs-uri-stem":
+		stem, _, _ := strings.Cut(w3cValue(v), "?")
+		return stem
+	case "time-taken":
+		if v.Kind() == slog.KindDuration {
+			return strconv.FormatFloat(v.Duration().Seconds(), 'f', 3, 64)
+		}
+	}
+	return w3cValue(v)
+}
+
+// writeHeader writes the directives which make up the header of a W3C extended log file to the buffer.
+func (e *w3cEncoder) writeHeader(buf *bytes.Buffer) error {
+	buf.WriteString("#Version: 1.0\n")
+	if e.w3cOpts.Software != "" {
+		fmt.Fprintf(buf, "#Software: %s\n", strings.Join(strings.Fields(e.w3cOpts.Software), " "))
+	}
+	fmt.Fprintf(buf, "#Date: %s\n", xlog.DefaultClock.Now().UTC().Format(w3cDateFormat+" "+w3cTimeFormat))
+	fmt.Fprintf(buf, "#Fields: %s\n", strings.Join(e.w3cOpts.Fields, " "))
+	return nil
+}
+
+// w3cValue returns the given value as a string or an empty string if the value is unset.
+func w3cValue(v slog.Value) string {
+	if v.Kind() == slog.KindAny && v.Any() == nil {
+		return ""
+	}
+	return formatTextValue(v, time.RFC3339Nano)
+}
+
+// writeW3CField writes a single field value to the buffer, quoting it if necessary.
+func writeW3CField(buf *bytes.Buffer, value string) {
+	if value == "" {
+		buf.WriteByte('-')
+		return
+	}
+	if !strings.ContainsFunc(value, func(r rune) bool { return r <= ' ' || r == '"' || r == 0x7f }) {
+		buf.WriteString(value)
+		return
+	}
+	buf.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"':
+			buf.WriteString(`""`)
+		case r < ' ' || r == 0x7f:
+			buf.WriteByte(' ')
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// walBatchExt is the file extension of the batch files in a write-ahead log directory.
+	walBatchExt = ".batch"
+
+	// walBatchNameFormat is the format of the name of a batch file, given its sequence number.
+	walBatchNameFormat = "%020d" + walBatchExt
+
+	// walTempExt is the file extension added to a batch file while it is being written.
+	walTempExt = ".tmp"
+)
+
+// payloadWAL is a write-ahead log which persists batches of records to a directory before they are sent by an HTTP
+// handler and removes each batch once it has been delivered, so that batches which were never delivered (eg: because
+// the process crashed or the sink was unavailable) can be sent again when the handler is next created.
+//
+// Each batch is stored in its own file, named after its sequence number so that the files sort in the order the
+// batches were written.
+type payloadWAL struct {
+	// unexported variables
+	dir string     // write-ahead log directory
+	mu  sync.Mutex // protects seq
+	seq uint64     // sequence number of the most recently written batch
+}
+
+// openPayloadWAL opens the write-ahead log in the given directory, creating the directory if it does not exist, and
+// returns the paths of the batch files left in it, oldest first.
+//
+// Temporary files left by batches which were being written when a previous process crashed are removed, since those
+// batches were never sent.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the directory
+//   - [xlog.DataWriteError]: failed to create the directory or remove a temporary file
+func openPayloadWAL(dir string) (*payloadWAL, []string, xerrors.Error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, xerrors.Wrapf(xlog.DataWriteError, err,
+			"failed to create write-ahead log directory '%s': %s", dir, err.Error()).WithAttr("dir", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, xerrors.Wrapf(xlog.DataReadError, err, "failed to read write-ahead log directory '%s': %s",
+			dir, err.Error()).WithAttr("dir", dir)
+	}
+	var pending []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+			continue
+		case strings.HasSuffix(entry.Name(), walBatchExt+walTempExt):
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, nil, xerrors.Wrapf(xlog.DataWriteError, err,
+					"failed to remove write-ahead log temporary file '%s': %s", path, err.Error()).
+					WithAttr("path", path)
+			}
+			continue
+		case !strings.HasSuffix(entry.Name(), walBatchExt):
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), walBatchExt), 10, 64); err != nil {
+			continue
+		}
+		pending = append(pending, path)
+	}
+	slices.Sort(pending)
+
+	w := &payloadWAL{
+		dir: dir,
+	}
+	if len(pending) > 0 {
+		w.seq = walBatchSeq(pending[len(pending)-1])
+	}
+	return w, pending, nil
+}
+
+// read returns the payload stored in the given batch file and the time at which it was written.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the file
+func (w *payloadWAL) read(path string) ([]byte, time.Time, xerrors.Error) {
+	var info os.FileInfo
+	payload, err := os.ReadFile(path)
+	if err == nil {
+		info, err = os.Stat(path)
+	}
+	if err != nil {
+		return nil, time.Time{}, xerrors.Wrapf(xlog.DataReadError, err,
+			"failed to read write-ahead log batch '%s': %s", path, err.Error()).WithAttr("path", path)
+	}
+	return payload, info.ModTime(), nil
+}
+
+// remove removes the given batch file once its payload has been delivered.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to remove the file
+func (w *payloadWAL) remove(path string) xerrors.Error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to remove write-ahead log batch '%s': %s", path,
+			err.Error()).WithAttr("path", path)
+	}
+	return nil
+}
+
+// write durably stores the payload in a new batch file and returns the path to the file.
+//
+// The payload is written to a temporary file which is synced and then renamed, so a batch file never holds a
+// partially written payload.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to write the file
+func (w *payloadWAL) write(payload []byte) (string, xerrors.Error) {
+	w.mu.Lock()
+	w.seq++
+	path := filepath.Join(w.dir, fmt.Sprintf(walBatchNameFormat, w.seq))
+	w.mu.Unlock()
+
+	tmp := path + walTempExt
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err == nil {
+		if _, err = f.Write(payload); err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", xerrors.Wrapf(xlog.DataWriteError, err, "failed to write write-ahead log batch '%s': %s", path,
+			err.Error()).WithAttr("path", path)
+	}
+	return path, nil
+}
+
+// walBatchSeq returns the sequence number of the given batch file.
+func walBatchSeq(path string) uint64 {
+	seq, _ := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), walBatchExt), 10, 64)
+	return seq
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.innotegrity.dev/secretmgr/secrets"
+)
+
+// walTestCollector is an HTTP event collector which records the payloads it accepts and responds with a configurable
+// status code.
+type walTestCollector struct {
+	mu       sync.Mutex // protects payloads and status
+	payloads []string   // payloads accepted, in the order they were received
+	status   int        // status code returned for each request
+}
+
+// newWALTestCollector starts a new collector and routes requests made using the default HTTP transport to it.
+//
+// It returns the collector along with its address.
+func newWALTestCollector(t *testing.T) (*walTestCollector, string) {
+	t.Helper()
+	c := &walTestCollector{
+		status: http.StatusOK,
+	}
+	server := httptest.NewTLSServer(c)
+	t.Cleanup(server.Close)
+	transport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	t.Cleanup(func() { http.DefaultTransport = transport })
+	return c, server.Listener.Addr().String()
+}
+
+// ServeHTTP records the payload of the request if it is accepted.
+func (c *walTestCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status < 300 {
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payload, _ := io.ReadAll(gr)
+		c.payloads = append(c.payloads, string(payload))
+	}
+	w.WriteHeader(c.status)
+}
+
+// messages returns the given messages in the order they appear in the accepted payloads.
+func (c *walTestCollector) messages(messages ...string) []string {
+	c.mu.Lock()
+	all := strings.Join(c.payloads, "")
+	c.mu.Unlock()
+	var found []string
+	for offset := 0; ; {
+		next, index := "", -1
+		for _, msg := range messages {
+			if i := strings.Index(all[offset:], `"`+msg+`"`); i >= 0 && (index < 0 || i < index) {
+				next, index = msg, i
+			}
+		}
+		if index < 0 {
+			return found
+		}
+		found = append(found, next)
+		offset += index + len(next)
+	}
+}
+
+// setStatus changes the status code returned for each request.
+func (c *walTestCollector) setStatus(status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+}
+
+// newWALTestHandler creates a handler which sends each record synchronously to the given collector using the given
+// write-ahead log directory.
+func newWALTestHandler(t *testing.T, address, dir string) *SentinelOneHECHandler {
+	t.Helper()
+	h, err := NewSentinelOneHECHandler(SentinelOneHECHandlerOptions{
+		APIToken:       secrets.GenericSecret{Data: []byte("token")},
+		DisableAsync:   true,
+		IngestHostname: address,
+		Scope:          "site-id",
+		WALDir:         dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// walBatches returns the names of the batch files in the given write-ahead log directory.
+func walBatches(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+walBatchExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return names
+}
+
+// TestPayloadWALOpen checks that opening a write-ahead log returns the batches left in it in the order they were
+// written, removes temporary files left by a crash and continues the sequence of batch names.
+func TestPayloadWALOpen(t *testing.T) {
+	dir := t.TempDir()
+	w, pending, err := openPayloadWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("pending = %v, want none", pending)
+	}
+	for _, payload := range []string{"first\n", "second\n"} {
+		if _, err := w.write([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmp := filepath.Join(dir, "00000000000000000003"+walBatchExt+walTempExt)
+	if err := os.WriteFile(tmp, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	w, pending, err = openPayloadWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("pending = %v, want 2 batches", pending)
+	}
+	for i, want := range []string{"first\n", "second\n"} {
+		payload, _, err := w.read(pending[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payload) != want {
+			t.Errorf("batch %d = %q, want %q", i, payload, want)
+		}
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary file was not removed: %v", err)
+	}
+	path, err := w.write([]byte("third\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "00000000000000000003" + walBatchExt; filepath.Base(path) != want {
+		t.Errorf("new batch = %s, want %s", filepath.Base(path), want)
+	}
+}
+
+// TestSentinelOneHECHandlerWALDelivered checks that batches are sent in order and removed from the write-ahead log
+// once the collector accepts them.
+func TestSentinelOneHECHandlerWALDelivered(t *testing.T) {
+	collector, address := newWALTestCollector(t)
+	dir := t.TempDir()
+	h := newWALTestHandler(t, address, dir)
+	logger := slog.New(h)
+	for _, msg := range []string{"m1", "m2", "m3"} {
+		logger.Info(msg)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := collector.messages("m1", "m2", "m3"); strings.Join(got, ",") != "m1,m2,m3" {
+		t.Errorf("collector received %v, want [m1 m2 m3]", got)
+	}
+	if batches := walBatches(t, dir); len(batches) != 0 {
+		t.Errorf("write-ahead log holds %v, want no batches", batches)
+	}
+}
+
+// TestSentinelOneHECHandlerWALRecovery checks that batches rejected by the collector are kept in the write-ahead log
+// and sent, in order, by the next handler using the directory.
+func TestSentinelOneHECHandlerWALRecovery(t *testing.T) {
+	collector, address := newWALTestCollector(t)
+	collector.setStatus(http.StatusServiceUnavailable)
+	dir := t.TempDir()
+	h := newWALTestHandler(t, address, dir)
+	logger := slog.New(h)
+	for _, msg := range []string{"m1", "m2"} {
+		logger.Info(msg)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if batches := walBatches(t, dir); len(batches) != 2 {
+		t.Fatalf("write-ahead log holds %v, want 2 batches", batches)
+	}
+
+	collector.setStatus(http.StatusOK)
+	h = newWALTestHandler(t, address, dir)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.messages("m1", "m2"); strings.Join(got, ",") != "m1,m2" {
+		t.Errorf("collector received %v, want [m1 m2]", got)
+	}
+	if batches := walBatches(t, dir); len(batches) != 0 {
+		t.Errorf("write-ahead log holds %v, want no batches", batches)
+	}
+}
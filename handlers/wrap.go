@@ -0,0 +1,777 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/types"
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// AttrLimitWrapperType is the type of the built-in wrapper which limits the number of attributes, the nesting depth
+	// of groups and the number of keys in maps held by attribute values using [xlog.NewAttrLimitHandler].
+	//
+	// The wrapper accepts "max_attrs", "max_depth" and "max_map_keys" options holding the limits and an
+	// "overflow_key" option holding the key of the attribute which summarizes whatever was dropped.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewAttrLimitHandler
+	AttrLimitWrapperType = "attr_limit"
+
+	// BaggageWrapperType is the type of the built-in wrapper which adds the baggage stored in the context to every
+	// record using [xlog.NewBaggageHandler].
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewBaggageHandler
+	BaggageWrapperType = "baggage"
+
+	// BuildInfoWrapperType is the type of the built-in wrapper which adds the build information of the running binary
+	// to every record using [xlog.NewBuildInfoHandler].
+	//
+	// The wrapper accepts a "key" option holding the key of the build information group.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewBuildInfoHandler
+	BuildInfoWrapperType = "build_info"
+
+	// BurstWrapperType is the type of the built-in wrapper which collapses bursts of repeated records into summary
+	// records using [xlog.NewBurstHandler].
+	//
+	// The wrapper accepts a "level" option holding the minimum level of records which are collapsed, a "window" option
+	// holding the length of each burst (eg: "30s"), "max_bursts" and "max_sample_values" options holding the limits
+	// and a "summary_key" option holding the key of the summary group.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewBurstHandler
+	BurstWrapperType = "burst"
+
+	// ConcurrencyWrapperType is the type of the built-in wrapper which limits the number of records handled at the same
+	// time and, optionally, records how long records wait and how long they take to handle using
+	// [xlog.NewConcurrencyHandler].
+	//
+	// The wrapper accepts a "max_concurrent" option holding the maximum number of records handled at the same time and
+	// an "instrument" option indicating whether or not to record the wait and handle duration histograms.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewConcurrencyHandler
+	ConcurrencyWrapperType = "concurrency"
+
+	// DedupWrapperType is the type of the built-in wrapper which removes duplicate attribute keys using
+	// [xlog.NewDedupHandler].
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewDedupHandler
+	DedupWrapperType = "dedup"
+
+	// FingerprintWrapperType is the type of the built-in wrapper which stamps records with a fingerprint and the name
+	// of their originating logger using [xlog.NewFingerprintHandler].
+	//
+	// The wrapper accepts "fingerprint_key" and "logger_key" options holding the keys of the attributes.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFingerprintHandler
+	FingerprintWrapperType = "fingerprint"
+
+	// FlattenWrapperType is the type of the built-in wrapper which flattens nested groups into attributes with dotted
+	// keys using [xlog.NewFlattenHandler].
+	//
+	// The wrapper accepts a "separator" option holding the string used to join the keys of nested groups.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewFlattenHandler
+	FlattenWrapperType = "flatten"
+
+	// GoroutineAttrsWrapperType is the type of the built-in wrapper which adds the attributes attached to the goroutine
+	// logging each record to the record using [xlog.NewGoroutineAttrsHandler].
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewGoroutineAttrsHandler
+	GoroutineAttrsWrapperType = "goroutine_attrs"
+
+	// RateLimitWrapperType is the type of the built-in wrapper which limits the rate of records with the same key
+	// using [xlog.NewRateLimitHandler].
+	//
+	// The wrapper accepts an "always_level" option holding the minimum level of records which are never limited, a
+	// "burst" option holding the maximum number of records with the same key in a burst, a "key" option holding the
+	// key of the attribute whose value is the key (eg: "user_id"), a "key_expiry" option holding the time after which
+	// unseen keys are forgotten (eg: "10m"), a "max_keys" option holding the maximum number of keys with their own
+	// rate limit, a "name" option holding the name of the handler in drop notifications and a "rate" option holding
+	// the maximum number of records per second with the same key.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewRateLimitHandler
+	RateLimitWrapperType = "rate_limit"
+
+	// RequestIDWrapperType is the type of the built-in wrapper which adds the request ID stored in the context to
+	// every record using [xlog.NewRequestIDHandler].
+	//
+	// The wrapper accepts a "key" option holding the key of the request ID attribute.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewRequestIDHandler
+	RequestIDWrapperType = "request_id"
+
+	// SequenceWrapperType is the type of the built-in wrapper which stamps records with a monotonically increasing
+	// sequence number and the instance ID of the process using [xlog.NewSequenceHandler].
+	//
+	// The wrapper accepts an "instance_id" option holding the instance ID and "instance_id_key" and "sequence_key"
+	// options holding the keys of the attributes.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewSequenceHandler
+	SequenceWrapperType = "sequence"
+
+	// SourceFilterWrapperType is the type of the built-in wrapper which allows, drops or changes the level of records
+	// based on their source location using [xlog.NewSourceFilterHandler].
+	//
+	// The wrapper accepts a "default_action" option and a "rules" option holding a list of rules, each with an
+	// "action" and optional "package", "file" and "level" options.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewSourceFilterHandler
+	SourceFilterWrapperType = "source_filter"
+
+	// SubjectWrapperType is the type of the built-in wrapper which tags records with the subject identifier stored in
+	// their context using [xlog.NewSubjectHandler].
+	//
+	// The wrapper accepts a "key" option holding the key of the subject attribute.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewSubjectHandler
+	SubjectWrapperType = "subject"
+
+	// TemplateWrapperType is the type of the built-in wrapper which renders named placeholders in messages using
+	// [xlog.NewTemplateHandler].
+	//
+	// The wrapper accepts a "key" option holding the key of the message template attribute, a "locale" option holding
+	// the locale into which messages are translated using [xlog.DefaultMessageCatalog] and a "message_id_key" option
+	// holding the key of the message ID attribute.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewTemplateHandler
+	TemplateWrapperType = "template"
+
+	// TimeNormalizeWrapperType is the type of the built-in wrapper which normalizes the time of records and flags
+	// records whose time is skewed using [xlog.NewTimeNormalizeHandler].
+	//
+	// The wrapper accepts an "offset" option holding the duration added to the time of each record (eg: "-90s"), a
+	// "skew_tolerance" option holding the maximum skew allowed before a record is flagged (eg: "5m"), a "skew_key"
+	// option holding the key of the skew attribute and a "utc" option indicating whether or not to convert the time
+	// of records to UTC.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewTimeNormalizeHandler
+	TimeNormalizeWrapperType = "time_normalize"
+
+	// TraceWrapperType is the type of the built-in wrapper which traces whether each record is filtered, accepted or
+	// fails to be handled using [xlog.NewTraceHandler].
+	//
+	// The wrapper accepts a "name" option holding the name of the handler in trace records. Nothing is traced unless
+	// a trace writer is designated using [xlog.SetTraceWriter] or the [xlog.TraceEnvVar] environment variable.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#NewTraceHandler
+	TraceWrapperType = "trace"
+)
+
+// MiddlewareFactoryFn should create an [xlog.Middleware] using the given raw JSON options.
+type MiddlewareFactoryFn func(options json.RawMessage) (xlog.Middleware, xerrors.Error)
+
+// WrapperFn should wrap the given handler in a new handler (eg: one that samples, redacts or retries records) using
+// the given raw JSON options and return the new handler.
+type WrapperFn func(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error)
+
+// WrapperConfig holds the configuration for a single wrapper applied to a handler built from configuration.
+//
+// In JSON, a wrapper may be given as just its type (eg: "dedup") or as an object holding its type and options (eg:
+// {"type": "rate_limit", "options": {"limit": 100}}).
+type WrapperConfig struct {
+	// Type holds the type of the wrapper, as registered using [RegisterWrapper].
+	Type string `json:"type"`
+
+	// Options holds the options for the wrapper.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// jsonWrapperConfig is just an alias for [WrapperConfig] that is used during unmarshalling to prevent infinite
+// recursion.
+type jsonWrapperConfig WrapperConfig
+
+// UnmarshalJSON decodes the JSON-encoded data into the current object.
+func (c *WrapperConfig) UnmarshalJSON(data []byte) error {
+	var wrapperType string
+	if err := json.Unmarshal(data, &wrapperType); err == nil {
+		*c = WrapperConfig{
+			Type: wrapperType,
+		}
+		return nil
+	}
+
+	var config jsonWrapperConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	*c = WrapperConfig(config)
+	return nil
+}
+
+// wrappedHandlerBuilder is used to build a handler and then wrap it in one or more wrappers.
+type wrappedHandlerBuilder struct {
+	// unexported variables
+	builder  xlog.HandlerBuilder // builder for the handler being wrapped
+	wrappers []WrapperConfig     // wrappers to apply, from outermost to innermost
+}
+
+// ensure [wrappedHandlerBuilder] implements [xlog.HandlerBuilderValidator] interface.
+var _ xlog.HandlerBuilderValidator = &wrappedHandlerBuilder{}
+
+// NewWrappedBuilder returns a new [xlog.HandlerBuilder] which builds a handler using the given builder and then wraps
+// it in the given wrappers.
+//
+// The first wrapper is the outermost, so records pass through the wrappers in the order in which they are given before
+// reaching the handler. If no wrappers are given, the original builder is returned.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: the builder is nil
+//   - [xlog.UnsupportedHandlerType]: one or more wrapper types are not registered
+func NewWrappedBuilder(builder xlog.HandlerBuilder, wrappers []WrapperConfig) (xlog.HandlerBuilder, xerrors.Error) {
+	if builder == nil {
+		return nil, xerrors.New(xlog.InvalidParameter, "handler builder cannot be nil")
+	}
+	if len(wrappers) == 0 {
+		return builder, nil
+	}
+
+	normalized := make([]WrapperConfig, len(wrappers))
+	for i, w := range wrappers {
+		w.Type = strings.TrimSpace(strings.ToLower(w.Type))
+		if _, ok := _wrappers[w.Type]; !ok {
+			return nil, xerrors.Newf(xlog.UnsupportedHandlerType, "unsupported wrapper type: %s", w.Type).
+				WithAttrs(map[string]any{
+					"handler_type": builder.Type(),
+					"type":         w.Type,
+				})
+		}
+		normalized[i] = w
+	}
+	return &wrappedHandlerBuilder{
+		builder:  builder,
+		wrappers: normalized,
+	}, nil
+}
+
+// RegisterMiddleware attempts to register a [MiddlewareFactoryFn] for creating middleware which wraps handlers built
+// from configuration with the given wrapper type, so that middleware published by third parties can be listed in the
+// "wrap" section of a handler configuration just like the built-in wrappers.
+//
+// The factory function is called with the options of the wrapper each time a handler is built and the middleware it
+// returns is applied to the handler. A factory function which returns nil middleware fails the build.
+//
+// To overwrite the function attached to a particular wrapper type, set overwrite to true.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: an invalid parameter was passed to the function (eg: wrapper type was empty or
+//     factory function was nil)
+//   - [xlog.HandlerTypeExists]: a wrapper for the given wrapper type already exists
+func RegisterMiddleware(wrapperType string, factoryFn MiddlewareFactoryFn, overwrite bool) xerrors.Error {
+	if factoryFn == nil {
+		return xerrors.New(xlog.InvalidParameter, "factory function cannot be nil")
+	}
+	return RegisterWrapper(wrapperType, func(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+		mw, xerr := factoryFn(options)
+		if xerr != nil {
+			return nil, xerr
+		}
+		if mw == nil {
+			return nil, xerrors.Wrap(xlog.BuildHandlerError, errors.New("factory function returned nil middleware"),
+				fmt.Sprintf("'%s' middleware factory returned nil middleware", wrapperType))
+		}
+		return mw(h), nil
+	}, overwrite)
+}
+
+// RegisterWrapper attempts to register a [WrapperFn] for wrapping handlers built from configuration with the given
+// wrapper type.
+//
+// To overwrite the function attached to a particular wrapper type, set overwrite to true.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: an invalid parameter was passed to the function (eg: wrapper type was empty or
+//     wrapper function was nil)
+//   - [xlog.HandlerTypeExists]: a wrapper for the given wrapper type already exists
+func RegisterWrapper(wrapperType string, wrapperFn WrapperFn, overwrite bool) xerrors.Error {
+	wrapperType = strings.TrimSpace(strings.ToLower(wrapperType))
+	if wrapperType == "" {
+		return xerrors.New(xlog.InvalidParameter, "wrapper type cannot be empty")
+	}
+	if wrapperFn == nil {
+		return xerrors.New(xlog.InvalidParameter, "wrapper function cannot be nil")
+	}
+	if _, ok := _wrappers[wrapperType]; ok && !overwrite {
+		return xerrors.Newf(xlog.HandlerTypeExists, "%s: wrapper type is already registered", wrapperType).
+			WithAttr("type", wrapperType)
+	}
+	_wrappers[wrapperType] = wrapperFn
+	return nil
+}
+
+// Build builds the underlying handler and wraps it in each of the wrappers.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.BuildHandlerError]: failed to wrap the handler
+//
+// In addition, the function may return any error returned by the underlying builder.
+func (b *wrappedHandlerBuilder) Build(cb xlog.BuildHandlerCallbackFn) (slog.Handler, xerrors.Error) {
+	h, err := b.builder.Build(cb)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(b.wrappers) - 1; i >= 0; i-- {
+		w := b.wrappers[i]
+		h, err = b.wrap(h, w)
+		if err != nil {
+			return nil, xerrors.Wrapf(xlog.BuildHandlerError, err, "failed to wrap '%s' handler with '%s' wrapper: %s",
+				b.Type(), w.Type, err.Error()).WithAttr("wrapper", w.Type)
+		}
+	}
+	return h, nil
+}
+
+// MarshalJSON overrides how the object is marshalled to JSON to alter how field values are presented or to
+// add additional fields.
+func (b *wrappedHandlerBuilder) MarshalJSON() ([]byte, error) {
+	return b.builder.MarshalJSON()
+}
+
+// Options returns the options of the underlying builder as a string map.
+func (b *wrappedHandlerBuilder) Options() map[string]any {
+	return b.builder.Options()
+}
+
+// Type returns the type of the handler being built.
+func (b *wrappedHandlerBuilder) Type() string {
+	return b.builder.Type()
+}
+
+// Validate checks the options of the underlying builder for problems without actually building the handler.
+//
+// The options of the wrappers cannot be checked without building the handler, so they are not validated.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+//
+// This function may return other errors if the callback function fails and defines its own error values.
+func (b *wrappedHandlerBuilder) Validate(cb xlog.BuildHandlerCallbackFn) xerrors.Error {
+	return xlog.ValidateConfig(b.builder, cb)
+}
+
+// wrap wraps the given handler using the given wrapper.
+func (b *wrappedHandlerBuilder) wrap(h slog.Handler, w WrapperConfig) (slog.Handler, xerrors.Error) {
+	wrapperFn, ok := _wrappers[w.Type]
+	if !ok {
+		return nil, xerrors.Newf(xlog.UnsupportedHandlerType, "unsupported wrapper type: %s", w.Type).
+			WithAttr("type", w.Type)
+	}
+	options, err := json.Marshal(w.Options)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to marshal wrapper options to JSON: %s",
+			err.Error()).WithAttr("options", w.Options)
+	}
+	wrapped, xerr := wrapperFn(h, options)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if wrapped == nil {
+		return nil, xerrors.Wrap(xlog.BuildHandlerError, errors.New("wrapper returned a nil handler"),
+			fmt.Sprintf("'%s' wrapper returned a nil handler", w.Type))
+	}
+	return wrapped, nil
+}
+
+// wrapAttrLimit wraps the given handler in a handler which limits the attributes of records.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: one or more limits are negative
+func wrapAttrLimit(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		MaxAttrs    int    `json:"max_attrs"`
+		MaxDepth    int    `json:"max_depth"`
+		MaxMapKeys  int    `json:"max_map_keys"`
+		OverflowKey string `json:"overflow_key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	var v optionsValidation
+	v.checkNonNegative("max_attrs", int64(opts.MaxAttrs))
+	v.checkNonNegative("max_depth", int64(opts.MaxDepth))
+	v.checkNonNegative("max_map_keys", int64(opts.MaxMapKeys))
+	if err := v.err(AttrLimitWrapperType); err != nil {
+		return nil, err
+	}
+	return xlog.NewAttrLimitHandler(h, xlog.AttrLimitOptions{
+		MaxAttrs:    opts.MaxAttrs,
+		MaxDepth:    opts.MaxDepth,
+		MaxMapKeys:  opts.MaxMapKeys,
+		OverflowKey: opts.OverflowKey,
+	}), nil
+}
+
+// wrapBaggage wraps the given handler in a handler which adds the baggage stored in the context to records.
+//
+// The wrapper has no options.
+func wrapBaggage(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	return xlog.NewBaggageHandler(h), nil
+}
+
+// wrapBuildInfo wraps the given handler in a handler which adds the build information of the running binary to records.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapBuildInfo(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewBuildInfoHandler(h, xlog.BuildInfoHandlerOptions{Key: opts.Key}), nil
+}
+
+// wrapBurst wraps the given handler in a handler which collapses bursts of repeated records into summaries.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: the level is invalid or the window or max bursts is negative
+func wrapBurst(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Level           string         `json:"level"`
+		MaxBursts       int            `json:"max_bursts"`
+		MaxSampleValues int            `json:"max_sample_values"`
+		SummaryKey      string         `json:"summary_key"`
+		Window          types.Duration `json:"window"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	var v optionsValidation
+	var level slog.Leveler
+	if opts.Level != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(opts.Level)); err != nil {
+			v.addf("level", "%s", err.Error())
+		}
+		level = l
+	}
+	v.checkNonNegative("max_bursts", int64(opts.MaxBursts))
+	v.checkNonNegative("window", int64(opts.Window))
+	if err := v.err(BurstWrapperType); err != nil {
+		return nil, err
+	}
+	return xlog.NewBurstHandler(h, xlog.BurstHandlerOptions{
+		Level:           level,
+		MaxBursts:       opts.MaxBursts,
+		MaxSampleValues: opts.MaxSampleValues,
+		SummaryKey:      opts.SummaryKey,
+		Window:          time.Duration(opts.Window),
+	}), nil
+}
+
+// wrapConcurrency wraps the given handler in a handler which limits the number of records handled at the same time.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: the maximum number of records handled at the same time is negative
+func wrapConcurrency(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Instrument    bool `json:"instrument"`
+		MaxConcurrent int  `json:"max_concurrent"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	var v optionsValidation
+	v.checkNonNegative("max_concurrent", int64(opts.MaxConcurrent))
+	if err := v.err(ConcurrencyWrapperType); err != nil {
+		return nil, err
+	}
+	return xlog.NewConcurrencyHandler(h, xlog.ConcurrencyHandlerOptions{
+		Instrument:    opts.Instrument,
+		MaxConcurrent: opts.MaxConcurrent,
+	}), nil
+}
+
+// wrapDedup wraps the given handler in a handler which removes duplicate attribute keys.
+//
+// The wrapper has no options.
+func wrapDedup(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	return xlog.NewDedupHandler(h), nil
+}
+
+// wrapFingerprint wraps the given handler in a handler which stamps records with a fingerprint and logger name.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapFingerprint(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		FingerprintKey string `json:"fingerprint_key"`
+		LoggerKey      string `json:"logger_key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewFingerprintHandler(h, xlog.FingerprintHandlerOptions{
+		FingerprintKey: opts.FingerprintKey,
+		LoggerKey:      opts.LoggerKey,
+	}), nil
+}
+
+// wrapFlatten wraps the given handler in a handler which flattens nested groups into attributes with dotted keys.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapFlatten(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Separator string `json:"separator"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewFlattenHandler(h, xlog.FlattenHandlerOptions{Separator: opts.Separator}), nil
+}
+
+// wrapGoroutineAttrs wraps the given handler in a handler which adds the attributes attached to the goroutine logging
+// each record.
+//
+// The wrapper has no options.
+func wrapGoroutineAttrs(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	return xlog.NewGoroutineAttrsHandler(h), nil
+}
+
+// wrapRateLimit wraps the given handler in a handler which limits the rate of records with the same key.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: the level is invalid, the key is empty or the rate, burst, key expiry or max keys
+//     is negative
+func wrapRateLimit(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		AlwaysLevel string         `json:"always_level"`
+		Burst       int            `json:"burst"`
+		Key         string         `json:"key"`
+		KeyExpiry   types.Duration `json:"key_expiry"`
+		MaxKeys     int            `json:"max_keys"`
+		Name        string         `json:"name"`
+		Rate        float64        `json:"rate"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	var v optionsValidation
+	var alwaysLevel slog.Leveler
+	if opts.AlwaysLevel != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(opts.AlwaysLevel)); err != nil {
+			v.addf("always_level", "%s", err.Error())
+		}
+		alwaysLevel = l
+	}
+	v.checkNonNegative("burst", int64(opts.Burst))
+	if opts.Key == "" {
+		v.addf("key", "value is required")
+	}
+	v.checkNonNegative("key_expiry", int64(opts.KeyExpiry))
+	v.checkNonNegative("max_keys", int64(opts.MaxKeys))
+	if opts.Rate < 0 {
+		v.addf("rate", "value cannot be negative")
+	}
+	if err := v.err(RateLimitWrapperType); err != nil {
+		return nil, err
+	}
+	return xlog.NewRateLimitHandler(h, xlog.RateLimitHandlerOptions{
+		AlwaysLevel: alwaysLevel,
+		Burst:       opts.Burst,
+		KeyAttr:     opts.Key,
+		KeyExpiry:   time.Duration(opts.KeyExpiry),
+		MaxKeys:     opts.MaxKeys,
+		Name:        opts.Name,
+		Rate:        opts.Rate,
+	}), nil
+}
+
+// wrapRequestID wraps the given handler in a handler which adds the request ID stored in the context to records.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapRequestID(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewRequestIDHandler(h, xlog.RequestIDHandlerOptions{Key: opts.Key}), nil
+}
+
+// wrapSequence wraps the given handler in a handler which stamps records with a sequence number and instance ID.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapSequence(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		InstanceID    string `json:"instance_id"`
+		InstanceIDKey string `json:"instance_id_key"`
+		SequenceKey   string `json:"sequence_key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewSequenceHandler(h, xlog.SequenceHandlerOptions{
+		InstanceID:    opts.InstanceID,
+		InstanceIDKey: opts.InstanceIDKey,
+		SequenceKey:   opts.SequenceKey,
+	}), nil
+}
+
+// wrapSourceFilter wraps the given handler in a handler which filters records based on their source location.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func wrapSourceFilter(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		DefaultAction string `json:"default_action"`
+		Rules         []struct {
+			Action  string `json:"action"`
+			File    string `json:"file"`
+			Level   string `json:"level"`
+			Package string `json:"package"`
+		} `json:"rules"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	filterOpts := xlog.SourceFilterOptions{
+		DefaultAction: xlog.SourceFilterAction(strings.TrimSpace(strings.ToLower(opts.DefaultAction))),
+	}
+	for i, rule := range opts.Rules {
+		r := xlog.SourceFilterRule{
+			Action:  xlog.SourceFilterAction(strings.TrimSpace(strings.ToLower(rule.Action))),
+			File:    rule.File,
+			Package: rule.Package,
+		}
+		if rule.Level != "" {
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(rule.Level)); err != nil {
+				return nil, xerrors.Wrapf(xlog.OptionsValidationError, err, "rules[%d].level: %s", i,
+					err.Error()).WithAttr("field", fmt.Sprintf("rules[%d].level", i))
+			}
+			r.Level = level
+		}
+		filterOpts.Rules = append(filterOpts.Rules, r)
+	}
+	return xlog.NewSourceFilterHandler(h, filterOpts)
+}
+
+// wrapSubject wraps the given handler in a handler which tags records with the subject identifier in their context.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapSubject(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewSubjectHandler(h, xlog.SubjectHandlerOptions{Key: opts.Key}), nil
+}
+
+// wrapTemplate wraps the given handler in a handler which translates and renders named placeholders in messages.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapTemplate(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Key          string `json:"key"`
+		Locale       string `json:"locale"`
+		MessageIDKey string `json:"message_id_key"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewTemplateHandler(h, xlog.TemplateHandlerOptions{
+		Locale:       opts.Locale,
+		MessageIDKey: opts.MessageIDKey,
+		TemplateKey:  opts.Key,
+	}), nil
+}
+
+// wrapTimeNormalize wraps the given handler in a handler which normalizes the time of records.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+//   - [xlog.OptionsValidationError]: the skew tolerance is negative
+func wrapTimeNormalize(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Offset        types.Duration `json:"offset"`
+		SkewKey       string         `json:"skew_key"`
+		SkewTolerance types.Duration `json:"skew_tolerance"`
+		UTC           bool           `json:"utc"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+
+	var v optionsValidation
+	v.checkNonNegative("skew_tolerance", int64(opts.SkewTolerance))
+	if err := v.err(TimeNormalizeWrapperType); err != nil {
+		return nil, err
+	}
+	return xlog.NewTimeNormalizeHandler(h, xlog.TimeNormalizeOptions{
+		Offset:        time.Duration(opts.Offset),
+		SkewKey:       opts.SkewKey,
+		SkewTolerance: time.Duration(opts.SkewTolerance),
+		UTC:           opts.UTC,
+	}), nil
+}
+
+// wrapTrace wraps the given handler in a handler which traces whether each record is filtered, accepted or fails.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.MarshalError]: failed to unmarshal the options
+func wrapTrace(h slog.Handler, options json.RawMessage) (slog.Handler, xerrors.Error) {
+	var opts struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, xerrors.Wrapf(xlog.MarshalError, err, "failed to unmarshal wrapper options: %s",
+			err.Error()).WithAttr("options", string(options))
+	}
+	return xlog.NewTraceHandler(h, opts.Name), nil
+}
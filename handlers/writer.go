@@ -3,46 +3,244 @@ package handlers
 import (
 	"bufio"
 	"io"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// atomicWriter is a goroutine-safe wrapper for a bufio.Writer.
+const (
+	// asyncWriterBackoff is the time a writer sleeps between checks for a free slot when the ring of an
+	// [asyncWriter] remains full after asyncWriterSpins attempts.
+	asyncWriterBackoff = 50 * time.Microsecond
+
+	// asyncWriterMaxSlotSize is the capacity above which the buffer of a slot in the ring of an [asyncWriter] is
+	// released once its message has been read so that a few unusually large messages do not pin memory.
+	asyncWriterMaxSlotSize = 4 * 1024
+
+	// asyncWriterSpins is the number of times a writer yields the processor while the ring of an [asyncWriter] is
+	// full before it starts to sleep instead.
+	asyncWriterSpins = 16
+)
+
+// asyncWriter is a goroutine-safe wrapper for a bufio.Writer which does not serialize writers on a single lock.
+//
+// Each write copies the message (which is one full log line) into the reusable buffer of a slot of a bounded
+// multi-producer, single-consumer ring claimed using a single atomic operation. A dedicated goroutine copies the
+// messages from the ring into the buffer in the order their slots were claimed and writes the buffer to the underlying
+// writer whenever it fills up, so writers never wait for each other or for the underlying writer unless the ring is
+// full. The goroutine is only woken each time a quarter of the ring has been filled, so messages are only guaranteed
+// to reach the underlying writer once Flush or Close returns.
+//
+// The ring holds a fixed number of slots and the buffer of a slot is only kept once read if it holds at most
+// asyncWriterMaxSlotSize bytes, so the memory retained by the ring is bounded by the number of slots multiplied by
+// that size.
 //
-// It ensures that Write and Flush calls are serialized, preventing race conditions between slog writing to the buffer
-// and the Close or Flush functions flushing it on exit.
-type atomicWriter struct {
+// Once closed, the goroutine exits and any further writes are buffered synchronously under a mutex.
+type asyncWriter struct {
 	// unexported variables
-	mu  sync.Mutex    // mutex for synchronization
-	buf *bufio.Writer // underlying buffered writer
+	buf     *bufio.Writer         // buffered writer, owned by the writer goroutine until closed
+	closed  atomic.Bool           // whether or not the writer has been closed
+	done    chan struct{}         // closed when the writer goroutine exits
+	err     atomic.Pointer[error] // first error returned by the underlying writer
+	flushes chan chan error       // flush requests for the writer goroutine
+	head    uint64                // position of the next slot read by the writer goroutine
+	mask    uint64                // number of slots in the ring minus one
+	mu      sync.Mutex            // serializes Flush and Close calls and writes once closed
+	notify  chan struct{}         // wakes the writer goroutine when messages are available
+	slots   []asyncWriterSlot     // ring of messages waiting to be buffered
+	stop    chan struct{}         // closed to stop the writer goroutine
+	tail    atomic.Uint64         // position of the next slot claimed by a writer
+	wakeAt  uint64                // number of messages written to the ring between wake-ups of the writer goroutine
+	writers atomic.Int64          // number of writes in progress
 }
 
-// newAtomicWriter creates a new [atomicWriter] object.
-func newAtomicWriter(wr io.Writer, size int) *atomicWriter {
-	return &atomicWriter{
-		buf: bufio.NewWriterSize(wr, size),
-	}
+// asyncWriterSlot holds a single message in the ring of an [asyncWriter].
+//
+// The sequence number equals the position of the slot when it is free to be claimed and the position plus one when
+// it holds a message which has not been read yet.
+type asyncWriterSlot struct {
+	data []byte        // copy of the message
+	seq  atomic.Uint64 // sequence number
 }
 
-// Close securely closes the underlying buffer by simply flushing its contents.
-func (aw *atomicWriter) Close() error {
-	return aw.Flush()
+// newAsyncWriter creates a new [asyncWriter] object whose buffer holds size bytes and whose ring holds the given
+// number of messages, rounded up to a power of 2, and starts its writer goroutine.
+func newAsyncWriter(wr io.Writer, size, slots int) *asyncWriter {
+	n := uint64(2)
+	for n < uint64(slots) {
+		n <<= 1
+	}
+	aw := &asyncWriter{
+		buf:     bufio.NewWriterSize(wr, size),
+		done:    make(chan struct{}),
+		flushes: make(chan chan error),
+		mask:    n - 1,
+		notify:  make(chan struct{}, 1),
+		slots:   make([]asyncWriterSlot, n),
+		stop:    make(chan struct{}),
+		wakeAt:  max(n/4, 1),
+	}
+	for i := range aw.slots {
+		aw.slots[i].seq.Store(uint64(i))
+	}
+	go aw.run()
+	return aw
 }
 
-// Flush securely flushes the underlying buffer.
-func (aw *atomicWriter) Flush() error {
+// Close flushes the contents of the ring and the buffer to the underlying writer and stops the writer goroutine.
+func (aw *asyncWriter) Close() error {
 	aw.mu.Lock()
 	defer aw.mu.Unlock()
+	if aw.closed.Load() {
+		return aw.buf.Flush()
+	}
+
+	// wait for writes which did not see the writer closed to finish claiming their slots
+	aw.closed.Store(true)
+	for aw.writers.Load() > 0 {
+		runtime.Gosched()
+	}
+	close(aw.stop)
+	<-aw.done
+	if err := aw.err.Load(); err != nil {
+		return *err
+	}
 	return aw.buf.Flush()
 }
 
-// Write implements the io.Writer interface.
+// Flush flushes the contents of the ring and the buffer to the underlying writer.
 //
-// It locks the mutex to ensure only one goroutine can write to the buffer at a time.
-func (aw *atomicWriter) Write(p []byte) (int, error) {
+// All messages written before Flush is called are written to the underlying writer before it returns.
+func (aw *asyncWriter) Flush() error {
 	aw.mu.Lock()
 	defer aw.mu.Unlock()
+	if aw.closed.Load() {
+		return aw.buf.Flush()
+	}
+	ch := make(chan error, 1)
+	aw.flushes <- ch
+	return <-ch
+}
+
+// Write implements the io.Writer interface.
+//
+// It copies the message into the next free slot in the ring, waiting only if the ring is full.
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	aw.writers.Add(1)
+	if aw.closed.Load() {
+		aw.writers.Add(-1)
+		aw.mu.Lock()
+		defer aw.mu.Unlock()
+		return aw.buf.Write(p)
+	}
+	defer aw.writers.Add(-1)
+	if err := aw.err.Load(); err != nil {
+		return 0, *err
+	}
+
+	pos := aw.tail.Load()
+	for spins := 0; ; {
+		slot := &aw.slots[pos&aw.mask]
+		seq := slot.seq.Load()
+		switch {
+		case seq == pos:
+			if aw.tail.CompareAndSwap(pos, pos+1) {
+				slot.data = append(slot.data[:0], p...)
+				slot.seq.Store(pos + 1)
+				if pos%aw.wakeAt == 0 {
+					aw.wake()
+				}
+				return len(p), nil
+			}
+		case seq < pos:
+			// the ring is full so give the writer goroutine a chance to catch up, backing off if it falls further behind
+			aw.wake()
+			if spins < asyncWriterSpins {
+				runtime.Gosched()
+				spins++
+			} else {
+				time.Sleep(asyncWriterBackoff)
+			}
+		}
+		pos = aw.tail.Load()
+	}
+}
+
+// drain copies the messages in the ring into the buffer until the slot at the given position has been read and no
+// further messages are available.
+//
+// Slots before the given position which have been claimed but do not hold a message yet are waited for.
+func (aw *asyncWriter) drain(target uint64) {
+	for {
+		slot := &aw.slots[aw.head&aw.mask]
+		if slot.seq.Load() != aw.head+1 {
+			if aw.head >= target {
+				return
+			}
+			runtime.Gosched()
+			continue
+		}
+		if _, err := aw.buf.Write(slot.data); err != nil {
+			aw.setErr(err)
+		}
+		if cap(slot.data) > asyncWriterMaxSlotSize {
+			slot.data = nil
+		}
+		slot.seq.Store(aw.head + aw.mask + 1)
+		aw.head++
+	}
+}
+
+// run copies messages from the ring into the buffer until the writer is closed.
+func (aw *asyncWriter) run() {
+	defer close(aw.done)
+	for {
+		aw.drain(aw.head)
+		select {
+		case <-aw.notify:
+		case ch := <-aw.flushes:
+			aw.drain(aw.tail.Load())
+			ch <- aw.buf.Flush()
+		case <-aw.stop:
+			aw.drain(aw.tail.Load())
+			return
+		}
+	}
+}
+
+// setErr records the given error unless an error has already been recorded.
+func (aw *asyncWriter) setErr(err error) {
+	aw.err.CompareAndSwap(nil, &err)
+}
+
+// wake wakes the writer goroutine if it is not already due to wake up.
+func (aw *asyncWriter) wake() {
+	select {
+	case aw.notify <- struct{}{}:
+	default:
+	}
+}
+
+// hookedWriter is a goroutine-safe wrapper for an io.Writer which calls a [ConsoleWriteHook] around each write.
+//
+// The mutex may be shared by several writers (eg: for stdout and stderr) so that the hook calls for one write are
+// never interleaved with those of another.
+type hookedWriter struct {
+	// unexported variables
+	hook ConsoleWriteHook // hook to call around each write
+	mu   *sync.Mutex      // mutex for synchronization
+	w    io.Writer        // underlying writer
+}
+
+// Write implements the io.Writer interface.
+//
+// Each write holds one full message, so the hook is called once per message.
+func (hw *hookedWriter) Write(p []byte) (int, error) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
 
-	// the underlying bufio.Writer.Write will handle buffering the entire byte slice 'p' (which is one full
-	// JSON log line) and will not be interrupted by a flush or closing the writer
-	return aw.buf.Write(p)
+	hw.hook.BeforeWrite(hw.w)
+	defer hw.hook.AfterWrite(hw.w)
+	return hw.w.Write(p)
 }
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.innotegrity.dev/types"
+)
+
+// benchmarkLine is the message written by the writer benchmarks.
+var benchmarkLine = []byte(`{"time":"2025-11-04T12:00:00Z","level":"INFO","msg":"benchmark message","status":200}` + "\n")
+
+// mutexWriter is a bufio.Writer guarded by a single mutex, used as the baseline for the [asyncWriter] benchmarks.
+type mutexWriter struct {
+	buf *bufio.Writer // underlying buffered writer
+	mu  sync.Mutex    // mutex for synchronization
+}
+
+// Write locks the mutex and writes the message to the buffer.
+func (mw *mutexWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return mw.buf.Write(p)
+}
+
+// errWriter is a writer which fails every write with the same error.
+type errWriter struct {
+	err error // error returned by each write
+}
+
+// Write returns the writer's error.
+func (ew errWriter) Write(p []byte) (int, error) {
+	return 0, ew.err
+}
+
+// TestAsyncWriterOrder checks that the messages of each writer reach the underlying writer whole and in the order
+// they were written, even when the ring is much smaller than the number of messages.
+func TestAsyncWriterOrder(t *testing.T) {
+	const writers, messages = 8, 500
+	var out bytes.Buffer
+	aw := newAsyncWriter(&out, 64, 4)
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range messages {
+				if _, err := fmt.Fprintf(aw, "%d:%d\n", w, i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	next := make([]int, writers)
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var w, i int
+		if _, err := fmt.Sscanf(line, "%d:%d", &w, &i); err != nil {
+			t.Fatalf("malformed line %q: %v", line, err)
+		}
+		if i != next[w] {
+			t.Fatalf("writer %d: got message %d, want %d", w, i, next[w])
+		}
+		next[w]++
+	}
+	for w, n := range next {
+		if n != messages {
+			t.Errorf("writer %d: got %d messages, want %d", w, n, messages)
+		}
+	}
+}
+
+// TestAsyncWriterFlushClose checks that Flush and Close return only once every message written before they were
+// called has reached the underlying writer and that writes after Close are still written.
+func TestAsyncWriterFlushClose(t *testing.T) {
+	var out bytes.Buffer
+	aw := newAsyncWriter(&out, 4096, 16)
+	var want strings.Builder
+	write := func(msg string) {
+		t.Helper()
+		if _, err := aw.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		want.WriteString(msg)
+	}
+
+	write("first\n")
+	write("second\n")
+	if err := aw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Fatalf("after Flush() got %q, want %q", out.String(), want.String())
+	}
+	for i := range 100 {
+		write(fmt.Sprintf("message %d\n", i))
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Fatalf("after Close() got %q, want %q", out.String(), want.String())
+	}
+	write("late\n")
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("after second Close() got %q, want %q", out.String(), want.String())
+	}
+}
+
+// TestAsyncWriterError checks that an error returned by the underlying writer is returned by Flush, by later writes
+// and by Close.
+func TestAsyncWriterError(t *testing.T) {
+	errWrite := errors.New("write failed")
+	aw := newAsyncWriter(errWriter{err: errWrite}, 16, 4)
+	if _, err := aw.Write([]byte("a message longer than the buffer\n")); err != nil {
+		t.Fatalf("first Write() = %v, want nil", err)
+	}
+	if err := aw.Flush(); !errors.Is(err, errWrite) {
+		t.Errorf("Flush() = %v, want %v", err, errWrite)
+	}
+	if _, err := aw.Write([]byte("another message\n")); !errors.Is(err, errWrite) {
+		t.Errorf("Write() after failure = %v, want %v", err, errWrite)
+	}
+	if err := aw.Close(); !errors.Is(err, errWrite) {
+		t.Errorf("Close() = %v, want %v", err, errWrite)
+	}
+}
+
+func BenchmarkAsyncWriterParallel(b *testing.B) {
+	aw := newAsyncWriter(io.Discard, 64*1024, DefaultFileHandlerBufferSlots)
+	defer aw.Close()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkLine)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := aw.Write(benchmarkLine); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	if err := aw.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkMutexWriterParallel(b *testing.B) {
+	mw := &mutexWriter{buf: bufio.NewWriterSize(io.Discard, 64*1024)}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkLine)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := mw.Write(benchmarkLine); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkFileHandlerParallel(b *testing.B) {
+	h, err := NewFileHandler(FileHandlerOptions{
+		BufferSize: 64 * 1024,
+		File: types.Path{
+			FSPath: filepath.Join(b.TempDir(), "benchmark.log"),
+			Group:  -1,
+			Owner:  -1,
+		},
+		Format: FileHandlerJSONFormat,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	r := newBenchmarkRecord()
+	ctx := context.Background()
+	var handler slog.Handler = h
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := handler.Handle(ctx, r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
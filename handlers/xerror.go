@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// maxErrorChainDepth is the maximum number of wrapped errors expanded by [ExpandErrorAttr].
+	maxErrorChainDepth = 32
+)
+
+// errorAttrser is implemented by errors which expose the attributes attached to them.
+type errorAttrser interface {
+	Attrs() map[string]any
+}
+
+// errorCoder is implemented by errors which expose a numeric error code.
+type errorCoder interface {
+	Code() int
+}
+
+// errorStacker is implemented by errors which expose the stack trace captured when they were created.
+type errorStacker interface {
+	Stack() string
+}
+
+// ExpandErrorAttr is a ReplaceAttr-compatible function (see [slog.HandlerOptions]) which expands attributes holding
+// an [xerrors.Error] into a group instead of relying on the string returned by its Error function.
+//
+// The group holds the following attributes, where those which are not available for an error are omitted:
+//   - "message": the string returned by the error's Error function
+//   - "code": the error's code
+//   - "attrs": a group holding the attributes attached to the error, sorted by key
+//   - "stack": the stack trace captured when the error was created
+//   - "cause": a group holding the same attributes for the error wrapped by the error, if any
+//
+// Errors which are not an [xerrors.Error] are returned unchanged, but wrapped errors of any type are expanded as part
+// of the chain.
+func ExpandErrorAttr(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindAny {
+		return attr
+	}
+	err, ok := attr.Value.Any().(xerrors.Error)
+	if !ok || err == nil {
+		return attr
+	}
+	attr.Value = slog.GroupValue(expandError(err, 0)...)
+	return attr
+}
+
+// expandError returns the attributes describing the given error and the chain of errors it wraps.
+func expandError(err error, depth int) []slog.Attr {
+	attrs := []slog.Attr{slog.String("message", err.Error())}
+	if e, ok := err.(errorCoder); ok {
+		attrs = append(attrs, slog.Int("code", e.Code()))
+	}
+	if e, ok := err.(errorAttrser); ok {
+		if errAttrs := e.Attrs(); len(errAttrs) > 0 {
+			group := make([]slog.Attr, 0, len(errAttrs))
+			for _, key := range slices.Sorted(maps.Keys(errAttrs)) {
+				group = append(group, slog.Any(key, errAttrs[key]))
+			}
+			attrs = append(attrs, slog.Attr{Key: "attrs", Value: slog.GroupValue(group...)})
+		}
+	}
+	if e, ok := err.(errorStacker); ok {
+		if stack := e.Stack(); stack != "" {
+			attrs = append(attrs, slog.String("stack", stack))
+		}
+	}
+	if cause := errors.Unwrap(err); cause != nil {
+		if depth+1 >= maxErrorChainDepth {
+			attrs = append(attrs, slog.Group("cause", slog.String("message", cause.Error())))
+		} else {
+			attrs = append(attrs, slog.Attr{Key: "cause", Value: slog.GroupValue(expandError(cause, depth+1)...)})
+		}
+	}
+	return attrs
+}
+
+// replaceAttrWithErrors wraps the given ReplaceAttr function so that the attribute it returns is passed through
+// [ExpandErrorAttr].
+//
+// If next is nil, the expanded attribute is simply returned.
+func replaceAttrWithErrors(next func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if next != nil {
+			attr = next(groups, attr)
+			if attr.Key == "" {
+				return attr
+			}
+		}
+		return ExpandErrorAttr(groups, attr)
+	}
+}
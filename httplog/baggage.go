@@ -0,0 +1,251 @@
+package httplog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// maxBaggageBytes is the maximum length of the baggage header written or read, as recommended by the W3C Baggage
+	// specification.
+	maxBaggageBytes = 8192
+
+	// maxBaggageMembers is the maximum number of members of the baggage header written or read, as recommended by the
+	// W3C Baggage specification.
+	maxBaggageMembers = 180
+)
+
+var (
+	// DefaultBaggageHeader is the default name of the header which holds the baggage propagated between services.
+	//
+	// This value is used when the header in [BaggageOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/httplog#BaggageOptions
+	//   https://www.w3.org/TR/baggage/
+	DefaultBaggageHeader = "baggage"
+)
+
+// BaggageOptions holds the options for propagating baggage (see [xlog.AddBaggageToContext]) between services.
+type BaggageOptions struct {
+	// Header is the name of the header which holds the baggage, written in the W3C Baggage format (eg:
+	// "tenant_id=acme,session_id=s%2F42").
+	//
+	// Members of the header written by others (eg: OpenTelemetry) are kept when the baggage is injected, so the header
+	// may be shared with them. Their members are kept out of the context when the baggage is extracted unless their
+	// keys are listed in Keys.
+	//
+	// The default behavior is defined by the default baggage header setting defined in the package.
+	Header string
+
+	// Keys holds the keys of the attributes which are propagated.
+	//
+	// Only the listed attributes are written to outgoing requests and only the listed members of incoming headers are
+	// added to the context, so that callers cannot inject arbitrary attributes into the records of a service. The
+	// request ID is handled separately (see TrustRequestID), so its key does not need to be listed.
+	//
+	// The default behavior is to propagate no attributes.
+	Keys []string
+
+	// TrustRequestID indicates whether or not to store the request ID held in the member of incoming headers whose key
+	// is the default request ID key defined in the xlog package in the context using [xlog.AddRequestIDToContext], so
+	// that the ID generated by the calling service is kept.
+	//
+	// The request ID stored in the context, if any, is always written to outgoing requests. IDs longer than 128
+	// characters or holding characters other than printable ASCII characters (excluding spaces) are ignored. Set
+	// [Options.TrustRequestID] as well so that [Middleware] uses the ID stored in the context.
+	//
+	// The default behavior is to ignore the request ID held in incoming headers.
+	TrustRequestID bool
+}
+
+// baggageTransport is the [http.RoundTripper] returned by [NewBaggageTransport].
+type baggageTransport struct {
+	// unexported variables
+	options BaggageOptions    // immutable transport options
+	rt      http.RoundTripper // underlying transport
+}
+
+// BaggageMiddleware returns HTTP middleware which adds the baggage held in the header of each request to the
+// request's context using [ExtractBaggage].
+//
+// Place it before [Middleware] and set TrustRequestID in the options of both so that the request ID propagated by the
+// calling service, if any, is used as the ID of the request.
+func BaggageMiddleware(options BaggageOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ExtractBaggage(r.Context(), r.Header, options)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ExtractBaggage adds the members of the baggage header in the given request headers to the baggage stored in the
+// given context and returns a new context.
+//
+// The values of the members whose keys are listed in the options are added as string attributes. If TrustRequestID is
+// set in the options, a valid member holding the request ID is stored in the context using
+// [xlog.AddRequestIDToContext] instead. Other members and members which are malformed or beyond the limits of the W3C
+// Baggage specification are ignored.
+func ExtractBaggage(ctx context.Context, header http.Header, options BaggageOptions) context.Context {
+	if options.Header == "" {
+		options.Header = DefaultBaggageHeader
+	}
+	var attrs []slog.Attr
+	var size, members int
+	for _, value := range header.Values(options.Header) {
+		for member := range strings.SplitSeq(value, ",") {
+			if size += len(member) + 1; size > maxBaggageBytes+1 || members >= maxBaggageMembers {
+				break
+			}
+			members++
+			key, value, ok := parseBaggageMember(member)
+			switch {
+			case !ok:
+			case key == xlog.DefaultRequestIDKey:
+				if options.TrustRequestID && validRequestID(value) {
+					ctx = xlog.AddRequestIDToContext(ctx, value)
+				}
+			case slices.Contains(options.Keys, key):
+				attrs = append(attrs, slog.String(key, value))
+			}
+		}
+	}
+	if len(attrs) == 0 {
+		return ctx
+	}
+	return xlog.AddBaggageToContext(ctx, attrs...)
+}
+
+// InjectBaggage writes the baggage whose keys are listed in the options and the request ID stored in the given context
+// to the baggage header in the given request headers.
+//
+// Values are written as strings (see [slog.Value.String]). Attributes whose keys cannot be written in the W3C Baggage
+// format and attributes beyond the limits of the specification are skipped.
+func InjectBaggage(ctx context.Context, header http.Header, options BaggageOptions) {
+	if options.Header == "" {
+		options.Header = DefaultBaggageHeader
+	}
+	var attrs []slog.Attr
+	for _, attr := range xlog.BaggageFromContext(ctx) {
+		if attr.Key != xlog.DefaultRequestIDKey && slices.Contains(options.Keys, attr.Key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	if id := xlog.RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, xlog.RequestIDAttr(id))
+	}
+
+	var ours []string
+	keys := map[string]bool{}
+	for _, attr := range attrs {
+		if !validBaggageKey(attr.Key) {
+			continue
+		}
+		keys[attr.Key] = true
+		ours = append(ours, attr.Key+"="+escapeBaggageValue(attr.Value.Resolve().String()))
+	}
+	if len(ours) == 0 {
+		return
+	}
+
+	// keep the members written by others, followed by ours, as long as they fit within the limits
+	var members []string
+	for _, value := range header.Values(options.Header) {
+		for member := range strings.SplitSeq(value, ",") {
+			if key, _, ok := parseBaggageMember(member); ok && !keys[key] {
+				members = append(members, strings.TrimSpace(member))
+			}
+		}
+	}
+	var size int
+	var kept []string
+	for _, member := range append(members, ours...) {
+		if size+len(member)+1 > maxBaggageBytes+1 || len(kept) >= maxBaggageMembers {
+			break
+		}
+		size += len(member) + 1
+		kept = append(kept, member)
+	}
+	header.Set(options.Header, strings.Join(kept, ","))
+}
+
+// NewBaggageTransport returns a new [http.RoundTripper] which writes the baggage and request ID stored in the context
+// of each request to its baggage header using [InjectBaggage] before passing it to the given transport.
+//
+// If rt is nil, [http.DefaultTransport] is used.
+func NewBaggageTransport(rt http.RoundTripper, options BaggageOptions) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &baggageTransport{
+		options: options,
+		rt:      rt,
+	}
+}
+
+// RoundTrip writes the baggage to a copy of the request and passes the copy to the underlying transport.
+func (t *baggageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	InjectBaggage(req.Context(), req.Header, t.options)
+	return t.rt.RoundTrip(req)
+}
+
+// escapeBaggageValue percent-encodes the characters in the given value which may not appear in the value of a member
+// of the baggage header.
+func escapeBaggageValue(value string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c > '~' || c == '"' || c == ',' || c == ';' || c == '\\' || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// parseBaggageMember returns the key and decoded value of the given member of the baggage header, ignoring any
+// properties, and whether or not the member is well formed.
+func parseBaggageMember(member string) (string, string, bool) {
+	member, _, _ = strings.Cut(member, ";")
+	key, value, ok := strings.Cut(member, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || !validBaggageKey(key) {
+		return "", "", false
+	}
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// validBaggageKey returns whether or not the given key is a valid token which may be used as the key of a member of
+// the baggage header.
+func validBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0 {
+			continue
+		}
+		return false
+	}
+	return true
+}
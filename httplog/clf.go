@@ -0,0 +1,178 @@
+package httplog
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strconv"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// clfTimeFormat is the layout used to format the time of the request in access log lines.
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+	// durationKey is the key of the attribute holding the total duration of the request.
+	durationKey = "duration"
+
+	// userKey is the key of the attribute holding the name of the authenticated user, if any.
+	userKey = "user"
+)
+
+// accessLogEncoder encodes records logged by [Middleware] as access log lines.
+type accessLogEncoder struct {
+	// unexported variables
+	combined bool // whether or not to include the referer and user agent
+}
+
+// NewCombinedLogEncoder creates a new [xlog.Encoder] which encodes records logged by [Middleware] as lines in the
+// Combined Log Format used by Apache and nginx (eg: for analyzers like GoAccess or AWStats).
+//
+// Lines are in the same format as [NewCommonLogEncoder] followed by the quoted referer and user agent.
+//
+// References:
+//
+//	https://httpd.apache.org/docs/current/logs.html#combined
+func NewCombinedLogEncoder() xlog.Encoder {
+	return &accessLogEncoder{
+		combined: true,
+	}
+}
+
+// NewCommonLogEncoder creates a new [xlog.Encoder] which encodes records logged by [Middleware] as lines in the
+// Common Log Format used by Apache and nginx.
+//
+// The remote host is taken from the remote address without its port and the time is the time at which the request
+// started. The authenticated user is taken from the "user" attribute, if a handler adds one to the request's record
+// using [xlog.RequestLogFromContext]. Missing values are written as "-" and quotes, backslashes and non-printable
+// characters inside of quoted values are escaped the same way as Apache. All other attributes, as well as the level
+// and message, are not written.
+//
+// References:
+//
+//	https://httpd.apache.org/docs/current/logs.html#common
+func NewCommonLogEncoder() xlog.Encoder {
+	return &accessLogEncoder{}
+}
+
+// EncodeRecord encodes the record as a single access log line.
+func (e *accessLogEncoder) EncodeRecord(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr, _ []string) error {
+	values := make(map[string]slog.Value, len(attrs)+r.NumAttrs())
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value.Resolve()
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		values[attr.Key] = attr.Value.Resolve()
+		return true
+	})
+
+	// host, identity and user
+	host := stringValue(values[remoteAddrKey])
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	writeField(buf, host)
+	buf.WriteString(" - ")
+	writeField(buf, stringValue(values[userKey]))
+
+	// time at which the request started
+	start := r.Time
+	if v, ok := values[durationKey]; ok && v.Kind() == slog.KindDuration {
+		start = start.Add(-v.Duration())
+	}
+	if start.IsZero() {
+		start = xlog.DefaultClock.Now()
+	}
+	buf.WriteString(" [")
+	buf.WriteString(start.Format(clfTimeFormat))
+	buf.WriteString("] ")
+
+	// request line
+	method := stringValue(values[methodKey])
+	if method == "" {
+		writeQuoted(buf, "")
+	} else {
+		writeQuoted(buf, method+" "+stringValue(values[uriKey])+" "+stringValue(values[protoKey]))
+	}
+
+	// status and size of the response
+	buf.WriteByte(' ')
+	writeField(buf, stringValue(values[statusKey]))
+	buf.WriteByte(' ')
+	if size := stringValue(values[bytesKey]); size != "0" {
+		writeField(buf, size)
+	} else {
+		buf.WriteByte('-')
+	}
+
+	// referer and user agent
+	if e.combined {
+		buf.WriteByte(' ')
+		writeQuoted(buf, stringValue(values[refererKey]))
+		buf.WriteByte(' ')
+		writeQuoted(buf, stringValue(values[userAgentKey]))
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// stringValue returns the given value as a string or an empty string if the value is unset.
+func stringValue(v slog.Value) string {
+	if v.Kind() == slog.KindAny && v.Any() == nil {
+		return ""
+	}
+	return v.String()
+}
+
+// writeEscaped writes the string to the buffer with quotes, backslashes and non-printable characters escaped, along
+// with spaces if escapeSpace is true.
+func writeEscaped(buf *bytes.Buffer, s string, escapeSpace bool) {
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c == utf8.RuneError && size == 1, c < 0x20, c == 0x7f, c == ' ' && escapeSpace:
+			buf.WriteString(`\x`)
+			if s[i] < 0x10 {
+				buf.WriteByte('0')
+			}
+			buf.WriteString(strconv.FormatUint(uint64(s[i]), 16))
+		default:
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+}
+
+// writeField writes an unquoted field to the buffer, using "-" for an empty value.
+//
+// Spaces are escaped along with any other characters escaped by [writeEscaped] so the field cannot be mistaken for
+// more than one field.
+func writeField(buf *bytes.Buffer, s string) {
+	if s == "" {
+		buf.WriteByte('-')
+		return
+	}
+	writeEscaped(buf, s, true)
+}
+
+// writeQuoted writes a quoted field to the buffer, using "-" for an empty value.
+func writeQuoted(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	if s == "" {
+		buf.WriteByte('-')
+	} else {
+		writeEscaped(buf, s, false)
+	}
+	buf.WriteByte('"')
+}
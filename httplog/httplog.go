@@ -0,0 +1,251 @@
+// Package httplog provides HTTP middleware which logs a single canonical record for each request using an
+// [xlog.RequestLogBuilder].
+//
+// Handlers wrapped by the middleware can add attributes, timings and counters to the request's record using
+// [xlog.RequestLogFromContext]. The record is logged once the handler returns and holds the following attributes in
+// addition to those added by the handler:
+//   - request_id: the ID of the request (see [xlog.NewRequestID]), which is also stored in the request's context using
+//     [xlog.AddRequestIDToContext] and returned in a response header
+//   - method, uri, proto, host, remote_addr, user_agent and referer: details of the request
+//   - status and bytes: the status code and number of body bytes written in the response
+//
+// Responses with a 4xx status are logged at [slog.LevelWarn] and responses with a 5xx status or whose handler panics
+// are logged at [slog.LevelError], unless the handler raises the level further.
+//
+// Records logged by the middleware can also be written as Common or Combined Log Format lines using the encoders
+// returned by [NewCommonLogEncoder] and [NewCombinedLogEncoder].
+//
+// The request ID and baggage (see [xlog.AddBaggageToContext]) can be propagated to other services by sending requests
+// through the transport returned by [NewBaggageTransport] and extracted by the services using [BaggageMiddleware].
+package httplog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// keys of the attributes added to each record by the middleware
+	bytesKey      = "bytes"
+	hostKey       = "host"
+	methodKey     = "method"
+	protoKey      = "proto"
+	refererKey    = "referer"
+	remoteAddrKey = "remote_addr"
+	statusKey     = "status"
+	uriKey        = "uri"
+	userAgentKey  = "user_agent"
+
+	// maxRequestIDLength is the maximum length of a request ID accepted from a request header.
+	maxRequestIDLength = 128
+)
+
+var (
+	// DefaultMessage is the default message for the record logged for each request.
+	//
+	// This value is used when the message in [Options] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/httplog#Options
+	DefaultMessage = "http request"
+
+	// DefaultRequestIDHeader is the default name of the header which holds the ID of each request.
+	//
+	// This value is used when the request ID header in [Options] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/httplog#Options
+	DefaultRequestIDHeader = "X-Request-ID"
+)
+
+// Options holds the options for the middleware returned by [Middleware].
+type Options struct {
+	// Logger is the logger used to log the record for each request.
+	//
+	// The default behavior is to use the logger stored in the request's context using [xlog.AddToContext] or the
+	// default logger if there isn't one.
+	Logger *slog.Logger
+
+	// Message is the message for the record logged for each request.
+	//
+	// The default behavior is defined by the default message setting defined in the package.
+	Message string
+
+	// NewRequestID is called to generate the ID of each request which doesn't already have a trusted one.
+	//
+	// The default behavior is to use [xlog.NewRequestID].
+	NewRequestID xlog.RequestIDFn
+
+	// RequestIDHeader is the name of the header in which the ID of each request is returned in the response and, if
+	// TrustRequestID is set, accepted from the request.
+	//
+	// The default behavior is defined by the default request ID header setting defined in the package.
+	RequestIDHeader string
+
+	// TrustRequestID indicates whether or not to use the ID stored in the request's context (see
+	// [BaggageOptions.TrustRequestID]) or held in the request ID header of the request, if it has one, instead of
+	// generating a new ID, so that the ID generated by an upstream service or proxy is kept.
+	//
+	// IDs longer than 128 characters or holding characters other than printable ASCII characters (excluding spaces)
+	// are ignored.
+	//
+	// The default behavior is to always generate a new ID.
+	TrustRequestID bool
+}
+
+// responseWriter wraps an [http.ResponseWriter] to capture the status code and number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+
+	// unexported variables
+	bytes  int64 // number of body bytes written
+	status int   // status code written, if any
+}
+
+// Middleware returns HTTP middleware which stores the ID of each request and a new [xlog.RequestLogBuilder] in the
+// context of the request and logs the builder's record once the wrapped handler returns.
+//
+// If TrustRequestID is set in the options and the request's context already holds a request ID (eg: one propagated by
+// the calling service and extracted by [BaggageMiddleware]), it is used as the ID of the request.
+func Middleware(options Options) func(http.Handler) http.Handler {
+	if options.Message == "" {
+		options.Message = DefaultMessage
+	}
+	if options.NewRequestID == nil {
+		options.NewRequestID = xlog.NewRequestID
+	}
+	if options.RequestIDHeader == "" {
+		options.RequestIDHeader = DefaultRequestIDHeader
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id string
+			if options.TrustRequestID {
+				id = xlog.RequestIDFromContext(r.Context())
+				if id == "" {
+					id = r.Header.Get(options.RequestIDHeader)
+				}
+			}
+			if !validRequestID(id) {
+				id = options.NewRequestID()
+			}
+			w.Header().Set(options.RequestIDHeader, id)
+
+			b := xlog.NewRequestLogBuilder(options.Message)
+			b.AddAttrs(
+				xlog.RequestIDAttr(id),
+				slog.String(methodKey, r.Method),
+				slog.String(uriKey, r.RequestURI),
+				slog.String(protoKey, r.Proto),
+				slog.String(hostKey, r.Host),
+				slog.String(remoteAddrKey, r.RemoteAddr),
+				slog.String(userAgentKey, r.UserAgent()),
+				slog.String(refererKey, r.Referer()),
+			)
+			rw := &responseWriter{
+				ResponseWriter: w,
+			}
+			ctx := xlog.AddRequestLogToContext(xlog.AddRequestIDToContext(r.Context(), id), b)
+
+			defer func() {
+				logger := options.Logger
+				if logger == nil {
+					logger = xlog.FromContext(ctx)
+				}
+				if v := recover(); v != nil {
+					// the handler panicked before writing a response, which the server reports as an error
+					if rw.status == 0 {
+						rw.status = http.StatusInternalServerError
+					}
+					b.SetError(fmt.Errorf("panic: %v", v))
+					rw.finish(b)
+					_ = b.Emit(ctx, logger)
+					panic(v)
+				}
+				rw.finish(b)
+				_ = b.Emit(ctx, logger)
+			}()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// Flush sends any buffered data to the client, if the underlying writer supports it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, if the underlying writer supports it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Unwrap returns the underlying writer so that it can be used by [http.ResponseController].
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Write writes the data to the response, counting the number of bytes written.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// WriteHeader writes the status code to the response, remembering the first status code written.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// finish adds the response details to the request's record and raises its level based on the status code.
+func (w *responseWriter) finish(b *xlog.RequestLogBuilder) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	b.AddAttrs(slog.Int(statusKey, status), slog.Int64(bytesKey, w.bytes))
+	switch {
+	case status >= 500:
+		b.RaiseLevel(slog.LevelError)
+	case status >= 400:
+		b.RaiseLevel(slog.LevelWarn)
+	}
+}
+
+// validRequestID returns whether or not the given request ID is not empty, is at most the maximum length and only
+// holds printable ASCII characters other than spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+const (
+	// InternalEventBuild is the event logged when a handler is built from configuration, whether or not it succeeds.
+	InternalEventBuild = "build"
+
+	// InternalEventConfigReload is the event logged when a handler is rebuilt because its configuration changed or
+	// when reloading the configuration fails.
+	InternalEventConfigReload = "config_reload"
+
+	// InternalEventFlushError is the event logged when a handler fails to flush buffered records to its destination.
+	InternalEventFlushError = "flush_error"
+
+	// InternalEventHandleError is the event logged when a handler fails to handle a record.
+	InternalEventHandleError = "handle_error"
+
+	// InternalEventKey is the key of the attribute holding the name of the event in internal event records.
+	InternalEventKey = "xlog_event"
+
+	// internalHistorySize is the number of recent internal warnings and errors kept for [RecentInternalErrors].
+	internalHistorySize = 32
+)
+
+var (
+	// internalHandler holds the handler designated using [SetInternalHandler].
+	internalHandler atomic.Pointer[internalHandlerHolder]
+
+	// internalHistory holds the most recent internal warnings and errors.
+	internalHistory = NewRingBuffer(internalHistorySize, slog.LevelWarn)
+)
+
+// internalCtxKey is the key for the context value which marks a context as being used to log an internal event.
+type internalCtxKey struct{}
+
+// internalHandlerHolder holds the handler designated using [SetInternalHandler] so that it can be stored atomically.
+type internalHandlerHolder struct {
+	handler slog.Handler // handler for internal events
+}
+
+// InternalHandler returns the handler designated using [SetInternalHandler] or nil if there isn't one.
+func InternalHandler() slog.Handler {
+	if holder := internalHandler.Load(); holder != nil {
+		return holder.handler
+	}
+	return nil
+}
+
+// LogInternal logs an operational event for the package itself (eg: [InternalEventBuild]) to the handler designated
+// using [SetInternalHandler].
+//
+// Nothing is logged if no handler has been designated or if the handler is not enabled for the level. The name of the
+// event is added to the record in the [InternalEventKey] attribute. Handlers in this package call this function
+// automatically. Custom handlers may call it to report their own events.
+//
+// To guard against recursion, events logged while the internal handler is handling another event (ie: using the
+// context passed to its Handle function) are discarded. Warnings and errors are also kept in memory, whether or not a
+// handler has been designated, and can be retrieved using [RecentInternalErrors].
+func LogInternal(ctx context.Context, level slog.Level, event, msg string, attrs ...slog.Attr) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Value(internalCtxKey{}) != nil {
+		return
+	}
+	ctx = context.WithValue(ctx, internalCtxKey{}, true)
+	h := InternalHandler()
+	keep := internalHistory.Enabled(ctx, level)
+	if !keep && (h == nil || !h.Enabled(ctx, level)) {
+		return
+	}
+
+	r := slog.NewRecord(DefaultClock.Now(), level, msg, 0)
+	r.AddAttrs(slog.String(InternalEventKey, event))
+	r.AddAttrs(attrs...)
+	if keep {
+		_ = internalHistory.Handle(ctx, r)
+	}
+	if h != nil && h.Enabled(ctx, level) {
+		_ = h.Handle(ctx, r)
+	}
+}
+
+// RecentInternalErrors returns the most recent internal events logged at [slog.LevelWarn] or above using
+// [LogInternal], from oldest to newest.
+//
+// Only a small number of events are kept, whether or not a handler has been designated using [SetInternalHandler].
+func RecentInternalErrors() []slog.Record {
+	return internalHistory.Records()
+}
+
+// SetInternalHandler designates the handler to which the package logs its own operational events (eg: handler build
+// results, flush failures and configuration reloads) separately from application logs and returns the previous
+// handler, if any.
+//
+// Use a simple handler which is unlikely to fail (eg: one writing to stderr or a small file) since the internal
+// handler cannot report its own failures. Pass nil to stop logging internal events.
+func SetInternalHandler(h slog.Handler) slog.Handler {
+	var holder *internalHandlerHolder
+	if h != nil {
+		holder = &internalHandlerHolder{
+			handler: h,
+		}
+	}
+	if prev := internalHandler.Swap(holder); prev != nil {
+		return prev.handler
+	}
+	return nil
+}
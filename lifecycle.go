@@ -0,0 +1,208 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// LifecycleConfigReload is the lifecycle event for a configuration reload.
+	LifecycleConfigReload = "config_reload"
+
+	// LifecycleSignal is the lifecycle event for a signal received by the process.
+	LifecycleSignal = "signal"
+
+	// LifecycleStart is the lifecycle event for the start of the process.
+	LifecycleStart = "start"
+
+	// LifecycleStop is the lifecycle event for the shutdown of the process.
+	LifecycleStop = "stop"
+)
+
+var (
+	// DefaultLifecycleEventKey is the default key of the attribute which holds the lifecycle event of a record.
+	//
+	// This value is used when the event key in [LifecycleOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#LifecycleOptions
+	DefaultLifecycleEventKey = "lifecycle_event"
+)
+
+// LifecycleOptions holds the options for a [LifecycleLogger].
+type LifecycleOptions struct {
+	// EnvAllowlist holds the names of the environment variables, or patterns as accepted by [path.Match] (eg:
+	// "APP_*"), whose values are included in the start record.
+	//
+	// Environment variables often hold credentials, so only list variables which are safe to log.
+	//
+	// The default behavior is to not include any environment variables.
+	EnvAllowlist []string
+
+	// EventKey is the key of the attribute which holds the lifecycle event.
+	//
+	// The default behavior is defined by the default lifecycle event key setting defined in the package.
+	EventKey string
+
+	// IncludeArgs indicates whether or not to include the command-line arguments in the start record.
+	//
+	// Arguments may hold credentials, so only enable this if the application never accepts them on the command line.
+	//
+	// The default behavior is to only include the executable.
+	IncludeArgs bool
+
+	// Level is the level at which lifecycle records are logged, except for failed configuration reloads and
+	// shutdowns caused by an error, which are always logged at [slog.LevelError].
+	//
+	// The default behavior is to log records at [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// LifecycleLogger emits standardized records for the lifecycle of a process (ie: start, shutdown, received signals
+// and configuration reloads), so that lifecycle events can be audited consistently across services.
+//
+// Each record holds an attribute whose value is one of the lifecycle events defined in the package (eg:
+// [LifecycleStart]) along with the attributes described by each method.
+type LifecycleLogger struct {
+	// unexported variables
+	logger  *slog.Logger     // logger used to log lifecycle records
+	options LifecycleOptions // immutable logger options
+	started time.Time        // time at which the logger was created
+}
+
+// NewLifecycleLogger creates a new [LifecycleLogger] which logs records using the given logger.
+//
+// If logger is nil, the default logger at the time each record is logged is used. The uptime reported when the
+// process stops is measured from the time this function is called.
+func NewLifecycleLogger(logger *slog.Logger, options LifecycleOptions) *LifecycleLogger {
+	if options.EventKey == "" {
+		options.EventKey = DefaultLifecycleEventKey
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	return &LifecycleLogger{
+		logger:  logger,
+		options: options,
+		started: time.Now(),
+	}
+}
+
+// ConfigReload logs a configuration reload from the given source (eg: a file path or URL).
+//
+// If err is not nil, the reload is logged as a failure at [slog.LevelError] along with the error.
+func (l *LifecycleLogger) ConfigReload(ctx context.Context, source string, err error) {
+	attrs := []slog.Attr{slog.String("source", source)}
+	if err != nil {
+		l.log(ctx, slog.LevelError, LifecycleConfigReload, "configuration reload failed",
+			append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	l.log(ctx, l.options.Level.Level(), LifecycleConfigReload, "configuration reloaded", attrs...)
+}
+
+// Signal logs the given signal as having been received by the process.
+func (l *LifecycleLogger) Signal(ctx context.Context, sig os.Signal) {
+	l.log(ctx, l.options.Level.Level(), LifecycleSignal, "signal received", slog.String("signal", sig.String()))
+}
+
+// Start logs the start of the process.
+//
+// The record holds the process ID ("pid"), the parent process ID ("ppid"), the host name ("hostname"), the path of the
+// executable ("executable"), the command-line arguments ("args") if enabled, a group holding the values of the allowed
+// environment variables which are set ("env") and the build information of the binary (see [BuildInfoAttr]).
+func (l *LifecycleLogger) Start(ctx context.Context) {
+	attrs := []slog.Attr{
+		slog.Int("pid", os.Getpid()),
+		slog.Int("ppid", os.Getppid()),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, slog.String("hostname", hostname))
+	}
+	if executable, err := os.Executable(); err == nil {
+		attrs = append(attrs, slog.String("executable", executable))
+	}
+	if l.options.IncludeArgs && len(os.Args) > 1 {
+		attrs = append(attrs, slog.Any("args", os.Args[1:]))
+	}
+	if env := l.allowedEnv(); len(env) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "env", Value: slog.GroupValue(env...)})
+	}
+	attrs = append(attrs, BuildInfoAttr(""))
+	l.log(ctx, l.options.Level.Level(), LifecycleStart, "process started", attrs...)
+}
+
+// Stop logs the shutdown of the process along with its uptime and the given reason (eg: "signal" or "completed").
+//
+// If err is not nil, the shutdown is logged as having been caused by the error at [slog.LevelError].
+func (l *LifecycleLogger) Stop(ctx context.Context, reason string, err error) {
+	attrs := []slog.Attr{
+		slog.String("reason", reason),
+		slog.Duration("uptime", time.Since(l.started)),
+	}
+	if err != nil {
+		l.log(ctx, slog.LevelError, LifecycleStop, "process stopped due to an error",
+			append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	l.log(ctx, l.options.Level.Level(), LifecycleStop, "process stopped", attrs...)
+}
+
+// WatchSignals logs each of the given signals received by the process until the context is canceled.
+//
+// Watching signals does not prevent them from being delivered to any other channels registered using
+// [signal.Notify], but the default action of the given signals (eg: exiting on SIGINT) is disabled while they are
+// being watched, so the application should handle them itself.
+//
+// This function blocks, so it is typically called in its own goroutine.
+func (l *LifecycleLogger) WatchSignals(ctx context.Context, signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			l.Signal(ctx, sig)
+		}
+	}
+}
+
+// allowedEnv returns the attributes holding the values of the environment variables matching the allowlist.
+func (l *LifecycleLogger) allowedEnv() []slog.Attr {
+	if len(l.options.EnvAllowlist) == 0 {
+		return nil
+	}
+	var attrs []slog.Attr
+	for _, entry := range slices.Sorted(slices.Values(os.Environ())) {
+		name, value, _ := strings.Cut(entry, "=")
+		for _, pattern := range l.options.EnvAllowlist {
+			if ok, _ := path.Match(pattern, name); ok {
+				attrs = append(attrs, slog.String(name, value))
+				break
+			}
+		}
+	}
+	return attrs
+}
+
+// log logs a lifecycle record with the given event and attributes.
+func (l *LifecycleLogger) log(ctx context.Context, level slog.Level, event, msg string, attrs ...slog.Attr) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger.LogAttrs(ctx, level, msg, append([]slog.Attr{slog.String(l.options.EventKey, event)}, attrs...)...)
+}
@@ -0,0 +1,394 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// heapObjectsMetric is the name of the runtime metric holding the bytes occupied by heap objects.
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+	// loadShedDebug is the stage at which records below [slog.LevelInfo] are shed.
+	loadShedDebug = 1
+
+	// loadShedInfo is the stage at which records below [slog.LevelWarn] are shed.
+	loadShedInfo = 2
+
+	// loadShedNone is the stage at which no records are shed.
+	loadShedNone = 0
+)
+
+var (
+	// DefaultLoadShedderInterval is the default interval at which a [LoadShedder] checks the memory pressure.
+	//
+	// This value is used when the interval in [LoadShedderOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#LoadShedderOptions
+	DefaultLoadShedderInterval = time.Second
+
+	// DefaultLoadShedderMessage is the default message of the notice records logged when a [LoadShedder] starts,
+	// changes or stops shedding records.
+	//
+	// This value is used when the message in [LoadShedderOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#LoadShedderOptions
+	DefaultLoadShedderMessage = "log load shedding changed"
+
+	// DefaultLoadShedderRecovery is the default fraction of a threshold below which the heap usage and queue depth
+	// must fall before a [LoadShedder] stops shedding the records it shed because the threshold was crossed.
+	//
+	// This value is used when the recovery in [LoadShedderOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#LoadShedderOptions
+	DefaultLoadShedderRecovery = 0.8
+)
+
+// LoadShedderOptions holds the options for a [LoadShedder].
+//
+// Thresholds which are 0 are not enforced.
+type LoadShedderOptions struct {
+	// DebugHeapBytes is the number of bytes occupied by heap objects at or above which records below
+	// [slog.LevelInfo] are shed.
+	DebugHeapBytes uint64
+
+	// DebugQueueDepth is the queue depth at or above which records below [slog.LevelInfo] are shed.
+	DebugQueueDepth int
+
+	// InfoHeapBytes is the number of bytes occupied by heap objects at or above which records below [slog.LevelWarn]
+	// are shed.
+	InfoHeapBytes uint64
+
+	// InfoQueueDepth is the queue depth at or above which records below [slog.LevelWarn] are shed.
+	InfoQueueDepth int
+
+	// Interval is the interval at which the memory pressure is checked by [LoadShedder.Run].
+	//
+	// The default behavior is defined by the default load shedder interval setting defined in the package.
+	Interval time.Duration
+
+	// Logger is the logger through which notice records are logged at [slog.LevelWarn] when shedding starts, changes
+	// or stops.
+	//
+	// The default behavior is to use [slog.Default] at the time each record is logged.
+	Logger *slog.Logger
+
+	// Message is the message of the notice records.
+	//
+	// The default behavior is defined by the default load shedder message setting defined in the package.
+	Message string
+
+	// Name is the name of the shedder used when notifying subscribers of shed records.
+	Name string
+
+	// QueueDepth is called each time the memory pressure is checked to get the number of records waiting to be
+	// delivered (eg: the sum of the pending records of each [Pipeline]).
+	//
+	// The default behavior is to only check the heap usage.
+	QueueDepth func() int
+
+	// Recovery is the fraction of a threshold below which the heap usage and queue depth must fall before the records
+	// shed because the threshold was crossed are delivered again, which keeps the shedder from flapping when the heap
+	// usage hovers around a threshold.
+	//
+	// The default behavior is defined by the default load shedder recovery setting defined in the package.
+	Recovery float64
+}
+
+// LoadShedderStats holds the current state and counters for a [LoadShedder].
+type LoadShedderStats struct {
+	// Dropped is the number of records shed which reached a handler returned by [LoadShedder.Handler].
+	Dropped uint64 `json:"dropped"`
+
+	// HeapBytes is the number of bytes occupied by heap objects when the memory pressure was last checked.
+	HeapBytes uint64 `json:"heap_bytes"`
+
+	// MinLevel is the name of the lowest level of records currently delivered (eg: "WARN"), if records are being shed.
+	MinLevel string `json:"min_level,omitempty"`
+
+	// QueueDepth is the queue depth when the memory pressure was last checked.
+	QueueDepth int `json:"queue_depth"`
+}
+
+// LoadShedder is a watchdog which protects the host application from being run out of memory by its own logging
+// pipeline.
+//
+// Wrap each handler to protect using [LoadShedder.Handler] and call [LoadShedder.Run] to check the heap usage and
+// queue depth periodically. Once the debug thresholds are crossed, records below [slog.LevelInfo] are shed. Once
+// the info thresholds are crossed, records below [slog.LevelWarn] are shed as well. Warnings, errors and records at
+// higher levels (eg: [LevelAudit]) are always delivered. Shed records are reported to drop notification subscribers
+// with the reason [DropReasonLoadShedding] and a notice record is logged whenever shedding starts, changes or stops.
+//
+// All methods are safe to call concurrently.
+type LoadShedder struct {
+	// unexported variables
+	dropped  atomic.Uint64      // number of records shed
+	heap     uint64             // heap usage when the memory pressure was last checked
+	minLevel atomic.Int64       // lowest level of records delivered
+	mu       sync.Mutex         // protects the heap usage, queue depth, sample and stage
+	options  LoadShedderOptions // immutable shedder options
+	queue    int                // queue depth when the memory pressure was last checked
+	sample   [1]metrics.Sample  // sample used to read the heap usage
+	stage    int                // current shedding stage
+}
+
+// loadShedHandler is the [slog.Handler] returned by [LoadShedder.Handler].
+type loadShedHandler struct {
+	// unexported variables
+	handler slog.Handler // underlying handler
+	shedder *LoadShedder // shedder deciding which records are shed
+}
+
+// ensure [loadShedHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &loadShedHandler{}
+
+// NewLoadShedder creates a new [LoadShedder] with the given options.
+//
+// Call [LoadShedder.Run] to start checking the memory pressure.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the interval is negative, the recovery is not between 0 and 1 or an info threshold is
+//     lower than the matching debug threshold
+func NewLoadShedder(options LoadShedderOptions) (*LoadShedder, xerrors.Error) {
+	if options.Interval < 0 {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid load shedder options: interval: %s: must not be "+
+			"negative", options.Interval).WithAttr("fields", []string{"interval"})
+	}
+	if options.Recovery < 0 || options.Recovery > 1 {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid load shedder options: recovery: %g: must be "+
+			"between 0 and 1", options.Recovery).WithAttr("fields", []string{"recovery"})
+	}
+	if options.DebugHeapBytes > 0 && options.InfoHeapBytes > 0 && options.InfoHeapBytes < options.DebugHeapBytes {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid load shedder options: info_heap_bytes: %d: must "+
+			"not be lower than debug_heap_bytes", options.InfoHeapBytes).WithAttr("fields", []string{"info_heap_bytes"})
+	}
+	if options.DebugQueueDepth > 0 && options.InfoQueueDepth > 0 && options.InfoQueueDepth < options.DebugQueueDepth {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid load shedder options: info_queue_depth: %d: must "+
+			"not be lower than debug_queue_depth", options.InfoQueueDepth).
+			WithAttr("fields", []string{"info_queue_depth"})
+	}
+	if options.Interval == 0 {
+		options.Interval = DefaultLoadShedderInterval
+	}
+	if options.Message == "" {
+		options.Message = DefaultLoadShedderMessage
+	}
+	if options.Recovery == 0 {
+		options.Recovery = DefaultLoadShedderRecovery
+	}
+
+	s := &LoadShedder{
+		options: options,
+	}
+	s.minLevel.Store(math.MinInt64)
+	s.sample[0].Name = heapObjectsMetric
+	return s, nil
+}
+
+// Check reads the current heap usage and queue depth and changes which records are shed if a threshold has been
+// crossed, logging a notice record if it does.
+//
+// Shedding increases as soon as a threshold is crossed but only decreases once the heap usage and queue depth fall
+// below the recovery fraction of the threshold.
+func (s *LoadShedder) Check(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	metrics.Read(s.sample[:])
+	if s.sample[0].Value.Kind() == metrics.KindUint64 {
+		s.heap = s.sample[0].Value.Uint64()
+	}
+	if s.options.QueueDepth != nil {
+		s.queue = s.options.QueueDepth()
+	}
+
+	stage := s.pressure(1)
+	if stage < s.stage {
+		stage = min(s.stage, s.pressure(s.options.Recovery))
+	}
+	if stage == s.stage {
+		s.mu.Unlock()
+		return
+	}
+	s.stage = stage
+	heap, queue := s.heap, s.queue
+	switch stage {
+	case loadShedDebug:
+		s.minLevel.Store(int64(slog.LevelInfo))
+	case loadShedInfo:
+		s.minLevel.Store(int64(slog.LevelWarn))
+	default:
+		s.minLevel.Store(math.MinInt64)
+	}
+	s.mu.Unlock()
+
+	logger := s.options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []slog.Attr{
+		slog.Bool("shedding", stage != loadShedNone),
+		slog.Uint64("heap_bytes", heap),
+		slog.Uint64("dropped", s.dropped.Load()),
+	}
+	if stage != loadShedNone {
+		attrs = append(attrs, slog.String("min_level", slog.Level(s.minLevel.Load()).String()))
+	}
+	if s.options.QueueDepth != nil {
+		attrs = append(attrs, slog.Int("queue_depth", queue))
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, s.options.Message, attrs...)
+}
+
+// Handler returns a new [slog.Handler] which passes records to the given handler unless they are currently being
+// shed.
+//
+// Records being shed are rejected by the handler's Enabled function, so that the application does not spend memory
+// building them. Since Enabled may be called without a record being logged (eg: to decide whether or not to compute
+// an expensive attribute), only records dropped by the handler's Handle function (eg: records built before shedding
+// started or logged without checking Enabled) are counted as shed and reported to drop notification subscribers.
+func (s *LoadShedder) Handler(h slog.Handler) slog.Handler {
+	return &loadShedHandler{
+		handler: h,
+		shedder: s,
+	}
+}
+
+// Options returns a copy of the shedder's options.
+func (s *LoadShedder) Options() any {
+	return s.options
+}
+
+// Run checks the memory pressure at the configured interval until the context is canceled.
+//
+// This function blocks, so it is typically called in its own goroutine.
+func (s *LoadShedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	for {
+		s.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats returns the shedder's current state and counters.
+func (s *LoadShedder) Stats() LoadShedderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := LoadShedderStats{
+		Dropped:    s.dropped.Load(),
+		HeapBytes:  s.heap,
+		QueueDepth: s.queue,
+	}
+	if s.stage != loadShedNone {
+		stats.MinLevel = slog.Level(s.minLevel.Load()).String()
+	}
+	return stats
+}
+
+// drop counts a shed record and notifies subscribers.
+func (s *LoadShedder) drop() {
+	s.dropped.Add(1)
+	NotifyDropped(s.options.Name, "load_shed", DropReasonLoadShedding, 1)
+}
+
+// pressure returns the shedding stage for the current heap usage and queue depth with the thresholds scaled by the
+// given fraction.
+//
+// The caller must hold the lock.
+func (s *LoadShedder) pressure(fraction float64) int {
+	crossed := func(heapBytes uint64, queueDepth int) bool {
+		return (heapBytes > 0 && float64(s.heap) >= float64(heapBytes)*fraction) ||
+			(queueDepth > 0 && s.options.QueueDepth != nil && float64(s.queue) >= float64(queueDepth)*fraction)
+	}
+	switch {
+	case crossed(s.options.InfoHeapBytes, s.options.InfoQueueDepth):
+		return loadShedInfo
+	case crossed(s.options.DebugHeapBytes, s.options.DebugQueueDepth):
+		return loadShedDebug
+	default:
+		return loadShedNone
+	}
+}
+
+// shed returns whether or not records at the given level are currently being shed.
+func (s *LoadShedder) shed(level slog.Level) bool {
+	return int64(level) < s.minLevel.Load()
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *loadShedHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level and records at the level are
+// not currently being shed.
+//
+// Records rejected because they are being shed are not counted.
+func (h *loadShedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return !h.shedder.shed(level) && h.handler.Enabled(ctx, level)
+}
+
+// Handle passes the record to the underlying handler unless records at its level are currently being shed, in which
+// case the record is dropped.
+func (h *loadShedHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.shedder.shed(r.Level) {
+		h.shedder.drop()
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the shedder's options.
+func (h *loadShedHandler) Options() any {
+	return h.shedder.Options()
+}
+
+// Type returns the type of the handler.
+func (h *loadShedHandler) Type() string {
+	return "load_shed"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *loadShedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &loadShedHandler{
+		handler: h.handler.WithAttrs(attrs),
+		shedder: h.shedder,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *loadShedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &loadShedHandler{
+		handler: h.handler.WithGroup(name),
+		shedder: h.shedder,
+	}
+}
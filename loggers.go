@@ -0,0 +1,254 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// _loggers holds the handlers, levels and loggers for named loggers.
+	_loggers = &loggerRegistry{
+		handlers: map[string]slog.Handler{},
+		levels:   map[string]slog.Leveler{},
+		loggers:  map[string]*slog.Logger{},
+	}
+)
+
+// loggerNameCtxKey is just a key for storing the name of a named logger in a context.
+type loggerNameCtxKey struct{}
+
+// loggerRegistry holds the handlers and level overrides attached to logger names.
+type loggerRegistry struct {
+	// unexported variables
+	gen      atomic.Uint64           // incremented whenever a handler or level changes
+	handlers map[string]slog.Handler // handlers attached to names
+	levels   map[string]slog.Leveler // level overrides attached to names
+	loggers  map[string]*slog.Logger // loggers returned by GetLogger
+	mu       sync.RWMutex            // protects the maps
+}
+
+// namedHandler is the [slog.Handler] used by the loggers returned by [GetLogger].
+type namedHandler struct {
+	// unexported variables
+	cache atomic.Pointer[namedHandlerCache] // resolved handler and level for the current registry generation
+	name  string                            // name of the logger
+	ops   []func(slog.Handler) slog.Handler // immutable WithAttrs and WithGroup calls to apply to the handler
+}
+
+// namedHandlerCache holds the handler and level resolved for a named logger.
+type namedHandlerCache struct {
+	gen     uint64       // registry generation from which the handler and level were resolved
+	handler slog.Handler // resolved handler with all of the logger's attributes and groups applied
+	level   slog.Leveler // resolved level override, if any
+}
+
+// GetLogger returns the logger with the given dot-separated name (eg: "pkg.sub").
+//
+// Named loggers form a hierarchy based on their names, where "pkg" is the parent of "pkg.sub" and the empty name is
+// the root of the hierarchy. Each logger passes records to the handler attached to its own name or to its nearest
+// ancestor's name using [SetLoggerHandler], falling back on the handler of [slog.Default] if no handler is attached
+// to any of them. Likewise, each logger only logs records at or above the level override set for its own name or its
+// nearest ancestor's name using [SetLoggerLevel], in addition to any level set on the handler itself.
+//
+// Handlers and levels can be changed at any time and take effect immediately for all existing loggers. The same
+// logger is returned each time the function is called with the same name.
+func GetLogger(name string) *slog.Logger {
+	name = strings.TrimSpace(name)
+	_loggers.mu.RLock()
+	logger, ok := _loggers.loggers[name]
+	_loggers.mu.RUnlock()
+	if ok {
+		return logger
+	}
+
+	_loggers.mu.Lock()
+	defer _loggers.mu.Unlock()
+	if logger, ok := _loggers.loggers[name]; ok {
+		return logger
+	}
+	logger = slog.New(&namedHandler{
+		name: name,
+	})
+	_loggers.loggers[name] = logger
+	return logger
+}
+
+// LoggerNameFromContext returns the name of the named logger which is handling a record, stored in the context passed
+// to the Handle function of each handler by the loggers returned by [GetLogger], and whether or not there is one.
+func LoggerNameFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	name, ok := ctx.Value(loggerNameCtxKey{}).(string)
+	return name, ok
+}
+
+// SetLoggerHandler attaches the given handler to the given logger name, so that it is used by the logger with that
+// name and all of its descendants which don't have a handler of their own.
+//
+// Use the empty name to set the handler for the root of the hierarchy. Pass a nil handler to detach the current
+// handler from the name. Since the root falls back on the handler of [slog.Default], the root handler must be set
+// before the default logger is replaced with a named logger.
+func SetLoggerHandler(name string, h slog.Handler) {
+	name = strings.TrimSpace(name)
+	_loggers.mu.Lock()
+	defer _loggers.mu.Unlock()
+	if h == nil {
+		delete(_loggers.handlers, name)
+	} else {
+		_loggers.handlers[name] = h
+	}
+	_loggers.gen.Add(1)
+}
+
+// SetLoggerLevel sets the level override for the given logger name, which applies to the logger with that name and
+// all of its descendants which don't have a level override of their own.
+//
+// The level is read each time a record is logged, so a [slog.LevelVar] may be used to change it later. Pass a nil
+// level to remove the current override from the name.
+func SetLoggerLevel(name string, level slog.Leveler) {
+	name = strings.TrimSpace(name)
+	_loggers.mu.Lock()
+	defer _loggers.mu.Unlock()
+	if level == nil {
+		delete(_loggers.levels, name)
+	} else {
+		_loggers.levels[name] = level
+	}
+	_loggers.gen.Add(1)
+}
+
+// SetLoggerLevels replaces all of the level overrides with the given levels, keyed by logger name.
+//
+// Each level is a level name optionally followed by an offset (eg: "debug", "warn" or "error+2"). This is typically
+// used to apply the "loggers" map read from a configuration file. If any level is invalid, none of the overrides are
+// changed.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: one or more levels are invalid
+func SetLoggerLevels(levels map[string]string) xerrors.Error {
+	parsed := make(map[string]slog.Leveler, len(levels))
+	for name, text := range levels {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(text)); err != nil {
+			return xerrors.Wrapf(OptionsValidationError, err, "invalid level '%s' for logger '%s': %s", text, name,
+				err.Error()).WithAttr("logger", name)
+		}
+		parsed[strings.TrimSpace(name)] = level
+	}
+
+	_loggers.mu.Lock()
+	defer _loggers.mu.Unlock()
+	_loggers.levels = parsed
+	_loggers.gen.Add(1)
+	return nil
+}
+
+// resolve returns the handler and level override for the given logger name, either of which may be nil if neither
+// the name nor any of its ancestors have one.
+func (r *loggerRegistry) resolve(name string) (slog.Handler, slog.Leveler) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var handler slog.Handler
+	var level slog.Leveler
+	for {
+		if handler == nil {
+			handler = r.handlers[name]
+		}
+		if level == nil {
+			level = r.levels[name]
+		}
+		if name == "" || (handler != nil && level != nil) {
+			return handler, level
+		}
+		name = parentLoggerName(name)
+	}
+}
+
+// Enabled returns whether or not the level is at or above the logger's level override, if any, and the resolved
+// handler is enabled for it.
+func (h *namedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	handler, minLevel := h.current()
+	if minLevel != nil && level < minLevel.Level() {
+		return false
+	}
+	return handler.Enabled(ctx, level)
+}
+
+// Handle passes the record to the resolved handler along with a context holding the name of the logger.
+func (h *namedHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	handler, _ := h.current()
+	return handler.Handle(context.WithValue(ctx, loggerNameCtxKey{}, h.name), r)
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *namedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	attrs = slices.Clone(attrs)
+	return &namedHandler{
+		name: h.name,
+		ops: append(slices.Clip(h.ops), func(handler slog.Handler) slog.Handler {
+			return handler.WithAttrs(attrs)
+		}),
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *namedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &namedHandler{
+		name: h.name,
+		ops: append(slices.Clip(h.ops), func(handler slog.Handler) slog.Handler {
+			return handler.WithGroup(name)
+		}),
+	}
+}
+
+// current returns the resolved handler, with all of the handler's attributes and groups applied, and level override
+// for the logger.
+func (h *namedHandler) current() (slog.Handler, slog.Leveler) {
+	gen := _loggers.gen.Load()
+	if cache := h.cache.Load(); cache != nil && cache.gen == gen {
+		return cache.handler, cache.level
+	}
+
+	handler, level := _loggers.resolve(h.name)
+	cacheable := handler != nil
+	if handler == nil {
+		// the default logger may be replaced at any time, so the fallback is never cached
+		handler = slog.Default().Handler()
+	}
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	if cacheable {
+		h.cache.Store(&namedHandlerCache{
+			gen:     gen,
+			handler: handler,
+			level:   level,
+		})
+	}
+	return handler, level
+}
+
+// parentLoggerName returns the name of the parent of the logger with the given name.
+func parentLoggerName(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
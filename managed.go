@@ -0,0 +1,226 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+// ManagedLoggerStats holds the counters for a [ManagedLogger].
+type ManagedLoggerStats struct {
+	// Errors is the number of records for which the handler tree returned an error.
+	Errors uint64
+
+	// Handled is the number of records that have been passed to the handler tree.
+	Handled uint64
+
+	// Reconfigures is the number of times the handler tree has been replaced using [ManagedLogger.Reconfigure].
+	Reconfigures uint64
+}
+
+// ManagedLogger is an [slog.Logger] which owns the tree of handlers it logs to, so that applications can flush,
+// close, adjust and replace the tree through a single object instead of keeping track of the individual handlers.
+//
+// All methods are safe to call concurrently.
+type ManagedLogger struct {
+	*slog.Logger
+
+	// unexported variables
+	callback     BuildHandlerCallbackFn // callback used when building handler trees
+	errors       atomic.Uint64          // number of records for which the tree returned an error
+	handled      atomic.Uint64          // number of records passed to the tree
+	mu           sync.Mutex             // serializes changes to the tree
+	reconfigures atomic.Uint64          // number of times the tree has been replaced
+	target       *SwappableHandler      // current handler tree
+}
+
+// managedHandler is the [slog.Handler] used by a [ManagedLogger] to count the records passed to its handler tree.
+type managedHandler struct {
+	// unexported variables
+	handler slog.Handler   // underlying handler
+	logger  *ManagedLogger // logger holding the counters
+}
+
+// ensure [managedHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &managedHandler{}
+
+// NewManagedLogger builds a handler tree using the given builder and returns a new [ManagedLogger] which owns it.
+//
+// The callback, which may be nil, is passed to the builder each time the tree is built, including whenever it is
+// replaced using [ManagedLogger.Reconfigure].
+//
+// This function may return an error with any of the following codes:
+//   - [InvalidParameter]: the builder is nil
+//
+// This function may return any other error returned by the builder.
+func NewManagedLogger(b HandlerBuilder, cb BuildHandlerCallbackFn) (*ManagedLogger, xerrors.Error) {
+	if b == nil {
+		return nil, xerrors.New(InvalidParameter, "handler builder cannot be nil")
+	}
+	h, xerr := b.Build(cb)
+	if xerr != nil {
+		return nil, xerr
+	}
+	m := &ManagedLogger{
+		callback: cb,
+		target:   NewSwappableHandler(h),
+	}
+	m.Logger = slog.New(&managedHandler{
+		handler: m.target,
+		logger:  m,
+	})
+	return m, nil
+}
+
+// Close closes the handler tree if it implements [io.Closer].
+//
+// Records which are still being handled by the tree are given up to [DefaultSwapDrainTimeout] to finish before it is
+// closed. Records logged after the logger is closed are discarded until the tree is replaced using
+// [ManagedLogger.Reconfigure].
+func (m *ManagedLogger) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if closer, ok := m.replace(nil).(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Flush flushes any buffered records held by the handlers in the tree which have a Flush function.
+func (m *ManagedLogger) Flush() error {
+	return FlushHandler(m.target.Handler())
+}
+
+// Reconfigure builds a new handler tree using the given builder and replaces the current tree with it, closing the
+// current tree once it has been replaced and the records still being handled by it have finished or
+// [DefaultSwapDrainTimeout] has passed.
+//
+// If the new tree cannot be built, the current tree is kept. Levels changed using [ManagedLogger.SetLevel] are not
+// carried over to the new tree.
+//
+// This function may return an error with any of the following codes:
+//   - [InvalidParameter]: the builder is nil
+//
+// This function may return any other error returned by the builder.
+func (m *ManagedLogger) Reconfigure(b HandlerBuilder) xerrors.Error {
+	if b == nil {
+		return xerrors.New(InvalidParameter, "handler builder cannot be nil")
+	}
+	h, xerr := b.Build(m.callback)
+	if xerr != nil {
+		return xerr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := m.replace(h)
+	m.reconfigures.Add(1)
+	LogInternal(context.Background(), slog.LevelInfo, InternalEventConfigReload, "replaced managed handler tree")
+	if closer, ok := old.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			LogInternal(context.Background(), slog.LevelWarn, InternalEventConfigReload,
+				"failed to close replaced handler tree", slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// SetLevel sets the minimum logging level of every handler in the tree which implements [LevelVarHandler].
+func (m *ManagedLogger) SetLevel(level slog.Level) {
+	setHandlerTreeLevel(m.target.Handler(), level)
+}
+
+// Stats returns a snapshot of the logger's counters.
+func (m *ManagedLogger) Stats() ManagedLoggerStats {
+	return ManagedLoggerStats{
+		Errors:       m.errors.Load(),
+		Handled:      m.handled.Load(),
+		Reconfigures: m.reconfigures.Load(),
+	}
+}
+
+// replace replaces the current handler tree with the given tree and returns the current tree once the records still
+// being handled by it have finished or [DefaultSwapDrainTimeout] has passed. The mutex must be held when calling this
+// function.
+func (m *ManagedLogger) replace(h slog.Handler) slog.Handler {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSwapDrainTimeout)
+	defer cancel()
+	old, xerr := m.target.SwapAndDrain(ctx, h)
+	if xerr != nil {
+		LogInternal(context.Background(), slog.LevelWarn, InternalEventConfigReload,
+			"closing replaced handler tree before all of its records were handled", slog.String("error", xerr.Error()))
+	}
+	return old
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *managedHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *managedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle passes the record to the underlying handler and counts the result.
+func (h *managedHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.logger.handled.Add(1)
+	err := h.handler.Handle(ctx, r)
+	if err != nil {
+		h.logger.errors.Add(1)
+	}
+	return err
+}
+
+// Options always returns nil since the handler has no options.
+func (h *managedHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *managedHandler) Type() string {
+	return "managed"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *managedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &managedHandler{
+		handler: h.handler.WithAttrs(attrs),
+		logger:  h.logger,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *managedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &managedHandler{
+		handler: h.handler.WithGroup(name),
+		logger:  h.logger,
+	}
+}
+
+// setHandlerTreeLevel sets the minimum level of the given handler and all of its children which implement
+// [LevelVarHandler].
+func setHandlerTreeLevel(h slog.Handler, level slog.Level) {
+	if lh, ok := h.(LevelVarHandler); ok {
+		if levelVar := lh.GetLevelVar(); levelVar != nil {
+			levelVar.Set(level)
+		}
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			setHandlerTreeLevel(child, level)
+		}
+	}
+}
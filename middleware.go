@@ -0,0 +1,36 @@
+package xlog
+
+import (
+	"log/slog"
+)
+
+// Middleware wraps a handler in a new handler (eg: one that samples, redacts, enriches or retries records) and returns
+// the new handler.
+//
+// Most of the handlers in this package which wrap another handler can be turned into middleware with a closure (eg:
+// func(h slog.Handler) slog.Handler { return NewDedupHandler(h) }). Middleware published by third parties can be
+// made available to configuration files using the RegisterMiddleware function of the handlers package.
+type Middleware func(slog.Handler) slog.Handler
+
+// Chain wraps the given handler in each of the given middleware and returns the outermost handler.
+//
+// The first middleware is the outermost, so records pass through the middleware in the order in which they are given
+// before reaching the handler. Nil middleware, and middleware which return a nil handler, are skipped.
+func Chain(h slog.Handler, mw ...Middleware) slog.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		if mw[i] == nil {
+			continue
+		}
+		if wrapped := mw[i](h); wrapped != nil {
+			h = wrapped
+		}
+	}
+	return h
+}
+
+// Compose combines the given middleware into a single middleware which applies them in the same order as [Chain].
+func Compose(mw ...Middleware) Middleware {
+	return func(h slog.Handler) slog.Handler {
+		return Chain(h, mw...)
+	}
+}
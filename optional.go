@@ -0,0 +1,83 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Optional holds an option value which may or may not have been explicitly set, so that handlers can tell the
+// difference between an option which was left unset, and should be replaced by its default, and an option which was
+// set to its zero value (eg: a timeout of 0 which disables the timeout).
+//
+// The zero value is unset. When unmarshalling from JSON, a missing key or a null value leaves the option unset and
+// any other value sets it. When marshalling to JSON, an unset option is encoded as null and is omitted entirely from
+// fields tagged with "omitzero".
+//
+// Handlers outside of this package can use the type for their own options in the same way.
+type Optional[T any] struct {
+	// unexported variables
+	set   bool // whether or not the value has been set
+	value T    // the value, if set
+}
+
+// Some returns an [Optional] which is set to the given value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{
+		set:   true,
+		value: value,
+	}
+}
+
+// Get returns the value and whether or not it has been set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set
+}
+
+// IsSet returns whether or not the value has been explicitly set.
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// IsZero returns whether or not the value is unset.
+//
+// This allows fields holding an [Optional] to be omitted when marshalling to JSON using the "omitzero" tag option.
+func (o Optional[T]) IsZero() bool {
+	return !o.set
+}
+
+// MarshalJSON encodes the value as JSON or as null if it is unset.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+// Or returns the value if it has been set or the given default value otherwise.
+func (o Optional[T]) Or(def T) T {
+	if o.set {
+		return o.value
+	}
+	return def
+}
+
+// UnmarshalJSON decodes the JSON-encoded data into the current object.
+//
+// A null value leaves the object unset.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*o = Optional[T]{}
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*o = Some(value)
+	return nil
+}
+
+// Value returns the value or the zero value of its type if it is unset.
+func (o Optional[T]) Value() T {
+	return o.value
+}
@@ -0,0 +1,147 @@
+package xlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+// CommonHandlerOptions holds the options which are shared by most handlers.
+//
+// Handlers, including handlers outside of this package, can embed the struct in their own options so that the fields
+// are decoded, validated and documented the same way everywhere. Since the struct defines its own UnmarshalJSON
+// function, options which embed it must also define an UnmarshalJSON function which decodes their remaining fields and
+// calls [CommonHandlerOptions.UnmarshalJSON] with the same data. Otherwise only the common fields are decoded.
+type CommonHandlerOptions struct {
+	// ErrorHandler is a function that's called to process any internal errors that may occur when a message is
+	// processed by the underlying handler.
+	//
+	// The default behavior is to ignore these errors.
+	//
+	// When reading configuration settings from a file or raw JSON, create a [HandlerBuilder] and pass the
+	// [HandlerBuilder.Build] function a [BuildHandlerCallbackFn] callback to modify the options and set this value
+	// from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BuildHandlerCallbackFn
+	ErrorHandler ErrorHandlerFn `json:"-"`
+
+	// IncludeCaller indicates whether or not to include the caller in log messages.
+	//
+	// The default behavior is to not include caller information.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to false.
+	IncludeCaller bool `json:"include_caller"`
+
+	// Level is the minimum level at which to log messages.
+	//
+	// The default behavior is defined by the default level setting of the handler.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	Level *slog.LevelVar `json:"level"`
+
+	// MaxLevel is the maximum level at which to log messages.
+	//
+	// The default behavior is to disable any maximum log message level.
+	//
+	// When reading configuration settings from a file or raw JSON, if this value is not present, it will be set
+	// to nil.
+	MaxLevel *slog.LevelVar `json:"max_level,omitempty"`
+
+	// ReplaceAttr is called to rewrite each non-group attribute before it is logged.
+	//
+	// The attribute's value has been resolved (see [slog.Value.Resolve]). If ReplaceAttr returns a zero Attr, the
+	// attribute is discarded.
+	//
+	// Unless stated otherwise by the handler, the built-in attributes with keys [slog.TimeKey], [slog.LevelKey],
+	// [slog.SourceKey], and [slog.MessageKey] are passed to this function, except that time is omitted if zero, and
+	// source is omitted if IncludeCaller is false.
+	//
+	// The default behavior is to not replace any attributes.
+	//
+	// When reading configuration settings from a file or raw JSON, create a [HandlerBuilder] and pass the
+	// [HandlerBuilder.Build] function a [BuildHandlerCallbackFn] callback to modify the options and set this value
+	// from your application, if desired.
+	//
+	// References:
+	//   https://pkg.go.dev/log/slog#TimeKey
+	//   https://pkg.go.dev/log/slog#LevelKey
+	//   https://pkg.go.dev/log/slog#SourceKey
+	//   https://pkg.go.dev/log/slog#MessageKey
+	//   https://pkg.go.dev/log/slog#HandlerOptions
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#HandlerBuilder.Build
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#BuildHandlerCallbackFn
+	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr `json:"-"`
+}
+
+// jsonCommonHandlerOptions is an alternate form of [CommonHandlerOptions] that is used during unmarshalling to
+// prevent infinite recursion.
+type jsonCommonHandlerOptions struct {
+	IncludeCaller bool   `json:"include_caller"`
+	Level         string `json:"level"`
+	MaxLevel      string `json:"max_level"`
+}
+
+// Enabled returns whether or not a record with the given level falls between the minimum and maximum levels.
+//
+// A nil minimum level is treated as [slog.LevelInfo] and a nil maximum level disables the maximum.
+func (o CommonHandlerOptions) Enabled(level slog.Level) bool {
+	if level < o.Level.Level() {
+		return false
+	}
+	return o.MaxLevel == nil || level <= o.MaxLevel.Level()
+}
+
+// UnmarshalJSON decodes the common fields of the JSON-encoded data into the current object, ignoring any other
+// fields.
+func (o *CommonHandlerOptions) UnmarshalJSON(data []byte) error {
+	var opts jsonCommonHandlerOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return err
+	}
+
+	// validate the log level(s)
+	//
+	// note that we purposely leave the level nil here if it's not set so that it can be set when the handler
+	// is created or overridden by the calling application
+	if opts.Level != "" {
+		var level slog.LevelVar
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return fmt.Errorf("failed to parse level '%s': %s", opts.Level, err.Error())
+		}
+		o.Level = &level
+	}
+	if opts.MaxLevel != "" {
+		var level slog.LevelVar
+		if err := level.UnmarshalText([]byte(opts.MaxLevel)); err != nil {
+			return fmt.Errorf("failed to parse max level '%s': %s", opts.MaxLevel, err.Error())
+		}
+		o.MaxLevel = &level
+	}
+
+	// copy remaining options
+	o.IncludeCaller = opts.IncludeCaller
+
+	return nil
+}
+
+// Validate checks the common options for problems.
+//
+// Options which embed the struct and define their own Validate function should check the common fields as well.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: one or more options are invalid
+func (o CommonHandlerOptions) Validate() xerrors.Error {
+	if o.Level != nil && o.MaxLevel != nil && o.MaxLevel.Level() < o.Level.Level() {
+		return xerrors.Newf(OptionsValidationError, "max_level: maximum level '%s' is lower than minimum level '%s'",
+			o.MaxLevel.Level(), o.Level.Level()).WithAttr("field", "max_level")
+	}
+	return nil
+}
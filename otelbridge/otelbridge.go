@@ -0,0 +1,249 @@
+// Package otelbridge derives basic RED (rate, errors, duration) telemetry from log records so that applications get
+// OpenTelemetry span events and metrics from their existing log calls.
+//
+// The bridge does not depend on the OpenTelemetry SDK. Instead, [Options] holds functions which the application
+// implements using the OpenTelemetry API it already has configured. For example:
+//
+//	errors, _ := meter.Int64Counter("log.errors")
+//	latency, _ := meter.Float64Histogram("log.duration", metric.WithUnit("ms"))
+//	h := otelbridge.NewHandler(next, otelbridge.Options{
+//		AddSpanEvent: func(ctx context.Context, name string, attrs []slog.Attr) {
+//			trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(toOTel(attrs)...))
+//		},
+//		CountError: func(ctx context.Context, attrs []slog.Attr) {
+//			errors.Add(ctx, 1, metric.WithAttributes(toOTel(attrs)...))
+//		},
+//		RecordDuration: func(ctx context.Context, d time.Duration, attrs []slog.Attr) {
+//			latency.Record(ctx, float64(d)/float64(time.Millisecond), metric.WithAttributes(toOTel(attrs)...))
+//		},
+//	})
+package otelbridge
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	// DefaultDurationKeys is the default list of attribute keys which hold the duration or latency of an operation.
+	//
+	// This value is used when the duration keys in [Options] are empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/otelbridge#Options
+	DefaultDurationKeys = []string{"duration", "elapsed", "latency"}
+
+	// DefaultNumericDurationUnit is the default unit of duration attributes whose values are numbers rather than
+	// durations.
+	//
+	// This value is used when the numeric duration unit in [Options] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/otelbridge#Options
+	DefaultNumericDurationUnit = time.Millisecond
+)
+
+// Options holds the options for the handler returned by [NewHandler].
+type Options struct {
+	// AddSpanEvent is called to add an event to the span in the given context for each record at or above the
+	// ErrorLevel or containing a duration.
+	//
+	// The event is named after the record's message and the attributes hold the record's level, the duration (under
+	// the "duration" key), if any, and all of the record's attributes.
+	//
+	// The default behavior is to not add span events.
+	AddSpanEvent func(ctx context.Context, name string, attrs []slog.Attr)
+
+	// CountError is called once for each record at or above the ErrorLevel, typically to increment a counter.
+	//
+	// The attributes hold the record's level and the values of the MetricKeys found in the record.
+	//
+	// The default behavior is to not count errors.
+	CountError func(ctx context.Context, attrs []slog.Attr)
+
+	// DurationKeys are the keys of the attributes which hold the duration or latency of an operation.
+	//
+	// Attribute values may be durations, strings accepted by [time.ParseDuration] or numbers in the
+	// NumericDurationUnit. Only the first matching attribute in each record is used.
+	//
+	// The default behavior is defined by the default duration keys setting defined in the package.
+	DurationKeys []string
+
+	// ErrorLevel is the minimum level of records which are treated as errors.
+	//
+	// The default behavior is to treat records at [slog.LevelError] or above as errors.
+	ErrorLevel slog.Leveler
+
+	// MetricKeys are the keys of the attributes which are passed to CountError and RecordDuration as metric
+	// dimensions.
+	//
+	// Only attributes with a small number of distinct values (eg: "route" or "status") should be used to avoid
+	// creating too many time series.
+	//
+	// The default behavior is to pass only the record's level.
+	MetricKeys []string
+
+	// NumericDurationUnit is the unit of duration attributes whose values are numbers.
+	//
+	// The default behavior is defined by the default numeric duration unit setting defined in the package.
+	NumericDurationUnit time.Duration
+
+	// RecordDuration is called once for each record containing a duration, typically to record it in a histogram.
+	//
+	// The attributes hold the record's level and the values of the MetricKeys found in the record.
+	//
+	// The default behavior is to not record durations.
+	RecordDuration func(ctx context.Context, d time.Duration, attrs []slog.Attr)
+}
+
+// handler is the [slog.Handler] returned by [NewHandler].
+type handler struct {
+	// unexported variables
+	attrs   []slog.Attr  // attributes added to the handler using WithAttrs
+	next    slog.Handler // underlying handler
+	options *Options     // immutable options shared by all derived handlers
+}
+
+// NewHandler returns a new [slog.Handler] which passes records to the given handler and derives span events and
+// metrics from them using the functions in the given options.
+//
+// Records are inspected whether or not the underlying handler is enabled for them, so errors and durations are
+// counted even if they are not logged. Attributes added using WithAttrs are included when looking for durations and
+// metric dimensions, but groups are ignored.
+func NewHandler(next slog.Handler, options Options) slog.Handler {
+	if len(options.DurationKeys) == 0 {
+		options.DurationKeys = DefaultDurationKeys
+	}
+	if options.ErrorLevel == nil {
+		options.ErrorLevel = slog.LevelError
+	}
+	if options.NumericDurationUnit == 0 {
+		options.NumericDurationUnit = DefaultNumericDurationUnit
+	}
+	return &handler{
+		next:    next,
+		options: &options,
+	}
+}
+
+// Enabled returns true if the underlying handler is enabled for the given level or if the bridge has anything to do
+// for records at the level.
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next.Enabled(ctx, level) {
+		return true
+	}
+	o := h.options
+	return o.AddSpanEvent != nil || o.RecordDuration != nil ||
+		(o.CountError != nil && level >= o.ErrorLevel.Level())
+}
+
+// Handle derives span events and metrics from the record and passes it to the underlying handler, if it is enabled.
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	o := h.options
+	attrs := slices.Clone(h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	isError := r.Level >= o.ErrorLevel.Level()
+	d, durationKey, hasDuration := h.duration(attrs)
+
+	if isError || hasDuration {
+		dims := h.dimensions(r.Level, attrs)
+		if isError && o.CountError != nil {
+			o.CountError(ctx, dims)
+		}
+		if hasDuration && o.RecordDuration != nil {
+			o.RecordDuration(ctx, d, dims)
+		}
+		if o.AddSpanEvent != nil {
+			eventAttrs := make([]slog.Attr, 0, len(attrs)+2)
+			eventAttrs = append(eventAttrs, slog.String(slog.LevelKey, r.Level.String()))
+			if hasDuration && durationKey != "duration" {
+				eventAttrs = append(eventAttrs, slog.Duration("duration", d))
+			}
+			o.AddSpanEvent(ctx, r.Message, append(eventAttrs, attrs...))
+		}
+	}
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &handler{
+		attrs:   append(slices.Clip(h.attrs), attrs...),
+		next:    h.next.WithAttrs(attrs),
+		options: h.options,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{
+		attrs:   h.attrs,
+		next:    h.next.WithGroup(name),
+		options: h.options,
+	}
+}
+
+// dimensions returns the metric dimensions for a record at the given level with the given attributes.
+func (h *handler) dimensions(level slog.Level, attrs []slog.Attr) []slog.Attr {
+	dims := []slog.Attr{slog.String(slog.LevelKey, level.String())}
+	for _, key := range h.options.MetricKeys {
+		for _, attr := range attrs {
+			if attr.Key == key {
+				dims = append(dims, slog.Attr{Key: key, Value: attr.Value.Resolve()})
+				break
+			}
+		}
+	}
+	return dims
+}
+
+// duration returns the value and key of the first duration attribute found in the given attributes.
+func (h *handler) duration(attrs []slog.Attr) (time.Duration, string, bool) {
+	for _, key := range h.options.DurationKeys {
+		for _, attr := range attrs {
+			if attr.Key != key {
+				continue
+			}
+			v := attr.Value.Resolve()
+			switch v.Kind() {
+			case slog.KindDuration:
+				return v.Duration(), key, true
+			case slog.KindInt64:
+				return time.Duration(v.Int64()) * h.options.NumericDurationUnit, key, true
+			case slog.KindUint64:
+				return time.Duration(v.Uint64()) * h.options.NumericDurationUnit, key, true
+			case slog.KindFloat64:
+				return time.Duration(v.Float64() * float64(h.options.NumericDurationUnit)), key, true
+			case slog.KindString:
+				if d, err := time.ParseDuration(v.String()); err == nil {
+					return d, key, true
+				}
+				if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+					return time.Duration(f * float64(h.options.NumericDurationUnit)), key, true
+				}
+			}
+		}
+	}
+	return 0, "", false
+}
@@ -0,0 +1,118 @@
+package xlog
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// AllHandlerTypes is the handler type which matches every handler in the overrides passed to [OverridesFromMap]
+	// and [OverridesFromFlags].
+	//
+	// Overrides for all handler types are applied before the overrides for a specific handler type.
+	AllHandlerTypes = "*"
+)
+
+// OverridesFromFlags returns a [BuildHandlerCallbackFn] which applies the given option overrides, whose keys hold a
+// handler type and the path of an option separated by the first dot (eg: "console.include_caller" or
+// "*.include_caller") and whose values hold the new values as strings (eg: "true"), as commonly returned by feature
+// flag systems.
+//
+// Each value is converted to the type of its option by decoding it as JSON or, if that fails, as a JSON string, so
+// booleans, numbers, durations, sizes and levels can all be given as plain strings. Keys without a dot are ignored.
+//
+// The callback may return an error with any of the following codes:
+//   - [HandlerOptionValueIncompatible]: a value could not be converted to the type of its option
+//
+// In addition, the callback may return any error returned by [OverrideHandlerOptionValue].
+func OverridesFromFlags(flags map[string]string) BuildHandlerCallbackFn {
+	overrides := map[string]map[string]string{}
+	for key, value := range flags {
+		handlerType, name, ok := strings.Cut(key, ".")
+		if !ok || name == "" {
+			continue
+		}
+		handlerType = strings.TrimSpace(strings.ToLower(handlerType))
+		if overrides[handlerType] == nil {
+			overrides[handlerType] = map[string]string{}
+		}
+		overrides[handlerType][name] = value
+	}
+
+	return func(handlerType string, options any) xerrors.Error {
+		for _, t := range []string{AllHandlerTypes, strings.ToLower(handlerType)} {
+			for _, name := range slices.Sorted(maps.Keys(overrides[t])) {
+				value, xerr := decodeOptionValue(options, name, overrides[t][name])
+				if xerr == nil {
+					xerr = OverrideHandlerOptionValue(options, name, value)
+				}
+				if xerr != nil {
+					return xerr.WithAttr("type", handlerType)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// OverridesFromMap returns a [BuildHandlerCallbackFn] which applies the given option overrides, keyed by handler type
+// and then by the path of the option (as accepted by [OverrideHandlerOptionValue]), to the options of each handler
+// before it is built.
+//
+// Overrides for [AllHandlerTypes] apply to every handler which has the option and are applied first, so overrides for
+// a specific handler type take precedence. Options within each handler type are applied in sorted order. Values must
+// be assignable to the type of their option.
+//
+// The callback may return any error returned by [OverrideHandlerOptionValue].
+func OverridesFromMap(overrides map[string]map[string]any) BuildHandlerCallbackFn {
+	normalized := make(map[string]map[string]any, len(overrides))
+	for handlerType, options := range overrides {
+		handlerType = strings.TrimSpace(strings.ToLower(handlerType))
+		normalized[handlerType] = maps.Clone(options)
+	}
+
+	return func(handlerType string, options any) xerrors.Error {
+		for _, t := range []string{AllHandlerTypes, strings.ToLower(handlerType)} {
+			for _, name := range slices.Sorted(maps.Keys(normalized[t])) {
+				if xerr := OverrideHandlerOptionValue(options, name, normalized[t][name]); xerr != nil {
+					return xerr.WithAttr("type", handlerType)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// decodeOptionValue converts the given string to the type of the option with the given name in the options.
+//
+// This function may return an error with any of the following codes:
+//   - [HandlerOptionValueIncompatible]: the value could not be converted to the type of the option
+//
+// In addition, the function may return any error returned by [GetHandlerOptionValue].
+func decodeOptionValue(options any, name, value string) (any, xerrors.Error) {
+	current, xerr := GetHandlerOptionValue(options, name)
+	if xerr != nil {
+		return nil, xerr
+	}
+	fieldType := reflect.TypeOf(current)
+	if fieldType == nil || fieldType.Kind() == reflect.String {
+		return value, nil
+	}
+
+	decoded := reflect.New(fieldType)
+	err := json.Unmarshal([]byte(value), decoded.Interface())
+	if err != nil {
+		err = json.Unmarshal([]byte(strconv.Quote(value)), decoded.Interface())
+	}
+	if err != nil {
+		return nil, xerrors.Wrapf(HandlerOptionValueIncompatible, err, "%s: value '%s' is not compatible with field",
+			name, value).WithAttr("value", value)
+	}
+	return decoded.Elem().Interface(), nil
+}
@@ -0,0 +1,606 @@
+package xlog
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultPipelineBatchSize is the default maximum number of queued records passed at once to a handler which
+	// implements [BatchHandler] by a [Pipeline].
+	//
+	// This value is used when the batch size in [PipelineOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#PipelineOptions
+	DefaultPipelineBatchSize = 100
+
+	// DefaultPipelineMaxBytes is the default memory budget (in bytes) for all of the records queued in a [Pipeline].
+	//
+	// This value is used when the max bytes in [PipelineOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#PipelineOptions
+	DefaultPipelineMaxBytes int64 = 64 * 1024 * 1024
+
+	// DefaultPipelineMaxRecords is the default maximum number of records queued in a [Pipeline].
+	//
+	// This value is used when the max records in [PipelineOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#PipelineOptions
+	DefaultPipelineMaxRecords = 100000
+
+	// DefaultPipelineWorkers is the default number of worker goroutines which pass records to handlers in a
+	// [Pipeline].
+	//
+	// This value is used when the workers in [PipelineOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#PipelineOptions
+	DefaultPipelineWorkers = 4
+)
+
+const (
+	// pipelineRecordOverhead is the estimated number of bytes used by a queued record in addition to its message and
+	// attributes.
+	pipelineRecordOverhead = 128
+
+	// pipelineAttrOverhead is the estimated number of bytes used by a single attribute in addition to its key and
+	// any string value.
+	pipelineAttrOverhead = 48
+)
+
+// PipelineOptions holds the options for a [Pipeline].
+type PipelineOptions struct {
+	// BatchSize is the maximum number of queued records passed to a handler at once when the handler implements
+	// [BatchHandler].
+	//
+	// Workers never wait for a batch to fill up: records which are already queued for the same handler in the same
+	// priority lane are taken from the queue together and passed to [BatchHandler.HandleBatch] with the context of
+	// the first record. Other handlers are always passed one record at a time.
+	//
+	// The default behavior is defined by the default batch size setting defined in the package.
+	BatchSize int
+
+	// ErrorHandler is a function that's called to process any errors returned by a handler when a queued record is
+	// handled.
+	//
+	// Errors returned by the function are ignored since there is no caller to return them to. The record is nil if
+	// the error was returned for a whole batch of records.
+	//
+	// The default behavior is to ignore these errors.
+	ErrorHandler ErrorHandlerFn
+
+	// MaxBytes is the memory budget (in bytes) shared by all of the records queued in the pipeline.
+	//
+	// The size of each record is estimated from its message and attributes. Records which would exceed the budget
+	// are dropped.
+	//
+	// The default behavior is defined by the default max bytes setting defined in the package.
+	MaxBytes int64
+
+	// MaxRecordAge is the maximum age of a queued record, measured from its time, at which it is still passed to its
+	// handler.
+	//
+	// Records which are older by the time a worker takes them from the queue (eg: because the handler was blocked on
+	// an unavailable sink) are dropped, counted and reported using [NotifyDropped] rather than delivering stale
+	// records. Records without a time never expire.
+	//
+	// The default behavior is to deliver records no matter how old they are.
+	MaxRecordAge time.Duration
+
+	// MaxRecords is the maximum number of records queued in the pipeline across all handlers.
+	//
+	// Records which would exceed the maximum are dropped.
+	//
+	// The default behavior is defined by the default max records setting defined in the package.
+	MaxRecords int
+
+	// Priority is called for each record to classify it into a priority lane within the queue of its handler (eg:
+	// [LevelPriority]).
+	//
+	// Records in lanes with higher priorities are handled before records in lanes with lower priorities, so that
+	// important records (eg: errors and audit records) are delivered first when a backlog is drained after an outage.
+	//
+	// The default behavior is to queue all records in a single lane.
+	Priority PriorityFn
+
+	// Workers is the number of worker goroutines which pass queued records to their handlers.
+	//
+	// The default behavior is defined by the default workers setting defined in the package.
+	Workers int
+}
+
+// PipelineStats holds the counters for a [Pipeline] or for a single handler driven by a pipeline.
+type PipelineStats struct {
+	// Dropped is the number of records that were dropped because the pipeline was full or closed or because they
+	// expired.
+	Dropped uint64
+
+	// Errors is the number of records for which the handler returned an error, including every record in a batch for
+	// which it returned an error.
+	Errors uint64
+
+	// Expired is the number of records that were dropped because they were older than the maximum record age.
+	Expired uint64
+
+	// Handled is the number of records that have been passed to the handler.
+	Handled uint64
+
+	// PendingBytes is the estimated size (in bytes) of the records currently queued.
+	PendingBytes int64
+
+	// PendingRecords is the number of records currently queued.
+	PendingRecords int
+}
+
+// HandlerStatsReporter defines the interface for an object which keeps counters for each of the handlers it drives,
+// such as a [Pipeline], so that they can be exported to a monitoring system.
+type HandlerStatsReporter interface {
+	// HandlerStats should return the counters for each handler, keyed by the handler's name.
+	HandlerStats() map[string]PipelineStats
+}
+
+// Pipeline drives any number of handlers asynchronously through a single bounded queue and worker pool.
+//
+// Each handler wrapped using [Pipeline.Handler] gets its own queue, and workers take turns between queues so that a
+// busy handler cannot starve the others. Records for a single handler are always handled in the order they were
+// logged, unless they are classified into priority lanes (see [PipelineOptions]), in which case the records in each
+// lane are handled in the order they were logged. When the pipeline's memory budget or record limit is reached, new
+// records are dropped and counted rather than blocking the caller, starting with the handlers using more than their
+// share of the pipeline. Subscribe to drop notifications using [SubscribeDropNotifications] to be told when this
+// happens.
+type Pipeline struct {
+	// unexported variables
+	closed  bool             // whether or not the pipeline has been closed
+	cond    *sync.Cond       // signals workers when records are queued or the pipeline is closed
+	done    chan struct{}    // closed once all workers have exited
+	mu      sync.Mutex       // protects the queues and counters
+	next    int              // index of the next queue to check for records
+	options PipelineOptions  // pipeline options
+	pending PipelineStats    // totals across all queues
+	queues  []*pipelineQueue // queues for each handler
+}
+
+// ensure [Pipeline] implements [HandlerStatsReporter] interface.
+var _ HandlerStatsReporter = &Pipeline{}
+
+// pipelineEntry is a single record queued in a [Pipeline].
+type pipelineEntry struct {
+	ctx      context.Context  // context passed to Handle
+	handler  slog.Handler     // handler which should handle the record
+	priority int              // priority lane of the record
+	record   slog.Record      // cloned record
+	size     int64            // estimated size of the record
+	source   *pipelineHandler // handler which queued the record
+}
+
+// pipelineLane holds the records queued in a single priority lane of a [pipelineQueue].
+type pipelineLane struct {
+	entries  []pipelineEntry // queued records
+	priority int             // priority of the lane
+}
+
+// pipelineQueue holds the records queued for a single handler in a [Pipeline].
+type pipelineQueue struct {
+	busy        bool            // whether or not a worker is currently handling a record from the queue
+	handlerType string          // type of the handler, if it is an ExtendedHandler
+	lanes       []*pipelineLane // lanes of queued records, highest priority first
+	name        string          // name of the handler
+	stats       PipelineStats   // counters for the handler
+}
+
+// NewPipeline creates a new [Pipeline] object with the given options and starts its workers.
+//
+// The pipeline should be closed using [Pipeline.Close] once it is no longer needed so that any queued records are
+// handled.
+func NewPipeline(options PipelineOptions) *Pipeline {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultPipelineBatchSize
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultPipelineMaxBytes
+	}
+	if options.MaxRecords <= 0 {
+		options.MaxRecords = DefaultPipelineMaxRecords
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultPipelineWorkers
+	}
+
+	p := &Pipeline{
+		done:    make(chan struct{}),
+		options: options,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	var wg sync.WaitGroup
+	for range options.Workers {
+		wg.Go(p.work)
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+	return p
+}
+
+// Close stops the pipeline from accepting new records and waits for all queued records to be handled or for the
+// context to be canceled, whichever comes first.
+//
+// Records logged through the pipeline's handlers after it is closed are dropped, counted and reported using
+// [NotifyDropped] with the reason [DropReasonClosed], so that they are never handled out of order or concurrently with
+// the queued records.
+//
+// This function may return an error with any of the following codes:
+//   - [HandleRecordError]: the context was canceled before all queued records were handled
+func (p *Pipeline) Close(ctx context.Context) xerrors.Error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		stats := p.Stats()
+		return xerrors.Wrapf(HandleRecordError, ctx.Err(), "pipeline closed with %d record(s) still queued: %s",
+			stats.PendingRecords, ctx.Err().Error()).WithAttr("pending_records", stats.PendingRecords)
+	}
+}
+
+// Handler returns a new [slog.Handler] which queues records in the pipeline to be passed to the given handler by
+// one of the pipeline's workers.
+//
+// The name identifies the handler in the pipeline's statistics and in any [DropNotification]. Handlers derived from
+// the returned handler using WithAttrs or WithGroup share its queue.
+func (p *Pipeline) Handler(name string, h slog.Handler) slog.Handler {
+	q := &pipelineQueue{
+		name: name,
+	}
+	if eh, ok := h.(ExtendedHandler); ok {
+		q.handlerType = eh.Type()
+	}
+	p.mu.Lock()
+	p.queues = append(p.queues, q)
+	p.mu.Unlock()
+	return &pipelineHandler{
+		handler:  h,
+		pipeline: p,
+		queue:    q,
+	}
+}
+
+// HandlerStats returns the counters for each handler in the pipeline, keyed by the name given to [Pipeline.Handler].
+//
+// Counters for handlers which were given the same name are added together.
+func (p *Pipeline) HandlerStats() map[string]PipelineStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]PipelineStats, len(p.queues))
+	for _, q := range p.queues {
+		s := stats[q.name]
+		s.Dropped += q.stats.Dropped
+		s.Errors += q.stats.Errors
+		s.Expired += q.stats.Expired
+		s.Handled += q.stats.Handled
+		s.PendingBytes += q.stats.PendingBytes
+		s.PendingRecords += q.stats.PendingRecords
+		stats[q.name] = s
+	}
+	return stats
+}
+
+// Stats returns the counters for the pipeline as a whole.
+func (p *Pipeline) Stats() PipelineStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending
+}
+
+// enqueue adds the record to the given queue.
+//
+// If the pipeline is full or closed, the record is dropped, counted and reported using [NotifyDropped].
+func (p *Pipeline) enqueue(q *pipelineQueue, e pipelineEntry) {
+	p.mu.Lock()
+	reason := DropReasonClosed
+	if !p.closed {
+		reason = p.full(q, e.size)
+	}
+	if reason != "" {
+		p.pending.Dropped++
+		q.stats.Dropped++
+		p.mu.Unlock()
+		NotifyDropped(q.name, q.handlerType, reason, 1)
+		return
+	}
+	defer p.mu.Unlock()
+	q.push(e)
+	q.stats.PendingBytes += e.size
+	q.stats.PendingRecords++
+	p.pending.PendingBytes += e.size
+	p.pending.PendingRecords++
+	p.cond.Signal()
+}
+
+// full returns the reason a record of the given size should be dropped instead of being added to the queue or an
+// empty string if the record should be added.
+//
+// A record is dropped if it would exceed the pipeline's limits. Once the pipeline is half full, a record is also
+// dropped if its queue already holds more than an equal share of the limits so that the remaining space is left for
+// the other handlers. The mutex must be held when calling this function.
+func (p *Pipeline) full(q *pipelineQueue, size int64) string {
+	maxRecords, maxBytes := p.options.MaxRecords, p.options.MaxBytes
+	if p.pending.PendingRecords >= maxRecords {
+		return DropReasonMaxRecords
+	}
+	if p.pending.PendingBytes+size > maxBytes {
+		return DropReasonMaxBytes
+	}
+	if p.pending.PendingRecords < maxRecords/2 && p.pending.PendingBytes+size <= maxBytes/2 {
+		return ""
+	}
+	n := len(p.queues)
+	if q.stats.PendingRecords >= maxRecords/n || q.stats.PendingBytes+size > maxBytes/int64(n) {
+		return DropReasonFairShare
+	}
+	return ""
+}
+
+// handle passes the given records taken from the queue to their handler, as a single batch if there is more than one
+// record, and returns the number of records which were handled, which expired and for which the handler returned an
+// error.
+func (p *Pipeline) handle(q *pipelineQueue, entries []pipelineEntry) (uint64, uint64, uint64) {
+	records := make([]slog.Record, 0, len(entries))
+	for _, e := range entries {
+		if !RecordExpired(e.record.Time, p.options.MaxRecordAge, nil) {
+			records = append(records, e.record)
+		}
+	}
+	expired := uint64(len(entries) - len(records))
+	if expired > 0 {
+		NotifyDropped(q.name, q.handlerType, DropReasonExpired, expired)
+	}
+	if len(records) == 0 {
+		return 0, expired, 0
+	}
+
+	var err error
+	var errRecord *slog.Record
+	ctx := entries[0].ctx
+	if len(entries) == 1 {
+		err = entries[0].handler.Handle(ctx, records[0])
+		errRecord = &records[0]
+	} else {
+		err = HandleBatch(ctx, entries[0].handler, records)
+	}
+	handled := uint64(len(records))
+	if err == nil {
+		return handled, expired, 0
+	}
+	if p.options.ErrorHandler != nil {
+		_ = p.options.ErrorHandler(ctx, err, errRecord)
+	}
+	return handled, expired, handled
+}
+
+// take removes the next records to handle from the queues, waiting until one is available.
+//
+// Queues are checked in turn starting after the last one records were taken from. It returns nil if the pipeline is
+// closed and no records are left. The mutex must be held when calling this function.
+func (p *Pipeline) take() (*pipelineQueue, []pipelineEntry) {
+	for {
+		for i := range p.queues {
+			idx := (p.next + i) % len(p.queues)
+			q := p.queues[idx]
+			if q.busy {
+				continue
+			}
+			entries := q.pop(p.options.BatchSize)
+			if len(entries) == 0 {
+				continue
+			}
+			q.busy = true
+			p.next = idx + 1
+			return q, entries
+		}
+		if p.closed && p.pending.PendingRecords == 0 {
+			return nil, nil
+		}
+		p.cond.Wait()
+	}
+}
+
+// work passes queued records to their handlers until the pipeline is closed and all records have been handled.
+func (p *Pipeline) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		q, entries := p.take()
+		if q == nil {
+			p.cond.Broadcast()
+			return
+		}
+
+		p.mu.Unlock()
+		handled, expired, failed := p.handle(q, entries)
+		p.mu.Lock()
+
+		var size int64
+		for _, e := range entries {
+			size += e.size
+		}
+		q.busy = false
+		q.stats.PendingBytes -= size
+		q.stats.PendingRecords -= len(entries)
+		p.pending.PendingBytes -= size
+		p.pending.PendingRecords -= len(entries)
+		q.stats.Dropped += expired
+		q.stats.Errors += failed
+		q.stats.Expired += expired
+		q.stats.Handled += handled
+		p.pending.Dropped += expired
+		p.pending.Errors += failed
+		p.pending.Expired += expired
+		p.pending.Handled += handled
+
+		// another worker may be waiting for this queue to become available
+		if q.stats.PendingRecords > 0 || (p.closed && p.pending.PendingRecords == 0) {
+			p.cond.Broadcast()
+		}
+	}
+}
+
+// pop removes the oldest record from the highest priority lane holding any records, returning no records if the
+// queue is empty.
+//
+// If the record's handler implements [BatchHandler], the records queued after it in the same lane by the same handler
+// are removed along with it, up to the given number of records in total.
+//
+// The pipeline's mutex must be held when calling this function.
+func (q *pipelineQueue) pop(limit int) []pipelineEntry {
+	for _, lane := range q.lanes {
+		if len(lane.entries) == 0 {
+			continue
+		}
+		n := 1
+		if _, ok := lane.entries[0].handler.(BatchHandler); ok {
+			for n < limit && n < len(lane.entries) && lane.entries[n].source == lane.entries[0].source {
+				n++
+			}
+		}
+		entries := slices.Clone(lane.entries[:n])
+		clear(lane.entries[:n])
+		lane.entries = lane.entries[n:]
+		return entries
+	}
+	return nil
+}
+
+// push adds the record to the end of the lane for its priority, creating the lane if necessary.
+//
+// The pipeline's mutex must be held when calling this function.
+func (q *pipelineQueue) push(e pipelineEntry) {
+	i, found := slices.BinarySearchFunc(q.lanes, e.priority, func(lane *pipelineLane, priority int) int {
+		return cmp.Compare(priority, lane.priority)
+	})
+	if !found {
+		q.lanes = slices.Insert(q.lanes, i, &pipelineLane{priority: e.priority})
+	}
+	q.lanes[i].entries = append(q.lanes[i].entries, e)
+}
+
+// pipelineHandler is the [slog.Handler] returned by [Pipeline.Handler].
+type pipelineHandler struct {
+	// unexported variables
+	handler  slog.Handler   // handler which handles the queued records
+	pipeline *Pipeline      // pipeline the records are queued in
+	queue    *pipelineQueue // queue shared with all derived handlers
+}
+
+// ensure [pipelineHandler] implements [AsyncHandler] interface.
+var _ AsyncHandler = &pipelineHandler{}
+
+// ensure [pipelineHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &pipelineHandler{}
+
+// Async always returns true since records are queued to be handled by one of the pipeline's workers.
+func (h *pipelineHandler) Async() bool {
+	return true
+}
+
+// ChildHandlers returns the handler which handles the queued records.
+func (h *pipelineHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *pipelineHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle queues a copy of the record to be handled by the underlying handler.
+//
+// The context is detached from cancellation since the record may be handled after the caller returns.
+func (h *pipelineHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := pipelineEntry{
+		ctx:     context.WithoutCancel(ctx),
+		handler: h.handler,
+		record:  r.Clone(),
+		size:    estimateRecordSize(r),
+		source:  h,
+	}
+	if priority := h.pipeline.options.Priority; priority != nil {
+		e.priority = priority(r)
+	}
+	h.pipeline.enqueue(h.queue, e)
+	return nil
+}
+
+// Options returns the pipeline's options.
+func (h *pipelineHandler) Options() any {
+	return h.pipeline.options
+}
+
+// Type returns the type of the handler.
+func (h *pipelineHandler) Type() string {
+	return "pipeline"
+}
+
+// WithAttrs returns a new handler which queues records for the underlying handler with the given attributes added.
+func (h *pipelineHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &pipelineHandler{
+		handler:  h.handler.WithAttrs(attrs),
+		pipeline: h.pipeline,
+		queue:    h.queue,
+	}
+}
+
+// WithGroup returns a new handler which queues records for the underlying handler with the given group added.
+func (h *pipelineHandler) WithGroup(name string) slog.Handler {
+	return &pipelineHandler{
+		handler:  h.handler.WithGroup(name),
+		pipeline: h.pipeline,
+		queue:    h.queue,
+	}
+}
+
+// estimateAttrSize returns the estimated number of bytes used by the attribute, including any nested attributes.
+func estimateAttrSize(attr slog.Attr) int64 {
+	size := int64(pipelineAttrOverhead + len(attr.Key))
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		size += int64(len(attr.Value.String()))
+	case slog.KindGroup:
+		for _, child := range attr.Value.Group() {
+			size += estimateAttrSize(child)
+		}
+	}
+	return size
+}
+
+// estimateRecordSize returns the estimated number of bytes used by the record and its attributes.
+func estimateRecordSize(r slog.Record) int64 {
+	size := int64(pipelineRecordOverhead + len(r.Message))
+	r.Attrs(func(attr slog.Attr) bool {
+		size += estimateAttrSize(attr)
+		return true
+	})
+	return size
+}
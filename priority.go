@@ -0,0 +1,42 @@
+package xlog
+
+import (
+	"log/slog"
+	"maps"
+)
+
+// PriorityFn is a function that's called to classify a record into a priority lane when records are queued for later
+// delivery.
+//
+// Records in lanes with higher priorities are delivered before records in lanes with lower priorities. Records in the
+// same lane are delivered in the order they were logged.
+type PriorityFn func(r slog.Record) int
+
+// AttrPriority returns a [PriorityFn] which classifies records using the value of their top-level attribute with the
+// given key (eg: "category") as a key into the given map of priorities.
+//
+// Records without the attribute or whose value is not in the map are classified using the fallback function, or put
+// in lane 0 if it is nil.
+func AttrPriority(key string, priorities map[string]int, fallback PriorityFn) PriorityFn {
+	priorities = maps.Clone(priorities)
+	return func(r slog.Record) int {
+		priority, found := 0, false
+		r.Attrs(func(attr slog.Attr) bool {
+			if attr.Key != key {
+				return true
+			}
+			priority, found = priorities[attr.Value.Resolve().String()]
+			return false
+		})
+		if !found && fallback != nil {
+			return fallback(r)
+		}
+		return priority
+	}
+}
+
+// LevelPriority is a [PriorityFn] which classifies records by their level, so that records with higher levels (eg:
+// [LevelAudit] and [slog.LevelError]) are delivered before records with lower levels (eg: [slog.LevelDebug]).
+func LevelPriority(r slog.Record) int {
+	return int(r.Level)
+}
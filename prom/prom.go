@@ -0,0 +1,478 @@
+// Package prom exposes metrics about the logging pipeline itself (records by level, handler errors, queue depth, flush
+// latency and dropped records) in the Prometheus text exposition format so that they can be scraped by Prometheus and
+// graphed in Grafana.
+//
+// The metrics are written directly in the text format rather than through the Prometheus client library so that
+// applications which do not use Prometheus do not inherit its dependencies. Serve a [Collector] on an HTTP endpoint
+// (eg: /metrics) to make the metrics available to Prometheus.
+package prom
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// ContentType is the content type of the metrics written by a [Collector].
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// DefaultNamespace is the default prefix for the names of the metrics written by a [Collector].
+	//
+	// This value is used when the namespace in [CollectorOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/prom#CollectorOptions
+	DefaultNamespace = "xlog"
+)
+
+// CollectorOptions holds the options for a [Collector].
+type CollectorOptions struct {
+	// Namespace is the prefix for the names of the metrics (eg: "xlog" produces "xlog_records_total").
+	//
+	// The default behavior is defined by the default namespace setting defined in the package.
+	Namespace string
+}
+
+// Collector collects metrics about the logging pipeline and writes them in the Prometheus text exposition format.
+//
+// Metrics are collected from handlers wrapped using [Collector.Handler], from any [xlog.HandlerStatsReporter] (such as
+// an [xlog.Pipeline]) added using [Collector.AddReporter], from any [xlog.CostReporter] (such as an [xlog.CostTracker])
+// added using [Collector.AddCostReporter], from any [xlog.ConcurrencyHandler] added using
+// [Collector.AddConcurrencyHandler] and from drop notifications sent using [xlog.NotifyDropped]. The following metrics
+// are written, prefixed by the namespace:
+//   - records_total: counter of records handled, by handler, handler type and level
+//   - handler_errors_total: counter of records for which the handler returned an error, by handler and handler type
+//   - flush_duration_seconds: summary of the time taken to flush a handler, by handler and handler type
+//   - queue_records: gauge of records waiting to be handled, by reporter and handler
+//   - queue_bytes: gauge of the estimated size of the records waiting to be handled, by reporter and handler
+//   - dropped_records_total: counter of records dropped, by handler, handler type and reason
+//   - sink_bytes_total: counter of bytes sent to sinks which charge by volume, by reporter, sink, group and level
+//   - sink_cost_total: counter of the cost of the bytes sent to sinks, by reporter, sink, group and level
+//   - concurrent_handles: gauge of calls to a handler in progress, by handler
+//   - concurrent_handles_waiting: gauge of calls waiting for calls to a handler in progress to return, by handler
+//   - handle_wait_seconds: histogram of the time calls waited before calling a handler, by handler
+//   - handle_duration_seconds: histogram of the time taken by a handler to handle a record, by handler
+//
+// All methods are safe to call concurrently.
+type Collector struct {
+	// unexported variables
+	concurrency map[string]*xlog.ConcurrencyHandler  // handlers whose concurrency is exported
+	costs       map[string]xlog.CostReporter         // reporters whose sink costs are exported
+	drops       map[dropKey]uint64                   // dropped record counts
+	handlers    map[handlerKey]*handlerMetrics       // metrics for wrapped handlers
+	mu          sync.Mutex                           // protects the maps
+	namespace   string                               // prefix for the metric names
+	reporters   map[string]xlog.HandlerStatsReporter // reporters whose queues are exported
+	unsubscribe func()                               // stops drop notifications
+}
+
+// dropKey identifies the dropped record counts for a single handler and reason.
+type dropKey struct {
+	handler     string // handler name
+	handlerType string // handler type
+	reason      string // reason the records were dropped
+}
+
+// handlerKey identifies the metrics for a single handler.
+type handlerKey struct {
+	name        string // handler name
+	handlerType string // handler type
+}
+
+// handlerMetrics holds the metrics for a single handler wrapped using [Collector.Handler].
+type handlerMetrics struct {
+	errors     atomic.Uint64                 // records for which the handler returned an error
+	flushCount atomic.Uint64                 // number of flushes
+	flushNanos atomic.Int64                  // total time spent flushing
+	mu         sync.Mutex                    // protects records
+	records    map[slog.Level]*atomic.Uint64 // records handled by level
+}
+
+// instrumentedHandler is the [slog.Handler] returned by [Collector.Handler].
+type instrumentedHandler struct {
+	// unexported variables
+	handler slog.Handler    // underlying handler
+	metrics *handlerMetrics // metrics shared by all derived handlers
+}
+
+// metricWriter writes metrics in the text exposition format, remembering the first error.
+type metricWriter struct {
+	err       error         // first error which occurred
+	n         int64         // number of bytes written
+	namespace string        // prefix for the metric names
+	w         *bufio.Writer // underlying writer
+}
+
+// NewCollector creates a new [Collector] with the given options and subscribes it to drop notifications.
+//
+// Call [Collector.Close] once the collector is no longer needed to stop receiving drop notifications.
+func NewCollector(options CollectorOptions) *Collector {
+	c := &Collector{
+		concurrency: map[string]*xlog.ConcurrencyHandler{},
+		costs:       map[string]xlog.CostReporter{},
+		drops:       map[dropKey]uint64{},
+		handlers:    map[handlerKey]*handlerMetrics{},
+		namespace:   options.Namespace,
+		reporters:   map[string]xlog.HandlerStatsReporter{},
+	}
+	if c.namespace == "" {
+		c.namespace = DefaultNamespace
+	}
+	c.unsubscribe = xlog.SubscribeDropNotifications(func(n xlog.DropNotification) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.drops[dropKey{
+			handler:     n.Handler,
+			handlerType: n.HandlerType,
+			reason:      n.Reason,
+		}] += n.Count
+	})
+	return c
+}
+
+// AddConcurrencyHandler adds a handler whose calls in progress and, if it is instrumented, wait and handle duration
+// histograms are exported, labelled with the given name.
+//
+// Any handler previously added with the same name is replaced.
+func (c *Collector) AddConcurrencyHandler(name string, h *xlog.ConcurrencyHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.concurrency[name] = h
+}
+
+// AddCostReporter adds a reporter whose per-sink volumes and costs are exported, labelled with the given name.
+//
+// Any cost reporter previously added with the same name is replaced.
+func (c *Collector) AddCostReporter(name string, r xlog.CostReporter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.costs[name] = r
+}
+
+// AddReporter adds a reporter whose per-handler queue depths are exported, labelled with the given name.
+//
+// Any reporter previously added with the same name is replaced.
+func (c *Collector) AddReporter(name string, r xlog.HandlerStatsReporter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reporters[name] = r
+}
+
+// Close stops the collector from receiving drop notifications.
+//
+// Metrics which have already been collected can still be written.
+func (c *Collector) Close() error {
+	c.unsubscribe()
+	return nil
+}
+
+// Handler returns a new [slog.Handler] which passes records to the given handler while counting them by level,
+// counting errors and timing flushes.
+//
+// The name identifies the handler in the metrics. Handlers wrapped with the same name and type share the same
+// metrics, as do handlers derived from the returned handler using WithAttrs or WithGroup. The returned handler has
+// Flush and Close functions which call those of the underlying handler, if it has them.
+func (c *Collector) Handler(name string, h slog.Handler) slog.Handler {
+	key := handlerKey{
+		name: name,
+	}
+	if eh, ok := h.(xlog.ExtendedHandler); ok {
+		key.handlerType = eh.Type()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.handlers[key]
+	if !ok {
+		m = &handlerMetrics{
+			records: map[slog.Level]*atomic.Uint64{},
+		}
+		c.handlers[key] = m
+	}
+	return &instrumentedHandler{
+		handler: h,
+		metrics: m,
+	}
+}
+
+// ServeHTTP writes the metrics to the response so that they can be scraped by Prometheus.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = c.WriteTo(w)
+}
+
+// WriteTo writes the metrics to the given writer in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	mw := &metricWriter{
+		namespace: c.namespace,
+		w:         bufio.NewWriter(w),
+	}
+
+	c.mu.Lock()
+	handlers := maps.Clone(c.handlers)
+	drops := maps.Clone(c.drops)
+	reporters := maps.Clone(c.reporters)
+	costReporters := maps.Clone(c.costs)
+	concurrency := maps.Clone(c.concurrency)
+	c.mu.Unlock()
+	handlerKeys := slices.SortedFunc(maps.Keys(handlers), func(a, b handlerKey) int {
+		return strings.Compare(a.name+"\x00"+a.handlerType, b.name+"\x00"+b.handlerType)
+	})
+
+	// records by level
+	mw.header("records_total", "counter", "Number of records handled, by handler and level.")
+	for _, key := range handlerKeys {
+		for level, count := range handlers[key].levels() {
+			mw.sample("records_total", count, "handler", key.name, "handler_type", key.handlerType, "level",
+				level.String())
+		}
+	}
+
+	// handler errors
+	mw.header("handler_errors_total", "counter", "Number of records for which the handler returned an error.")
+	for _, key := range handlerKeys {
+		mw.sample("handler_errors_total", handlers[key].errors.Load(), "handler", key.name, "handler_type",
+			key.handlerType)
+	}
+
+	// flush latency
+	mw.header("flush_duration_seconds", "summary", "Time taken to flush the handler.")
+	for _, key := range handlerKeys {
+		m := handlers[key]
+		seconds := time.Duration(m.flushNanos.Load()).Seconds()
+		mw.sample("flush_duration_seconds_sum", seconds, "handler", key.name, "handler_type", key.handlerType)
+		mw.sample("flush_duration_seconds_count", m.flushCount.Load(), "handler", key.name, "handler_type",
+			key.handlerType)
+	}
+
+	// queue depth
+	reporterNames := slices.Sorted(maps.Keys(reporters))
+	reporterStats := make(map[string]map[string]xlog.PipelineStats, len(reporters))
+	for _, name := range reporterNames {
+		reporterStats[name] = reporters[name].HandlerStats()
+	}
+	mw.header("queue_records", "gauge", "Number of records waiting to be handled.")
+	for _, name := range reporterNames {
+		for _, handler := range slices.Sorted(maps.Keys(reporterStats[name])) {
+			mw.sample("queue_records", reporterStats[name][handler].PendingRecords, "reporter", name, "handler",
+				handler)
+		}
+	}
+	mw.header("queue_bytes", "gauge", "Estimated size of the records waiting to be handled.")
+	for _, name := range reporterNames {
+		for _, handler := range slices.Sorted(maps.Keys(reporterStats[name])) {
+			mw.sample("queue_bytes", reporterStats[name][handler].PendingBytes, "reporter", name, "handler",
+				handler)
+		}
+	}
+
+	// dropped records
+	mw.header("dropped_records_total", "counter", "Number of records dropped, by handler and reason.")
+	dropKeys := slices.SortedFunc(maps.Keys(drops), func(a, b dropKey) int {
+		return strings.Compare(a.handler+"\x00"+a.handlerType+"\x00"+a.reason,
+			b.handler+"\x00"+b.handlerType+"\x00"+b.reason)
+	})
+	for _, key := range dropKeys {
+		mw.sample("dropped_records_total", drops[key], "handler", key.handler, "handler_type", key.handlerType,
+			"reason", key.reason)
+	}
+
+	// sink volume and cost
+	costNames := slices.Sorted(maps.Keys(costReporters))
+	costs := make(map[string][]xlog.SinkCost, len(costReporters))
+	for _, name := range costNames {
+		costs[name] = costReporters[name].Costs()
+	}
+	mw.header("sink_bytes_total", "counter", "Number of bytes sent to the sink, by group and level.")
+	for _, name := range costNames {
+		for _, c := range costs[name] {
+			mw.sample("sink_bytes_total", c.Bytes, "reporter", name, "sink", c.Sink, "group", c.Group, "level",
+				c.Level)
+		}
+	}
+	mw.header("sink_cost_total", "counter", "Cost of the bytes sent to the sink, by group and level.")
+	for _, name := range costNames {
+		for _, c := range costs[name] {
+			mw.sample("sink_cost_total", c.Cost, "reporter", name, "sink", c.Sink, "group", c.Group, "level",
+				c.Level)
+		}
+	}
+
+	// handler concurrency
+	concurrencyNames := slices.Sorted(maps.Keys(concurrency))
+	concurrencyStats := make(map[string]xlog.ConcurrencyStats, len(concurrency))
+	for _, name := range concurrencyNames {
+		concurrencyStats[name] = concurrency[name].Stats()
+	}
+	mw.header("concurrent_handles", "gauge", "Number of calls to the handler in progress.")
+	for _, name := range concurrencyNames {
+		mw.sample("concurrent_handles", concurrencyStats[name].Active, "handler", name)
+	}
+	mw.header("concurrent_handles_waiting", "gauge", "Number of calls waiting for calls to the handler to return.")
+	for _, name := range concurrencyNames {
+		mw.sample("concurrent_handles_waiting", concurrencyStats[name].Waiting, "handler", name)
+	}
+	mw.header("handle_wait_seconds", "histogram", "Time calls waited before calling the handler.")
+	for _, name := range concurrencyNames {
+		mw.histogram("handle_wait_seconds", concurrencyStats[name].Wait, "handler", name)
+	}
+	mw.header("handle_duration_seconds", "histogram", "Time taken by the handler to handle a record.")
+	for _, name := range concurrencyNames {
+		mw.histogram("handle_duration_seconds", concurrencyStats[name].HandleDuration, "handler", name)
+	}
+
+	if err := mw.w.Flush(); err != nil && mw.err == nil {
+		mw.err = err
+	}
+	return mw.n, mw.err
+}
+
+// levels returns the number of records handled for each level, sorted by level.
+func (m *handlerMetrics) levels() iter.Seq2[slog.Level, uint64] {
+	m.mu.Lock()
+	counts := make(map[slog.Level]uint64, len(m.records))
+	for level, count := range m.records {
+		counts[level] = count.Load()
+	}
+	m.mu.Unlock()
+
+	return func(yield func(slog.Level, uint64) bool) {
+		for _, level := range slices.Sorted(maps.Keys(counts)) {
+			if !yield(level, counts[level]) {
+				return
+			}
+		}
+	}
+}
+
+// Close closes the underlying handler if it implements [io.Closer].
+func (h *instrumentedHandler) Close() error {
+	if closer, ok := h.handler.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *instrumentedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Flush flushes the underlying handler, if it has a Flush function, and records how long it took.
+func (h *instrumentedHandler) Flush() error {
+	flusher, ok := h.handler.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := flusher.Flush()
+	h.metrics.flushNanos.Add(int64(time.Since(start)))
+	h.metrics.flushCount.Add(1)
+	return err
+}
+
+// Handle counts the record and passes it to the underlying handler, counting any error it returns.
+func (h *instrumentedHandler) Handle(ctx context.Context, r slog.Record) error {
+	m := h.metrics
+	m.mu.Lock()
+	count, ok := m.records[r.Level]
+	if !ok {
+		count = &atomic.Uint64{}
+		m.records[r.Level] = count
+	}
+	m.mu.Unlock()
+	count.Add(1)
+
+	err := h.handler.Handle(ctx, r)
+	if err != nil {
+		m.errors.Add(1)
+	}
+	return err
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *instrumentedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &instrumentedHandler{
+		handler: h.handler.WithAttrs(attrs),
+		metrics: h.metrics,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *instrumentedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &instrumentedHandler{
+		handler: h.handler.WithGroup(name),
+		metrics: h.metrics,
+	}
+}
+
+// header writes the HELP and TYPE lines for the metric with the given name.
+func (w *metricWriter) header(name, metricType, help string) {
+	w.printf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", w.namespace, name, help, w.namespace, name, metricType)
+}
+
+// histogram writes the bucket, sum and count samples of the given histogram for the metric with the given name and
+// label name/value pairs.
+//
+// Nothing is written if the histogram has no buckets (ie: the handler is not instrumented).
+func (w *metricWriter) histogram(name string, h xlog.DurationHistogram, labels ...string) {
+	if len(h.Buckets) == 0 {
+		return
+	}
+	for _, b := range h.Buckets {
+		w.sample(name+"_bucket", b.Count, append(slices.Clone(labels), "le",
+			strconv.FormatFloat(b.UpperBound.Seconds(), 'g', -1, 64))...)
+	}
+	w.sample(name+"_bucket", h.Count, append(slices.Clone(labels), "le", "+Inf")...)
+	w.sample(name+"_sum", h.Sum.Seconds(), labels...)
+	w.sample(name+"_count", h.Count, labels...)
+}
+
+// printf writes the formatted text unless an error has already occurred.
+func (w *metricWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+// sample writes a single sample for the metric with the given name, value and label name/value pairs.
+func (w *metricWriter) sample(name string, value any, labels ...string) {
+	var sb strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+	}
+	w.printf("%s_%s{%s} %v\n", w.namespace, name, sb.String(), value)
+}
+
+// escapeLabelValue escapes the backslashes, double quotes and line feeds in the given label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,384 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultRateLimitKeyExpiry is the default time after which a [RateLimitHandler] forgets the rate limit of a key
+	// which has not been seen.
+	//
+	// This value is used when the key expiry in [RateLimitHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RateLimitHandlerOptions
+	DefaultRateLimitKeyExpiry = 5 * time.Minute
+
+	// DefaultRateLimitMaxKeys is the default maximum number of keys for which a [RateLimitHandler] keeps separate
+	// rate limits.
+	//
+	// This value is used when the max keys in [RateLimitHandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RateLimitHandlerOptions
+	DefaultRateLimitMaxKeys = 10000
+)
+
+// RateLimiter is a token bucket which limits how often an action (eg: delivering a record) may occur.
+//
+// The bucket holds up to burst tokens and is refilled at the given rate. Each action takes a token and may only occur
+// once a token is available. A rate of 0 or less means actions are never limited.
+//
+// All methods are safe to call concurrently.
+type RateLimiter struct {
+	// unexported variables
+	burst  float64    // maximum number of tokens in the bucket
+	clock  Clock      // clock used to refill the bucket
+	last   time.Time  // time at which the bucket was last refilled
+	mu     sync.Mutex // protects last and tokens
+	rate   float64    // number of tokens added to the bucket per second
+	tokens float64    // number of tokens in the bucket, which is negative if actions are waiting for tokens
+}
+
+// NewRateLimiter creates a new [RateLimiter] which allows the given number of actions per second, with bursts of up
+// to the given number of actions, starting with a full bucket.
+//
+// If burst is 0 or less, bursts of up to one second's worth of actions (and at least one action) are allowed. If the
+// clock is nil, [DefaultClock] is used.
+func NewRateLimiter(rate float64, burst int, clock Clock) *RateLimiter {
+	if clock == nil {
+		clock = DefaultClock
+	}
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &RateLimiter{
+		burst:  float64(burst),
+		clock:  clock,
+		last:   clock.Now(),
+		rate:   rate,
+		tokens: float64(burst),
+	}
+}
+
+// Allow takes a token and returns true if one is available, or returns false without taking a token otherwise.
+func (l *RateLimiter) Allow() bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Reserve takes a token, whether or not one is available, and returns how long the caller must wait before the
+// action may occur.
+func (l *RateLimiter) Reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait takes a token and waits until the action may occur or the context is canceled, whichever comes first.
+//
+// If the context is canceled, its error is returned and the token is not given back.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	delay := l.Reserve()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill adds the tokens accumulated since the bucket was last refilled, up to the burst size.
+//
+// The caller must hold the lock.
+func (l *RateLimiter) refill() {
+	now := l.clock.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+	}
+	l.last = now
+}
+
+// RateLimitHandlerOptions holds the options for a [RateLimitHandler].
+type RateLimitHandlerOptions struct {
+	// AlwaysLevel is the minimum level of records which are never limited.
+	//
+	// The default behavior is to limit records at every level.
+	AlwaysLevel slog.Leveler
+
+	// Burst is the maximum number of records with the same key passed to the underlying handler in a burst.
+	//
+	// The default behavior is to allow bursts of up to one second's worth of records.
+	Burst int
+
+	// Clock is the clock used to refill the rate limits and expire keys.
+	//
+	// The default behavior is to use [DefaultClock].
+	Clock Clock
+
+	// Key is called for each record to get the key whose rate limit applies to it (eg: a tenant or source IP).
+	//
+	// Records for which it returns an empty string are never limited.
+	//
+	// The default behavior is to use the value of the top-level attribute named by KeyAttr.
+	Key func(r slog.Record) string
+
+	// KeyAttr is the key of the top-level attribute (eg: "user_id") whose value is the key whose rate limit applies to
+	// the record, when Key is not set.
+	//
+	// The attribute may be added to the record itself or to the handler using WithAttrs. Records without the
+	// attribute are never limited.
+	KeyAttr string
+
+	// KeyExpiry is the time after which the rate limit of a key which has not been seen is forgotten, so that keys
+	// which are only seen for a while (eg: the addresses of attack traffic) do not use memory forever.
+	//
+	// The default behavior is defined by the default key expiry setting defined in the package.
+	KeyExpiry time.Duration
+
+	// MaxKeys is the maximum number of keys with their own rate limit.
+	//
+	// Once the limit is reached, records with new keys share a single rate limit until some of the keys expire.
+	//
+	// The default behavior is defined by the default max keys setting defined in the package.
+	MaxKeys int
+
+	// Name is the name of the handler used when notifying subscribers of records dropped by the rate limits.
+	Name string
+
+	// Rate is the maximum number of records per second with the same key passed to the underlying handler.
+	//
+	// The default behavior is to not limit records.
+	Rate float64
+}
+
+// RateLimitHandlerStats holds the counters for a [RateLimitHandler].
+type RateLimitHandlerStats struct {
+	// Dropped is the number of records dropped because their key exceeded its rate limit.
+	Dropped uint64 `json:"dropped"`
+
+	// Keys is the number of keys with their own rate limit.
+	Keys int `json:"keys"`
+}
+
+// RateLimitHandler is a handler which limits the rate of records with the same key (eg: the same tenant or source IP)
+// using a separate token bucket (see [RateLimiter]) for each key, protecting the sinks from a single hot tenant or
+// attack traffic flooding the logs.
+//
+// Records which exceed the rate limit of their key are dropped and reported to drop notification subscribers with
+// the reason [DropReasonRateLimit]. The rate limit of a key is forgotten once the key has not been seen for the key
+// expiry. Handlers derived using WithAttrs or WithGroup share the same rate limits.
+//
+// All methods are safe to call concurrently.
+type RateLimitHandler struct {
+	// unexported variables
+	grouped bool            // whether or not a group has been opened using WithGroup
+	handler slog.Handler    // underlying handler
+	key     string          // value of the key attribute added using WithAttrs, if any
+	state   *rateLimitState // state shared by all derived handlers
+}
+
+// ensure [RateLimitHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &RateLimitHandler{}
+
+// rateLimitState holds the rate limits shared by all handlers derived from the same rate limit handler.
+type rateLimitState struct {
+	dropped  uint64                   // number of records dropped
+	limiters map[string]*rateLimitKey // rate limits by key
+	mu       sync.Mutex               // protects the fields above and below
+	options  RateLimitHandlerOptions  // immutable handler options
+	overflow *RateLimiter             // rate limit shared by new keys once the maximum number of keys is reached
+	swept    time.Time                // time at which expired keys were last removed
+}
+
+// rateLimitKey holds the rate limit of a single key.
+type rateLimitKey struct {
+	limiter *RateLimiter // rate limit of the key
+	seen    time.Time    // time at which the key was last seen
+}
+
+// NewRateLimitHandler creates a new [RateLimitHandler] which passes records to the given handler, limiting the rate
+// of records with the same key.
+func NewRateLimitHandler(h slog.Handler, options RateLimitHandlerOptions) *RateLimitHandler {
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	if options.KeyExpiry <= 0 {
+		options.KeyExpiry = DefaultRateLimitKeyExpiry
+	}
+	if options.MaxKeys <= 0 {
+		options.MaxKeys = DefaultRateLimitMaxKeys
+	}
+	return &RateLimitHandler{
+		handler: h,
+		state: &rateLimitState{
+			limiters: map[string]*rateLimitKey{},
+			options:  options,
+			overflow: NewRateLimiter(options.Rate, options.Burst, options.Clock),
+			swept:    options.Clock.Now(),
+		},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *RateLimitHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *RateLimitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle passes the record to the underlying handler unless its key has exceeded its rate limit, in which case the
+// record is dropped.
+func (h *RateLimitHandler) Handle(ctx context.Context, r slog.Record) error {
+	st := h.state
+	if st.options.Rate <= 0 || (st.options.AlwaysLevel != nil && r.Level >= st.options.AlwaysLevel.Level()) {
+		return h.handler.Handle(ctx, r)
+	}
+	key := h.recordKey(r)
+	if key == "" || st.limiter(key).Allow() {
+		return h.handler.Handle(ctx, r)
+	}
+
+	st.mu.Lock()
+	st.dropped++
+	st.mu.Unlock()
+	NotifyDropped(st.options.Name, "rate_limit", DropReasonRateLimit, 1)
+	return nil
+}
+
+// Options returns a copy of the handler's options.
+func (h *RateLimitHandler) Options() any {
+	return h.state.options
+}
+
+// Stats returns the handler's counters.
+func (h *RateLimitHandler) Stats() RateLimitHandlerStats {
+	st := h.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return RateLimitHandlerStats{
+		Dropped: st.dropped,
+		Keys:    len(st.limiters),
+	}
+}
+
+// Type returns the type of the handler.
+func (h *RateLimitHandler) Type() string {
+	return "rate_limit"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *RateLimitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	key := h.key
+	if keyAttr := h.state.options.KeyAttr; keyAttr != "" && !h.grouped {
+		for _, attr := range attrs {
+			if attr.Key == keyAttr {
+				key = attr.Value.Resolve().String()
+			}
+		}
+	}
+	return &RateLimitHandler{
+		grouped: h.grouped,
+		handler: h.handler.WithAttrs(attrs),
+		key:     key,
+		state:   h.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *RateLimitHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &RateLimitHandler{
+		grouped: true,
+		handler: h.handler.WithGroup(name),
+		key:     h.key,
+		state:   h.state,
+	}
+}
+
+// recordKey returns the key whose rate limit applies to the record.
+func (h *RateLimitHandler) recordKey(r slog.Record) string {
+	options := h.state.options
+	if options.Key != nil {
+		return options.Key(r)
+	}
+	key := h.key
+	if options.KeyAttr != "" && !h.grouped {
+		r.Attrs(func(attr slog.Attr) bool {
+			if attr.Key == options.KeyAttr {
+				key = attr.Value.Resolve().String()
+				return false
+			}
+			return true
+		})
+	}
+	return key
+}
+
+// limiter returns the rate limit for the given key, creating it if necessary, and removes the rate limits of keys
+// which have expired at most once per key expiry.
+func (st *rateLimitState) limiter(key string) *RateLimiter {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := st.options.Clock.Now()
+	if now.Sub(st.swept) >= st.options.KeyExpiry {
+		for k, l := range st.limiters {
+			if now.Sub(l.seen) >= st.options.KeyExpiry {
+				delete(st.limiters, k)
+			}
+		}
+		st.swept = now
+	}
+
+	l, ok := st.limiters[key]
+	if !ok {
+		if len(st.limiters) >= st.options.MaxKeys {
+			return st.overflow
+		}
+		l = &rateLimitKey{
+			limiter: NewRateLimiter(st.options.Rate, st.options.Burst, st.options.Clock),
+		}
+		st.limiters[key] = l
+	}
+	l.seen = now
+	return l.limiter
+}
@@ -1,6 +1,34 @@
 package xlog
 
-import "log/slog"
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// RecordTimeEpoch formats the record's time as the number of seconds since the Unix epoch with fractional
+	// nanoseconds as a float64.
+	RecordTimeEpoch RecordTimeFormat = "epoch"
+
+	// RecordTimeEpochMillis formats the record's time as the number of milliseconds since the Unix epoch as an
+	// int64.
+	RecordTimeEpochMillis RecordTimeFormat = "epoch_millis"
+
+	// RecordTimeRFC3339 formats the record's time as a string using [time.RFC3339Nano].
+	RecordTimeRFC3339 RecordTimeFormat = "rfc3339"
+
+	// RecordTimeRaw leaves the record's time as a [time.Time] value.
+	RecordTimeRaw RecordTimeFormat = ""
+)
 
 var (
 	// AttrsKey is the key under which a record's attributes are mapped when a record is converted to a string map.
@@ -32,6 +60,34 @@ var (
 	TimeKey = slog.TimeKey
 )
 
+// RecordTimeFormat is the format used for the record's time when a record is converted to a string map.
+type RecordTimeFormat string
+
+// RecordToMapOptions holds the options for converting a record to a string map using [RecordToMapWithOptions].
+type RecordToMapOptions struct {
+	// Attrs holds any handler-level attributes added using [slog.Handler.WithAttrs].
+	//
+	// Attributes added while a group was open should already be nested inside of that group. They are included in
+	// the map before the record's own attributes.
+	Attrs []slog.Attr
+
+	// FlattenSeparator is the separator used to join group names and attribute keys when flattening groups.
+	//
+	// If empty, groups are mapped as nested maps. Otherwise, all attributes are mapped directly into the attributes
+	// map using keys made up of their group names and key joined by the separator (eg: "group.key").
+	FlattenSeparator string
+
+	// Groups holds the names of any groups opened using [slog.Handler.WithGroup], in order.
+	//
+	// The record's own attributes are nested inside of these groups.
+	Groups []string
+
+	// TimeFormat is the format to use for the record's time.
+	//
+	// The default is to leave the time as a [time.Time] value.
+	TimeFormat RecordTimeFormat
+}
+
 // RecordToMap converts an entire [slog.Record] into a map[string]any.
 //
 // The map includes the record's time, level, message and any and all user-provided attributes, with support for
@@ -47,6 +103,16 @@ var (
 // the package's [SourceKey] (default: [slog.SourceKey]) with the fields [FileKey], [LineKey] and [FunctionKey]
 // mapped to the caller's file, line and function, respectively.
 func RecordToMap(r *slog.Record) map[string]any {
+	return RecordToMapWithOptions(r, RecordToMapOptions{})
+}
+
+// RecordToMapWithOptions converts an entire [slog.Record] into a map[string]any in the same way as [RecordToMap],
+// but also includes any handler-level attributes and groups from the options.
+//
+// Attribute values are resolved and groups with empty keys are inlined into their parent. If the options specify a
+// flatten separator, nested groups are flattened into the attributes map. The record's time is formatted using the
+// time format from the options.
+func RecordToMapWithOptions(r *slog.Record, options RecordToMapOptions) map[string]any {
 	if r == nil {
 		return nil
 	}
@@ -55,7 +121,7 @@ func RecordToMap(r *slog.Record) map[string]any {
 	m := make(map[string]any, 4)
 
 	// add the built-in fields
-	m[TimeKey] = r.Time
+	m[TimeKey] = formatRecordTime(r.Time, options.TimeFormat)
 	m[LevelKey] = r.Level.String()
 	m[MessageKey] = r.Message
 	src := r.Source()
@@ -66,10 +132,24 @@ func RecordToMap(r *slog.Record) map[string]any {
 			FunctionKey: src.Function,
 		}
 	}
-	// iterate over all attributes in the record
-	attrs := make(map[string]any, r.NumAttrs())
+
+	// add the handler-level attributes followed by all attributes in the record, nested within any open groups
+	attrs := make(map[string]any, len(options.Attrs)+r.NumAttrs())
+	for _, a := range options.Attrs {
+		addAttrToMap(attrs, "", options.FlattenSeparator, a)
+	}
+	recAttrs, prefix := attrs, ""
+	if options.FlattenSeparator != "" {
+		if len(options.Groups) > 0 {
+			prefix = strings.Join(options.Groups, options.FlattenSeparator) + options.FlattenSeparator
+		}
+	} else if r.NumAttrs() > 0 {
+		for _, g := range options.Groups {
+			recAttrs = groupMap(recAttrs, g)
+		}
+	}
 	r.Attrs(func(a slog.Attr) bool {
-		attrs[a.Key] = resolveValue(a.Value)
+		addAttrToMap(recAttrs, prefix, options.FlattenSeparator, a)
 		return true
 	})
 	if len(attrs) > 0 {
@@ -78,18 +158,283 @@ func RecordToMap(r *slog.Record) map[string]any {
 	return m
 }
 
-// resolveValue recursively processes an slog.Value.
+// addAttrToMap resolves the given attribute and adds it to the map, prefixing its key with the given prefix.
+//
+// If sep is empty, groups are added as nested maps, merging with any existing map for the same group. Otherwise,
+// groups are flattened using sep to join keys.
+func addAttrToMap(m map[string]any, prefix, sep string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Key != "" || !a.Value.Equal(slog.Value{}) {
+			m[prefix+a.Key] = a.Value.Any()
+		}
+		return
+	}
+
+	// groups with empty keys are inlined into their parent
+	switch {
+	case len(a.Value.Group()) == 0:
+		return
+	case a.Key == "":
+		for _, child := range a.Value.Group() {
+			addAttrToMap(m, prefix, sep, child)
+		}
+	case sep != "":
+		for _, child := range a.Value.Group() {
+			addAttrToMap(m, prefix+a.Key+sep, sep, child)
+		}
+	default:
+		group := groupMap(m, prefix+a.Key)
+		for _, child := range a.Value.Group() {
+			addAttrToMap(group, "", sep, child)
+		}
+	}
+}
+
+// formatRecordTime converts the given time into the given format.
+func formatRecordTime(t time.Time, format RecordTimeFormat) any {
+	switch format {
+	case RecordTimeEpoch:
+		return float64(t.UnixNano()) / float64(time.Second)
+	case RecordTimeEpochMillis:
+		return t.UnixMilli()
+	case RecordTimeRFC3339:
+		return t.Format(time.RFC3339Nano)
+	}
+	return t
+}
+
+// groupMap returns the nested map stored under the given key, creating it if it does not exist or if the key holds
+// a value which is not a map.
+func groupMap(m map[string]any, key string) map[string]any {
+	if group, ok := m[key].(map[string]any); ok {
+		return group
+	}
+	group := map[string]any{}
+	m[key] = group
+	return group
+}
+
+// JSONToRecord parses a single JSON object, such as a line of NDJSON output, into an [slog.Record].
+//
+// The object is converted using [MapToRecord], with numbers being converted to int64 values when possible and
+// float64 values otherwise.
+//
+// This function may return an error with any of the following codes:
+//   - [MarshalError]: the data is not a valid JSON object
+func JSONToRecord(data []byte) (slog.Record, xerrors.Error) {
+	var m map[string]any
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return slog.Record{}, xerrors.Wrapf(MarshalError, err, "failed to parse JSON record: %s", err.Error())
+	}
+	if m == nil {
+		return slog.Record{}, xerrors.New(MarshalError, "failed to parse JSON record: data is not a JSON object")
+	}
+	return MapToRecord(m)
+}
+
+// MapToRecord converts a map[string]any back into an [slog.Record] on a best-effort basis.
+//
+// This is the inverse of [RecordToMap], but it also accepts maps decoded from the output of [slog.JSONHandler] and
+// similar handlers where attributes are stored alongside the built-in fields rather than under [AttrsKey].
+//
+// Map fields are converted as follows:
+//   - time is read from [TimeKey], "@timestamp", "timestamp" or "ts" and may be a [time.Time], a string in RFC 3339
+//     or [time.DateTime] format or a number of seconds, milliseconds, microseconds or nanoseconds since the epoch;
+//     if the time is missing or cannot be parsed, the record's time is left as the zero value
+//   - level is read from [LevelKey] or "severity" and may be a level name as accepted by [slog.Level.UnmarshalText]
+//     (eg: "INFO" or "WARN+2"), a common alias (eg: "warning", "fatal", "trace") or a number; if the level is
+//     missing or cannot be parsed, [slog.LevelInfo] is used and the original value is kept as an attribute
+//   - message is read from [MessageKey] or "message"
+//   - caller information under [SourceKey] is added as an attribute holding an [*slog.Source] since the record's
+//     program counter cannot be restored
+//   - all attributes under [AttrsKey] and all other fields are added as attributes in key order, with nested maps
+//     converted into groups
+//
+// This function may return an error with any of the following codes:
+//   - [InvalidParameter]: the map is nil
+func MapToRecord(m map[string]any) (slog.Record, xerrors.Error) {
+	if m == nil {
+		return slog.Record{}, xerrors.New(InvalidParameter, "cannot convert a nil map to a record")
+	}
+	fields := maps.Clone(m)
+
+	// parse the built-in fields
+	var t time.Time
+	if key, v, ok := takeField(fields, TimeKey, "@timestamp", "timestamp", "ts"); ok {
+		var parsed bool
+		if t, parsed = parseRecordTime(v); !parsed {
+			fields[key] = v
+		}
+	}
+	level := slog.LevelInfo
+	if key, v, ok := takeField(fields, LevelKey, "severity"); ok {
+		var parsed bool
+		if level, parsed = parseRecordLevel(v); !parsed {
+			fields[key] = v
+		}
+	}
+	var msg string
+	if key, v, ok := takeField(fields, MessageKey, "message"); ok {
+		if s, isString := v.(string); isString {
+			msg = s
+		} else {
+			fields[key] = v
+		}
+	}
+	r := slog.NewRecord(t, level, msg, 0)
+
+	// add the caller information as an attribute
+	if src, ok := fields[SourceKey].(map[string]any); ok {
+		source := &slog.Source{}
+		source.File, _ = src[FileKey].(string)
+		source.Function, _ = src[FunctionKey].(string)
+		if line, ok := toInt64(src[LineKey]); ok {
+			source.Line = int(line)
+		}
+		r.AddAttrs(slog.Any(SourceKey, source))
+		delete(fields, SourceKey)
+	}
+
+	// add the attributes
+	if attrs, ok := fields[AttrsKey].(map[string]any); ok {
+		r.AddAttrs(mapToAttrs(attrs)...)
+		delete(fields, AttrsKey)
+	}
+	r.AddAttrs(mapToAttrs(fields)...)
+	return r, nil
+}
+
+// mapToAttrs converts the given map into a list of attributes sorted by key, converting nested maps into groups.
+func mapToAttrs(m map[string]any) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		switch v := m[k].(type) {
+		case map[string]any:
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(mapToAttrs(v)...)})
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				attrs = append(attrs, slog.Int64(k, i))
+			} else if f, err := v.Float64(); err == nil {
+				attrs = append(attrs, slog.Float64(k, f))
+			} else {
+				attrs = append(attrs, slog.String(k, v.String()))
+			}
+		default:
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	return attrs
+}
+
+// parseRecordLevel converts the given value into a level, returning false if it could not be converted.
+func parseRecordLevel(v any) (slog.Level, bool) {
+	if i, ok := toInt64(v); ok {
+		return slog.Level(i), true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return slog.LevelInfo, false
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace", "finest":
+		return slog.LevelDebug - 4, true
+	case "warning":
+		return slog.LevelWarn, true
+	case "err":
+		return slog.LevelError, true
+	case "critical", "fatal", "panic":
+		return slog.LevelError + 4, true
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, false
+	}
+	return level, true
+}
+
+// parseRecordTime converts the given value into a time, returning false if it could not be converted.
 //
-// If the value is a group, it creates a nested map. Otherwise, it returns the value's underlying 'any'
-// representation.
-func resolveValue(v slog.Value) any {
-	if v.Kind() == slog.KindGroup {
-		attrs := v.Group()
-		groupMap := make(map[string]any, len(attrs))
-		for _, a := range attrs {
-			groupMap[a.Key] = resolveValue(a.Value)
-		}
-		return groupMap
-	}
-	return v.Any()
+// Numeric values are treated as seconds, milliseconds, microseconds or nanoseconds since the epoch depending on
+// their magnitude.
+func parseRecordTime(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, "2006-01-02T15:04:05.000-0700"} {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, true
+			}
+		}
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return epochToTime(f), true
+		}
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return epochToTime(f), true
+		}
+	case float64:
+		return epochToTime(val), true
+	default:
+		if i, ok := toInt64(val); ok {
+			return epochToTime(float64(i)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// epochToTime converts a number of seconds, milliseconds, microseconds or nanoseconds since the epoch into a time,
+// guessing the unit based on the magnitude of the number.
+func epochToTime(f float64) time.Time {
+	switch abs := math.Abs(f); {
+	case abs >= 1e17:
+		return time.Unix(0, int64(f))
+	case abs >= 1e14:
+		return time.UnixMicro(int64(f))
+	case abs >= 1e11:
+		return time.UnixMilli(int64(f))
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+// takeField removes and returns the value for the first of the given keys which exists in the map.
+func takeField(m map[string]any, keys ...string) (string, any, bool) {
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			delete(m, k)
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// toInt64 converts the given integer or integral value into an int64, returning false if it could not be converted.
+func toInt64(v any) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int64:
+		return val, true
+	case int32:
+		return int64(val), true
+	case uint:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case uint64:
+		return int64(val), true
+	case float64:
+		if val == math.Trunc(val) {
+			return int64(val), true
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
 }
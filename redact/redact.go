@@ -0,0 +1,472 @@
+// Package redact erases the records of a data subject from locally stored logs to support right-to-erasure requests.
+//
+// Records are found using the subject attribute added by [xlog.NewSubjectHandler]. Log files written in JSON (NDJSON),
+// ECS or logfmt format are rewritten in place, including rotated files compressed using gzip, and tables holding
+// records in a SQL database (eg: SQLite) are updated using [database/sql]. Each matching record is either deleted or
+// replaced by a tombstone which records that a record was erased without holding any of its data.
+//
+// Records in any other format (eg: CSV, TSV, W3C, CEF, LEEF or the binary formats) cannot be searched for a subject, so
+// they are refused with an error rather than being left in place unredacted.
+//
+// Files encrypted by the file handler cannot be rewritten without the private key, so they should be decrypted,
+// redacted and encrypted again by the caller. Only rotated files may be redacted while the application is logging:
+// the file currently being written by a file handler is refused, since replacing it would lose the handler's writes.
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+	"go.innotegrity.dev/xlog/handlers"
+)
+
+const (
+	// ModeDelete removes matching records entirely.
+	ModeDelete Mode = "delete"
+
+	// ModeTombstone replaces matching records with a tombstone holding only the time of the record and the
+	// [TombstoneKey] attribute.
+	ModeTombstone Mode = "tombstone"
+)
+
+const (
+	// TombstoneKey is the key of the attribute which marks a record as a tombstone.
+	TombstoneKey = "xlog_tombstone"
+)
+
+var (
+	// errUnsupportedRecord is returned by redactLine for lines which are not JSON, ECS or logfmt records.
+	errUnsupportedRecord = errors.New("record is not in JSON, ECS or logfmt format")
+
+	// identifierRegexp matches the SQL table and column names accepted by [SQL].
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Mode defines what happens to the records of a subject.
+type Mode string
+
+// Options holds the options used to redact records.
+type Options struct {
+	// Columns holds the names of additional columns which are set to NULL when records in a SQL table are replaced
+	// by tombstones (eg: the message and attributes columns).
+	//
+	// The subject column is always set to NULL. This option is ignored for files and in [ModeDelete].
+	Columns []string
+
+	// Key is the key of the attribute which holds the subject identifier.
+	//
+	// The default behavior is defined by the default subject key setting defined in the xlog package.
+	Key string
+
+	// Mode defines whether matching records are deleted or replaced by tombstones.
+	//
+	// The default behavior is to delete matching records.
+	Mode Mode
+}
+
+// File redacts the records of the given subject from the log file at the given path and returns the number of
+// records redacted.
+//
+// The file is only rewritten if it holds any matching records. Files holding records which are not in JSON, ECS or
+// logfmt format (see [Stream]) are left unchanged and an error is returned. The new contents are written to a temporary file in
+// the same folder which then replaces the original, so the file is never left partially redacted. Files compressed
+// using gzip are detected automatically and compressed again after they are redacted.
+//
+// The file currently being written by a [handlers.FileHandler] in this process cannot be redacted, since the handler
+// would keep writing to the replaced file; only rotated files should be redacted. Files written by other processes
+// are not detected, so the caller must ensure they are no longer being written.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataCompressionError]: failed to decompress or compress the file
+//   - [xlog.DataReadError]: failed to read the file
+//   - [xlog.DataWriteError]: failed to write the redacted file
+//   - [xlog.InvalidParameter]: the subject identifier is empty, the mode is invalid or the file is currently being
+//     written by a file handler
+//   - [xlog.UnsupportedFormatError]: the file holds a record which is not in JSON, ECS or logfmt format
+func File(path, subjectID string, options Options) (int, xerrors.Error) {
+	if err := options.validate(subjectID); err != nil {
+		return 0, err
+	}
+	if handlers.IsActiveLogFile(path) {
+		return 0, xerrors.Newf(xlog.InvalidParameter,
+			"log file '%s' is currently being written by a file handler; only rotated files may be redacted", path).
+			WithAttr("log_file", path)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.DataReadError, err, "failed to open log file '%s': %s", path, err.Error()).
+			WithAttr("log_file", path)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.DataReadError, err, "failed to stat log file '%s': %s", path, err.Error()).
+			WithAttr("log_file", path)
+	}
+
+	// detect compressed data using the gzip magic number rather than relying on the file name
+	var r io.Reader = bufio.NewReader(src)
+	compressed := false
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, xerrors.Wrapf(xlog.DataCompressionError, err, "failed to decompress log file '%s': %s", path,
+				err.Error()).WithAttr("log_file", path)
+		}
+		defer gr.Close()
+		r = gr
+		compressed = true
+	}
+
+	// write the redacted records to a temporary file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".redact-*")
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to create temporary file for '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var w io.Writer = tmp
+	var gw *gzip.Writer
+	if compressed {
+		gw = gzip.NewWriter(tmp)
+		w = gw
+	}
+	count, xerr := Stream(r, w, subjectID, options)
+	if xerr != nil {
+		return 0, xerr.WithAttr("log_file", path)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return 0, xerrors.Wrapf(xlog.DataCompressionError, err, "failed to compress log file '%s': %s", path,
+				err.Error()).WithAttr("log_file", path)
+		}
+	}
+
+	// replace the original file
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to set mode of redacted file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to write redacted file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to replace log file '%s': %s", path,
+			err.Error()).WithAttr("log_file", path)
+	}
+	return count, nil
+}
+
+// Glob redacts the records of the given subject from every log file matching the given pattern (eg:
+// "/var/log/app/app*.log*") and returns the total number of records redacted.
+//
+// Files with the ".age" extension are encrypted and files currently being written by a [handlers.FileHandler] are
+// active, so both are skipped. Redaction stops at the first file which fails, including files holding records which
+// are not in JSON, ECS or logfmt format, so the pattern should only match files which can be redacted.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataCompressionError]: failed to decompress or compress a file
+//   - [xlog.DataReadError]: failed to read a file
+//   - [xlog.DataWriteError]: failed to write a redacted file
+//   - [xlog.InvalidParameter]: the pattern is malformed, the subject identifier is empty or the mode is invalid
+//   - [xlog.UnsupportedFormatError]: a file holds a record which is not in JSON, ECS or logfmt format
+func Glob(pattern, subjectID string, options Options) (int, xerrors.Error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.InvalidParameter, err, "invalid pattern '%s': %s", pattern, err.Error()).
+			WithAttr("pattern", pattern)
+	}
+	total := 0
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || filepath.Ext(path) == ".age" ||
+			handlers.IsActiveLogFile(path) {
+			continue
+		}
+		count, xerr := File(path, subjectID, options)
+		total += count
+		if xerr != nil {
+			return total, xerr
+		}
+	}
+	return total, nil
+}
+
+// SQL redacts the records of the given subject from the given table, whose column holds the subject identifier, and
+// returns the number of records redacted.
+//
+// In [ModeDelete], matching rows are deleted. In [ModeTombstone], the subject column and any columns in the options
+// are set to NULL. The statement uses "?" placeholders, as supported by SQLite and MySQL drivers.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to execute the statement
+//   - [xlog.InvalidParameter]: a table or column name is invalid, the subject identifier is empty or the mode is
+//     invalid
+func SQL(ctx context.Context, db *sql.DB, table, column, subjectID string, options Options) (int64, xerrors.Error) {
+	if err := options.validate(subjectID); err != nil {
+		return 0, err
+	}
+	for _, name := range append([]string{table, column}, options.Columns...) {
+		if !identifierRegexp.MatchString(name) {
+			return 0, xerrors.Newf(xlog.InvalidParameter, "invalid table or column name '%s'", name).
+				WithAttr("name", name)
+		}
+	}
+
+	var query string
+	if options.Mode == ModeTombstone {
+		set := make([]string, 0, len(options.Columns)+1)
+		for _, name := range append([]string{column}, options.Columns...) {
+			set = append(set, fmt.Sprintf(`"%s" = NULL`, name))
+		}
+		query = fmt.Sprintf(`UPDATE "%s" SET %s WHERE "%s" = ?`, table, strings.Join(set, ", "), column)
+	} else {
+		query = fmt.Sprintf(`DELETE FROM "%s" WHERE "%s" = ?`, table, column)
+	}
+	result, err := db.ExecContext(ctx, query, subjectID)
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to redact records from table '%s': %s", table,
+			err.Error()).WithAttr("table", table)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, xerrors.Wrapf(xlog.DataWriteError, err, "failed to count records redacted from table '%s': %s",
+			table, err.Error()).WithAttr("table", table)
+	}
+	return count, nil
+}
+
+// Stream copies the records read from r to w, redacting the records of the given subject, and returns the number of
+// records redacted.
+//
+// Each line read from r holds a single record in JSON, ECS or logfmt format, while empty lines are copied unchanged.
+// Numeric subject identifiers in JSON records are compared exactly as written, so identifiers too large to be held by
+// a float64 still match. Since a record which cannot be parsed may still belong to the subject, redaction stops at the
+// first line which is not a JSON, ECS or logfmt record (eg: a CSV, CEF or binary record) and an error is returned.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read from r
+//   - [xlog.DataWriteError]: failed to write to w
+//   - [xlog.InvalidParameter]: the subject identifier is empty or the mode is invalid
+//   - [xlog.UnsupportedFormatError]: a line is not a JSON, ECS or logfmt record
+func Stream(r io.Reader, w io.Writer, subjectID string, options Options) (int, xerrors.Error) {
+	if err := options.validate(subjectID); err != nil {
+		return 0, err
+	}
+	if options.Key == "" {
+		options.Key = xlog.DefaultSubjectKey
+	}
+
+	count := 0
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for n := 1; ; n++ {
+		line, readErr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			out := line
+			tombstone, ok, err := redactLine(bytes.TrimRight(line, "\r\n"), subjectID, options)
+			if err != nil {
+				return count, xerrors.Wrapf(xlog.UnsupportedFormatError, err, "failed to parse record on line %d: %s", n,
+					err.Error()).WithAttr("line", n)
+			}
+			if ok {
+				count++
+				out = tombstone
+			}
+			if _, err := bw.Write(out); err != nil {
+				return count, xerrors.Wrapf(xlog.DataWriteError, err, "failed to write record: %s", err.Error())
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return count, xerrors.Wrapf(xlog.DataReadError, readErr, "failed to read record: %s", readErr.Error())
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return count, xerrors.Wrapf(xlog.DataWriteError, err, "failed to write record: %s", err.Error())
+	}
+	return count, nil
+}
+
+// validate checks the options and subject identifier for problems.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.InvalidParameter]: the subject identifier is empty or the mode is invalid
+func (o Options) validate(subjectID string) xerrors.Error {
+	if subjectID == "" {
+		return xerrors.New(xlog.InvalidParameter, "subject identifier cannot be empty")
+	}
+	switch o.Mode {
+	case ModeDelete, ModeTombstone, "":
+	default:
+		return xerrors.Newf(xlog.InvalidParameter, "invalid redaction mode '%s'", o.Mode).WithAttr("mode", o.Mode)
+	}
+	return nil
+}
+
+// isLogfmt returns whether or not the given line consists entirely of logfmt key/value pairs separated by spaces.
+//
+// Keys and values may be quoted. Unquoted keys and values cannot hold spaces, equal signs, quotes or non-printable
+// characters, so CSV, TSV, W3C and binary records are rejected. CEF and LEEF records are rejected by their prefix,
+// since their headers may otherwise look like a key.
+func isLogfmt(line []byte) bool {
+	if !utf8.Valid(line) || bytes.HasPrefix(line, []byte("CEF:")) || bytes.HasPrefix(line, []byte("LEEF:")) {
+		return false
+	}
+	// token returns the length of the quoted or unquoted key or value at the start of the given text, or 0 if there
+	// is none
+	token := func(text string) int {
+		if strings.HasPrefix(text, `"`) {
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return 0
+			}
+			return len(quoted)
+		}
+		return strings.IndexFunc(text+" ", func(r rune) bool {
+			return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+		})
+	}
+	for text := string(line); ; {
+		n := token(text)
+		if n == 0 || n >= len(text) || text[n] != '=' {
+			return false
+		}
+		text = text[n+1:]
+		if n = token(text); n == 0 {
+			return false
+		}
+		if text = text[n:]; text == "" {
+			return true
+		}
+		if text[0] != ' ' {
+			return false
+		}
+		text = text[1:]
+	}
+}
+
+// logfmtValue returns the value of the given key in a logfmt line, if it is present.
+func logfmtValue(line []byte, key string) (string, bool) {
+	prefix := []byte(key + "=")
+	for i := 0; i < len(line); {
+		j := bytes.Index(line[i:], prefix)
+		if j < 0 {
+			return "", false
+		}
+		start := i + j
+		i = start + len(prefix)
+		if start > 0 && line[start-1] != ' ' {
+			continue
+		}
+
+		rest := line[i:]
+		if len(rest) > 0 && rest[0] == '"' {
+			if quoted, err := strconv.QuotedPrefix(string(rest)); err == nil {
+				value, _ := strconv.Unquote(quoted)
+				return value, true
+			}
+		}
+		if end := bytes.IndexByte(rest, ' '); end >= 0 {
+			rest = rest[:end]
+		}
+		return string(rest), true
+	}
+	return "", false
+}
+
+// redactLine returns the replacement for the given line, which is empty in [ModeDelete], if it holds a record for
+// the given subject.
+//
+// An error is returned if the line is not empty and is not a JSON, ECS or logfmt record.
+func redactLine(line []byte, subjectID string, options Options) ([]byte, bool, error) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return nil, false, nil
+	}
+
+	// JSON and ECS records
+	if trimmed[0] == '{' {
+		var record map[string]any
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&record); err != nil {
+			return nil, false, err
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, false, errors.New("unexpected data after the record")
+		}
+		value, ok := record[options.Key]
+		if labels, isMap := record["labels"].(map[string]any); !ok && isMap {
+			value, ok = labels[options.Key]
+		}
+		if !ok || subjectValue(value) != subjectID {
+			return nil, false, nil
+		}
+		if options.Mode != ModeTombstone {
+			return []byte{}, true, nil
+		}
+		tombstone := map[string]any{TombstoneKey: true}
+		for _, key := range []string{"time", "@timestamp"} {
+			if t, ok := record[key]; ok {
+				tombstone[key] = t
+			}
+		}
+		data, _ := json.Marshal(tombstone)
+		return append(data, '\n'), true, nil
+	}
+
+	// logfmt records
+	if !isLogfmt(trimmed) {
+		return nil, false, errUnsupportedRecord
+	}
+	value, ok := logfmtValue(line, options.Key)
+	if !ok || value != subjectID {
+		return nil, false, nil
+	}
+	if options.Mode != ModeTombstone {
+		return []byte{}, true, nil
+	}
+	var tombstone []byte
+	if t, ok := logfmtValue(line, "time"); ok {
+		if strings.ContainsAny(t, " \"=") {
+			t = strconv.Quote(t)
+		}
+		tombstone = append(tombstone, "time="+t+" "...)
+	}
+	tombstone = append(tombstone, TombstoneKey+"=true\n"...)
+	return tombstone, true, nil
+}
+
+// subjectValue returns the given decoded JSON subject identifier as a string, using the exact text of numeric
+// identifiers so that large IDs (eg: 9007199254740993) still match.
+func subjectValue(value any) string {
+	if v, ok := value.(json.Number); ok {
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
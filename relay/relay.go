@@ -0,0 +1,362 @@
+// Package relay provides a simple log forwarder which reads NDJSON records from stdin, files or network sockets and
+// replays them through an [slog.Handler] tree.
+//
+// Combined with handlers built from configuration files using the handlers package, this allows a small process to
+// ship logs written by other applications to any destination supported by xlog.
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+var (
+	// DefaultMaxLineSize is the default maximum size (in bytes) of a single NDJSON line.
+	//
+	// This value is used when the max line size in [Options] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/relay#Options
+	DefaultMaxLineSize = 1024 * 1024
+
+	// DefaultPollInterval is the default interval at which a file being tailed is checked for new data.
+	//
+	// This value is used when the poll interval in [Options] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/relay#Options
+	DefaultPollInterval = 250 * time.Millisecond
+)
+
+// Options holds the options for a [Relay].
+type Options struct {
+	// ErrorHandler is a function that's called to process any errors that occur while parsing or replaying a
+	// record.
+	//
+	// The record is nil if the line could not be parsed. Errors returned by the function are ignored and the relay
+	// continues with the next line.
+	//
+	// The default behavior is to ignore these errors.
+	ErrorHandler xlog.ErrorHandlerFn
+
+	// Handler is the handler through which records are replayed.
+	//
+	// This value is required.
+	Handler slog.Handler
+
+	// MaxLineSize is the maximum size (in bytes) of a single NDJSON line.
+	//
+	// Lines longer than this are discarded and reported to the ErrorHandler.
+	//
+	// The default behavior is defined by the default max line size setting defined in the package.
+	MaxLineSize int
+
+	// PollInterval is the interval at which a file being tailed is checked for new data, truncation or rotation.
+	//
+	// The default behavior is defined by the default poll interval setting defined in the package.
+	PollInterval time.Duration
+}
+
+// Relay reads NDJSON records from one or more sources and replays them through a handler.
+//
+// Each line is parsed using [xlog.JSONToRecord] and passed to the handler if it is enabled for the record's level.
+// All methods are safe to call concurrently, so a single relay can read from several sources at once.
+type Relay struct {
+	// unexported variables
+	options Options // relay options
+}
+
+// lineState holds the state of the line being read from a file which is followed across several reads.
+type lineState struct {
+	discarding bool   // whether the rest of a line which is too long is being discarded
+	partial    []byte // incomplete line read at the end of the file
+}
+
+// New creates a new [Relay] object with the given options.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.OptionsValidationError]: one or more options are invalid
+func New(options Options) (*Relay, xerrors.Error) {
+	if options.Handler == nil {
+		return nil, xerrors.New(xlog.OptionsValidationError, "relay handler cannot be nil")
+	}
+	if options.MaxLineSize <= 0 {
+		options.MaxLineSize = DefaultMaxLineSize
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	return &Relay{
+		options: options,
+	}, nil
+}
+
+// Listen accepts connections on the given network address (eg: "tcp" and ":5170" or "unix" and "/run/app.sock")
+// and replays the records read from each connection until the context is canceled.
+//
+// Each connection is read in its own goroutine. The function returns nil once the context is canceled and all
+// connections have been closed.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.NetworkListenError]: failed to listen on the address or to accept a connection
+func (r *Relay) Listen(ctx context.Context, network, address string) xerrors.Error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return xerrors.Wrapf(xlog.NetworkListenError, err, "failed to listen on %s address '%s': %s", network,
+			address, err.Error()).WithAttrs(map[string]any{
+			"network": network,
+			"address": address,
+		})
+	}
+	return r.Serve(ctx, listener)
+}
+
+// Serve accepts connections on the given listener and replays the records read from each connection until the
+// context is canceled.
+//
+// The listener and any open connections are closed when the function returns.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.NetworkListenError]: failed to accept a connection
+func (r *Relay) Serve(ctx context.Context, listener net.Listener) xerrors.Error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := map[net.Conn]struct{}{}
+
+	// close the listener and any open connections when the context is canceled
+	closeAll := func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			conn.Close()
+		}
+	}
+	stop := context.AfterFunc(ctx, closeAll)
+	defer stop()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			closeAll()
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return xerrors.Wrapf(xlog.NetworkListenError, err, "failed to accept connection: %s", err.Error()).
+				WithAttr("address", listener.Addr().String())
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		if ctx.Err() != nil {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+			if err := r.ReadFrom(ctx, conn); err != nil && ctx.Err() == nil {
+				r.handleError(ctx, err, nil)
+			}
+		}()
+	}
+}
+
+// ReadFrom reads records from the given reader until it reaches EOF or the context is canceled.
+//
+// Note that cancelling the context does not interrupt a blocked read. Close the reader to stop reading immediately.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read from the reader
+func (r *Relay) ReadFrom(ctx context.Context, reader io.Reader) xerrors.Error {
+	_, err := r.readLines(ctx, bufio.NewReader(reader), nil)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return xerrors.Wrapf(xlog.DataReadError, err, "failed to read records: %s", err.Error())
+	}
+	return nil
+}
+
+// ReadStdin reads records from [os.Stdin] until it reaches EOF or the context is canceled.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read from stdin
+func (r *Relay) ReadStdin(ctx context.Context) xerrors.Error {
+	return r.ReadFrom(ctx, os.Stdin)
+}
+
+// TailFile follows the file at the given path and replays new records as they are written until the context is
+// canceled.
+//
+// If fromStart is true, records already in the file are replayed first. Otherwise, only records written after the
+// function is called are replayed. If the file is truncated, reading starts again from the beginning of the file.
+// If the file is rotated (ie: replaced by a new file at the same path), the remainder of the old file is read before
+// switching to the new file. The file does not need to exist when the function is called.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to open or read from the file
+func (r *Relay) TailFile(ctx context.Context, path string, fromStart bool) xerrors.Error {
+	ticker := time.NewTicker(r.options.PollInterval)
+	defer ticker.Stop()
+
+	var file *os.File
+	var reader *bufio.Reader
+	var offset int64
+	var state lineState
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		// open the file if it isn't already open
+		if file == nil {
+			var err error
+			if file, err = os.Open(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return xerrors.Wrapf(xlog.DataReadError, err, "failed to open file '%s': %s", path, err.Error()).
+					WithAttr("path", path)
+			} else if err != nil {
+				// read the whole file once it has been created
+				fromStart = true
+			} else {
+				offset = 0
+				if !fromStart {
+					if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+						return xerrors.Wrapf(xlog.DataReadError, err, "failed to seek file '%s': %s", path,
+							err.Error()).WithAttr("path", path)
+					}
+				}
+				reader = bufio.NewReader(file)
+				state = lineState{}
+			}
+		}
+
+		// read all complete lines currently in the file
+		if file != nil {
+			n, err := r.readLines(ctx, reader, &state)
+			offset += n
+			if err != nil && !errors.Is(err, io.EOF) {
+				return xerrors.Wrapf(xlog.DataReadError, err, "failed to read file '%s': %s", path, err.Error()).
+					WithAttr("path", path)
+			}
+
+			// check for truncation or rotation
+			if info, err := os.Stat(path); err == nil {
+				if current, err := file.Stat(); err == nil && !os.SameFile(info, current) {
+					file.Close()
+					file = nil
+					fromStart = true
+				} else if info.Size() < offset {
+					if _, err := file.Seek(0, io.SeekStart); err == nil {
+						offset = 0
+						reader.Reset(file)
+						state = lineState{}
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleError passes the error to the error handler, if one is set.
+func (r *Relay) handleError(ctx context.Context, err error, rec *slog.Record) {
+	if r.options.ErrorHandler != nil {
+		_ = r.options.ErrorHandler(ctx, err, rec)
+	}
+}
+
+// readLines reads and replays complete lines from the reader until EOF, an error or the context is canceled,
+// returning the number of bytes read.
+//
+// If state is not nil, any incomplete line at EOF and whether or not the rest of it is being discarded are stored in
+// it so that reading can be resumed later. Otherwise, an incomplete line at EOF is treated as a complete line.
+func (r *Relay) readLines(ctx context.Context, reader *bufio.Reader, state *lineState) (int64, error) {
+	var total int64
+	var line []byte
+	var discarding bool
+	if state != nil {
+		line, discarding = state.partial, state.discarding
+	}
+	for ctx.Err() == nil {
+		chunk, err := reader.ReadSlice('\n')
+		total += int64(len(chunk))
+
+		// discard the rest of any line which is too long
+		if !discarding {
+			if len(bytes.TrimRight(line, "\r\n"))+len(bytes.TrimRight(chunk, "\r\n")) > r.options.MaxLineSize {
+				discarding = true
+				line = nil
+				r.handleError(ctx, xerrors.Newf(xlog.DataReadError,
+					"record exceeds maximum line size of %d bytes", r.options.MaxLineSize), nil)
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err != nil {
+			if state != nil {
+				state.partial, state.discarding = line, discarding
+			} else if !discarding {
+				r.replay(ctx, line)
+			}
+			return total, err
+		}
+		if !discarding {
+			r.replay(ctx, line)
+		}
+		line = nil
+		discarding = false
+	}
+	return total, nil
+}
+
+// replay parses the given line and passes the resulting record to the handler.
+func (r *Relay) replay(ctx context.Context, line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	rec, err := xlog.JSONToRecord(line)
+	if err != nil {
+		r.handleError(ctx, err.WithAttr("line", string(line)), nil)
+		return
+	}
+	if !r.options.Handler.Enabled(ctx, rec.Level) {
+		return
+	}
+	if err := r.options.Handler.Handle(ctx, rec); err != nil {
+		r.handleError(ctx, err, &rec)
+	}
+}
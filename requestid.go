@@ -0,0 +1,368 @@
+package xlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// RequestIDFormatSnowflake generates 64-bit Snowflake IDs written as decimal numbers (eg: "1541815603606036480"),
+	// which hold the milliseconds since the Snowflake epoch, a node number and a sequence number.
+	RequestIDFormatSnowflake RequestIDFormat = "snowflake"
+
+	// RequestIDFormatULID generates ULIDs (eg: "01ARZ3NDEKTSV4RRFFQ69G5FAV"), which hold the milliseconds since the
+	// Unix epoch and 80 random bits written as 26 characters of Crockford's base32.
+	RequestIDFormatULID RequestIDFormat = "ulid"
+
+	// RequestIDFormatUUIDv7 generates version 7 UUIDs as defined by RFC 9562 (eg:
+	// "01890a5d-ac96-774b-bcce-b302099a8057"), which hold the milliseconds since the Unix epoch and 74 random bits.
+	RequestIDFormatUUIDv7 RequestIDFormat = "uuidv7"
+
+	// RequestIDFormatXID generates xids (eg: "9m4e2mr0ui3e8a215n4g"), which hold the seconds since the Unix epoch,
+	// a machine identifier, the process ID and a counter written as 20 characters of lowercase base32hex.
+	RequestIDFormatXID RequestIDFormat = "xid"
+
+	// crockfordAlphabet is the alphabet of Crockford's base32 used to write ULIDs.
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	// snowflakeEpoch is the Snowflake epoch (2010-11-04T01:42:54.657Z) in milliseconds since the Unix epoch.
+	snowflakeEpoch = 1288834974657
+
+	// snowflakeMaxNode is the highest node number of a Snowflake ID.
+	snowflakeMaxNode = 1<<10 - 1
+
+	// snowflakeMaxSequence is the highest sequence number of a Snowflake ID.
+	snowflakeMaxSequence = 1<<12 - 1
+)
+
+var (
+	// DefaultRequestIDKey is the default key of the attribute which holds the request ID of a record.
+	//
+	// This value is used when the key in [RequestIDHandlerOptions] is empty and by [RequestIDAttr].
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RequestIDHandlerOptions
+	DefaultRequestIDKey = "request_id"
+
+	// _requestIDGenerator holds the generator designated using [SetRequestIDGenerator], if any.
+	_requestIDGenerator atomic.Pointer[RequestIDFn]
+
+	// _uuidv7Generator holds the generator used by [NewRequestID] when no generator has been designated.
+	_uuidv7Generator = newUUIDv7Generator(ClockFunc(func() time.Time { return DefaultClock.Now() }))
+
+	// xidEncoding is the encoding used to write xids.
+	xidEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+)
+
+// RequestIDFn is a function that's called to generate a new request ID.
+//
+// The function must be safe to call concurrently.
+type RequestIDFn func() string
+
+// RequestIDFormat is the format of the IDs generated by a [RequestIDFn] returned by [NewRequestIDGenerator].
+type RequestIDFormat string
+
+// RequestIDHandlerOptions holds the options for the handler returned by [NewRequestIDHandler].
+type RequestIDHandlerOptions struct {
+	// Key is the key of the attribute which holds the request ID.
+	//
+	// The default behavior is defined by the default request ID key setting defined in the package.
+	Key string
+}
+
+// RequestIDOptions holds the options for [NewRequestIDGenerator].
+type RequestIDOptions struct {
+	// Clock is the clock used to get the time held by each ID.
+	//
+	// The default behavior is to use [DefaultClock].
+	Clock Clock
+
+	// Format is the format of the IDs.
+	//
+	// The default behavior is to generate IDs in the [RequestIDFormatUUIDv7] format.
+	Format RequestIDFormat
+
+	// Node is the node number (0 to 1023) held by Snowflake IDs, which must be unique among the processes generating
+	// IDs at the same time.
+	//
+	// The default behavior is to derive the node number from the hostname and process ID, which may collide with that
+	// of another process.
+	Node *int
+}
+
+// requestIDCtxKey is just a key for storing a request ID in a context.
+type requestIDCtxKey struct{}
+
+// requestIDHandler is the [slog.Handler] returned by [NewRequestIDHandler].
+type requestIDHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	key string // key of the request ID attribute
+}
+
+// ensure [requestIDHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &requestIDHandler{}
+
+// snowflakeGenerator holds the state of a Snowflake ID generator.
+type snowflakeGenerator struct {
+	clock    Clock      // clock used to get the time
+	last     int64      // milliseconds since the Snowflake epoch of the last ID
+	mu       sync.Mutex // protects the time and sequence number
+	node     int64      // node number
+	sequence int64      // sequence number of the last ID
+}
+
+// AddRequestIDToContext adds the given request ID to the existing context and returns a new context.
+//
+// The ID is added to every record logged with the context, so IDs received from other services should only be stored
+// if the services are trusted.
+func AddRequestIDToContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// NewRequestID returns a new request ID using the generator designated using [SetRequestIDGenerator] or, if there
+// isn't one, a new version 7 UUID.
+func NewRequestID() string {
+	if fn := _requestIDGenerator.Load(); fn != nil {
+		return (*fn)()
+	}
+	return _uuidv7Generator()
+}
+
+// NewRequestIDGenerator returns a new [RequestIDFn] which generates IDs in the given format.
+//
+// All of the formats start with the time at which the ID was generated, so IDs generated by the same process sort in
+// roughly the order in which they were generated.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the format is unknown or the node number is out of range
+func NewRequestIDGenerator(options RequestIDOptions) (RequestIDFn, xerrors.Error) {
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	switch options.Format {
+	case "", RequestIDFormatUUIDv7:
+		return newUUIDv7Generator(options.Clock), nil
+	case RequestIDFormatSnowflake:
+		node := machineHash() % (snowflakeMaxNode + 1)
+		if options.Node != nil {
+			if *options.Node < 0 || *options.Node > snowflakeMaxNode {
+				return nil, xerrors.Newf(OptionsValidationError, "invalid request ID options: node: %d: must be "+
+					"between 0 and %d", *options.Node, snowflakeMaxNode).WithAttr("fields", []string{"node"})
+			}
+			node = uint32(*options.Node)
+		}
+		g := &snowflakeGenerator{
+			clock: options.Clock,
+			node:  int64(node),
+		}
+		return g.next, nil
+	case RequestIDFormatULID:
+		return newULIDGenerator(options.Clock), nil
+	case RequestIDFormatXID:
+		return newXIDGenerator(options.Clock), nil
+	default:
+		return nil, xerrors.Newf(OptionsValidationError, "invalid request ID options: format: %s: must be one of %s, "+
+			"%s, %s or %s", options.Format, RequestIDFormatSnowflake, RequestIDFormatULID, RequestIDFormatUUIDv7,
+			RequestIDFormatXID).WithAttr("fields", []string{"format"})
+	}
+}
+
+// NewRequestIDHandler returns a new [slog.Handler] which adds the request ID stored in the context passed to Handle
+// using [AddRequestIDToContext] to every record, so that every record logged while handling a request can be
+// correlated with it.
+//
+// The attribute is added at the top level of the record, outside of any groups, and records which already hold an
+// attribute with the same key at the top level or whose context holds no request ID are not changed.
+func NewRequestIDHandler(h slog.Handler, options RequestIDHandlerOptions) slog.Handler {
+	if options.Key == "" {
+		options.Key = DefaultRequestIDKey
+	}
+	return &requestIDHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		key:             options.Key,
+	}
+}
+
+// RequestIDAttr returns an attribute holding the given request ID whose key is the default request ID key defined in
+// the package.
+func RequestIDAttr(id string) slog.Attr {
+	return slog.String(DefaultRequestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID stored in the context or an empty string if there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIDCtxKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// SetRequestIDGenerator designates the generator used by [NewRequestID] and returns the previous generator, if any.
+//
+// Pass nil to go back to generating version 7 UUIDs.
+func SetRequestIDGenerator(fn RequestIDFn) RequestIDFn {
+	var prev *RequestIDFn
+	if fn == nil {
+		prev = _requestIDGenerator.Swap(nil)
+	} else {
+		prev = _requestIDGenerator.Swap(&fn)
+	}
+	if prev != nil {
+		return *prev
+	}
+	return nil
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *requestIDHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *requestIDHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the request ID stored in the context, combines the handler's attributes with the record's attributes
+// and passes the resulting record to the underlying handler.
+func (h *requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	id := RequestIDFromContext(ctx)
+	if id == "" && len(h.attrs) == 0 && len(h.groups) == 0 {
+		return h.handler.Handle(ctx, r)
+	}
+
+	if id == "" {
+		return h.handler.Handle(ctx, h.stamp(r))
+	}
+	return h.handler.Handle(ctx, h.stamp(r, slog.String(h.key, id)))
+}
+
+// Options returns the handler's options.
+func (h *requestIDHandler) Options() any {
+	return RequestIDHandlerOptions{
+		Key: h.key,
+	}
+}
+
+// Type returns the type of the handler.
+func (h *requestIDHandler) Type() string {
+	return "request_id"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// next returns the next Snowflake ID.
+//
+// If more IDs are generated within a millisecond than the sequence number can hold, or the clock goes backwards, the
+// time held by the IDs is moved ahead of the clock so that IDs remain unique.
+func (g *snowflakeGenerator) next() string {
+	now := g.clock.Now().UnixMilli() - snowflakeEpoch
+	g.mu.Lock()
+	if now > g.last {
+		g.last = now
+		g.sequence = 0
+	} else if g.sequence++; g.sequence > snowflakeMaxSequence {
+		g.last++
+		g.sequence = 0
+	}
+	id := g.last<<22 | g.node<<12 | g.sequence
+	g.mu.Unlock()
+	return strconv.FormatInt(id, 10)
+}
+
+// machineHash returns a hash of the hostname and process ID which identifies the process.
+func machineHash() uint32 {
+	host, _ := os.Hostname()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	return h.Sum32() ^ uint32(os.Getpid())
+}
+
+// newULIDGenerator returns a [RequestIDFn] which generates ULIDs using the given clock.
+func newULIDGenerator(clock Clock) RequestIDFn {
+	return func() string {
+		var id [16]byte
+		binary.BigEndian.PutUint64(id[:8], uint64(clock.Now().UnixMilli())<<16)
+		_, _ = rand.Read(id[6:])
+
+		// write the 128 bits as 26 characters of 5 bits each, the first of which only holds 3 bits
+		hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+		var buf [26]byte
+		for i := len(buf) - 1; i >= 0; i-- {
+			buf[i] = crockfordAlphabet[lo&31]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(buf[:])
+	}
+}
+
+// newUUIDv7Generator returns a [RequestIDFn] which generates version 7 UUIDs using the given clock.
+func newUUIDv7Generator(clock Clock) RequestIDFn {
+	return func() string {
+		var id [16]byte
+		binary.BigEndian.PutUint64(id[:8], uint64(clock.Now().UnixMilli())<<16)
+		_, _ = rand.Read(id[6:])
+		id[6] = id[6]&0x0f | 0x70
+		id[8] = id[8]&0x3f | 0x80
+
+		var buf [36]byte
+		hex.Encode(buf[0:8], id[0:4])
+		buf[8] = '-'
+		hex.Encode(buf[9:13], id[4:6])
+		buf[13] = '-'
+		hex.Encode(buf[14:18], id[6:8])
+		buf[18] = '-'
+		hex.Encode(buf[19:23], id[8:10])
+		buf[23] = '-'
+		hex.Encode(buf[24:], id[10:])
+		return string(buf[:])
+	}
+}
+
+// newXIDGenerator returns a [RequestIDFn] which generates xids using the given clock.
+func newXIDGenerator(clock Clock) RequestIDFn {
+	machine := machineHash()
+	pid := uint16(os.Getpid())
+	var counter atomic.Uint32
+	var seed [4]byte
+	_, _ = rand.Read(seed[:])
+	counter.Store(binary.BigEndian.Uint32(seed[:]))
+	return func() string {
+		var id [12]byte
+		binary.BigEndian.PutUint32(id[0:4], uint32(clock.Now().Unix()))
+		id[4], id[5], id[6] = byte(machine>>16), byte(machine>>8), byte(machine)
+		binary.BigEndian.PutUint16(id[7:9], pid)
+		n := counter.Add(1)
+		id[9], id[10], id[11] = byte(n>>16), byte(n>>8), byte(n)
+		return xidEncoding.EncodeToString(id[:])
+	}
+}
@@ -0,0 +1,206 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// requestLogCtxKey is just a key for storing a request log builder in a context.
+type requestLogCtxKey struct{}
+
+// RequestLogBuilder accumulates attributes, timings and counters over the life of a request and logs them as a
+// single canonical record once the request completes, instead of many scattered records.
+//
+// Store the builder in the request's context using [AddRequestLogToContext] so that code handling the request can
+// add to it using [RequestLogFromContext]. All methods are safe to call concurrently and on a nil builder, in which
+// case they do nothing, so callers never need to check whether a builder is present.
+type RequestLogBuilder struct {
+	// unexported variables
+	attrs    []slog.Attr              // attributes, in the order they were first added
+	counters []string                 // counter names, in the order they were first added
+	counts   map[string]int64         // counter values
+	emitted  bool                     // whether or not the record has been logged
+	level    slog.Level               // level of the record
+	message  string                   // message of the record
+	mu       sync.Mutex               // protects the fields in the struct
+	start    time.Time                // time at which the request started
+	timers   []string                 // timing names, in the order they were first added
+	timings  map[string]time.Duration // accumulated timings
+}
+
+// AddRequestLogToContext adds the given request log builder to the existing context and returns a new context.
+func AddRequestLogToContext(ctx context.Context, b *RequestLogBuilder) context.Context {
+	return context.WithValue(ctx, requestLogCtxKey{}, b)
+}
+
+// NewRequestLogBuilder creates a new [RequestLogBuilder] for a request starting now, whose record is logged at
+// [slog.LevelInfo] with the given message unless the level is raised.
+func NewRequestLogBuilder(msg string) *RequestLogBuilder {
+	return &RequestLogBuilder{
+		counts:  map[string]int64{},
+		level:   slog.LevelInfo,
+		message: msg,
+		start:   DefaultClock.Now(),
+		timings: map[string]time.Duration{},
+	}
+}
+
+// RequestLogFromContext returns the [RequestLogBuilder] stored in the context or nil if there isn't one.
+func RequestLogFromContext(ctx context.Context) *RequestLogBuilder {
+	if b, ok := ctx.Value(requestLogCtxKey{}).(*RequestLogBuilder); ok {
+		return b
+	}
+	return nil
+}
+
+// Add adds attributes, given as alternating keys and values in the same way as [slog.Logger.Log], to the record.
+//
+// An attribute with the same key as an existing attribute replaces it.
+func (b *RequestLogBuilder) Add(args ...any) {
+	if b == nil || len(args) == 0 {
+		return
+	}
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	b.AddAttrs(attrs...)
+}
+
+// AddAttrs adds the given attributes to the record.
+//
+// An attribute with the same key as an existing attribute replaces it.
+func (b *RequestLogBuilder) AddAttrs(attrs ...slog.Attr) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, attr := range attrs {
+		if i := slices.IndexFunc(b.attrs, func(a slog.Attr) bool { return a.Key == attr.Key }); i >= 0 {
+			b.attrs[i] = attr
+			continue
+		}
+		b.attrs = append(b.attrs, attr)
+	}
+}
+
+// Count adds the given delta to the counter with the given name (eg: the number of database queries).
+func (b *RequestLogBuilder) Count(name string, delta int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.counts[name]; !ok {
+		b.counters = append(b.counters, name)
+	}
+	b.counts[name] += delta
+}
+
+// Emit logs the canonical record using the given logger, unless it has already been logged.
+//
+// The record holds the attributes added to the builder followed by the total duration of the request in the
+// "duration" attribute, the timings in the "timings" group and the counters in the "counters" group. If logger is
+// nil, the default logger is used.
+func (b *RequestLogBuilder) Emit(ctx context.Context, logger *slog.Logger) error {
+	if b == nil {
+		return nil
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r, ok := b.record()
+	if !ok || !logger.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return logger.Handler().Handle(ctx, r)
+}
+
+// RaiseLevel raises the level of the record to the given level if it is higher than the current level (eg: to
+// [slog.LevelWarn] when a request is retried).
+func (b *RequestLogBuilder) RaiseLevel(level slog.Level) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.level = max(b.level, level)
+}
+
+// SetError records the error which caused the request to fail in the "error" attribute and raises the level of the
+// record to [slog.LevelError].
+func (b *RequestLogBuilder) SetError(err error) {
+	if b == nil || err == nil {
+		return
+	}
+	b.AddAttrs(slog.String("error", err.Error()))
+	b.RaiseLevel(slog.LevelError)
+}
+
+// StartTimer starts timing the step with the given name and returns a function which stops the timer and adds the
+// elapsed time to the step's timing.
+func (b *RequestLogBuilder) StartTimer(name string) func() {
+	if b == nil {
+		return func() {}
+	}
+	start := DefaultClock.Now()
+	return func() {
+		b.Time(name, DefaultClock.Now().Sub(start))
+	}
+}
+
+// Time adds the given duration to the timing of the step with the given name (eg: the time spent in the database).
+func (b *RequestLogBuilder) Time(name string, d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.timings[name]; !ok {
+		b.timers = append(b.timers, name)
+	}
+	b.timings[name] += d
+}
+
+// record builds the canonical record from a snapshot of the builder, marking it as logged, and returns it along with
+// whether or not it had not been logged yet.
+//
+// The caller is recorded as the caller of the function calling [RequestLogBuilder.Emit].
+func (b *RequestLogBuilder) record() (slog.Record, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.emitted {
+		return slog.Record{}, false
+	}
+	b.emitted = true
+
+	now := DefaultClock.Now()
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip [runtime.Callers, record, Emit]
+	r := slog.NewRecord(now, b.level, b.message, pcs[0])
+	r.AddAttrs(b.attrs...)
+	r.AddAttrs(slog.Duration("duration", now.Sub(b.start)))
+	if len(b.timers) > 0 {
+		timings := make([]slog.Attr, 0, len(b.timers))
+		for _, name := range b.timers {
+			timings = append(timings, slog.Duration(name, b.timings[name]))
+		}
+		r.AddAttrs(slog.Attr{Key: "timings", Value: slog.GroupValue(timings...)})
+	}
+	if len(b.counters) > 0 {
+		counters := make([]slog.Attr, 0, len(b.counters))
+		for _, name := range b.counters {
+			counters = append(counters, slog.Int64(name, b.counts[name]))
+		}
+		r.AddAttrs(slog.Attr{Key: "counters", Value: slog.GroupValue(counters...)})
+	}
+	return r, true
+}
@@ -0,0 +1,197 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+const (
+	// RetentionKey is the reserved attribute key which holds the retention class of a record.
+	//
+	// Handlers which understand retention classes (eg: a [RetentionRouter]) look for the key at the top level of a
+	// record's attributes or of the attributes added to the handler using WithAttrs.
+	RetentionKey = "retention"
+
+	// RetentionLong is the retention class for records which must be kept for a long time (eg: audit trails).
+	RetentionLong = "long"
+
+	// RetentionShort is the retention class for records which should be deleted quickly (eg: debugging output or
+	// records holding personal data).
+	RetentionShort = "short"
+
+	// RetentionStandard is the retention class for records which are kept according to the default policy.
+	RetentionStandard = "standard"
+)
+
+// retentionCtxKey is just a key for storing a retention class in a context.
+type retentionCtxKey struct{}
+
+// RetentionRouter is a [slog.Handler] which passes each record to the handler for its retention class so that
+// different classes of data can be retained or deleted according to their own policies.
+//
+// The retention class of a record is determined by [RecordRetention]. Records whose class has no handler are passed to
+// the fallback handler, if there is one, or discarded otherwise.
+type RetentionRouter struct {
+	// unexported variables
+	fallback  slog.Handler            // handler for records without a matching class
+	grouped   bool                    // whether or not a group has been opened using WithGroup
+	retention string                  // retention class added using WithAttrs, if any
+	routes    map[string]slog.Handler // handlers for each retention class
+}
+
+// ensure [RetentionRouter] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &RetentionRouter{}
+
+// AddRetentionToContext adds the given retention class to the existing context and returns a new context.
+//
+// Records logged with the context are given the class unless they hold their own retention attribute.
+func AddRetentionToContext(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, retentionCtxKey{}, class)
+}
+
+// NewRetentionRouter returns a new [RetentionRouter] which passes records to the handler for their retention class
+// or to the fallback handler, which may be nil.
+func NewRetentionRouter(routes map[string]slog.Handler, fallback slog.Handler) *RetentionRouter {
+	return &RetentionRouter{
+		fallback: fallback,
+		routes:   maps.Clone(routes),
+	}
+}
+
+// RecordRetention returns the retention class of the given record.
+//
+// The class is taken from the first of the following which is set: the retention attribute at the top level of the
+// record, the given handler class (ie: the class added to the handler using WithAttrs) or the class stored in the
+// context using [AddRetentionToContext]. An empty string is returned if none of them are set.
+func RecordRetention(ctx context.Context, r slog.Record, handlerClass string) string {
+	var class string
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == RetentionKey {
+			class = attr.Value.Resolve().String()
+			return false
+		}
+		return true
+	})
+	if class != "" {
+		return class
+	}
+	if handlerClass != "" {
+		return handlerClass
+	}
+	return RetentionFromContext(ctx)
+}
+
+// RetentionFromAttrs returns the value of the last retention attribute in the given attributes or an empty string if
+// there isn't one.
+//
+// Handlers which understand retention classes should call this function from WithAttrs, before any group has been
+// opened, to remember the class added to the handler.
+func RetentionFromAttrs(attrs []slog.Attr) string {
+	var class string
+	for _, attr := range attrs {
+		if attr.Key == RetentionKey {
+			class = attr.Value.Resolve().String()
+		}
+	}
+	return class
+}
+
+// RetentionFromContext returns the retention class stored in the context or an empty string if there isn't one.
+func RetentionFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if class, ok := ctx.Value(retentionCtxKey{}).(string); ok {
+		return class
+	}
+	return ""
+}
+
+// ChildHandlers returns the handlers for each retention class, sorted by class, followed by the fallback handler.
+func (h *RetentionRouter) ChildHandlers() []slog.Handler {
+	children := make([]slog.Handler, 0, len(h.routes)+1)
+	for _, class := range slices.Sorted(maps.Keys(h.routes)) {
+		children = append(children, h.routes[class])
+	}
+	if h.fallback != nil {
+		children = append(children, h.fallback)
+	}
+	return children
+}
+
+// Enabled returns true if any of the handlers is enabled for the given level.
+func (h *RetentionRouter) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, child := range h.ChildHandlers() {
+		if child.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes the record to the handler for its retention class, if that handler is enabled for the record.
+func (h *RetentionRouter) Handle(ctx context.Context, r slog.Record) error {
+	handler, ok := h.routes[RecordRetention(ctx, r, h.retention)]
+	if !ok {
+		handler = h.fallback
+	}
+	if handler == nil || !handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the handlers for each retention class.
+func (h *RetentionRouter) Options() any {
+	return maps.Clone(h.routes)
+}
+
+// Type returns the type of the handler.
+func (h *RetentionRouter) Type() string {
+	return "retention_router"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *RetentionRouter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := h.derive(func(child slog.Handler) slog.Handler {
+		return child.WithAttrs(attrs)
+	})
+	if class := RetentionFromAttrs(attrs); class != "" && !h.grouped {
+		clone.retention = class
+	}
+	return clone
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *RetentionRouter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := h.derive(func(child slog.Handler) slog.Handler {
+		return child.WithGroup(name)
+	})
+	clone.grouped = true
+	return clone
+}
+
+// derive returns a copy of the router whose handlers have been derived using the given function.
+func (h *RetentionRouter) derive(fn func(slog.Handler) slog.Handler) *RetentionRouter {
+	clone := &RetentionRouter{
+		grouped:   h.grouped,
+		retention: h.retention,
+		routes:    make(map[string]slog.Handler, len(h.routes)),
+	}
+	for class, child := range h.routes {
+		clone.routes[class] = fn(child)
+	}
+	if h.fallback != nil {
+		clone.fallback = fn(h.fallback)
+	}
+	return clone
+}
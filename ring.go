@@ -0,0 +1,106 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+)
+
+// RingBuffer is a [slog.Handler] which keeps the most recent records it handles in memory so that they can be
+// dumped later (eg: in a crash report written by [CrashReportHook]).
+//
+// Add it to a fanout alongside the handlers which actually write the records. Handlers derived using WithAttrs or
+// WithGroup share the same buffer with the handler they were derived from.
+type RingBuffer struct {
+	// unexported variables
+	attrs  []slog.Attr      // immutable attributes for the handler, nested within their groups
+	groups []string         // immutable groups for the handler
+	level  slog.Leveler     // minimum level of records to keep
+	state  *ringBufferState // buffer shared by all derived handlers
+}
+
+// ringBufferState holds the records kept by a [RingBuffer] and all of the handlers derived from it.
+type ringBufferState struct {
+	mu      sync.Mutex    // protects the fields below
+	next    int           // index at which the next record is stored
+	records []slog.Record // stored records, which wrap around once the buffer is full
+	size    int           // maximum number of records to keep
+}
+
+// NewRingBuffer creates a new [RingBuffer] which keeps up to the given number of the most recent records at or above
+// the given level.
+//
+// If level is nil, records of all levels are kept. If size is less than 1, a single record is kept.
+func NewRingBuffer(size int, level slog.Leveler) *RingBuffer {
+	if level == nil {
+		level = slog.Level(math.MinInt)
+	}
+	return &RingBuffer{
+		level: level,
+		state: &ringBufferState{
+			size: max(size, 1),
+		},
+	}
+}
+
+// Enabled returns true if the record is at or above the buffer's minimum level.
+func (b *RingBuffer) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= b.level.Level()
+}
+
+// Handle stores the record in the buffer, replacing the oldest record if the buffer is full.
+func (b *RingBuffer) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(appendGroupedAttrs(b.attrs, b.groups, recordAttrs(r))...)
+
+	s := b.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) < s.size {
+		s.records = append(s.records, record)
+	} else {
+		s.records[s.next] = record
+	}
+	s.next = (s.next + 1) % s.size
+	return nil
+}
+
+// Records returns a copy of the records currently held in the buffer, from oldest to newest.
+func (b *RingBuffer) Records() []slog.Record {
+	s := b.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) < s.size {
+		return slices.Clone(s.records)
+	}
+	return slices.Concat(s.records[s.next:], s.records[:s.next])
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (b *RingBuffer) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return b
+	}
+	return &RingBuffer{
+		attrs:  appendGroupedAttrs(b.attrs, b.groups, attrs),
+		groups: b.groups,
+		level:  b.level,
+		state:  b.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (b *RingBuffer) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return b
+	}
+	return &RingBuffer{
+		attrs:  b.attrs,
+		groups: append(slices.Clip(b.groups), name),
+		level:  b.level,
+		state:  b.state,
+	}
+}
@@ -0,0 +1,184 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultRuntimeStatsInterval is the default interval at which runtime statistics are logged.
+	//
+	// This value is used when the interval in [RuntimeStatsOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RuntimeStatsOptions
+	DefaultRuntimeStatsInterval = time.Minute
+
+	// DefaultRuntimeStatsMessage is the default message of the records holding runtime statistics.
+	//
+	// This value is used when the message in [RuntimeStatsOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#RuntimeStatsOptions
+	DefaultRuntimeStatsMessage = "runtime stats"
+)
+
+// RuntimeStatsOptions holds the options for a [RuntimeStatsEmitter].
+type RuntimeStatsOptions struct {
+	// Interval is the interval at which runtime statistics are logged.
+	//
+	// The default behavior is defined by the default runtime stats interval setting defined in the package.
+	Interval time.Duration
+
+	// Level is the level at which runtime statistics are logged.
+	//
+	// The default behavior is to log statistics at [slog.LevelInfo].
+	Level slog.Leveler
+
+	// Logger is the logger through which runtime statistics are logged.
+	//
+	// The default behavior is to use [slog.Default] at the time each record is logged.
+	Logger *slog.Logger
+
+	// Message is the message of the records holding runtime statistics.
+	//
+	// The default behavior is defined by the default runtime stats message setting defined in the package.
+	Message string
+}
+
+// RuntimeStatsEmitter periodically logs a record holding statistics about the Go runtime, which provides lightweight
+// telemetry for environments without a metrics stack.
+//
+// All methods are safe to call concurrently.
+type RuntimeStatsEmitter struct {
+	// unexported variables
+	lastNumGC uint32              // number of garbage collections when statistics were last logged
+	mu        sync.Mutex          // protects the number of garbage collections
+	options   RuntimeStatsOptions // emitter options
+}
+
+// NewRuntimeStatsEmitter creates a new [RuntimeStatsEmitter] with the given options.
+//
+// Call [RuntimeStatsEmitter.Run] to start logging statistics.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the interval is negative
+func NewRuntimeStatsEmitter(options RuntimeStatsOptions) (*RuntimeStatsEmitter, xerrors.Error) {
+	if options.Interval < 0 {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid runtime stats options: interval: %s: must not be "+
+			"negative", options.Interval).WithAttr("fields", []string{"interval"})
+	}
+	if options.Interval == 0 {
+		options.Interval = DefaultRuntimeStatsInterval
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	if options.Message == "" {
+		options.Message = DefaultRuntimeStatsMessage
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return &RuntimeStatsEmitter{
+		lastNumGC: stats.NumGC,
+		options:   options,
+	}, nil
+}
+
+// Emit logs a single record holding the current runtime statistics.
+//
+// The record holds the following attributes:
+//   - "goroutines": the number of goroutines
+//   - "heap": a group holding the bytes allocated and in use by heap objects ("alloc"), the bytes of heap memory
+//     obtained from the OS ("sys"), the bytes of heap memory returned to the OS ("released") and the number of
+//     allocated heap objects ("objects")
+//   - "gc": a group holding the number of completed garbage collections ("count"), the number of collections and the
+//     longest pause since statistics were last logged ("recent_count" and "max_pause"), the most recent pause
+//     ("last_pause"), the total of all pauses ("pause_total") and the fraction of CPU time used by the collector
+//     ("cpu_fraction")
+//   - "open_fds": the number of open file descriptors, which is only available on systems with a /proc file system
+//
+// Reading the statistics briefly stops the world, so the interval should not be too short.
+func (e *RuntimeStatsEmitter) Emit(ctx context.Context) {
+	logger := e.options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := e.options.Level.Level()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.LogAttrs(ctx, level, e.options.Message, e.snapshot()...)
+}
+
+// Run logs the runtime statistics at the configured interval until the context is canceled.
+//
+// This function blocks, so it is typically called in its own goroutine.
+func (e *RuntimeStatsEmitter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		e.Emit(ctx)
+	}
+}
+
+// snapshot reads the current runtime statistics and returns them as attributes.
+func (e *RuntimeStatsEmitter) snapshot() []slog.Attr {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	// find the longest pause since the last snapshot, which is limited to the pauses kept by the runtime
+	e.mu.Lock()
+	recent := stats.NumGC - e.lastNumGC
+	e.lastNumGC = stats.NumGC
+	e.mu.Unlock()
+	var maxPause, lastPause uint64
+	if stats.NumGC > 0 {
+		lastPause = stats.PauseNs[(stats.NumGC+255)%256]
+	}
+	for i := uint32(0); i < min(recent, 256); i++ {
+		maxPause = max(maxPause, stats.PauseNs[(stats.NumGC-i+255)%256])
+	}
+
+	attrs := []slog.Attr{
+		slog.Int("goroutines", runtime.NumGoroutine()),
+		slog.Group("heap",
+			slog.Uint64("alloc", stats.HeapAlloc),
+			slog.Uint64("sys", stats.HeapSys),
+			slog.Uint64("released", stats.HeapReleased),
+			slog.Uint64("objects", stats.HeapObjects),
+		),
+		slog.Group("gc",
+			slog.Uint64("count", uint64(stats.NumGC)),
+			slog.Uint64("recent_count", uint64(recent)),
+			slog.Duration("max_pause", time.Duration(maxPause)),
+			slog.Duration("last_pause", time.Duration(lastPause)),
+			slog.Duration("pause_total", time.Duration(stats.PauseTotalNs)),
+			slog.Float64("cpu_fraction", stats.GCCPUFraction),
+		),
+	}
+	if entries, err := os.ReadDir("/proc/self/fd"); err == nil {
+		// the directory itself is open while it is being read
+		attrs = append(attrs, slog.Int("open_fds", max(len(entries)-1, 0)))
+	}
+	return attrs
+}
@@ -0,0 +1,326 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultAdaptiveSamplerDecreaseFactor is the default factor by which the sampling rate of an [AdaptiveSampler]
+	// is multiplied when the sink is under pressure.
+	//
+	// This value is used when the decrease factor in [AdaptiveSamplerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AdaptiveSamplerOptions
+	DefaultAdaptiveSamplerDecreaseFactor = 0.5
+
+	// DefaultAdaptiveSamplerIncreaseStep is the default amount by which the sampling rate of an [AdaptiveSampler] is
+	// increased after each interval in which the sink is healthy.
+	//
+	// This value is used when the increase step in [AdaptiveSamplerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AdaptiveSamplerOptions
+	DefaultAdaptiveSamplerIncreaseStep = 0.1
+
+	// DefaultAdaptiveSamplerInterval is the default minimum time between changes to the sampling rate of an
+	// [AdaptiveSampler].
+	//
+	// This value is used when the interval in [AdaptiveSamplerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AdaptiveSamplerOptions
+	DefaultAdaptiveSamplerInterval = 5 * time.Second
+
+	// DefaultAdaptiveSamplerMinRate is the default lowest sampling rate of an [AdaptiveSampler].
+	//
+	// This value is used when the min rate in [AdaptiveSamplerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#AdaptiveSamplerOptions
+	DefaultAdaptiveSamplerMinRate = 0.01
+)
+
+// AdaptiveSamplerOptions holds the options for an [AdaptiveSampler].
+type AdaptiveSamplerOptions struct {
+	// AlwaysLevel is the minimum level of records which are never sampled.
+	//
+	// The default behavior is to never sample records at [slog.LevelError] or above.
+	AlwaysLevel slog.Leveler
+
+	// Clock is the clock used to decide when the sampling rate may change.
+	//
+	// The default behavior is to use [DefaultClock].
+	Clock Clock
+
+	// DecreaseFactor is the factor (between 0 and 1) by which the sampling rate is multiplied when the sink is under
+	// pressure.
+	//
+	// The default behavior is defined by the default decrease factor setting defined in the package.
+	DecreaseFactor float64
+
+	// IncreaseStep is the amount by which the sampling rate is increased after each interval in which the sink is
+	// healthy, until all records are kept again.
+	//
+	// The default behavior is defined by the default increase step setting defined in the package.
+	IncreaseStep float64
+
+	// Interval is the minimum time between changes to the sampling rate.
+	//
+	// Errors observed within an interval of the last change are counted as a single signal so that a burst of
+	// failures does not immediately reduce the rate to the minimum.
+	//
+	// The default behavior is defined by the default interval setting defined in the package.
+	Interval time.Duration
+
+	// IsBackpressure is called for each error returned by the underlying handler or passed to [AdaptiveSampler.Observe]
+	// to decide whether or not it means the sink is under pressure.
+	//
+	// The default behavior is to use [IsBackpressureError].
+	IsBackpressure func(err error) bool
+
+	// MaxQueueDepth is the queue depth above which the sink is considered to be under pressure.
+	//
+	// This option is ignored if QueueDepth is nil.
+	MaxQueueDepth int
+
+	// MinRate is the lowest sampling rate (between 0 and 1) the sampler will reduce to.
+	//
+	// The default behavior is defined by the default min rate setting defined in the package.
+	MinRate float64
+
+	// Name is the name of the sampler used when notifying subscribers of records dropped by sampling.
+	Name string
+
+	// QueueDepth is called once per interval to get the number of records waiting to be sent to the sink, such as
+	// the pending records of a [Pipeline].
+	//
+	// The default behavior is to ignore the queue depth.
+	QueueDepth func() int
+}
+
+// HTTPStatusError is an error which holds the status of an HTTP response returned by a sink.
+//
+// Handlers which send records over HTTP should wrap this error in the errors they return for failed responses so
+// that callers such as an [AdaptiveSampler] can react to the status.
+type HTTPStatusError struct {
+	// Status is the status line of the response (eg: "429 Too Many Requests").
+	Status string
+
+	// StatusCode is the status code of the response.
+	StatusCode int
+}
+
+// Error returns the status of the response.
+func (e *HTTPStatusError) Error() string {
+	return e.Status
+}
+
+// AdaptiveSampler is a [slog.Handler] which samples records passed to an underlying handler at a rate which adapts to
+// the health of the sink.
+//
+// All records are kept while the sink is healthy. Whenever the sink signals that it is under pressure, either by
+// returning an error (eg: an HTTP 429 or 5xx response) or by its queue growing beyond a limit, the rate is multiplied
+// by the decrease factor, down to the minimum rate. After each interval without any pressure, the rate is increased
+// again by the increase step. Records at or above the always level are never sampled.
+//
+// When the underlying handler is driven asynchronously by a [Pipeline], its errors never reach the sampler, so pass
+// them on using [AdaptiveSampler.ErrorHandler] in the [PipelineOptions] and set the queue depth from
+// [Pipeline.Stats]. Records dropped by sampling are reported to drop notification subscribers with the reason
+// [DropReasonAdaptiveSampling].
+type AdaptiveSampler struct {
+	// unexported variables
+	handler slog.Handler          // underlying handler
+	state   *adaptiveSamplerState // state shared by all derived handlers
+}
+
+// ensure [AdaptiveSampler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &AdaptiveSampler{}
+
+// adaptiveSamplerState holds the sampling rate shared by all handlers derived from the same sampler.
+type adaptiveSamplerState struct {
+	changed  time.Time              // time of the last change to the rate or health check
+	mu       sync.Mutex             // protects the fields below
+	options  AdaptiveSamplerOptions // immutable sampler options
+	pressure bool                   // whether or not pressure was observed since the last change
+	rate     float64                // current sampling rate
+}
+
+// IsBackpressureError returns true if the given error, or any error it wraps, is an [HTTPStatusError] with a
+// 429 (Too Many Requests) or 5xx status code.
+func IsBackpressureError(err error) bool {
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+}
+
+// NewAdaptiveSampler returns a new [AdaptiveSampler] which samples records passed to the given handler.
+func NewAdaptiveSampler(h slog.Handler, options AdaptiveSamplerOptions) *AdaptiveSampler {
+	if options.AlwaysLevel == nil {
+		options.AlwaysLevel = slog.LevelError
+	}
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	if options.DecreaseFactor <= 0 || options.DecreaseFactor >= 1 {
+		options.DecreaseFactor = DefaultAdaptiveSamplerDecreaseFactor
+	}
+	if options.IncreaseStep <= 0 {
+		options.IncreaseStep = DefaultAdaptiveSamplerIncreaseStep
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultAdaptiveSamplerInterval
+	}
+	if options.IsBackpressure == nil {
+		options.IsBackpressure = IsBackpressureError
+	}
+	if options.MinRate <= 0 || options.MinRate > 1 {
+		options.MinRate = DefaultAdaptiveSamplerMinRate
+	}
+	return &AdaptiveSampler{
+		handler: h,
+		state: &adaptiveSamplerState{
+			changed: options.Clock.Now(),
+			options: options,
+			rate:    1,
+		},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (s *AdaptiveSampler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{s.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (s *AdaptiveSampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.handler.Enabled(ctx, level)
+}
+
+// ErrorHandler returns an [ErrorHandlerFn] which passes each error to [AdaptiveSampler.Observe] before calling the
+// given function, if it is not nil.
+func (s *AdaptiveSampler) ErrorHandler(next ErrorHandlerFn) ErrorHandlerFn {
+	return func(ctx context.Context, err error, r *slog.Record) error {
+		s.Observe(err)
+		if next != nil {
+			return next(ctx, err, r)
+		}
+		return err
+	}
+}
+
+// Handle passes the record to the underlying handler if it is kept by sampling and observes any error returned.
+func (s *AdaptiveSampler) Handle(ctx context.Context, r slog.Record) error {
+	if !s.state.keep(r.Level) {
+		NotifyDropped(s.state.options.Name, "", DropReasonAdaptiveSampling, 1)
+		return nil
+	}
+	err := s.handler.Handle(ctx, r)
+	if err != nil {
+		s.Observe(err)
+	}
+	return err
+}
+
+// Observe reports an error returned by the sink, reducing the sampling rate if the error means the sink is under
+// pressure.
+func (s *AdaptiveSampler) Observe(err error) {
+	if err == nil || !s.state.options.IsBackpressure(err) {
+		return
+	}
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pressure = true
+	st.adjust()
+}
+
+// Options returns a copy of the sampler's options.
+func (s *AdaptiveSampler) Options() any {
+	return s.state.options
+}
+
+// Rate returns the current sampling rate, between the minimum rate and 1.
+func (s *AdaptiveSampler) Rate() float64 {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.adjust()
+	return st.rate
+}
+
+// Type returns the type of the handler.
+func (s *AdaptiveSampler) Type() string {
+	return "adaptive_sampler"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (s *AdaptiveSampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return s
+	}
+	return &AdaptiveSampler{
+		handler: s.handler.WithAttrs(attrs),
+		state:   s.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (s *AdaptiveSampler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return &AdaptiveSampler{
+		handler: s.handler.WithGroup(name),
+		state:   s.state,
+	}
+}
+
+// adjust changes the sampling rate once per interval based on the pressure observed since the last change.
+//
+// The caller must hold the lock.
+func (st *adaptiveSamplerState) adjust() {
+	now := st.options.Clock.Now()
+	if now.Sub(st.changed) < st.options.Interval {
+		return
+	}
+	if st.options.QueueDepth != nil && st.options.QueueDepth() > st.options.MaxQueueDepth {
+		st.pressure = true
+	}
+	if st.pressure {
+		st.rate = max(st.options.MinRate, st.rate*st.options.DecreaseFactor)
+	} else {
+		st.rate = min(1, st.rate+st.options.IncreaseStep)
+	}
+	st.changed = now
+	st.pressure = false
+}
+
+// keep returns whether or not a record at the given level should be kept.
+func (st *adaptiveSamplerState) keep(level slog.Level) bool {
+	if level >= st.options.AlwaysLevel.Level() {
+		return true
+	}
+	st.mu.Lock()
+	st.adjust()
+	rate := st.rate
+	st.mu.Unlock()
+	return rate >= 1 || rand.Float64() < rate
+}
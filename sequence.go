@@ -0,0 +1,156 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// DefaultInstanceIDKey is the default key of the attribute which holds the instance ID of the process that logged
+	// a record.
+	//
+	// This value is used when the instance ID key in [SequenceHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#SequenceHandlerOptions
+	DefaultInstanceIDKey = "instance_id"
+
+	// DefaultSequenceKey is the default key of the attribute which holds the sequence number of a record.
+	//
+	// This value is used when the sequence key in [SequenceHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#SequenceHandlerOptions
+	DefaultSequenceKey = "seq"
+)
+
+var (
+	// _instanceID holds the random identifier generated for the running process.
+	_instanceID = sync.OnceValue(NewBatchID)
+)
+
+// SequenceHandlerOptions holds the options for a [SequenceHandler].
+type SequenceHandlerOptions struct {
+	// InstanceID identifies the process (or other source) whose sequence numbers are stamped on records.
+	//
+	// The default behavior is to use the random identifier returned by [InstanceID].
+	InstanceID string
+
+	// InstanceIDKey is the key of the attribute which holds the instance ID.
+	//
+	// The default behavior is defined by the default instance ID key setting defined in the package.
+	InstanceIDKey string
+
+	// SequenceKey is the key of the attribute which holds the sequence number.
+	//
+	// The default behavior is defined by the default sequence key setting defined in the package.
+	SequenceKey string
+}
+
+// SequenceStats holds the counters for a [SequenceHandler].
+type SequenceStats struct {
+	// InstanceID is the instance ID stamped on records.
+	InstanceID string
+
+	// Sequence is the sequence number stamped on the most recent record, or 0 if no records have been handled.
+	Sequence uint64
+}
+
+// SequenceHandler is an [slog.Handler] which stamps each record with a monotonically increasing sequence number and
+// the instance ID of the process, so that downstream consumers can detect records which were lost or reordered by
+// asynchronous pipelines.
+//
+// Sequence numbers start at 1 and are shared by the handler and every handler derived from it using WithAttrs or
+// WithGroup. A record is only given a number once it is passed to Handle, so a gap in the numbers received for an
+// instance means that records were lost after they were logged.
+type SequenceHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	options SequenceHandlerOptions // immutable handler options
+	seq     *atomic.Uint64         // sequence number of the most recent record, shared with derived handlers
+}
+
+// ensure [SequenceHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &SequenceHandler{}
+
+// InstanceID returns the random identifier generated for the running process, which is the same for every call.
+func InstanceID() string {
+	return _instanceID()
+}
+
+// NewSequenceHandler returns a new [SequenceHandler] which passes records to the given handler.
+//
+// The attributes are always added at the top level of a record, outside of any groups.
+func NewSequenceHandler(h slog.Handler, options SequenceHandlerOptions) *SequenceHandler {
+	if options.InstanceID == "" {
+		options.InstanceID = InstanceID()
+	}
+	if options.InstanceIDKey == "" {
+		options.InstanceIDKey = DefaultInstanceIDKey
+	}
+	if options.SequenceKey == "" {
+		options.SequenceKey = DefaultSequenceKey
+	}
+	return &SequenceHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		options:         options,
+		seq:             &atomic.Uint64{},
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *SequenceHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *SequenceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle stamps the record with the next sequence number and the instance ID and passes it to the underlying handler.
+func (h *SequenceHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(
+		slog.Uint64(h.options.SequenceKey, h.seq.Add(1)),
+		slog.String(h.options.InstanceIDKey, h.options.InstanceID),
+	)
+	record.AddAttrs(h.mergeAttrs(r)...)
+	return h.handler.Handle(ctx, record)
+}
+
+// Options returns the handler's options.
+func (h *SequenceHandler) Options() any {
+	return h.options
+}
+
+// Stats returns a snapshot of the handler's counters.
+func (h *SequenceHandler) Stats() SequenceStats {
+	return SequenceStats{
+		InstanceID: h.options.InstanceID,
+		Sequence:   h.seq.Load(),
+	}
+}
+
+// Type returns the type of the handler.
+func (h *SequenceHandler) Type() string {
+	return "sequence"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *SequenceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *SequenceHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
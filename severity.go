@@ -0,0 +1,292 @@
+package xlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// LevelFatal is the level for records describing errors from which the application cannot recover.
+	//
+	// Register an [ExitHook] for this level using [RegisterSeverityHook] to exit the application once such a record
+	// is logged.
+	LevelFatal = slog.Level(12)
+
+	// LevelPanic is the level for records logged just before the application panics or crashes.
+	LevelPanic = slog.Level(16)
+)
+
+var (
+	// exitFn is the function used by [ExitHook] to exit the application.
+	exitFn = os.Exit
+
+	// severityHookState holds the package-wide severity hooks.
+	severityHookState = &severityHooks{}
+)
+
+// SeverityHookFn is a function that's called when a record at or above the level for which it was registered is
+// handled by a handler returned by [NewSeverityHookHandler].
+//
+// The record is passed to the function after it has been passed to the underlying handler. You should not modify the
+// record in any way. If you need to make changes to it, use the [slog.Record.Clone] function to clone it first.
+type SeverityHookFn func(ctx context.Context, r slog.Record) error
+
+// severityHook holds the details of a hook registered using [RegisterSeverityHook].
+type severityHook struct {
+	fn    SeverityHookFn // function to call
+	id    int            // unique identifier used to unregister the hook
+	level slog.Level     // minimum level which triggers the hook
+}
+
+// severityHooks holds all of the registered severity hooks.
+type severityHooks struct {
+	hooks  []severityHook // registered hooks, in the order in which they were registered
+	mu     sync.RWMutex   // protects the fields in the struct
+	nextID int            // identifier to assign to the next hook
+}
+
+// severityHookHandler is the [slog.Handler] returned by [NewSeverityHookHandler].
+type severityHookHandler struct {
+	// unexported variables
+	handler slog.Handler // underlying handler
+}
+
+// ensure [severityHookHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &severityHookHandler{}
+
+// BellHook returns a [SeverityHookFn] which writes the terminal bell character to the given writer (eg: [os.Stderr])
+// to alert anyone watching the terminal.
+func BellHook(w io.Writer) SeverityHookFn {
+	return func(ctx context.Context, r slog.Record) error {
+		_, err := w.Write([]byte("\a"))
+		return err
+	}
+}
+
+// CloseHook returns a [SeverityHookFn] which closes the given handler if it implements [io.Closer] so that any
+// buffered records are written before the application exits.
+//
+// The handler should not be used after the hook is called.
+func CloseHook(h slog.Handler) SeverityHookFn {
+	return func(ctx context.Context, r slog.Record) error {
+		if closer, ok := h.(io.Closer); ok {
+			return closer.Close()
+		}
+		return nil
+	}
+}
+
+// CrashReportHook returns a [SeverityHookFn] which writes a crash report to the file at the given path.
+//
+// The report is a JSON document holding the time the report was written, the record which triggered the hook, the
+// stack traces of all goroutines and, if a ring buffer is given, the records it holds. Any existing file at the path
+// is replaced.
+//
+// The hook may return an error with any of the following codes:
+//   - [DataWriteError]: failed to write the crash report
+//   - [MarshalError]: failed to encode the crash report
+func CrashReportHook(path string, history *RingBuffer) SeverityHookFn {
+	return func(ctx context.Context, r slog.Record) error {
+		return writeCrashReport(path, &r, nil, history)
+	}
+}
+
+// ExitHook returns a [SeverityHookFn] which exits the application with the given status code.
+//
+// Any hooks which should run before the application exits (eg: [FlushHook] or [CloseHook]) must be registered before
+// this hook.
+func ExitHook(code int) SeverityHookFn {
+	return func(ctx context.Context, r slog.Record) error {
+		exitFn(code)
+		return nil
+	}
+}
+
+// FlushHook returns a [SeverityHookFn] which flushes any buffered records held by the given handler and its children.
+//
+// Every handler in the tree which has a Flush function returning an error is flushed. Child handlers are found using
+// [ExtendedHandler.ChildHandlers].
+func FlushHook(h slog.Handler) SeverityHookFn {
+	return func(ctx context.Context, r slog.Record) error {
+		return FlushHandler(h)
+	}
+}
+
+// NewSeverityHookHandler returns a new [slog.Handler] which passes records to the given handler and then calls every
+// registered severity hook whose level is at or below the level of the record.
+//
+// Hooks are called in the order in which they were registered, even if the underlying handler is not enabled for the
+// record or fails to handle it. Any errors returned by the underlying handler and the hooks are joined together.
+func NewSeverityHookHandler(h slog.Handler) slog.Handler {
+	return &severityHookHandler{
+		handler: h,
+	}
+}
+
+// RegisterSeverityHook registers a function which is called whenever a record at or above the given level is handled
+// by a handler returned by [NewSeverityHookHandler] and returns a function which unregisters it.
+//
+// Hooks are package-wide, so they apply to every such handler.
+func RegisterSeverityHook(level slog.Level, fn SeverityHookFn) func() {
+	s := severityHookState
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.hooks = append(s.hooks, severityHook{
+		fn:    fn,
+		id:    id,
+		level: level,
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.hooks = slices.DeleteFunc(slices.Clone(s.hooks), func(hook severityHook) bool {
+				return hook.id == id
+			})
+		})
+	}
+}
+
+// RunSeverityHooks calls every registered severity hook whose level is at or below the level of the given record, in
+// the order in which they were registered, and joins any errors they return together.
+//
+// Custom handlers which do not use [NewSeverityHookHandler] may call this function directly.
+func RunSeverityHooks(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, hook := range severityHookState.matching(r.Level) {
+		if err := hook.fn(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *severityHookHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns true if the underlying handler is enabled for the given level or if the level triggers any hooks.
+func (h *severityHookHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level) || len(severityHookState.matching(level)) > 0
+}
+
+// Handle passes the record to the underlying handler, if it is enabled for the record, and then calls any hooks
+// triggered by the record's level.
+func (h *severityHookHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	if h.handler.Enabled(ctx, r.Level) {
+		if err := h.handler.Handle(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := RunSeverityHooks(ctx, r); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Options always returns nil since the handler has no options.
+func (h *severityHookHandler) Options() any {
+	return nil
+}
+
+// Type returns the type of the handler.
+func (h *severityHookHandler) Type() string {
+	return "severity_hook"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *severityHookHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &severityHookHandler{
+		handler: h.handler.WithAttrs(attrs),
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *severityHookHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &severityHookHandler{
+		handler: h.handler.WithGroup(name),
+	}
+}
+
+// matching returns the hooks which are triggered by records at the given level.
+func (s *severityHooks) matching(level slog.Level) []severityHook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var hooks []severityHook
+	for _, hook := range s.hooks {
+		if level >= hook.level {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// writeCrashReport writes a crash report holding the given record and/or panic value, the stack traces of all
+// goroutines and the records held by the ring buffer, if any, to the file at the given path.
+//
+// This function may return an error with any of the following codes:
+//   - [DataWriteError]: failed to write the crash report
+//   - [MarshalError]: failed to encode the crash report
+func writeCrashReport(path string, r *slog.Record, panicValue any, history *RingBuffer) xerrors.Error {
+	report := map[string]any{
+		"time": DefaultClock.Now().Format(time.RFC3339Nano),
+	}
+	if r != nil {
+		report["record"] = RecordToMap(r)
+	}
+	if panicValue != nil {
+		report["panic"] = fmt.Sprint(panicValue)
+	}
+
+	// capture the stacks of all goroutines, growing the buffer until they fit
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			report["goroutines"] = string(buf[:n])
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	if history != nil {
+		records := history.Records()
+		entries := make([]map[string]any, 0, len(records))
+		for i := range records {
+			entries = append(entries, RecordToMap(&records[i]))
+		}
+		report["history"] = entries
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return xerrors.Wrapf(MarshalError, err, "failed to encode crash report: %s", err.Error()).
+			WithAttr("path", path)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return xerrors.Wrapf(DataWriteError, err, "failed to write crash report: %s", err.Error()).
+			WithAttr("path", path)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package xlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+const (
+	// SourceFilterAllow passes matching records to the underlying handler unchanged.
+	SourceFilterAllow SourceFilterAction = "allow"
+
+	// SourceFilterDrop drops matching records.
+	SourceFilterDrop SourceFilterAction = "drop"
+
+	// SourceFilterRelevel changes the level of matching records before they are passed to the underlying handler.
+	SourceFilterRelevel SourceFilterAction = "relevel"
+)
+
+// SourceFilterAction is the action taken for records matching a [SourceFilterRule].
+type SourceFilterAction string
+
+// SourceFilterOptions holds the options for the handler returned by [NewSourceFilterHandler].
+type SourceFilterOptions struct {
+	// DefaultAction is the action taken for records which don't match any rule, which must be either
+	// [SourceFilterAllow] or [SourceFilterDrop].
+	//
+	// Set this value to [SourceFilterDrop] to use the rules as an allowlist.
+	//
+	// The default behavior is to allow records which don't match any rule.
+	DefaultAction SourceFilterAction
+
+	// Rules holds the rules to match against the source location of each record, in order. The first matching rule
+	// determines the action taken for the record.
+	Rules []SourceFilterRule
+}
+
+// SourceFilterRule matches records based on the package and/or file of their source location.
+type SourceFilterRule struct {
+	// Action is the action to take for matching records.
+	//
+	// This value is required.
+	Action SourceFilterAction
+
+	// File is a pattern, as accepted by [path.Match], which must match the file of the record's source location.
+	//
+	// If the pattern does not contain a slash, it is matched against the base name of the file (eg: "*_gen.go").
+	// Otherwise it is matched against the full path of the file.
+	//
+	// The default behavior is to match records from any file.
+	File string
+
+	// Level is the level to which matching records are changed when the action is [SourceFilterRelevel] or, when
+	// the action is [SourceFilterDrop], the level below which matching records are dropped.
+	//
+	// This value is required when the action is [SourceFilterRelevel]. The default behavior for [SourceFilterDrop] is
+	// to drop matching records at any level.
+	Level slog.Leveler
+
+	// Package is the import path of the package (eg: "github.com/vendor/chatty") from which matching records must be
+	// logged. A path ending in "/..." also matches all of the packages below it.
+	//
+	// The default behavior is to match records from any package.
+	Package string
+}
+
+// sourceFilterHandler is the [slog.Handler] returned by [NewSourceFilterHandler].
+type sourceFilterHandler struct {
+	// unexported variables
+	handler slog.Handler        // underlying handler
+	options SourceFilterOptions // immutable handler options
+	relevel bool                // whether or not any rule changes the level of records
+	rules   *sync.Map           // shared cache of the rule matching each program counter
+}
+
+// ensure [sourceFilterHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &sourceFilterHandler{}
+
+// sourceFilterMatch is the cached result of matching a program counter against the rules.
+type sourceFilterMatch struct {
+	rule *SourceFilterRule // matching rule or nil if no rule matched
+}
+
+// NewSourceFilterHandler returns a new [slog.Handler] which allows, drops or changes the level of records based on
+// the package and/or file from which they were logged (eg: to silence a chatty dependency without modifying it).
+//
+// The source location of each record is resolved from its program counter, so records without one (eg: those created
+// by hand with a zero program counter) never match any rule. The rule matching each source location is cached.
+//
+// If any rule changes the level of records, the handler reports that it is enabled for every level, since the level
+// of a record cannot be changed until its source location is known, and records are dropped afterward if the
+// underlying handler is not enabled for their final level.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: one or more options are invalid
+func NewSourceFilterHandler(h slog.Handler, options SourceFilterOptions) (slog.Handler, xerrors.Error) {
+	var fields, problems []string
+	addf := func(field, format string, args ...any) {
+		fields = append(fields, field)
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+	switch options.DefaultAction {
+	case "":
+		options.DefaultAction = SourceFilterAllow
+	case SourceFilterAllow, SourceFilterDrop:
+	default:
+		addf("default_action", "%s: invalid action", options.DefaultAction)
+	}
+	options.Rules = slices.Clone(options.Rules)
+	relevel := false
+	for i, rule := range options.Rules {
+		switch rule.Action {
+		case SourceFilterAllow, SourceFilterDrop:
+		case SourceFilterRelevel:
+			relevel = true
+			if rule.Level == nil {
+				addf(fmt.Sprintf("rules[%d].level", i), "level is required to relevel records")
+			}
+		default:
+			addf(fmt.Sprintf("rules[%d].action", i), "%s: invalid action", rule.Action)
+		}
+		if _, err := path.Match(rule.File, ""); err != nil {
+			addf(fmt.Sprintf("rules[%d].file", i), "%s", err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid source filter options: %s",
+			strings.Join(problems, "; ")).WithAttr("fields", fields)
+	}
+	return &sourceFilterHandler{
+		handler: h,
+		options: options,
+		relevel: relevel,
+		rules:   &sync.Map{},
+	}, nil
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *sourceFilterHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level, or true if any rule changes
+// the level of records.
+func (h *sourceFilterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.relevel || h.handler.Enabled(ctx, level)
+}
+
+// Handle applies the action of the rule matching the record's source location and passes the record to the
+// underlying handler unless it is dropped.
+func (h *sourceFilterHandler) Handle(ctx context.Context, r slog.Record) error {
+	rule := h.match(r.PC)
+	switch {
+	case rule == nil && h.options.DefaultAction == SourceFilterDrop:
+		return nil
+	case rule == nil || rule.Action == SourceFilterAllow:
+	case rule.Action == SourceFilterDrop:
+		if rule.Level == nil || r.Level < rule.Level.Level() {
+			return nil
+		}
+	case rule.Action == SourceFilterRelevel:
+		r.Level = rule.Level.Level()
+	}
+	if h.relevel && !h.handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the handler's options.
+func (h *sourceFilterHandler) Options() any {
+	options := h.options
+	options.Rules = slices.Clone(options.Rules)
+	return options
+}
+
+// Type returns the type of the handler.
+func (h *sourceFilterHandler) Type() string {
+	return "source_filter"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *sourceFilterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &sourceFilterHandler{
+		handler: h.handler.WithAttrs(attrs),
+		options: h.options,
+		relevel: h.relevel,
+		rules:   h.rules,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *sourceFilterHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &sourceFilterHandler{
+		handler: h.handler.WithGroup(name),
+		options: h.options,
+		relevel: h.relevel,
+		rules:   h.rules,
+	}
+}
+
+// match returns the first rule matching the source location of the given program counter or nil if there isn't one.
+func (h *sourceFilterHandler) match(pc uintptr) *SourceFilterRule {
+	if pc == 0 {
+		return nil
+	}
+	if cached, ok := h.rules.Load(pc); ok {
+		return cached.(sourceFilterMatch).rule
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	pkg := funcPackage(frame.Function)
+	var match sourceFilterMatch
+	for i := range h.options.Rules {
+		if rule := &h.options.Rules[i]; rule.matches(pkg, frame.File) {
+			match.rule = rule
+			break
+		}
+	}
+	h.rules.Store(pc, match)
+	return match.rule
+}
+
+// matches returns whether or not the given package and file match the rule.
+func (r *SourceFilterRule) matches(pkg, file string) bool {
+	if r.Package != "" {
+		if prefix, ok := strings.CutSuffix(r.Package, "/..."); ok {
+			if pkg != prefix && !strings.HasPrefix(pkg, prefix+"/") {
+				return false
+			}
+		} else if pkg != r.Package {
+			return false
+		}
+	}
+	if r.File != "" {
+		name := file
+		if !strings.Contains(r.File, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(r.File, name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// funcPackage returns the import path of the package holding the function with the given fully-qualified name (eg:
+// "github.com/vendor/chatty" for "github.com/vendor/chatty.(*Client).Do").
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,240 @@
+package spool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+// HandlerOptions holds the options for a [Handler].
+type HandlerOptions struct {
+	// Dir is the spool directory, which is created if it does not exist.
+	//
+	// This value is required.
+	Dir string
+
+	// IncludeCaller indicates whether or not to include the caller in spooled records.
+	//
+	// The default behavior is to not include caller information.
+	IncludeCaller bool
+
+	// Level is the minimum level at which to spool records.
+	//
+	// The default behavior is to spool records at [slog.LevelInfo] or above.
+	Level slog.Leveler
+
+	// MaxSegmentSize is the size (in bytes) after which a new segment file is started.
+	//
+	// The default behavior is defined by the default max segment size setting defined in the package.
+	MaxSegmentSize int64
+}
+
+// HandlerStats holds the size of the backlog of records in a spool directory.
+type HandlerStats struct {
+	// Bytes is the total size (in bytes) of the segments in the spool directory.
+	Bytes int64 `json:"bytes"`
+
+	// Segments is the number of segments in the spool directory, including the one currently being written.
+	Segments int `json:"segments"`
+}
+
+// Handler is a [slog.Handler] which appends records to segment files in a spool directory so that they can be
+// replayed into another handler later using [Spool.Replay] (eg: once a sink is reachable again).
+//
+// A new segment is started each time a handler is created and whenever the current segment reaches the maximum
+// segment size, so segments are never appended to once they have been closed. Handlers derived using WithAttrs or
+// WithGroup share the open segment with the handler they were derived from.
+type Handler struct {
+	// unexported variables
+	handler slog.Handler  // underlying JSON handler
+	state   *segmentState // shared segment writer
+}
+
+// ensure [Handler] implements [xlog.ExtendedHandler] interface.
+var _ xlog.ExtendedHandler = &Handler{}
+
+// segmentState holds the segment file currently being written, shared by a handler and its descendants.
+type segmentState struct {
+	// unexported variables
+	file    *os.File       // current segment file
+	mu      sync.Mutex     // protects the fields below
+	options HandlerOptions // immutable handler options
+	seq     uint64         // sequence number of the current segment
+	size    int64          // size of the current segment
+}
+
+// NewHandler creates a new [Handler] which spools records to the given directory.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to create the spool directory or the first segment
+//   - [xlog.OptionsValidationError]: the directory is empty
+func NewHandler(options HandlerOptions) (*Handler, xerrors.Error) {
+	if options.Dir == "" {
+		return nil, xerrors.New(xlog.OptionsValidationError, "spool directory cannot be empty")
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	if options.MaxSegmentSize <= 0 {
+		options.MaxSegmentSize = DefaultMaxSegmentSize
+	}
+	if err := os.MkdirAll(options.Dir, 0750); err != nil {
+		return nil, xerrors.Wrapf(xlog.DataWriteError, err, "failed to create spool directory '%s': %s",
+			options.Dir, err.Error()).WithAttr("dir", options.Dir)
+	}
+
+	// continue numbering after the newest existing segment
+	state := &segmentState{
+		options: options,
+	}
+	segments, xerr := listSegments(options.Dir)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if len(segments) > 0 {
+		state.seq = segments[len(segments)-1].seq
+	}
+	if xerr := state.rotate(); xerr != nil {
+		return nil, xerr
+	}
+	return &Handler{
+		handler: slog.NewJSONHandler(state, &slog.HandlerOptions{
+			AddSource: options.IncludeCaller,
+			Level:     options.Level,
+		}),
+		state: state,
+	}, nil
+}
+
+// ChildHandlers will always return nil as this handler has no child handlers.
+func (h *Handler) ChildHandlers() []slog.Handler {
+	return nil
+}
+
+// Close closes the current segment file.
+//
+// Records handled after the handler is closed start a new segment.
+func (h *Handler) Close() error {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	if h.state.file == nil {
+		return nil
+	}
+	err := h.state.file.Close()
+	h.state.file = nil
+	return err
+}
+
+// Enabled returns true if the handler should handle records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Flush commits the current segment file to stable storage.
+func (h *Handler) Flush() error {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	if h.state.file == nil {
+		return nil
+	}
+	return h.state.file.Sync()
+}
+
+// Handle appends the record to the current segment.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the handler's options.
+func (h *Handler) Options() any {
+	return h.state.options
+}
+
+// Stats returns the size of the backlog of records waiting in the spool directory to be replayed.
+//
+// Segments which cannot be read (eg: because they are removed while the stats are being collected) are skipped.
+func (h *Handler) Stats() HandlerStats {
+	var stats HandlerStats
+	segments, xerr := listSegments(h.state.options.Dir)
+	if xerr != nil {
+		return stats
+	}
+	for _, seg := range segments {
+		info, err := os.Stat(seg.path)
+		if err != nil {
+			continue
+		}
+		stats.Bytes += info.Size()
+		stats.Segments++
+	}
+	return stats
+}
+
+// Type returns the type of the handler.
+func (h *Handler) Type() string {
+	return "spool"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{
+		handler: h.handler.WithAttrs(attrs),
+		state:   h.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{
+		handler: h.handler.WithGroup(name),
+		state:   h.state,
+	}
+}
+
+// Write appends a single formatted record to the current segment, starting a new segment first if the current one
+// is full or has been closed.
+func (s *segmentState) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil || (s.size > 0 && s.size+int64(len(p)) > s.options.MaxSegmentSize) {
+		if err := s.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// rotate closes the current segment, if any, and creates the next one.
+//
+// The caller must hold the lock, except when the state is being created.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to create the segment
+func (s *segmentState) rotate() xerrors.Error {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	s.seq++
+	path := filepath.Join(s.options.Dir, fmt.Sprintf(segmentNameFormat, s.seq))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to create spool segment '%s': %s", path,
+			err.Error()).WithAttr("segment", path)
+	}
+	s.file = file
+	s.size = 0
+	return nil
+}
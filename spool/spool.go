@@ -0,0 +1,610 @@
+// Package spool stores records on disk while a sink is unavailable and replays them into any handler once it is
+// available again.
+//
+// A spool is a directory of segment files written by a [Handler]. Each segment holds records in NDJSON format, as
+// written by [slog.JSONHandler], and is named after its sequence number (eg: "00000000000000000001.ndjson") so that
+// segments sort in the order they were written. Operators can open a spool using [Open] to inspect the records it
+// holds and replay them into any handler, including a [Handler] for another spool directory in order to migrate
+// spooled data between sinks.
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+	"go.innotegrity.dev/xlog"
+)
+
+var (
+	// DefaultMaxReplayBackoff is the default maximum time to wait before replaying a record which was rejected because
+	// the sink is under pressure.
+	//
+	// This value is used when the max backoff in [ReplayOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/spool#ReplayOptions
+	DefaultMaxReplayBackoff = time.Minute
+
+	// DefaultMaxSegmentSize is the default size (in bytes) after which a [Handler] starts a new segment file.
+	//
+	// This value is used when the max segment size in [HandlerOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/spool#HandlerOptions
+	DefaultMaxSegmentSize int64 = 16 * 1024 * 1024
+
+	// DefaultReplayBatchSize is the default maximum number of records passed at once to a handler which implements
+	// [xlog.BatchHandler] by [Spool.Replay].
+	//
+	// This value is used when the batch size in [ReplayOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/spool#ReplayOptions
+	DefaultReplayBatchSize = 100
+)
+
+const (
+	// segmentExt is the file extension of segment files.
+	segmentExt = ".ndjson"
+
+	// segmentNameFormat is the format of the name of a segment file, given its sequence number.
+	segmentNameFormat = "%020d" + segmentExt
+)
+
+// ReplayOptions holds the options for [Spool.Replay].
+type ReplayOptions struct {
+	// Backoff is the time to wait before passing a record to the handler again after the handler rejected it because
+	// the sink is under pressure (see [xlog.IsBackpressureError]), such as when the collector's rate limits are hit.
+	//
+	// The time doubles after each consecutive rejection, up to MaxBackoff, and is reset once a record is accepted.
+	// Only errors returned by the handler are seen, so the handler should deliver records synchronously.
+	//
+	// The default behavior is to stop replaying at the first record the handler fails to handle.
+	Backoff time.Duration
+
+	// BatchSize is the maximum number of records passed to the handler at once when the handler implements
+	// [xlog.BatchHandler].
+	//
+	// Records are collected into batches within each segment and passed to [xlog.BatchHandler.HandleBatch], so a sink
+	// with a native bulk API receives the backlog in bulk. If the handler fails to handle a batch, every record in
+	// the batch is left in the spool, so records which the handler did deliver may be replayed again. Other handlers
+	// are always passed one record at a time.
+	//
+	// The default behavior is defined by the default replay batch size setting defined in the package.
+	BatchSize int
+
+	// Burst is the maximum number of records replayed in a burst when Rate is set.
+	//
+	// The default behavior is to allow bursts of up to one second's worth of records.
+	Burst int
+
+	// Keep indicates whether or not to keep segments once all of their records have been replayed.
+	//
+	// The default behavior is to remove each segment once all of its records have been replayed and to rewrite a
+	// segment to hold only the records which were not replayed if replay stops part way through it, so that a
+	// replay can be resumed without sending any record twice. Segments are only updated once the handler has been
+	// flushed, so this requires a handler which delivers records synchronously (see [xlog.IsAsyncHandler]).
+	//
+	// Set this to replay records into an asynchronous handler (eg: one returned by [xlog.Pipeline.Handler]), in which
+	// case the segments should be removed by the caller once the handler has been closed.
+	Keep bool
+
+	// MaxRecordAge is the maximum age of a record, measured from its time, at which it is still replayed.
+	//
+	// Older records are removed from the spool (unless Keep is set), counted and reported using [xlog.NotifyDropped]
+	// rather than being passed to the handler, so that stale records are not delivered after a long outage. Records
+	// without a time never expire.
+	//
+	// The default behavior is to replay records no matter how old they are.
+	MaxRecordAge time.Duration
+
+	// MaxBackoff is the maximum time to wait before passing a record rejected because of backpressure to the handler
+	// again.
+	//
+	// The default behavior is defined by the default max replay backoff setting defined in the package.
+	MaxBackoff time.Duration
+
+	// Priority is called for each record to classify it into a priority lane (eg: [xlog.LevelPriority]).
+	//
+	// The records in each lane are replayed, oldest first, before the records in the lanes with lower priorities, so
+	// that important records (eg: errors and audit records) are delivered first when a large backlog is drained. The
+	// spool is read once to find the lanes and once more for each lane.
+	//
+	// The default behavior is to replay all records in the order they were spooled.
+	Priority xlog.PriorityFn
+
+	// Rate is the maximum number of records per second passed to the handler, so that draining a large backlog does
+	// not saturate the network link or trip the rate limits of the sink.
+	//
+	// The default behavior is to replay records as fast as the handler accepts them.
+	Rate float64
+
+	// Window is the daily period of time (eg: overnight) during which records may be replayed.
+	//
+	// Outside of the window, replay pauses until the window opens again or the context is canceled.
+	//
+	// The default behavior is to replay records at any time.
+	Window *Window
+}
+
+// ReplayStats holds the counters for a replay.
+type ReplayStats struct {
+	// Expired is the number of records which were not replayed because they were older than the maximum record age.
+	Expired int
+
+	// Replayed is the number of records passed to the handler.
+	Replayed int
+
+	// Skipped is the number of lines which could not be parsed as records or whose level the handler was not enabled
+	// for, which are left in the spool.
+	Skipped int
+}
+
+// Window defines a daily period of time, such as off-peak hours, as offsets from midnight.
+type Window struct {
+	// End is the time of day at which the window closes (eg: 6*time.Hour for 06:00).
+	//
+	// If End is before Start, the window spans midnight. If End is equal to Start, the window never closes.
+	End time.Duration
+
+	// Location is the time zone in which the times of day are given.
+	//
+	// The default behavior is to use [time.Local].
+	Location *time.Location
+
+	// Start is the time of day at which the window opens (eg: 22*time.Hour for 22:00).
+	Start time.Duration
+}
+
+// Spool provides access to the records stored in a spool directory.
+type Spool struct {
+	// unexported variables
+	dir string // spool directory
+}
+
+// segment describes a single segment file.
+type segment struct {
+	path string // path to the file
+	seq  uint64 // sequence number of the segment
+}
+
+// Open opens the spool in the given directory.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: the directory does not exist or cannot be read
+func Open(dir string) (*Spool, xerrors.Error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.DataReadError, err, "failed to open spool directory '%s': %s", dir,
+			err.Error()).WithAttr("dir", dir)
+	}
+	if !info.IsDir() {
+		return nil, xerrors.Newf(xlog.DataReadError, "spool path '%s' is not a directory", dir).WithAttr("dir", dir)
+	}
+	return &Spool{
+		dir: dir,
+	}, nil
+}
+
+// Iterate calls the given function for each record in the spool, oldest first, until the function returns false.
+//
+// Lines which cannot be parsed are passed to the function as errors along with an empty record. Records are not
+// removed from the spool.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the spool directory or a segment
+func (s *Spool) Iterate(fn func(r slog.Record, err error) bool) xerrors.Error {
+	segments, xerr := listSegments(s.dir)
+	if xerr != nil {
+		return xerr
+	}
+	for _, seg := range segments {
+		stop := false
+		xerr := readSegment(seg.path, func(line []byte) bool {
+			r, err := xlog.JSONToRecord(line)
+			if err != nil {
+				stop = !fn(slog.Record{}, err.WithAttr("segment", seg.path))
+			} else {
+				stop = !fn(r, nil)
+			}
+			return !stop
+		})
+		if xerr != nil {
+			return xerr
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Peek returns up to n of the oldest records in the spool without removing them.
+//
+// Lines which cannot be parsed are skipped.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the spool directory or a segment
+func (s *Spool) Peek(n int) ([]slog.Record, xerrors.Error) {
+	records := make([]slog.Record, 0, max(n, 0))
+	if n <= 0 {
+		return records, nil
+	}
+	xerr := s.Iterate(func(r slog.Record, err error) bool {
+		if err == nil {
+			records = append(records, r)
+		}
+		return len(records) < n
+	})
+	return records, xerr
+}
+
+// Replay passes each record in the spool, oldest first, to the given handler if it is enabled for the record's level
+// and is not older than the maximum record age.
+//
+// If a priority function is given, the records are replayed one priority lane at a time, highest priority first.
+// Replay may be limited to a rate and a daily window and may back off and retry records rejected by a sink under
+// pressure rather than stopping (see [ReplayOptions]), so that a large backlog can be drained without disrupting the
+// sink.
+//
+// Lines which cannot be parsed (eg: a line torn by a crash) and records whose level the handler is not enabled for are
+// counted as skipped and left in the spool, so that they can be inspected or replayed into another handler. Replay
+// stops at the first record or batch of records (see [ReplayOptions]) the handler fails to handle or when the context
+// is canceled, leaving those records and all of the records after them in the spool. The spool should not be replayed
+// while a [Handler] is writing to it since the segment being written may be removed.
+//
+// Unless Keep is set, the handler and its descendants are flushed using [xlog.FlushHandler] before each segment is
+// removed or rewritten, so that replayed records are never removed from the spool before they reach the sink. Handlers
+// which may deliver records after they have been flushed (see [xlog.IsAsyncHandler]) are rejected in that case.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the spool directory or a segment
+//   - [xlog.DataWriteError]: failed to remove or rewrite a segment
+//   - [xlog.HandleRecordError]: the handler failed to handle a record or to be flushed or the context was canceled
+//   - [xlog.InvalidParameter]: the handler delivers records asynchronously and Keep is not set
+func (s *Spool) Replay(ctx context.Context, h slog.Handler, options ReplayOptions) (ReplayStats, xerrors.Error) {
+	if !options.Keep && xlog.IsAsyncHandler(h) {
+		return ReplayStats{}, xerrors.New(xlog.InvalidParameter,
+			"replay handler cannot handle records asynchronously unless segments are kept")
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultReplayBatchSize
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxReplayBackoff
+	}
+	limiter := xlog.NewRateLimiter(options.Rate, options.Burst, nil)
+	if options.Priority == nil {
+		return s.replay(ctx, h, options, limiter, nil)
+	}
+
+	// find the lanes holding records, highest priority first
+	lanes := map[int]struct{}{}
+	xerr := s.Iterate(func(r slog.Record, err error) bool {
+		if err == nil {
+			lanes[options.Priority(r)] = struct{}{}
+		}
+		return true
+	})
+	if xerr != nil {
+		return ReplayStats{}, xerr
+	}
+
+	var stats ReplayStats
+	for _, lane := range slices.Backward(slices.Sorted(maps.Keys(lanes))) {
+		laneStats, xerr := s.replay(ctx, h, options, limiter, &lane)
+		stats.Expired += laneStats.Expired
+		stats.Replayed += laneStats.Replayed
+		stats.Skipped += laneStats.Skipped
+		if xerr != nil {
+			return stats, xerr
+		}
+	}
+	return stats, nil
+}
+
+// Segments returns the paths of the segment files in the spool, oldest first.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the spool directory
+func (s *Spool) Segments() ([]string, xerrors.Error) {
+	segments, xerr := listSegments(s.dir)
+	if xerr != nil {
+		return nil, xerr
+	}
+	paths := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		paths = append(paths, seg.path)
+	}
+	return paths, nil
+}
+
+// replay passes each record in the spool which is in the given priority lane, or each record if lane is nil, to the
+// given handler as described by [Spool.Replay].
+//
+// Records in other lanes are left in the spool. The limiter is shared by all lanes. Records are collected into
+// batches of up to the batch size in the options if the handler implements [xlog.BatchHandler].
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the spool directory or a segment
+//   - [xlog.DataWriteError]: failed to remove or rewrite a segment
+//   - [xlog.HandleRecordError]: the handler failed to handle a record or to be flushed or the context was canceled
+func (s *Spool) replay(ctx context.Context, h slog.Handler, options ReplayOptions, limiter *xlog.RateLimiter,
+	lane *int) (ReplayStats, xerrors.Error) {
+	var stats ReplayStats
+	segments, xerr := listSegments(s.dir)
+	if xerr != nil {
+		return stats, xerr
+	}
+	batchSize := 1
+	if _, ok := h.(xlog.BatchHandler); ok {
+		batchSize = options.BatchSize
+	}
+	for _, seg := range segments {
+		var expired uint64
+		var handleErr error
+		var remaining [][]byte
+		var batch []slog.Record
+		var batchLines []int // indexes of the lines of the records in the batch within remaining
+
+		// the lines of the records in the batch are kept in remaining until the batch is handled so that the order of
+		// the lines left in the spool is preserved if it fails
+		flush := func() {
+			if err := deliver(ctx, h, batch, options, limiter); err != nil {
+				handleErr = err
+			} else {
+				stats.Replayed += len(batch)
+				for _, i := range batchLines {
+					remaining[i] = nil
+				}
+			}
+			batch, batchLines = batch[:0], batchLines[:0]
+		}
+		xerr := readSegment(seg.path, func(line []byte) bool {
+			if handleErr != nil {
+				remaining = append(remaining, slices.Clone(line))
+				return true
+			}
+			if err := ctx.Err(); err != nil {
+				handleErr = err
+				remaining = append(remaining, slices.Clone(line))
+				return true
+			}
+			r, err := xlog.JSONToRecord(line)
+			if err == nil && lane != nil && options.Priority(r) != *lane {
+				remaining = append(remaining, slices.Clone(line))
+				return true
+			}
+			if err != nil || !h.Enabled(ctx, r.Level) {
+				stats.Skipped++
+				remaining = append(remaining, slices.Clone(line))
+				return true
+			}
+			if xlog.RecordExpired(r.Time, options.MaxRecordAge, nil) {
+				stats.Expired++
+				expired++
+				return true
+			}
+			batch = append(batch, r)
+			batchLines = append(batchLines, len(remaining))
+			remaining = append(remaining, slices.Clone(line))
+			if len(batch) >= batchSize {
+				flush()
+			}
+			return true
+		})
+		if xerr == nil && handleErr == nil && len(batch) > 0 {
+			flush()
+		}
+		remaining = slices.DeleteFunc(remaining, func(line []byte) bool { return line == nil })
+		xlog.NotifyDropped(s.dir, "spool", xlog.DropReasonExpired, expired)
+		if xerr != nil {
+			return stats, xerr
+		}
+
+		if !options.Keep {
+			if err := xlog.FlushHandler(h); err != nil {
+				return stats, xerrors.Wrapf(xlog.HandleRecordError, err,
+					"failed to flush handler before updating spool segment: %s", err.Error()).
+					WithAttr("segment", seg.path)
+			}
+			if xerr := updateSegment(seg.path, remaining); xerr != nil {
+				return stats, xerr
+			}
+		}
+		if handleErr != nil {
+			return stats, xerrors.Wrapf(xlog.HandleRecordError, handleErr, "failed to replay spooled record: %s",
+				handleErr.Error()).WithAttr("segment", seg.path)
+		}
+	}
+	return stats, nil
+}
+
+// Until returns how long it is from the given time until the window opens, which is 0 if the window is open.
+func (w Window) Until(t time.Time) time.Duration {
+	loc := w.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	start, end := w.Start, w.End
+	switch {
+	case start == end:
+		return 0
+	case start < end && offset >= start && offset < end:
+		return 0
+	case start > end && (offset >= start || offset < end):
+		return 0
+	case offset < start:
+		return start - offset
+	}
+	return midnight.AddDate(0, 0, 1).Add(start).Sub(t)
+}
+
+// deliver waits until the records may be replayed according to the window and rate limit and passes them to the
+// handler, as a single batch if there is more than one record, retrying while the handler rejects them because of
+// backpressure if a backoff is set.
+//
+// It returns the error returned by the handler or the context's error if it is canceled while waiting.
+func deliver(ctx context.Context, h slog.Handler, records []slog.Record, options ReplayOptions,
+	limiter *xlog.RateLimiter) error {
+	backoff := options.Backoff
+	for {
+		if options.Window != nil {
+			if err := sleep(ctx, options.Window.Until(xlog.DefaultClock.Now())); err != nil {
+				return err
+			}
+		}
+		for range records {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		var err error
+		if len(records) == 1 {
+			err = h.Handle(ctx, records[0])
+		} else {
+			err = xlog.HandleBatch(ctx, h, records)
+		}
+		if err == nil || options.Backoff <= 0 || !xlog.IsBackpressureError(err) {
+			return err
+		}
+		xlog.Trace(ctx, xlog.TraceEventRetryScheduled, "replay retry scheduled", slog.String("handler_type", "spool"),
+			slog.Duration("backoff", backoff), slog.String("error", err.Error()))
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = min(2*backoff, options.MaxBackoff)
+	}
+}
+
+// listSegments returns the segment files in the given directory, sorted by sequence number.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the directory
+func listSegments(dir string) ([]segment, xerrors.Error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Wrapf(xlog.DataReadError, err, "failed to read spool directory '%s': %s", dir,
+			err.Error()).WithAttr("dir", dir)
+	}
+	var segments []segment
+	for _, entry := range entries {
+		var seq uint64
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentExt), "%d", &seq); err != nil {
+			continue
+		}
+		segments = append(segments, segment{
+			path: filepath.Join(dir, name),
+			seq:  seq,
+		})
+	}
+	slices.SortFunc(segments, func(a, b segment) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+	return segments, nil
+}
+
+// readSegment calls the given function for each non-empty line in the segment until the function returns false.
+//
+// A partial line at the end of the segment (eg: after a crash) is passed to the function like any other line.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataReadError]: failed to read the segment
+func readSegment(path string, fn func(line []byte) bool) xerrors.Error {
+	file, err := os.Open(path)
+	if err != nil {
+		return xerrors.Wrapf(xlog.DataReadError, err, "failed to open spool segment '%s': %s", path,
+			err.Error()).WithAttr("segment", path)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 && !fn(line) {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Wrapf(xlog.DataReadError, err, "failed to read spool segment '%s': %s", path,
+				err.Error()).WithAttr("segment", path)
+		}
+	}
+}
+
+// sleep waits for the given duration or until the context is canceled, whichever comes first, returning the context's
+// error if it is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// updateSegment removes the segment at the given path if there are no remaining lines or atomically rewrites it to
+// hold only the remaining lines otherwise.
+//
+// This function may return an error with any of the following codes:
+//   - [xlog.DataWriteError]: failed to remove or rewrite the segment
+func updateSegment(path string, remaining [][]byte) xerrors.Error {
+	if len(remaining) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return xerrors.Wrapf(xlog.DataWriteError, err, "failed to remove spool segment '%s': %s", path,
+				err.Error()).WithAttr("segment", path)
+		}
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	data := append(bytes.Join(remaining, []byte{'\n'}), '\n')
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to rewrite spool segment '%s': %s", path,
+			err.Error()).WithAttr("segment", path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return xerrors.Wrapf(xlog.DataWriteError, err, "failed to rewrite spool segment '%s': %s", path,
+			err.Error()).WithAttr("segment", path)
+	}
+	return nil
+}
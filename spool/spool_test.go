@@ -0,0 +1,149 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// batchTestHandler is an [xlog.BatchHandler] which records the messages of each batch it is passed and fails once a
+// given number of batches have been handled.
+type batchTestHandler struct {
+	batches [][]string // messages of each batch handled
+	failAt  int        // number of batches after which every batch fails, or 0 to never fail
+}
+
+func (h *batchTestHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *batchTestHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.HandleBatch(ctx, []slog.Record{r})
+}
+
+func (h *batchTestHandler) HandleBatch(_ context.Context, records []slog.Record) error {
+	if h.failAt > 0 && len(h.batches) >= h.failAt {
+		return errors.New("sink unavailable")
+	}
+	var messages []string
+	for _, r := range records {
+		messages = append(messages, r.Message)
+	}
+	h.batches = append(h.batches, messages)
+	return nil
+}
+
+func (h *batchTestHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *batchTestHandler) WithGroup(string) slog.Handler { return h }
+
+// newTestSpool creates a spool holding the given number of records whose messages are their index.
+func newTestSpool(t *testing.T, n int) *Spool {
+	t.Helper()
+	dir := t.TempDir()
+	h, err := NewHandler(HandlerOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h)
+	for i := range n {
+		logger.Info(fmt.Sprint(i))
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestReplayBatches checks that records are replayed into a batch handler in batches and that a batch which fails is
+// left in the spool along with the records after it.
+func TestReplayBatches(t *testing.T) {
+	s := newTestSpool(t, 5)
+	h := &batchTestHandler{failAt: 1}
+	stats, err := s.Replay(context.Background(), h, ReplayOptions{BatchSize: 2})
+	if err == nil {
+		t.Fatal("Replay() succeeded, want the batch error")
+	}
+	if stats.Replayed != 2 || fmt.Sprint(h.batches) != "[[0 1]]" {
+		t.Errorf("Replay() replayed %d records in batches %v, want [[0 1]]", stats.Replayed, h.batches)
+	}
+
+	h.failAt = 0
+	h.batches = nil
+	if _, err := s.Replay(context.Background(), h, ReplayOptions{BatchSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(h.batches) != "[[2 3] [4]]" {
+		t.Errorf("Replay() passed batches %v, want [[2 3] [4]]", h.batches)
+	}
+}
+
+// TestReplayKeepsSkippedLines checks that replaying a spool into a handler which is not enabled for some of its
+// records leaves those records, along with lines which cannot be parsed, in the spool.
+func TestReplayKeepsSkippedLines(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewHandler(HandlerOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h)
+	logger.Info("info 1")
+	logger.Warn("warn 1")
+	logger.Info("info 2")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err := s.Segments()
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("Segments() = %v, %v", segments, err)
+	}
+	f, openErr := os.OpenFile(segments[len(segments)-1], os.O_APPEND|os.O_WRONLY, 0)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	if _, err := f.WriteString(`{"time":"2025-11-04T12:00:00Z","level":"INFO","msg":"torn`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var out bytes.Buffer
+	stats, err := s.Replay(context.Background(), slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Replayed != 1 || stats.Skipped != 3 {
+		t.Errorf("Replay() stats = %+v, want 1 replayed and 3 skipped", stats)
+	}
+	if !strings.Contains(out.String(), `"msg":"warn 1"`) {
+		t.Errorf("handler received %q, want the warning", out.String())
+	}
+
+	var messages []string
+	var parseErrors int
+	err = s.Iterate(func(r slog.Record, err error) bool {
+		if err != nil {
+			parseErrors++
+		} else {
+			messages = append(messages, r.Message)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(messages, ",") != "info 1,info 2" || parseErrors != 1 {
+		t.Errorf("spool holds %v and %d unparseable lines, want [info 1 info 2] and 1", messages, parseErrors)
+	}
+}
@@ -0,0 +1,123 @@
+package xlog
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// stampingWrapper holds the attributes, groups and underlying handler of a wrapper which rebuilds each record before
+// passing it to the underlying handler (eg: to add attributes at the top level of the record or to rewrite all of its
+// attributes).
+//
+// Attributes and groups are not passed on to the underlying handler until a record is handled, so the wrapper sees
+// every attribute of the record and the underlying handler receives every record with all of its attributes nested
+// inside of any groups. Wrappers embed this type and implement WithAttrs and WithGroup using [withStampedAttrs] and
+// [withStampedGroup].
+type stampingWrapper struct {
+	// unexported variables
+	attrs   []slog.Attr  // immutable attributes for the handler, nested within their groups
+	groups  []string     // immutable groups for the handler
+	handler slog.Handler // underlying handler without any attributes or groups applied
+}
+
+// stampingHandler is the constraint satisfied by pointers to wrappers embedding a [stampingWrapper].
+type stampingHandler[T any] interface {
+	*T
+	slog.Handler
+	wrapper() *stampingWrapper
+}
+
+// appendGroupedAttrs returns a new slice containing the given attributes followed by newAttrs nested inside of the
+// given groups.
+//
+// If the last attribute already holds the outermost group, newAttrs are merged into it. The original slice is never
+// modified.
+func appendGroupedAttrs(attrs []slog.Attr, groups []string, newAttrs []slog.Attr) []slog.Attr {
+	if len(newAttrs) == 0 {
+		return attrs
+	}
+	if len(groups) == 0 {
+		return append(slices.Clip(attrs), newAttrs...)
+	}
+	if n := len(attrs); n > 0 && attrs[n-1].Key == groups[0] && attrs[n-1].Value.Kind() == slog.KindGroup {
+		merged := slices.Clone(attrs)
+		merged[n-1] = slog.Attr{
+			Key:   groups[0],
+			Value: slog.GroupValue(appendGroupedAttrs(attrs[n-1].Value.Group(), groups[1:], newAttrs)...),
+		}
+		return merged
+	}
+	return append(slices.Clip(attrs), slog.Attr{
+		Key:   groups[0],
+		Value: slog.GroupValue(appendGroupedAttrs(nil, groups[1:], newAttrs)...),
+	})
+}
+
+// recordAttrs returns a new slice containing the attributes of the given record.
+func recordAttrs(r slog.Record) []slog.Attr {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
+
+// stampRecord returns a new record with the time, level, message and source location of the given record holding the
+// given stamps followed by the given attributes.
+//
+// Stamps whose key is already used by one of the attributes at the top level are skipped so that attributes set
+// explicitly are never replaced.
+func stampRecord(r slog.Record, attrs []slog.Attr, stamps ...slog.Attr) slog.Record {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	for _, stamp := range stamps {
+		if !slices.ContainsFunc(attrs, func(attr slog.Attr) bool { return attr.Key == stamp.Key }) {
+			record.AddAttrs(stamp)
+		}
+	}
+	record.AddAttrs(attrs...)
+	return record
+}
+
+// withStampedAttrs returns a copy of the given wrapper whose attributes consist of both the wrapper's attributes and
+// the given attributes, or the wrapper itself if there are no attributes to add.
+func withStampedAttrs[T any, H stampingHandler[T]](h H, attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	w := H(&clone).wrapper()
+	w.attrs = appendGroupedAttrs(w.attrs, w.groups, attrs)
+	return H(&clone)
+}
+
+// withStampedGroup returns a copy of the given wrapper with the wrapper's attributes part of the given group, or the
+// wrapper itself if the name is empty.
+func withStampedGroup[T any, H stampingHandler[T]](h H, name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	w := H(&clone).wrapper()
+	w.groups = append(slices.Clip(w.groups), name)
+	return H(&clone)
+}
+
+// mergeAttrs returns the wrapper's attributes followed by the attributes of the given record nested inside of the
+// wrapper's groups.
+func (w *stampingWrapper) mergeAttrs(r slog.Record) []slog.Attr {
+	return appendGroupedAttrs(w.attrs, w.groups, recordAttrs(r))
+}
+
+// stamp returns a new record holding the given stamps at the top level followed by the wrapper's attributes and the
+// attributes of the given record nested inside of the wrapper's groups.
+//
+// Stamps whose key is already used at the top level of the record or by the wrapper's attributes are skipped.
+func (w *stampingWrapper) stamp(r slog.Record, stamps ...slog.Attr) slog.Record {
+	return stampRecord(r, w.mergeAttrs(r), stamps...)
+}
+
+// wrapper returns the wrapper itself so that wrappers embedding it satisfy [stampingHandler].
+func (w *stampingWrapper) wrapper() *stampingWrapper {
+	return w
+}
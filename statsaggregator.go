@@ -0,0 +1,468 @@
+package xlog
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultStatsAggregatorInterval is the default interval at which a [StatsAggregatorHandler] logs a summary of its
+	// statistics.
+	//
+	// This value is used when the interval in [StatsAggregatorOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#StatsAggregatorOptions
+	DefaultStatsAggregatorInterval = time.Minute
+
+	// DefaultStatsAggregatorMessage is the default message of the records holding a summary of log statistics.
+	//
+	// This value is used when the message in [StatsAggregatorOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#StatsAggregatorOptions
+	DefaultStatsAggregatorMessage = "log stats"
+
+	// DefaultStatsAggregatorTopFingerprints is the default number of fingerprints reported for each window.
+	//
+	// This value is used when the top fingerprints in [StatsAggregatorOptions] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#StatsAggregatorOptions
+	DefaultStatsAggregatorTopFingerprints = 10
+
+	// DefaultStatsAggregatorWindows is the default list of windows over which records are counted.
+	//
+	// This value is used when the windows in [StatsAggregatorOptions] are unset.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#StatsAggregatorOptions
+	DefaultStatsAggregatorWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+)
+
+// StatsAggregatorOptions holds the options for a [StatsAggregatorHandler].
+type StatsAggregatorOptions struct {
+	// Clock is the clock used to decide which window each record is counted in.
+	//
+	// The default behavior is to use [DefaultClock].
+	Clock Clock
+
+	// CountLevel is the minimum level of records which are counted even if the underlying handler is not enabled for
+	// them.
+	//
+	// This allows, for example, debug records to be counted without being written.
+	//
+	// The default behavior is to only count records which the underlying handler is enabled for.
+	CountLevel slog.Leveler
+
+	// Interval is the interval at which a summary of the statistics is logged by [StatsAggregatorHandler.Run].
+	//
+	// The default behavior is defined by the default stats aggregator interval setting defined in the package.
+	Interval time.Duration
+
+	// Level is the level at which summaries are logged.
+	//
+	// The default behavior is to log summaries at [slog.LevelInfo].
+	Level slog.Leveler
+
+	// Logger is the logger through which summaries are logged.
+	//
+	// If the logger writes to the aggregator itself, each summary is also counted.
+	//
+	// The default behavior is to use [slog.Default] at the time each summary is logged.
+	Logger *slog.Logger
+
+	// Message is the message of the records holding summaries.
+	//
+	// The default behavior is defined by the default stats aggregator message setting defined in the package.
+	Message string
+
+	// TopFingerprints is the number of most frequent fingerprints (see [RecordFingerprint]) reported for each window.
+	//
+	// Set this value to a negative number to not report fingerprints at all.
+	//
+	// The default behavior is defined by the default stats aggregator top fingerprints setting defined in the package.
+	TopFingerprints int
+
+	// Windows is the list of windows over which records are counted.
+	//
+	// Records are counted in buckets whose width is 1/60th of the smallest window, with a minimum of one second, so
+	// the counts for each window are accurate to within the width of a bucket.
+	//
+	// The default behavior is defined by the default stats aggregator windows setting defined in the package.
+	Windows []time.Duration
+}
+
+// FingerprintCount holds the number of records with the same fingerprint within a window.
+type FingerprintCount struct {
+	// Count is the number of records with the fingerprint.
+	Count uint64 `json:"count"`
+
+	// Fingerprint is the fingerprint of the records, as returned by [RecordFingerprint].
+	Fingerprint string `json:"fingerprint"`
+
+	// Message is the message of the most recent record with the fingerprint.
+	Message string `json:"message"`
+}
+
+// StatsWindow holds the number of records handled within a single window.
+type StatsWindow struct {
+	// Fingerprints holds the most frequent fingerprints within the window, from most to least frequent.
+	Fingerprints []FingerprintCount `json:"fingerprints,omitempty"`
+
+	// Levels holds the number of records by level name (eg: "INFO").
+	Levels map[string]uint64 `json:"levels"`
+
+	// Total is the number of records within the window.
+	Total uint64 `json:"total"`
+
+	// Window is the length of the window.
+	Window time.Duration `json:"window"`
+}
+
+// MarshalJSON encodes the window as JSON with the length of the window written as a duration string (eg: "5m").
+func (w StatsWindow) MarshalJSON() ([]byte, error) {
+	type jsonStatsWindow StatsWindow
+	return json.Marshal(struct {
+		jsonStatsWindow
+		Window string `json:"window"`
+	}{
+		jsonStatsWindow: jsonStatsWindow(w),
+		Window:          statsWindowName(w.Window),
+	})
+}
+
+// StatsAggregatorHandler is a handler which keeps rolling counts of the records passed to an underlying handler, by
+// level and by fingerprint, over one or more sliding windows.
+//
+// The counts are available from [StatsAggregatorHandler.Stats], as JSON from [StatsAggregatorHandler.ServeHTTP] or as
+// periodic summary records logged by [StatsAggregatorHandler.Run], which provides basic visibility into unusual
+// spikes of errors or repeated messages without external tooling. Handlers derived using WithAttrs or WithGroup share
+// the same counts.
+//
+// All methods are safe to call concurrently.
+type StatsAggregatorHandler struct {
+	// unexported variables
+	handler slog.Handler          // underlying handler
+	state   *statsAggregatorState // state shared by all derived handlers
+}
+
+// ensure [StatsAggregatorHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &StatsAggregatorHandler{}
+
+// statsAggregatorState holds the counts shared by all handlers derived from the same aggregator.
+type statsAggregatorState struct {
+	buckets []statsBucket          // ring of buckets covering the largest window
+	mu      sync.Mutex             // protects the buckets
+	options StatsAggregatorOptions // immutable aggregator options
+	width   time.Duration          // width of each bucket
+}
+
+// statsBucket holds the counts of the records within a single bucket of time.
+type statsBucket struct {
+	fingerprints map[string]*FingerprintCount // counts by fingerprint
+	index        int64                        // index of the bucket since the zero Unix time
+	levels       map[slog.Level]uint64        // counts by level
+}
+
+// NewStatsAggregatorHandler creates a new [StatsAggregatorHandler] which counts the records passed to the given
+// handler.
+//
+// If the handler is nil, records are counted but not written anywhere and the count level defaults to
+// [slog.LevelInfo]. Call [StatsAggregatorHandler.Run] to start
+// logging summaries.
+//
+// This function may return an error with any of the following codes:
+//   - [OptionsValidationError]: the interval or a window is negative or 0
+func NewStatsAggregatorHandler(h slog.Handler, options StatsAggregatorOptions) (*StatsAggregatorHandler,
+	xerrors.Error) {
+	if options.Interval < 0 {
+		return nil, xerrors.Newf(OptionsValidationError, "invalid stats aggregator options: interval: %s: must not be "+
+			"negative", options.Interval).WithAttr("fields", []string{"interval"})
+	}
+	for _, window := range options.Windows {
+		if window <= 0 {
+			return nil, xerrors.Newf(OptionsValidationError, "invalid stats aggregator options: windows: %s: must be "+
+				"greater than 0", window).WithAttr("fields", []string{"windows"})
+		}
+	}
+	if h == nil {
+		h = slog.DiscardHandler
+		if options.CountLevel == nil {
+			options.CountLevel = slog.LevelInfo
+		}
+	}
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	if options.Interval == 0 {
+		options.Interval = DefaultStatsAggregatorInterval
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	if options.Message == "" {
+		options.Message = DefaultStatsAggregatorMessage
+	}
+	if options.TopFingerprints == 0 {
+		options.TopFingerprints = DefaultStatsAggregatorTopFingerprints
+	}
+	if len(options.Windows) == 0 {
+		options.Windows = DefaultStatsAggregatorWindows
+	}
+	options.Windows = slices.Clone(options.Windows)
+	slices.Sort(options.Windows)
+	options.Windows = slices.Compact(options.Windows)
+
+	width := max(options.Windows[0]/60, time.Second)
+	count := (options.Windows[len(options.Windows)-1] + width - 1) / width
+	return &StatsAggregatorHandler{
+		handler: h,
+		state: &statsAggregatorState{
+			buckets: make([]statsBucket, count),
+			options: options,
+			width:   width,
+		},
+	}, nil
+}
+
+// ChildHandlers returns the underlying handler.
+func (s *StatsAggregatorHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{s.handler}
+}
+
+// Emit logs a single record holding a summary of the current statistics.
+//
+// The record holds a group for each window, keyed by the length of the window (eg: "5m"), which holds the number
+// of records within the window ("total"), a group holding the number of records by level ("levels") and the most
+// frequent fingerprints ("fingerprints").
+func (s *StatsAggregatorHandler) Emit(ctx context.Context) {
+	options := s.state.options
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := options.Level.Level()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	windows := s.Stats()
+	attrs := make([]slog.Attr, 0, len(windows))
+	for _, w := range windows {
+		levels := make([]slog.Attr, 0, len(w.Levels))
+		for _, name := range slices.Sorted(maps.Keys(w.Levels)) {
+			levels = append(levels, slog.Uint64(name, w.Levels[name]))
+		}
+		group := []any{
+			slog.Uint64("total", w.Total),
+			slog.Attr{Key: "levels", Value: slog.GroupValue(levels...)},
+		}
+		if len(w.Fingerprints) > 0 {
+			group = append(group, slog.Any("fingerprints", w.Fingerprints))
+		}
+		attrs = append(attrs, slog.Group(statsWindowName(w.Window), group...))
+	}
+	logger.LogAttrs(ctx, level, options.Message, attrs...)
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level or records at the given level
+// are counted.
+func (s *StatsAggregatorHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	countLevel := s.state.options.CountLevel
+	return (countLevel != nil && level >= countLevel.Level()) || s.handler.Enabled(ctx, level)
+}
+
+// Handle counts the record and passes it to the underlying handler if the handler is enabled for its level.
+func (s *StatsAggregatorHandler) Handle(ctx context.Context, r slog.Record) error {
+	enabled := s.handler.Enabled(ctx, r.Level)
+	countLevel := s.state.options.CountLevel
+	if enabled || (countLevel != nil && r.Level >= countLevel.Level()) {
+		s.state.add(r)
+	}
+	if !enabled {
+		return nil
+	}
+	return s.handler.Handle(ctx, r)
+}
+
+// Options returns a copy of the aggregator's options.
+func (s *StatsAggregatorHandler) Options() any {
+	return s.state.options
+}
+
+// Run logs a summary of the statistics at the configured interval until the context is canceled.
+//
+// This function blocks, so it is typically called in its own goroutine.
+func (s *StatsAggregatorHandler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.state.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.Emit(ctx)
+	}
+}
+
+// ServeHTTP writes the current statistics to the response as a JSON array holding one object for each window.
+func (s *StatsAggregatorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Stats())
+}
+
+// Stats returns the number of records counted within each window, from the smallest to the largest window.
+func (s *StatsAggregatorHandler) Stats() []StatsWindow {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	current := st.options.Clock.Now().UnixNano() / int64(st.width)
+	windows := make([]StatsWindow, 0, len(st.options.Windows))
+	for _, window := range st.options.Windows {
+		oldest := current - int64((window+st.width-1)/st.width)
+		stats := StatsWindow{
+			Levels: map[string]uint64{},
+			Window: window,
+		}
+		fingerprints := map[string]*FingerprintCount{}
+		latest := map[string]int64{}
+		for i := range st.buckets {
+			b := &st.buckets[i]
+			if b.index <= oldest || b.index > current {
+				continue
+			}
+			for level, count := range b.levels {
+				stats.Levels[level.String()] += count
+				stats.Total += count
+			}
+			for fingerprint, fc := range b.fingerprints {
+				total, ok := fingerprints[fingerprint]
+				if !ok {
+					total = &FingerprintCount{Fingerprint: fingerprint}
+					fingerprints[fingerprint] = total
+				}
+				if b.index >= latest[fingerprint] {
+					total.Message = fc.Message
+					latest[fingerprint] = b.index
+				}
+				total.Count += fc.Count
+			}
+		}
+		stats.Fingerprints = topFingerprints(fingerprints, st.options.TopFingerprints)
+		windows = append(windows, stats)
+	}
+	return windows
+}
+
+// Type returns the type of the handler.
+func (s *StatsAggregatorHandler) Type() string {
+	return "stats_aggregator"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (s *StatsAggregatorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return s
+	}
+	return &StatsAggregatorHandler{
+		handler: s.handler.WithAttrs(attrs),
+		state:   s.state,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (s *StatsAggregatorHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return &StatsAggregatorHandler{
+		handler: s.handler.WithGroup(name),
+		state:   s.state,
+	}
+}
+
+// add counts the given record in the bucket for the current time.
+func (st *statsAggregatorState) add(r slog.Record) {
+	var fingerprint string
+	if st.options.TopFingerprints > 0 {
+		fingerprint = RecordFingerprint(r)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	index := st.options.Clock.Now().UnixNano() / int64(st.width)
+	b := &st.buckets[index%int64(len(st.buckets))]
+	if b.index != index || b.levels == nil {
+		*b = statsBucket{
+			fingerprints: map[string]*FingerprintCount{},
+			index:        index,
+			levels:       map[slog.Level]uint64{},
+		}
+	}
+	b.levels[r.Level]++
+	if fingerprint == "" {
+		return
+	}
+	fc, ok := b.fingerprints[fingerprint]
+	if !ok {
+		fc = &FingerprintCount{Fingerprint: fingerprint}
+		b.fingerprints[fingerprint] = fc
+	}
+	fc.Count++
+	fc.Message = r.Message
+}
+
+// statsWindowName returns the length of a window as a short duration string (eg: "5m" rather than "5m0s").
+func statsWindowName(d time.Duration) string {
+	name := d.String()
+	if strings.HasSuffix(name, "m0s") {
+		name = strings.TrimSuffix(name, "0s")
+	}
+	if strings.HasSuffix(name, "h0m") {
+		name = strings.TrimSuffix(name, "0m")
+	}
+	return name
+}
+
+// topFingerprints returns up to n of the given fingerprint counts, from most to least frequent.
+func topFingerprints(fingerprints map[string]*FingerprintCount, n int) []FingerprintCount {
+	if n <= 0 || len(fingerprints) == 0 {
+		return nil
+	}
+	counts := make([]FingerprintCount, 0, len(fingerprints))
+	for _, fc := range fingerprints {
+		counts = append(counts, *fc)
+	}
+	slices.SortFunc(counts, func(a, b FingerprintCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return counts[:min(n, len(counts))]
+}
@@ -0,0 +1,120 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+var (
+	// DefaultSubjectKey is the default key of the attribute which holds the identifier of the data subject (ie: the
+	// person) a record relates to.
+	//
+	// This value is used when the key in [SubjectHandlerOptions] is empty and by the redact package to find the
+	// records for a subject.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#SubjectHandlerOptions
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/redact
+	DefaultSubjectKey = "subject_id"
+)
+
+// subjectCtxKey is just a key for storing a subject identifier in a context.
+type subjectCtxKey struct{}
+
+// SubjectHandlerOptions holds the options for the handler returned by [NewSubjectHandler].
+type SubjectHandlerOptions struct {
+	// Key is the key of the attribute which holds the subject identifier.
+	//
+	// The default behavior is defined by the default subject key setting defined in the package.
+	Key string
+}
+
+// subjectHandler is the [slog.Handler] returned by [NewSubjectHandler].
+type subjectHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	key string // key of the subject attribute
+}
+
+// ensure [subjectHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &subjectHandler{}
+
+// AddSubjectToContext adds the given subject identifier to the existing context and returns a new context.
+//
+// Records logged with the context through a handler returned by [NewSubjectHandler] are tagged with the identifier so
+// that they can later be found and erased using the redact package.
+func AddSubjectToContext(ctx context.Context, subjectID string) context.Context {
+	return context.WithValue(ctx, subjectCtxKey{}, subjectID)
+}
+
+// NewSubjectHandler returns a new [slog.Handler] which tags each record logged with a context holding a subject
+// identifier, stored using [AddSubjectToContext], with an attribute holding the identifier.
+//
+// The attribute is added at the top level of the record, outside of any groups, so that tools searching for the
+// records of a subject only need to look in one place. Records which already hold the attribute at the top level are
+// not changed.
+func NewSubjectHandler(h slog.Handler, options SubjectHandlerOptions) slog.Handler {
+	if options.Key == "" {
+		options.Key = DefaultSubjectKey
+	}
+	return &subjectHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		key:             options.Key,
+	}
+}
+
+// SubjectFromContext returns the subject identifier stored in the context or an empty string if there isn't one.
+func SubjectFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if subjectID, ok := ctx.Value(subjectCtxKey{}).(string); ok {
+		return subjectID
+	}
+	return ""
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *subjectHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *subjectHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the subject attribute, if the context holds a subject identifier, combines the handler's attributes
+// with the record's attributes and passes the resulting record to the underlying handler.
+func (h *subjectHandler) Handle(ctx context.Context, r slog.Record) error {
+	if subjectID := SubjectFromContext(ctx); subjectID != "" {
+		return h.handler.Handle(ctx, h.stamp(r, slog.String(h.key, subjectID)))
+	}
+	return h.handler.Handle(ctx, h.stamp(r))
+}
+
+// Options returns the handler's options.
+func (h *subjectHandler) Options() any {
+	return SubjectHandlerOptions{
+		Key: h.key,
+	}
+}
+
+// Type returns the type of the handler.
+func (h *subjectHandler) Type() string {
+	return "subject"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *subjectHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *subjectHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
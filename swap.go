@@ -0,0 +1,201 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.innotegrity.dev/xerrors"
+)
+
+var (
+	// DefaultSwapDrainTimeout is the default maximum amount of time to wait for the records being handled by a handler
+	// replaced in a [SwappableHandler] to finish before the handler is closed anyway.
+	//
+	// This value is used by [ManagedLogger] and by the remote configuration loader in the handlers package when they
+	// replace a handler tree.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#SwappableHandler.SwapAndDrain
+	DefaultSwapDrainTimeout = 5 * time.Second
+)
+
+// SwappableHandler is an [slog.Handler] which passes records to a target handler that can be replaced at any time,
+// such as when a configuration file is reloaded.
+//
+// Handlers derived using WithAttrs or WithGroup share the target with the handler they were derived from, so swapping
+// the target of any one of them affects all of them. Attributes and groups added to a derived handler are re-applied
+// to the new target the first time a record is handled after a swap. All methods are safe to call concurrently.
+type SwappableHandler struct {
+	// unexported variables
+	cache  atomic.Pointer[swappableHandlerCache]   // derived handler for the current target
+	ops    []func(slog.Handler) slog.Handler       // immutable WithAttrs and WithGroup calls to apply to the target
+	target *atomic.Pointer[swappableHandlerTarget] // shared target handler
+}
+
+// swappableHandlerCache holds the handler derived from a particular target.
+type swappableHandlerCache struct {
+	handler slog.Handler            // target with all of the handler's attributes and groups applied
+	target  *swappableHandlerTarget // target from which the handler was derived
+}
+
+// swappableHandlerTarget holds the handler to which records are passed.
+type swappableHandlerTarget struct {
+	drained   chan struct{} // closed once the target has been replaced and no records are being handled by it
+	drainOnce sync.Once     // closes the drained channel only once
+	handler   slog.Handler  // target handler
+	inflight  atomic.Int64  // number of records being handled by the target
+	replaced  atomic.Bool   // whether or not the target has been replaced by [SwappableHandler.SwapAndDrain]
+}
+
+// NewSwappableHandler returns a new [SwappableHandler] which initially passes records to the given handler.
+//
+// If the handler is nil, records are discarded until a handler is swapped in.
+func NewSwappableHandler(h slog.Handler) *SwappableHandler {
+	if h == nil {
+		h = slog.DiscardHandler
+	}
+	target := &atomic.Pointer[swappableHandlerTarget]{}
+	target.Store(newSwappableHandlerTarget(h))
+	return &SwappableHandler{
+		target: target,
+	}
+}
+
+// Enabled returns whether or not the current target handler is enabled for the given level.
+func (h *SwappableHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.current().Enabled(ctx, level)
+}
+
+// Handle passes the record to the current target handler.
+func (h *SwappableHandler) Handle(ctx context.Context, r slog.Record) error {
+	for {
+		target := h.target.Load()
+		target.inflight.Add(1)
+		if target.replaced.Load() {
+			// the target was replaced after it was loaded, so use the new one instead
+			target.done()
+			continue
+		}
+		err := h.derive(target).Handle(ctx, r)
+		target.done()
+		return err
+	}
+}
+
+// Handler returns the current target handler without any of the attributes or groups added to this handler.
+func (h *SwappableHandler) Handler() slog.Handler {
+	return h.target.Load().handler
+}
+
+// Swap replaces the target handler and returns the previous target.
+//
+// Records which are already being handled by the previous target are not interrupted, so use
+// [SwappableHandler.SwapAndDrain] instead if the previous target is going to be closed. If the handler is nil,
+// records are discarded until another handler is swapped in.
+func (h *SwappableHandler) Swap(handler slog.Handler) slog.Handler {
+	if handler == nil {
+		handler = slog.DiscardHandler
+	}
+	return h.target.Swap(newSwappableHandlerTarget(handler)).handler
+}
+
+// SwapAndDrain replaces the target handler like [SwappableHandler.Swap] and then waits for the records already being
+// handled by the previous target to finish, so that the previous target can be closed safely, before returning it.
+//
+// The previous target is returned even if the context is canceled before the records finish.
+//
+// This function may return an error with any of the following codes:
+//   - [HandleRecordError]: the context was canceled before the records being handled by the previous target finished
+func (h *SwappableHandler) SwapAndDrain(ctx context.Context, handler slog.Handler) (slog.Handler, xerrors.Error) {
+	if handler == nil {
+		handler = slog.DiscardHandler
+	}
+	old := h.target.Swap(newSwappableHandlerTarget(handler))
+	old.replaced.Store(true)
+	if old.inflight.Load() == 0 {
+		old.drainOnce.Do(func() { close(old.drained) })
+	}
+
+	select {
+	case <-old.drained:
+		return old.handler, nil
+	case <-ctx.Done():
+		return old.handler, xerrors.Wrapf(HandleRecordError, ctx.Err(), "replaced handler still has %d record(s) "+
+			"being handled: %s", old.inflight.Load(), ctx.Err().Error())
+	}
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *SwappableHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	attrs = slices.Clone(attrs)
+	return &SwappableHandler{
+		ops: append(slices.Clip(h.ops), func(handler slog.Handler) slog.Handler {
+			return handler.WithAttrs(attrs)
+		}),
+		target: h.target,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *SwappableHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SwappableHandler{
+		ops: append(slices.Clip(h.ops), func(handler slog.Handler) slog.Handler {
+			return handler.WithGroup(name)
+		}),
+		target: h.target,
+	}
+}
+
+// current returns the current target handler with all of the handler's attributes and groups applied.
+func (h *SwappableHandler) current() slog.Handler {
+	return h.derive(h.target.Load())
+}
+
+// derive returns the given target handler with all of the handler's attributes and groups applied.
+func (h *SwappableHandler) derive(target *swappableHandlerTarget) slog.Handler {
+	if len(h.ops) == 0 {
+		return target.handler
+	}
+	if cache := h.cache.Load(); cache != nil && cache.target == target {
+		return cache.handler
+	}
+
+	handler := target.handler
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	h.cache.Store(&swappableHandlerCache{
+		handler: handler,
+		target:  target,
+	})
+	return handler
+}
+
+// newSwappableHandlerTarget returns a new target holding the given handler.
+func newSwappableHandlerTarget(h slog.Handler) *swappableHandlerTarget {
+	return &swappableHandlerTarget{
+		drained: make(chan struct{}),
+		handler: h,
+	}
+}
+
+// done marks a record as no longer being handled by the target, signaling that the target is drained if it was the
+// last record being handled after the target was replaced.
+func (t *swappableHandlerTarget) done() {
+	if t.inflight.Add(-1) == 0 && t.replaced.Load() {
+		t.drainOnce.Do(func() { close(t.drained) })
+	}
+}
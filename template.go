@@ -0,0 +1,236 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+var (
+	// DefaultMessageTemplateKey is the default key of the attribute which holds the template from which the message of
+	// a record was rendered.
+	//
+	// This value is used when the template key in [TemplateHandlerOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TemplateHandlerOptions
+	DefaultMessageTemplateKey = "message_template"
+)
+
+// TemplateHandlerOptions holds the options for the handler returned by [NewTemplateHandler].
+type TemplateHandlerOptions struct {
+	// Catalog is the catalog from which messages are translated when a locale is set.
+	//
+	// The default behavior is defined by the default message catalog setting defined in the package.
+	Catalog MessageCatalog
+
+	// Locale is the locale (eg: "de-DE") into which messages are translated before they are rendered.
+	//
+	// When set, the message of each record is looked up in the catalog, falling back on the locale's base language
+	// (eg: "de") and then on the original message, and the original message is added in an attribute at the top level
+	// of the record so that records can be identified no matter which language they were logged in.
+	//
+	// The default behavior is to not translate messages.
+	Locale string
+
+	// MessageIDKey is the key of the attribute which holds the original message when a locale is set.
+	//
+	// The default behavior is defined by the default message ID key setting defined in the package.
+	MessageIDKey string
+
+	// TemplateKey is the key of the attribute which holds the message template.
+	//
+	// The default behavior is defined by the default message template key setting defined in the package.
+	TemplateKey string
+}
+
+// templateHandler is the [slog.Handler] returned by [NewTemplateHandler].
+type templateHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	options TemplateHandlerOptions // immutable handler options
+}
+
+// ensure [templateHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &templateHandler{}
+
+// NewTemplateHandler returns a new [slog.Handler] which treats the message of each record as a template holding named
+// placeholders (eg: "user {user_id} logged in"), replacing each placeholder with the value of the attribute with the
+// same key.
+//
+// This allows records to be logged using any [slog.Logger] function with the parameters given as ordinary attributes:
+//
+//	logger.Info("user {user_id} logged in", "user_id", id)
+//
+// Each placeholder is first looked up among the attributes of the record itself, then among all of the record's
+// attributes by its full key, where the keys of any groups are separated by dots (eg: "{request.id}"), and finally
+// among the attributes inside of any group (eg: when another handler has already nested the record's attributes inside
+// of its groups). Placeholders without a matching attribute are left as they are. Use "{{" and "}}" to write literal
+// braces.
+//
+// The rendered message is passed to the underlying handler and, if any placeholder was replaced, the template is added
+// in an attribute at the top level of the record, outside of any groups, so that sinks can group records by template.
+// The parameters are left in place as ordinary attributes. The template attribute always holds the original message,
+// even when the message is translated using a [MessageCatalog]. [RecordFingerprint] uses the template in place of the
+// message when it is present, so wrap this handler in the handler returned by [NewFingerprintHandler] or the other way
+// around and records from the same call site still share a fingerprint.
+func NewTemplateHandler(h slog.Handler, options TemplateHandlerOptions) slog.Handler {
+	if options.Catalog == nil {
+		options.Catalog = DefaultMessageCatalog
+	}
+	if options.MessageIDKey == "" {
+		options.MessageIDKey = DefaultMessageIDKey
+	}
+	if options.TemplateKey == "" {
+		options.TemplateKey = DefaultMessageTemplateKey
+	}
+	return &templateHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		options:         options,
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *templateHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *templateHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle translates and renders the record's message, combines the handler's attributes with the record's attributes
+// and passes the resulting record to the underlying handler.
+func (h *templateHandler) Handle(ctx context.Context, r slog.Record) error {
+	recAttrs := recordAttrs(r)
+	attrs := appendGroupedAttrs(h.attrs, h.groups, recAttrs)
+
+	template := r.Message
+	if h.options.Locale != "" && h.options.Catalog != nil {
+		if translated, ok := translateMessage(h.options.Catalog, h.options.Locale, r.Message); ok {
+			template = translated
+		}
+	}
+	msg, ok := renderTemplate(template, func(name string) (slog.Value, bool) {
+		if v, ok := lookupAttr(recAttrs, []string{name}); ok {
+			return v, true
+		}
+		if v, ok := lookupAttr(attrs, strings.Split(name, ".")); ok {
+			return v, true
+		}
+		return findAttr(attrs, name)
+	})
+	var stamps []slog.Attr
+	if h.options.Locale != "" {
+		stamps = append(stamps, slog.String(h.options.MessageIDKey, r.Message))
+	}
+	if ok {
+		stamps = append(stamps, slog.String(h.options.TemplateKey, r.Message))
+	}
+	record := stampRecord(r, attrs, stamps...)
+	record.Message = msg
+	return h.handler.Handle(ctx, record)
+}
+
+// Options returns the handler's options.
+func (h *templateHandler) Options() any {
+	return h.options
+}
+
+// Type returns the type of the handler.
+func (h *templateHandler) Type() string {
+	return "template"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *templateHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *templateHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
+
+// findAttr returns the value of the last attribute with the given key at any depth.
+func findAttr(attrs []slog.Attr, key string) (slog.Value, bool) {
+	for _, attr := range slices.Backward(attrs) {
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			if v, ok := findAttr(value.Group(), key); ok {
+				return v, true
+			}
+		} else if attr.Key == key {
+			return value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
+// lookupAttr returns the value of the attribute found by following the given path of keys through any groups.
+func lookupAttr(attrs []slog.Attr, path []string) (slog.Value, bool) {
+	for _, attr := range slices.Backward(attrs) {
+		if attr.Key == "" && attr.Value.Kind() == slog.KindGroup {
+			if v, ok := lookupAttr(attr.Value.Group(), path); ok {
+				return v, true
+			}
+			continue
+		}
+		if attr.Key != path[0] {
+			continue
+		}
+		value := attr.Value.Resolve()
+		if len(path) == 1 {
+			return value, true
+		}
+		if value.Kind() == slog.KindGroup {
+			return lookupAttr(value.Group(), path[1:])
+		}
+		return slog.Value{}, false
+	}
+	return slog.Value{}, false
+}
+
+// renderTemplate replaces each "{name}" placeholder in the template with the value returned by the lookup function,
+// leaving placeholders which cannot be found as they are, and replaces "{{" and "}}" with literal braces.
+//
+// The rendered string is returned along with whether or not any placeholder was replaced.
+func renderTemplate(template string, lookup func(name string) (slog.Value, bool)) (string, bool) {
+	if !strings.ContainsAny(template, "{}") {
+		return template, false
+	}
+
+	var b strings.Builder
+	replaced := false
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(template) && template[i+1] == c:
+			b.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexAny(template[i+1:], "{} \t\r\n")
+			if end <= 0 || template[i+1+end] != '}' {
+				b.WriteByte(c)
+				continue
+			}
+			name := template[i+1 : i+1+end]
+			if value, ok := lookup(name); ok {
+				b.WriteString(value.String())
+				replaced = true
+			} else {
+				b.WriteString(template[i : i+2+end])
+			}
+			i += end + 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), replaced
+}
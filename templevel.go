@@ -0,0 +1,96 @@
+package xlog
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTemporaryLevel sets the minimum logging level of every handler in the tree rooted at the given handler which
+// implements [LevelVarHandler] to the given level and returns a function which restores their previous levels.
+//
+// The previous levels are restored automatically once the given duration has elapsed unless the returned function is
+// called first. If the duration is 0 or less, the levels are only restored when the function is called. The function
+// may be called any number of times, but only restores the levels once. This is useful for raising the verbosity of
+// a running application while debugging an incident (eg: from an admin endpoint) without having to remember to lower
+// it again.
+//
+// If the levels are changed temporarily more than once at the same time, they should be restored in the reverse
+// order to which they were changed.
+func WithTemporaryLevel(h slog.Handler, level slog.Level, d time.Duration) func() {
+	type savedLevel struct {
+		level    slog.Level     // level before the change
+		levelVar *slog.LevelVar // level variable which was changed
+	}
+
+	var saved []savedLevel
+	var walk func(h slog.Handler)
+	walk = func(h slog.Handler) {
+		if lh, ok := h.(LevelVarHandler); ok {
+			if levelVar := lh.GetLevelVar(); levelVar != nil {
+				saved = append(saved, savedLevel{
+					level:    levelVar.Level(),
+					levelVar: levelVar,
+				})
+				levelVar.Set(level)
+			}
+		}
+		if eh, ok := h.(ExtendedHandler); ok {
+			for _, child := range eh.ChildHandlers() {
+				walk(child)
+			}
+		}
+	}
+	walk(h)
+
+	return restoreAfter(d, func() {
+		for _, s := range slices.Backward(saved) {
+			s.levelVar.Set(s.level)
+		}
+	})
+}
+
+// WithTemporaryLoggerLevel sets the level override for the given logger name (see [SetLoggerLevel]) to the given level
+// and returns a function which restores the previous override, if any.
+//
+// The previous override is restored automatically once the given duration has elapsed unless the returned function is
+// called first. If the duration is 0 or less, the override is only restored when the function is called. The function
+// may be called any number of times, but only restores the override once.
+func WithTemporaryLoggerLevel(name string, level slog.Level, d time.Duration) func() {
+	name = strings.TrimSpace(name)
+	_loggers.mu.Lock()
+	previous := _loggers.levels[name]
+	_loggers.levels[name] = level
+	_loggers.gen.Add(1)
+	_loggers.mu.Unlock()
+
+	return restoreAfter(d, func() {
+		SetLoggerLevel(name, previous)
+	})
+}
+
+// restoreAfter returns a function which calls the given restore function once, either when it is called or once the
+// given duration has elapsed, whichever comes first.
+func restoreAfter(d time.Duration, restore func()) func() {
+	var once sync.Once
+	var timer *time.Timer
+	var mu sync.Mutex
+	fn := func() {
+		once.Do(func() {
+			mu.Lock()
+			if timer != nil {
+				timer.Stop()
+			}
+			mu.Unlock()
+			restore()
+		})
+	}
+	if d > 0 {
+		mu.Lock()
+		timer = time.AfterFunc(d, fn)
+		mu.Unlock()
+	}
+	return fn
+}
@@ -0,0 +1,136 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+var (
+	// DefaultClockSkewKey is the default key of the attribute which flags a record whose time is further in the future
+	// or the past than the skew tolerance allows.
+	//
+	// This value is used when the skew key in [TimeNormalizeOptions] is empty.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog#TimeNormalizeOptions
+	DefaultClockSkewKey = "clock_skew"
+)
+
+// TimeNormalizeOptions holds the options for the handler returned by [NewTimeNormalizeHandler].
+type TimeNormalizeOptions struct {
+	// Clock is the source of the current time against which the skew of each record is measured.
+	//
+	// The default behavior is defined by the default clock defined in the package.
+	Clock Clock
+
+	// Offset is added to the time of each record to correct a clock which is known to be wrong (eg: -90s for a clock
+	// which runs 90 seconds fast).
+	//
+	// The default behavior is to not correct the time of records.
+	Offset time.Duration
+
+	// SkewKey is the key of the attribute which flags a record whose time is outside of the skew tolerance.
+	//
+	// The default behavior is defined by the default clock skew key setting defined in the package.
+	SkewKey string
+
+	// SkewTolerance is the maximum difference between the corrected time of a record and the current time before the
+	// record is flagged with an attribute holding the difference, which is positive for records in the future.
+	//
+	// The default behavior is to not flag any records.
+	SkewTolerance time.Duration
+
+	// UTC indicates whether or not to convert the time of each record to UTC.
+	//
+	// The default behavior is to leave the time zone of records unchanged.
+	UTC bool
+}
+
+// timeNormalizeHandler is the [slog.Handler] returned by [NewTimeNormalizeHandler].
+type timeNormalizeHandler struct {
+	stampingWrapper
+
+	// unexported variables
+	options TimeNormalizeOptions // immutable handler options
+}
+
+// ensure [timeNormalizeHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &timeNormalizeHandler{}
+
+// NewTimeNormalizeHandler returns a new [slog.Handler] which normalizes the time of each record by applying an offset
+// correction and converting it to UTC, and flags records whose time is skewed too far from the current time, which is
+// useful for devices with drifting clocks which ship their logs to a central system (eg: a SIEM).
+//
+// Records with a zero time are passed on unchanged. The skew attribute is always added at the top level of a record,
+// outside of any groups.
+func NewTimeNormalizeHandler(h slog.Handler, options TimeNormalizeOptions) slog.Handler {
+	if options.Clock == nil {
+		options.Clock = DefaultClock
+	}
+	if options.SkewKey == "" {
+		options.SkewKey = DefaultClockSkewKey
+	}
+	return &timeNormalizeHandler{
+		stampingWrapper: stampingWrapper{handler: h},
+		options:         options,
+	}
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *timeNormalizeHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level.
+func (h *timeNormalizeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle normalizes the time of the record, flags it if its time is skewed and passes it to the underlying handler.
+func (h *timeNormalizeHandler) Handle(ctx context.Context, r slog.Record) error {
+	t := r.Time
+	var skewAttr *slog.Attr
+	if !t.IsZero() {
+		t = t.Add(h.options.Offset)
+		if h.options.UTC {
+			t = t.UTC()
+		}
+		if h.options.SkewTolerance > 0 {
+			if skew := t.Sub(h.options.Clock.Now()); skew > h.options.SkewTolerance || -skew > h.options.SkewTolerance {
+				attr := slog.Duration(h.options.SkewKey, skew)
+				skewAttr = &attr
+			}
+		}
+	}
+
+	record := slog.NewRecord(t, r.Level, r.Message, r.PC)
+	if skewAttr != nil {
+		record.AddAttrs(*skewAttr)
+	}
+	record.AddAttrs(h.mergeAttrs(r)...)
+	return h.handler.Handle(ctx, record)
+}
+
+// Options returns the handler's options.
+func (h *timeNormalizeHandler) Options() any {
+	return h.options
+}
+
+// Type returns the type of the handler.
+func (h *timeNormalizeHandler) Type() string {
+	return "time_normalize"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *timeNormalizeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withStampedAttrs(h, attrs)
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *timeNormalizeHandler) WithGroup(name string) slog.Handler {
+	return withStampedGroup(h, name)
+}
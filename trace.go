@@ -0,0 +1,223 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// TraceEnvVar is the name of the environment variable from which the trace writer is set when the package is
+	// initialized.
+	//
+	// Set it to "1", "true" or "stderr" to trace to [os.Stderr], "stdout" to trace to [os.Stdout] or the path of a file
+	// to which traces are appended. Tracing is disabled if the variable is empty, "0" or "false".
+	TraceEnvVar = "XLOG_TRACE"
+
+	// TraceEventBatchFlushed is the trace event logged when a batch of records is delivered to its destination.
+	TraceEventBatchFlushed = "batch_flushed"
+
+	// TraceEventBatchFailed is the trace event logged when a batch of records fails to be delivered to its
+	// destination.
+	TraceEventBatchFailed = "batch_failed"
+
+	// TraceEventKey is the key of the attribute holding the name of the event in trace records.
+	TraceEventKey = "xlog_trace"
+
+	// TraceEventRecordAccepted is the trace event logged when a handler accepts a record.
+	TraceEventRecordAccepted = "record_accepted"
+
+	// TraceEventRecordDropped is the trace event logged when a handler drops records (see [NotifyDropped]).
+	TraceEventRecordDropped = "record_dropped"
+
+	// TraceEventRecordFailed is the trace event logged when a handler fails to handle a record.
+	TraceEventRecordFailed = "record_failed"
+
+	// TraceEventRecordFiltered is the trace event logged when a handler is not enabled for the level of a record.
+	TraceEventRecordFiltered = "record_filtered"
+
+	// TraceEventRetryScheduled is the trace event logged when a handler schedules another attempt to reach its
+	// destination.
+	TraceEventRetryScheduled = "retry_scheduled"
+)
+
+var (
+	// tracer holds the handler writing to the writer designated using [SetTraceWriter].
+	tracer atomic.Pointer[traceHolder]
+)
+
+// traceHolder holds the trace writer and the handler writing to it so that they can be stored atomically.
+type traceHolder struct {
+	handler slog.Handler // handler writing trace records
+	w       io.Writer    // designated trace writer
+}
+
+// TraceHandler is a handler which traces whether each record is filtered, accepted or fails to be handled by the
+// underlying handler (see [Trace]).
+//
+// The handler adds nothing but a check of [TraceEnabled] when tracing is disabled, so it may be left in place in
+// production and enabled on demand using [TraceEnvVar] or [SetTraceWriter].
+type TraceHandler struct {
+	// unexported variables
+	handler slog.Handler // underlying handler
+	name    string       // name of the handler in trace records
+}
+
+// ensure [TraceHandler] implements [ExtendedHandler] interface.
+var _ ExtendedHandler = &TraceHandler{}
+
+func init() {
+	value := os.Getenv(TraceEnvVar)
+	switch strings.ToLower(value) {
+	case "", "0", "false":
+	case "1", "true", "stderr":
+		SetTraceWriter(os.Stderr)
+	case "stdout":
+		SetTraceWriter(os.Stdout)
+	default:
+		f, err := os.OpenFile(value, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			LogInternal(context.Background(), slog.LevelWarn, InternalEventBuild, "failed to open trace file",
+				slog.String("path", value), slog.String("error", err.Error()))
+			return
+		}
+		SetTraceWriter(f)
+	}
+}
+
+// NewTraceHandler creates a new [TraceHandler] which passes records to the given handler and traces them under the
+// given name.
+//
+// If the name is empty, the type of the underlying handler is used instead (see [Capabilities]).
+func NewTraceHandler(h slog.Handler, name string) *TraceHandler {
+	if name == "" {
+		name = Capabilities(h).Type
+	}
+	return &TraceHandler{
+		handler: h,
+		name:    name,
+	}
+}
+
+// SetTraceWriter designates the writer to which the package traces its own decisions (eg: records accepted or
+// filtered, batches flushed and retries scheduled) and returns the previous writer, if any.
+//
+// Trace records are written as text (see [slog.TextHandler]) at [slog.LevelDebug] with the name of the event in the
+// [TraceEventKey] attribute. Pass nil to disable tracing. Tracing is always disabled when the package is built with
+// the "xlog_notrace" build tag, in which case this function does nothing.
+func SetTraceWriter(w io.Writer) io.Writer {
+	if !traceCompiled {
+		return nil
+	}
+	var holder *traceHolder
+	if w != nil {
+		holder = &traceHolder{
+			handler: slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			w:       w,
+		}
+	}
+	if prev := tracer.Swap(holder); prev != nil {
+		return prev.w
+	}
+	return nil
+}
+
+// Trace writes a trace record for the given event to the writer designated using [SetTraceWriter] or [TraceEnvVar].
+//
+// Nothing is written if tracing is disabled. Handlers in this package call this function automatically. Custom
+// handlers may call it to trace their own decisions, checking [TraceEnabled] first if building the attributes is
+// expensive.
+func Trace(ctx context.Context, event, msg string, attrs ...slog.Attr) {
+	if !traceCompiled {
+		return
+	}
+	holder := tracer.Load()
+	if holder == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r := slog.NewRecord(DefaultClock.Now(), slog.LevelDebug, msg, 0)
+	r.AddAttrs(slog.String(TraceEventKey, event))
+	r.AddAttrs(attrs...)
+	_ = holder.handler.Handle(ctx, r)
+}
+
+// TraceEnabled returns whether or not tracing is enabled.
+func TraceEnabled() bool {
+	return traceCompiled && tracer.Load() != nil
+}
+
+// ChildHandlers returns the underlying handler.
+func (h *TraceHandler) ChildHandlers() []slog.Handler {
+	return []slog.Handler{h.handler}
+}
+
+// Enabled returns whether or not the underlying handler is enabled for the given level, tracing the record as filtered
+// if it is not.
+func (h *TraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.handler.Enabled(ctx, level) {
+		return true
+	}
+	if TraceEnabled() {
+		Trace(ctx, TraceEventRecordFiltered, "record filtered", slog.String("handler", h.name),
+			slog.String("record_level", level.String()))
+	}
+	return false
+}
+
+// Handle passes the record to the underlying handler and traces whether it was accepted or failed.
+func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
+	err := h.handler.Handle(ctx, r)
+	if !TraceEnabled() {
+		return err
+	}
+	attrs := []slog.Attr{
+		slog.String("handler", h.name),
+		slog.String("record_level", r.Level.String()),
+		slog.String("record_msg", r.Message),
+	}
+	if err != nil {
+		Trace(ctx, TraceEventRecordFailed, "record failed", append(attrs, slog.String("error", err.Error()))...)
+	} else {
+		Trace(ctx, TraceEventRecordAccepted, "record accepted", attrs...)
+	}
+	return err
+}
+
+// Options returns the name of the handler in trace records.
+func (h *TraceHandler) Options() any {
+	return h.name
+}
+
+// Type returns the type of the handler.
+func (h *TraceHandler) Type() string {
+	return "trace"
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the current object's attributes and the given
+// attributes.
+func (h *TraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &TraceHandler{
+		handler: h.handler.WithAttrs(attrs),
+		name:    h.name,
+	}
+}
+
+// WithGroup returns a new handler with the existing object's attributes part of the given group.
+func (h *TraceHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &TraceHandler{
+		handler: h.handler.WithGroup(name),
+		name:    h.name,
+	}
+}
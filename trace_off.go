@@ -0,0 +1,8 @@
+//go:build xlog_notrace
+
+package xlog
+
+// traceCompiled indicates whether or not tracing is compiled into the package.
+//
+// The package was built with the "xlog_notrace" tag, so tracing is removed entirely.
+const traceCompiled = false
@@ -0,0 +1,8 @@
+//go:build !xlog_notrace
+
+package xlog
+
+// traceCompiled indicates whether or not tracing is compiled into the package.
+//
+// Build with the "xlog_notrace" tag to remove tracing entirely.
+const traceCompiled = true
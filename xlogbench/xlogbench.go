@@ -0,0 +1,255 @@
+// Package xlogbench measures the throughput, allocations and flush latency of handler configurations so that
+// performance work and regressions are measurable.
+//
+// Use [Run] to measure a handler from a program (eg: to compare candidate configurations before deploying them) and
+// [Benchmark] to measure one from a Go benchmark, where the measurements are reported as custom metrics which can be
+// compared across runs using benchstat:
+//
+//	func BenchmarkFileHandler(b *testing.B) {
+//		h, _ := handlers.NewFileHandler(options)
+//		xlogbench.Benchmark(b, h, xlogbench.Options{Goroutines: 8})
+//	}
+package xlogbench
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.innotegrity.dev/xlog"
+)
+
+const (
+	// deadlineCheckInterval is the number of records each goroutine logs between checks of the deadline, so that
+	// reading the clock does not dominate the cost of cheap handlers.
+	deadlineCheckInterval = 64
+)
+
+var (
+	// DefaultDuration is the default length of time for which [Run] logs records.
+	//
+	// This value is used when the duration in [Options] is 0.
+	//
+	// Setting this value changes the default globally for the package.
+	//
+	// References:
+	//   https://pkg.go.dev/go.innotegrity.dev/xlog/xlogbench#Options
+	DefaultDuration = time.Second
+)
+
+// Options holds the options for [Run] and [Benchmark].
+type Options struct {
+	// Duration is the length of time for which [Run] logs records.
+	//
+	// [Benchmark] ignores this value since the benchmark framework decides how many records to log.
+	//
+	// The default behavior is defined by the default duration setting defined in the package.
+	Duration time.Duration
+
+	// Flush is a function that's called to flush the handler once all of the records have been logged so that the
+	// flush latency can be measured.
+	//
+	// Use it for handlers which buffer records but are only flushed when they are closed (eg: the file handler).
+	//
+	// The default behavior is to flush every handler in the tree which implements [xlog.Flusher].
+	Flush func(h slog.Handler) error
+
+	// Goroutines is the number of goroutines logging records concurrently.
+	//
+	// The default behavior is to log records from a single goroutine.
+	Goroutines int
+
+	// Record is a function that's called to create the record logged by each goroutine.
+	//
+	// The record is created once per goroutine and logged repeatedly, so it should not be modified by the handler.
+	//
+	// The default behavior is to log the record returned by [NewRecord].
+	Record func() slog.Record
+
+	// Records is the maximum number of records [Run] logs across all goroutines.
+	//
+	// [Benchmark] ignores this value since the benchmark framework decides how many records to log.
+	//
+	// The default behavior is to log records until the duration has elapsed.
+	Records int
+}
+
+// Result holds the measurements of a single run of a handler.
+type Result struct {
+	// AllocsPerRecord is the average number of heap allocations made per record.
+	//
+	// Allocations made by any goroutine in the process while the records were being logged are included (eg: those
+	// of background workers started by the handler).
+	AllocsPerRecord float64 `json:"allocs_per_record"`
+
+	// BytesPerRecord is the average number of bytes allocated on the heap per record.
+	BytesPerRecord float64 `json:"bytes_per_record"`
+
+	// Duration is the length of time spent logging the records, excluding the final flush.
+	Duration time.Duration `json:"duration"`
+
+	// Errors is the number of records for which the handler returned an error.
+	Errors uint64 `json:"errors"`
+
+	// FlushLatency is the length of time the final flush of the handler took.
+	FlushLatency time.Duration `json:"flush_latency"`
+
+	// Records is the number of records logged.
+	Records uint64 `json:"records"`
+
+	// RecordsPerSecond is the number of records logged per second.
+	RecordsPerSecond float64 `json:"records_per_second"`
+}
+
+// Benchmark logs records to the given handler from within a Go benchmark, with each benchmark iteration logging a
+// single record.
+//
+// In addition to the standard measurements (where allocs/op is the number of allocations per record), the number of
+// records logged per second is reported as the "records/s" metric and the length of time the final flush of the
+// handler took as the "flush-ns" metric. If the handler returns an error, the benchmark fails.
+func Benchmark(b *testing.B, h slog.Handler, options Options) {
+	b.Helper()
+	options = options.withDefaults()
+	ctx := context.Background()
+
+	var firstErr atomic.Pointer[error]
+	b.ReportAllocs()
+	if options.Goroutines <= 1 {
+		r := options.Record()
+		for b.Loop() {
+			if err := h.Handle(ctx, r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	} else {
+		b.SetParallelism(max(1, (options.Goroutines+runtime.GOMAXPROCS(0)-1)/runtime.GOMAXPROCS(0)))
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := options.Record()
+			for pb.Next() {
+				if err := h.Handle(ctx, r); err != nil {
+					firstErr.CompareAndSwap(nil, &err)
+				}
+			}
+		})
+		b.StopTimer()
+	}
+	if err := firstErr.Load(); err != nil {
+		b.Fatal(*err)
+	}
+
+	start := time.Now()
+	if err := options.Flush(h); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds()), "flush-ns")
+	if elapsed := b.Elapsed(); elapsed > 0 {
+		b.ReportMetric(float64(b.N)/elapsed.Seconds(), "records/s")
+	}
+}
+
+// NewRecord returns a record representative of a typical application log record: an informational message with a
+// handful of attributes of the most common kinds.
+func NewRecord() slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "request completed", 0)
+	r.AddAttrs(
+		slog.String("method", "GET"),
+		slog.String("path", "/api/v1/items"),
+		slog.Int("status", 200),
+		slog.Duration("elapsed", 1500*time.Microsecond),
+		slog.Any("error", errors.New("connection reset by peer")),
+	)
+	return r
+}
+
+// Run logs records to the given handler until the duration has elapsed, the maximum number of records has been
+// logged or the context is canceled, flushes the handler and returns the measurements.
+//
+// The context is passed to the handler with each record. Errors returned by the handler are counted rather than
+// stopping the run. The error returned by the flush, if any, is returned along with the measurements.
+func Run(ctx context.Context, h slog.Handler, options Options) (Result, error) {
+	options = options.withDefaults()
+	var records, errs atomic.Uint64
+	limit := uint64(options.Records)
+	deadline := time.Now().Add(options.Duration)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range max(1, options.Goroutines) {
+		wg.Go(func() {
+			r := options.Record()
+			for i := 0; ; i++ {
+				if i%deadlineCheckInterval == 0 && (ctx.Err() != nil || time.Now().After(deadline)) {
+					return
+				}
+				if n := records.Add(1); limit > 0 && n > limit {
+					records.Add(^uint64(0))
+					return
+				}
+				if err := h.Handle(ctx, r); err != nil {
+					errs.Add(1)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	result := Result{
+		Duration: elapsed,
+		Errors:   errs.Load(),
+		Records:  records.Load(),
+	}
+	if result.Records > 0 {
+		result.AllocsPerRecord = float64(after.Mallocs-before.Mallocs) / float64(result.Records)
+		result.BytesPerRecord = float64(after.TotalAlloc-before.TotalAlloc) / float64(result.Records)
+	}
+	if elapsed > 0 {
+		result.RecordsPerSecond = float64(result.Records) / elapsed.Seconds()
+	}
+
+	flushStart := time.Now()
+	err := options.Flush(h)
+	result.FlushLatency = time.Since(flushStart)
+	return result, err
+}
+
+// withDefaults returns a copy of the options with the defaults applied.
+func (o Options) withDefaults() Options {
+	if o.Duration <= 0 {
+		o.Duration = DefaultDuration
+	}
+	if o.Flush == nil {
+		o.Flush = flushTree
+	}
+	if o.Record == nil {
+		o.Record = NewRecord
+	}
+	return o
+}
+
+// flushTree flushes the given handler and all of its children which implement [xlog.Flusher].
+func flushTree(h slog.Handler) error {
+	var errs []error
+	if flusher, ok := h.(xlog.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if eh, ok := h.(xlog.ExtendedHandler); ok {
+		for _, child := range eh.ChildHandlers() {
+			if err := flushTree(child); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}